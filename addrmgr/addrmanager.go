@@ -47,6 +47,7 @@ type AddrManager struct {
 	lamtx          sync.Mutex
 	localAddresses map[string]*localAddress
 	version        int
+	asmap          *ASMap
 }
 
 type serializedKnownAddress struct {
@@ -67,6 +68,7 @@ type serializedAddrManager struct {
 	Addresses    []*serializedKnownAddress
 	NewBuckets   [newBucketCount][]string // string is NetAddressKey
 	TriedBuckets [triedBucketCount][]string
+	ASMap        string `json:",omitempty"` // checksum of the asmap in use
 }
 
 type localAddress struct {
@@ -254,6 +256,7 @@ func (a *AddrManager) expireNew(bucket int) {
 			if v.refs == 0 {
 				a.nNew--
 				delete(a.addrIndex, k)
+				a.forgetI2P(v.na)
 			}
 			continue
 		}
@@ -273,10 +276,26 @@ func (a *AddrManager) expireNew(bucket int) {
 		if oldest.refs == 0 {
 			a.nNew--
 			delete(a.addrIndex, key)
+			a.forgetI2P(oldest.na)
 		}
 	}
 }
 
+// forgetI2P forgets the full address of the passed address when it is an I2P
+// address which is no longer known to the address manager.  This must be
+// called with the address manager lock held.
+func (a *AddrManager) forgetI2P(na *wire.NetAddress) {
+	if !IsI2P(na) {
+		return
+	}
+	for _, ka := range a.addrIndex {
+		if ka.na.IP.Equal(na.IP) {
+			return
+		}
+	}
+	forgetI2PHost(na)
+}
+
 // pickTried selects an address from the tried bucket to be evicted.
 // We just choose the eldest. Bitcoind selects 4 random entries and throws away
 // the older of them.
@@ -300,8 +319,8 @@ func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 
 	data1 := []byte{}
 	data1 = append(data1, a.key[:]...)
-	data1 = append(data1, []byte(GroupKey(netAddr))...)
-	data1 = append(data1, []byte(GroupKey(srcAddr))...)
+	data1 = append(data1, []byte(a.groupKey(netAddr))...)
+	data1 = append(data1, []byte(a.groupKey(srcAddr))...)
 	hash1 := chainhash.DoubleHashB(data1)
 	hash64 := binary.LittleEndian.Uint64(hash1)
 	hash64 %= newBucketsPerGroup
@@ -309,7 +328,7 @@ func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(srcAddr)...)
+	data2 = append(data2, a.groupKey(srcAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
@@ -329,7 +348,7 @@ func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(netAddr)...)
+	data2 = append(data2, a.groupKey(netAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
@@ -367,6 +386,9 @@ func (a *AddrManager) savePeers() {
 	sam := new(serializedAddrManager)
 	sam.Version = a.version
 	copy(sam.Key[:], a.key[:])
+	if a.asmap != nil {
+		sam.ASMap = a.asmap.checksum
+	}
 
 	sam.Addresses = make([]*serializedKnownAddress, len(a.addrIndex))
 	i := 0
@@ -543,9 +565,63 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 		}
 	}
 
+	// The buckets depend on the network groups of the addresses, so they
+	// need to be recalculated when the asmap changed since the addresses
+	// were saved.
+	var checksum string
+	if a.asmap != nil {
+		checksum = a.asmap.checksum
+	}
+	if sam.ASMap != checksum {
+		log.Infof("Asmap changed since the address manager state was "+
+			"saved, rebucketing %d addresses", len(a.addrIndex))
+		a.rebucket()
+	}
+
 	return nil
 }
 
+// rebucket redistributes all known addresses over the new and tried buckets
+// according to the current bucketing rules.  Tried addresses whose bucket is
+// already full are moved back to the new buckets, while new addresses whose
+// bucket is full are dropped.
+//
+// This function MUST be called with the address manager lock held (for
+// writes).
+func (a *AddrManager) rebucket() {
+	for i := range a.addrNew {
+		a.addrNew[i] = make(map[string]*KnownAddress)
+	}
+	for i := range a.addrTried {
+		a.addrTried[i] = list.New()
+	}
+	a.nNew = 0
+	a.nTried = 0
+
+	for k, ka := range a.addrIndex {
+		ka.refs = 0
+		if ka.tried {
+			bucket := a.getTriedBucket(ka.na)
+			if a.addrTried[bucket].Len() < triedBucketSize {
+				a.addrTried[bucket].PushBack(ka)
+				a.nTried++
+				continue
+			}
+			ka.tried = false
+		}
+
+		bucket := a.getNewBucket(ka.na, ka.srcAddr)
+		if len(a.addrNew[bucket]) >= newBucketSize {
+			delete(a.addrIndex, k)
+			a.forgetI2P(ka.na)
+			continue
+		}
+		ka.refs++
+		a.addrNew[bucket][k] = ka
+		a.nNew++
+	}
+}
+
 // DeserializeNetAddress converts a given address string to a *wire.NetAddress.
 func (a *AddrManager) DeserializeNetAddress(addr string,
 	services wire.ServiceFlag) (*wire.NetAddress, error) {
@@ -718,8 +794,8 @@ func (a *AddrManager) reset() {
 }
 
 // HostToNetAddress returns a netaddress given a host address.  If the address
-// is a Tor .onion address this will be taken care of.  Else if the host is
-// not an IP address it will be resolved (via Tor if required).
+// is a Tor .onion or an I2P .b32.i2p address this will be taken care of.  Else
+// if the host is not an IP address it will be resolved (via Tor if required).
 func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.ServiceFlag) (*wire.NetAddress, error) {
 	// Tor address is 16 char base32 + ".onion"
 	var ip net.IP
@@ -734,6 +810,12 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.S
 		}
 		prefix := []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
 		ip = net.IP(append(prefix, data...))
+	} else if strings.HasSuffix(strings.ToLower(host), I2PSuffix) {
+		var err error
+		ip, err = i2pHostToIP(host)
+		if err != nil {
+			return nil, err
+		}
 	} else if ip = net.ParseIP(host); ip == nil {
 		ips, err := a.lookupFunc(host)
 		if err != nil {
@@ -750,13 +832,19 @@ func (a *AddrManager) HostToNetAddress(host string, port uint16, services wire.S
 
 // ipString returns a string for the ip from the provided NetAddress. If the
 // ip is in the range used for Tor addresses then it will be transformed into
-// the relevant .onion address.
+// the relevant .onion address.  Likewise, known I2P addresses are transformed
+// into the relevant .b32.i2p address.
 func ipString(na *wire.NetAddress) string {
 	if IsOnionCatTor(na) {
 		// We know now that na.IP is long enough.
 		base32 := base32.StdEncoding.EncodeToString(na.IP[6:])
 		return strings.ToLower(base32) + ".onion"
 	}
+	if IsI2P(na) {
+		if host, ok := i2pHost(na); ok {
+			return host
+		}
+	}
 
 	return na.IP.String()
 }
@@ -1046,6 +1134,14 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Default
 	}
 
+	if IsI2P(remoteAddr) {
+		if IsI2P(localAddr) {
+			return Private
+		}
+
+		return Default
+	}
+
 	if IsRFC4380(remoteAddr) {
 		if !IsRoutable(localAddr) {
 			return Default
@@ -1135,6 +1231,51 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.Net
 	return bestAddress
 }
 
+// SetASMap sets the asmap used to group addresses by the autonomous system
+// announcing them rather than by their network prefix.  It must be called
+// before Start.
+func (a *AddrManager) SetASMap(m *ASMap) {
+	a.mtx.Lock()
+	a.asmap = m
+	a.mtx.Unlock()
+}
+
+// MappedAS returns the autonomous system number the passed address is mapped
+// to by the asmap in use, or zero when no asmap is in use or the address is
+// not mapped.
+func (a *AddrManager) MappedAS(na *wire.NetAddress) uint32 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.asmap.Lookup(na)
+}
+
+// GroupKey returns a string representing the network group an address is part
+// of.  When an asmap is in use and the address is mapped, the group is the
+// autonomous system announcing the address in the form "as<number>".
+// Otherwise, it is the same as the package level GroupKey.
+func (a *AddrManager) GroupKey(na *wire.NetAddress) string {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.groupKey(na)
+}
+
+// groupKey returns the network group of the passed address as described by
+// GroupKey.
+//
+// This function MUST be called with the address manager lock held (for
+// reads).
+func (a *AddrManager) groupKey(na *wire.NetAddress) string {
+	if IsLocal(na) || !IsRoutable(na) {
+		return GroupKey(na)
+	}
+	if asn := a.asmap.Lookup(na); asn != 0 {
+		return fmt.Sprintf("as%d", asn)
+	}
+	return GroupKey(na)
+}
+
 // New returns a new bitcoin address manager.
 // Use Start to begin processing asynchronous address updates.
 func New(dataDir string, lookupFunc func(string) ([]net.IP, error)) *AddrManager {
@@ -5,6 +5,7 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/eacsuite/eacd/wire"
@@ -192,3 +193,45 @@ func TestAddrManagerV1ToV2(t *testing.T) {
 	addrMgr.loadPeers()
 	assertAddrs(t, addrMgr, expectedAddrs)
 }
+
+// TestI2PHosts ensures the number of remembered I2P addresses is limited and
+// that they are forgotten once the address manager no longer knows them.
+func TestI2PHosts(t *testing.T) {
+	newHost := func(i int) string {
+		var hash [32]byte
+		hash[0], hash[1] = byte(i>>8), byte(i)
+		return strings.ToLower(i2pEncoding.EncodeToString(hash[:])) +
+			I2PSuffix
+	}
+	for i := 0; i < maxI2PHosts+10; i++ {
+		if _, err := i2pHostToIP(newHost(i)); err != nil {
+			t.Fatalf("i2pHostToIP: unexpected error: %v", err)
+		}
+	}
+	i2pHostsMtx.Lock()
+	numHosts := len(i2pHosts)
+	i2pHostsMtx.Unlock()
+	if numHosts != maxI2PHosts {
+		t.Fatalf("got %d I2P addresses, want %d", numHosts, maxI2PHosts)
+	}
+
+	// Addresses are only forgotten once no known address has the same
+	// destination.
+	addrMgr := New("testi2phosts", nil)
+	ip, err := i2pHostToIP(newHost(0))
+	if err != nil {
+		t.Fatalf("i2pHostToIP: unexpected error: %v", err)
+	}
+	na := wire.NewNetAddressIPPort(ip, 9333, 0)
+	known := wire.NewNetAddressIPPort(ip, 9334, 0)
+	addrMgr.addrIndex[NetAddressKey(known)] = &KnownAddress{na: known}
+	addrMgr.forgetI2P(na)
+	if _, ok := i2pHost(na); !ok {
+		t.Fatalf("I2P address forgotten while it is still known")
+	}
+	addrMgr.addrIndex = make(map[string]*KnownAddress)
+	addrMgr.forgetI2P(na)
+	if _, ok := i2pHost(na); ok {
+		t.Fatalf("I2P address remembered after it was forgotten")
+	}
+}
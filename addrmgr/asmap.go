@@ -0,0 +1,353 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/bits"
+	"net"
+
+	"github.com/eacsuite/eacd/wire"
+)
+
+// asmapInvalid is returned by the asmap decoding functions when the encoded
+// value straddles the end of the asmap.
+const asmapInvalid = 0xffffffff
+
+// asmapInstruction is an opcode of the asmap interpreter.
+type asmapInstruction uint32
+
+const (
+	// asmapReturn terminates the lookup with the encoded AS number.
+	asmapReturn asmapInstruction = iota
+
+	// asmapJump consumes a single input bit and skips the encoded number of
+	// bits when it is set.
+	asmapJump
+
+	// asmapMatch compares a run of input bits against the encoded bits and
+	// terminates the lookup with the default AS number on a mismatch.
+	asmapMatch
+
+	// asmapDefault sets the AS number returned by a failed match.
+	asmapDefault
+)
+
+// These are the bit sizes of the variable length integers used to encode
+// instruction types, AS numbers, match bits and jump offsets.
+var (
+	asmapTypeBitSizes  = []uint8{0, 0, 1}
+	asmapASNBitSizes   = []uint8{15, 16, 17, 18, 19, 20, 21, 22, 23, 24}
+	asmapMatchBitSizes = []uint8{1, 2, 3, 4, 5, 6, 7, 8}
+	asmapJumpBitSizes  = []uint8{5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+		17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30}
+)
+
+// ASMap maps IP prefixes to the autonomous system (AS) numbers announcing
+// them.  It uses the compact binary trie format produced by the asmap tooling
+// shipped with Bitcoin Core, which makes it possible to share the same files
+// between implementations.
+type ASMap struct {
+	data     []byte
+	checksum string
+}
+
+// NewASMap returns an ASMap backed by the passed encoded asmap after verifying
+// that it is well formed.
+func NewASMap(data []byte) (*ASMap, error) {
+	m := &ASMap{data: data}
+	if !m.sanityCheck(128) {
+		return nil, errors.New("malformed asmap")
+	}
+	sum := sha256.Sum256(data)
+	m.checksum = hex.EncodeToString(sum[:])
+	return m, nil
+}
+
+// LoadASMap reads and validates the asmap file at the given path.
+func LoadASMap(path string) (*ASMap, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := NewASMap(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return m, nil
+}
+
+// bit returns the bit of the asmap at the given position.  Bits are stored
+// least significant first within each byte.
+func (m *ASMap) bit(pos int) bool {
+	return m.data[pos/8]>>(uint(pos)%8)&1 == 1
+}
+
+// numBits returns the total number of bits in the asmap.
+func (m *ASMap) numBits() int {
+	return len(m.data) * 8
+}
+
+// decodeBits decodes a variable length integer starting at the passed bit
+// position, advancing it past the encoded value.  asmapInvalid is returned when
+// the value straddles the end of the asmap.
+func (m *ASMap) decodeBits(pos *int, minVal uint32, bitSizes []uint8) uint32 {
+	val := minVal
+	end := m.numBits()
+	for i, size := range bitSizes {
+		var bit bool
+		if i+1 != len(bitSizes) {
+			if *pos == end {
+				break
+			}
+			bit = m.bit(*pos)
+			*pos++
+		}
+		if bit {
+			val += 1 << size
+			continue
+		}
+		for b := uint8(0); b < size; b++ {
+			if *pos == end {
+				return asmapInvalid
+			}
+			if m.bit(*pos) {
+				val += 1 << (size - 1 - b)
+			}
+			*pos++
+		}
+		return val
+	}
+	return asmapInvalid
+}
+
+func (m *ASMap) decodeType(pos *int) asmapInstruction {
+	return asmapInstruction(m.decodeBits(pos, 0, asmapTypeBitSizes))
+}
+
+func (m *ASMap) decodeASN(pos *int) uint32 {
+	return m.decodeBits(pos, 1, asmapASNBitSizes)
+}
+
+func (m *ASMap) decodeMatch(pos *int) uint32 {
+	return m.decodeBits(pos, 2, asmapMatchBitSizes)
+}
+
+func (m *ASMap) decodeJump(pos *int) uint32 {
+	return m.decodeBits(pos, 17, asmapJumpBitSizes)
+}
+
+// interpret runs the asmap program against the passed 128-bit address and
+// returns the resulting AS number.  Zero is returned when the address is not
+// mapped.
+func (m *ASMap) interpret(ip net.IP) uint32 {
+	ipBit := func(i int) bool {
+		return ip[i/8]>>(7-uint(i)%8)&1 == 1
+	}
+
+	pos, end := 0, m.numBits()
+	remaining := len(ip) * 8
+	var defaultASN uint32
+	for pos != end {
+		switch m.decodeType(&pos) {
+		case asmapReturn:
+			asn := m.decodeASN(&pos)
+			if asn == asmapInvalid {
+				return 0
+			}
+			return asn
+
+		case asmapJump:
+			jump := m.decodeJump(&pos)
+			if jump == asmapInvalid || remaining == 0 ||
+				int64(jump) >= int64(end-pos) {
+				return 0
+			}
+			if ipBit(len(ip)*8 - remaining) {
+				pos += int(jump)
+			}
+			remaining--
+
+		case asmapMatch:
+			match := m.decodeMatch(&pos)
+			if match == asmapInvalid {
+				return 0
+			}
+			matchLen := bits.Len32(match) - 1
+			if remaining < matchLen {
+				return 0
+			}
+			for b := 0; b < matchLen; b++ {
+				want := match>>uint(matchLen-1-b)&1 == 1
+				if ipBit(len(ip)*8-remaining) != want {
+					return defaultASN
+				}
+				remaining--
+			}
+
+		case asmapDefault:
+			defaultASN = m.decodeASN(&pos)
+			if defaultASN == asmapInvalid {
+				return 0
+			}
+
+		default:
+			return 0
+		}
+	}
+
+	// The sanity checks performed when the asmap is created guarantee
+	// that every lookup terminates with a return instruction.
+	return 0
+}
+
+// sanityCheck returns whether the asmap is a well formed program for inputs
+// of the given number of bits.  It ensures that every lookup terminates with a
+// return instruction, that jumps neither leave the program nor intersect, and
+// that no unreachable code or excessive padding is present.
+func (m *ASMap) sanityCheck(numBits int) bool {
+	type jumpTarget struct {
+		offset int
+		bits   int
+	}
+
+	pos, end := 0, m.numBits()
+	var jumps []jumpTarget
+	prevOpcode := asmapJump
+	hadIncompleteMatch := false
+	for pos != end {
+		// There must not be a jump into the middle of the previous
+		// instruction.
+		if len(jumps) > 0 && pos >= jumps[len(jumps)-1].offset {
+			return false
+		}
+
+		switch m.decodeType(&pos) {
+		case asmapReturn:
+			// A return directly after a default could have been
+			// encoded as just the return.
+			if prevOpcode == asmapDefault {
+				return false
+			}
+			if m.decodeASN(&pos) == asmapInvalid {
+				return false
+			}
+			if len(jumps) == 0 {
+				// Nothing is left to execute, so only up to
+				// seven zero bits of padding may remain.
+				if end-pos > 7 {
+					return false
+				}
+				for ; pos != end; pos++ {
+					if m.bit(pos) {
+						return false
+					}
+				}
+				return true
+			}
+
+			// Continue as if the last jump was taken, which must
+			// lead exactly to the next instruction.
+			last := jumps[len(jumps)-1]
+			if pos != last.offset {
+				return false
+			}
+			numBits = last.bits
+			jumps = jumps[:len(jumps)-1]
+			prevOpcode = asmapJump
+
+		case asmapJump:
+			jump := m.decodeJump(&pos)
+			if jump == asmapInvalid || int64(jump) > int64(end-pos) {
+				return false
+			}
+			if numBits == 0 {
+				return false
+			}
+			numBits--
+			offset := pos + int(jump)
+			if len(jumps) > 0 && offset >= jumps[len(jumps)-1].offset {
+				return false
+			}
+			jumps = append(jumps, jumpTarget{offset, numBits})
+			prevOpcode = asmapJump
+
+		case asmapMatch:
+			match := m.decodeMatch(&pos)
+			if match == asmapInvalid {
+				return false
+			}
+			matchLen := bits.Len32(match) - 1
+			if prevOpcode != asmapMatch {
+				hadIncompleteMatch = false
+			}
+
+			// Within a sequence of matches at most one may match
+			// fewer than eight bits.
+			if matchLen < 8 && hadIncompleteMatch {
+				return false
+			}
+			hadIncompleteMatch = matchLen < 8
+			if numBits < matchLen {
+				return false
+			}
+			numBits -= matchLen
+			prevOpcode = asmapMatch
+
+		case asmapDefault:
+			if prevOpcode == asmapDefault {
+				return false
+			}
+			if m.decodeASN(&pos) == asmapInvalid {
+				return false
+			}
+			prevOpcode = asmapDefault
+
+		default:
+			return false
+		}
+	}
+
+	// The end of the asmap was reached without a return instruction.
+	return false
+}
+
+// Lookup returns the AS number the passed address is mapped to, or zero when
+// it is not mapped.  Only IPv4 and IPv6 addresses can be mapped.  Addresses
+// which embed an IPv4 address, such as 6to4 and Teredo addresses, are mapped
+// using the embedded address.
+func (m *ASMap) Lookup(na *wire.NetAddress) uint32 {
+	if m == nil || na == nil || IsOnionCatTor(na) || IsI2P(na) {
+		return 0
+	}
+
+	var ip4 net.IP
+	switch {
+	case IsIPv4(na):
+		ip4 = na.IP.To4()
+	case IsRFC6145(na) || IsRFC6052(na):
+		ip4 = net.IP(na.IP[12:16])
+	case IsRFC3964(na):
+		ip4 = net.IP(na.IP[2:6])
+	case IsRFC4380(na):
+		ip4 = make(net.IP, 4)
+		for i, b := range na.IP[12:16] {
+			ip4[i] = b ^ 0xff
+		}
+	}
+	if ip4 != nil {
+		return m.interpret(ip4.To16())
+	}
+
+	ip := na.IP.To16()
+	if ip == nil {
+		return 0
+	}
+	return m.interpret(ip)
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/eacsuite/eacd/wire"
+)
+
+// asmapWriter builds encoded asmaps for tests.
+type asmapWriter struct {
+	data []byte
+	n    int
+}
+
+// writeBit appends a single bit to the asmap.
+func (w *asmapWriter) writeBit(bit bool) {
+	if w.n%8 == 0 {
+		w.data = append(w.data, 0)
+	}
+	if bit {
+		w.data[w.n/8] |= 1 << uint(w.n%8)
+	}
+	w.n++
+}
+
+// writeBits appends the low n bits of val, most significant first.
+func (w *asmapWriter) writeBits(val uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.writeBit(val>>uint(i)&1 == 1)
+	}
+}
+
+// asn appends an AS number that fits in the smallest encoding class.
+func (w *asmapWriter) asn(asn uint32) {
+	w.writeBit(false)
+	w.writeBits(asn-1, 15)
+}
+
+// ret appends a return instruction for the given AS number.
+func (w *asmapWriter) ret(asn uint32) {
+	w.writeBit(false)
+	w.asn(asn)
+}
+
+// def appends a default instruction for the given AS number.
+func (w *asmapWriter) def(asn uint32) {
+	w.writeBits(0x7, 3)
+	w.asn(asn)
+}
+
+// match appends a match instruction for all eight bits of b.
+func (w *asmapWriter) match(b byte) {
+	w.writeBits(0x6, 3)
+	w.writeBits(0x7f, 7)
+	w.writeBits(uint32(b), 8)
+}
+
+// jump appends a jump instruction skipping the given number of bits, which
+// must be in the smallest encoding class.
+func (w *asmapWriter) jump(offset uint32) {
+	w.writeBits(0x2, 2)
+	w.writeBit(false)
+	w.writeBits(offset-17, 5)
+}
+
+// testASMap returns an asmap which maps IPv4 addresses in 0.0.0.0/1 to AS 100,
+// those in 128.0.0.0/1 to AS 200 and everything else to AS 300.
+func testASMap(t *testing.T) *ASMap {
+	t.Helper()
+
+	var w asmapWriter
+	w.def(300)
+	for _, b := range net.IPv4(0, 0, 0, 0)[:12] {
+		w.match(b)
+	}
+	// A return with a small AS number is 17 bits long.
+	w.jump(17)
+	w.ret(100)
+	w.ret(200)
+
+	m, err := NewASMap(w.data)
+	if err != nil {
+		t.Fatalf("unable to create asmap: %v", err)
+	}
+	return m
+}
+
+// TestASMapLookup ensures addresses are mapped to the expected AS numbers.
+func TestASMapLookup(t *testing.T) {
+	m := testASMap(t)
+
+	tests := []struct {
+		ip   string
+		want uint32
+	}{
+		{"1.2.3.4", 100},
+		{"127.255.0.1", 100},
+		{"128.0.0.1", 200},
+		{"200.1.2.3", 200},
+		{"2001:470::1", 300},
+		// 6to4 and Teredo addresses use the embedded IPv4 address.
+		{"2002:c801:0203::1", 200},
+		{"2001:0:4136:e378:8000:63bf:fefd:fcfb", 100},
+		// Tor addresses are never mapped.
+		{"fd87:d87e:eb43:1234::1", 0},
+	}
+	for _, test := range tests {
+		na := wire.NewNetAddressIPPort(net.ParseIP(test.ip), 8333, 0)
+		if got := m.Lookup(na); got != test.want {
+			t.Errorf("Lookup(%s): got %d, want %d", test.ip, got,
+				test.want)
+		}
+	}
+
+	var nilMap *ASMap
+	na := wire.NewNetAddressIPPort(net.ParseIP("1.2.3.4"), 8333, 0)
+	if got := nilMap.Lookup(na); got != 0 {
+		t.Errorf("Lookup with nil asmap: got %d, want 0", got)
+	}
+}
+
+// TestASMapMalformed ensures malformed asmaps are rejected.
+func TestASMapMalformed(t *testing.T) {
+	var ret asmapWriter
+	ret.ret(100)
+
+	var noReturn asmapWriter
+	noReturn.def(100)
+
+	var defaultReturn asmapWriter
+	defaultReturn.def(100)
+	defaultReturn.ret(200)
+
+	var badJump asmapWriter
+	badJump.jump(45)
+	badJump.ret(100)
+	badJump.ret(200)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"truncated", ret.data[:1]},
+		{"nonzero padding", append(append([]byte{}, ret.data...), 0x01)},
+		{"excessive padding", append(append([]byte{}, ret.data...), 0x00)},
+		{"missing return", noReturn.data},
+		{"return after default", defaultReturn.data},
+		{"jump past end", badJump.data},
+	}
+	for _, test := range tests {
+		if _, err := NewASMap(test.data); err == nil {
+			t.Errorf("%s: malformed asmap was accepted", test.name)
+		}
+	}
+
+	if _, err := NewASMap(ret.data); err != nil {
+		t.Errorf("valid asmap was rejected: %v", err)
+	}
+}
+
+// TestGroupKeyASMap ensures the address manager groups mapped addresses by
+// autonomous system when an asmap is in use.
+func TestGroupKeyASMap(t *testing.T) {
+	addrMgr := New("", nil)
+
+	mapped := wire.NewNetAddressIPPort(net.ParseIP("1.2.3.4"), 8333, 0)
+	unroutable := wire.NewNetAddressIPPort(net.ParseIP("10.0.0.1"), 8333, 0)
+	tor := wire.NewNetAddressIPPort(net.ParseIP("fd87:d87e:eb43:1234::1"),
+		8333, 0)
+
+	if got := addrMgr.GroupKey(mapped); got != GroupKey(mapped) {
+		t.Fatalf("GroupKey without asmap: got %s, want %s", got,
+			GroupKey(mapped))
+	}
+
+	addrMgr.SetASMap(testASMap(t))
+	tests := []struct {
+		na   *wire.NetAddress
+		want string
+	}{
+		{mapped, "as100"},
+		{unroutable, "unroutable"},
+		{tor, GroupKey(tor)},
+	}
+	for _, test := range tests {
+		if got := addrMgr.GroupKey(test.na); got != test.want {
+			t.Errorf("GroupKey(%s): got %s, want %s", test.na.IP, got,
+				test.want)
+		}
+	}
+	if got := addrMgr.MappedAS(mapped); got != 100 {
+		t.Errorf("MappedAS: got %d, want 100", got)
+	}
+}
+
+// TestASMapRebucket ensures the addresses are redistributed over the buckets
+// when the asmap changed since they were saved.
+func TestASMapRebucket(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "addrmgr")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	addrMgr := New(tempDir, nil)
+	src := wire.NewNetAddressIPPort(net.ParseIP("173.194.115.66"), 8333, 0)
+	expectedAddrs := make(map[string]*wire.NetAddress)
+	for i := 0; i < 20; i++ {
+		ip := net.IPv4(byte(1+i*10), byte(i), 3, 4)
+		na := wire.NewNetAddressIPPort(ip, 8333, wire.SFNodeNetwork)
+		addrMgr.AddAddress(na, src)
+		if i%2 == 0 {
+			addrMgr.Good(na)
+		}
+		expectedAddrs[NetAddressKey(na)] = na
+	}
+	assertAddrs(t, addrMgr, expectedAddrs)
+	addrMgr.savePeers()
+
+	addrMgr = New(tempDir, nil)
+	addrMgr.SetASMap(testASMap(t))
+	addrMgr.loadPeers()
+	assertAddrs(t, addrMgr, expectedAddrs)
+
+	if addrMgr.nTried != 10 || addrMgr.nNew != 10 {
+		t.Fatalf("got %d tried and %d new addresses, want 10 of each",
+			addrMgr.nTried, addrMgr.nNew)
+	}
+	for i := range addrMgr.addrNew {
+		for _, ka := range addrMgr.addrNew[i] {
+			want := addrMgr.getNewBucket(ka.na, ka.srcAddr)
+			if i != want {
+				t.Errorf("new address %s in bucket %d, want %d",
+					NetAddressKey(ka.na), i, want)
+			}
+		}
+	}
+	for i := range addrMgr.addrTried {
+		for e := addrMgr.addrTried[i].Front(); e != nil; e = e.Next() {
+			ka := e.Value.(*KnownAddress)
+			want := addrMgr.getTriedBucket(ka.na)
+			if i != want {
+				t.Errorf("tried address %s in bucket %d, want %d",
+					NetAddressKey(ka.na), i, want)
+			}
+		}
+	}
+}
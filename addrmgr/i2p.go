@@ -0,0 +1,123 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package addrmgr
+
+import (
+	"encoding/base32"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/eacsuite/eacd/wire"
+)
+
+const (
+	// I2PSuffix is the suffix of the base32 addresses of I2P destinations.
+	I2PSuffix = ".b32.i2p"
+
+	// i2pHostLen is the length of a base32 I2P address, which is the
+	// unpadded base32 encoding of the 32 byte hash of the destination
+	// followed by the suffix.
+	i2pHostLen = 52 + len(I2PSuffix)
+
+	// maxI2PHosts is the maximum number of full I2P addresses remembered.
+	// An arbitrary one is forgotten to make room for another one once
+	// there are as many.
+	maxI2PHosts = 1000
+)
+
+var (
+	// garliCatNet defines the IPv6 address block used to support I2P.  An
+	// I2P address is encoded as a 16 byte number by storing the first ten
+	// bytes of the destination hash after the 6 byte GarliCat prefix
+	// 0xfd, 0x60, 0xdb, 0x4d, 0xdd, 0xb5.
+	//
+	// Since the encoding only keeps part of the destination hash, the full
+	// address can't be recovered from the IP alone.  Full addresses are
+	// therefore remembered when they are parsed with HostToNetAddress, and
+	// addresses in the range whose full address is unknown are treated as
+	// unroutable.  This also means I2P addresses can't be shared with other
+	// peers in addr messages, which only hold the IP, so they are only
+	// learned from the configuration and from inbound connections.
+	garliCatNet = ipNet("fd60:db4d:ddb5::", 48, 128)
+
+	// i2pEncoding is the unpadded base32 encoding used by I2P addresses.
+	i2pEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	// i2pHosts maps the GarliCat encoded part of the I2P destination hashes
+	// seen so far to their full base32 addresses.  It holds at most
+	// maxI2PHosts entries, and entries are removed once the address manager
+	// forgets their addresses.
+	i2pHosts    = make(map[[10]byte]string)
+	i2pHostsMtx sync.RWMutex
+
+	// errInvalidI2PAddress is returned when an I2P address can't be
+	// decoded.
+	errInvalidI2PAddress = errors.New("invalid I2P address")
+)
+
+// IsI2P returns whether or not the passed address is in the IPv6 range used to
+// support I2P (fd60:db4d:ddb5::/48).  Note that this range is the same range
+// used by GarliCat, which is part of the RFC4193 unique local IPv6 range.
+func IsI2P(na *wire.NetAddress) bool {
+	return garliCatNet.Contains(na.IP)
+}
+
+// i2pKey returns the key of the passed GarliCat address in the table of known
+// I2P addresses.
+func i2pKey(ip net.IP) [10]byte {
+	var key [10]byte
+	copy(key[:], ip.To16()[6:])
+	return key
+}
+
+// i2pHost returns the full base32 address of the passed GarliCat address and
+// whether it is known.
+func i2pHost(na *wire.NetAddress) (string, bool) {
+	i2pHostsMtx.RLock()
+	host, ok := i2pHosts[i2pKey(na.IP)]
+	i2pHostsMtx.RUnlock()
+	return host, ok
+}
+
+// i2pHostToIP decodes the passed base32 I2P address, remembers it, and returns
+// its GarliCat encoding.
+func i2pHostToIP(host string) (net.IP, error) {
+	host = strings.ToLower(host)
+	if len(host) != i2pHostLen || !strings.HasSuffix(host, I2PSuffix) {
+		return nil, errInvalidI2PAddress
+	}
+	hash, err := i2pEncoding.DecodeString(strings.ToUpper(
+		strings.TrimSuffix(host, I2PSuffix)))
+	if err != nil || len(hash) != 32 {
+		return nil, errInvalidI2PAddress
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, garliCatNet.IP.To16()[:6])
+	copy(ip[6:], hash)
+
+	key := i2pKey(ip)
+	i2pHostsMtx.Lock()
+	if _, ok := i2pHosts[key]; !ok && len(i2pHosts) >= maxI2PHosts {
+		for k := range i2pHosts {
+			delete(i2pHosts, k)
+			break
+		}
+	}
+	i2pHosts[key] = host
+	i2pHostsMtx.Unlock()
+
+	return ip, nil
+}
+
+// forgetI2PHost forgets the full base32 address of the passed GarliCat
+// address.
+func forgetI2PHost(na *wire.NetAddress) {
+	i2pHostsMtx.Lock()
+	delete(i2pHosts, i2pKey(na.IP))
+	i2pHostsMtx.Unlock()
+}
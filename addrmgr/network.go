@@ -226,14 +226,26 @@ func IsRoutable(na *wire.NetAddress) bool {
 	return IsValid(na) && !(IsRFC1918(na) || IsRFC2544(na) ||
 		IsRFC3927(na) || IsRFC4862(na) || IsRFC3849(na) ||
 		IsRFC4843(na) || IsRFC5737(na) || IsRFC6598(na) ||
-		IsLocal(na) || (IsRFC4193(na) && !IsOnionCatTor(na)))
+		IsLocal(na) || (IsRFC4193(na) && !IsOnionCatTor(na) &&
+		!isKnownI2P(na)))
+}
+
+// isKnownI2P returns whether or not the passed address is an I2P address whose
+// full .b32.i2p address is known.  Only those can be connected to.
+func isKnownI2P(na *wire.NetAddress) bool {
+	if !IsI2P(na) {
+		return false
+	}
+	_, ok := i2pHost(na)
+	return ok
 }
 
 // GroupKey returns a string representing the network group an address is part
 // of.  This is the /16 for IPv4, the /32 (/36 for he.net) for IPv6, the string
 // "local" for a local address, the string "tor:key" where key is the /4 of the
-// onion address for Tor address, and the string "unroutable" for an unroutable
-// address.
+// onion address for Tor address, the string "i2p:key" where key is the /4 of the
+// destination hash for I2P addresses, and the string "unroutable" for an
+// unroutable address.
 func GroupKey(na *wire.NetAddress) string {
 	if IsLocal(na) {
 		return "local"
@@ -268,6 +280,10 @@ func GroupKey(na *wire.NetAddress) string {
 		// group is keyed off the first 4 bits of the actual onion key.
 		return fmt.Sprintf("tor:%d", na.IP[6]&((1<<4)-1))
 	}
+	if IsI2P(na) {
+		// group is keyed off the first 4 bits of the destination hash.
+		return fmt.Sprintf("i2p:%d", na.IP[6]&((1<<4)-1))
+	}
 
 	// OK, so now we know ourselves to be a IPv6 address.
 	// bitcoind uses /32 for everything, except for Hurricane Electric's
@@ -6,6 +6,7 @@ package addrmgr_test
 
 import (
 	"net"
+	"strings"
 	"testing"
 
 	"github.com/eacsuite/eacd/addrmgr"
@@ -166,6 +167,7 @@ func TestGroupKey(t *testing.T) {
 		{name: "ipv6 rfc4193 fc00::/7", ip: "fc00::1234", expected: "unroutable"},
 		{name: "ipv6 rfc4843 2001:10::/28", ip: "2001:10::1234", expected: "unroutable"},
 		{name: "ipv6 rfc4862 fe80::/64", ip: "fe80::1234", expected: "unroutable"},
+		{name: "ipv6 unknown i2p garlicat", ip: "fd60:db4d:ddb5:1234::5678", expected: "unroutable"},
 
 		// IPv4 normal.
 		{name: "ipv4 normal class a", ip: "12.1.2.3", expected: "12.1.0.0"},
@@ -200,3 +202,43 @@ func TestGroupKey(t *testing.T) {
 		}
 	}
 }
+
+// TestI2PAddress ensures I2P addresses are encoded as GarliCat addresses which
+// can be converted back to the full address once it is known.
+func TestI2PAddress(t *testing.T) {
+	const host = "bs4kgfbkh5f3swplfltjngibhij4qpyjrumhbgxniucyeu4y63fa.b32.i2p"
+	amgr := addrmgr.New("testi2paddress", nil)
+
+	na, err := amgr.HostToNetAddress(strings.ToUpper(host), 9333,
+		wire.SFNodeNetwork)
+	if err != nil {
+		t.Fatalf("HostToNetAddress: unexpected error: %v", err)
+	}
+	wantIP := net.ParseIP("fd60:db4d:ddb5:0cb8:a314:2a3f:4bb9:59eb")
+	if !na.IP.Equal(wantIP) {
+		t.Fatalf("HostToNetAddress: got ip %v, want %v", na.IP, wantIP)
+	}
+	if !addrmgr.IsI2P(na) || addrmgr.IsOnionCatTor(na) {
+		t.Fatalf("address %v is not identified as I2P", na.IP)
+	}
+	if !addrmgr.IsRoutable(na) {
+		t.Fatalf("known I2P address %v is not routable", na.IP)
+	}
+	if key := addrmgr.NetAddressKey(na); key != host+":9333" {
+		t.Fatalf("NetAddressKey: got %s, want %s", key, host+":9333")
+	}
+	if key := addrmgr.GroupKey(na); key != "i2p:12" {
+		t.Fatalf("GroupKey: got %s, want i2p:12", key)
+	}
+
+	invalid := []string{
+		"bs4kgfbkh5f3swplfltjngibhij4qpyjrumhbgxniucyeu4y63f.b32.i2p",
+		"bs4kgfbkh5f3swplfltjngibhij4qpyjrumhbgxniucyeu4y63f1.b32.i2p",
+	}
+	for _, host := range invalid {
+		if _, err := amgr.HostToNetAddress(host, 9333, 0); err == nil {
+			t.Errorf("HostToNetAddress: invalid address %s was "+
+				"accepted", host)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// anchorsFilename is the name of the file in the data directory used to
+// persist the addresses of the block-relay-only peers that were connected at
+// shutdown as a JSON list.
+const anchorsFilename = "anchors"
+
+// loadAnchors reads the block-relay-only peer addresses persisted by a
+// previous session from the anchors file at the given path.  The file is
+// removed once it has been read so that a crash does not cause the same
+// anchors to be reused indefinitely.  Any errors are logged and result in no
+// anchors being returned since anchors are purely an optimization.
+func loadAnchors(path string) []net.Addr {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			srvrLog.Warnf("Unable to read anchors file %s: %v", path,
+				err)
+		}
+		return nil
+	}
+	if err := os.Remove(path); err != nil {
+		srvrLog.Warnf("Unable to remove anchors file %s: %v", path, err)
+	}
+
+	var addrStrs []string
+	if err := json.Unmarshal(data, &addrStrs); err != nil {
+		srvrLog.Warnf("Unable to parse anchors file %s: %v", path, err)
+		return nil
+	}
+
+	anchors := make([]net.Addr, 0, len(addrStrs))
+	for _, addrStr := range addrStrs {
+		addr, err := addrStringToNetAddr(addrStr)
+		if err != nil {
+			srvrLog.Debugf("Ignoring anchor %s: %v", addrStr, err)
+			continue
+		}
+		anchors = append(anchors, addr)
+	}
+	if len(anchors) > 0 {
+		srvrLog.Infof("Loaded %d block-relay-only %s from %s",
+			len(anchors), pickNoun(uint64(len(anchors)), "anchor",
+				"anchors"), path)
+	}
+	return anchors
+}
+
+// saveAnchors writes the addresses of all connected block-relay-only outbound
+// peers to the anchors file in the data directory.  It is invoked from the
+// peerHandler goroutine on shutdown.
+func (s *server) saveAnchors(state *peerState) {
+	var addrStrs []string
+	for _, sp := range state.outboundPeers {
+		if !sp.blockRelayOnly || !sp.Connected() || sp.connReq == nil {
+			continue
+		}
+		addrStrs = append(addrStrs, sp.connReq.Addr.String())
+	}
+	if len(addrStrs) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(addrStrs)
+	if err != nil {
+		srvrLog.Errorf("Unable to encode anchors: %v", err)
+		return
+	}
+	path := filepath.Join(cfg.DataDir, anchorsFilename)
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		srvrLog.Errorf("Unable to write anchors file %s: %v", path, err)
+		return
+	}
+	srvrLog.Infof("Saved %d block-relay-only %s to %s", len(addrStrs),
+		pickNoun(uint64(len(addrStrs)), "anchor", "anchors"), path)
+}
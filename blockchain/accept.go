@@ -62,16 +62,22 @@ func (b *BlockChain) maybeAcceptBlock(block *eacutil.Block, flags BehaviorFlags)
 
 	// Create a new block node for the block and add it to the node index. Even
 	// if the block ultimately gets connected to the main chain, it starts out
-	// on a side chain.
+	// on a side chain.  The node also records whether the proof of work has
+	// been verified, which takes the place of the entry in the proof of work
+	// cache.
 	blockHeader := &block.MsgBlock().Header
 	newNode := newBlockNode(blockHeader, prevNode)
 	newNode.status = statusDataStored
+	if flags&BFNoPoWCheck != BFNoPoWCheck {
+		newNode.status |= statusPowValid
+	}
 
 	b.index.AddNode(newNode)
 	err = b.index.flushToDB()
 	if err != nil {
 		return false, err
 	}
+	b.pow.forget(&newNode.hash)
 
 	// Connect the passed block to the chain while respecting proper chain
 	// selection according to the chain with the most proof of work.  This
@@ -34,6 +34,10 @@ const (
 	// has failed validation, thus the block is also invalid.
 	statusInvalidAncestor
 
+	// statusPowValid indicates that the proof of work of the block header
+	// has been verified, so it never needs to be hashed again.
+	statusPowValid
+
 	// statusNone indicates that the block has no validation state flags set.
 	//
 	// NOTE: This must be defined last in order to avoid influencing iota.
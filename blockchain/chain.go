@@ -126,6 +126,11 @@ type BlockChain struct {
 	index     *blockIndex
 	bestChain *chainView
 
+	// pow verifies the proof of work of block headers concurrently and
+	// caches the results until the blocks are added to the block index.
+	// It has its own lock.
+	pow *powVerifier
+
 	// These fields are related to handling of orphan blocks.  They are
 	// protected by a combination of the chain lock and the orphan lock.
 	orphanLock   sync.RWMutex
@@ -289,7 +294,7 @@ func (b *BlockChain) removeOrphanBlock(orphan *orphanBlock) {
 func (b *BlockChain) addOrphanBlock(block *eacutil.Block) {
 	// Remove expired orphan blocks.
 	for _, oBlock := range b.orphans {
-		if time.Now().After(oBlock.expiration) {
+		if Now().After(oBlock.expiration) {
 			b.removeOrphanBlock(oBlock)
 			continue
 		}
@@ -316,7 +321,7 @@ func (b *BlockChain) addOrphanBlock(block *eacutil.Block) {
 
 	// Insert the block into the orphan map with an expiration time
 	// 1 hour from now.
-	expiration := time.Now().Add(time.Hour)
+	expiration := Now().Add(time.Hour)
 	oBlock := &orphanBlock{
 		block:      block,
 		expiration: expiration,
@@ -1762,6 +1767,7 @@ func New(config *Config) (*BlockChain, error) {
 		index:               newBlockIndex(config.DB, params),
 		hashCache:           config.HashCache,
 		bestChain:           newChainView(nil),
+		pow:                 newPowVerifier(params.PowLimit),
 		orphans:             make(map[chainhash.Hash]*orphanBlock),
 		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
 		warningCaches:       newThresholdCaches(vbNumBits),
@@ -8,6 +8,7 @@ import (
 	"math"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -28,8 +29,53 @@ var (
 	// median time data.  This is a variable as opposed to a constant so the
 	// test code can modify it.
 	maxMedianTimeEntries = 200
+
+	// mockTime is the time returned by Now in place of the local clock as
+	// the number of seconds since the Unix epoch, or zero when the local
+	// clock is used.  It must be accessed atomically.
+	mockTime int64
 )
 
+// Now returns the current local time, or the mock time set with SetMockTime
+// when there is one.  It is the clock used by:
+//
+//   - the median time source, and so the adjusted time of the chain rules
+//   - the expiration of orphan blocks and orphan transactions
+//   - the memory pool entry and update times, the expiration of old
+//     transactions, the decay of the rolling minimum fee and the double
+//     spend records
+//   - the refresh of block templates by getblocktemplate, the CPU miner and
+//     the stratum server, and the stratum share time checks
+//
+// so tests can control the time those rules see.  Everything else, including
+// the sync stall detection, timeouts and rate measurements, uses the local
+// clock directly.
+//
+// This function is safe for concurrent access.
+func Now() time.Time {
+	if secs := atomic.LoadInt64(&mockTime); secs != 0 {
+		return time.Unix(secs, 0)
+	}
+	return time.Now()
+}
+
+// SetMockTime makes Now return the passed time instead of the local time,
+// limited to 1 second precision, until it is called again.  Passing the zero
+// time, or any time at or before the Unix epoch, makes Now use the local clock
+// again.
+//
+// It is intended for testing time dependent rules only and must never be used
+// on the main network.
+//
+// This function is safe for concurrent access.
+func SetMockTime(t time.Time) {
+	var secs int64
+	if !t.IsZero() && t.Unix() > 0 {
+		secs = t.Unix()
+	}
+	atomic.StoreInt64(&mockTime, secs)
+}
+
 // MedianTimeSource provides a mechanism to add several time samples which are
 // used to determine a median time which is then used as an offset to the local
 // clock.
@@ -95,7 +141,7 @@ func (m *medianTime) AdjustedTime() time.Time {
 	defer m.mtx.Unlock()
 
 	// Limit the adjusted time to 1 second precision.
-	now := time.Unix(time.Now().Unix(), 0)
+	now := time.Unix(Now().Unix(), 0)
 	return now.Add(time.Duration(m.offsetSecs) * time.Second)
 }
 
@@ -118,7 +164,7 @@ func (m *medianTime) AddTimeSample(sourceID string, timeVal time.Time) {
 	// of offsets while respecting the maximum number of allowed entries by
 	// replacing the oldest entry with the new entry once the maximum number
 	// of entries is reached.
-	now := time.Unix(time.Now().Unix(), 0)
+	now := time.Unix(Now().Unix(), 0)
 	offsetSecs := int64(timeVal.Sub(now).Seconds())
 	numOffsets := len(m.offsets)
 	if numOffsets == maxMedianTimeEntries && maxMedianTimeEntries > 0 {
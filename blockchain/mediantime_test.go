@@ -102,3 +102,38 @@ func TestMedianTime(t *testing.T) {
 		}
 	}
 }
+
+// TestMockTime ensures the median time source follows the mock time while one
+// is set and the local clock otherwise.
+//
+// NOTE: This test must not be run in parallel with other tests since the mock
+// time affects the whole package.
+func TestMockTime(t *testing.T) {
+	defer SetMockTime(time.Time{})
+
+	filter := NewMedianTime()
+	mockTime := time.Unix(1600000000, 0)
+	SetMockTime(mockTime.Add(500 * time.Millisecond))
+	if got := Now(); !got.Equal(mockTime) {
+		t.Fatalf("Now: got %v, want %v", got, mockTime)
+	}
+	if got := filter.AdjustedTime(); !got.Equal(mockTime) {
+		t.Fatalf("AdjustedTime: got %v, want %v", got, mockTime)
+	}
+
+	// Time samples are compared against the mock time.
+	for i := 0; i < 5; i++ {
+		filter.AddTimeSample(strconv.Itoa(i), mockTime.Add(time.Minute))
+	}
+	if got := filter.Offset(); got != time.Minute {
+		t.Fatalf("Offset: got %v, want %v", got, time.Minute)
+	}
+
+	// The local clock is used again once the mock time is reset.
+	SetMockTime(time.Time{})
+	if got := Now(); got.Sub(time.Now()) > time.Second ||
+		time.Since(got) > time.Second {
+
+		t.Fatalf("Now: got %v after resetting the mock time", got)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/wire"
+)
+
+const (
+	// maxPowCacheEntries is the maximum number of block hashes the proof
+	// of work verifier remembers as verified before their blocks are
+	// added to the block index.  This covers the headers downloaded ahead
+	// of their blocks during a headers-first sync while keeping the cache
+	// to a few megabytes.
+	maxPowCacheEntries = 100000
+)
+
+// powResult is the outcome of a proof of work check which may still be in
+// progress.  The error must only be read once done is closed.
+type powResult struct {
+	done chan struct{}
+	err  error
+}
+
+// powVerifier checks the proof of work of block headers on a pool of
+// goroutines sized to the number of CPUs.  Unlike the double-SHA256 of
+// bitcoin, the scrypt proof of work hash is expensive, so the verifier also
+// remembers which headers passed until their blocks are added to the block
+// index, where the result is kept in the status of the block node instead.
+type powVerifier struct {
+	powLimit *big.Int
+
+	// sem limits the number of proof of work checks which run
+	// concurrently to the number of CPUs.
+	sem chan struct{}
+
+	// pending houses the checks which are in progress, while verified
+	// houses the hashes of the headers which passed.
+	mtx      sync.Mutex
+	pending  map[chainhash.Hash]*powResult
+	verified map[chainhash.Hash]struct{}
+}
+
+// newPowVerifier returns a new proof of work verifier for headers which must
+// not have targets above the passed proof of work limit.
+func newPowVerifier(powLimit *big.Int) *powVerifier {
+	return &powVerifier{
+		powLimit: powLimit,
+		sem:      make(chan struct{}, runtime.NumCPU()),
+		pending:  make(map[chainhash.Hash]*powResult),
+		verified: make(map[chainhash.Hash]struct{}),
+	}
+}
+
+// lookup returns the result of the check of the header with the passed hash
+// when it is either in progress or passed, and nil otherwise.
+//
+// This function MUST be called with the verifier lock held.
+func (v *powVerifier) lookup(hash *chainhash.Hash) *powResult {
+	if result, ok := v.pending[*hash]; ok {
+		return result
+	}
+	if _, ok := v.verified[*hash]; ok {
+		result := &powResult{done: make(chan struct{})}
+		close(result.done)
+		return result
+	}
+	return nil
+}
+
+// finish records the outcome of a completed check and wakes its waiters.
+func (v *powVerifier) finish(hash *chainhash.Hash, result *powResult, err error) {
+	result.err = err
+	close(result.done)
+
+	v.mtx.Lock()
+	delete(v.pending, *hash)
+	if err == nil {
+		// Evict a random entry when the cache is full.  Go map
+		// iteration is randomized, so the first entry is arbitrary.
+		if len(v.verified) >= maxPowCacheEntries {
+			for evict := range v.verified {
+				delete(v.verified, evict)
+				break
+			}
+		}
+		v.verified[*hash] = struct{}{}
+	}
+	v.mtx.Unlock()
+}
+
+// verify starts checking the proof of work of all passed headers which are
+// neither already being checked nor known to pass and returns the results for
+// every header in the passed order.  When wait is set, the calling goroutine
+// takes part in the checks and the function only returns once they are all
+// done.
+//
+// This function is safe for concurrent access.
+func (v *powVerifier) verify(hashes []chainhash.Hash, headers []*wire.BlockHeader, wait bool) []*powResult {
+	type powJob struct {
+		hash   *chainhash.Hash
+		header *wire.BlockHeader
+		result *powResult
+	}
+
+	results := make([]*powResult, len(headers))
+	var jobs []powJob
+	v.mtx.Lock()
+	for i := range headers {
+		result := v.lookup(&hashes[i])
+		if result == nil {
+			result = &powResult{done: make(chan struct{})}
+			v.pending[hashes[i]] = result
+			jobs = append(jobs, powJob{&hashes[i], headers[i], result})
+		}
+		results[i] = result
+	}
+	v.mtx.Unlock()
+
+	// Hand the checks out to at most one worker per CPU.  The semaphore
+	// limits the number of checks which run at once across all callers.
+	var next int32 = -1
+	worker := func() {
+		for {
+			i := int(atomic.AddInt32(&next, 1))
+			if i >= len(jobs) {
+				return
+			}
+			job := &jobs[i]
+			v.sem <- struct{}{}
+			err := checkProofOfWork(job.header, v.powLimit, BFNone)
+			<-v.sem
+			v.finish(job.hash, job.result, err)
+		}
+	}
+	numWorkers := cap(v.sem)
+	if numWorkers > len(jobs) {
+		numWorkers = len(jobs)
+	}
+	if wait && numWorkers > 0 {
+		numWorkers--
+	}
+	for i := 0; i < numWorkers; i++ {
+		go worker()
+	}
+	if !wait {
+		return results
+	}
+
+	worker()
+	for _, result := range results {
+		<-result.done
+	}
+	return results
+}
+
+// check returns the result of the proof of work check of the passed header,
+// waiting for a check which is already in progress rather than hashing the
+// header again.
+//
+// This function is safe for concurrent access.
+func (v *powVerifier) check(hash *chainhash.Hash, header *wire.BlockHeader) error {
+	results := v.verify([]chainhash.Hash{*hash},
+		[]*wire.BlockHeader{header}, true)
+	return results[0].err
+}
+
+// forget removes the passed hash from the cache of verified headers.  It is
+// used once the result is kept in the status of a block node.
+//
+// This function is safe for concurrent access.
+func (v *powVerifier) forget(hash *chainhash.Hash) {
+	v.mtx.Lock()
+	delete(v.verified, *hash)
+	v.mtx.Unlock()
+}
+
+// unverifiedHeaders returns the hashes of the passed headers along with the
+// headers themselves, excluding the headers whose block nodes already record
+// that their proof of work passed.
+func (b *BlockChain) unverifiedHeaders(headers []*wire.BlockHeader) ([]chainhash.Hash, []*wire.BlockHeader) {
+	hashes := make([]chainhash.Hash, 0, len(headers))
+	unverified := make([]*wire.BlockHeader, 0, len(headers))
+	for _, header := range headers {
+		hash := header.BlockHash()
+		node := b.index.LookupNode(&hash)
+		if node != nil && b.index.NodeStatus(node)&statusPowValid != 0 {
+			continue
+		}
+		hashes = append(hashes, hash)
+		unverified = append(unverified, header)
+	}
+	return hashes, unverified
+}
+
+// VerifyProofOfWork checks the proof of work of all of the passed block
+// headers concurrently on a pool of goroutines sized to the number of CPUs.
+// The error of the first header in the passed order which fails is returned.
+//
+// Only the proof of work is checked, so callers are expected to perform the
+// cheap checks which depend on the order of the headers, such as ensuring they
+// connect to each other and match the checkpoints, themselves beforehand so
+// headers which fail them are never hashed.  The results are remembered so
+// ProcessBlock doesn't hash the headers again when their blocks are processed,
+// and headers of blocks which are already known to pass are not hashed at all.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) VerifyProofOfWork(headers []*wire.BlockHeader) error {
+	hashes, unverified := b.unverifiedHeaders(headers)
+	for _, result := range b.pow.verify(hashes, unverified, true) {
+		if result.err != nil {
+			return result.err
+		}
+	}
+	return nil
+}
+
+// QueueProofOfWork starts checking the proof of work of the passed block
+// headers in the background on the same pool of goroutines as
+// VerifyProofOfWork and returns immediately.  It is used for blocks which are
+// queued for processing so their proof of work is checked concurrently and,
+// when ProcessBlock reaches them, it waits for the result instead of hashing
+// the headers itself.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) QueueProofOfWork(headers []*wire.BlockHeader) {
+	hashes, unverified := b.unverifiedHeaders(headers)
+	b.pow.verify(hashes, unverified, false)
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/wire"
+)
+
+// isHighHashError returns whether the passed error is a rule error for a block
+// hash which is higher than its target.
+func isHighHashError(err error) bool {
+	rerr, ok := err.(RuleError)
+	return ok && rerr.ErrorCode == ErrHighHash
+}
+
+// TestVerifyProofOfWork ensures the proof of work of header batches is checked
+// concurrently with the error of the first failing header returned and that
+// the headers which pass are remembered.
+func TestVerifyProofOfWork(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	chain := newFakeChain(&params)
+
+	// Find headers whose proof of work hashes do and don't satisfy the
+	// easy regression test target by varying the nonce.
+	target := CompactToBig(params.PowLimitBits)
+	var good, bad []*wire.BlockHeader
+	for nonce := uint32(0); len(good) < 8 || len(bad) < 2; nonce++ {
+		header := params.GenesisBlock.Header
+		header.Bits = params.PowLimitBits
+		header.Nonce = nonce
+		hash, err := header.PowHash()
+		if err != nil {
+			t.Fatalf("PowHash: unexpected error: %v", err)
+		}
+		if HashToBig(hash).Cmp(target) <= 0 {
+			good = append(good, &header)
+		} else {
+			bad = append(bad, &header)
+		}
+	}
+
+	// A batch of valid headers must pass and be remembered.
+	if err := chain.VerifyProofOfWork(good); err != nil {
+		t.Fatalf("VerifyProofOfWork: unexpected error: %v", err)
+	}
+	for i, header := range good {
+		hash := header.BlockHash()
+		chain.pow.mtx.Lock()
+		_, ok := chain.pow.verified[hash]
+		chain.pow.mtx.Unlock()
+		if !ok {
+			t.Fatalf("VerifyProofOfWork: header #%d not remembered", i)
+		}
+	}
+
+	// The error of the first invalid header in the batch must be returned
+	// and invalid headers must not be remembered.
+	batch := append(append([]*wire.BlockHeader{}, good[:4]...), bad...)
+	err := chain.VerifyProofOfWork(batch)
+	if !isHighHashError(err) {
+		t.Fatalf("VerifyProofOfWork: unexpected error: got %v, want %v",
+			err, ErrHighHash)
+	}
+	for i, header := range bad {
+		hash := header.BlockHash()
+		chain.pow.mtx.Lock()
+		_, ok := chain.pow.verified[hash]
+		chain.pow.mtx.Unlock()
+		if ok {
+			t.Fatalf("VerifyProofOfWork: invalid header #%d "+
+				"remembered", i)
+		}
+	}
+
+	// Checks of queued headers must produce the same results.
+	chain.QueueProofOfWork(bad[:1])
+	hash := bad[0].BlockHash()
+	err = chain.pow.check(&hash, bad[0])
+	if !isHighHashError(err) {
+		t.Fatalf("check: unexpected error: got %v, want %v", err,
+			ErrHighHash)
+	}
+
+	// Headers of block nodes whose proof of work is known to be valid must
+	// be skipped.
+	node := newBlockNode(bad[1], chain.bestChain.Tip())
+	node.status = statusDataStored | statusPowValid
+	chain.index.AddNode(node)
+	if err := chain.VerifyProofOfWork(bad[1:2]); err != nil {
+		t.Fatalf("VerifyProofOfWork: unexpected error for known "+
+			"header: %v", err)
+	}
+}
@@ -164,8 +164,24 @@ func (b *BlockChain) ProcessBlock(block *eacutil.Block, flags BehaviorFlags) (bo
 		return false, false, ruleError(ErrDuplicateBlock, str)
 	}
 
+	// Check the proof of work of the block header before the other sanity
+	// checks.  The scrypt hash is expensive, so the header is not hashed
+	// again when it has already been verified, such as from a headers
+	// message, and the result is awaited when it is still being verified
+	// in the background, such as for a queued block.
+	blockHeader := &block.MsgBlock().Header
+	sanityFlags := flags
+	if flags&BFNoPoWCheck != BFNoPoWCheck {
+		err = b.pow.check(blockHash, blockHeader)
+		if err != nil {
+			return false, false, err
+		}
+		sanityFlags |= BFNoPoWCheck
+	}
+
 	// Perform preliminary sanity checks on the block and its transactions.
-	err = checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource, flags)
+	err = checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource,
+		sanityFlags)
 	if err != nil {
 		return false, false, err
 	}
@@ -176,7 +192,6 @@ func (b *BlockChain) ProcessBlock(block *eacutil.Block, flags BehaviorFlags) (bo
 	// rejecting easy to mine, but otherwise bogus, blocks that could be
 	// used to eat memory, and ensuring expected (versus claimed) proof of
 	// work requirements since the previous checkpoint are met.
-	blockHeader := &block.MsgBlock().Header
 	checkpointNode, err := b.findPreviousCheckpoint()
 	if err != nil {
 		return false, false, err
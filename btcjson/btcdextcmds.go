@@ -59,20 +59,49 @@ func NewDebugLevelCmd(levelSpec string) *DebugLevelCmd {
 	}
 }
 
+// GenerateBlockCmd defines the generateblock JSON-RPC command.
+type GenerateBlockCmd struct {
+	Address      string
+	Transactions []string
+	Timestamp    *int64
+	Version      *int32
+}
+
+// NewGenerateBlockCmd returns a new instance which can be used to issue a
+// generateblock JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGenerateBlockCmd(address string, transactions []string, timestamp *int64, version *int32) *GenerateBlockCmd {
+	return &GenerateBlockCmd{
+		Address:      address,
+		Transactions: transactions,
+		Timestamp:    timestamp,
+		Version:      version,
+	}
+}
+
 // GenerateToAddressCmd defines the generatetoaddress JSON-RPC command.
 type GenerateToAddressCmd struct {
 	NumBlocks int64
 	Address   string
 	MaxTries  *int64 `jsonrpcdefault:"1000000"`
+	Timestamp *int64
+	Version   *int32
 }
 
 // NewGenerateToAddressCmd returns a new instance which can be used to issue a
 // generatetoaddress JSON-RPC command.
-func NewGenerateToAddressCmd(numBlocks int64, address string, maxTries *int64) *GenerateToAddressCmd {
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGenerateToAddressCmd(numBlocks int64, address string, maxTries, timestamp *int64, version *int32) *GenerateToAddressCmd {
 	return &GenerateToAddressCmd{
 		NumBlocks: numBlocks,
 		Address:   address,
 		MaxTries:  maxTries,
+		Timestamp: timestamp,
+		Version:   version,
 	}
 }
 
@@ -128,6 +157,17 @@ func NewGetHeadersCmd(blockLocators []string, hashStop string) *GetHeadersCmd {
 	}
 }
 
+// GetStratumInfoCmd defines the getstratuminfo JSON-RPC command.  This
+// command is not a standard Bitcoin command.  It is an extension for eacd.
+type GetStratumInfoCmd struct{}
+
+// NewGetStratumInfoCmd returns a new instance which can be used to issue a
+// getstratuminfo JSON-RPC command.  This command is not a standard Bitcoin
+// command.  It is an extension for eacd.
+func NewGetStratumInfoCmd() *GetStratumInfoCmd {
+	return &GetStratumInfoCmd{}
+}
+
 // VersionCmd defines the version JSON-RPC command.
 //
 // NOTE: This is a btcsuite extension ported from
@@ -148,9 +188,11 @@ func init() {
 	MustRegisterCmd("debuglevel", (*DebugLevelCmd)(nil), flags)
 	MustRegisterCmd("node", (*NodeCmd)(nil), flags)
 	MustRegisterCmd("generate", (*GenerateCmd)(nil), flags)
+	MustRegisterCmd("generateblock", (*GenerateBlockCmd)(nil), flags)
 	MustRegisterCmd("generatetoaddress", (*GenerateToAddressCmd)(nil), flags)
 	MustRegisterCmd("getbestblock", (*GetBestBlockCmd)(nil), flags)
 	MustRegisterCmd("getcurrentnet", (*GetCurrentNetCmd)(nil), flags)
 	MustRegisterCmd("getheaders", (*GetHeadersCmd)(nil), flags)
+	MustRegisterCmd("getstratuminfo", (*GetStratumInfoCmd)(nil), flags)
 	MustRegisterCmd("version", (*VersionCmd)(nil), flags)
 }
@@ -120,7 +120,7 @@ func TestBtcdExtCmds(t *testing.T) {
 				return btcjson.NewCmd("generatetoaddress", 1, "1Address")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGenerateToAddressCmd(1, "1Address", nil)
+				return btcjson.NewGenerateToAddressCmd(1, "1Address", nil, nil, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"generatetoaddress","params":[1,"1Address"],"id":1}`,
 			unmarshalled: &btcjson.GenerateToAddressCmd{
@@ -132,6 +132,58 @@ func TestBtcdExtCmds(t *testing.T) {
 				}(),
 			},
 		},
+		{
+			name: "generatetoaddress optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generatetoaddress", 2, "1Address", 100, 1600000000, 4)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateToAddressCmd(2, "1Address",
+					btcjson.Int64(100), btcjson.Int64(1600000000),
+					btcjson.Int32(4))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generatetoaddress","params":[2,"1Address",100,1600000000,4],"id":1}`,
+			unmarshalled: &btcjson.GenerateToAddressCmd{
+				NumBlocks: 2,
+				Address:   "1Address",
+				MaxTries:  btcjson.Int64(100),
+				Timestamp: btcjson.Int64(1600000000),
+				Version:   btcjson.Int32(4),
+			},
+		},
+		{
+			name: "generateblock",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generateblock", "1Address", []string{"0100", "abcd"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateBlockCmd("1Address",
+					[]string{"0100", "abcd"}, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generateblock","params":["1Address",["0100","abcd"]],"id":1}`,
+			unmarshalled: &btcjson.GenerateBlockCmd{
+				Address:      "1Address",
+				Transactions: []string{"0100", "abcd"},
+			},
+		},
+		{
+			name: "generateblock optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generateblock", "1Address", []string{}, 1600000000, 4)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateBlockCmd("1Address",
+					[]string{}, btcjson.Int64(1600000000),
+					btcjson.Int32(4))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generateblock","params":["1Address",[],1600000000,4],"id":1}`,
+			unmarshalled: &btcjson.GenerateBlockCmd{
+				Address:      "1Address",
+				Transactions: []string{},
+				Timestamp:    btcjson.Int64(1600000000),
+				Version:      btcjson.Int32(4),
+			},
+		},
 		{
 			name: "getbestblock",
 			newCmd: func() (interface{}, error) {
@@ -194,6 +246,17 @@ func TestBtcdExtCmds(t *testing.T) {
 				HashStop: "000000000000000000ba33b33e1fad70b69e234fc24414dd47113bff38f523f7",
 			},
 		},
+		{
+			name: "getstratuminfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getstratuminfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetStratumInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getstratuminfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetStratumInfoCmd{},
+		},
 		{
 			name: "version",
 			newCmd: func() (interface{}, error) {
@@ -5,6 +5,33 @@
 
 package btcjson
 
+// GenerateBlockResult models the data returned by the generateblock command.
+type GenerateBlockResult struct {
+	Hash string `json:"hash"`
+}
+
+// StratumWorkerResult models the data of a Stratum worker returned by the
+// getstratuminfo command.
+type StratumWorkerResult struct {
+	Name           string  `json:"name"`
+	Connections    int32   `json:"connections"`
+	Difficulty     float64 `json:"difficulty"`
+	HashesPerSec   float64 `json:"hashespersec"`
+	AcceptedShares uint64  `json:"acceptedshares"`
+	RejectedShares uint64  `json:"rejectedshares"`
+	StaleShares    uint64  `json:"staleshares"`
+	BlocksFound    uint64  `json:"blocksfound"`
+	LastShare      int64   `json:"lastshare"`
+}
+
+// GetStratumInfoResult models the data returned by the getstratuminfo
+// command.
+type GetStratumInfoResult struct {
+	Connections  int32                 `json:"connections"`
+	HashesPerSec float64               `json:"hashespersec"`
+	Workers      []StratumWorkerResult `json:"workers"`
+}
+
 // VersionResult models objects included in the version response.  In the actual
 // result, these objects are keyed by the program or API name.
 //
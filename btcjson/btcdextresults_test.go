@@ -35,6 +35,25 @@ func TestBtcdExtCustomResults(t *testing.T) {
 			},
 			expected: `{"versionstring":"1.0.0","major":1,"minor":0,"patch":0,"prerelease":"pr","buildmetadata":"bm"}`,
 		},
+		{
+			name: "getstratuminforesult",
+			result: &btcjson.GetStratumInfoResult{
+				Connections:  1,
+				HashesPerSec: 2048,
+				Workers: []btcjson.StratumWorkerResult{{
+					Name:           "worker",
+					Connections:    1,
+					Difficulty:     4,
+					HashesPerSec:   2048,
+					AcceptedShares: 3,
+					RejectedShares: 2,
+					StaleShares:    1,
+					BlocksFound:    0,
+					LastShare:      1600000000,
+				}},
+			},
+			expected: `{"connections":1,"hashespersec":2048,"workers":[{"name":"worker","connections":1,"difficulty":4,"hashespersec":2048,"acceptedshares":3,"rejectedshares":2,"staleshares":1,"blocksfound":0,"lastshare":1600000000}]}`,
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
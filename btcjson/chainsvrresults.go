@@ -299,29 +299,34 @@ type MempoolFees struct {
 // GetMempoolEntryResult models the data returned from the getmempoolentry
 // command.
 type GetMempoolEntryResult struct {
-	VSize           int32       `json:"vsize"`
-	Size            int32       `json:"size"`
-	Weight          int64       `json:"weight"`
-	Fee             float64     `json:"fee"`
-	ModifiedFee     float64     `json:"modifiedfee"`
-	Time            int64       `json:"time"`
-	Height          int64       `json:"height"`
-	DescendantCount int64       `json:"descendantcount"`
-	DescendantSize  int64       `json:"descendantsize"`
-	DescendantFees  float64     `json:"descendantfees"`
-	AncestorCount   int64       `json:"ancestorcount"`
-	AncestorSize    int64       `json:"ancestorsize"`
-	AncestorFees    float64     `json:"ancestorfees"`
-	WTxId           string      `json:"wtxid"`
-	Fees            MempoolFees `json:"fees"`
-	Depends         []string    `json:"depends"`
+	VSize             int32       `json:"vsize"`
+	Size              int32       `json:"size"`
+	Weight            int64       `json:"weight"`
+	Fee               float64     `json:"fee"`
+	ModifiedFee       float64     `json:"modifiedfee"`
+	Time              int64       `json:"time"`
+	Height            int64       `json:"height"`
+	DescendantCount   int64       `json:"descendantcount"`
+	DescendantSize    int64       `json:"descendantsize"`
+	DescendantFees    float64     `json:"descendantfees"`
+	AncestorCount     int64       `json:"ancestorcount"`
+	AncestorSize      int64       `json:"ancestorsize"`
+	AncestorFees      float64     `json:"ancestorfees"`
+	WTxId             string      `json:"wtxid"`
+	Fees              MempoolFees `json:"fees"`
+	Depends           []string    `json:"depends"`
+	SpentBy           []string    `json:"spentby"`
+	BIP125Replaceable bool        `json:"bip125-replaceable"`
 }
 
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
 // command.
 type GetMempoolInfoResult struct {
-	Size  int64 `json:"size"`
-	Bytes int64 `json:"bytes"`
+	Size          int64   `json:"size"`
+	Bytes         int64   `json:"bytes"`
+	MaxMempool    int64   `json:"maxmempool"`
+	MempoolMinFee float64 `json:"mempoolminfee"`
+	MinRelayTxFee float64 `json:"minrelaytxfee"`
 }
 
 // NetworksResult models the networks data from the getnetworkinfo command.
@@ -382,6 +387,7 @@ type GetPeerInfoResult struct {
 	BanScore       int32   `json:"banscore"`
 	FeeFilter      int64   `json:"feefilter"`
 	SyncNode       bool    `json:"syncnode"`
+	MappedAS       uint32  `json:"mapped_as,omitempty"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -392,6 +398,7 @@ type GetRawMempoolVerboseResult struct {
 	Vsize            int32    `json:"vsize"`
 	Weight           int32    `json:"weight"`
 	Fee              float64  `json:"fee"`
+	ModifiedFee      float64  `json:"modifiedfee"`
 	Time             int64    `json:"time"`
 	Height           int64    `json:"height"`
 	StartingPriority float64  `json:"startingpriority"`
@@ -399,6 +406,24 @@ type GetRawMempoolVerboseResult struct {
 	Depends          []string `json:"depends"`
 }
 
+// TestMempoolAcceptFees models the fees of a transaction returned from the
+// testmempoolaccept command.
+type TestMempoolAcceptFees struct {
+	Base float64 `json:"base"`
+}
+
+// TestMempoolAcceptResult models the data returned from the testmempoolaccept
+// command for each transaction.
+type TestMempoolAcceptResult struct {
+	TxID         string                 `json:"txid"`
+	WTxID        string                 `json:"wtxid"`
+	Allowed      bool                   `json:"allowed"`
+	VSize        int32                  `json:"vsize,omitempty"`
+	Fees         *TestMempoolAcceptFees `json:"fees,omitempty"`
+	Conflicts    []string               `json:"conflicts,omitempty"`
+	RejectReason string                 `json:"reject-reason,omitempty"`
+}
+
 // ScriptPubKeyResult models the scriptPubKey data of a tx script.  It is
 // defined separately since it is used by multiple commands.
 type ScriptPubKeyResult struct {
@@ -686,6 +711,37 @@ type EstimateSmartFeeResult struct {
 	Blocks  int64    `json:"blocks"`
 }
 
+// EstimateRawFeeBucket models a range of fee rates examined by the chain
+// server estimaterawfee command.
+type EstimateRawFeeBucket struct {
+	StartRange     float64 `json:"startrange"`
+	EndRange       float64 `json:"endrange"`
+	WithinTarget   float64 `json:"withintarget"`
+	TotalConfirmed float64 `json:"totalconfirmed"`
+	InMempool      float64 `json:"inmempool"`
+	LeftMempool    float64 `json:"leftmempool"`
+}
+
+// EstimateRawFeeHorizonResult models the estimate of a single horizon returned
+// by the chain server estimaterawfee command.
+type EstimateRawFeeHorizonResult struct {
+	FeeRate *float64              `json:"feerate,omitempty"`
+	Decay   float64               `json:"decay"`
+	Scale   int64                 `json:"scale"`
+	Pass    *EstimateRawFeeBucket `json:"pass,omitempty"`
+	Fail    *EstimateRawFeeBucket `json:"fail,omitempty"`
+	Errors  []string              `json:"errors,omitempty"`
+}
+
+// EstimateRawFeeResult models the data returned by the chain server
+// estimaterawfee command.  Horizons which don't track the requested target
+// are omitted.
+type EstimateRawFeeResult struct {
+	Short  *EstimateRawFeeHorizonResult `json:"short,omitempty"`
+	Medium *EstimateRawFeeHorizonResult `json:"medium,omitempty"`
+	Long   *EstimateRawFeeHorizonResult `json:"long,omitempty"`
+}
+
 var _ json.Unmarshaler = &FundRawTransactionResult{}
 
 type rawFundRawTransactionResult struct {
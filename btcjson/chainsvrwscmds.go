@@ -57,6 +57,15 @@ func NewNotifyNewTransactionsCmd(verbose *bool) *NotifyNewTransactionsCmd {
 	}
 }
 
+// NotifyReplacementsCmd defines the notifyreplacements JSON-RPC command.
+type NotifyReplacementsCmd struct{}
+
+// NewNotifyReplacementsCmd returns a new instance which can be used to issue a
+// notifyreplacements JSON-RPC command.
+func NewNotifyReplacementsCmd() *NotifyReplacementsCmd {
+	return &NotifyReplacementsCmd{}
+}
+
 // SessionCmd defines the session JSON-RPC command.
 type SessionCmd struct{}
 
@@ -78,6 +87,16 @@ func NewStopNotifyNewTransactionsCmd() *StopNotifyNewTransactionsCmd {
 	return &StopNotifyNewTransactionsCmd{}
 }
 
+// StopNotifyReplacementsCmd defines the stopnotifyreplacements JSON-RPC
+// command.
+type StopNotifyReplacementsCmd struct{}
+
+// NewStopNotifyReplacementsCmd returns a new instance which can be used to
+// issue a stopnotifyreplacements JSON-RPC command.
+func NewStopNotifyReplacementsCmd() *StopNotifyReplacementsCmd {
+	return &StopNotifyReplacementsCmd{}
+}
+
 // NotifyReceivedCmd defines the notifyreceived JSON-RPC command.
 //
 // Deprecated: Use LoadTxFilterCmd instead.
@@ -230,10 +249,12 @@ func init() {
 	MustRegisterCmd("notifyblocks", (*NotifyBlocksCmd)(nil), flags)
 	MustRegisterCmd("notifynewtransactions", (*NotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("notifyreceived", (*NotifyReceivedCmd)(nil), flags)
+	MustRegisterCmd("notifyreplacements", (*NotifyReplacementsCmd)(nil), flags)
 	MustRegisterCmd("notifyspent", (*NotifySpentCmd)(nil), flags)
 	MustRegisterCmd("session", (*SessionCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyblocks", (*StopNotifyBlocksCmd)(nil), flags)
 	MustRegisterCmd("stopnotifynewtransactions", (*StopNotifyNewTransactionsCmd)(nil), flags)
+	MustRegisterCmd("stopnotifyreplacements", (*StopNotifyReplacementsCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyspent", (*StopNotifySpentCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyreceived", (*StopNotifyReceivedCmd)(nil), flags)
 	MustRegisterCmd("rescan", (*RescanCmd)(nil), flags)
@@ -100,6 +100,28 @@ func TestChainSvrWsCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifynewtransactions","params":[],"id":1}`,
 			unmarshalled: &btcjson.StopNotifyNewTransactionsCmd{},
 		},
+		{
+			name: "notifyreplacements",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifyreplacements")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyReplacementsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifyreplacements","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyReplacementsCmd{},
+		},
+		{
+			name: "stopnotifyreplacements",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifyreplacements")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyReplacementsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifyreplacements","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyReplacementsCmd{},
+		},
 		{
 			name: "notifyreceived",
 			newCmd: func() (interface{}, error) {
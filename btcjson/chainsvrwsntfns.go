@@ -75,6 +75,21 @@ const (
 	// from the chain server that inform a client that a transaction that
 	// matches the loaded filter was accepted by the mempool.
 	RelevantTxAcceptedNtfnMethod = "relevanttxaccepted"
+
+	// TxRemovedNtfnMethod is the method used for notifications from the
+	// chain server that a transaction has been removed from the mempool.
+	TxRemovedNtfnMethod = "txremoved"
+
+	// TxReplacedNtfnMethod is the method used for notifications from the
+	// chain server that a transaction accepted into the mempool replaced
+	// transactions in it.
+	TxReplacedNtfnMethod = "txreplaced"
+
+	// RelevantDoubleSpendNtfnMethod is the method used for notifications
+	// from the chain server that inform a client that a transaction which
+	// double spends a transaction in the mempool and matches the loaded
+	// filter was rejected.
+	RelevantDoubleSpendNtfnMethod = "relevantdoublespend"
 )
 
 // BlockConnectedNtfn defines the blockconnected JSON-RPC notification.
@@ -285,6 +300,54 @@ func NewRelevantTxAcceptedNtfn(txHex string) *RelevantTxAcceptedNtfn {
 	return &RelevantTxAcceptedNtfn{Transaction: txHex}
 }
 
+// TxRemovedNtfn defines the txremoved JSON-RPC notification.
+type TxRemovedNtfn struct {
+	TxID   string
+	Reason string
+}
+
+// NewTxRemovedNtfn returns a new instance which can be used to issue a
+// txremoved JSON-RPC notification.
+func NewTxRemovedNtfn(txHash string, reason string) *TxRemovedNtfn {
+	return &TxRemovedNtfn{
+		TxID:   txHash,
+		Reason: reason,
+	}
+}
+
+// TxReplacedNtfn defines the txreplaced JSON-RPC notification.
+type TxReplacedNtfn struct {
+	Replacement TxFeeRate
+	Replaced    []TxFeeRate
+}
+
+// NewTxReplacedNtfn returns a new instance which can be used to issue a
+// txreplaced JSON-RPC notification.
+func NewTxReplacedNtfn(replacement TxFeeRate, replaced []TxFeeRate) *TxReplacedNtfn {
+	return &TxReplacedNtfn{
+		Replacement: replacement,
+		Replaced:    replaced,
+	}
+}
+
+// RelevantDoubleSpendNtfn defines the parameters to the relevantdoublespend
+// JSON-RPC notification.
+type RelevantDoubleSpendNtfn struct {
+	Transaction string   `json:"transaction"`
+	Conflicts   []string `json:"conflicts"`
+	Time        int64    `json:"time"`
+}
+
+// NewRelevantDoubleSpendNtfn returns a new instance which can be used to issue
+// a relevantdoublespend JSON-RPC notification.
+func NewRelevantDoubleSpendNtfn(txHex string, conflicts []string, time int64) *RelevantDoubleSpendNtfn {
+	return &RelevantDoubleSpendNtfn{
+		Transaction: txHex,
+		Conflicts:   conflicts,
+		Time:        time,
+	}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets and are
 	// notifications.
@@ -301,4 +364,7 @@ func init() {
 	MustRegisterCmd(TxAcceptedNtfnMethod, (*TxAcceptedNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedVerboseNtfnMethod, (*TxAcceptedVerboseNtfn)(nil), flags)
 	MustRegisterCmd(RelevantTxAcceptedNtfnMethod, (*RelevantTxAcceptedNtfn)(nil), flags)
+	MustRegisterCmd(TxRemovedNtfnMethod, (*TxRemovedNtfn)(nil), flags)
+	MustRegisterCmd(TxReplacedNtfnMethod, (*TxReplacedNtfn)(nil), flags)
+	MustRegisterCmd(RelevantDoubleSpendNtfnMethod, (*RelevantDoubleSpendNtfn)(nil), flags)
 }
@@ -225,6 +225,52 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Transaction: "001122",
 			},
 		},
+		{
+			name: "txremoved",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("txremoved", "123", "expiry")
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewTxRemovedNtfn("123", "expiry")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"txremoved","params":["123","expiry"],"id":null}`,
+			unmarshalled: &btcjson.TxRemovedNtfn{
+				TxID:   "123",
+				Reason: "expiry",
+			},
+		},
+		{
+			name: "txreplaced",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("txreplaced", `{"txid":"123","feerate":0.0002}`, `[{"txid":"456","feerate":0.0001}]`)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewTxReplacedNtfn(
+					btcjson.TxFeeRate{TxID: "123", FeeRate: 0.0002},
+					[]btcjson.TxFeeRate{{TxID: "456", FeeRate: 0.0001}},
+				)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"txreplaced","params":[{"txid":"123","feerate":0.0002},[{"txid":"456","feerate":0.0001}]],"id":null}`,
+			unmarshalled: &btcjson.TxReplacedNtfn{
+				Replacement: btcjson.TxFeeRate{TxID: "123", FeeRate: 0.0002},
+				Replaced:    []btcjson.TxFeeRate{{TxID: "456", FeeRate: 0.0001}},
+			},
+		},
+		{
+			name: "relevantdoublespend",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("relevantdoublespend", "001122", []string{"123"}, 1500000000)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewRelevantDoubleSpendNtfn("001122", []string{"123"}, 1500000000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"relevantdoublespend","params":["001122",["123"],1500000000],"id":null}`,
+			unmarshalled: &btcjson.RelevantDoubleSpendNtfn{
+				Transaction: "001122",
+				Conflicts:   []string{"123"},
+				Time:        1500000000,
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -19,3 +19,10 @@ type RescannedBlock struct {
 	Hash         string   `json:"hash"`
 	Transactions []string `json:"transactions"`
 }
+
+// TxFeeRate models a transaction along with its fee rate in BTC/kB as used in
+// the txreplaced notification.
+type TxFeeRate struct {
+	TxID    string  `json:"txid"`
+	FeeRate float64 `json:"feerate"`
+}
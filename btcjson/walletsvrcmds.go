@@ -105,6 +105,24 @@ func NewEstimateSmartFeeCmd(confTarget int64, mode *EstimateSmartFeeMode) *Estim
 	}
 }
 
+// EstimateRawFeeCmd defines the estimaterawfee JSON-RPC command.
+type EstimateRawFeeCmd struct {
+	ConfTarget int64
+	Threshold  *float64 `jsonrpcdefault:"0.95"`
+}
+
+// NewEstimateRawFeeCmd returns a new instance which can be used to issue a
+// estimaterawfee JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewEstimateRawFeeCmd(confTarget int64, threshold *float64) *EstimateRawFeeCmd {
+	return &EstimateRawFeeCmd{
+		ConfTarget: confTarget,
+		Threshold:  threshold,
+	}
+}
+
 // EstimateFeeCmd defines the estimatefee JSON-RPC command.
 type EstimateFeeCmd struct {
 	NumBlocks int64
@@ -698,6 +716,7 @@ func init() {
 	MustRegisterCmd("estimatesmartfee", (*EstimateSmartFeeCmd)(nil), flags)
 	MustRegisterCmd("estimatefee", (*EstimateFeeCmd)(nil), flags)
 	MustRegisterCmd("estimatepriority", (*EstimatePriorityCmd)(nil), flags)
+	MustRegisterCmd("estimaterawfee", (*EstimateRawFeeCmd)(nil), flags)
 	MustRegisterCmd("getaccount", (*GetAccountCmd)(nil), flags)
 	MustRegisterCmd("getaccountaddress", (*GetAccountAddressCmd)(nil), flags)
 	MustRegisterCmd("getaddressesbyaccount", (*GetAddressesByAccountCmd)(nil), flags)
@@ -156,6 +156,34 @@ func TestWalletSvrCmds(t *testing.T) {
 				EstimateMode: &btcjson.EstimateModeEconomical,
 			},
 		},
+		{
+			name: "estimaterawfee",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("estimaterawfee", 6)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewEstimateRawFeeCmd(6, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimaterawfee","params":[6],"id":1}`,
+			unmarshalled: &btcjson.EstimateRawFeeCmd{
+				ConfTarget: 6,
+				Threshold:  btcjson.Float64(0.95),
+			},
+		},
+		{
+			name: "estimaterawfee optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("estimaterawfee", 6, 0.5)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewEstimateRawFeeCmd(6, btcjson.Float64(0.5))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimaterawfee","params":[6,0.5],"id":1}`,
+			unmarshalled: &btcjson.EstimateRawFeeCmd{
+				ConfTarget: 6,
+				Threshold:  btcjson.Float64(0.5),
+			},
+		},
 		{
 			name: "estimatepriority",
 			newCmd: func() (interface{}, error) {
@@ -0,0 +1,168 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/btcsuite/btclog"
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/wire"
+	"github.com/eacsuite/eacutil"
+	flags "github.com/jessevdk/go-flags"
+)
+
+const (
+	defaultListen      = ":53"
+	defaultMaxCrawlers = 32
+	defaultDebugLevel  = "info"
+)
+
+var (
+	defaultDataDir  = eacutil.AppDataDir("eacseeder", false)
+	activeNetParams = &chaincfg.MainNetParams
+)
+
+// config defines the configuration options for eacseeder.
+//
+// See loadConfig for details on the configuration load process.
+type config struct {
+	Host           string   `short:"H" long:"host" description:"Hostname of the seeder zone the DNS responder is authoritative for (e.g. seed.example.com)"`
+	Nameserver     string   `short:"n" long:"nameserver" description:"Hostname of the nameserver the seeder zone is delegated to"`
+	Mbox           string   `short:"m" long:"mbox" description:"Email address of the zone administrator reported in SOA records"`
+	Listen         string   `short:"l" long:"listen" description:"Interface/port the DNS responder listens on for UDP queries"`
+	Seeders        []string `short:"s" long:"seeder" description:"Add a peer to bootstrap the crawl from -- Defaults to the DNS seeds of the network when not specified"`
+	DataDir        string   `short:"b" long:"datadir" description:"Directory to store the crawl state"`
+	MaxCrawlers    int      `long:"crawlers" description:"Maximum number of peers to crawl concurrently"`
+	DebugLevel     string   `short:"d" long:"debuglevel" description:"Logging level {trace, debug, info, warn, error, critical}"`
+	TestNet4       bool     `long:"testnet" description:"Use the test network"`
+	RegressionTest bool     `long:"regtest" description:"Use the regression test network"`
+	SimNet         bool     `long:"simnet" description:"Use the simulation test network"`
+}
+
+// netName returns the name used when referring to a bitcoin network.  At the
+// time of writing, eacd currently places blocks for testnet version 3 in the
+// data and log directory "testnet", which does not match the Name field of the
+// chaincfg parameters.  This function can be used to override this directory name
+// as "testnet" when the passed active network matches wire.TestNet4.
+func netName(chainParams *chaincfg.Params) string {
+	switch chainParams.Net {
+	case wire.TestNet4:
+		return "testnet"
+	default:
+		return chainParams.Name
+	}
+}
+
+// normalizeAddress returns addr with the default port appended if there is not
+// already a port specified.
+func normalizeAddress(addr, defaultPort string) string {
+	_, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return net.JoinHostPort(addr, defaultPort)
+	}
+	return addr
+}
+
+// loadConfig initializes and parses the config using command line options.
+func loadConfig() (*config, []string, error) {
+	// Default config.
+	cfg := config{
+		Listen:      defaultListen,
+		DataDir:     defaultDataDir,
+		MaxCrawlers: defaultMaxCrawlers,
+		DebugLevel:  defaultDebugLevel,
+	}
+
+	// Parse command line options.
+	parser := flags.NewParser(&cfg, flags.Default)
+	remainingArgs, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return nil, nil, err
+	}
+
+	// Multiple networks can't be selected simultaneously.
+	funcName := "loadConfig"
+	numNets := 0
+	// Count number of network flags passed; assign active network params
+	// while we're at it
+	if cfg.TestNet4 {
+		numNets++
+		activeNetParams = &chaincfg.TestNet4Params
+	}
+	if cfg.RegressionTest {
+		numNets++
+		activeNetParams = &chaincfg.RegressionNetParams
+	}
+	if cfg.SimNet {
+		numNets++
+		activeNetParams = &chaincfg.SimNetParams
+	}
+	if numNets > 1 {
+		str := "%s: The testnet, regtest, and simnet params can't be " +
+			"used together -- choose one of the three"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// The zone and its nameserver are required to answer queries
+	// authoritatively.
+	if cfg.Host == "" || cfg.Nameserver == "" {
+		str := "%s: The --host and --nameserver options must be specified"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+	cfg.Host = strings.ToLower(strings.TrimSuffix(cfg.Host, "."))
+	cfg.Nameserver = strings.ToLower(strings.TrimSuffix(cfg.Nameserver, "."))
+	if cfg.Mbox == "" {
+		cfg.Mbox = "hostmaster@" + cfg.Host
+	}
+
+	if cfg.MaxCrawlers < 1 {
+		str := "%s: The crawlers option may not be less than 1 -- " +
+			"parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.MaxCrawlers)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	if _, ok := btclog.LevelFromString(cfg.DebugLevel); !ok {
+		str := "%s: The specified debug level [%v] is invalid"
+		err := fmt.Errorf(str, funcName, cfg.DebugLevel)
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
+	// Fall back to the DNS seeds of the network when no peers to bootstrap
+	// from were specified.
+	if len(cfg.Seeders) == 0 {
+		for _, seed := range activeNetParams.DNSSeeds {
+			cfg.Seeders = append(cfg.Seeders, seed.Host)
+		}
+	}
+	for i, seeder := range cfg.Seeders {
+		cfg.Seeders[i] = normalizeAddress(seeder,
+			activeNetParams.DefaultPort)
+	}
+
+	// Append the network type to the data directory so it is "namespaced"
+	// per network.
+	cfg.DataDir = filepath.Join(cfg.DataDir, netName(activeNetParams))
+
+	return &cfg, remainingArgs, nil
+}
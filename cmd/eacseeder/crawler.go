@@ -0,0 +1,487 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/eacsuite/eacd/addrmgr"
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/peer"
+	"github.com/eacsuite/eacd/wire"
+)
+
+const (
+	// nodesFilename is the name of the file in the data directory the crawl
+	// state is persisted to.
+	nodesFilename = "nodes.json"
+
+	// dumpFilename is the name of the file in the data directory a human
+	// readable summary of the node statistics is written to.
+	dumpFilename = "dump.txt"
+
+	// crawlInterval is the interval at which nodes that are due are
+	// dispatched to the crawlers.
+	crawlInterval = 10 * time.Second
+
+	// saveInterval is the interval at which the crawl state is persisted.
+	saveInterval = 10 * time.Minute
+
+	// dialTimeout is the maximum time to wait for a connection to a node.
+	dialTimeout = 10 * time.Second
+
+	// handshakeTimeout is the maximum time to wait for a node to complete
+	// the version handshake.
+	handshakeTimeout = 20 * time.Second
+
+	// addrTimeout is the maximum time to wait for a node to answer a
+	// getaddr request.
+	addrTimeout = 30 * time.Second
+
+	// maxAddrAge is the age after which addresses learned from other nodes
+	// are not added to the crawl anymore.
+	maxAddrAge = 14 * 24 * time.Hour
+
+	// maxNodes is the maximum number of nodes tracked by the crawler.
+	maxNodes = 100000
+)
+
+// crawler discovers nodes on the network by repeatedly connecting to known
+// nodes and asking them for the addresses they know about.  It keeps track of
+// how reliably each node can be reached.
+type crawler struct {
+	params          *chaincfg.Params
+	dataDir         string
+	maxCrawlers     int
+	allowUnroutable bool
+	allowAnyPort    bool
+
+	mtx      sync.RWMutex
+	nodes    map[string]*node
+	visiting map[string]struct{}
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// newCrawler returns a new crawler for the passed network which persists its
+// state to the given data directory.  Nodes that are not routable on the
+// public internet are only crawled, and nodes that don't listen on the default
+// port of the network are only returned by goodAddrs, on the regression and
+// simulation test networks so that local test networks, whose nodes commonly
+// listen on other ports, can be crawled.
+func newCrawler(params *chaincfg.Params, dataDir string,
+	maxCrawlers int) *crawler {
+
+	testNet := params.Net == wire.TestNet || params.Net == wire.SimNet
+	return &crawler{
+		params:          params,
+		dataDir:         dataDir,
+		maxCrawlers:     maxCrawlers,
+		allowUnroutable: testNet,
+		allowAnyPort:    testNet,
+		nodes:           make(map[string]*node),
+		visiting:        make(map[string]struct{}),
+		quit:            make(chan struct{}),
+	}
+}
+
+// Start loads the persisted crawl state, adds the passed bootstrap peers and
+// begins crawling the network.
+func (c *crawler) Start(seeders []string) {
+	c.load()
+	for _, seeder := range seeders {
+		c.addSeeder(seeder)
+	}
+
+	c.wg.Add(1)
+	go c.crawlHandler()
+}
+
+// Stop stops crawling the network, waits for any visits in progress to finish
+// and persists the crawl state.
+func (c *crawler) Stop() {
+	close(c.quit)
+	c.wg.Wait()
+	c.save()
+}
+
+// addSeeder resolves the passed host:port and adds the resulting addresses to
+// the crawl.
+func (c *crawler) addSeeder(seeder string) {
+	host, port, err := net.SplitHostPort(seeder)
+	if err != nil {
+		log.Warnf("Invalid seeder %s: %v", seeder, err)
+		return
+	}
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		ips, err = net.LookupIP(host)
+		if err != nil {
+			log.Warnf("Unable to resolve seeder %s: %v", host, err)
+			return
+		}
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, ip := range ips {
+		c.addNode(net.JoinHostPort(ip.String(), port))
+	}
+}
+
+// addNode adds the passed address to the crawl if it is not known yet.
+//
+// This function MUST be called with the crawler lock held (for writes).
+func (c *crawler) addNode(addr string) {
+	if _, ok := c.nodes[addr]; ok || len(c.nodes) >= maxNodes {
+		return
+	}
+	log.Tracef("Adding node %s", addr)
+	c.nodes[addr] = &node{Addr: addr}
+}
+
+// addAddrs adds the addresses learned from a node to the crawl.  Addresses
+// which can't be crawled or have not been seen for a long time are skipped.
+func (c *crawler) addAddrs(addrs []*wire.NetAddress, now time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, na := range addrs {
+		if na.Port == 0 || addrmgr.IsOnionCatTor(na) {
+			continue
+		}
+		if !addrmgr.IsRoutable(na) && !(c.allowUnroutable &&
+			addrmgr.IsValid(na)) {
+
+			continue
+		}
+		if now.Sub(na.Timestamp) > maxAddrAge {
+			continue
+		}
+		c.addNode(addrmgr.NetAddressKey(na))
+	}
+}
+
+// crawlHandler periodically dispatches the nodes that are due for a visit and
+// persists the crawl state.  It must be run as a goroutine.
+func (c *crawler) crawlHandler() {
+	defer c.wg.Done()
+
+	crawlTicker := time.NewTicker(crawlInterval)
+	defer crawlTicker.Stop()
+	saveTicker := time.NewTicker(saveInterval)
+	defer saveTicker.Stop()
+
+	sem := make(chan struct{}, c.maxCrawlers)
+	c.dispatch(sem)
+out:
+	for {
+		select {
+		case <-crawlTicker.C:
+			c.dispatch(sem)
+
+		case <-saveTicker.C:
+			c.save()
+
+		case <-c.quit:
+			break out
+		}
+	}
+
+	// Wait for all visits in progress to finish.
+	for i := 0; i < cap(sem); i++ {
+		sem <- struct{}{}
+	}
+	log.Trace("Crawl handler done")
+}
+
+// dispatch starts a visit of every node that is due as long as crawler slots
+// are available.  Stale nodes are forgotten along the way.
+func (c *crawler) dispatch(sem chan struct{}) {
+	now := time.Now()
+
+	c.mtx.Lock()
+	type dueNode struct {
+		addr string
+		next time.Time
+	}
+	var due []dueNode
+	for addr, n := range c.nodes {
+		if _, ok := c.visiting[addr]; ok {
+			continue
+		}
+		if n.isStale(now) {
+			log.Debugf("Forgetting unreachable node %s", addr)
+			delete(c.nodes, addr)
+			continue
+		}
+		if next := n.nextAttempt(); !next.After(now) {
+			due = append(due, dueNode{addr, next})
+		}
+	}
+	c.mtx.Unlock()
+
+	// Visit the nodes that have been due for the longest time first.
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].next.Before(due[j].next)
+	})
+
+	for _, d := range due {
+		select {
+		case sem <- struct{}{}:
+		case <-c.quit:
+			return
+		default:
+			// All crawlers are busy, so try again on the next
+			// tick.
+			return
+		}
+
+		c.mtx.Lock()
+		c.visiting[d.addr] = struct{}{}
+		c.mtx.Unlock()
+
+		go func(addr string) {
+			defer func() { <-sem }()
+			c.visit(addr)
+		}(d.addr)
+	}
+}
+
+// visit connects to the node with the passed address and records the outcome.
+func (c *crawler) visit(addr string) {
+	res, addrs := c.poll(addr)
+	c.recordVisit(addr, res, addrs, time.Now())
+}
+
+// recordVisit records the result of a visit of the node with the passed
+// address made at the given time along with the addresses learned from it.
+func (c *crawler) recordVisit(addr string, res *visitResult,
+	addrs []*wire.NetAddress, now time.Time) {
+
+	if res.success {
+		log.Debugf("Visited %s (%s, height %d, services %v): learned "+
+			"%d addresses", addr, res.userAgent, res.height,
+			res.services, len(addrs))
+	} else {
+		log.Debugf("Unable to visit %s", addr)
+	}
+
+	c.mtx.Lock()
+	delete(c.visiting, addr)
+	if n, ok := c.nodes[addr]; ok {
+		n.update(res, now)
+	}
+	c.mtx.Unlock()
+
+	c.addAddrs(addrs, now)
+}
+
+// poll performs the version handshake with the node at the passed address and
+// requests the addresses it knows about.
+func (c *crawler) poll(addr string) (*visitResult, []*wire.NetAddress) {
+	verAck := make(chan struct{}, 1)
+	addrMsgs := make(chan *wire.MsgAddr, 4)
+	peerCfg := &peer.Config{
+		UserAgentName:    "eacseeder",
+		UserAgentVersion: "0.1.0",
+		ChainParams:      c.params,
+		DisableRelayTx:   true,
+		Listeners: peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verAck <- struct{}{}
+			},
+			OnAddr: func(p *peer.Peer, msg *wire.MsgAddr) {
+				select {
+				case addrMsgs <- msg:
+				default:
+				}
+			},
+		},
+	}
+
+	res := &visitResult{}
+	p, err := peer.NewOutboundPeer(peerCfg, addr)
+	if err != nil {
+		log.Debugf("Unable to create peer %s: %v", addr, err)
+		return res, nil
+	}
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return res, nil
+	}
+	p.AssociateConnection(conn)
+	defer func() {
+		p.Disconnect()
+		p.WaitForDisconnect()
+	}()
+
+	select {
+	case <-verAck:
+	case <-time.After(handshakeTimeout):
+		return res, nil
+	case <-c.quit:
+		return res, nil
+	}
+
+	res.success = true
+	res.services = p.Services()
+	res.protocolVersion = p.ProtocolVersion()
+	res.userAgent = p.UserAgent()
+	res.height = p.StartingHeight()
+
+	// Nodes commonly announce their own address right after the handshake,
+	// so keep collecting addresses until a message with more than one
+	// address arrives, which is the response to the request.
+	p.QueueMessage(wire.NewMsgGetAddr(), nil)
+	var addrs []*wire.NetAddress
+	timeout := time.After(addrTimeout)
+	for {
+		select {
+		case msg := <-addrMsgs:
+			addrs = append(addrs, msg.AddrList...)
+			if len(msg.AddrList) > 1 {
+				return res, addrs
+			}
+		case <-timeout:
+			return res, addrs
+		case <-c.quit:
+			return res, addrs
+		}
+	}
+}
+
+// goodAddrs returns up to max randomly selected IPv4 or IPv6 addresses of good
+// nodes which support all of the passed services.  DNS answers can't hold
+// ports, so only nodes listening on the default port of the network are
+// returned unless any port is allowed.  It is part of the addrSource interface
+// implementation.
+func (c *crawler) goodAddrs(ipv4 bool, services wire.ServiceFlag,
+	max int) []net.IP {
+
+	c.mtx.RLock()
+	var ips []net.IP
+	for _, n := range c.nodes {
+		if !n.isGood() || n.Services&services != services {
+			continue
+		}
+		host, port, err := net.SplitHostPort(n.Addr)
+		if err != nil || (port != c.params.DefaultPort &&
+			!c.allowAnyPort) {
+
+			continue
+		}
+		ip := net.ParseIP(host)
+		if ip == nil || (ip.To4() != nil) != ipv4 {
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	c.mtx.RUnlock()
+
+	rand.Shuffle(len(ips), func(i, j int) {
+		ips[i], ips[j] = ips[j], ips[i]
+	})
+	if len(ips) > max {
+		ips = ips[:max]
+	}
+	return ips
+}
+
+// load reads the crawl state persisted by a previous run.  A missing or
+// malformed file results in starting afresh.
+func (c *crawler) load() {
+	path := filepath.Join(c.dataDir, nodesFilename)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Unable to read %s: %v", path, err)
+		}
+		return
+	}
+	var nodes []*node
+	if err := json.Unmarshal(data, &nodes); err != nil {
+		log.Warnf("Unable to parse %s: %v", path, err)
+		return
+	}
+
+	c.mtx.Lock()
+	for _, n := range nodes {
+		c.nodes[n.Addr] = n
+	}
+	c.mtx.Unlock()
+	log.Infof("Loaded %d nodes from %s", len(nodes), path)
+}
+
+// save persists the crawl state and writes a human readable summary of the
+// node statistics sorted by their monthly uptime.
+func (c *crawler) save() {
+	c.mtx.RLock()
+	nodes := make([]*node, 0, len(c.nodes))
+	for _, n := range c.nodes {
+		nodeCopy := *n
+		nodes = append(nodes, &nodeCopy)
+	}
+	c.mtx.RUnlock()
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].uptime(stat1M) > nodes[j].uptime(stat1M)
+	})
+
+	if err := os.MkdirAll(c.dataDir, 0700); err != nil {
+		log.Errorf("Unable to create data directory %s: %v", c.dataDir,
+			err)
+		return
+	}
+	data, err := json.Marshal(nodes)
+	if err != nil {
+		log.Errorf("Unable to encode nodes: %v", err)
+		return
+	}
+	path := filepath.Join(c.dataDir, nodesFilename)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		log.Errorf("Unable to write %s: %v", path, err)
+		return
+	}
+
+	numGood := 0
+	dump := fmt.Sprintf("%-47s %4s %11s %7s %7s %7s %7s %7s %7s %16s %5s %s\n",
+		"# address", "good", "lastSuccess", "%(2h)", "%(8h)", "%(1d)",
+		"%(7d)", "%(30d)", "blocks", "svcs", "pver", "useragent")
+	for _, n := range nodes {
+		good := 0
+		if n.isGood() {
+			good = 1
+			numGood++
+		}
+		var lastSuccess int64
+		if !n.LastSuccess.IsZero() {
+			lastSuccess = n.LastSuccess.Unix()
+		}
+		dump += fmt.Sprintf("%-47s %4d %11d %6.2f%% %6.2f%% %6.2f%% "+
+			"%6.2f%% %6.2f%% %7d %016x %5d %s\n", n.Addr, good,
+			lastSuccess, n.uptime(stat2H), n.uptime(stat8H),
+			n.uptime(stat1D), n.uptime(stat1W), n.uptime(stat1M),
+			n.Height, uint64(n.Services), n.ProtocolVersion,
+			strconv.Quote(n.UserAgent))
+	}
+	path = filepath.Join(c.dataDir, dumpFilename)
+	if err := ioutil.WriteFile(path, []byte(dump), 0644); err != nil {
+		log.Errorf("Unable to write %s: %v", path, err)
+		return
+	}
+	log.Infof("Tracking %d nodes, %d good", len(nodes), numGood)
+}
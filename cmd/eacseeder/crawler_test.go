@@ -0,0 +1,191 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/wire"
+)
+
+// TestGoodAddrs ensures only good nodes supporting the requested services are
+// returned, and that nodes on other ports than the default one are only
+// returned on the test networks.
+func TestGoodAddrs(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	good := &visitResult{
+		success:  true,
+		services: wire.SFNodeNetwork | wire.SFNodeWitness,
+	}
+	limited := &visitResult{success: true, services: wire.SFNodeNetwork}
+
+	tests := []struct {
+		name   string
+		params *chaincfg.Params
+		ipv4   []string
+		ipv6   []string
+	}{
+		{
+			name:   "mainnet",
+			params: &chaincfg.MainNetParams,
+			ipv4:   []string{"1.2.3.4"},
+			ipv6:   []string{"2001:db8::1"},
+		},
+		{
+			name:   "regtest",
+			params: &chaincfg.RegressionNetParams,
+			ipv4:   []string{"1.2.3.4", "1.2.3.5"},
+			ipv6:   []string{"2001:db8::1"},
+		},
+		{
+			name:   "simnet",
+			params: &chaincfg.SimNetParams,
+			ipv4:   []string{"1.2.3.4", "1.2.3.5"},
+			ipv6:   []string{"2001:db8::1"},
+		},
+	}
+	for _, test := range tests {
+		c := newCrawler(test.params, "", 1)
+		port := test.params.DefaultPort
+		visits := []struct {
+			host string
+			port string
+			res  *visitResult
+		}{
+			{"1.2.3.4", port, good},
+			{"1.2.3.5", "1", good},
+			{"1.2.3.6", port, limited},
+			{"1.2.3.7", port, &visitResult{}},
+			{"2001:db8::1", port, good},
+		}
+		for _, v := range visits {
+			addr := net.JoinHostPort(v.host, v.port)
+			c.nodes[addr] = &node{Addr: addr}
+			c.recordVisit(addr, v.res, nil, now)
+		}
+
+		services := wire.SFNodeNetwork | wire.SFNodeWitness
+		for _, ipv4 := range []bool{true, false} {
+			want := test.ipv6
+			if ipv4 {
+				want = test.ipv4
+			}
+			var got []string
+			for _, ip := range c.goodAddrs(ipv4, services, 10) {
+				got = append(got, ip.String())
+			}
+			sort.Strings(got)
+			if len(got) != len(want) {
+				t.Fatalf("%s: got addresses %v, want %v",
+					test.name, got, want)
+			}
+			for i := range got {
+				if got[i] != want[i] {
+					t.Fatalf("%s: got addresses %v, want "+
+						"%v", test.name, got, want)
+				}
+			}
+		}
+
+		// The number of addresses is limited.
+		if got := c.goodAddrs(true, wire.SFNodeNetwork, 1); len(got) != 1 {
+			t.Fatalf("%s: got %d addresses, want 1", test.name,
+				len(got))
+		}
+	}
+}
+
+// TestCrawlBookkeeping ensures visits are recorded, addresses learned from
+// nodes are added to the crawl, stale nodes are forgotten and the crawl state
+// survives a restart.
+func TestCrawlBookkeeping(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "eacseeder")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	params := &chaincfg.MainNetParams
+	c := newCrawler(params, dataDir, 1)
+	now := time.Now()
+	seeder := net.JoinHostPort("1.2.3.4", params.DefaultPort)
+	c.addSeeder(seeder)
+	if _, ok := c.nodes[seeder]; !ok || len(c.nodes) != 1 {
+		t.Fatalf("seeder was not added to the crawl")
+	}
+
+	// Only recent routable addresses which can be crawled are added.
+	newAddr := func(ip string, port uint16, age time.Duration) *wire.NetAddress {
+		na := wire.NewNetAddressIPPort(net.ParseIP(ip), port,
+			wire.SFNodeNetwork)
+		na.Timestamp = now.Add(-age)
+		return na
+	}
+	c.visiting[seeder] = struct{}{}
+	c.recordVisit(seeder, &visitResult{
+		success:  true,
+		services: wire.SFNodeNetwork,
+		height:   100,
+	}, []*wire.NetAddress{
+		newAddr("5.6.7.8", 35677, time.Hour),
+		newAddr("5.6.7.9", 0, time.Hour),
+		newAddr("5.6.7.10", 35677, maxAddrAge+time.Hour),
+		newAddr("10.0.0.1", 35677, time.Hour),
+		newAddr("fd87:d87e:eb43::1", 35677, time.Hour),
+	}, now)
+	if _, ok := c.visiting[seeder]; ok {
+		t.Fatalf("visited node is still being visited")
+	}
+	n := c.nodes[seeder]
+	if n.Attempts != 1 || n.Successes != 1 || n.Height != 100 ||
+		!n.LastSuccess.Equal(now) {
+
+		t.Fatalf("visit was not recorded: %+v", n)
+	}
+	if _, ok := c.nodes["5.6.7.8:35677"]; !ok || len(c.nodes) != 2 {
+		t.Fatalf("unexpected nodes after visit: %v", c.nodes)
+	}
+
+	// Unroutable addresses are crawled on the test networks.
+	testCrawler := newCrawler(&chaincfg.RegressionNetParams, "", 1)
+	testCrawler.addAddrs([]*wire.NetAddress{
+		newAddr("10.0.0.1", 18444, time.Hour),
+	}, now)
+	if len(testCrawler.nodes) != 1 {
+		t.Fatalf("unroutable address was not added on regtest")
+	}
+
+	// Nodes which were never reachable are forgotten after too many
+	// failures, while nodes which are not due are left alone.
+	failing := c.nodes["5.6.7.8:35677"]
+	for i := 0; i < maxFailuresBeforeEviction; i++ {
+		c.recordVisit(failing.Addr, &visitResult{}, nil, now)
+	}
+	sem := make(chan struct{}, 1)
+	c.dispatch(sem)
+	if _, ok := c.nodes[failing.Addr]; ok || len(c.nodes) != 1 {
+		t.Fatalf("stale node was not forgotten")
+	}
+	if len(sem) != 0 || len(c.visiting) != 0 {
+		t.Fatalf("node which is not due was visited")
+	}
+
+	// The crawl state is restored after a restart.
+	c.save()
+	restarted := newCrawler(params, dataDir, 1)
+	restarted.load()
+	n = restarted.nodes[seeder]
+	if len(restarted.nodes) != 1 || n == nil || n.Successes != 1 ||
+		!n.LastSuccess.Equal(now) {
+
+		t.Fatalf("crawl state was not restored: %v", restarted.nodes)
+	}
+}
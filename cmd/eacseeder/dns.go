@@ -0,0 +1,310 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eacsuite/eacd/wire"
+)
+
+// DNS message constants as defined by RFC 1035 and RFC 3596.
+const (
+	dnsHeaderLen = 12
+
+	// dnsRecordOverhead is the size of a resource record without its data
+	// when the name is a compression pointer.
+	dnsRecordOverhead = 12
+
+	// dnsMaxUDPLen is the maximum size of a DNS message sent over UDP
+	// without EDNS.
+	dnsMaxUDPLen = 512
+
+	dnsTypeA    = 1
+	dnsTypeNS   = 2
+	dnsTypeSOA  = 6
+	dnsTypeAAAA = 28
+	dnsTypeANY  = 255
+
+	dnsClassIN = 1
+
+	dnsRcodeSuccess  = 0
+	dnsRcodeFormErr  = 1
+	dnsRcodeNXDomain = 3
+	dnsRcodeNotImp   = 4
+	dnsRcodeRefused  = 5
+
+	// dnsNamePointer is a compression pointer to the question name, which
+	// always starts right after the header.
+	dnsNamePointer = 0xc000 | dnsHeaderLen
+)
+
+const (
+	// addrTTL is the time to live of the A and AAAA records.
+	addrTTL = 60
+
+	// zoneTTL is the time to live of the NS and SOA records.
+	zoneTTL = 40000
+
+	// maxAddrRecords is the maximum number of A or AAAA records returned in
+	// a single response.
+	maxAddrRecords = 25
+
+	// defaultServices are the services nodes must support to be returned
+	// for queries without a service bits subdomain.
+	defaultServices = wire.SFNodeNetwork
+)
+
+// errMalformedQuery is returned when a DNS query can't be parsed.
+var errMalformedQuery = errors.New("malformed DNS query")
+
+// addrSource provides the addresses of good nodes to the DNS responder.
+type addrSource interface {
+	// goodAddrs returns up to max randomly selected IPv4 or IPv6 addresses
+	// of good nodes which support all of the passed services.
+	goodAddrs(ipv4 bool, services wire.ServiceFlag, max int) []net.IP
+}
+
+// dnsQuestion is the question section of a DNS query.
+type dnsQuestion struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+}
+
+// dnsServer is an authoritative DNS responder for the seeder zone.  Queries
+// for the zone itself return good nodes that support the default services,
+// while queries for an x<flags> subdomain, where flags are the required
+// service bits in hex, return good nodes that support all of those services.
+type dnsServer struct {
+	host       string
+	nameserver string
+	mbox       string
+	source     addrSource
+}
+
+// newDNSServer returns a DNS responder for the passed zone which serves the
+// addresses provided by source.
+func newDNSServer(host, nameserver, mbox string, source addrSource) *dnsServer {
+	return &dnsServer{
+		host:       host,
+		nameserver: nameserver,
+		mbox:       mbox,
+		source:     source,
+	}
+}
+
+// Serve answers the queries received on conn until it is closed.
+func (s *dnsServer) Serve(conn net.PacketConn) {
+	buf := make([]byte, dnsMaxUDPLen)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			log.Debugf("DNS responder done: %v", err)
+			return
+		}
+
+		resp, err := s.handleQuery(buf[:n])
+		if err != nil {
+			log.Debugf("Ignoring query from %v: %v", addr, err)
+			continue
+		}
+		if _, err := conn.WriteTo(resp, addr); err != nil {
+			log.Debugf("Unable to answer query from %v: %v", addr, err)
+		}
+	}
+}
+
+// parseName decodes the uncompressed domain name starting at the passed offset
+// of msg and returns it in lower case without the trailing dot along with the
+// offset following it.
+func parseName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	for {
+		if offset >= len(msg) {
+			return "", 0, errMalformedQuery
+		}
+		labelLen := int(msg[offset])
+		offset++
+		if labelLen == 0 {
+			break
+		}
+		// Queries never contain compressed names, so anything but a
+		// plain label is rejected.
+		if labelLen > 63 || offset+labelLen > len(msg) {
+			return "", 0, errMalformedQuery
+		}
+		labels = append(labels, string(msg[offset:offset+labelLen]))
+		offset += labelLen
+	}
+	return strings.ToLower(strings.Join(labels, ".")), offset, nil
+}
+
+// appendName appends the passed domain name to msg in uncompressed form.
+func appendName(msg []byte, name string) []byte {
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			continue
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	return append(msg, 0)
+}
+
+// appendRecord appends a resource record for the question name with the given
+// type, ttl and data to msg.
+func appendRecord(msg []byte, rtype uint16, ttl uint32, data []byte) []byte {
+	var hdr [10]byte
+	binary.BigEndian.PutUint16(hdr[0:2], dnsNamePointer)
+	binary.BigEndian.PutUint16(hdr[2:4], rtype)
+	binary.BigEndian.PutUint16(hdr[4:6], dnsClassIN)
+	binary.BigEndian.PutUint32(hdr[6:10], ttl)
+	msg = append(msg, hdr[:]...)
+	var rdlen [2]byte
+	binary.BigEndian.PutUint16(rdlen[:], uint16(len(data)))
+	msg = append(msg, rdlen[:]...)
+	return append(msg, data...)
+}
+
+// nsData returns the data of the NS record of the zone.
+func (s *dnsServer) nsData() []byte {
+	return appendName(nil, s.nameserver)
+}
+
+// soaData returns the data of the SOA record of the zone.  The serial is
+// derived from the current time since the records change constantly.
+func (s *dnsServer) soaData() []byte {
+	data := appendName(nil, s.nameserver)
+	data = appendName(data, strings.Replace(s.mbox, "@", ".", 1))
+	var fields [20]byte
+	binary.BigEndian.PutUint32(fields[0:4], uint32(time.Now().Unix()))
+	binary.BigEndian.PutUint32(fields[4:8], 604800)    // refresh
+	binary.BigEndian.PutUint32(fields[8:12], 86400)    // retry
+	binary.BigEndian.PutUint32(fields[12:16], 2592000) // expire
+	binary.BigEndian.PutUint32(fields[16:20], 604800)  // minimum
+	return append(data, fields[:]...)
+}
+
+// zoneServices returns the services requested by a query for the passed name
+// and whether the name is part of the zone at all.  A query for a name within
+// the zone that does not exist returns zero services.
+func (s *dnsServer) zoneServices(name string) (wire.ServiceFlag, bool) {
+	if name == s.host {
+		return defaultServices, true
+	}
+	if !strings.HasSuffix(name, "."+s.host) {
+		return 0, false
+	}
+
+	sub := strings.TrimSuffix(name, "."+s.host)
+	if len(sub) < 2 || sub[0] != 'x' {
+		return 0, true
+	}
+	services, err := strconv.ParseUint(sub[1:], 16, 64)
+	if err != nil {
+		return 0, true
+	}
+	return wire.ServiceFlag(services), true
+}
+
+// handleQuery returns the response to the passed DNS query.  An error is
+// returned for messages which can't be answered at all, such as responses or
+// truncated headers.
+func (s *dnsServer) handleQuery(query []byte) ([]byte, error) {
+	if len(query) < dnsHeaderLen {
+		return nil, errMalformedQuery
+	}
+	flags := binary.BigEndian.Uint16(query[2:4])
+	if flags&0x8000 != 0 {
+		return nil, errors.New("message is not a query")
+	}
+
+	resp := make([]byte, dnsHeaderLen, dnsMaxUDPLen)
+	copy(resp[0:2], query[0:2])
+
+	// Set the response and authoritative answer bits and echo the opcode
+	// and recursion desired bits.
+	respFlags := uint16(0x8400) | flags&0x7900
+	setRcode := func(rcode uint16) []byte {
+		binary.BigEndian.PutUint16(resp[2:4], respFlags|rcode)
+		return resp
+	}
+
+	opcode := flags >> 11 & 0xf
+	if opcode != 0 {
+		return setRcode(dnsRcodeNotImp), nil
+	}
+	if binary.BigEndian.Uint16(query[4:6]) != 1 {
+		return setRcode(dnsRcodeFormErr), nil
+	}
+	name, offset, err := parseName(query, dnsHeaderLen)
+	if err != nil || offset+4 > len(query) {
+		return setRcode(dnsRcodeFormErr), nil
+	}
+	q := dnsQuestion{
+		name:   name,
+		qtype:  binary.BigEndian.Uint16(query[offset : offset+2]),
+		qclass: binary.BigEndian.Uint16(query[offset+2 : offset+4]),
+	}
+
+	// Echo the question.
+	binary.BigEndian.PutUint16(resp[4:6], 1)
+	resp = append(resp, query[dnsHeaderLen:offset+4]...)
+
+	services, inZone := s.zoneServices(q.name)
+	if !inZone || q.qclass != dnsClassIN {
+		return setRcode(dnsRcodeRefused), nil
+	}
+	if services == 0 {
+		resp = appendRecord(resp, dnsTypeSOA, zoneTTL, s.soaData())
+		binary.BigEndian.PutUint16(resp[8:10], 1)
+		return setRcode(dnsRcodeNXDomain), nil
+	}
+
+	var answers uint16
+	addAnswer := func(rtype uint16, ttl uint32, data []byte) {
+		if len(resp)+dnsRecordOverhead+len(data) > dnsMaxUDPLen {
+			return
+		}
+		resp = appendRecord(resp, rtype, ttl, data)
+		answers++
+	}
+	if q.name == s.host {
+		if q.qtype == dnsTypeNS || q.qtype == dnsTypeANY {
+			addAnswer(dnsTypeNS, zoneTTL, s.nsData())
+		}
+		if q.qtype == dnsTypeSOA || q.qtype == dnsTypeANY {
+			addAnswer(dnsTypeSOA, zoneTTL, s.soaData())
+		}
+	}
+	if q.qtype == dnsTypeA || q.qtype == dnsTypeANY {
+		for _, ip := range s.source.goodAddrs(true, services,
+			maxAddrRecords) {
+
+			addAnswer(dnsTypeA, addrTTL, ip.To4())
+		}
+	}
+	if q.qtype == dnsTypeAAAA || q.qtype == dnsTypeANY {
+		for _, ip := range s.source.goodAddrs(false, services,
+			maxAddrRecords) {
+
+			addAnswer(dnsTypeAAAA, addrTTL, ip.To16())
+		}
+	}
+	binary.BigEndian.PutUint16(resp[6:8], answers)
+
+	log.Debugf("Answered query for %s (type %d) with %d records", q.name,
+		q.qtype, answers)
+	return setRcode(dnsRcodeSuccess), nil
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/eacsuite/eacd/wire"
+)
+
+// fakeAddrSource is an addrSource which returns fixed addresses of nodes with
+// fixed services.
+type fakeAddrSource struct {
+	services wire.ServiceFlag
+	ipv4     []net.IP
+	ipv6     []net.IP
+}
+
+func (f *fakeAddrSource) goodAddrs(ipv4 bool, services wire.ServiceFlag,
+	max int) []net.IP {
+
+	if f.services&services != services {
+		return nil
+	}
+	ips := f.ipv6
+	if ipv4 {
+		ips = f.ipv4
+	}
+	if len(ips) > max {
+		ips = ips[:max]
+	}
+	return ips
+}
+
+// buildQuery returns a DNS query for the passed name and type.
+func buildQuery(id uint16, name string, qtype uint16) []byte {
+	query := make([]byte, dnsHeaderLen)
+	binary.BigEndian.PutUint16(query[0:2], id)
+	binary.BigEndian.PutUint16(query[2:4], 0x0100) // recursion desired
+	binary.BigEndian.PutUint16(query[4:6], 1)
+	query = appendName(query, name)
+	var tail [4]byte
+	binary.BigEndian.PutUint16(tail[0:2], qtype)
+	binary.BigEndian.PutUint16(tail[2:4], dnsClassIN)
+	return append(query, tail[:]...)
+}
+
+// TestHandleQuery ensures the DNS responder answers queries as expected.
+func TestHandleQuery(t *testing.T) {
+	source := &fakeAddrSource{
+		services: wire.SFNodeNetwork | wire.SFNodeWitness,
+		ipv4: []net.IP{
+			net.ParseIP("1.2.3.4"),
+			net.ParseIP("5.6.7.8"),
+		},
+		ipv6: []net.IP{net.ParseIP("2001:db8::1")},
+	}
+	s := newDNSServer("seed.example.com", "ns.example.com",
+		"admin@example.com", source)
+
+	tests := []struct {
+		name      string
+		qname     string
+		qtype     uint16
+		rcode     uint16
+		answers   uint16
+		authority uint16
+		firstType uint16
+	}{
+		{"A", "seed.example.com", dnsTypeA, dnsRcodeSuccess, 2, 0, dnsTypeA},
+		{"AAAA mixed case", "SEED.Example.com", dnsTypeAAAA,
+			dnsRcodeSuccess, 1, 0, dnsTypeAAAA},
+		{"ANY", "seed.example.com", dnsTypeANY, dnsRcodeSuccess, 5, 0,
+			dnsTypeNS},
+		{"NS", "seed.example.com", dnsTypeNS, dnsRcodeSuccess, 1, 0,
+			dnsTypeNS},
+		{"supported services", "x9.seed.example.com", dnsTypeA,
+			dnsRcodeSuccess, 2, 0, dnsTypeA},
+		{"unsupported services", "x5.seed.example.com", dnsTypeA,
+			dnsRcodeSuccess, 0, 0, 0},
+		{"unknown subdomain", "www.seed.example.com", dnsTypeA,
+			dnsRcodeNXDomain, 0, 1, 0},
+		{"outside zone", "example.org", dnsTypeA, dnsRcodeRefused, 0, 0, 0},
+	}
+	for _, test := range tests {
+		query := buildQuery(0x1234, test.qname, test.qtype)
+		resp, err := s.handleQuery(query)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if len(resp) < len(query) {
+			t.Errorf("%s: short response of %d bytes", test.name,
+				len(resp))
+			continue
+		}
+		if id := binary.BigEndian.Uint16(resp[0:2]); id != 0x1234 {
+			t.Errorf("%s: got id %x, want 1234", test.name, id)
+		}
+		flags := binary.BigEndian.Uint16(resp[2:4])
+		if flags&0x8400 != 0x8400 || flags&0x0100 == 0 {
+			t.Errorf("%s: unexpected flags %x", test.name, flags)
+		}
+		if rcode := flags & 0xf; rcode != test.rcode {
+			t.Errorf("%s: got rcode %d, want %d", test.name, rcode,
+				test.rcode)
+		}
+		if n := binary.BigEndian.Uint16(resp[6:8]); n != test.answers {
+			t.Errorf("%s: got %d answers, want %d", test.name, n,
+				test.answers)
+		}
+		if n := binary.BigEndian.Uint16(resp[8:10]); n != test.authority {
+			t.Errorf("%s: got %d authority records, want %d",
+				test.name, n, test.authority)
+		}
+		if test.answers == 0 {
+			continue
+		}
+
+		// The first answer follows the echoed question and refers to
+		// its name.
+		rr := resp[len(query):]
+		if ptr := binary.BigEndian.Uint16(rr[0:2]); ptr != dnsNamePointer {
+			t.Errorf("%s: got name pointer %x", test.name, ptr)
+		}
+		if rtype := binary.BigEndian.Uint16(rr[2:4]); rtype != test.firstType {
+			t.Errorf("%s: got record type %d, want %d", test.name,
+				rtype, test.firstType)
+		}
+		if test.firstType == dnsTypeA {
+			ip := net.IP(rr[12:16])
+			if !ip.Equal(source.ipv4[0]) {
+				t.Errorf("%s: got address %v, want %v", test.name,
+					ip, source.ipv4[0])
+			}
+		}
+	}
+}
+
+// TestHandleQueryMalformed ensures malformed queries are rejected.
+func TestHandleQueryMalformed(t *testing.T) {
+	s := newDNSServer("seed.example.com", "ns.example.com",
+		"admin@example.com", &fakeAddrSource{})
+
+	if _, err := s.handleQuery([]byte{0x12, 0x34}); err == nil {
+		t.Error("truncated header was accepted")
+	}
+
+	response := buildQuery(1, "seed.example.com", dnsTypeA)
+	response[2] |= 0x80
+	if _, err := s.handleQuery(response); err == nil {
+		t.Error("response was answered")
+	}
+
+	truncated := buildQuery(1, "seed.example.com", dnsTypeA)
+	resp, err := s.handleQuery(truncated[:len(truncated)-3])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rcode := binary.BigEndian.Uint16(resp[2:4]) & 0xf; rcode != dnsRcodeFormErr {
+		t.Errorf("got rcode %d for truncated question, want %d", rcode,
+			dnsRcodeFormErr)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+
+	"github.com/btcsuite/btclog"
+	"github.com/eacsuite/eacd/limits"
+	"github.com/eacsuite/eacd/peer"
+)
+
+var (
+	cfg *config
+	log = btclog.Disabled
+)
+
+// realMain is the real main function for the utility.  It is necessary to work
+// around the fact that deferred functions do not run when os.Exit() is called.
+func realMain() error {
+	// Load configuration and parse command line.
+	tcfg, _, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	cfg = tcfg
+
+	// Setup logging.  The peer subsystem is only interesting when
+	// debugging since failing to connect to nodes is the norm.
+	backendLogger := btclog.NewBackend(os.Stdout)
+	defer os.Stdout.Sync()
+	level, _ := btclog.LevelFromString(cfg.DebugLevel)
+	log = backendLogger.Logger("SEED")
+	log.SetLevel(level)
+	peerLog := backendLogger.Logger("PEER")
+	peerLog.SetLevel(btclog.LevelWarn)
+	if level < btclog.LevelInfo {
+		peerLog.SetLevel(level)
+	}
+	peer.UseLogger(peerLog)
+
+	conn, err := net.ListenPacket("udp", cfg.Listen)
+	if err != nil {
+		log.Errorf("Unable to listen on %s: %v", cfg.Listen, err)
+		return err
+	}
+	defer conn.Close()
+
+	log.Infof("Seeding %s for zone %s (nameserver %s)", activeNetParams.Name,
+		cfg.Host, cfg.Nameserver)
+	c := newCrawler(activeNetParams, cfg.DataDir, cfg.MaxCrawlers)
+	c.Start(cfg.Seeders)
+	defer c.Stop()
+
+	dns := newDNSServer(cfg.Host, cfg.Nameserver, cfg.Mbox, c)
+	go dns.Serve(conn)
+	log.Infof("DNS responder listening on %s", conn.LocalAddr())
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	sig := <-interrupt
+	log.Infof("Received signal (%s).  Shutting down...", sig)
+	return nil
+}
+
+func main() {
+	// Use all processor cores and up some limits.
+	runtime.GOMAXPROCS(runtime.NumCPU())
+	if err := limits.SetLimits(); err != nil {
+		os.Exit(1)
+	}
+
+	// Work around defer not working after os.Exit()
+	if err := realMain(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"math"
+	"time"
+
+	"github.com/eacsuite/eacd/wire"
+)
+
+// statWindow identifies one of the time windows over which the reliability of
+// a node is tracked.
+type statWindow int
+
+const (
+	stat2H statWindow = iota
+	stat8H
+	stat1D
+	stat1W
+	stat1M
+	numStatWindows
+)
+
+// statWindowTaus are the time constants of the exponential decay applied to
+// the observations of each window.
+var statWindowTaus = [numStatWindows]time.Duration{
+	stat2H: 2 * time.Hour,
+	stat8H: 8 * time.Hour,
+	stat1D: 24 * time.Hour,
+	stat1W: 7 * 24 * time.Hour,
+	stat1M: 30 * 24 * time.Hour,
+}
+
+// goodThresholds are the minimum reliability and number of observations a node
+// must reach in any of the windows to be considered good.
+var goodThresholds = [numStatWindows]struct {
+	reliability float64
+	count       float64
+}{
+	stat2H: {0.85, 2},
+	stat8H: {0.70, 4},
+	stat1D: {0.55, 8},
+	stat1W: {0.45, 16},
+	stat1M: {0.35, 32},
+}
+
+const (
+	// minRetryInterval is the time to wait before crawling a node again
+	// after a successful visit.
+	minRetryInterval = 15 * time.Minute
+
+	// maxRetryInterval is the maximum time to wait before crawling a node
+	// again after repeated failures.
+	maxRetryInterval = 24 * time.Hour
+
+	// maxFailuresBeforeEviction is the number of consecutive failures after
+	// which a node that was never reachable is forgotten.
+	maxFailuresBeforeEviction = 8
+
+	// maxSilence is the duration after which a node that used to be
+	// reachable but has not been since is forgotten.
+	maxSilence = 30 * 24 * time.Hour
+)
+
+// reliabilityStat tracks the exponentially decaying reliability of a node over
+// a single time window.
+type reliabilityStat struct {
+	// Reliability is the decayed fraction of successful visits.
+	Reliability float64 `json:"reliability"`
+
+	// Count is the decayed number of visits.
+	Count float64 `json:"count"`
+}
+
+// update records the result of a visit made age after the previous one.
+func (s *reliabilityStat) update(good bool, age, tau time.Duration) {
+	f := math.Exp(-float64(age) / float64(tau))
+	s.Reliability *= f
+	if good {
+		s.Reliability += 1 - f
+	}
+	s.Count = s.Count*f + 1
+}
+
+// node houses the crawl state and statistics of a single network address.
+type node struct {
+	Addr            string                          `json:"addr"`
+	Services        wire.ServiceFlag                `json:"services"`
+	ProtocolVersion uint32                          `json:"protocolversion"`
+	UserAgent       string                          `json:"useragent"`
+	Height          int32                           `json:"height"`
+	Attempts        int                             `json:"attempts"`
+	Successes       int                             `json:"successes"`
+	Failures        int                             `json:"failures"`
+	LastAttempt     time.Time                       `json:"lastattempt"`
+	LastSuccess     time.Time                       `json:"lastsuccess"`
+	Stats           [numStatWindows]reliabilityStat `json:"stats"`
+}
+
+// visitResult describes the outcome of a visit of a node.
+type visitResult struct {
+	success         bool
+	services        wire.ServiceFlag
+	protocolVersion uint32
+	userAgent       string
+	height          int32
+}
+
+// update records the result of a visit of the node made at the given time.
+func (n *node) update(res *visitResult, now time.Time) {
+	age := time.Duration(0)
+	if !n.LastAttempt.IsZero() && now.After(n.LastAttempt) {
+		age = now.Sub(n.LastAttempt)
+	}
+	for w := range n.Stats {
+		n.Stats[w].update(res.success, age, statWindowTaus[w])
+	}
+
+	n.Attempts++
+	n.LastAttempt = now
+	if !res.success {
+		n.Failures++
+		return
+	}
+	n.Successes++
+	n.Failures = 0
+	n.LastSuccess = now
+	n.Services = res.services
+	n.ProtocolVersion = res.protocolVersion
+	n.UserAgent = res.userAgent
+	n.Height = res.height
+}
+
+// isGood returns whether the node has proven reliable enough to be returned
+// by the DNS responder.  Nodes that have only been visited a few times are
+// considered good as long as at least half of the visits succeeded.
+func (n *node) isGood() bool {
+	if n.Successes == 0 || n.Services&wire.SFNodeNetwork == 0 {
+		return false
+	}
+	if n.Attempts <= 3 && n.Successes*2 >= n.Attempts {
+		return true
+	}
+	for w, threshold := range goodThresholds {
+		stat := n.Stats[w]
+		if stat.Reliability > threshold.reliability &&
+			stat.Count > threshold.count {
+
+			return true
+		}
+	}
+	return false
+}
+
+// uptime returns the percentage of successful visits over the given window.
+func (n *node) uptime(w statWindow) float64 {
+	return n.Stats[w].Reliability * 100
+}
+
+// nextAttempt returns the earliest time the node should be visited again.
+// The interval doubles with every consecutive failure.
+func (n *node) nextAttempt() time.Time {
+	if n.LastAttempt.IsZero() {
+		return n.LastAttempt
+	}
+	interval := minRetryInterval
+	for i := 0; i < n.Failures && interval < maxRetryInterval; i++ {
+		interval *= 2
+	}
+	if interval > maxRetryInterval {
+		interval = maxRetryInterval
+	}
+	return n.LastAttempt.Add(interval)
+}
+
+// isStale returns whether the node should be forgotten because it has been
+// unreachable for too long.
+func (n *node) isStale(now time.Time) bool {
+	if n.LastSuccess.IsZero() {
+		return n.Failures >= maxFailuresBeforeEviction
+	}
+	return now.Sub(n.LastSuccess) > maxSilence
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eacsuite/eacd/wire"
+)
+
+// TestNodeScoring ensures nodes are only considered good while they are
+// reliably reachable and are retried less often after repeated failures.
+func TestNodeScoring(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	success := &visitResult{success: true, services: wire.SFNodeNetwork}
+	failure := &visitResult{}
+
+	n := &node{Addr: "1.2.3.4:35677"}
+	if n.isGood() {
+		t.Fatal("unvisited node is good")
+	}
+	if !n.nextAttempt().IsZero() {
+		t.Fatal("unvisited node is not due immediately")
+	}
+
+	// A node reached on its first visit is good right away.
+	n.update(success, now)
+	if !n.isGood() {
+		t.Fatal("reachable node is not good")
+	}
+	if want := now.Add(minRetryInterval); !n.nextAttempt().Equal(want) {
+		t.Fatalf("got next attempt %v, want %v", n.nextAttempt(), want)
+	}
+
+	// Nodes that don't provide the network service are never good.
+	limited := &node{}
+	limited.update(&visitResult{success: true}, now)
+	if limited.isGood() {
+		t.Fatal("node without network service is good")
+	}
+
+	// Keep the node reachable for a day and ensure it stays good.
+	for i := 0; i < 96; i++ {
+		now = now.Add(minRetryInterval)
+		n.update(success, now)
+	}
+	if !n.isGood() || n.uptime(stat2H) < 99 {
+		t.Fatalf("reliable node is not good (2h uptime %.2f%%)",
+			n.uptime(stat2H))
+	}
+
+	// Repeated failures make it bad and back off the retries.
+	for i := 0; i < 96; i++ {
+		now = now.Add(time.Hour)
+		n.update(failure, now)
+	}
+	if n.isGood() {
+		t.Fatal("unreachable node is still good")
+	}
+	if want := now.Add(maxRetryInterval); !n.nextAttempt().Equal(want) {
+		t.Fatalf("got next attempt %v, want %v", n.nextAttempt(), want)
+	}
+	if n.isStale(now) {
+		t.Fatal("recently reachable node is stale")
+	}
+	if !n.isStale(now.Add(maxSilence)) {
+		t.Fatal("long unreachable node is not stale")
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/eacsuite/eacd/database"
 	_ "github.com/eacsuite/eacd/database/ffldb"
 	"github.com/eacsuite/eacd/mempool"
+	"github.com/eacsuite/eacd/mining"
 	"github.com/eacsuite/eacd/peer"
 	"github.com/eacsuite/eacutil"
 )
@@ -46,6 +47,7 @@ const (
 	defaultMaxRPCClients         = 10
 	defaultMaxRPCWebsockets      = 25
 	defaultMaxRPCConcurrentReqs  = 20
+	defaultMaxRestClients        = 10
 	defaultDbType                = "ffldb"
 	defaultFreeTxRelayLimit      = 15.0
 	defaultTrickleInterval       = peer.DefaultTrickleInterval
@@ -60,10 +62,21 @@ const (
 	defaultGenerate              = false
 	defaultMaxOrphanTransactions = 100
 	defaultMaxOrphanTxSize       = 100000
+	defaultMaxMempool            = mempool.DefaultMaxPoolSize / 1000000
+	minMaxMempool                = 5
+	defaultMempoolExpiry         = int(mempool.DefaultMaxTxAge / time.Hour)
+	defaultLimitAncestorCount    = mempool.DefaultMaxAncestorCount
+	defaultLimitAncestorSize     = mempool.DefaultMaxAncestorSize / 1000
+	defaultLimitDescendantCount  = mempool.DefaultMaxDescendantCount
+	defaultLimitDescendantSize   = mempool.DefaultMaxDescendantSize / 1000
 	defaultSigCacheMaxSize       = 100000
+	defaultStratumPort           = "3333"
+	defaultStratumDifficulty     = 65536
+	defaultStratumMaxClients     = 100
 	sampleConfigFilename         = "sample-eacd.conf"
 	defaultTxIndex               = false
 	defaultAddrIndex             = false
+	defaultTorControl            = "127.0.0.1:9051"
 )
 
 var (
@@ -98,6 +111,7 @@ type config struct {
 	AddrIndex            bool          `long:"addrindex" description:"Maintain a full address-based transaction index which makes the searchrawtransactions RPC available"`
 	AgentBlacklist       []string      `long:"agentblacklist" description:"A comma separated list of user-agent substrings which will cause btcd to reject any peers whose user-agent contains any of the blacklisted substrings."`
 	AgentWhitelist       []string      `long:"agentwhitelist" description:"A comma separated list of user-agent substrings which will cause btcd to require all peers' user-agents to contain one of the whitelisted substrings. The blacklist is applied before the blacklist, and an empty whitelist will allow all agents that do not fail the blacklist."`
+	ASMap                string        `long:"asmap" description:"Path to an asmap file used to group peers by the autonomous system announcing their address instead of by network prefix -- Relative paths are resolved against the data directory"`
 	BanDuration          time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
 	BanThreshold         uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and banning misbehaving peers."`
 	BlockMaxSize         uint32        `long:"blockmaxsize" description:"Maximum block size in bytes to be used when creating a block"`
@@ -106,6 +120,8 @@ type config struct {
 	BlockMinWeight       uint32        `long:"blockminweight" description:"Mininum block weight to be used when creating a block"`
 	BlockPrioritySize    uint32        `long:"blockprioritysize" description:"Size in bytes for high-priority/low-fee transactions when creating a block"`
 	BlocksOnly           bool          `long:"blocksonly" description:"Do not accept transactions from remote peers."`
+	BlockRelayOutbound   int           `long:"blockrelayoutbound" description:"Number of block-relay-only outbound peers to maintain in addition to the full relay outbound peers -- These peers do not relay transactions or addresses and are reconnected to first on restart"`
+	CoinbaseFlags        string        `long:"coinbaseflags" description:"Message and pool tag to add to the coinbase script of generated blocks instead of the default /P2SH/eacd/"`
 	ConfigFile           string        `short:"C" long:"configfile" description:"Path to configuration file"`
 	ConnectPeers         []string      `long:"connect" description:"Connect only to the specified peers at startup"`
 	CPUProfile           string        `long:"cpuprofile" description:"Write CPU profile to the specified file"`
@@ -117,13 +133,24 @@ type config struct {
 	DropTxIndex          bool          `long:"droptxindex" description:"Deletes the hash-based transaction index from the database on start up and then exits."`
 	ExternalIPs          []string      `long:"externalip" description:"Add an ip to the list of local addresses we claim to listen on to peers"`
 	Generate             bool          `long:"generate" description:"Generate (mine) bitcoins using the CPU"`
+	I2PAcceptIncoming    bool          `long:"i2pacceptincoming" description:"Accept inbound connections from I2P peers through the SAM bridge specified by --i2psam"`
+	I2PSAM               string        `long:"i2psam" description:"Connect to I2P peers through the given I2P SAM bridge (eg. 127.0.0.1:7656)"`
+	LimitAncestorCount   int           `long:"limitancestorcount" description:"Do not accept transactions with more in-mempool ancestors (0 to disable)"`
+	LimitAncestorSize    int           `long:"limitancestorsize" description:"Do not accept transactions whose size in kilobytes with all in-mempool ancestors exceeds this value (0 to disable)"`
+	LimitDescendantCount int           `long:"limitdescendantcount" description:"Do not accept transactions if any ancestor would have more in-mempool descendants (0 to disable)"`
+	LimitDescendantSize  int           `long:"limitdescendantsize" description:"Do not accept transactions if any ancestor would have more than this many kilobytes of in-mempool descendants (0 to disable)"`
 	FreeTxRelayLimit     float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
 	Listeners            []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
+	ListenOnion          bool          `long:"listenonion" description:"Automatically create a Tor onion service through the Tor control port to accept inbound connections"`
 	LogDir               string        `long:"logdir" description:"Directory to log output."`
+	MaxMempool           int           `long:"maxmempool" description:"Max size in megabytes of the transactions in the memory pool -- The cheapest transactions are evicted when it is exceeded"`
 	MaxOrphanTxs         int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
 	MaxPeers             int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
-	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
+	MempoolExpiry        int           `long:"mempoolexpiry" description:"Do not keep transactions in the memory pool longer than this many hours (0 to disable)"`
+	MempoolFullRBF       bool          `long:"mempoolfullrbf" description:"Accept transactions replacing transactions in the memory pool even when those do not signal replaceability through the Replace-By-Fee (RBF) signaling policy"`
+	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- Use <addr>:<weight>,<addr>:<weight>,... to split the reward of a block among several addresses in proportion to their weights -- At least one address is required if the generate option is set"`
 	MinRelayTxFee        float64       `long:"minrelaytxfee" description:"The minimum transaction fee in BTC/kB to be considered a non-zero fee."`
+	NATPMP               bool          `long:"natpmp" description:"Use NAT-PMP or PCP to map our listening port outside of NAT"`
 	DisableBanning       bool          `long:"nobanning" description:"Disable banning of misbehaving peers"`
 	NoCFilters           bool          `long:"nocfilters" description:"Disable committed filtering (CF) support"`
 	DisableCheckpoints   bool          `long:"nocheckpoints" description:"Disable built-in checkpoints.  Don't do this unless you know what you're doing."`
@@ -138,16 +165,22 @@ type config struct {
 	OnionProxy           string        `long:"onion" description:"Connect to tor hidden services via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	OnionProxyPass       string        `long:"onionpass" default-mask:"-" description:"Password for onion proxy server"`
 	OnionProxyUser       string        `long:"onionuser" description:"Username for onion proxy server"`
+	PersistMempool       bool          `long:"persistmempool" description:"Save the memory pool to mempool.dat in the data directory on shutdown and load it on startup"`
+	PortMap              bool          `long:"portmap" description:"Map our listening port outside of NAT using whichever of PCP, NAT-PMP and UPnP the router supports"`
 	Profile              string        `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
 	Proxy                string        `long:"proxy" description:"Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	ProxyPass            string        `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
 	ProxyUser            string        `long:"proxyuser" description:"Username for proxy server"`
+	TorControl           string        `long:"torcontrol" description:"Tor control port used to create the onion service when --listenonion is set"`
 	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
+	TorPassword          string        `long:"torpassword" default-mask:"-" description:"Password for the Tor control port -- Cookie authentication is used when not specified"`
 	TestNet4             bool          `long:"testnet" description:"Use the test network"`
 	RegressionTest       bool          `long:"regtest" description:"Use the regression test network"`
 	RejectNonStd         bool          `long:"rejectnonstd" description:"Reject non-standard transactions regardless of the default settings for the active network."`
 	RejectReplacement    bool          `long:"rejectreplacement" description:"Reject transactions that attempt to replace existing transactions within the mempool through the Replace-By-Fee (RBF) signaling policy."`
 	RelayNonStd          bool          `long:"relaynonstd" description:"Relay non-standard transactions regardless of the default settings for the active network."`
+	Rest                 bool          `long:"rest" description:"Serve the unauthenticated read-only REST interface under /rest/ on the RPC listeners"`
+	RestMaxClients       int           `long:"restmaxclients" description:"Max number of REST clients, which are limited separately from RPC clients"`
 	RPCCert              string        `long:"rpccert" description:"File containing the certificate file"`
 	RPCKey               string        `long:"rpckey" description:"File containing the certificate key"`
 	RPCLimitPass         string        `long:"rpclimitpass" default-mask:"-" description:"Password for limited RPC connections"`
@@ -161,17 +194,26 @@ type config struct {
 	RPCUser              string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
 	SigCacheMaxSize      uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
+	StratumDifficulty    float64       `long:"stratumdifficulty" description:"Initial share difficulty of Stratum connections before it is adjusted to their hash rate"`
+	StratumListeners     []string      `long:"stratumlisten" description:"Add an interface/port to listen for Stratum miner connections (default port: 3333) -- The Stratum server is disabled unless at least one is specified"`
+	StratumMaxClients    int           `long:"stratummaxclients" description:"Max number of Stratum miner connections"`
 	TrickleInterval      time.Duration `long:"trickleinterval" description:"Minimum time between attempts to send new inventory to a connected peer"`
 	TxIndex              bool          `long:"txindex" description:"Maintain a full hash-based transaction index which makes all transactions available via the getrawtransaction RPC"`
 	UserAgentComments    []string      `long:"uacomment" description:"Comment to add to the user agent -- See BIP 14 for more information."`
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
 	ShowVersion          bool          `short:"V" long:"version" description:"Display version information and exit"`
 	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
+	ZMQPubHashBlock      string        `long:"zmqpubhashblock" description:"Publish the hashes of connected blocks on the given ZeroMQ endpoint (eg. tcp://127.0.0.1:28332)"`
+	ZMQPubHashTx         string        `long:"zmqpubhashtx" description:"Publish the hashes of transactions accepted into the mempool or included in connected and disconnected blocks on the given ZeroMQ endpoint"`
+	ZMQPubRawBlock       string        `long:"zmqpubrawblock" description:"Publish connected blocks on the given ZeroMQ endpoint"`
+	ZMQPubRawTx          string        `long:"zmqpubrawtx" description:"Publish transactions accepted into the mempool or included in connected and disconnected blocks on the given ZeroMQ endpoint"`
+	ZMQPubSequence       string        `long:"zmqpubsequence" description:"Publish block connections and disconnections and mempool additions and removals on the given ZeroMQ endpoint"`
 	lookup               func(string) ([]net.IP, error)
 	oniondial            func(string, string, time.Duration) (net.Conn, error)
+	i2pSession           *connmgr.I2PSession
 	dial                 func(string, string, time.Duration) (net.Conn, error)
 	addCheckpoints       []chaincfg.Checkpoint
-	miningAddrs          []eacutil.Address
+	miningPayouts        [][]mining.Payout
 	minRelayTxFee        eacutil.Amount
 	whitelists           []*net.IPNet
 }
@@ -373,6 +415,41 @@ func parseCheckpoints(checkpointStrings []string) ([]chaincfg.Checkpoint, error)
 	return checkpoints, nil
 }
 
+// parseMiningPayouts parses a payout list in the
+// '<address>[:<weight>][,<address>[:<weight>]...]' format for the passed
+// network.  Addresses without a weight have a weight of 1.
+func parseMiningPayouts(payoutList string, params *chaincfg.Params) ([]mining.Payout, error) {
+	entries := strings.Split(payoutList, ",")
+	payouts := make([]mining.Payout, 0, len(entries))
+	for _, entry := range entries {
+		strAddr, strWeight := entry, "1"
+		if i := strings.IndexByte(entry, ':'); i >= 0 {
+			strAddr, strWeight = entry[:i], entry[i+1:]
+		}
+		addr, err := eacutil.DecodeAddress(strAddr, params)
+		if err != nil {
+			return nil, fmt.Errorf("mining address '%s' failed to "+
+				"decode: %v", strAddr, err)
+		}
+		if !addr.IsForNet(params) {
+			return nil, fmt.Errorf("mining address '%s' is on the "+
+				"wrong network", strAddr)
+		}
+
+		weight, err := strconv.ParseUint(strWeight, 10, 32)
+		if err != nil || weight == 0 {
+			return nil, fmt.Errorf("weight '%s' of mining address "+
+				"'%s' is not a positive integer", strWeight,
+				strAddr)
+		}
+		payouts = append(payouts, mining.Payout{
+			Address: addr,
+			Weight:  uint32(weight),
+		})
+	}
+	return payouts, nil
+}
+
 // filesExists reports whether the named file or directory exists.
 func fileExists(name string) bool {
 	if _, err := os.Stat(name); err != nil {
@@ -410,17 +487,23 @@ func loadConfig() (*config, []string, error) {
 		ConfigFile:           defaultConfigFile,
 		DebugLevel:           defaultLogLevel,
 		MaxPeers:             defaultMaxPeers,
+		BlockRelayOutbound:   defaultBlockRelayOutbound,
 		BanDuration:          defaultBanDuration,
 		BanThreshold:         defaultBanThreshold,
 		RPCMaxClients:        defaultMaxRPCClients,
 		RPCMaxWebsockets:     defaultMaxRPCWebsockets,
 		RPCMaxConcurrentReqs: defaultMaxRPCConcurrentReqs,
+		RestMaxClients:       defaultMaxRestClients,
 		DataDir:              defaultDataDir,
 		LogDir:               defaultLogDir,
 		DbType:               defaultDbType,
 		RPCKey:               defaultRPCKeyFile,
 		RPCCert:              defaultRPCCertFile,
 		MinRelayTxFee:        mempool.DefaultMinRelayTxFee.ToBTC(),
+		LimitAncestorCount:   defaultLimitAncestorCount,
+		LimitAncestorSize:    defaultLimitAncestorSize,
+		LimitDescendantCount: defaultLimitDescendantCount,
+		LimitDescendantSize:  defaultLimitDescendantSize,
 		FreeTxRelayLimit:     defaultFreeTxRelayLimit,
 		TrickleInterval:      defaultTrickleInterval,
 		BlockMinSize:         defaultBlockMinSize,
@@ -428,9 +511,14 @@ func loadConfig() (*config, []string, error) {
 		BlockMinWeight:       defaultBlockMinWeight,
 		BlockMaxWeight:       defaultBlockMaxWeight,
 		BlockPrioritySize:    mempool.DefaultBlockPrioritySize,
+		MaxMempool:           defaultMaxMempool,
+		MempoolExpiry:        defaultMempoolExpiry,
 		MaxOrphanTxs:         defaultMaxOrphanTransactions,
 		SigCacheMaxSize:      defaultSigCacheMaxSize,
+		StratumDifficulty:    defaultStratumDifficulty,
+		StratumMaxClients:    defaultStratumMaxClients,
 		Generate:             defaultGenerate,
+		TorControl:           defaultTorControl,
 		TxIndex:              defaultTxIndex,
 		AddrIndex:            defaultAddrIndex,
 	}
@@ -593,6 +681,15 @@ func loadConfig() (*config, []string, error) {
 	cfg.LogDir = cleanAndExpandPath(cfg.LogDir)
 	cfg.LogDir = filepath.Join(cfg.LogDir, netName(activeNetParams))
 
+	// Resolve the asmap path against the data directory when it is not
+	// absolute.
+	if cfg.ASMap != "" {
+		cfg.ASMap = cleanAndExpandPath(cfg.ASMap)
+		if !filepath.IsAbs(cfg.ASMap) {
+			cfg.ASMap = filepath.Join(cfg.DataDir, cfg.ASMap)
+		}
+	}
+
 	// Special show command to list supported subsystems and exit.
 	if cfg.DebugLevel == "show" {
 		fmt.Println("Supported subsystems", supportedSubsystems())
@@ -695,6 +792,20 @@ func loadConfig() (*config, []string, error) {
 		cfg.DisableDNSSeed = true
 	}
 
+	// The number of block-relay-only outbound peers may not be negative
+	// and can't exceed the maximum number of peers.
+	if cfg.BlockRelayOutbound < 0 {
+		str := "%s: The blockrelayoutbound option may not be less " +
+			"than 0 -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.BlockRelayOutbound)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.BlockRelayOutbound > cfg.MaxPeers {
+		cfg.BlockRelayOutbound = cfg.MaxPeers
+	}
+
 	// Add the default listener if none were specified. The default
 	// listener is all addresses on the listen port for the network
 	// we are to connect to.
@@ -704,6 +815,55 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// An onion service can only forward connections when listening.
+	if cfg.ListenOnion && cfg.DisableListen {
+		str := "%s: the --listenonion option requires listening for " +
+			"incoming connections -- specify interfaces via --listen " +
+			"when --proxy or --connect are used"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if _, _, err := net.SplitHostPort(cfg.TorControl); err != nil {
+		str := "%s: Tor control address '%s' is invalid: %v"
+		err := fmt.Errorf(str, funcName, cfg.TorControl, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Inbound I2P connections are accepted through the SAM bridge, so
+	// there must be one and listening must not be disabled.
+	if cfg.I2PAcceptIncoming && cfg.I2PSAM == "" {
+		str := "%s: the --i2pacceptincoming option requires an I2P " +
+			"SAM bridge specified via --i2psam"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.I2PAcceptIncoming && cfg.DisableListen {
+		str := "%s: the --i2pacceptincoming option requires listening " +
+			"for incoming connections -- specify interfaces via " +
+			"--listen when --proxy or --connect are used"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.I2PSAM != "" {
+		if _, _, err := net.SplitHostPort(cfg.I2PSAM); err != nil {
+			str := "%s: I2P SAM bridge address '%s' is invalid: %v"
+			err := fmt.Errorf(str, funcName, cfg.I2PSAM, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.i2pSession = connmgr.NewI2PSession(cfg.I2PSAM,
+			filepath.Join(cfg.DataDir, i2pKeyFilename))
+	}
+
 	// Check to make sure limited and admin users don't have the same username
 	if cfg.RPCUser == cfg.RPCLimitUser && cfg.RPCUser != "" {
 		str := "%s: --rpcuser and --rpclimituser must not specify the " +
@@ -734,6 +894,17 @@ func loadConfig() (*config, []string, error) {
 		eacdLog.Infof("RPC service is disabled")
 	}
 
+	// The REST interface is served by the RPC server.
+	if cfg.Rest && cfg.DisableRPC {
+		str := "%s: the --rest option requires the RPC server to be " +
+			"enabled -- specify rpcuser and rpcpass or rpclimituser " +
+			"and rpclimitpass"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Default RPC to listen on localhost only.
 	if !cfg.DisableRPC && len(cfg.RPCListeners) == 0 {
 		addrs, err := net.LookupHost("localhost")
@@ -802,6 +973,48 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Ensure the memory pool can hold a reasonable number of transactions.
+	if cfg.MaxMempool < minMaxMempool {
+		str := "%s: The maxmempool option may not be less than %d " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, minMaxMempool, cfg.MaxMempool)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Ensure the mempool package limits and expiry are not negative.
+	packageLimits := []struct {
+		name  string
+		value int
+	}{
+		{"limitancestorcount", cfg.LimitAncestorCount},
+		{"limitancestorsize", cfg.LimitAncestorSize},
+		{"limitdescendantcount", cfg.LimitDescendantCount},
+		{"limitdescendantsize", cfg.LimitDescendantSize},
+		{"mempoolexpiry", cfg.MempoolExpiry},
+	}
+	for _, limit := range packageLimits {
+		if limit.value < 0 {
+			str := "%s: The %s option may not be less than 0 " +
+				"-- parsed [%d]"
+			err := fmt.Errorf(str, funcName, limit.name, limit.value)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// Full replace-by-fee requires replacements to be accepted.
+	if cfg.MempoolFullRBF && cfg.RejectReplacement {
+		str := "%s: mempoolfullrbf and rejectreplacement cannot be " +
+			"used together -- choose only one"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Limit the block priority and minimum block sizes to max block size.
 	cfg.BlockPrioritySize = minUint32(cfg.BlockPrioritySize, cfg.BlockMaxSize)
 	cfg.BlockMinSize = minUint32(cfg.BlockMinSize, cfg.BlockMaxSize)
@@ -868,25 +1081,30 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
-	// Check mining addresses are valid and saved parsed versions.
-	cfg.miningAddrs = make([]eacutil.Address, 0, len(cfg.MiningAddrs))
-	for _, strAddr := range cfg.MiningAddrs {
-		addr, err := eacutil.DecodeAddress(strAddr, activeNetParams.Params)
+	// Check mining payout lists are valid and saved parsed versions.
+	cfg.miningPayouts = make([][]mining.Payout, 0, len(cfg.MiningAddrs))
+	for _, payoutList := range cfg.MiningAddrs {
+		payouts, err := parseMiningPayouts(payoutList,
+			activeNetParams.Params)
 		if err != nil {
-			str := "%s: mining address '%s' failed to decode: %v"
-			err := fmt.Errorf(str, funcName, strAddr, err)
+			err := fmt.Errorf("%s: %v", funcName, err)
 			fmt.Fprintln(os.Stderr, err)
 			fmt.Fprintln(os.Stderr, usageMessage)
 			return nil, nil, err
 		}
-		if !addr.IsForNet(activeNetParams.Params) {
-			str := "%s: mining address '%s' is on the wrong network"
-			err := fmt.Errorf(str, funcName, strAddr)
-			fmt.Fprintln(os.Stderr, err)
-			fmt.Fprintln(os.Stderr, usageMessage)
-			return nil, nil, err
-		}
-		cfg.miningAddrs = append(cfg.miningAddrs, addr)
+		cfg.miningPayouts = append(cfg.miningPayouts, payouts)
+	}
+
+	// The coinbase flags must leave room for the rest of the coinbase
+	// script.
+	if len(cfg.CoinbaseFlags) > mining.MaxCoinbaseFlagsLen {
+		str := "%s: the coinbaseflags option may not be longer " +
+			"than %d bytes -- parsed [%d bytes]"
+		err := fmt.Errorf(str, funcName, mining.MaxCoinbaseFlagsLen,
+			len(cfg.CoinbaseFlags))
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
 	}
 
 	// Ensure there is at least one mining address when the generate flag is
@@ -900,6 +1118,26 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// The initial Stratum share difficulty must be positive.
+	if cfg.StratumDifficulty <= 0 {
+		str := "%s: the stratumdifficulty option must be greater " +
+			"than 0 -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.StratumDifficulty)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The Stratum server must accept at least one connection.
+	if cfg.StratumMaxClients <= 0 {
+		str := "%s: the stratummaxclients option must be greater " +
+			"than 0 -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.StratumMaxClients)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Add default port to all listener addresses if needed and remove
 	// duplicate addresses.
 	cfg.Listeners = normalizeAddresses(cfg.Listeners,
@@ -910,6 +1148,11 @@ func loadConfig() (*config, []string, error) {
 	cfg.RPCListeners = normalizeAddresses(cfg.RPCListeners,
 		activeNetParams.rpcPort)
 
+	// Add default port to all stratum listener addresses if needed and
+	// remove duplicate addresses.
+	cfg.StratumListeners = normalizeAddresses(cfg.StratumListeners,
+		defaultStratumPort)
+
 	// Only allow TLS to be disabled if the RPC is bound to localhost
 	// addresses.
 	if !cfg.DisableRPC && cfg.DisableTLS {
@@ -1161,12 +1404,19 @@ func createDefaultConfigFile(destinationPath string) error {
 // dial function depending on the address and configuration options.  For
 // example, .onion addresses will be dialed using the onion specific proxy if
 // one was specified, but will otherwise use the normal dial function (which
-// could itself use a proxy or not).
+// could itself use a proxy or not).  I2P addresses are dialed through the I2P
+// SAM bridge.
 func eacdDial(addr net.Addr) (net.Conn, error) {
 	if strings.Contains(addr.String(), ".onion:") {
 		return cfg.oniondial(addr.Network(), addr.String(),
 			defaultConnectTimeout)
 	}
+	if strings.Contains(addr.String(), ".b32.i2p:") {
+		if cfg.i2pSession == nil {
+			return nil, errors.New("no I2P SAM bridge configured")
+		}
+		return cfg.i2pSession.Dial(addr.String())
+	}
 	return cfg.dial(addr.Network(), addr.String(), defaultConnectTimeout)
 }
 
@@ -7,6 +7,8 @@ import (
 	"regexp"
 	"runtime"
 	"testing"
+
+	"github.com/eacsuite/eacd/chaincfg"
 )
 
 var (
@@ -70,3 +72,63 @@ func TestCreateDefaultConfigFile(t *testing.T) {
 		t.Error("Could not find rpcpass in generated default config file.")
 	}
 }
+
+// TestParseMiningPayouts ensures payout lists given with the miningaddr option
+// are parsed along with their weights.
+func TestParseMiningPayouts(t *testing.T) {
+	params := &chaincfg.SimNetParams
+	addr1 := "SMJ12qn9jNCCXJnTYRz5Yu9ZenERqvYwfg"
+	addr2 := "SRgpQFhQePjcT8cTX7tcPFnw8hQ4PGDsEd"
+
+	tests := []struct {
+		payoutList string
+		weights    []uint32
+		valid      bool
+	}{
+		{addr1, []uint32{1}, true},
+		{addr1 + ":5", []uint32{5}, true},
+		{addr1 + ":3," + addr2, []uint32{3, 1}, true},
+		{addr1 + ":1," + addr2 + ":4294967295", []uint32{1, 4294967295}, true},
+		{addr1 + ":0", nil, false},
+		{addr1 + ":-1", nil, false},
+		{addr1 + ":4294967296", nil, false},
+		{addr1 + ":", nil, false},
+		{addr1 + ",", nil, false},
+		{"notanaddress:1", nil, false},
+	}
+
+	for _, test := range tests {
+		payouts, err := parseMiningPayouts(test.payoutList, params)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("parseMiningPayouts(%q): no error",
+					test.payoutList)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseMiningPayouts(%q): unexpected error: %v",
+				test.payoutList, err)
+			continue
+		}
+		if len(payouts) != len(test.weights) {
+			t.Errorf("parseMiningPayouts(%q): got %d payouts, want %d",
+				test.payoutList, len(payouts), len(test.weights))
+			continue
+		}
+		for i, payout := range payouts {
+			if payout.Weight != test.weights[i] {
+				t.Errorf("parseMiningPayouts(%q): payout %d: got "+
+					"weight %d, want %d", test.payoutList, i,
+					payout.Weight, test.weights[i])
+			}
+		}
+	}
+
+	// Addresses of other networks are rejected.
+	_, err := parseMiningPayouts(addr1, &chaincfg.RegressionNetParams)
+	if err == nil {
+		t.Errorf("parseMiningPayouts: no error for address on the " +
+			"wrong network")
+	}
+}
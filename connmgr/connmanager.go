@@ -53,13 +53,16 @@ const (
 )
 
 // ConnReq is the connection request to a network address. If permanent, the
-// connection will be retried on disconnection.
+// connection will be retried on disconnection.  If block relay only, the
+// connection is intended to be used solely for relaying blocks and is not
+// counted towards the target number of full relay outbound connections.
 type ConnReq struct {
 	// The following variables must only be used atomically.
 	id uint64
 
-	Addr      net.Addr
-	Permanent bool
+	Addr           net.Addr
+	Permanent      bool
+	BlockRelayOnly bool
 
 	conn       net.Conn
 	state      ConnState
@@ -124,6 +127,20 @@ type Config struct {
 	// maintain. Defaults to 8.
 	TargetOutbound uint32
 
+	// TargetBlockRelayOutbound is the number of block-relay-only outbound
+	// network connections to maintain in addition to TargetOutbound.
+	// These connections do not relay transactions or addresses, which
+	// makes them harder to discover through transaction relay analysis.
+	// Defaults to 0, which disables block-relay-only connections.
+	TargetBlockRelayOutbound uint32
+
+	// Anchors is a list of addresses of block-relay-only peers from a
+	// previous session.  They are connected to before any new
+	// block-relay-only connections are requested in order to make eclipse
+	// attacks across restarts more difficult.  Only the first
+	// TargetBlockRelayOutbound anchors are used.
+	Anchors []net.Addr
+
 	// RetryDuration is the duration to wait before retrying connection
 	// requests. Defaults to 5s.
 	RetryDuration time.Duration
@@ -211,12 +228,34 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 				"-- retrying connection in: %v", maxFailedAttempts,
 				cm.cfg.RetryDuration)
 			time.AfterFunc(cm.cfg.RetryDuration, func() {
-				cm.NewConnReq()
+				cm.newConnReq(c.BlockRelayOnly)
 			})
 		} else {
-			go cm.NewConnReq()
+			go cm.newConnReq(c.BlockRelayOnly)
+		}
+	}
+}
+
+// targetFor returns the target number of outbound connections for the class
+// of connection request, either full relay or block relay only.
+func (cm *ConnManager) targetFor(c *ConnReq) uint32 {
+	if c.BlockRelayOnly {
+		return cm.cfg.TargetBlockRelayOutbound
+	}
+	return cm.cfg.TargetOutbound
+}
+
+// countClass returns the number of connections in the passed map which are of
+// the same class, either full relay or block relay only, as the passed
+// connection request.
+func countClass(conns map[uint64]*ConnReq, c *ConnReq) uint32 {
+	var count uint32
+	for _, connReq := range conns {
+		if connReq.BlockRelayOnly == c.BlockRelayOnly {
+			count++
 		}
 	}
+	return count
 }
 
 // connHandler handles all connection related requests.  It must be run as a
@@ -317,12 +356,13 @@ out:
 				}
 
 				// Otherwise, we will attempt a reconnection if
-				// we do not have enough peers, or if this is a
-				// persistent peer. The connection request is
-				// re added to the pending map, so that
-				// subsequent processing of connections and
-				// failures do not ignore the request.
-				if uint32(len(conns)) < cm.cfg.TargetOutbound ||
+				// we do not have enough peers of the same
+				// class, or if this is a persistent peer. The
+				// connection request is re added to the pending
+				// map, so that subsequent processing of
+				// connections and failures do not ignore the
+				// request.
+				if countClass(conns, connReq) < cm.targetFor(connReq) ||
 					connReq.Permanent {
 
 					connReq.updateState(ConnPending)
@@ -356,9 +396,21 @@ out:
 	log.Trace("Connection handler done")
 }
 
-// NewConnReq creates a new connection request and connects to the
+// NewConnReq creates a new full relay connection request and connects to the
 // corresponding address.
 func (cm *ConnManager) NewConnReq() {
+	cm.newConnReq(false)
+}
+
+// NewBlockRelayConnReq creates a new block-relay-only connection request and
+// connects to the corresponding address.
+func (cm *ConnManager) NewBlockRelayConnReq() {
+	cm.newConnReq(true)
+}
+
+// newConnReq creates a new connection request of the given class and connects
+// to the corresponding address.
+func (cm *ConnManager) newConnReq(blockRelayOnly bool) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
@@ -366,7 +418,7 @@ func (cm *ConnManager) NewConnReq() {
 		return
 	}
 
-	c := &ConnReq{}
+	c := &ConnReq{BlockRelayOnly: blockRelayOnly}
 	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
 
 	// Submit a request of a pending connection attempt to the connection
@@ -529,6 +581,20 @@ func (cm *ConnManager) Start() {
 	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.cfg.TargetOutbound); i++ {
 		go cm.NewConnReq()
 	}
+
+	// Reconnect to the anchors from the previous session before filling
+	// any remaining block-relay-only slots with new addresses.
+	var numBlockRelay uint32
+	for _, addr := range cm.cfg.Anchors {
+		if numBlockRelay >= cm.cfg.TargetBlockRelayOutbound {
+			break
+		}
+		go cm.Connect(&ConnReq{Addr: addr, BlockRelayOnly: true})
+		numBlockRelay++
+	}
+	for ; numBlockRelay < cm.cfg.TargetBlockRelayOutbound; numBlockRelay++ {
+		go cm.NewBlockRelayConnReq()
+	}
 }
 
 // Wait blocks until the connection manager halts gracefully.
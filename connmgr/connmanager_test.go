@@ -216,6 +216,84 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestBlockRelayOutbound tests that block-relay-only connections are made in
+// addition to the target number of full relay outbound connections and that
+// anchors are used for them before any new addresses.
+func TestBlockRelayOutbound(t *testing.T) {
+	targetOutbound := uint32(2)
+	targetBlockRelay := uint32(2)
+	anchor := &net.TCPAddr{
+		IP:   net.ParseIP("127.0.0.2"),
+		Port: 18555,
+	}
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound:           targetOutbound,
+		TargetBlockRelayOutbound: targetBlockRelay,
+		Anchors:                  []net.Addr{anchor},
+		Dial:                     mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+
+	var numFullRelay, numBlockRelay uint32
+	var sawAnchor bool
+	var blockRelayReq *ConnReq
+	for i := uint32(0); i < targetOutbound+targetBlockRelay; i++ {
+		c := <-connected
+		if !c.BlockRelayOnly {
+			numFullRelay++
+			continue
+		}
+		numBlockRelay++
+		blockRelayReq = c
+		if c.Addr.String() == anchor.String() {
+			sawAnchor = true
+		}
+	}
+	if numFullRelay != targetOutbound {
+		t.Fatalf("block relay outbound: got %d full relay connections, "+
+			"want %d", numFullRelay, targetOutbound)
+	}
+	if numBlockRelay != targetBlockRelay {
+		t.Fatalf("block relay outbound: got %d block relay connections, "+
+			"want %d", numBlockRelay, targetBlockRelay)
+	}
+	if !sawAnchor {
+		t.Fatalf("block relay outbound: no connection made to anchor %v",
+			anchor)
+	}
+
+	select {
+	case c := <-connected:
+		t.Fatalf("block relay outbound: got unexpected connection - %v",
+			c.Addr)
+	case <-time.After(time.Millisecond):
+		break
+	}
+
+	// Removing a block-relay-only connection must result in a new
+	// block-relay-only connection rather than a full relay one.
+	cmgr.Disconnect(blockRelayReq.ID())
+	c := <-connected
+	if !c.BlockRelayOnly {
+		t.Fatalf("block relay outbound: replacement for %v is not block "+
+			"relay only", blockRelayReq)
+	}
+	cmgr.Stop()
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
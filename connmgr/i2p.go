@@ -0,0 +1,507 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// i2pSAMTimeout is the maximum time to wait for the SAM bridge to
+	// answer a command.  Connecting to a destination involves building
+	// tunnels through the I2P network, so it is rather generous.
+	i2pSAMTimeout = 3 * time.Minute
+
+	// i2pSAMVersion is the version of the SAM protocol spoken to the
+	// bridge.
+	i2pSAMVersion = "3.1"
+
+	// i2pSignatureType is the signature type of newly generated
+	// destinations, which is EdDSA-SHA512-Ed25519.
+	i2pSignatureType = 7
+
+	// i2pMaxLineLen is the maximum length of a line sent by the SAM
+	// bridge.
+	i2pMaxLineLen = 65536
+
+	// i2pAcceptRetryInterval is the time to wait before trying to accept
+	// inbound connections again after the SAM bridge failed.
+	i2pAcceptRetryInterval = 5 * time.Second
+
+	// i2pSuffix is the suffix of the base32 addresses of I2P destinations.
+	i2pSuffix = ".b32.i2p"
+)
+
+var (
+	// ErrI2PInvalidSAMResponse indicates the SAM bridge returned a response
+	// in an unexpected format.
+	ErrI2PInvalidSAMResponse = errors.New("invalid SAM bridge response")
+
+	// ErrI2PSessionClosed is returned when using an I2P session or
+	// listener after it has been closed.
+	ErrI2PSessionClosed = errors.New("I2P session closed")
+
+	// i2pBase64 is the base64 encoding used by I2P, which replaces the + and
+	// / characters of the standard encoding with - and ~.
+	i2pBase64 = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+		"abcdefghijklmnopqrstuvwxyz0123456789-~")
+
+	// i2pBase32 is the unpadded base32 encoding of I2P addresses.
+	i2pBase32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// I2PSAMError is returned when the SAM bridge answers a command with a result
+// other than OK.
+type I2PSAMError struct {
+	Result  string
+	Message string
+}
+
+// Error returns the error as a human-readable string and satisfies the error
+// interface.
+func (e *I2PSAMError) Error() string {
+	if e.Message == "" {
+		return "SAM bridge error " + e.Result
+	}
+	return fmt.Sprintf("SAM bridge error %s: %s", e.Result, e.Message)
+}
+
+// I2PAddr is the address of an I2P destination.  It implements the net.Addr
+// interface.  Streams through SAM version 3.1 have no notion of ports, so the
+// port is only kept so the address can be handled like any other address.
+type I2PAddr struct {
+	Host string
+	Port int
+}
+
+// Network returns "i2p".  It is part of the net.Addr interface.
+func (a *I2PAddr) Network() string {
+	return "i2p"
+}
+
+// String returns the address in the form <base32>.b32.i2p:port.  It is part of
+// the net.Addr interface.
+func (a *I2PAddr) String() string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(a.Port))
+}
+
+// i2pDestinationAddr returns the base32 address of the passed base64 encoded
+// destination.
+func i2pDestinationAddr(dest string) (string, error) {
+	raw, err := i2pBase64.DecodeString(dest)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(raw)
+	return strings.ToLower(i2pBase32.EncodeToString(hash[:])) + i2pSuffix, nil
+}
+
+// i2pConn is a stream to an I2P destination.  It reports the I2P addresses of
+// both ends of the stream.
+type i2pConn struct {
+	net.Conn
+	localAddr  *I2PAddr
+	remoteAddr *I2PAddr
+}
+
+// LocalAddr returns the I2P address of the local destination.
+func (c *i2pConn) LocalAddr() net.Addr {
+	return c.localAddr
+}
+
+// RemoteAddr returns the I2P address of the remote destination.
+func (c *i2pConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// readSAMLine reads a single line from the SAM bridge.  The line is read one
+// byte at a time since the stream data following the reply must not be
+// consumed.
+func readSAMLine(conn net.Conn) (string, error) {
+	var line []byte
+	var b [1]byte
+	for {
+		if _, err := io.ReadFull(conn, b[:]); err != nil {
+			return "", err
+		}
+		if b[0] == '\n' {
+			return strings.TrimSuffix(string(line), "\r"), nil
+		}
+		if len(line) >= i2pMaxLineLen {
+			return "", ErrI2PInvalidSAMResponse
+		}
+		line = append(line, b[0])
+	}
+}
+
+// samCommand sends the passed command to the SAM bridge and returns the
+// key=value pairs of its reply, which must start with the passed topic.
+// Replies with a result other than OK are returned as a *I2PSAMError.
+func samCommand(conn net.Conn, cmd, topic string) (map[string]string, error) {
+	conn.SetDeadline(time.Now().Add(i2pSAMTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := conn.Write([]byte(cmd + "\n")); err != nil {
+		return nil, err
+	}
+	line, err := readSAMLine(conn)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, topic+" ") {
+		return nil, ErrI2PInvalidSAMResponse
+	}
+	kvs, err := parseTorKeyValues(strings.TrimPrefix(line, topic+" "))
+	if err != nil {
+		return nil, ErrI2PInvalidSAMResponse
+	}
+	if result, ok := kvs["RESULT"]; ok && result != "OK" {
+		return nil, &I2PSAMError{Result: result, Message: kvs["MESSAGE"]}
+	}
+	return kvs, nil
+}
+
+// I2PSession manages a stream session with an I2P SAM bridge, which allows
+// connecting to I2P destinations and accepting connections from them.
+//
+// The session is created when it is first needed and is recreated after the
+// connection to the SAM bridge is lost.  The private key of the destination is
+// kept in a file so the same I2P address is used across restarts.
+type I2PSession struct {
+	samAddr string
+	keyPath string
+
+	// createMtx serializes the creation of the session, while mtx
+	// protects the fields below it.
+	createMtx sync.Mutex
+	mtx       sync.Mutex
+	id        string
+	control   net.Conn
+	localAddr *I2PAddr
+
+	quit      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewI2PSession returns a session with the SAM bridge at the passed address
+// which uses the destination whose private key is stored in keyPath.  A new
+// destination is generated and saved to keyPath when the file doesn't exist.
+func NewI2PSession(samAddr, keyPath string) *I2PSession {
+	return &I2PSession{
+		samAddr: samAddr,
+		keyPath: keyPath,
+		quit:    make(chan struct{}),
+	}
+}
+
+// helloSAM connects to the SAM bridge and negotiates the protocol version.
+func (s *I2PSession) helloSAM() (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", s.samAddr, i2pSAMTimeout)
+	if err != nil {
+		return nil, err
+	}
+	_, err = samCommand(conn, fmt.Sprintf("HELLO VERSION MIN=%s MAX=%s",
+		i2pSAMVersion, i2pSAMVersion), "HELLO REPLY")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// session returns the ID and the local address of the session, creating it
+// first when there is none.
+func (s *I2PSession) session() (string, *I2PAddr, error) {
+	s.createMtx.Lock()
+	defer s.createMtx.Unlock()
+
+	s.mtx.Lock()
+	id, control, localAddr := s.id, s.control, s.localAddr
+	s.mtx.Unlock()
+	if control != nil {
+		return id, localAddr, nil
+	}
+
+	privKey := "TRANSIENT"
+	if key, err := ioutil.ReadFile(s.keyPath); err == nil {
+		privKey = strings.TrimSpace(string(key))
+	} else if !os.IsNotExist(err) {
+		return "", nil, err
+	}
+
+	var rawID [5]byte
+	if _, err := rand.Read(rawID[:]); err != nil {
+		return "", nil, err
+	}
+	id = hex.EncodeToString(rawID[:])
+
+	conn, err := s.helloSAM()
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Track the connection so it is closed on shutdown while the session
+	// is still being created.
+	s.mtx.Lock()
+	select {
+	case <-s.quit:
+		s.mtx.Unlock()
+		conn.Close()
+		return "", nil, ErrI2PSessionClosed
+	default:
+	}
+	s.control = conn
+	s.mtx.Unlock()
+	fail := func(err error) (string, *I2PAddr, error) {
+		s.mtx.Lock()
+		if s.control == conn {
+			s.control = nil
+		}
+		s.mtx.Unlock()
+		conn.Close()
+		return "", nil, err
+	}
+
+	kvs, err := samCommand(conn, fmt.Sprintf("SESSION CREATE STYLE=STREAM "+
+		"ID=%s DESTINATION=%s SIGNATURE_TYPE=%d", id, privKey,
+		i2pSignatureType), "SESSION STATUS")
+	if err != nil {
+		return fail(err)
+	}
+	if privKey == "TRANSIENT" {
+		privKey = kvs["DESTINATION"]
+		if privKey == "" {
+			return fail(ErrI2PInvalidSAMResponse)
+		}
+		err := ioutil.WriteFile(s.keyPath, []byte(privKey), 0600)
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	// The public destination is looked up rather than derived from the
+	// private key since its length depends on the key type.
+	kvs, err = samCommand(conn, "NAMING LOOKUP NAME=ME", "NAMING REPLY")
+	if err != nil {
+		return fail(err)
+	}
+	host, err := i2pDestinationAddr(kvs["VALUE"])
+	if err != nil {
+		return fail(ErrI2PInvalidSAMResponse)
+	}
+	localAddr = &I2PAddr{Host: host}
+
+	s.mtx.Lock()
+	if s.control != conn {
+		s.mtx.Unlock()
+		return fail(ErrI2PSessionClosed)
+	}
+	s.id = id
+	s.localAddr = localAddr
+	s.mtx.Unlock()
+
+	// The session is closed by the SAM bridge when the control connection
+	// is closed, so forget about it once that happens.
+	go func() {
+		io.Copy(ioutil.Discard, conn)
+		s.mtx.Lock()
+		if s.control == conn {
+			s.control = nil
+		}
+		s.mtx.Unlock()
+		conn.Close()
+	}()
+
+	log.Debugf("Created I2P session %s for %s", id, host)
+	return id, localAddr, nil
+}
+
+// Addr returns the base32 address of the local destination, creating the
+// session first when there is none.
+func (s *I2PSession) Addr() (string, error) {
+	_, localAddr, err := s.session()
+	if err != nil {
+		return "", err
+	}
+	return localAddr.Host, nil
+}
+
+// Dial connects to the I2P destination at the passed address, which must be a
+// .b32.i2p host followed by a port.
+func (s *I2PSession) Dial(addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, err
+	}
+	host = strings.ToLower(host)
+	if !strings.HasSuffix(host, i2pSuffix) {
+		return nil, fmt.Errorf("%s is not an I2P address", host)
+	}
+
+	id, localAddr, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.helloSAM()
+	if err != nil {
+		return nil, err
+	}
+	kvs, err := samCommand(conn, "NAMING LOOKUP NAME="+host, "NAMING REPLY")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	_, err = samCommand(conn, fmt.Sprintf("STREAM CONNECT ID=%s "+
+		"DESTINATION=%s SILENT=false", id, kvs["VALUE"]), "STREAM STATUS")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &i2pConn{
+		Conn:       conn,
+		localAddr:  &I2PAddr{Host: localAddr.Host, Port: port},
+		remoteAddr: &I2PAddr{Host: host, Port: port},
+	}, nil
+}
+
+// accept waits for a single inbound stream and returns it.
+func (s *I2PSession) accept() (net.Conn, error) {
+	id, localAddr, err := s.session()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.helloSAM()
+	if err != nil {
+		return nil, err
+	}
+	_, err = samCommand(conn, fmt.Sprintf("STREAM ACCEPT ID=%s SILENT=false",
+		id), "STREAM STATUS")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The SAM bridge announces the destination of the peer once a stream
+	// has been accepted.  Close the connection on shutdown since the wait
+	// can't be interrupted otherwise.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.quit:
+			conn.Close()
+		case <-done:
+		}
+	}()
+	line, err := readSAMLine(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	dest := strings.SplitN(line, " ", 2)[0]
+	host, err := i2pDestinationAddr(dest)
+	if err != nil {
+		conn.Close()
+		return nil, ErrI2PInvalidSAMResponse
+	}
+
+	return &i2pConn{
+		Conn:       conn,
+		localAddr:  localAddr,
+		remoteAddr: &I2PAddr{Host: host},
+	}, nil
+}
+
+// Listen returns a listener which accepts inbound streams from other I2P
+// destinations.  The listener keeps trying to accept streams until it is
+// closed, which also closes the session.
+func (s *I2PSession) Listen() net.Listener {
+	return &i2pListener{session: s}
+}
+
+// Close closes the session and any listener returned by Listen.
+func (s *I2PSession) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.quit)
+	})
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.control == nil {
+		return nil
+	}
+	err := s.control.Close()
+	s.control = nil
+	return err
+}
+
+// i2pListener accepts inbound streams of an I2P session.  It implements the
+// net.Listener interface.
+type i2pListener struct {
+	session *I2PSession
+}
+
+// Accept waits for and returns the next inbound stream.  Failures of the SAM
+// bridge are retried until the listener is closed.  It is part of the
+// net.Listener interface.
+func (l *i2pListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.session.accept()
+		if err == nil {
+			return conn, nil
+		}
+
+		select {
+		case <-l.session.quit:
+			return nil, ErrI2PSessionClosed
+		default:
+		}
+		log.Debugf("Unable to accept I2P connection through SAM bridge "+
+			"%s: %v", l.session.samAddr, err)
+
+		select {
+		case <-time.After(i2pAcceptRetryInterval):
+		case <-l.session.quit:
+			return nil, ErrI2PSessionClosed
+		}
+	}
+}
+
+// Close closes the listener along with its session.  It is part of the
+// net.Listener interface.
+func (l *i2pListener) Close() error {
+	return l.session.Close()
+}
+
+// Addr returns the address of the local destination.  The address has an
+// empty host until the session has been created.  It is part of the
+// net.Listener interface.
+func (l *i2pListener) Addr() net.Addr {
+	l.session.mtx.Lock()
+	defer l.session.mtx.Unlock()
+	if l.session.localAddr == nil {
+		return &I2PAddr{}
+	}
+	return l.session.localAddr
+}
@@ -0,0 +1,303 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSAMBridge is a fake I2P SAM bridge.  It knows a single remote
+// destination which can be connected to and which connects to every accepting
+// stream session exactly once.
+type fakeSAMBridge struct {
+	listener net.Listener
+
+	// privKey is the private key returned for new destinations and
+	// localDest and remoteDest are the public destinations of the session
+	// and the remote peer.
+	privKey    string
+	localDest  string
+	remoteDest string
+
+	mtx      sync.Mutex
+	sessions []string
+	accepted bool
+}
+
+// newFakeSAMBridge starts a fake SAM bridge which accepts any number of
+// connections.
+func newFakeSAMBridge(t *testing.T) *fakeSAMBridge {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	f := &fakeSAMBridge{
+		listener:   listener,
+		privKey:    i2pBase64.EncodeToString(bytes.Repeat([]byte{0x01}, 663)),
+		localDest:  i2pBase64.EncodeToString(bytes.Repeat([]byte{0x02}, 391)),
+		remoteDest: i2pBase64.EncodeToString(bytes.Repeat([]byte{0x03}, 391)),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+// serve answers the commands received on a single connection.
+func (f *fakeSAMBridge) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) bool {
+		_, err := conn.Write([]byte(line + "\n"))
+		return err == nil
+	}
+	remoteHost, _ := i2pDestinationAddr(f.remoteDest)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSuffix(line, "\n")
+		switch {
+		case cmd == "HELLO VERSION MIN=3.1 MAX=3.1":
+			reply("HELLO REPLY RESULT=OK VERSION=3.1")
+
+		case strings.HasPrefix(cmd, "SESSION CREATE STYLE=STREAM "):
+			kvs, _ := parseTorKeyValues(cmd)
+			f.mtx.Lock()
+			f.sessions = append(f.sessions, kvs["DESTINATION"])
+			f.mtx.Unlock()
+			switch kvs["DESTINATION"] {
+			case "TRANSIENT":
+				reply("SESSION STATUS RESULT=OK DESTINATION=" +
+					f.privKey)
+			case f.privKey:
+				reply("SESSION STATUS RESULT=OK")
+			default:
+				reply("SESSION STATUS RESULT=INVALID_KEY")
+			}
+
+		case cmd == "NAMING LOOKUP NAME=ME":
+			reply("NAMING REPLY RESULT=OK NAME=ME VALUE=" +
+				f.localDest)
+
+		case cmd == "NAMING LOOKUP NAME="+remoteHost:
+			reply("NAMING REPLY RESULT=OK NAME=" + remoteHost +
+				" VALUE=" + f.remoteDest)
+
+		case strings.HasPrefix(cmd, "NAMING LOOKUP "):
+			reply("NAMING REPLY RESULT=KEY_NOT_FOUND")
+
+		case strings.HasPrefix(cmd, "STREAM CONNECT "):
+			kvs, _ := parseTorKeyValues(cmd)
+			if kvs["DESTINATION"] != f.remoteDest {
+				reply("STREAM STATUS RESULT=CANT_REACH_PEER " +
+					"MESSAGE=\"unknown destination\"")
+				continue
+			}
+			reply("STREAM STATUS RESULT=OK")
+
+			// Echo the stream back.
+			io.Copy(conn, reader)
+			return
+
+		case strings.HasPrefix(cmd, "STREAM ACCEPT "):
+			reply("STREAM STATUS RESULT=OK")
+
+			// Only the first accept is connected to, the others
+			// wait until the connection is closed.
+			f.mtx.Lock()
+			accepted := f.accepted
+			f.accepted = true
+			f.mtx.Unlock()
+			if accepted {
+				io.Copy(ioutil.Discard, reader)
+				return
+			}
+			reply(f.remoteDest + " FROM_PORT=0 TO_PORT=0")
+			conn.Write([]byte("ping"))
+			io.Copy(ioutil.Discard, reader)
+			return
+
+		default:
+			reply("UNKNOWN RESULT=I2P_ERROR")
+		}
+	}
+}
+
+func (f *fakeSAMBridge) Close() {
+	f.listener.Close()
+}
+
+// newSession returns a session with the fake SAM bridge which stores its
+// key in the passed directory.
+func (f *fakeSAMBridge) newSession(dir string) *I2PSession {
+	return NewI2PSession(f.listener.Addr().String(),
+		filepath.Join(dir, "i2p_private_key"))
+}
+
+// TestI2PSessionDial ensures a new destination is created and saved and that
+// streams to I2P destinations can be opened.
+func TestI2PSessionDial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i2p")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	f := newFakeSAMBridge(t)
+	defer f.Close()
+	session := f.newSession(dir)
+	defer session.Close()
+
+	localHost, _ := i2pDestinationAddr(f.localDest)
+	remoteHost, _ := i2pDestinationAddr(f.remoteDest)
+	if len(remoteHost) != 60 {
+		t.Fatalf("unexpected I2P address %s", remoteHost)
+	}
+
+	host, err := session.Addr()
+	if err != nil {
+		t.Fatalf("unable to create session: %v", err)
+	}
+	if host != localHost {
+		t.Fatalf("got local address %s, want %s", host, localHost)
+	}
+	key, err := ioutil.ReadFile(filepath.Join(dir, "i2p_private_key"))
+	if err != nil || string(key) != f.privKey {
+		t.Fatalf("private key was not saved: %v", err)
+	}
+
+	conn, err := session.Dial(strings.ToUpper(remoteHost) + ":9333")
+	if err != nil {
+		t.Fatalf("unable to dial: %v", err)
+	}
+	defer conn.Close()
+	if got := conn.RemoteAddr().String(); got != remoteHost+":9333" {
+		t.Fatalf("got remote address %s, want %s", got,
+			remoteHost+":9333")
+	}
+	if got := conn.LocalAddr().String(); got != localHost+":9333" {
+		t.Fatalf("got local address %s, want %s", got,
+			localHost+":9333")
+	}
+	if _, err := conn.Write([]byte("version")); err != nil {
+		t.Fatalf("unable to write to stream: %v", err)
+	}
+	buf := make([]byte, len("version"))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("unable to read from stream: %v", err)
+	}
+	if string(buf) != "version" {
+		t.Fatalf("got %q from stream, want %q", buf, "version")
+	}
+
+	unknown := strings.Repeat("a", 52) + ".b32.i2p:9333"
+	_, err = session.Dial(unknown)
+	samErr, ok := err.(*I2PSAMError)
+	if !ok || samErr.Result != "KEY_NOT_FOUND" {
+		t.Fatalf("got error %v, want KEY_NOT_FOUND", err)
+	}
+
+	f.mtx.Lock()
+	sessions := len(f.sessions)
+	f.mtx.Unlock()
+	if sessions != 1 {
+		t.Fatalf("created %d sessions, want 1", sessions)
+	}
+}
+
+// TestI2PSessionPersistentKey ensures a saved private key is reused.
+func TestI2PSessionPersistentKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i2p")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	f := newFakeSAMBridge(t)
+	defer f.Close()
+
+	keyPath := filepath.Join(dir, "i2p_private_key")
+	if err := ioutil.WriteFile(keyPath, []byte(f.privKey+"\n"), 0600); err != nil {
+		t.Fatalf("unable to write key: %v", err)
+	}
+	session := f.newSession(dir)
+	defer session.Close()
+	if _, err := session.Addr(); err != nil {
+		t.Fatalf("unable to create session: %v", err)
+	}
+
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	if len(f.sessions) != 1 || f.sessions[0] != f.privKey {
+		t.Fatalf("session was not created with the saved key")
+	}
+}
+
+// TestI2PListener ensures inbound streams are accepted and that closing the
+// listener stops it.
+func TestI2PListener(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i2p")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	f := newFakeSAMBridge(t)
+	defer f.Close()
+	listener := f.newSession(dir).Listen()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("unable to accept: %v", err)
+	}
+	defer conn.Close()
+	remoteHost, _ := i2pDestinationAddr(f.remoteDest)
+	if got := conn.RemoteAddr().(*I2PAddr).Host; got != remoteHost {
+		t.Fatalf("got remote address %s, want %s", got, remoteHost)
+	}
+	localHost, _ := i2pDestinationAddr(f.localDest)
+	if got := listener.Addr().(*I2PAddr).Host; got != localHost {
+		t.Fatalf("got listener address %s, want %s", got, localHost)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("unexpected stream data %q: %v", buf, err)
+	}
+
+	// The next accept blocks until the listener is closed.
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := listener.Accept()
+		errChan <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	listener.Close()
+	select {
+	case err := <-errChan:
+		if err != ErrI2PSessionClosed {
+			t.Fatalf("got error %v, want %v", err, ErrI2PSessionClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("accept did not return after closing the listener")
+	}
+}
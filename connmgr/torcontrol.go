@@ -0,0 +1,383 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// torControlTimeout is the maximum time to wait for the Tor control
+	// port to answer a command.
+	torControlTimeout = time.Minute
+
+	// torCookieLen is the length of the Tor authentication cookie.
+	torCookieLen = 32
+
+	// torSafeCookieServerKey and torSafeCookieClientKey are the HMAC keys
+	// used by the SAFECOOKIE authentication method.
+	torSafeCookieServerKey = "Tor safe cookie authentication server-to-controller hash"
+	torSafeCookieClientKey = "Tor safe cookie authentication controller-to-server hash"
+)
+
+var (
+	// ErrTorInvalidControlResponse indicates the Tor control port returned a
+	// response in an unexpected format.
+	ErrTorInvalidControlResponse = errors.New("invalid control port response")
+
+	// ErrTorNoAuthMethod indicates none of the authentication methods
+	// supported by the Tor control port can be used.
+	ErrTorNoAuthMethod = errors.New("no supported control port " +
+		"authentication method")
+
+	// ErrTorServerHashMismatch indicates the Tor control port failed to
+	// prove it knows the authentication cookie during SAFECOOKIE
+	// authentication.
+	ErrTorServerHashMismatch = errors.New("control port server hash " +
+		"mismatch")
+)
+
+// TorControlError is returned when the Tor control port answers a command
+// with an error status.
+type TorControlError struct {
+	Code    int
+	Message string
+}
+
+// Error returns the error as a human-readable string and satisfies the error
+// interface.
+func (e *TorControlError) Error() string {
+	return fmt.Sprintf("tor control error %d: %s", e.Code, e.Message)
+}
+
+// torReply is a reply of the Tor control port to a command.
+type torReply struct {
+	code  int
+	lines []string
+}
+
+// TorController is a client for the Tor control protocol.  It supports
+// authenticating to the control port and managing ephemeral onion services.
+// Onion services created without the Detach flag are removed by Tor when the
+// controller is closed.
+type TorController struct {
+	conn   net.Conn
+	reader *textproto.Reader
+}
+
+// DialTorController connects to the Tor control port at the passed address.
+// The returned controller must be authenticated before issuing any other
+// commands.
+func DialTorController(addr string) (*TorController, error) {
+	conn, err := net.DialTimeout("tcp", addr, torControlTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &TorController{
+		conn:   conn,
+		reader: textproto.NewReader(bufio.NewReader(conn)),
+	}, nil
+}
+
+// Close closes the connection to the control port.
+func (c *TorController) Close() error {
+	return c.conn.Close()
+}
+
+// sendCommand sends the passed command to the control port and returns its
+// reply.  Error replies are returned as a *TorControlError.
+func (c *TorController) sendCommand(cmd string) (*torReply, error) {
+	c.conn.SetDeadline(time.Now().Add(torControlTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if _, err := c.conn.Write([]byte(cmd + "\r\n")); err != nil {
+		return nil, err
+	}
+
+	reply := &torReply{}
+	for {
+		line, err := c.reader.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) < 4 {
+			return nil, ErrTorInvalidControlResponse
+		}
+		code, err := strconv.Atoi(line[:3])
+		if err != nil {
+			return nil, ErrTorInvalidControlResponse
+		}
+		if reply.code != 0 && code != reply.code {
+			return nil, ErrTorInvalidControlResponse
+		}
+		reply.code = code
+
+		switch line[3] {
+		case ' ':
+			reply.lines = append(reply.lines, line[4:])
+			if code/100 != 2 {
+				return nil, &TorControlError{
+					Code:    code,
+					Message: strings.Join(reply.lines, " "),
+				}
+			}
+			return reply, nil
+
+		case '-':
+			reply.lines = append(reply.lines, line[4:])
+
+		case '+':
+			// Data replies continue until a line containing a
+			// single period and are folded into a single line.
+			data, err := c.reader.ReadDotLines()
+			if err != nil {
+				return nil, err
+			}
+			reply.lines = append(reply.lines, line[4:]+
+				strings.Join(data, "\n"))
+
+		default:
+			return nil, ErrTorInvalidControlResponse
+		}
+	}
+}
+
+// parseTorKeyValues parses the space separated key=value pairs of a reply
+// line.  Values may be quoted strings with backslash escapes.  Tokens without a
+// value are returned with an empty value.
+func parseTorKeyValues(line string) (map[string]string, error) {
+	kvs := make(map[string]string)
+	for len(line) > 0 {
+		line = strings.TrimLeft(line, " ")
+		if len(line) == 0 {
+			break
+		}
+		end := strings.IndexAny(line, "= ")
+		if end == -1 {
+			kvs[line] = ""
+			break
+		}
+		key := line[:end]
+		if line[end] == ' ' {
+			kvs[key] = ""
+			line = line[end:]
+			continue
+		}
+		line = line[end+1:]
+
+		if !strings.HasPrefix(line, "\"") {
+			end := strings.IndexByte(line, ' ')
+			if end == -1 {
+				end = len(line)
+			}
+			kvs[key] = line[:end]
+			line = line[end:]
+			continue
+		}
+
+		var value bytes.Buffer
+		i := 1
+		for ; i < len(line) && line[i] != '"'; i++ {
+			if line[i] == '\\' {
+				i++
+				if i == len(line) {
+					return nil, ErrTorInvalidControlResponse
+				}
+			}
+			value.WriteByte(line[i])
+		}
+		if i == len(line) {
+			return nil, ErrTorInvalidControlResponse
+		}
+		kvs[key] = value.String()
+		line = line[i+1:]
+	}
+	return kvs, nil
+}
+
+// quoteTorString returns s as a quoted string suitable for the control
+// protocol.
+func quoteTorString(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "\"", "\\\"", -1)
+	return "\"" + s + "\""
+}
+
+// Authenticate authenticates to the control port using the best method it
+// supports.  Password authentication is used when a password is passed,
+// otherwise cookie authentication is preferred.  Control ports that do not
+// require authentication are accepted as well.
+func (c *TorController) Authenticate(password string) error {
+	reply, err := c.sendCommand("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+
+	methods := make(map[string]bool)
+	var cookieFile string
+	for _, line := range reply.lines {
+		if !strings.HasPrefix(line, "AUTH ") {
+			continue
+		}
+		kvs, err := parseTorKeyValues(strings.TrimPrefix(line, "AUTH "))
+		if err != nil {
+			return err
+		}
+		for _, method := range strings.Split(kvs["METHODS"], ",") {
+			methods[method] = true
+		}
+		cookieFile = kvs["COOKIEFILE"]
+	}
+
+	switch {
+	case methods["HASHEDPASSWORD"] && password != "":
+		_, err := c.sendCommand("AUTHENTICATE " + quoteTorString(password))
+		return err
+
+	case methods["SAFECOOKIE"] && cookieFile != "":
+		return c.authenticateSafeCookie(cookieFile)
+
+	case methods["COOKIE"] && cookieFile != "":
+		cookie, err := readTorCookie(cookieFile)
+		if err != nil {
+			return err
+		}
+		_, err = c.sendCommand("AUTHENTICATE " + hex.EncodeToString(cookie))
+		return err
+
+	case methods["NULL"]:
+		_, err := c.sendCommand("AUTHENTICATE")
+		return err
+	}
+
+	return ErrTorNoAuthMethod
+}
+
+// readTorCookie reads the authentication cookie from the passed file.
+func readTorCookie(path string) ([]byte, error) {
+	cookie, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(cookie) != torCookieLen {
+		return nil, fmt.Errorf("authentication cookie %s has invalid "+
+			"length %d", path, len(cookie))
+	}
+	return cookie, nil
+}
+
+// torSafeCookieHMAC returns the SAFECOOKIE HMAC of the cookie and nonces with
+// the passed key.
+func torSafeCookieHMAC(key string, cookie, clientNonce, serverNonce []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(cookie)
+	mac.Write(clientNonce)
+	mac.Write(serverNonce)
+	return mac.Sum(nil)
+}
+
+// authenticateSafeCookie authenticates using the SAFECOOKIE method, which
+// proves knowledge of the cookie without revealing it to the control port.
+func (c *TorController) authenticateSafeCookie(cookieFile string) error {
+	cookie, err := readTorCookie(cookieFile)
+	if err != nil {
+		return err
+	}
+	clientNonce := make([]byte, 32)
+	if _, err := rand.Read(clientNonce); err != nil {
+		return err
+	}
+
+	reply, err := c.sendCommand("AUTHCHALLENGE SAFECOOKIE " +
+		hex.EncodeToString(clientNonce))
+	if err != nil {
+		return err
+	}
+	line := strings.TrimPrefix(reply.lines[0], "AUTHCHALLENGE ")
+	kvs, err := parseTorKeyValues(line)
+	if err != nil {
+		return err
+	}
+	serverHash, err := hex.DecodeString(kvs["SERVERHASH"])
+	if err != nil {
+		return ErrTorInvalidControlResponse
+	}
+	serverNonce, err := hex.DecodeString(kvs["SERVERNONCE"])
+	if err != nil {
+		return ErrTorInvalidControlResponse
+	}
+
+	expected := torSafeCookieHMAC(torSafeCookieServerKey, cookie,
+		clientNonce, serverNonce)
+	if !hmac.Equal(serverHash, expected) {
+		return ErrTorServerHashMismatch
+	}
+
+	clientHash := torSafeCookieHMAC(torSafeCookieClientKey, cookie,
+		clientNonce, serverNonce)
+	_, err = c.sendCommand("AUTHENTICATE " + hex.EncodeToString(clientHash))
+	return err
+}
+
+// AddOnion creates an ephemeral onion service which forwards connections to
+// the passed virtual port to target.  The private key is either a key returned
+// by a previous call in the form "<type>:<blob>" or "NEW:<type>" to have Tor
+// generate a new key of the given type.  The service ID, which is the onion
+// address without the .onion suffix, is returned along with the private key of
+// the service.
+func (c *TorController) AddOnion(privateKey string, virtPort int,
+	target string) (string, string, error) {
+
+	reply, err := c.sendCommand(fmt.Sprintf("ADD_ONION %s Port=%d,%s",
+		privateKey, virtPort, target))
+	if err != nil {
+		return "", "", err
+	}
+
+	var serviceID string
+	for _, line := range reply.lines {
+		switch {
+		case strings.HasPrefix(line, "ServiceID="):
+			serviceID = strings.TrimPrefix(line, "ServiceID=")
+		case strings.HasPrefix(line, "PrivateKey="):
+			privateKey = strings.TrimPrefix(line, "PrivateKey=")
+		}
+	}
+	if serviceID == "" || strings.HasPrefix(privateKey, "NEW:") {
+		return "", "", ErrTorInvalidControlResponse
+	}
+	return serviceID, privateKey, nil
+}
+
+// DelOnion removes the onion service with the passed service ID.
+func (c *TorController) DelOnion(serviceID string) error {
+	_, err := c.sendCommand("DEL_ONION " + serviceID)
+	return err
+}
+
+// WaitClose blocks until the connection to the control port is lost or the
+// controller is closed and returns the error that ended it.  Asynchronous
+// event notifications received in the meantime are discarded.  It must not be
+// called concurrently with any other command.
+func (c *TorController) WaitClose() error {
+	for {
+		if _, err := c.reader.ReadLine(); err != nil {
+			return err
+		}
+	}
+}
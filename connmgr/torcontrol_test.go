@@ -0,0 +1,291 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeTorControl is a fake Tor control port which answers each command with
+// the reply returned by its handler.
+type fakeTorControl struct {
+	listener net.Listener
+	handler  func(cmd string) string
+}
+
+// newFakeTorControl starts a fake Tor control port which accepts a single
+// connection.
+func newFakeTorControl(t *testing.T, handler func(cmd string) string) *fakeTorControl {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	f := &fakeTorControl{
+		listener: listener,
+		handler:  handler,
+	}
+	go f.serve()
+	return f
+}
+
+func (f *fakeTorControl) serve() {
+	conn, err := f.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		cmd := strings.TrimSuffix(scanner.Text(), "\r")
+		if _, err := conn.Write([]byte(f.handler(cmd))); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeTorControl) Close() {
+	f.listener.Close()
+}
+
+// dial connects a controller to the fake control port.
+func (f *fakeTorControl) dial(t *testing.T) *TorController {
+	t.Helper()
+
+	ctrl, err := DialTorController(f.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unable to dial control port: %v", err)
+	}
+	return ctrl
+}
+
+// protocolInfo returns a PROTOCOLINFO reply advertising the passed methods.
+func protocolInfo(methods, cookieFile string) string {
+	auth := "250-AUTH METHODS=" + methods
+	if cookieFile != "" {
+		auth += " COOKIEFILE=\"" + cookieFile + "\""
+	}
+	return "250-PROTOCOLINFO 1\r\n" + auth + "\r\n" +
+		"250-VERSION Tor=\"0.3.5.8\"\r\n250 OK\r\n"
+}
+
+// writeCookie writes a test authentication cookie to a temporary directory and
+// returns the cookie and the path of the file.
+func writeCookie(t *testing.T, dir string) ([]byte, string) {
+	t.Helper()
+
+	cookie := bytes.Repeat([]byte{0x5a}, torCookieLen)
+	path := filepath.Join(dir, "control_auth_cookie")
+	if err := ioutil.WriteFile(path, cookie, 0600); err != nil {
+		t.Fatalf("unable to write cookie: %v", err)
+	}
+	return cookie, path
+}
+
+// TestTorControlAuthenticate ensures the controller authenticates using each of
+// the supported methods.
+func TestTorControlAuthenticate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torcontrol")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cookie, cookieFile := writeCookie(t, dir)
+
+	tests := []struct {
+		name     string
+		methods  string
+		password string
+		wantAuth string
+	}{
+		{
+			name:     "cookie",
+			methods:  "COOKIE",
+			wantAuth: "AUTHENTICATE " + hex.EncodeToString(cookie),
+		},
+		{
+			name:     "password",
+			methods:  "COOKIE,HASHEDPASSWORD",
+			password: `pass "word"\`,
+			wantAuth: `AUTHENTICATE "pass \"word\"\\"`,
+		},
+		{
+			name:     "null",
+			methods:  "NULL",
+			wantAuth: "AUTHENTICATE",
+		},
+	}
+	for _, test := range tests {
+		f := newFakeTorControl(t, func(cmd string) string {
+			if cmd == "PROTOCOLINFO 1" {
+				return protocolInfo(test.methods, cookieFile)
+			}
+			if cmd == test.wantAuth {
+				return "250 OK\r\n"
+			}
+			return "515 Authentication failed\r\n"
+		})
+		ctrl := f.dial(t)
+		if err := ctrl.Authenticate(test.password); err != nil {
+			t.Errorf("%s: unable to authenticate: %v", test.name, err)
+		}
+		ctrl.Close()
+		f.Close()
+	}
+}
+
+// TestTorControlSafeCookie ensures the controller performs SAFECOOKIE
+// authentication and rejects control ports that don't know the cookie.
+func TestTorControlSafeCookie(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torcontrol")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	cookie, cookieFile := writeCookie(t, dir)
+	serverNonce := bytes.Repeat([]byte{0x01}, 32)
+
+	for _, knowsCookie := range []bool{true, false} {
+		var clientHash []byte
+		f := newFakeTorControl(t, func(cmd string) string {
+			switch {
+			case cmd == "PROTOCOLINFO 1":
+				return protocolInfo("COOKIE,SAFECOOKIE", cookieFile)
+
+			case strings.HasPrefix(cmd, "AUTHCHALLENGE SAFECOOKIE "):
+				clientNonce, err := hex.DecodeString(
+					strings.TrimPrefix(cmd, "AUTHCHALLENGE SAFECOOKIE "))
+				if err != nil {
+					return "513 Invalid nonce\r\n"
+				}
+				serverCookie := cookie
+				if !knowsCookie {
+					serverCookie = make([]byte, torCookieLen)
+				}
+				serverHash := torSafeCookieHMAC(torSafeCookieServerKey,
+					serverCookie, clientNonce, serverNonce)
+				clientHash = torSafeCookieHMAC(torSafeCookieClientKey,
+					cookie, clientNonce, serverNonce)
+				return "250 AUTHCHALLENGE SERVERHASH=" +
+					hex.EncodeToString(serverHash) + " SERVERNONCE=" +
+					hex.EncodeToString(serverNonce) + "\r\n"
+
+			case cmd == "AUTHENTICATE "+hex.EncodeToString(clientHash):
+				return "250 OK\r\n"
+			}
+			return "515 Authentication failed\r\n"
+		})
+
+		ctrl := f.dial(t)
+		err := ctrl.Authenticate("")
+		switch {
+		case knowsCookie && err != nil:
+			t.Errorf("unable to authenticate: %v", err)
+		case !knowsCookie && err != ErrTorServerHashMismatch:
+			t.Errorf("got error %v for impostor control port, want %v",
+				err, ErrTorServerHashMismatch)
+		}
+		ctrl.Close()
+		f.Close()
+	}
+}
+
+// TestTorControlNoAuthMethod ensures authentication fails when none of the
+// methods offered by the control port can be used.
+func TestTorControlNoAuthMethod(t *testing.T) {
+	f := newFakeTorControl(t, func(cmd string) string {
+		return protocolInfo("HASHEDPASSWORD", "")
+	})
+	defer f.Close()
+	ctrl := f.dial(t)
+	defer ctrl.Close()
+
+	if err := ctrl.Authenticate(""); err != ErrTorNoAuthMethod {
+		t.Fatalf("got error %v, want %v", err, ErrTorNoAuthMethod)
+	}
+}
+
+// TestTorControlAddOnion ensures onion services are created with new and
+// existing keys and that errors are reported.
+func TestTorControlAddOnion(t *testing.T) {
+	const (
+		serviceID = "expyuzz4wqqyqhjn"
+		newKey    = "RSA1024:MIICXAIBAAKBgQC"
+	)
+	f := newFakeTorControl(t, func(cmd string) string {
+		switch cmd {
+		case "ADD_ONION NEW:RSA1024 Port=35677,127.0.0.1:35677":
+			return "250-ServiceID=" + serviceID + "\r\n" +
+				"250-PrivateKey=" + newKey + "\r\n250 OK\r\n"
+		case "ADD_ONION " + newKey + " Port=35677,127.0.0.1:35677":
+			return "250-ServiceID=" + serviceID + "\r\n250 OK\r\n"
+		}
+		return "512 Invalid key type\r\n"
+	})
+	defer f.Close()
+	ctrl := f.dial(t)
+	defer ctrl.Close()
+
+	id, key, err := ctrl.AddOnion("NEW:RSA1024", 35677, "127.0.0.1:35677")
+	if err != nil {
+		t.Fatalf("unable to add onion service: %v", err)
+	}
+	if id != serviceID || key != newKey {
+		t.Fatalf("got service %s with key %s, want %s with key %s", id,
+			key, serviceID, newKey)
+	}
+
+	id, key, err = ctrl.AddOnion(newKey, 35677, "127.0.0.1:35677")
+	if err != nil {
+		t.Fatalf("unable to add onion service: %v", err)
+	}
+	if id != serviceID || key != newKey {
+		t.Fatalf("got service %s with key %s, want %s with key %s", id,
+			key, serviceID, newKey)
+	}
+
+	_, _, err = ctrl.AddOnion("NEW:ED25519-V3", 35677, "127.0.0.1:35677")
+	ctrlErr, ok := err.(*TorControlError)
+	if !ok || ctrlErr.Code != 512 {
+		t.Fatalf("got error %v, want control error 512", err)
+	}
+}
+
+// TestParseTorKeyValues ensures reply lines are split into their keys and
+// values.
+func TestParseTorKeyValues(t *testing.T) {
+	kvs, err := parseTorKeyValues(`METHODS=COOKIE,SAFECOOKIE ` +
+		`COOKIEFILE="/var/lib/tor/a \"b\"\\c" FLAG`)
+	if err != nil {
+		t.Fatalf("unable to parse: %v", err)
+	}
+	want := map[string]string{
+		"METHODS":    "COOKIE,SAFECOOKIE",
+		"COOKIEFILE": `/var/lib/tor/a "b"\c`,
+		"FLAG":       "",
+	}
+	if len(kvs) != len(want) {
+		t.Fatalf("got %d pairs, want %d", len(kvs), len(want))
+	}
+	for k, v := range want {
+		if kvs[k] != v {
+			t.Errorf("got %s=%q, want %q", k, kvs[k], v)
+		}
+	}
+
+	if _, err := parseTorKeyValues(`COOKIEFILE="unterminated`); err == nil {
+		t.Error("unterminated quoted string was accepted")
+	}
+}
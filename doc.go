@@ -27,6 +27,9 @@ Application Options:
       --addrindex             Maintain a full address-based transaction index
                               which makes the searchrawtransactions RPC
                               available
+      --asmap=                Path to an asmap file used to group peers by the
+                              autonomous system announcing their address
+                              instead of by network prefix
       --banduration=          How long to ban misbehaving peers.  Valid time
                               units are {s, m, h}.  Minimum 1 second (default:
                               24h0m0s)
@@ -44,6 +47,12 @@ Application Options:
                               transactions when creating a block (default:
                               50000)
       --blocksonly            Do not accept transactions from remote peers.
+      --blockrelayoutbound=   Number of block-relay-only outbound peers to
+                              maintain in addition to the full relay outbound
+                              peers (default: 2)
+      --coinbaseflags=        Message and pool tag to add to the coinbase
+                              script of generated blocks instead of the default
+                              /P2SH/eacd/
   -C, --configfile=           Path to configuration file
       --connect=              Connect only to the specified peers at startup
       --cpuprofile=           Write CPU profile to the specified file
@@ -67,23 +76,55 @@ Application Options:
       --externalip=           Add an ip to the list of local addresses we claim
                               to listen on to peers
       --generate              Generate (mine) earthcoins using the CPU
+      --i2pacceptincoming     Accept inbound connections from I2P peers through
+                              the SAM bridge specified by --i2psam
+      --i2psam=               Connect to I2P peers through the given I2P SAM
+                              bridge (eg. 127.0.0.1:7656)
+      --limitancestorcount=   Do not accept transactions with more in-mempool
+                              ancestors (0 to disable) (default: 25)
+      --limitancestorsize=    Do not accept transactions whose size in
+                              kilobytes with all in-mempool ancestors exceeds
+                              this value (0 to disable) (default: 101)
+      --limitdescendantcount= Do not accept transactions if any ancestor would
+                              have more in-mempool descendants (0 to disable)
+                              (default: 25)
+      --limitdescendantsize=  Do not accept transactions if any ancestor would
+                              have more than this many kilobytes of in-mempool
+                              descendants (0 to disable) (default: 101)
       --limitfreerelay=       Limit relay of transactions with no transaction
                               fee to the given amount in thousands of bytes per
                               minute (default: 15)
       --listen=               Add an interface/port to listen for connections
                               (default all interfaces port: 8333, testnet:
                               18333)
+      --listenonion           Automatically create a Tor onion service through
+                              the Tor control port to accept inbound
+                              connections
       --logdir=               Directory to log output
+      --maxmempool=           Max size in megabytes of the transactions in the
+                              memory pool -- The cheapest transactions are
+                              evicted when it is exceeded (default: 300)
       --maxorphantx=          Max number of orphan transactions to keep in
                               memory (default: 100)
       --maxpeers=             Max number of inbound and outbound peers
                               (default: 125)
+      --mempoolexpiry=        Do not keep transactions in the memory pool longer
+                              than this many hours (0 to disable) (default:
+                              336)
+      --mempoolfullrbf        Accept transactions replacing transactions in the
+                              memory pool even when those do not signal
+                              replaceability through the Replace-By-Fee (RBF)
+                              signaling policy
       --miningaddr=           Add the specified payment address to the list of
-                              addresses to use for generated blocks -- At least
-                              one address is required if the generate option is
-                              set
+                              addresses to use for generated blocks -- Use
+                              <addr>:<weight>,<addr>:<weight>,... to split the
+                              reward of a block among several addresses in
+                              proportion to their weights -- At least one
+                              address is required if the generate option is set
       --minrelaytxfee=        The minimum transaction fee in EAC/kB to be
                               considered a non-zero fee. (default: 1e-05)
+      --natpmp                Use NAT-PMP or PCP to map our listening port
+                              outside of NAT
       --nobanning             Disable banning of misbehaving peers
       --nocfilters            Disable committed filtering (CF) support
       --nocheckpoints         Disable built-in checkpoints.  Don't do this
@@ -108,6 +149,11 @@ Application Options:
                               (eg. 127.0.0.1:9050)
       --onionpass=            Password for onion proxy server
       --onionuser=            Username for onion proxy server
+      --persistmempool        Save the memory pool to mempool.dat in the data
+                              directory on shutdown and load it on startup
+      --portmap               Map our listening port outside of NAT using
+                              whichever of PCP, NAT-PMP and UPnP the router
+                              supports
       --profile=              Enable HTTP profiling on given port -- NOTE port
                               must be between 1024 and 65536
       --proxy=                Connect via SOCKS5 proxy (eg. 127.0.0.1:9050)
@@ -118,6 +164,10 @@ Application Options:
                               the default settings for the active network.
       --relaynonstd           Relay non-standard transactions regardless of the
                               default settings for the active network.
+      --rest                  Serve the unauthenticated read-only REST
+                              interface under /rest/ on the RPC listeners
+      --restmaxclients=       Max number of REST clients, which are limited
+                              separately from RPC clients (default: 10)
       --rpccert=              File containing the certificate file
       --rpckey=               File containing the certificate key
       --rpclimitpass=         Password for limited RPC connections
@@ -138,9 +188,23 @@ Application Options:
       --sigcachemaxsize=      The maximum number of entries in the signature
                               verification cache (default: 100000)
       --simnet                Use the simulation test network
+      --stratumdifficulty=    Initial share difficulty of Stratum connections
+                              before it is adjusted to their hash rate
+                              (default: 65536)
+      --stratumlisten=        Add an interface/port to listen for Stratum miner
+                              connections (default port: 3333) -- The Stratum
+                              server is disabled unless at least one is
+                              specified
+      --stratummaxclients=    Max number of Stratum miner connections
+                              (default: 100)
       --testnet               Use the test network
+      --torcontrol=           Tor control port used to create the onion service
+                              when --listenonion is set (default:
+                              127.0.0.1:9051)
       --torisolation          Enable Tor stream isolation by randomizing user
                               credentials for each connection.
+      --torpassword=          Password for the Tor control port -- Cookie
+                              authentication is used when not specified
       --trickleinterval=      Minimum time between attempts to send new
                               inventory to a connected peer (default: 10s)
       --txindex               Maintain a full hash-based transaction index
@@ -152,6 +216,19 @@ Application Options:
   -V, --version               Display version information and exit
       --whitelist=            Add an IP network or IP that will not be banned.
                               (eg. 192.168.1.0/24 or ::1)
+      --zmqpubhashblock=      Publish the hashes of connected blocks on the
+                              given ZeroMQ endpoint (eg. tcp://127.0.0.1:28332)
+      --zmqpubhashtx=         Publish the hashes of transactions accepted into
+                              the mempool or included in connected and
+                              disconnected blocks on the given ZeroMQ endpoint
+      --zmqpubrawblock=       Publish connected blocks on the given ZeroMQ
+                              endpoint
+      --zmqpubrawtx=          Publish transactions accepted into the mempool or
+                              included in connected and disconnected blocks on
+                              the given ZeroMQ endpoint
+      --zmqpubsequence=       Publish block connections and disconnections and
+                              mempool additions and removals on the given
+                              ZeroMQ endpoint
 
 Help Options:
   -h, --help           Show this help message
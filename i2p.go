@@ -0,0 +1,83 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/eacsuite/eacd/addrmgr"
+)
+
+const (
+	// i2pKeyFilename is the name of the file in the data directory used to
+	// persist the private key of the I2P destination so the same I2P
+	// address is used across restarts.
+	i2pKeyFilename = "i2p_private_key"
+
+	// i2pRetryInterval is the time to wait before trying to create the I2P
+	// session again after the SAM bridge failed.
+	i2pRetryInterval = time.Minute
+)
+
+// publishI2PAddress creates the I2P session and advertises its address.  It
+// keeps retrying until it succeeds or the server is shutting down.
+func (s *server) publishI2PAddress() {
+	port, err := strconv.ParseUint(activeNetParams.DefaultPort, 10, 16)
+	if err != nil {
+		srvrLog.Errorf("Invalid default port %s: %v",
+			activeNetParams.DefaultPort, err)
+		return
+	}
+
+	for {
+		host, err := cfg.i2pSession.Addr()
+		if err == nil {
+			srvrLog.Infof("Accepting I2P connections at %s", host)
+			na, err := s.addrManager.HostToNetAddress(host,
+				uint16(port), s.services)
+			if err != nil {
+				srvrLog.Warnf("I2P address %s can not be "+
+					"advertised to peers: %v", host, err)
+				return
+			}
+			err = s.addrManager.AddLocalAddress(na, addrmgr.ManualPrio)
+			if err != nil {
+				srvrLog.Warnf("Skipping I2P address %s: %v", host,
+					err)
+			}
+			return
+		}
+		srvrLog.Warnf("Unable to create I2P session through SAM bridge "+
+			"%s: %v", cfg.I2PSAM, err)
+
+		select {
+		case <-time.After(i2pRetryInterval):
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// i2pSessionThread advertises the I2P address when inbound I2P connections are
+// accepted and closes the I2P session on shutdown.  It must be run as a
+// goroutine.
+func (s *server) i2pSessionThread() {
+	defer s.wg.Done()
+
+	published := make(chan struct{})
+	go func() {
+		defer close(published)
+		if cfg.I2PAcceptIncoming {
+			s.publishI2PAddress()
+		}
+	}()
+
+	// Closing the session also aborts creating it, so it is done before
+	// waiting for the address to be published.
+	<-s.quit
+	cfg.i2pSession.Close()
+	<-published
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is ignored during the regular tests due to the following build tag.
+// +build rpctest
+
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/integration/rpctest"
+)
+
+// assertBlockTime generates a block with the harness and ensures its timestamp
+// is the expected one.
+func assertBlockTime(r *rpctest.Harness, t *testing.T, want time.Time) {
+	blockHashes, err := r.Node.Generate(1)
+	if err != nil {
+		t.Fatalf("unable to generate block: %v", err)
+	}
+	header, err := r.Node.GetBlockHeader(blockHashes[0])
+	if err != nil {
+		t.Fatalf("unable to get block header: %v", err)
+	}
+	if !header.Timestamp.Equal(want) {
+		t.Fatalf("block timestamp incorrect: got %v, want %v",
+			header.Timestamp, want)
+	}
+}
+
+// TestMockTime ensures the time used by a regtest node to generate blocks can
+// be set and advanced with the setmocktime RPC and that other networks reject
+// it.
+func TestMockTime(t *testing.T) {
+	t.Parallel()
+
+	// The primary harness runs on simnet, which doesn't support faking
+	// the time.
+	if err := primaryHarness.SetMockTime(time.Now()); err == nil {
+		t.Fatalf("setmocktime succeeded on simnet")
+	}
+
+	r, err := rpctest.New(&chaincfg.RegressionNetParams, nil, nil)
+	if err != nil {
+		t.Fatalf("unable to create harness: %v", err)
+	}
+	if err := r.SetUp(false, 0); err != nil {
+		t.Fatalf("unable to setup test chain: %v", err)
+	}
+	defer r.TearDown()
+
+	// Blocks are generated with the mock time once it is set.  The mock
+	// time is kept close enough to the system time for the blocks to still
+	// be valid once the system time is used again.
+	mockTime, err := r.AdvanceMockTime(time.Hour)
+	if err != nil {
+		t.Fatalf("unable to advance mock time: %v", err)
+	}
+	assertBlockTime(r, t, mockTime)
+
+	// Advancing the mock time again moves it further from where it was.
+	mockTime, err = r.AdvanceMockTime(30 * time.Minute)
+	if err != nil {
+		t.Fatalf("unable to advance mock time: %v", err)
+	}
+	assertBlockTime(r, t, mockTime)
+
+	// Negative timestamps are rejected.
+	if err := r.Node.SetMockTime(-1); err == nil {
+		t.Fatalf("setmocktime succeeded with a negative timestamp")
+	}
+
+	// The system time is used again after resetting the mock time, so
+	// the timestamp of the next block must be limited by the median time
+	// of the previous blocks instead, which is the timestamp of the first
+	// block generated with the mock time.
+	if err := r.SetMockTime(time.Time{}); err != nil {
+		t.Fatalf("unable to reset mock time: %v", err)
+	}
+	assertBlockTime(r, t, mockTime.Add(-30*time.Minute).Add(time.Second))
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is ignored during the regular tests due to the following build tag.
+// +build rpctest
+
+package integration
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/integration/rpctest"
+)
+
+// restGet requests the passed path of the REST interface of the harness and
+// returns the status code and body of the response.
+func restGet(r *rpctest.Harness, t *testing.T, path string) (int, []byte) {
+	rpcConfig := r.RPCConfig()
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(rpcConfig.Certificates) {
+		t.Fatalf("unable to load the RPC certificate")
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	}
+
+	resp, err := client.Get("https://" + rpcConfig.Host + "/rest/" + path)
+	if err != nil {
+		t.Fatalf("unable to request %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unable to read response for %s: %v", path, err)
+	}
+	return resp.StatusCode, body
+}
+
+// TestRest ensures the REST interface serves chain data without
+// authentication when it is enabled.
+func TestRest(t *testing.T) {
+	t.Parallel()
+
+	r, err := rpctest.New(&chaincfg.SimNetParams, nil, []string{"--rest"})
+	if err != nil {
+		t.Fatalf("unable to create harness: %v", err)
+	}
+	if err := r.SetUp(false, 0); err != nil {
+		t.Fatalf("unable to setup test chain: %v", err)
+	}
+	defer r.TearDown()
+
+	blockHashes, err := r.Node.Generate(2)
+	if err != nil {
+		t.Fatalf("unable to generate blocks: %v", err)
+	}
+
+	// The block hash by height must match the one of the RPC.
+	status, body := restGet(r, t, "blockhashbyheight/1.json")
+	var hashResult struct {
+		BlockHash string `json:"blockhash"`
+	}
+	if status != http.StatusOK {
+		t.Fatalf("blockhashbyheight: unexpected status %d: %s", status,
+			body)
+	}
+	if err := json.Unmarshal(body, &hashResult); err != nil {
+		t.Fatalf("blockhashbyheight: unable to decode %s: %v", body,
+			err)
+	}
+	if hashResult.BlockHash != blockHashes[0].String() {
+		t.Fatalf("blockhashbyheight: got %s, want %s",
+			hashResult.BlockHash, blockHashes[0])
+	}
+
+	// The serialized block must match the one of the RPC.
+	rawBlock, err := r.Node.RawRequest("getblock", []json.RawMessage{
+		json.RawMessage(`"` + blockHashes[1].String() + `"`),
+		json.RawMessage("0"),
+	})
+	if err != nil {
+		t.Fatalf("unable to get raw block: %v", err)
+	}
+	var blockHex string
+	if err := json.Unmarshal(rawBlock, &blockHex); err != nil {
+		t.Fatalf("unable to decode raw block: %v", err)
+	}
+	status, body = restGet(r, t, "block/"+blockHashes[1].String()+".bin")
+	if status != http.StatusOK {
+		t.Fatalf("block: unexpected status %d: %s", status, body)
+	}
+	if hex.EncodeToString(body) != blockHex {
+		t.Fatalf("block: got %x, want %s", body, blockHex)
+	}
+	block, err := r.Node.GetBlock(blockHashes[1])
+	if err != nil {
+		t.Fatalf("unable to get block: %v", err)
+	}
+
+	// The unspent coinbase output of the block must be found.
+	coinbase := block.Transactions[0].TxHash().String()
+	status, body = restGet(r, t, "getutxos/checkmempool/"+coinbase+
+		"-0/"+coinbase+"-1.json")
+	var utxosResult struct {
+		ChainHeight int32  `json:"chainHeight"`
+		Bitmap      string `json:"bitmap"`
+	}
+	if status != http.StatusOK {
+		t.Fatalf("getutxos: unexpected status %d: %s", status, body)
+	}
+	if err := json.Unmarshal(body, &utxosResult); err != nil {
+		t.Fatalf("getutxos: unable to decode %s: %v", body, err)
+	}
+	if utxosResult.ChainHeight != 2 || utxosResult.Bitmap != "10" {
+		t.Fatalf("getutxos: got height %d and bitmap %s, want height "+
+			"2 and bitmap 10", utxosResult.ChainHeight,
+			utxosResult.Bitmap)
+	}
+
+	// Unknown blocks and unsupported formats are not found.
+	status, _ = restGet(r, t, "block/"+strings.Repeat("0", 64)+".json")
+	if status != http.StatusNotFound {
+		t.Fatalf("block: got status %d for unknown block, want %d",
+			status, http.StatusNotFound)
+	}
+	status, _ = restGet(r, t, "chaininfo.bin")
+	if status != http.StatusNotFound {
+		t.Fatalf("chaininfo: got status %d for binary format, want %d",
+			status, http.StatusNotFound)
+	}
+}
@@ -9,6 +9,7 @@ package integration
 
 import (
 	"bytes"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"runtime/debug"
@@ -16,6 +17,8 @@ import (
 
 	"github.com/eacsuite/eacd/chaincfg"
 	"github.com/eacsuite/eacd/integration/rpctest"
+	"github.com/eacsuite/eacd/txscript"
+	"github.com/eacsuite/eacd/wire"
 )
 
 func testGetBestBlock(r *rpctest.Harness, t *testing.T) {
@@ -94,10 +97,117 @@ func testGetBlockHash(r *rpctest.Harness, t *testing.T) {
 	}
 }
 
+func testGenerateToAddress(r *rpctest.Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("Unable to create address: %v", err)
+	}
+
+	generatedBlockHashes, err := r.Node.GenerateToAddress(2, addr, nil)
+	if err != nil {
+		t.Fatalf("Call to `generatetoaddress` failed: %v", err)
+	}
+	if len(generatedBlockHashes) != 2 {
+		t.Fatalf("Number of generated blocks incorrect. Got %v, "+
+			"wanted %v", len(generatedBlockHashes), 2)
+	}
+
+	// The coinbase of each block should pay to the requested address.
+	for _, hash := range generatedBlockHashes {
+		block, err := r.Node.GetBlock(hash)
+		if err != nil {
+			t.Fatalf("Call to `getblock` failed: %v", err)
+		}
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			block.Transactions[0].TxOut[0].PkScript, r.ActiveNet)
+		if err != nil {
+			t.Fatalf("Unable to extract coinbase address: %v", err)
+		}
+		if len(addrs) != 1 ||
+			addrs[0].EncodeAddress() != addr.EncodeAddress() {
+
+			t.Fatalf("Coinbase of block %v pays to %v, wanted %v",
+				hash, addrs, addr)
+		}
+	}
+}
+
+func testGenerateBlock(r *rpctest.Harness, t *testing.T) {
+	addr, err := r.NewAddress()
+	if err != nil {
+		t.Fatalf("Unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("Unable to create pkScript: %v", err)
+	}
+
+	// Create a transaction which isn't in the mempool to include in the
+	// block.
+	tx, err := r.CreateTransaction(
+		[]*wire.TxOut{wire.NewTxOut(5e8, pkScript)}, 10, true)
+	if err != nil {
+		t.Fatalf("Unable to create transaction: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatalf("Unable to serialize transaction: %v", err)
+	}
+
+	bestHash, _, err := r.Node.GetBestBlock()
+	if err != nil {
+		t.Fatalf("Call to `getbestblock` failed: %v", err)
+	}
+	bestHeader, err := r.Node.GetBlockHeader(bestHash)
+	if err != nil {
+		t.Fatalf("Call to `getblockheader` failed: %v", err)
+	}
+
+	// Generate a block with exactly that transaction, a timestamp just
+	// after the current tip and a custom version.
+	timestamp := bestHeader.Timestamp.Unix() + 1
+	version := int32(0x20000000)
+	blockHash, err := r.Node.GenerateBlock(addr,
+		[]string{hex.EncodeToString(buf.Bytes())}, &timestamp, &version)
+	if err != nil {
+		t.Fatalf("Call to `generateblock` failed: %v", err)
+	}
+
+	block, err := r.Node.GetBlock(blockHash)
+	if err != nil {
+		t.Fatalf("Call to `getblock` failed: %v", err)
+	}
+	if block.Header.Timestamp.Unix() != timestamp {
+		t.Fatalf("Block timestamp incorrect. Got %v, wanted %v",
+			block.Header.Timestamp.Unix(), timestamp)
+	}
+	if block.Header.Version != version {
+		t.Fatalf("Block version incorrect. Got %v, wanted %v",
+			block.Header.Version, version)
+	}
+	if len(block.Transactions) != 2 ||
+		block.Transactions[1].TxHash() != tx.TxHash() {
+
+		t.Fatalf("Block does not contain exactly the requested " +
+			"transaction")
+	}
+
+	// A timestamp which isn't after the median time of the last several
+	// blocks must be rejected.
+	timestamp = bestHeader.Timestamp.Unix() - 3600
+	_, err = r.Node.GenerateBlock(addr, []string{}, &timestamp, nil)
+	if err == nil {
+		t.Fatalf("Call to `generateblock` with an old timestamp " +
+			"succeeded")
+	}
+}
+
 var rpcTestCases = []rpctest.HarnessTestCase{
 	testGetBestBlock,
 	testGetBlockCount,
 	testGetBlockHash,
+	testGenerateToAddress,
+	testGenerateBlock,
 }
 
 var primaryHarness *rpctest.Harness
@@ -88,6 +88,10 @@ type Harness struct {
 	maxConnRetries int
 	nodeNum        int
 
+	// mockTime is the time last set with SetMockTime or AdvanceMockTime,
+	// or the zero time when the node uses its system time.
+	mockTime time.Time
+
 	sync.Mutex
 }
 
@@ -407,6 +411,56 @@ func (h *Harness) P2PAddress() string {
 	return h.node.config.listen
 }
 
+// SetMockTime makes the node use the passed time, with 1 second precision, for
+// its consensus and policy rules instead of its system time.  Passing the zero
+// time makes the node use its system time again.  It is only supported by nodes
+// on the regression test network.
+//
+// This function is safe for concurrent access.
+func (h *Harness) SetMockTime(t time.Time) error {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.setMockTime(t)
+}
+
+// AdvanceMockTime moves the time the node uses for its consensus and policy
+// rules forward by the passed duration and returns the new time.  The time is
+// advanced from the current system time when no mock time is set yet.  It is
+// only supported by nodes on the regression test network.
+//
+// This function is safe for concurrent access.
+func (h *Harness) AdvanceMockTime(d time.Duration) (time.Time, error) {
+	h.Lock()
+	defer h.Unlock()
+
+	t := h.mockTime
+	if t.IsZero() {
+		t = time.Now()
+	}
+	t = t.Add(d)
+	if err := h.setMockTime(t); err != nil {
+		return time.Time{}, err
+	}
+	return h.mockTime, nil
+}
+
+// setMockTime sets the mock time of the node and records it in the harness.
+//
+// This function MUST be called with the harness lock held.
+func (h *Harness) setMockTime(t time.Time) error {
+	var timestamp int64
+	if !t.IsZero() {
+		t = t.Truncate(time.Second)
+		timestamp = t.Unix()
+	}
+	if err := h.Node.SetMockTime(timestamp); err != nil {
+		return err
+	}
+	h.mockTime = t
+	return nil
+}
+
 // GenerateAndSubmitBlock creates a block whose contents include the passed
 // transactions and submits it to the running simnet node. For generating
 // blocks with only a coinbase tx, callers can simply pass nil instead of
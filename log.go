@@ -18,9 +18,11 @@ import (
 	"github.com/eacsuite/eacd/mempool"
 	"github.com/eacsuite/eacd/mining"
 	"github.com/eacsuite/eacd/mining/cpuminer"
+	"github.com/eacsuite/eacd/mining/stratum"
 	"github.com/eacsuite/eacd/netsync"
 	"github.com/eacsuite/eacd/peer"
 	"github.com/eacsuite/eacd/txscript"
+	"github.com/eacsuite/eacd/zmq"
 
 	"github.com/btcsuite/btclog"
 	"github.com/jrick/logrotate/rotator"
@@ -67,8 +69,10 @@ var (
 	rpcsLog = backendLog.Logger("RPCS")
 	scrpLog = backendLog.Logger("SCRP")
 	srvrLog = backendLog.Logger("SRVR")
+	strmLog = backendLog.Logger("STRM")
 	syncLog = backendLog.Logger("SYNC")
 	txmpLog = backendLog.Logger("TXMP")
+	zmqpLog = backendLog.Logger("ZMQP")
 )
 
 // Initialize package-global logger variables.
@@ -80,10 +84,12 @@ func init() {
 	indexers.UseLogger(indxLog)
 	mining.UseLogger(minrLog)
 	cpuminer.UseLogger(minrLog)
+	stratum.UseLogger(strmLog)
 	peer.UseLogger(peerLog)
 	txscript.UseLogger(scrpLog)
 	netsync.UseLogger(syncLog)
 	mempool.UseLogger(txmpLog)
+	zmq.UseLogger(zmqpLog)
 }
 
 // subsystemLoggers maps each subsystem identifier to its associated logger.
@@ -101,8 +107,10 @@ var subsystemLoggers = map[string]btclog.Logger{
 	"RPCS": rpcsLog,
 	"SCRP": scrpLog,
 	"SRVR": srvrLog,
+	"STRM": strmLog,
 	"SYNC": syncLog,
 	"TXMP": txmpLog,
+	"ZMQP": zmqpLog,
 }
 
 // initLogRotator initializes the logging rotater to write logs to logFile and
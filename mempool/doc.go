@@ -40,6 +40,8 @@ be an exhaustive list.
    - Reject non-fully-spent duplicate transactions
    - Reject coinbase transactions
    - Reject double spends (both from the chain and other transactions in pool)
+   - Record rejected double spends of transactions in the pool which can't be
+     replaced and have valid signatures as proof, at a limited rate
    - Reject invalid transactions according to the network consensus rules
    - Full script execution and validation with signature cache support
    - Individual transaction query support
@@ -0,0 +1,245 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/eacsuite/eacd/blockchain"
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/txscript"
+	"github.com/eacsuite/eacd/wire"
+	"github.com/eacsuite/eacutil"
+)
+
+const (
+	// maxDoubleSpends is the maximum number of rejected double spends
+	// which are kept.  The oldest ones are forgotten once it is exceeded.
+	maxDoubleSpends = 1000
+
+	// maxDoubleSpendChecks is the limit of the exponentially decaying
+	// count of double spends whose signatures are checked.  The count
+	// decays with a window of about ten minutes, so it limits the cost of
+	// validating double spends, which pay no fees, the same way free
+	// transactions are rate limited.
+	maxDoubleSpendChecks = 100
+)
+
+// DoubleSpend describes a transaction which was rejected from the pool because
+// it spends outputs which are already spent by transactions in the pool.  Only
+// transactions with valid signatures for all their inputs are recorded, so a
+// double spend proves the owner of the outputs attempted to spend them twice.
+type DoubleSpend struct {
+	// Tx is the rejected transaction.
+	Tx *eacutil.Tx
+
+	// Fee is the fee paid by the rejected transaction in Satoshi.
+	Fee int64
+
+	// Conflicts holds the transactions in the pool spending the same
+	// outputs as the rejected transaction.
+	Conflicts []*eacutil.Tx
+
+	// Outpoints holds the outputs spent by both the rejected transaction
+	// and the transactions in the pool.
+	Outpoints []wire.OutPoint
+
+	// Peer is the tag, usually the peer ID, the rejected transaction was
+	// first received with.
+	Peer Tag
+
+	// Time is when the rejected transaction was first received.
+	Time time.Time
+
+	// First is true when the rejected transaction is the first double
+	// spend seen of at least one of its outpoints.  Only the first double
+	// spend of an output is relayed to other peers as proof.
+	First bool
+}
+
+// maybeAddDoubleSpend records the passed transaction, which was rejected from
+// the pool, as a double spend when it spends outputs which are already spent by
+// transactions in the pool and its signatures are valid.  The record is
+// returned when the transaction was not recorded before, nil otherwise.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) maybeAddDoubleSpend(tx *eacutil.Tx, tag Tag) *DoubleSpend {
+	txHash := tx.Hash()
+	if _, exists := mp.doubleSpends[*txHash]; exists {
+		return nil
+	}
+	if mp.isTransactionInPool(txHash) {
+		return nil
+	}
+
+	ds := &DoubleSpend{
+		Tx:   tx,
+		Peer: tag,
+		Time: blockchain.Now(),
+	}
+	conflicts := make(map[chainhash.Hash]struct{})
+	for _, txIn := range tx.MsgTx().TxIn {
+		conflict, exists := mp.outpoints[txIn.PreviousOutPoint]
+		if !exists {
+			continue
+		}
+		ds.Outpoints = append(ds.Outpoints, txIn.PreviousOutPoint)
+		if len(mp.doubleSpentOutpoints[txIn.PreviousOutPoint]) == 0 {
+			ds.First = true
+		}
+		if _, exists := conflicts[*conflict.Hash()]; !exists {
+			conflicts[*conflict.Hash()] = struct{}{}
+			ds.Conflicts = append(ds.Conflicts, conflict)
+		}
+	}
+	if len(ds.Outpoints) == 0 {
+		return nil
+	}
+
+	// Limit the rate at which double spends are validated since they are
+	// never accepted and thus never pay for it.
+	nowUnix := blockchain.Now().Unix()
+	mp.doubleSpendChecks *= math.Pow(1.0-1.0/600.0,
+		float64(nowUnix-mp.lastDoubleSpendCheck))
+	mp.lastDoubleSpendCheck = nowUnix
+	if mp.doubleSpendChecks >= maxDoubleSpendChecks {
+		log.Debugf("Ignoring double spend %v due to the rate limit",
+			txHash)
+		return nil
+	}
+	mp.doubleSpendChecks++
+
+	// Only transactions which could have been mined in place of the ones
+	// in the pool are double spends, so their inputs and signatures must
+	// be valid.
+	if err := mp.checkDoubleSpend(tx, ds); err != nil {
+		log.Debugf("Ignoring invalid double spend %v: %v", txHash, err)
+		return nil
+	}
+
+	// Forget the oldest double spend when there are too many.
+	if len(mp.doubleSpends) >= maxDoubleSpends {
+		var oldest *DoubleSpend
+		for _, otherDS := range mp.doubleSpends {
+			if oldest == nil || otherDS.Time.Before(oldest.Time) {
+				oldest = otherDS
+			}
+		}
+		mp.removeDoubleSpend(oldest)
+	}
+
+	mp.doubleSpends[*txHash] = ds
+	for _, op := range ds.Outpoints {
+		doubleSpends, exists := mp.doubleSpentOutpoints[op]
+		if !exists {
+			doubleSpends = make(map[chainhash.Hash]*DoubleSpend)
+			mp.doubleSpentOutpoints[op] = doubleSpends
+		}
+		doubleSpends[*txHash] = ds
+	}
+
+	log.Infof("Rejected transaction %v from peer %d double spends "+
+		"transaction %v", txHash, tag, ds.Conflicts[0].Hash())
+
+	return ds
+}
+
+// checkDoubleSpend ensures the inputs of the passed double spend exist and its
+// signatures are valid.  The fee of the double spend is set when they are.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkDoubleSpend(tx *eacutil.Tx, ds *DoubleSpend) error {
+	err := blockchain.CheckTransactionSanity(tx)
+	if err != nil {
+		return err
+	}
+
+	utxoView, err := mp.fetchInputUtxos(tx)
+	if err != nil {
+		return err
+	}
+
+	// The outputs spent by the transactions in the pool are still unspent
+	// in the view, so this only fails for unknown or confirmed spent
+	// outputs.
+	nextBlockHeight := mp.cfg.BestHeight() + 1
+	fee, err := blockchain.CheckTransactionInputs(tx, nextBlockHeight,
+		utxoView, mp.cfg.ChainParams)
+	if err != nil {
+		return err
+	}
+
+	err = blockchain.ValidateTransactionScripts(tx, utxoView,
+		txscript.StandardVerifyFlags, mp.cfg.SigCache,
+		mp.cfg.HashCache)
+	if err != nil {
+		return err
+	}
+
+	ds.Fee = fee
+	return nil
+}
+
+// removeDoubleSpend forgets the passed double spend.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) removeDoubleSpend(ds *DoubleSpend) {
+	txHash := ds.Tx.Hash()
+	delete(mp.doubleSpends, *txHash)
+	for _, op := range ds.Outpoints {
+		doubleSpends := mp.doubleSpentOutpoints[op]
+		delete(doubleSpends, *txHash)
+		if len(doubleSpends) == 0 {
+			delete(mp.doubleSpentOutpoints, op)
+		}
+	}
+}
+
+// removeDoubleSpendsOf forgets the double spends of the passed outpoint.  It is
+// called once the transaction in the pool spending it is removed since they
+// are no longer double spends of a transaction in the pool.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) removeDoubleSpendsOf(op wire.OutPoint) {
+	for _, ds := range mp.doubleSpentOutpoints[op] {
+		mp.removeDoubleSpend(ds)
+	}
+}
+
+// DoubleSpends returns the recorded double spends of transactions in the pool
+// ordered by the time they were first received.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) DoubleSpends() []*DoubleSpend {
+	mp.mtx.RLock()
+	doubleSpends := make([]*DoubleSpend, 0, len(mp.doubleSpends))
+	for _, ds := range mp.doubleSpends {
+		doubleSpends = append(doubleSpends, ds)
+	}
+	mp.mtx.RUnlock()
+
+	sort.Slice(doubleSpends, func(i, j int) bool {
+		return doubleSpends[i].Time.Before(doubleSpends[j].Time)
+	})
+	return doubleSpends
+}
+
+// FetchDoubleSpend returns the recorded double spend with the passed hash so it
+// can be served to peers as proof.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) FetchDoubleSpend(txHash *chainhash.Hash) (*eacutil.Tx, error) {
+	mp.mtx.RLock()
+	ds, exists := mp.doubleSpends[*txHash]
+	mp.mtx.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("transaction is not a known double spend")
+	}
+	return ds.Tx, nil
+}
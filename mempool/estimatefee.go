@@ -178,6 +178,9 @@ type FeeEstimator struct {
 	// Transactions that have been removed from the bins. This allows us to
 	// revert in case of an orphaned block.
 	dropped []*registeredBlock
+
+	// The decaying counts used by EstimateSmartFee and EstimateRawFee.
+	smart *smartFeeStats
 }
 
 // NewFeeEstimator creates a FeeEstimator for which at most maxRollback blocks
@@ -192,6 +195,7 @@ func NewFeeEstimator(maxRollback, minRegisteredBlocks uint32) *FeeEstimator {
 		maxReplacements:     estimateFeeMaxReplacements,
 		observed:            make(map[chainhash.Hash]*observedTransaction),
 		dropped:             make([]*registeredBlock, 0, maxRollback),
+		smart:               newSmartFeeStats(),
 	}
 }
 
@@ -207,6 +211,8 @@ func (ef *FeeEstimator) ObserveTransaction(t *TxDesc) {
 	}
 
 	hash := *t.Tx.Hash()
+	ef.smart.observeTransaction(&hash, eacutil.Amount(t.Fee),
+		GetTxVirtualSize(t.Tx), t.Height)
 	if _, ok := ef.observed[hash]; !ok {
 		size := uint32(GetTxVirtualSize(t.Tx))
 
@@ -219,6 +225,15 @@ func (ef *FeeEstimator) ObserveTransaction(t *TxDesc) {
 	}
 }
 
+// RemoveTransaction is called when a transaction leaves the mempool for any
+// reason other than being included in a block.  It counts as a transaction
+// which was not confirmed for EstimateSmartFee and EstimateRawFee.
+func (ef *FeeEstimator) RemoveTransaction(hash *chainhash.Hash) {
+	ef.mtx.Lock()
+	ef.smart.removeTransaction(hash)
+	ef.mtx.Unlock()
+}
+
 // RegisterBlock informs the fee estimator of a new block to take into account.
 func (ef *FeeEstimator) RegisterBlock(block *eacutil.Block) error {
 	ef.mtx.Lock()
@@ -236,6 +251,7 @@ func (ef *FeeEstimator) RegisterBlock(block *eacutil.Block) error {
 	// Update the last known height.
 	ef.lastKnownHeight = height
 	ef.numBlocksRegistered++
+	ef.smart.registerBlock(block)
 
 	// Randomly order txs in block.
 	transactions := make(map[*eacutil.Tx]struct{})
@@ -342,7 +358,9 @@ func (ef *FeeEstimator) LastKnownHeight() int32 {
 // Note: not everything can be rolled back because some transactions are
 // deleted if they have been observed too long ago. That means the result
 // of Rollback won't always be exactly the same as if the last block had not
-// happened, but it should be close enough.
+// happened, but it should be close enough.  The decaying counts used by
+// EstimateSmartFee are not rolled back at all; the blocks replacing the
+// orphaned ones are ignored by them instead.
 func (ef *FeeEstimator) Rollback(hash *chainhash.Hash) error {
 	ef.mtx.Lock()
 	defer ef.mtx.Unlock()
@@ -574,11 +592,80 @@ func (ef *FeeEstimator) EstimateFee(numBlocks uint32) (BtcPerKilobyte, error) {
 	return ef.cached[int(numBlocks)-1].ToBtcPerKb(), nil
 }
 
+// EstimateSmartFee estimates the fee rate a transaction must pay to be
+// confirmed within the passed number of blocks.  It returns the estimate along
+// with the number of blocks it was made for, which differs from the requested
+// one when there isn't enough data to estimate it.
+//
+// Estimates combine the fee rates which were confirmed within half, once and
+// twice the target with increasing success thresholds.  Conservative
+// estimates also take the longer horizons into account for twice the target,
+// which makes them react slower to falling fees.
+func (ef *FeeEstimator) EstimateSmartFee(confTarget uint32, conservative bool) (BtcPerKilobyte, uint32, error) {
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
+
+	if confTarget == 0 || confTarget > MaxFeeEstimateTarget {
+		return -1, 0, fmt.Errorf("can only estimate fees for 1 to %d "+
+			"blocks from now", MaxFeeEstimateTarget)
+	}
+
+	feeRate, target := ef.smart.estimateSmartFee(confTarget, conservative)
+	if feeRate < 0 {
+		return -1, target, errors.New("insufficient data or no fee " +
+			"rate found")
+	}
+	return SatoshiPerByte(feeRate / bytePerKb).ToBtcPerKb(), target, nil
+}
+
+// EstimateRawFee estimates the fee rate a transaction must pay to be confirmed
+// within the passed number of blocks with the passed probability using a
+// single horizon.  The ranges of fee rates which were found to pass and fail
+// the threshold are returned along with the estimate.  An error is returned
+// when the horizon doesn't track the passed number of blocks.
+func (ef *FeeEstimator) EstimateRawFee(confTarget uint32, threshold float64,
+	horizon FeeEstimateHorizon) (*RawFeeEstimate, error) {
+
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
+
+	if horizon < 0 || horizon >= numFeeEstimateHorizons {
+		return nil, fmt.Errorf("unknown fee estimate horizon %d",
+			int(horizon))
+	}
+	stats := ef.smart.horizons[horizon]
+	if confTarget == 0 || confTarget > stats.maxConfirms() {
+		return nil, fmt.Errorf("the %v horizon can only estimate fees "+
+			"for 1 to %d blocks from now", horizon,
+			stats.maxConfirms())
+	}
+
+	sufficientTxs := float64(sufficientFeeTxs)
+	if horizon == ShortHorizon {
+		sufficientTxs = sufficientTxsShort
+	}
+	feeRate, pass, fail := stats.estimateMedianFeeRate(confTarget,
+		sufficientTxs, threshold, ef.smart.buckets,
+		ef.smart.unconfirmed(confTarget))
+
+	estimate := &RawFeeEstimate{
+		FeeRate: -1,
+		Decay:   stats.decay,
+		Scale:   stats.scale,
+		Pass:    pass,
+		Fail:    fail,
+	}
+	if feeRate >= 0 {
+		estimate.FeeRate = SatoshiPerByte(feeRate / bytePerKb).ToBtcPerKb()
+	}
+	return estimate, nil
+}
+
 // In case the format for the serialized version of the FeeEstimator changes,
 // we use a version number. If the version number changes, it does not make
 // sense to try to upgrade a previous version to a new version. Instead, just
 // start fee estimation over.
-const estimateFeeSaveVersion = 1
+const estimateFeeSaveVersion = 2
 
 func deserializeRegisteredBlock(r io.Reader, txs map[uint32]*observedTransaction) (*registeredBlock, error) {
 	var lenTransactions uint32
@@ -671,6 +758,9 @@ func (ef *FeeEstimator) Save() FeeEstimatorState {
 		registered.serialize(w, observed)
 	}
 
+	// The decaying counts.
+	ef.smart.serialize(w)
+
 	// Commit the tx and return.
 	return FeeEstimatorState(w.Bytes())
 }
@@ -745,5 +835,11 @@ func RestoreFeeEstimator(data FeeEstimatorState) (*FeeEstimator, error) {
 		}
 	}
 
+	// Read the decaying counts.
+	ef.smart, err = deserializeSmartFeeStats(r)
+	if err != nil {
+		return nil, err
+	}
+
 	return ef, nil
 }
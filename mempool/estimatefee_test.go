@@ -6,6 +6,7 @@ package mempool
 
 import (
 	"bytes"
+	"math"
 	"math/rand"
 	"testing"
 
@@ -26,6 +27,7 @@ func newTestFeeEstimator(binSize, maxReplacements, maxRollback uint32) *FeeEstim
 		maxReplacements:     int32(maxReplacements),
 		observed:            make(map[chainhash.Hash]*observedTransaction),
 		dropped:             make([]*registeredBlock, 0, maxRollback),
+		smart:               newSmartFeeStats(),
 	}
 }
 
@@ -422,3 +424,174 @@ func TestDatabase(t *testing.T) {
 		eft.checkSaveAndRestore(estimateHistory[len(estimateHistory)-round-1])
 	}
 }
+
+// smartFeeRate returns the fee rate in BTC/kB the smart fee estimator tracks
+// the passed transaction with.
+func smartFeeRate(t *TxDesc) BtcPerKilobyte {
+	size := float64(GetTxVirtualSize(t.Tx))
+	return SatoshiPerByte(float64(t.Fee) / size).ToBtcPerKb()
+}
+
+// TestEstimateSmartFee ensures the smart fee estimator estimates the fee rate
+// of transactions confirmed within the target and distinguishes the horizons
+// and modes.
+func TestEstimateSmartFee(t *testing.T) {
+	t.Parallel()
+
+	const (
+		numBlocks = 200
+		slowDelay = 30
+	)
+
+	eft := estimateFeeTester{ef: newTestFeeEstimator(5, 3, 1), t: t}
+	eft.newBlock(nil)
+
+	_, _, err := eft.ef.EstimateSmartFee(2, false)
+	if err == nil {
+		t.Fatal("EstimateSmartFee: expected an error without data")
+	}
+
+	// Every block confirms two transactions paying a high fee rate which
+	// were added one block before and two transactions paying a low fee
+	// rate which were added slowDelay+1 blocks before.  Estimates for
+	// targets of more than twice that find the low fee rate.
+	var fast, slow []*TxDesc
+	var pendingFast []*TxDesc
+	var pendingSlow [][]*TxDesc
+	for i := 0; i < numBlocks; i++ {
+		var added []*TxDesc
+		for j := 0; j < 2; j++ {
+			fastTx := eft.testTx(100000)
+			slowTx := eft.testTx(1000)
+			eft.ef.ObserveTransaction(fastTx)
+			eft.ef.ObserveTransaction(slowTx)
+			fast = append(fast, fastTx)
+			slow = append(slow, slowTx)
+			added = append(added, slowTx)
+		}
+
+		var txns []*wire.MsgTx
+		for _, desc := range pendingFast {
+			txns = append(txns, desc.Tx.MsgTx())
+		}
+		if len(pendingSlow) == slowDelay {
+			for _, desc := range pendingSlow[0] {
+				txns = append(txns, desc.Tx.MsgTx())
+			}
+			pendingSlow = pendingSlow[1:]
+		}
+		pendingFast = fast[len(fast)-2:]
+		pendingSlow = append(pendingSlow, added)
+		eft.newBlock(txns)
+	}
+
+	fastRate := smartFeeRate(fast[0])
+	slowRate := smartFeeRate(slow[0])
+	closeTo := func(got, want BtcPerKilobyte) bool {
+		return math.Abs(float64(got-want)) < float64(want)*1e-9
+	}
+
+	tests := []struct {
+		name         string
+		target       uint32
+		conservative bool
+		want         BtcPerKilobyte
+		wantTarget   uint32
+	}{
+		{"next block", 1, false, fastRate, 2},
+		{"next block conservative", 1, true, fastRate, 2},
+		{"beyond slow delay", 80, false, slowRate, 80},
+		{"beyond usable data", MaxFeeEstimateTarget, false, slowRate,
+			(numBlocks - 1) / 2},
+	}
+	for _, test := range tests {
+		feeRate, target, err := eft.ef.EstimateSmartFee(test.target,
+			test.conservative)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !closeTo(feeRate, test.want) {
+			t.Errorf("%s: unexpected fee rate - got %v, want %v",
+				test.name, feeRate, test.want)
+		}
+		if target != test.wantTarget {
+			t.Errorf("%s: unexpected target - got %d, want %d",
+				test.name, target, test.wantTarget)
+		}
+	}
+
+	// The conservative estimate must not be lower than the economical one.
+	economical, _, _ := eft.ef.EstimateSmartFee(80, false)
+	conservative, _, _ := eft.ef.EstimateSmartFee(80, true)
+	if conservative < economical {
+		t.Errorf("conservative estimate %v is lower than economical "+
+			"estimate %v", conservative, economical)
+	}
+
+	_, _, err = eft.ef.EstimateSmartFee(MaxFeeEstimateTarget+1, false)
+	if err == nil {
+		t.Error("EstimateSmartFee: expected an error for a target " +
+			"which is not tracked")
+	}
+
+	// A raw estimate requiring every transaction to be confirmed within
+	// two blocks only passes the fast transactions.
+	raw, err := eft.ef.EstimateRawFee(2, 0.95, ShortHorizon)
+	if err != nil {
+		t.Fatalf("EstimateRawFee: unexpected error: %v", err)
+	}
+	if !closeTo(raw.FeeRate, fastRate) {
+		t.Errorf("EstimateRawFee: unexpected fee rate - got %v, want %v",
+			raw.FeeRate, fastRate)
+	}
+	if raw.Pass == nil || raw.Fail == nil {
+		t.Fatalf("EstimateRawFee: expected passing and failing ranges")
+	}
+	if raw.Fail.EndRange > raw.Pass.StartRange {
+		t.Errorf("EstimateRawFee: failing range ends at %v above "+
+			"passing range starting at %v", raw.Fail.EndRange,
+			raw.Pass.StartRange)
+	}
+	if raw.Scale != shortScale || raw.Decay != shortDecay {
+		t.Errorf("EstimateRawFee: unexpected horizon - got scale %d "+
+			"and decay %v", raw.Scale, raw.Decay)
+	}
+	_, err = eft.ef.EstimateRawFee(shortBlockPeriods*shortScale+1, 0.95,
+		ShortHorizon)
+	if err == nil {
+		t.Error("EstimateRawFee: expected an error for a target beyond " +
+			"the short horizon")
+	}
+
+	// Transactions which leave the pool unconfirmed count as failures
+	// for every period they were waiting for.
+	tx := eft.testTx(50000)
+	eft.ef.ObserveTransaction(tx)
+	bucket := eft.ef.smart.tracked[*tx.Tx.Hash()].bucket
+	for i := 0; i < 2*shortScale; i++ {
+		eft.newBlock(nil)
+	}
+	stats := eft.ef.smart.horizons[ShortHorizon]
+	before := stats.failAvg[1][bucket]
+	eft.ef.RemoveTransaction(tx.Tx.Hash())
+	if _, exists := eft.ef.smart.tracked[*tx.Tx.Hash()]; exists {
+		t.Error("RemoveTransaction: transaction is still tracked")
+	}
+	if stats.failAvg[1][bucket] != before+1 {
+		t.Errorf("RemoveTransaction: failure not counted - got %v, "+
+			"want %v", stats.failAvg[1][bucket], before+1)
+	}
+
+	// The decaying counts survive saving and restoring the estimator.
+	restored, err := RestoreFeeEstimator(eft.ef.Save())
+	if err != nil {
+		t.Fatalf("RestoreFeeEstimator: unexpected error: %v", err)
+	}
+	want, wantTarget, _ := eft.ef.EstimateSmartFee(80, true)
+	got, gotTarget, _ := restored.EstimateSmartFee(80, true)
+	if got != want || gotTarget != wantTarget {
+		t.Errorf("restored estimate %v for %d blocks differs from %v "+
+			"for %d blocks", got, gotTarget, want, wantTarget)
+	}
+}
@@ -5,9 +5,11 @@
 package mempool
 
 import (
+	"container/heap"
 	"container/list"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -48,6 +50,44 @@ const (
 	// can be evicted from the mempool when accepting a transaction
 	// replacement.
 	MaxReplacementEvictions = 100
+
+	// DefaultMaxPoolSize is the default maximum total serialized size in
+	// bytes of the transactions in the main pool.
+	DefaultMaxPoolSize = 300 * 1000 * 1000
+
+	// rollingFeeHalfLife is the amount of time it takes the minimum fee
+	// raised by evicting transactions from a full pool to decay by half.
+	// The half-life is shortened while the pool is less than half full.
+	rollingFeeHalfLife = time.Hour * 12
+
+	// DefaultMaxAncestorCount is the default maximum number of unconfirmed
+	// transactions in the pool a transaction may depend on, including
+	// itself.
+	DefaultMaxAncestorCount = 25
+
+	// DefaultMaxAncestorSize is the default maximum total virtual size in
+	// bytes of a transaction along with its unconfirmed ancestors.
+	DefaultMaxAncestorSize = 101000
+
+	// DefaultMaxDescendantCount is the default maximum number of
+	// transactions in the pool which may depend on a transaction,
+	// including itself.
+	DefaultMaxDescendantCount = 25
+
+	// DefaultMaxDescendantSize is the default maximum total virtual size in
+	// bytes of a transaction along with the transactions in the pool which
+	// depend on it.
+	DefaultMaxDescendantSize = 101000
+
+	// incrementalRelayFee is the fee rate in Satoshi/kB the minimum fee of a
+	// full pool is raised above the fee rate of the evicted transactions.
+	// It ensures a transaction taking the place of evicted ones also pays
+	// for its own relay.
+	incrementalRelayFee = 1000
+
+	// DefaultMaxTxAge is the default maximum amount of time a transaction
+	// may stay in the pool before it expires.
+	DefaultMaxTxAge = time.Hour * 24 * 14
 )
 
 // Tag represents an identifier to use for tagging orphan transactions.  The
@@ -55,6 +95,59 @@ const (
 // so that orphans can be identified by which peer first relayed them.
 type Tag uint64
 
+// RemovalReason identifies why a transaction was removed from the pool.
+type RemovalReason int
+
+// These constants define the reasons a transaction is removed from the pool.
+const (
+	// RemovalReasonUnknown indicates the transaction was removed for a
+	// reason not covered by the other reasons.
+	RemovalReasonUnknown RemovalReason = iota
+
+	// RemovalReasonBlock indicates the transaction was included in a block
+	// connected to the main chain.
+	RemovalReasonBlock
+
+	// RemovalReasonConflict indicates the transaction spends an output
+	// spent by a transaction in a block connected to the main chain.
+	RemovalReasonConflict
+
+	// RemovalReasonReplaced indicates the transaction was replaced by a
+	// transaction paying a higher fee.
+	RemovalReasonReplaced
+
+	// RemovalReasonSizeLimit indicates the transaction was evicted to keep
+	// the pool within its maximum size.
+	RemovalReasonSizeLimit
+
+	// RemovalReasonExpiry indicates the transaction was in the pool for
+	// longer than the maximum transaction age.
+	RemovalReasonExpiry
+
+	// RemovalReasonReorg indicates the transaction is no longer valid for
+	// inclusion in the next block after the main chain was reorganized.
+	RemovalReasonReorg
+)
+
+// Map of removal reasons back to their constant names for pretty printing.
+var removalReasonStrings = map[RemovalReason]string{
+	RemovalReasonUnknown:   "unknown",
+	RemovalReasonBlock:     "block",
+	RemovalReasonConflict:  "conflict",
+	RemovalReasonReplaced:  "replaced",
+	RemovalReasonSizeLimit: "sizelimit",
+	RemovalReasonExpiry:    "expiry",
+	RemovalReasonReorg:     "reorg",
+}
+
+// String returns the RemovalReason as a human-readable name.
+func (reason RemovalReason) String() string {
+	if s, ok := removalReasonStrings[reason]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown RemovalReason (%d)", int(reason))
+}
+
 // Config is a descriptor containing the memory pool configuration.
 type Config struct {
 	// Policy defines the various mempool configuration options related
@@ -103,6 +196,41 @@ type Config struct {
 	// FeeEstimatator provides a feeEstimator. If it is not nil, the mempool
 	// records all new transactions it observes into the feeEstimator.
 	FeeEstimator *FeeEstimator
+
+	// NotifyTxAdded defines the function to call with the descriptor of
+	// every transaction added to the main pool, and with the new
+	// descriptor of a transaction in it when its fee delta changes.  It is
+	// called with the mempool lock held, so it must not call back into the
+	// mempool.  It may be nil.
+	NotifyTxAdded func(txDesc *TxDesc)
+
+	// NotifyTxAccepted defines the function to call with the descriptor of
+	// every transaction added to the main pool.  Unlike NotifyTxAdded, it
+	// is not called when the fee delta of a transaction changes.  It is
+	// called with the mempool lock held, so it must not call back into the
+	// mempool.  It may be nil.
+	NotifyTxAccepted func(txDesc *TxDesc)
+
+	// NotifyTxRemoved defines the function to call with every transaction
+	// removed from the main pool along with the reason it was removed.  It
+	// is called with the mempool lock held, so it must not call back into
+	// the mempool.  It may be nil.
+	NotifyTxRemoved func(tx *eacutil.Tx, reason RemovalReason)
+
+	// NotifyTxReplaced defines the function to call when a transaction
+	// accepted into the main pool replaced transactions in it.  It is
+	// called with the descriptor of the replacement and the descriptors
+	// of all the transactions it replaced, including the descendants of
+	// the ones it conflicts with.  It is called with the mempool lock
+	// held, so it must not call back into the mempool.  It may be nil.
+	NotifyTxReplaced func(txDesc *TxDesc, replaced []*TxDesc)
+
+	// NotifyDoubleSpend defines the function to call when a transaction
+	// which spends outputs already spent by transactions in the main pool
+	// is rejected and recorded as a double spend.  It is called once for
+	// every double spend after the mempool lock has been released, so it
+	// may block and call back into the mempool.  It may be nil.
+	NotifyDoubleSpend func(ds *DoubleSpend)
 }
 
 // Policy houses the policy (configuration parameters) which is used to
@@ -148,6 +276,38 @@ type Policy struct {
 	// transactions using the Replace-By-Fee (RBF) signaling policy into
 	// the mempool.
 	RejectReplacement bool
+
+	// FullRBF, if true, accepts replacement transactions into the mempool
+	// even when the transactions they replace do not signal replacement
+	// using the Replace-By-Fee (RBF) signaling policy.  It has no effect
+	// when RejectReplacement is set.
+	FullRBF bool
+
+	// MaxPoolSize is the maximum total serialized size in bytes of the
+	// transactions in the main pool.  When it is exceeded, the transactions
+	// with the lowest fee rates are evicted.  A value of zero disables the
+	// limit.
+	MaxPoolSize int64
+
+	// MaxAncestorCount and MaxAncestorSize are the maximum number and
+	// total virtual size of the unconfirmed transactions in the pool a
+	// transaction may depend on, including the transaction itself.  A
+	// value of zero disables the limit.
+	MaxAncestorCount int64
+	MaxAncestorSize  int64
+
+	// MaxDescendantCount and MaxDescendantSize are the maximum number and
+	// total virtual size of the transactions in the pool which may depend
+	// on a transaction, including the transaction itself.  A value of zero
+	// disables the limit.
+	MaxDescendantCount int64
+	MaxDescendantSize  int64
+
+	// MaxTxAge is the maximum amount of time a transaction may stay in the
+	// main pool.  Older transactions are removed along with the
+	// transactions depending on them by ExpireTransactions.  A value of
+	// zero disables expiry.
+	MaxTxAge time.Duration
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -158,6 +318,137 @@ type TxDesc struct {
 	// StartingPriority is the priority of the transaction when it was added
 	// to the pool.
 	StartingPriority float64
+
+	// The following fields hold the number, total virtual size and total
+	// fees including fee deltas of the unconfirmed ancestors and
+	// descendants of the transaction in the pool, including the
+	// transaction itself.  They are updated as the pool changes and must
+	// only be accessed with the mempool lock held.
+	ancestorCount   int64
+	ancestorSize    int64
+	ancestorFees    int64
+	descendantCount int64
+	descendantSize  int64
+	descendantFees  int64
+
+	// evictionIndex is the position of the transaction in the eviction
+	// heap of the pool, or -1 while it is not in it.
+	evictionIndex int
+}
+
+// modifiedFee returns the fee of the transaction adjusted by its fee delta.
+func (txD *TxDesc) modifiedFee() int64 {
+	return txD.Fee + txD.FeeDelta
+}
+
+// descendantFeeRate returns the fee rate in Satoshi/kB of the transaction
+// along with its descendants in the pool, which are evicted together.
+func (txD *TxDesc) descendantFeeRate() float64 {
+	return float64(txD.descendantFees) * 1000 / float64(txD.descendantSize)
+}
+
+// evictionHeap is a min-heap of the transactions in the pool ordered by their
+// descendant fee rate so the cheapest package to evict from a full pool is
+// found without scanning the pool.  It implements heap.Interface and keeps the
+// eviction index of the transactions up to date.
+type evictionHeap []*TxDesc
+
+// Len returns the number of transactions in the heap.  It is part of the
+// heap.Interface implementation.
+func (h evictionHeap) Len() int {
+	return len(h)
+}
+
+// Less returns whether the transaction at index i has a lower descendant fee
+// rate than the one at index j.  It is part of the heap.Interface
+// implementation.
+func (h evictionHeap) Less(i, j int) bool {
+	return h[i].descendantFeeRate() < h[j].descendantFeeRate()
+}
+
+// Swap swaps the transactions at the passed indices.  It is part of the
+// heap.Interface implementation.
+func (h evictionHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].evictionIndex = i
+	h[j].evictionIndex = j
+}
+
+// Push adds the passed transaction to the end of the heap.  It is part of the
+// heap.Interface implementation.
+func (h *evictionHeap) Push(x interface{}) {
+	txDesc := x.(*TxDesc)
+	txDesc.evictionIndex = len(*h)
+	*h = append(*h, txDesc)
+}
+
+// Pop removes the transaction at the end of the heap and returns it.  It is
+// part of the heap.Interface implementation.
+func (h *evictionHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	txDesc := old[n-1]
+	old[n-1] = nil
+	txDesc.evictionIndex = -1
+	*h = old[0 : n-1]
+	return txDesc
+}
+
+// evictionOrder is a min-heap of positions in an eviction heap ordered by the
+// descendant fee rate of the transactions at them.  Since the children of a
+// position in the eviction heap never have a lower fee rate, it is used to
+// visit the transactions in the order they would be evicted without modifying
+// the eviction heap by only adding the children of visited positions.  It
+// implements heap.Interface.
+type evictionOrder struct {
+	heap      evictionHeap
+	positions []int
+}
+
+// Len returns the number of positions to visit.  It is part of the
+// heap.Interface implementation.
+func (o *evictionOrder) Len() int {
+	return len(o.positions)
+}
+
+// Less returns whether the transaction at the position at index i has a lower
+// descendant fee rate than the one at index j.  It is part of the
+// heap.Interface implementation.
+func (o *evictionOrder) Less(i, j int) bool {
+	return o.heap.Less(o.positions[i], o.positions[j])
+}
+
+// Swap swaps the positions at the passed indices.  It is part of the
+// heap.Interface implementation.
+func (o *evictionOrder) Swap(i, j int) {
+	o.positions[i], o.positions[j] = o.positions[j], o.positions[i]
+}
+
+// Push adds the passed position to the end of the heap.  It is part of the
+// heap.Interface implementation.
+func (o *evictionOrder) Push(x interface{}) {
+	o.positions = append(o.positions, x.(int))
+}
+
+// Pop removes the position at the end of the heap and returns it.  It is part
+// of the heap.Interface implementation.
+func (o *evictionOrder) Pop() interface{} {
+	n := len(o.positions)
+	pos := o.positions[n-1]
+	o.positions = o.positions[:n-1]
+	return pos
+}
+
+// next removes the position of the transaction with the lowest descendant fee
+// rate which was not visited yet and returns the transaction at it.
+func (o *evictionOrder) next() *TxDesc {
+	pos := heap.Pop(o).(int)
+	for _, child := range []int{2*pos + 1, 2*pos + 2} {
+		if child < len(o.heap) {
+			heap.Push(o, child)
+		}
+	}
+	return o.heap[pos]
 }
 
 // orphanTx is normal transaction that references an ancestor transaction
@@ -184,6 +475,34 @@ type TxPool struct {
 	outpoints     map[wire.OutPoint]*eacutil.Tx
 	pennyTotal    float64 // exponentially decaying total for penny spends.
 	lastPennyUnix int64   // unix time of last ``penny spend''
+	poolSize      int64   // total serialized size of the main pool.
+
+	// evictionHeap orders the transactions in the main pool by their
+	// descendant fee rate for evicting them when the pool is full.
+	evictionHeap evictionHeap
+
+	// rollingMinFee is the minimum fee rate in Satoshi/kB raised by
+	// evicting transactions from a full pool.  It decays exponentially
+	// since lastRollingFeeUpdate so the pool accepts cheaper transactions
+	// again once blocks have made room.
+	rollingMinFee        float64
+	lastRollingFeeUpdate time.Time
+
+	// feeDeltas holds the fee adjustments in Satoshi of transactions keyed
+	// by their hash.  They are kept for transactions which are not in the
+	// pool as well and persisted along with the pool.
+	feeDeltas map[chainhash.Hash]int64
+
+	// doubleSpends holds the rejected double spends of transactions in the
+	// pool keyed by their hash and doubleSpentOutpoints holds them keyed
+	// by the outputs they spend which are spent by the pool.
+	doubleSpends         map[chainhash.Hash]*DoubleSpend
+	doubleSpentOutpoints map[wire.OutPoint]map[chainhash.Hash]*DoubleSpend
+
+	// doubleSpendChecks is an exponentially decaying count of the double
+	// spends whose signatures were checked, as of lastDoubleSpendCheck.
+	doubleSpendChecks    float64
+	lastDoubleSpendCheck int64
 
 	// nextExpireScan is the time after which the orphan pool will be
 	// scanned in order to evict orphans.  This is NOT a hard deadline as
@@ -271,7 +590,7 @@ func (mp *TxPool) limitNumOrphans() error {
 	// Scan through the orphan pool and remove any expired orphans when it's
 	// time.  This is done for efficiency so the scan only happens
 	// periodically instead of on every orphan added to the pool.
-	if now := time.Now(); now.After(mp.nextExpireScan) {
+	if now := blockchain.Now(); now.After(mp.nextExpireScan) {
 		origNumOrphans := len(mp.orphans)
 		for _, otx := range mp.orphans {
 			if now.After(otx.expiration) {
@@ -333,7 +652,7 @@ func (mp *TxPool) addOrphan(tx *eacutil.Tx, tag Tag) {
 	mp.orphans[*tx.Hash()] = &orphanTx{
 		tx:         tx,
 		tag:        tag,
-		expiration: time.Now().Add(orphanTTL),
+		expiration: blockchain.Now().Add(orphanTTL),
 	}
 	for _, txIn := range tx.MsgTx().TxIn {
 		if _, exists := mp.orphansByPrev[txIn.PreviousOutPoint]; !exists {
@@ -466,14 +785,23 @@ func (mp *TxPool) HaveTransaction(hash *chainhash.Hash) bool {
 // RemoveTransaction.  See the comment for RemoveTransaction for more details.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) removeTransaction(tx *eacutil.Tx, removeRedeemers bool) {
+func (mp *TxPool) removeTransaction(tx *eacutil.Tx, removeRedeemers bool,
+	reason RemovalReason) {
+
 	txHash := tx.Hash()
+
+	// The fee delta of a transaction is no longer needed once it is
+	// included in a block, regardless of whether it was in the pool.
+	if reason == RemovalReasonBlock {
+		delete(mp.feeDeltas, *txHash)
+	}
+
 	if removeRedeemers {
 		// Remove any transactions which rely on this one.
 		for i := uint32(0); i < uint32(len(tx.MsgTx().TxOut)); i++ {
 			prevOut := wire.OutPoint{Hash: *txHash, Index: i}
 			if txRedeemer, exists := mp.outpoints[prevOut]; exists {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true, reason)
 			}
 		}
 	}
@@ -486,25 +814,94 @@ func (mp *TxPool) removeTransaction(tx *eacutil.Tx, removeRedeemers bool) {
 			mp.cfg.AddrIndex.RemoveUnconfirmedTx(txHash)
 		}
 
-		// Mark the referenced outpoints as unspent by the pool.
+		// Determine the transactions whose packages change before the
+		// transaction is removed.
+		ancestors := mp.txAncestors(tx, nil)
+		descendants := mp.txDescendants(tx, nil)
+
+		// Mark the referenced outpoints as unspent by the pool and
+		// forget their double spends.
 		for _, txIn := range txDesc.Tx.MsgTx().TxIn {
 			delete(mp.outpoints, txIn.PreviousOutPoint)
+			mp.removeDoubleSpendsOf(txIn.PreviousOutPoint)
 		}
 		delete(mp.pool, *txHash)
-		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+		heap.Remove(&mp.evictionHeap, txDesc.evictionIndex)
+		mp.poolSize -= int64(txDesc.Tx.MsgTx().SerializeSize())
+		mp.updatePackages(ancestors, descendants)
+		atomic.StoreInt64(&mp.lastUpdated, blockchain.Now().Unix())
+
+		// Transactions which leave the pool without being included in
+		// a block count as not confirmed for fee estimation.
+		if mp.cfg.FeeEstimator != nil && reason != RemovalReasonBlock {
+			mp.cfg.FeeEstimator.RemoveTransaction(txHash)
+		}
+
+		if mp.cfg.NotifyTxRemoved != nil {
+			mp.cfg.NotifyTxRemoved(tx, reason)
+		}
 	}
 }
 
+// PrioritiseTransaction adds the passed fee delta in Satoshi to the one of the
+// transaction with the passed hash.  The fee delta is added to the fee of the
+// transaction when deciding whether to accept, replace or evict it and when
+// selecting transactions for blocks, without changing the fee it pays.  It can
+// be set before the transaction is known and is kept until the transaction is
+// included in a block.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) PrioritiseTransaction(txHash *chainhash.Hash, delta int64) {
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	feeDelta := mp.feeDeltas[*txHash] + delta
+	if feeDelta == 0 {
+		delete(mp.feeDeltas, *txHash)
+	} else {
+		mp.feeDeltas[*txHash] = feeDelta
+	}
+
+	txDesc, exists := mp.pool[*txHash]
+	if !exists {
+		return
+	}
+
+	// The descriptors of the transactions in the pool are shared with the
+	// callers of TxDescs and MiningDescs, so the transaction is given an
+	// updated copy instead of modifying it.  The packages it is part of
+	// are updated for its new fee.
+	updated := *txDesc
+	updated.FeeDelta = feeDelta
+	mp.pool[*txHash] = &updated
+	mp.evictionHeap[updated.evictionIndex] = &updated
+	mp.updateAncestorStats(&updated)
+	mp.updateDescendantStats(&updated)
+	mp.updatePackages(mp.txAncestors(updated.Tx, nil),
+		mp.txDescendants(updated.Tx, nil))
+	atomic.StoreInt64(&mp.lastUpdated, blockchain.Now().Unix())
+
+	if mp.cfg.NotifyTxAdded != nil {
+		mp.cfg.NotifyTxAdded(&updated)
+	}
+
+	log.Debugf("Set fee delta of transaction %v to %d", txHash, feeDelta)
+}
+
 // RemoveTransaction removes the passed transaction from the mempool. When the
 // removeRedeemers flag is set, any transactions that redeem outputs from the
 // removed transaction will also be removed recursively from the mempool, as
-// they would otherwise become orphans.
+// they would otherwise become orphans.  The passed reason is reported for
+// every removed transaction.
 //
 // This function is safe for concurrent access.
-func (mp *TxPool) RemoveTransaction(tx *eacutil.Tx, removeRedeemers bool) {
+func (mp *TxPool) RemoveTransaction(tx *eacutil.Tx, removeRedeemers bool,
+	reason RemovalReason) {
+
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	mp.removeTransaction(tx, removeRedeemers)
+	mp.removeTransaction(tx, removeRedeemers, reason)
 	mp.mtx.Unlock()
 }
 
@@ -521,13 +918,115 @@ func (mp *TxPool) RemoveDoubleSpends(tx *eacutil.Tx) {
 	for _, txIn := range tx.MsgTx().TxIn {
 		if txRedeemer, ok := mp.outpoints[txIn.PreviousOutPoint]; ok {
 			if !txRedeemer.Hash().IsEqual(tx.Hash()) {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true,
+					RemovalReasonConflict)
 			}
 		}
 	}
 	mp.mtx.Unlock()
 }
 
+// ExpireTransactions removes the transactions which have been in the pool for
+// longer than the maximum transaction age along with the transactions which
+// depend on them.  It returns the number of removed transactions.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) ExpireTransactions() int {
+	maxAge := mp.cfg.Policy.MaxTxAge
+	if maxAge <= 0 {
+		return 0
+	}
+
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	expiry := blockchain.Now().Add(-maxAge)
+	var expired []*eacutil.Tx
+	for _, txDesc := range mp.pool {
+		if txDesc.Added.Before(expiry) {
+			expired = append(expired, txDesc.Tx)
+		}
+	}
+
+	numBefore := len(mp.pool)
+	for _, tx := range expired {
+		mp.removeTransaction(tx, true, RemovalReasonExpiry)
+	}
+	numExpired := numBefore - len(mp.pool)
+	if numExpired > 0 {
+		log.Debugf("Expired %d %s older than %v", numExpired,
+			pickNoun(numExpired, "transaction", "transactions"),
+			maxAge)
+	}
+
+	return numExpired
+}
+
+// Revalidate removes the transactions which can no longer be included in the
+// next block along with the transactions which depend on them.  That happens
+// when the main chain is reorganized to a lower height or to blocks confirming
+// the inputs of transactions at different heights, so the lock time of
+// transactions is no longer final, the relative lock times of their inputs are
+// no longer met or the coinbase outputs they spend are no longer mature.  It
+// returns the number of removed transactions.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Revalidate() int {
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	nextBlockHeight := mp.cfg.BestHeight() + 1
+	medianTimePast := mp.cfg.MedianTimePast()
+	var invalid []*eacutil.Tx
+	for txHash, txDesc := range mp.pool {
+		tx := txDesc.Tx
+		if !blockchain.IsFinalizedTransaction(tx, nextBlockHeight,
+			medianTimePast) {
+
+			log.Debugf("Transaction %v is no longer final", txHash)
+			invalid = append(invalid, tx)
+			continue
+		}
+
+		utxoView, err := mp.fetchInputUtxos(tx)
+		if err != nil {
+			log.Errorf("Unable to fetch inputs of transaction %v: %v",
+				txHash, err)
+			continue
+		}
+		sequenceLock, err := mp.cfg.CalcSequenceLock(tx, utxoView)
+		if err == nil && !blockchain.SequenceLockActive(sequenceLock,
+			nextBlockHeight, medianTimePast) {
+
+			err = fmt.Errorf("sequence locks on inputs not met")
+		}
+		if err == nil {
+			_, err = blockchain.CheckTransactionInputs(tx,
+				nextBlockHeight, utxoView, mp.cfg.ChainParams)
+		}
+		if err != nil {
+			log.Debugf("Transaction %v is no longer valid: %v",
+				txHash, err)
+			invalid = append(invalid, tx)
+		}
+	}
+
+	numBefore := len(mp.pool)
+	for _, tx := range invalid {
+		mp.removeTransaction(tx, true, RemovalReasonReorg)
+	}
+	numRemoved := numBefore - len(mp.pool)
+	if numRemoved > 0 {
+		log.Debugf("Removed %d %s invalidated by a reorganization",
+			numRemoved, pickNoun(numRemoved, "transaction",
+				"transactions"))
+	}
+
+	return numRemoved
+}
+
 // addTransaction adds the passed transaction to the memory pool.  It should
 // not be called directly as it doesn't perform any validation.  This is a
 // helper for maybeAcceptTransaction.
@@ -539,19 +1038,32 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *eacutil
 	txD := &TxDesc{
 		TxDesc: mining.TxDesc{
 			Tx:       tx,
-			Added:    time.Now(),
+			Added:    blockchain.Now(),
 			Height:   height,
 			Fee:      fee,
 			FeePerKB: fee * 1000 / GetTxVirtualSize(tx),
+			FeeDelta: mp.feeDeltas[*tx.Hash()],
 		},
 		StartingPriority: mining.CalcPriority(tx.MsgTx(), utxoView, height),
+		evictionIndex:    -1,
 	}
 
 	mp.pool[*tx.Hash()] = txD
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
-	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+	mp.poolSize += int64(tx.MsgTx().SerializeSize())
+	atomic.StoreInt64(&mp.lastUpdated, blockchain.Now().Unix())
+
+	// Track the package of the transaction and add it to the packages of
+	// its ancestors.  Transactions added back to the pool after a reorg
+	// can also already have descendants in the pool.
+	ancestors := mp.txAncestors(tx, nil)
+	descendants := mp.txDescendants(tx, nil)
+	mp.updateAncestorStats(txD)
+	mp.updateDescendantStats(txD)
+	mp.updatePackages(ancestors, descendants)
+	heap.Push(&mp.evictionHeap, txD)
 
 	// Add unconfirmed address index entries associated with the transaction
 	// if enabled.
@@ -564,16 +1076,200 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *eacutil
 		mp.cfg.FeeEstimator.ObserveTransaction(txD)
 	}
 
+	if mp.cfg.NotifyTxAdded != nil {
+		mp.cfg.NotifyTxAdded(txD)
+	}
+	if mp.cfg.NotifyTxAccepted != nil {
+		mp.cfg.NotifyTxAccepted(txD)
+	}
+
 	return txD
 }
 
+// rollingFee returns the minimum fee rate in Satoshi/kB raised by evicting
+// transactions from a full pool after decaying it for the time elapsed since
+// it was last updated.  It decays faster while the pool is less than half full
+// since cheaper transactions are then unlikely to cause evictions again.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) rollingFee() eacutil.Amount {
+	if mp.rollingMinFee == 0 {
+		return 0
+	}
+
+	halfLife := rollingFeeHalfLife
+	maxSize := mp.cfg.Policy.MaxPoolSize
+	if mp.poolSize < maxSize/4 {
+		halfLife /= 4
+	} else if mp.poolSize < maxSize/2 {
+		halfLife /= 2
+	}
+
+	now := blockchain.Now()
+	elapsed := now.Sub(mp.lastRollingFeeUpdate)
+	mp.rollingMinFee /= math.Pow(2, elapsed.Seconds()/halfLife.Seconds())
+	mp.lastRollingFeeUpdate = now
+
+	// Drop the rolling fee altogether once it is too low to matter.
+	if mp.rollingMinFee < incrementalRelayFee/2 {
+		mp.rollingMinFee = 0
+	}
+
+	return eacutil.Amount(math.Ceil(mp.rollingMinFee))
+}
+
+// MinFee returns the minimum fee rate in Satoshi/kB a transaction must pay to
+// be accepted into the pool.  It is the higher of the configured minimum relay
+// fee and the decaying fee raised by evicting transactions from a full pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MinFee() eacutil.Amount {
+	mp.mtx.Lock()
+	minFee := mp.rollingFee()
+	mp.mtx.Unlock()
+
+	if minFee < mp.cfg.Policy.MinRelayTxFee {
+		return mp.cfg.Policy.MinRelayTxFee
+	}
+	return minFee
+}
+
+// raiseRollingFee raises the rolling minimum fee above the passed fee rate in
+// Satoshi/kB of a package which was evicted from a full pool so transactions
+// paying less aren't accepted only to be evicted again.  It must never be
+// raised from the fee rate of a rejected transaction, which didn't pay anything
+// and could otherwise raise it at no cost.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) raiseRollingFee(feeRate float64) {
+	// Decay the rolling fee before raising it.
+	mp.rollingFee()
+
+	feeRate += incrementalRelayFee
+	if feeRate > mp.rollingMinFee {
+		mp.rollingMinFee = feeRate
+	}
+	mp.lastRollingFeeUpdate = blockchain.Now()
+}
+
+// poolEvictions returns the transactions which have to be evicted along with
+// their descendants to make room in the main pool for the passed transaction
+// with the passed modified fee and virtual size once its conflicts are removed.
+// A transaction is always evicted along with its descendants since they can't
+// be mined without it, so the packages with the lowest descendant fee rates are
+// chosen first.
+//
+// The transaction is compared against those packages by the fee rate of its
+// own package, which is the transaction along with its unconfirmed ancestors in
+// the pool, so a child paying for a cheap parent is accepted as long as the
+// two of them pay more than the packages evicted for it.  The ancestors
+// themselves are never evicted since their package now includes the child.
+// It returns false when the transaction would be evicted instead, since its
+// package doesn't pay more than the cheapest other package, in which case it
+// must not be accepted.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) poolEvictions(tx *eacutil.Tx, fee, size int64,
+	conflicts map[chainhash.Hash]*eacutil.Tx) ([]*TxDesc, bool) {
+
+	maxSize := mp.cfg.Policy.MaxPoolSize
+	newSize := mp.poolSize + int64(tx.MsgTx().SerializeSize())
+	for _, conflict := range conflicts {
+		newSize -= int64(conflict.MsgTx().SerializeSize())
+	}
+	if maxSize <= 0 || newSize <= maxSize {
+		return nil, true
+	}
+
+	// Conflicts are removed anyway, so they don't make room and neither do
+	// the descendants of already chosen transactions.
+	removed := make(map[chainhash.Hash]struct{}, len(conflicts))
+	for hash := range conflicts {
+		removed[hash] = struct{}{}
+	}
+
+	// Calculate the fee rate of the package of the transaction.
+	ancestors := mp.txAncestors(tx, nil)
+	pkgFees, pkgSize := fee, size
+	for hash := range ancestors {
+		if _, ok := removed[hash]; ok {
+			continue
+		}
+		ancestor := mp.pool[hash]
+		pkgFees += ancestor.modifiedFee()
+		pkgSize += GetTxVirtualSize(ancestor.Tx)
+	}
+	pkgFeeRate := float64(pkgFees) * 1000 / float64(pkgSize)
+
+	order := &evictionOrder{heap: mp.evictionHeap}
+	if len(mp.evictionHeap) > 0 {
+		order.positions = append(order.positions, 0)
+	}
+	var evictions []*TxDesc
+	for newSize > maxSize && order.Len() > 0 {
+		txDesc := order.next()
+		txHash := *txDesc.Tx.Hash()
+		if _, ok := removed[txHash]; ok {
+			continue
+		}
+		if _, ok := ancestors[txHash]; ok {
+			continue
+		}
+		if txDesc.descendantFeeRate() >= pkgFeeRate {
+			return nil, false
+		}
+
+		evictions = append(evictions, txDesc)
+		removed[txHash] = struct{}{}
+		newSize -= int64(txDesc.Tx.MsgTx().SerializeSize())
+		for hash, descendant := range mp.txDescendants(txDesc.Tx, nil) {
+			if _, ok := removed[hash]; ok {
+				continue
+			}
+			removed[hash] = struct{}{}
+			newSize -= int64(descendant.MsgTx().SerializeSize())
+		}
+	}
+	return evictions, newSize <= maxSize
+}
+
+// limitPoolSize evicts the passed transactions chosen by poolEvictions along
+// with their descendants from the main pool.  The rolling minimum fee is
+// raised above the fee rate of each evicted package.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) limitPoolSize(evictions []*TxDesc) {
+	if len(evictions) == 0 {
+		return
+	}
+
+	numBefore := len(mp.pool)
+	for _, txDesc := range evictions {
+		// The descendant fee rate can have changed since the
+		// transaction was chosen when its conflicting descendants were
+		// replaced.
+		txDesc, exists := mp.pool[*txDesc.Tx.Hash()]
+		if !exists {
+			continue
+		}
+		mp.raiseRollingFee(txDesc.descendantFeeRate())
+		mp.removeTransaction(txDesc.Tx, true, RemovalReasonSizeLimit)
+	}
+
+	numEvicted := numBefore - len(mp.pool)
+	log.Debugf("Evicted %d %s to limit the pool size to %d bytes "+
+		"(minimum fee rate %.0f sat/kb)", numEvicted,
+		pickNoun(numEvicted, "transaction", "transactions"),
+		mp.cfg.Policy.MaxPoolSize, mp.rollingMinFee)
+}
+
 // checkPoolDoubleSpend checks whether or not the passed transaction is
 // attempting to spend coins already spent by other transactions in the pool.
 // If it does, we'll check whether each of those transactions are signaling for
-// replacement. If just one of them isn't, an error is returned. Otherwise, a
-// boolean is returned signaling that the transaction is a replacement. Note it
-// does not check for double spends against transactions already in the main
-// chain.
+// replacement unless full replace-by-fee is enabled. If just one of them isn't,
+// an error is returned. Otherwise, a boolean is returned signaling that the
+// transaction is a replacement. Note it does not check for double spends
+// against transactions already in the main chain.
 //
 // This function MUST be called with the mempool lock held (for reads).
 func (mp *TxPool) checkPoolDoubleSpend(tx *eacutil.Tx) (bool, error) {
@@ -585,9 +1281,10 @@ func (mp *TxPool) checkPoolDoubleSpend(tx *eacutil.Tx) (bool, error) {
 		}
 
 		// Reject the transaction if we don't accept replacement
-		// transactions or if it doesn't signal replacement.
-		if mp.cfg.Policy.RejectReplacement ||
-			!mp.signalsReplacement(conflict, nil) {
+		// transactions or if it doesn't signal replacement while full
+		// replace-by-fee is disabled.
+		if mp.cfg.Policy.RejectReplacement || (!mp.cfg.Policy.FullRBF &&
+			!mp.signalsReplacement(conflict, nil)) {
 			str := fmt.Sprintf("output %v already spent by "+
 				"transaction %v in the memory pool",
 				txIn.PreviousOutPoint, conflict.Hash())
@@ -741,6 +1438,132 @@ func (mp *TxPool) txDescendants(tx *eacutil.Tx,
 	return descendants
 }
 
+// updateAncestorStats recalculates the number, total virtual size and total
+// fees of the passed transaction along with its unconfirmed ancestors.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) updateAncestorStats(txDesc *TxDesc) {
+	txDesc.ancestorCount = 1
+	txDesc.ancestorSize = GetTxVirtualSize(txDesc.Tx)
+	txDesc.ancestorFees = txDesc.modifiedFee()
+	for hash := range mp.txAncestors(txDesc.Tx, nil) {
+		ancestor := mp.pool[hash]
+		txDesc.ancestorCount++
+		txDesc.ancestorSize += GetTxVirtualSize(ancestor.Tx)
+		txDesc.ancestorFees += ancestor.modifiedFee()
+	}
+}
+
+// updateDescendantStats recalculates the number, total virtual size and total
+// fees of the passed transaction along with the transactions in the pool which
+// depend on it.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) updateDescendantStats(txDesc *TxDesc) {
+	txDesc.descendantCount = 1
+	txDesc.descendantSize = GetTxVirtualSize(txDesc.Tx)
+	txDesc.descendantFees = txDesc.modifiedFee()
+	for hash := range mp.txDescendants(txDesc.Tx, nil) {
+		descendant := mp.pool[hash]
+		txDesc.descendantCount++
+		txDesc.descendantSize += GetTxVirtualSize(descendant.Tx)
+		txDesc.descendantFees += descendant.modifiedFee()
+	}
+
+	// Keep the eviction heap ordered for the new fee rate.
+	if txDesc.evictionIndex >= 0 {
+		heap.Fix(&mp.evictionHeap, txDesc.evictionIndex)
+	}
+}
+
+// updatePackages recalculates the descendant statistics of the passed
+// ancestors and the ancestor statistics of the passed descendants of a
+// transaction which was added to or removed from the pool.  Transactions which
+// are no longer in the pool are skipped.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) updatePackages(ancestors, descendants map[chainhash.Hash]*eacutil.Tx) {
+	for hash := range ancestors {
+		if txDesc, exists := mp.pool[hash]; exists {
+			mp.updateDescendantStats(txDesc)
+		}
+	}
+	for hash := range descendants {
+		if txDesc, exists := mp.pool[hash]; exists {
+			mp.updateAncestorStats(txDesc)
+		}
+	}
+}
+
+// checkPackageLimits ensures accepting the passed transaction with the passed
+// virtual size does not result in a transaction with more unconfirmed
+// ancestors or descendants in the pool than allowed by the policy.  When a
+// package is provided, its transactions count towards the limits as though
+// they were in the pool.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkPackageLimits(tx *eacutil.Tx, txSize int64,
+	pkg *txPackage) error {
+
+	policy := &mp.cfg.Policy
+	ancestors := mp.txAncestors(tx, nil)
+	if pkg != nil {
+		for hash, ancestor := range pkg.txAncestors(tx) {
+			ancestors[hash] = ancestor
+		}
+	}
+	ancestorCount := int64(len(ancestors)) + 1
+	if policy.MaxAncestorCount > 0 && ancestorCount > policy.MaxAncestorCount {
+		str := fmt.Sprintf("transaction %v has too many unconfirmed "+
+			"ancestors: %d > %d", tx.Hash(), ancestorCount,
+			policy.MaxAncestorCount)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
+	ancestorSize := txSize
+	for hash, ancestor := range ancestors {
+		ancestorSize += GetTxVirtualSize(ancestor)
+
+		descendantCount := int64(1)
+		descendantSize := GetTxVirtualSize(ancestor)
+		if txDesc, exists := mp.pool[hash]; exists {
+			descendantCount = txDesc.descendantCount
+			descendantSize = txDesc.descendantSize
+		}
+		if pkg != nil {
+			descendantCount += pkg.descendantCounts[hash]
+			descendantSize += pkg.descendantSizes[hash]
+		}
+
+		if policy.MaxDescendantCount > 0 &&
+			descendantCount+1 > policy.MaxDescendantCount {
+
+			str := fmt.Sprintf("transaction %v exceeds the "+
+				"descendant count limit of %d of unconfirmed "+
+				"ancestor %v", tx.Hash(),
+				policy.MaxDescendantCount, hash)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+		if policy.MaxDescendantSize > 0 &&
+			descendantSize+txSize > policy.MaxDescendantSize {
+
+			str := fmt.Sprintf("transaction %v exceeds the "+
+				"descendant size limit of %d bytes of "+
+				"unconfirmed ancestor %v", tx.Hash(),
+				policy.MaxDescendantSize, hash)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+	}
+	if policy.MaxAncestorSize > 0 && ancestorSize > policy.MaxAncestorSize {
+		str := fmt.Sprintf("transaction %v and its unconfirmed "+
+			"ancestors are too large: %d > %d bytes", tx.Hash(),
+			ancestorSize, policy.MaxAncestorSize)
+		return txRuleError(wire.RejectNonstandard, str)
+	}
+
+	return nil
+}
+
 // txConflicts returns all of the unconfirmed transactions that would become
 // conflicts if we were to accept the given transaction into the mempool. An
 // unconfirmed conflict is known as a transaction that spends an output already
@@ -828,7 +1651,8 @@ func (mp *TxPool) FetchTransaction(txHash *chainhash.Hash) (*eacutil.Tx, error)
 // validateReplacement determines whether a transaction is deemed as a valid
 // replacement of all of its conflicts according to the RBF policy. If it is
 // valid, no error is returned. Otherwise, an error is returned indicating what
-// went wrong.
+// went wrong.  The fees of the transaction and its conflicts include their fee
+// deltas.
 //
 // This function MUST be called with the mempool lock held (for reads).
 func (mp *TxPool) validateReplacement(tx *eacutil.Tx,
@@ -871,15 +1695,16 @@ func (mp *TxPool) validateReplacement(tx *eacutil.Tx,
 		conflictsParents = make(map[chainhash.Hash]struct{})
 	)
 	for hash, conflict := range conflicts {
-		if txFeeRate <= mp.pool[hash].FeePerKB {
+		conflictFee := mp.pool[hash].modifiedFee()
+		conflictFeeRate := conflictFee * 1000 / GetTxVirtualSize(conflict)
+		if txFeeRate <= conflictFeeRate {
 			str := fmt.Sprintf("replacement transaction %v has an "+
 				"insufficient fee rate: needs more than %v, "+
-				"has %v", tx.Hash(), mp.pool[hash].FeePerKB,
-				txFeeRate)
+				"has %v", tx.Hash(), conflictFeeRate, txFeeRate)
 			return nil, txRuleError(wire.RejectInsufficientFee, str)
 		}
 
-		conflictsFee += mp.pool[hash].Fee
+		conflictsFee += conflictFee
 
 		// We'll track each conflict's parents to ensure the replacement
 		// isn't spending any new unconfirmed inputs.
@@ -919,12 +1744,32 @@ func (mp *TxPool) validateReplacement(tx *eacutil.Tx,
 	return conflicts, nil
 }
 
-// maybeAcceptTransaction is the internal function which implements the public
-// MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
-// more details.
+// txValidation holds the results of validating a transaction for acceptance
+// to the pool which are needed to add it.
+type txValidation struct {
+	utxoView   *blockchain.UtxoViewpoint
+	bestHeight int32
+	fee        int64
+	size       int64
+	conflicts  map[chainhash.Hash]*eacutil.Tx
+}
+
+// validateTransaction performs all of the checks maybeAcceptTransaction does
+// to determine whether the passed transaction can be accepted to the pool
+// without modifying the pool.  The only state it changes is the one of the
+// rate limiter when the rate limit flag is set.
+//
+// When a package is provided, the transactions in it are treated as though
+// they were accepted already, so the transaction may spend their outputs and
+// they count towards the ancestor and descendant limits.
+//
+// The returned flag is set when the transaction is rejected because it double
+// spends transactions in the pool which can't be replaced.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejectDupOrphans bool) ([]*chainhash.Hash, *TxDesc, error) {
+func (mp *TxPool) validateTransaction(tx *eacutil.Tx, isNew, rateLimit,
+	rejectDupOrphans bool, pkg *txPackage) ([]*chainhash.Hash, *txValidation, bool, error) {
+
 	txHash := tx.Hash()
 
 	// If a transaction has witness data, and segwit isn't active yet, If
@@ -933,7 +1778,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 	if tx.MsgTx().HasWitness() {
 		segwitActive, err := mp.cfg.IsDeploymentActive(chaincfg.DeploymentSegwit)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, false, err
 		}
 
 		if !segwitActive {
@@ -945,7 +1790,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 			}
 			str := fmt.Sprintf("transaction %v has witness data, "+
 				"but segwit isn't active yet%s", txHash, simnetHint)
-			return nil, nil, txRuleError(wire.RejectNonstandard, str)
+			return nil, nil, false, txRuleError(wire.RejectNonstandard, str)
 		}
 	}
 
@@ -957,7 +1802,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 		mp.isOrphanInPool(txHash)) {
 
 		str := fmt.Sprintf("already have transaction %v", txHash)
-		return nil, nil, txRuleError(wire.RejectDuplicate, str)
+		return nil, nil, false, txRuleError(wire.RejectDuplicate, str)
 	}
 
 	// Perform preliminary sanity checks on the transaction.  This makes
@@ -966,16 +1811,16 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 	err := blockchain.CheckTransactionSanity(tx)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return nil, nil, false, chainRuleError(cerr)
 		}
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
 	// A standalone transaction must not be a coinbase transaction.
 	if blockchain.IsCoinBase(tx) {
 		str := fmt.Sprintf("transaction %v is an individual coinbase",
 			txHash)
-		return nil, nil, txRuleError(wire.RejectInvalid, str)
+		return nil, nil, false, txRuleError(wire.RejectInvalid, str)
 	}
 
 	// Get the current height of the main chain.  A standalone transaction
@@ -1002,7 +1847,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 			}
 			str := fmt.Sprintf("transaction %v is not standard: %v",
 				txHash, err)
-			return nil, nil, txRuleError(rejectCode, str)
+			return nil, nil, false, txRuleError(rejectCode, str)
 		}
 	}
 
@@ -1017,7 +1862,12 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 	// spend data and prevents double spends.
 	isReplacement, err := mp.checkPoolDoubleSpend(tx)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, true, err
+	}
+	if pkg != nil {
+		if err := pkg.checkDoubleSpend(tx); err != nil {
+			return nil, nil, false, err
+		}
 	}
 
 	// Fetch all of the unspent transaction outputs referenced by the inputs
@@ -1027,9 +1877,12 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 	utxoView, err := mp.fetchInputUtxos(tx)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return nil, nil, false, chainRuleError(cerr)
 		}
-		return nil, nil, err
+		return nil, nil, false, err
+	}
+	if pkg != nil {
+		pkg.fetchInputUtxos(tx, utxoView)
 	}
 
 	// Don't allow the transaction if it exists in the main chain and is not
@@ -1039,7 +1892,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 		prevOut.Index = uint32(txOutIdx)
 		entry := utxoView.LookupEntry(prevOut)
 		if entry != nil && !entry.IsSpent() {
-			return nil, nil, txRuleError(wire.RejectDuplicate,
+			return nil, nil, false, txRuleError(wire.RejectDuplicate,
 				"transaction already exists")
 		}
 		utxoView.RemoveEntry(prevOut)
@@ -1061,7 +1914,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 		}
 	}
 	if len(missingParents) > 0 {
-		return missingParents, nil, nil
+		return missingParents, nil, false, nil
 	}
 
 	// Don't allow the transaction into the mempool unless its sequence
@@ -1070,13 +1923,13 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 	sequenceLock, err := mp.cfg.CalcSequenceLock(tx, utxoView)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return nil, nil, false, chainRuleError(cerr)
 		}
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 	if !blockchain.SequenceLockActive(sequenceLock, nextBlockHeight,
 		medianTimePast) {
-		return nil, nil, txRuleError(wire.RejectNonstandard,
+		return nil, nil, false, txRuleError(wire.RejectNonstandard,
 			"transaction's sequence locks on inputs not met")
 	}
 
@@ -1088,9 +1941,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 		utxoView, mp.cfg.ChainParams)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return nil, nil, false, chainRuleError(cerr)
 		}
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 
 	// Don't allow transactions with non-standard inputs if the network
@@ -1107,7 +1960,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 			}
 			str := fmt.Sprintf("transaction %v has a non-standard "+
 				"input: %v", txHash, err)
-			return nil, nil, txRuleError(rejectCode, str)
+			return nil, nil, false, txRuleError(rejectCode, str)
 		}
 	}
 
@@ -1124,14 +1977,14 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 	sigOpCost, err := blockchain.GetSigOpCost(tx, false, utxoView, true, true)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return nil, nil, false, chainRuleError(cerr)
 		}
-		return nil, nil, err
+		return nil, nil, false, err
 	}
 	if sigOpCost > mp.cfg.Policy.MaxSigOpCostPerTx {
 		str := fmt.Sprintf("transaction %v sigop cost is too high: %d > %d",
 			txHash, sigOpCost, mp.cfg.Policy.MaxSigOpCostPerTx)
-		return nil, nil, txRuleError(wire.RejectNonstandard, str)
+		return nil, nil, false, txRuleError(wire.RejectNonstandard, str)
 	}
 
 	// Don't allow transactions with fees too low to get into a mined block.
@@ -1145,35 +1998,54 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 	// which is more desirable.  Therefore, as long as the size of the
 	// transaction does not exceeed 1000 less than the reserved space for
 	// high-priority transactions, don't require a fee for it.
+	//
+	// The fee delta of the transaction is included in the fee used to
+	// decide whether it pays enough here and below.
+	modifiedFee := txFee + mp.feeDeltas[*txHash]
 	serializedSize := GetTxVirtualSize(tx)
 	minFee := calcMinRequiredTxRelayFee(serializedSize,
 		mp.cfg.Policy.MinRelayTxFee)
-	if serializedSize >= (DefaultBlockPrioritySize-1000) && txFee < minFee {
+	if serializedSize >= (DefaultBlockPrioritySize-1000) && modifiedFee < minFee {
 		str := fmt.Sprintf("transaction %v has %d fees which is under "+
-			"the required amount of %d", txHash, txFee,
+			"the required amount of %d", txHash, modifiedFee,
 			minFee)
-		return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+		return nil, nil, false, txRuleError(wire.RejectInsufficientFee, str)
 	}
 
 	// Require that free transactions have sufficient priority to be mined
 	// in the next block.  Transactions which are being added back to the
 	// memory pool from blocks that have been disconnected during a reorg
 	// are exempted.
-	if isNew && !mp.cfg.Policy.DisableRelayPriority && txFee < minFee {
+	if isNew && !mp.cfg.Policy.DisableRelayPriority && modifiedFee < minFee {
 		currentPriority := mining.CalcPriority(tx.MsgTx(), utxoView,
 			nextBlockHeight)
 		if currentPriority <= mining.MinHighPriority {
 			str := fmt.Sprintf("transaction %v has insufficient "+
 				"priority (%g <= %g)", txHash,
 				currentPriority, mining.MinHighPriority)
-			return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+			return nil, nil, false, txRuleError(wire.RejectInsufficientFee, str)
+		}
+	}
+
+	// Don't allow new transactions paying less than the minimum fee raised
+	// by evicting transactions from a full pool since they would be the
+	// first to be evicted again.  Transactions which are being added back
+	// to the memory pool from blocks that have been disconnected during a
+	// reorg are exempted.
+	if rollingFee := mp.rollingFee(); isNew && rollingFee > 0 {
+		poolMinFee := calcMinRequiredTxRelayFee(serializedSize, rollingFee)
+		if modifiedFee < poolMinFee {
+			str := fmt.Sprintf("transaction %v has %d fees which is "+
+				"under the mempool minimum fee of %d", txHash,
+				modifiedFee, poolMinFee)
+			return nil, nil, false, txRuleError(wire.RejectInsufficientFee, str)
 		}
 	}
 
 	// Free-to-relay transactions are rate limited here to prevent
 	// penny-flooding with tiny transactions as a form of attack.
-	if rateLimit && txFee < minFee {
-		nowUnix := time.Now().Unix()
+	if rateLimit && modifiedFee < minFee {
+		nowUnix := blockchain.Now().Unix()
 		// Decay passed data with an exponentially decaying ~10 minute
 		// window - matches bitcoind handling.
 		mp.pennyTotal *= math.Pow(1.0-1.0/600.0,
@@ -1184,7 +2056,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 		if mp.pennyTotal >= mp.cfg.Policy.FreeTxRelayLimit*10*1000 {
 			str := fmt.Sprintf("transaction %v has been rejected "+
 				"by the rate limiter due to low fees", txHash)
-			return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+			return nil, nil, false, txRuleError(wire.RejectInsufficientFee, str)
 		}
 		oldTotal := mp.pennyTotal
 
@@ -1194,13 +2066,20 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 			mp.cfg.Policy.FreeTxRelayLimit*10*1000)
 	}
 
+	// Don't allow the transaction to form too long or too large chains of
+	// unconfirmed transactions in the pool.
+	err = mp.checkPackageLimits(tx, serializedSize, pkg)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
 	// If the transaction has any conflicts and we've made it this far, then
 	// we're processing a potential replacement.
 	var conflicts map[chainhash.Hash]*eacutil.Tx
 	if isReplacement {
-		conflicts, err = mp.validateReplacement(tx, txFee)
+		conflicts, err = mp.validateReplacement(tx, modifiedFee)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, false, err
 		}
 	}
 
@@ -1211,31 +2090,75 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 		mp.cfg.HashCache)
 	if err != nil {
 		if cerr, ok := err.(blockchain.RuleError); ok {
-			return nil, nil, chainRuleError(cerr)
+			return nil, nil, false, chainRuleError(cerr)
 		}
-		return nil, nil, err
+		return nil, nil, false, err
+	}
+
+	return nil, &txValidation{
+		utxoView:   utxoView,
+		bestHeight: bestHeight,
+		fee:        txFee,
+		size:       serializedSize,
+		conflicts:  conflicts,
+	}, false, nil
+}
+
+// maybeAcceptTransaction is the internal function which implements the public
+// MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
+// more details.  The returned flag is set when the transaction is rejected
+// because it double spends transactions in the pool which can't be replaced.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejectDupOrphans bool) ([]*chainhash.Hash, *TxDesc, bool, error) {
+	txHash := tx.Hash()
+	missingParents, v, isDoubleSpend, err := mp.validateTransaction(tx,
+		isNew, rateLimit, rejectDupOrphans, nil)
+	if err != nil || len(missingParents) > 0 {
+		return missingParents, nil, isDoubleSpend, err
+	}
+
+	// Ensure there is room for the transaction before modifying the pool.
+	// The cheapest packages are evicted to make room unless the
+	// transaction would be among them, in which case it is rejected
+	// without replacing any conflicts or raising the rolling minimum fee.
+	evictions, ok := mp.poolEvictions(tx, v.fee+mp.feeDeltas[*txHash],
+		v.size, v.conflicts)
+	if !ok {
+		str := fmt.Sprintf("transaction %v was not accepted because "+
+			"the mempool is full", txHash)
+		return nil, nil, false, txRuleError(wire.RejectInsufficientFee,
+			str)
 	}
 
 	// Now that we've deemed the transaction as valid, we can add it to the
 	// mempool. If it ended up replacing any transactions, we'll remove them
 	// first.
-	for _, conflict := range conflicts {
+	var replaced []*TxDesc
+	for _, conflict := range v.conflicts {
+		conflictDesc := mp.pool[*conflict.Hash()]
+		replaced = append(replaced, conflictDesc)
+
 		log.Debugf("Replacing transaction %v (fee_rate=%v sat/kb) "+
 			"with %v (fee_rate=%v sat/kb)\n", conflict.Hash(),
-			mp.pool[*conflict.Hash()].FeePerKB, tx.Hash(),
-			txFee*1000/serializedSize)
+			conflictDesc.FeePerKB, tx.Hash(), v.fee*1000/v.size)
 
 		// The conflict set should already include the descendants for
 		// each one, so we don't need to remove the redeemers within
 		// this call as they'll be removed eventually.
-		mp.removeTransaction(conflict, false)
+		mp.removeTransaction(conflict, false, RemovalReasonReplaced)
+	}
+	mp.limitPoolSize(evictions)
+	txD := mp.addTransaction(v.utxoView, tx, v.bestHeight, v.fee)
+
+	if len(replaced) > 0 && mp.cfg.NotifyTxReplaced != nil {
+		mp.cfg.NotifyTxReplaced(txD, replaced)
 	}
-	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
 
 	log.Debugf("Accepted transaction %v (pool size: %v)", txHash,
 		len(mp.pool))
 
-	return nil, txD, nil
+	return nil, txD, false, nil
 }
 
 // MaybeAcceptTransaction is the main workhorse for handling insertion of new
@@ -1252,12 +2175,238 @@ func (mp *TxPool) maybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit, rejec
 func (mp *TxPool) MaybeAcceptTransaction(tx *eacutil.Tx, isNew, rateLimit bool) ([]*chainhash.Hash, *TxDesc, error) {
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	hashes, txD, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit, true)
+	hashes, txD, _, err := mp.maybeAcceptTransaction(tx, isNew, rateLimit,
+		true)
 	mp.mtx.Unlock()
 
 	return hashes, txD, err
 }
 
+// txPackage tracks the transactions of a package which would be accepted by
+// TestMempoolAccept so the transactions following them in the package are
+// validated as though they had been accepted to the pool.
+type txPackage struct {
+	// txns holds the transactions of the package which would be accepted.
+	txns map[chainhash.Hash]*eacutil.Tx
+
+	// ancestors holds the unconfirmed ancestors in the pool and in the
+	// package of each transaction of the package.
+	ancestors map[chainhash.Hash]map[chainhash.Hash]*eacutil.Tx
+
+	// spent holds the outputs spent by the transactions of the package.
+	spent map[wire.OutPoint]*eacutil.Tx
+
+	// replaced holds the transactions in the pool which would be replaced
+	// by the transactions of the package.
+	replaced map[chainhash.Hash]struct{}
+
+	// descendantCounts and descendantSizes hold the number and total
+	// virtual size of the descendants unconfirmed transactions would gain
+	// from the package.
+	descendantCounts map[chainhash.Hash]int64
+	descendantSizes  map[chainhash.Hash]int64
+}
+
+// newTxPackage returns a new empty package.
+func newTxPackage() *txPackage {
+	return &txPackage{
+		txns:             make(map[chainhash.Hash]*eacutil.Tx),
+		ancestors:        make(map[chainhash.Hash]map[chainhash.Hash]*eacutil.Tx),
+		spent:            make(map[wire.OutPoint]*eacutil.Tx),
+		replaced:         make(map[chainhash.Hash]struct{}),
+		descendantCounts: make(map[chainhash.Hash]int64),
+		descendantSizes:  make(map[chainhash.Hash]int64),
+	}
+}
+
+// txAncestors returns the transactions of the package the passed transaction
+// spends along with all of their unconfirmed ancestors in the pool and in the
+// package.
+func (pkg *txPackage) txAncestors(tx *eacutil.Tx) map[chainhash.Hash]*eacutil.Tx {
+	ancestors := make(map[chainhash.Hash]*eacutil.Tx)
+	for _, txIn := range tx.MsgTx().TxIn {
+		parentHash := txIn.PreviousOutPoint.Hash
+		parent, ok := pkg.txns[parentHash]
+		if !ok {
+			continue
+		}
+		ancestors[parentHash] = parent
+		for hash, ancestor := range pkg.ancestors[parentHash] {
+			ancestors[hash] = ancestor
+		}
+	}
+
+	return ancestors
+}
+
+// checkDoubleSpend ensures the passed transaction does not spend any outputs
+// already spent by the transactions of the package.
+func (pkg *txPackage) checkDoubleSpend(tx *eacutil.Tx) error {
+	for _, txIn := range tx.MsgTx().TxIn {
+		spender, ok := pkg.spent[txIn.PreviousOutPoint]
+		if !ok {
+			continue
+		}
+
+		str := fmt.Sprintf("output %v already spent by transaction %v "+
+			"in the package", txIn.PreviousOutPoint, spender.Hash())
+		return txRuleError(wire.RejectDuplicate, str)
+	}
+
+	return nil
+}
+
+// fetchInputUtxos adjusts the passed view of the outputs spent by the passed
+// transaction based upon the contents of the package.  Outputs of the
+// transactions of the package are added to it and outputs of the transactions
+// they replace are marked spent.
+func (pkg *txPackage) fetchInputUtxos(tx *eacutil.Tx,
+	utxoView *blockchain.UtxoViewpoint) {
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := txIn.PreviousOutPoint
+		entry := utxoView.LookupEntry(prevOut)
+		if _, ok := pkg.replaced[prevOut.Hash]; ok && entry != nil {
+			entry.Spend()
+			continue
+		}
+		if entry != nil && !entry.IsSpent() {
+			continue
+		}
+
+		if pkgTx, ok := pkg.txns[prevOut.Hash]; ok {
+			// AddTxOut ignores out of range index values, so it is
+			// safe to call without bounds checking here.
+			utxoView.AddTxOut(pkgTx, prevOut.Index,
+				mining.UnminedHeight)
+		}
+	}
+}
+
+// add adds the passed transaction, which has the passed unconfirmed ancestors
+// in the pool and replaces the passed transactions, to the package.
+func (pkg *txPackage) add(tx *eacutil.Tx,
+	poolAncestors, conflicts map[chainhash.Hash]*eacutil.Tx) {
+
+	txHash := *tx.Hash()
+	ancestors := pkg.txAncestors(tx)
+	for hash, ancestor := range poolAncestors {
+		ancestors[hash] = ancestor
+	}
+	pkg.txns[txHash] = tx
+	pkg.ancestors[txHash] = ancestors
+
+	txSize := GetTxVirtualSize(tx)
+	for hash := range ancestors {
+		pkg.descendantCounts[hash]++
+		pkg.descendantSizes[hash] += txSize
+	}
+	for _, txIn := range tx.MsgTx().TxIn {
+		pkg.spent[txIn.PreviousOutPoint] = tx
+	}
+	for hash := range conflicts {
+		pkg.replaced[hash] = struct{}{}
+	}
+}
+
+// TestAcceptResult describes whether a transaction passed to TestMempoolAccept
+// would be accepted to the pool.
+type TestAcceptResult struct {
+	// Tx is the transaction the result is for.
+	Tx *eacutil.Tx
+
+	// Fee is the fee the transaction pays and VSize is its virtual size.
+	// They are only set when the transaction would be accepted.
+	Fee   int64
+	VSize int64
+
+	// Conflicts holds the hashes of the transactions in the pool which
+	// would be replaced by the transaction.
+	Conflicts []*chainhash.Hash
+
+	// Err is the reason the transaction would be rejected.  It is nil when
+	// the transaction would be accepted.
+	Err error
+}
+
+// TestMempoolAccept determines whether the passed transactions would be
+// accepted to the pool by performing the same checks as ProcessTransaction
+// without modifying the pool.
+//
+// The transactions form an ordered package in which each transaction may spend
+// the outputs of the transactions before it.  Each transaction is validated as
+// though the transactions before it which would be accepted had been accepted,
+// so transactions spending outputs of rejected or unknown transactions are
+// rejected for missing inputs.  The rolling minimum fee and the size limit of
+// the pool are checked against the pool as it is, so the transactions before a
+// transaction in the package don't count towards the size limit, and the
+// rolling minimum fee is never raised.  The results are in the same order as
+// the transactions.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) TestMempoolAccept(txns []*eacutil.Tx) []*TestAcceptResult {
+	// Protect concurrent access.
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	pkg := newTxPackage()
+	results := make([]*TestAcceptResult, 0, len(txns))
+	for _, tx := range txns {
+		result := &TestAcceptResult{Tx: tx}
+		results = append(results, result)
+
+		if _, ok := pkg.txns[*tx.Hash()]; ok {
+			str := fmt.Sprintf("transaction %v is already in the "+
+				"package", tx.Hash())
+			result.Err = txRuleError(wire.RejectDuplicate, str)
+			continue
+		}
+
+		missingParents, v, _, err := mp.validateTransaction(tx, true,
+			false, true, pkg)
+		if err == nil && len(missingParents) > 0 {
+			str := fmt.Sprintf("orphan transaction %v has missing "+
+				"inputs: it references outputs of unknown or "+
+				"fully-spent transaction %v", tx.Hash(),
+				missingParents[0])
+			err = chainRuleError(blockchain.RuleError{
+				ErrorCode:   blockchain.ErrMissingTxOut,
+				Description: str,
+			})
+		}
+		if err != nil {
+			result.Err = err
+			continue
+		}
+
+		// Ensure there is room for the transaction in the pool as it
+		// is now, like maybeAcceptTransaction does.
+		fee := v.fee + mp.feeDeltas[*tx.Hash()]
+		_, ok := mp.poolEvictions(tx, fee, v.size, v.conflicts)
+		if !ok {
+			str := fmt.Sprintf("transaction %v was not accepted "+
+				"because the mempool is full", tx.Hash())
+			result.Err = txRuleError(wire.RejectInsufficientFee, str)
+			continue
+		}
+
+		result.Fee = v.fee
+		result.VSize = v.size
+		for hash := range v.conflicts {
+			hash := hash
+			result.Conflicts = append(result.Conflicts, &hash)
+		}
+		sort.Slice(result.Conflicts, func(i, j int) bool {
+			return result.Conflicts[i].String() <
+				result.Conflicts[j].String()
+		})
+
+		pkg.add(tx, mp.txAncestors(tx, nil), v.conflicts)
+	}
+
+	return results
+}
+
 // processOrphans is the internal function which implements the public
 // ProcessOrphans.  See the comment for ProcessOrphans for more details.
 //
@@ -1294,7 +2443,7 @@ func (mp *TxPool) processOrphans(acceptedTx *eacutil.Tx) []*TxDesc {
 
 			// Potentially accept an orphan into the tx pool.
 			for _, tx := range orphans {
-				missing, txD, err := mp.maybeAcceptTransaction(
+				missing, txD, _, err := mp.maybeAcceptTransaction(
 					tx, true, true, false)
 				if err != nil {
 					// The orphan is now invalid, so there
@@ -1375,13 +2524,39 @@ func (mp *TxPool) ProcessTransaction(tx *eacutil.Tx, allowOrphan, rateLimit bool
 
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	defer mp.mtx.Unlock()
+	acceptedTxs, ds, err := mp.processTransaction(tx, allowOrphan,
+		rateLimit, tag)
+	mp.mtx.Unlock()
 
+	// Double spends are only announced once the lock is released since
+	// relaying them may block on other subsystems which use the mempool.
+	if ds != nil && mp.cfg.NotifyDoubleSpend != nil {
+		mp.cfg.NotifyDoubleSpend(ds)
+	}
+
+	return acceptedTxs, err
+}
+
+// processTransaction is the internal function which implements the public
+// ProcessTransaction.  See the comment for ProcessTransaction for more details.
+// The rejected transaction is returned as a double spend when it was recorded
+// as one.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) processTransaction(tx *eacutil.Tx, allowOrphan, rateLimit bool, tag Tag) ([]*TxDesc, *DoubleSpend, error) {
 	// Potentially accept the transaction to the memory pool.
-	missingParents, txD, err := mp.maybeAcceptTransaction(tx, true, rateLimit,
-		true)
+	missingParents, txD, isDoubleSpend, err := mp.maybeAcceptTransaction(tx,
+		true, rateLimit, true)
 	if err != nil {
-		return nil, err
+		// Record rejected transactions which double spend transactions
+		// in the pool.  Only transactions rejected because the outputs
+		// they spend are already spent by transactions which can't be
+		// replaced are double spends, so failed replacements and
+		// transactions rejected for other reasons are not recorded.
+		if isDoubleSpend {
+			return nil, mp.maybeAddDoubleSpend(tx, tag), err
+		}
+		return nil, nil, err
 	}
 
 	if len(missingParents) == 0 {
@@ -1397,7 +2572,7 @@ func (mp *TxPool) ProcessTransaction(tx *eacutil.Tx, allowOrphan, rateLimit bool
 		acceptedTxs[0] = txD
 		copy(acceptedTxs[1:], newTxs)
 
-		return acceptedTxs, nil
+		return acceptedTxs, nil, nil
 	}
 
 	// The transaction is an orphan (has inputs missing).  Reject
@@ -1415,12 +2590,12 @@ func (mp *TxPool) ProcessTransaction(tx *eacutil.Tx, allowOrphan, rateLimit bool
 		str := fmt.Sprintf("orphan transaction %v references "+
 			"outputs of unknown or fully-spent "+
 			"transaction %v", tx.Hash(), missingParents[0])
-		return nil, txRuleError(wire.RejectDuplicate, str)
+		return nil, nil, txRuleError(wire.RejectDuplicate, str)
 	}
 
 	// Potentially add the orphan transaction to the orphan pool.
 	err = mp.maybeAddOrphan(tx, tag)
-	return nil, err
+	return nil, nil, err
 }
 
 // Count returns the number of transactions in the main pool.  It does not
@@ -1517,6 +2692,7 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 			Vsize:            int32(GetTxVirtualSize(tx)),
 			Weight:           int32(blockchain.GetTransactionWeight(tx)),
 			Fee:              eacutil.Amount(desc.Fee).ToBTC(),
+			ModifiedFee:      eacutil.Amount(desc.modifiedFee()).ToBTC(),
 			Time:             desc.Added.Unix(),
 			Height:           int64(desc.Height),
 			StartingPriority: desc.StartingPriority,
@@ -1537,6 +2713,133 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 	return result
 }
 
+// mempoolEntry returns a verbose description of the passed transaction in the
+// pool along with the statistics of its unconfirmed ancestors and descendants.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) mempoolEntry(desc *TxDesc) *btcjson.GetMempoolEntryResult {
+	tx := desc.Tx
+	modifiedFee := desc.modifiedFee()
+	entry := &btcjson.GetMempoolEntryResult{
+		VSize:           int32(GetTxVirtualSize(tx)),
+		Size:            int32(tx.MsgTx().SerializeSize()),
+		Weight:          blockchain.GetTransactionWeight(tx),
+		Fee:             eacutil.Amount(desc.Fee).ToBTC(),
+		ModifiedFee:     eacutil.Amount(modifiedFee).ToBTC(),
+		Time:            desc.Added.Unix(),
+		Height:          int64(desc.Height),
+		DescendantCount: desc.descendantCount,
+		DescendantSize:  desc.descendantSize,
+		DescendantFees:  float64(desc.descendantFees),
+		AncestorCount:   desc.ancestorCount,
+		AncestorSize:    desc.ancestorSize,
+		AncestorFees:    float64(desc.ancestorFees),
+		WTxId:           tx.WitnessHash().String(),
+		Fees: btcjson.MempoolFees{
+			Base:       eacutil.Amount(desc.Fee).ToBTC(),
+			Modified:   eacutil.Amount(modifiedFee).ToBTC(),
+			Ancestor:   eacutil.Amount(desc.ancestorFees).ToBTC(),
+			Descendant: eacutil.Amount(desc.descendantFees).ToBTC(),
+		},
+		Depends:           make([]string, 0),
+		SpentBy:           make([]string, 0),
+		BIP125Replaceable: mp.signalsReplacement(tx, nil),
+	}
+
+	// Only the transactions directly spent by or spending the transaction
+	// are listed, once each, regardless of how many outputs they spend.
+	depends := make(map[chainhash.Hash]struct{})
+	for _, txIn := range tx.MsgTx().TxIn {
+		hash := txIn.PreviousOutPoint.Hash
+		if _, ok := mp.pool[hash]; ok {
+			depends[hash] = struct{}{}
+		}
+	}
+	for hash := range depends {
+		entry.Depends = append(entry.Depends, hash.String())
+	}
+	sort.Strings(entry.Depends)
+
+	spentBy := make(map[chainhash.Hash]struct{})
+	op := wire.OutPoint{Hash: *tx.Hash()}
+	for i := range tx.MsgTx().TxOut {
+		op.Index = uint32(i)
+		if spender, ok := mp.outpoints[op]; ok {
+			spentBy[*spender.Hash()] = struct{}{}
+		}
+	}
+	for hash := range spentBy {
+		entry.SpentBy = append(entry.SpentBy, hash.String())
+	}
+	sort.Strings(entry.SpentBy)
+
+	return entry
+}
+
+// MempoolEntry returns a verbose description of the transaction with the
+// passed hash in the pool as used by the getmempoolentry RPC.  An error is
+// returned when the transaction is not in the main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolEntry(txHash *chainhash.Hash) (*btcjson.GetMempoolEntryResult, error) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	desc, exists := mp.pool[*txHash]
+	if !exists {
+		return nil, fmt.Errorf("transaction is not in the pool")
+	}
+
+	return mp.mempoolEntry(desc), nil
+}
+
+// MempoolAncestors returns verbose descriptions of all of the unconfirmed
+// ancestors of the transaction with the passed hash keyed by their hashes.  An
+// error is returned when the transaction is not in the main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolAncestors(txHash *chainhash.Hash) (map[string]*btcjson.GetMempoolEntryResult, error) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	desc, exists := mp.pool[*txHash]
+	if !exists {
+		return nil, fmt.Errorf("transaction is not in the pool")
+	}
+
+	ancestors := mp.txAncestors(desc.Tx, nil)
+	result := make(map[string]*btcjson.GetMempoolEntryResult, len(ancestors))
+	for hash := range ancestors {
+		result[hash.String()] = mp.mempoolEntry(mp.pool[hash])
+	}
+
+	return result, nil
+}
+
+// MempoolDescendants returns verbose descriptions of all of the unconfirmed
+// descendants of the transaction with the passed hash keyed by their hashes.
+// An error is returned when the transaction is not in the main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolDescendants(txHash *chainhash.Hash) (map[string]*btcjson.GetMempoolEntryResult, error) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	desc, exists := mp.pool[*txHash]
+	if !exists {
+		return nil, fmt.Errorf("transaction is not in the pool")
+	}
+
+	descendants := mp.txDescendants(desc.Tx, nil)
+	result := make(map[string]*btcjson.GetMempoolEntryResult,
+		len(descendants))
+	for hash := range descendants {
+		result[hash.String()] = mp.mempoolEntry(mp.pool[hash])
+	}
+
+	return result, nil
+}
+
 // LastUpdated returns the last time a transaction was added to or removed from
 // the main pool.  It does not include the orphan pool.
 //
@@ -1553,7 +2856,11 @@ func New(cfg *Config) *TxPool {
 		pool:           make(map[chainhash.Hash]*TxDesc),
 		orphans:        make(map[chainhash.Hash]*orphanTx),
 		orphansByPrev:  make(map[wire.OutPoint]map[chainhash.Hash]*eacutil.Tx),
-		nextExpireScan: time.Now().Add(orphanExpireScanInterval),
+		nextExpireScan: blockchain.Now().Add(orphanExpireScanInterval),
 		outpoints:      make(map[wire.OutPoint]*eacutil.Tx),
+		feeDeltas:      make(map[chainhash.Hash]int64),
+		doubleSpends:   make(map[chainhash.Hash]*DoubleSpend),
+		doubleSpentOutpoints: make(
+			map[wire.OutPoint]map[chainhash.Hash]*DoubleSpend),
 	}
 }
@@ -6,7 +6,9 @@ package mempool
 
 import (
 	"encoding/hex"
+	"math"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
@@ -1808,3 +1810,984 @@ func TestRBF(t *testing.T) {
 		}
 	}
 }
+
+// TestPoolSizeLimit ensures the transaction packages with the lowest fee rates
+// are evicted when the pool exceeds its maximum size and that the minimum fee
+// is raised and decays afterwards.
+func TestPoolSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	// Create a cheap transaction along with a cheap parent whose child
+	// pays enough for both of them.
+	coinbase := ctx.addCoinbaseTx(4)
+	cheapOut := txOutToSpendableOut(coinbase, 0)
+	cheap := ctx.addSignedTx([]spendableOutput{cheapOut}, 1, 1000, false,
+		false)
+	parentOut := txOutToSpendableOut(coinbase, 1)
+	parent := ctx.addSignedTx([]spendableOutput{parentOut}, 1, 1000, false,
+		false)
+	childOut := txOutToSpendableOut(parent, 0)
+	child := ctx.addSignedTx([]spendableOutput{childOut}, 1, 100000, false,
+		false)
+	if txPool.MinFee() != 0 {
+		t.Fatalf("got minimum fee %v before the pool was full",
+			txPool.MinFee())
+	}
+
+	// Fill the pool so the next transaction evicts the cheap one, but not
+	// the parent which has a high fee rate along with its child.  The
+	// slack allows for signatures of different sizes.
+	txPool.cfg.Policy.MaxPoolSize = txPool.poolSize + 10
+	midOut := txOutToSpendableOut(coinbase, 2)
+	mid := ctx.addSignedTx([]spendableOutput{midOut}, 1, 10000, true, false)
+	testPoolMembership(ctx, cheap, false, false)
+	for _, tx := range []*eacutil.Tx{parent, child, mid} {
+		testPoolMembership(ctx, tx, false, true)
+	}
+	cheapRate := 1000 * 1000 / float64(GetTxVirtualSize(cheap))
+	wantMinFee := eacutil.Amount(math.Ceil(cheapRate + incrementalRelayFee))
+	if minFee := txPool.MinFee(); minFee != wantMinFee {
+		t.Fatalf("got minimum fee %v, want %v", minFee, wantMinFee)
+	}
+
+	// Transactions paying less than the raised minimum fee are rejected.
+	lastOut := txOutToSpendableOut(coinbase, 3)
+	tx, err := harness.CreateSignedTx([]spendableOutput{lastOut}, 1, 1000,
+		false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = txPool.ProcessTransaction(tx, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "mempool minimum fee") {
+		t.Fatalf("got error %v, want mempool minimum fee error", err)
+	}
+
+	// A transaction paying the minimum fee is still rejected when it is
+	// the cheapest one in the full pool without raising the minimum fee
+	// since it didn't pay anything.
+	tx, err = harness.CreateSignedTx([]spendableOutput{lastOut}, 1, 3000,
+		false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = txPool.ProcessTransaction(tx, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "mempool is full") {
+		t.Fatalf("got error %v, want mempool full error", err)
+	}
+	testPoolMembership(ctx, tx, false, false)
+	for _, tx := range []*eacutil.Tx{parent, child, mid} {
+		testPoolMembership(ctx, tx, false, true)
+	}
+	minFee := txPool.MinFee()
+	if minFee != wantMinFee {
+		t.Fatalf("minimum fee %v was changed from %v by a rejected "+
+			"transaction", minFee, wantMinFee)
+	}
+
+	// A replacement which pays enough to replace its conflict but would
+	// still be the cheapest package in the pool is rejected before its
+	// conflict is removed and without being announced.
+	var notified []*eacutil.Tx
+	txPool.cfg.NotifyTxAdded = func(txDesc *TxDesc) {
+		notified = append(notified, txDesc.Tx)
+	}
+	tx, err = harness.CreateSignedTx([]spendableOutput{midOut}, 50,
+		200000, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = txPool.ProcessTransaction(tx, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "mempool is full") {
+		t.Fatalf("got error %v, want mempool full error", err)
+	}
+	testPoolMembership(ctx, tx, false, false)
+	for _, tx := range []*eacutil.Tx{parent, child, mid} {
+		testPoolMembership(ctx, tx, false, true)
+	}
+	if len(notified) != 0 {
+		t.Fatalf("rejected transaction was announced")
+	}
+	txPool.cfg.NotifyTxAdded = nil
+	if fee := txPool.MinFee(); fee != minFee {
+		t.Fatalf("minimum fee %v was changed from %v by a rejected "+
+			"replacement", fee, minFee)
+	}
+
+	// The eviction heap holds every transaction in the pool in order.
+	txPool.mtx.RLock()
+	if len(txPool.evictionHeap) != len(txPool.pool) {
+		t.Fatalf("eviction heap has %d transactions, pool has %d",
+			len(txPool.evictionHeap), len(txPool.pool))
+	}
+	for i, txDesc := range txPool.evictionHeap {
+		if txDesc.evictionIndex != i ||
+			txPool.pool[*txDesc.Tx.Hash()] != txDesc {
+
+			t.Fatalf("eviction heap entry %d is out of date", i)
+		}
+		if i > 0 && txPool.evictionHeap.Less(i, (i-1)/2) {
+			t.Fatalf("eviction heap is not ordered at %d", i)
+		}
+	}
+	txPool.mtx.RUnlock()
+
+	// The minimum fee halves after the half-life of a full pool.
+	txPool.mtx.Lock()
+	txPool.lastRollingFeeUpdate = time.Now().Add(-rollingFeeHalfLife)
+	txPool.mtx.Unlock()
+	decayed := txPool.MinFee()
+	if decayed < minFee/2-1 || decayed > minFee/2+1 {
+		t.Fatalf("got decayed minimum fee %v, want %v", decayed,
+			minFee/2)
+	}
+}
+
+// TestRejectedChildMinFee ensures a transaction rejected from a full pool
+// because it spends the cheapest transaction in the pool doesn't raise the
+// minimum fee to its own fee rate, which would let anyone raise it for free.
+func TestRejectedChildMinFee(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	// Fill the pool with a single cheap transaction so the only package
+	// which could be evicted to make room is the parent of the child.
+	coinbase := ctx.addCoinbaseTx(1)
+	parent := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 1, 1000, false, false)
+	txPool.cfg.Policy.MaxPoolSize = txPool.poolSize + 10
+	minFee := txPool.MinFee()
+
+	child, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 1000000, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = txPool.ProcessTransaction(child, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "mempool is full") {
+		t.Fatalf("got error %v, want mempool full error", err)
+	}
+	testPoolMembership(ctx, child, false, false)
+	testPoolMembership(ctx, parent, false, true)
+	if fee := txPool.MinFee(); fee != minFee {
+		t.Fatalf("minimum fee %v was changed from %v by a rejected "+
+			"transaction", fee, minFee)
+	}
+}
+
+// TestPoolSizeLimitCPFP ensures a child paying for its cheap parent in a full
+// pool is judged by the fee rate of the two of them, so it is accepted in place
+// of other cheap packages and its parent is kept.
+func TestPoolSizeLimitCPFP(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	// Fill the pool with a cheap parent, which is the cheapest package,
+	// and a slightly more expensive unrelated transaction.  The slack
+	// allows for signatures of different sizes.
+	coinbase := ctx.addCoinbaseTx(2)
+	parent := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 1, 1000, false, false)
+	other := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 1),
+	}, 1, 3000, false, false)
+	txPool.cfg.Policy.MaxPoolSize = txPool.poolSize + 10
+	minFee := txPool.MinFee()
+
+	// A child whose package with its parent pays less than the other
+	// transaction is rejected without evicting anything.
+	createChild := func(fee eacutil.Amount) *eacutil.Tx {
+		t.Helper()
+		child, err := harness.CreateSignedTx([]spendableOutput{
+			txOutToSpendableOut(parent, 0),
+		}, 1, fee, false)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		return child
+	}
+	child := createChild(2000)
+	_, err = txPool.ProcessTransaction(child, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "mempool is full") {
+		t.Fatalf("got error %v, want mempool full error", err)
+	}
+	for _, tx := range []*eacutil.Tx{parent, other} {
+		testPoolMembership(ctx, tx, false, true)
+	}
+	if fee := txPool.MinFee(); fee != minFee {
+		t.Fatalf("minimum fee %v was changed from %v by a rejected "+
+			"transaction", fee, minFee)
+	}
+
+	// A child paying enough for both of them evicts the other
+	// transaction instead of its parent.
+	child = createChild(20000)
+	_, err = txPool.ProcessTransaction(child, false, false, 0)
+	if err != nil {
+		t.Fatalf("unable to process child paying for its parent: %v",
+			err)
+	}
+	testPoolMembership(ctx, parent, false, true)
+	testPoolMembership(ctx, child, false, true)
+	testPoolMembership(ctx, other, false, false)
+	otherRate := 3000 * 1000 / float64(GetTxVirtualSize(other))
+	wantMinFee := eacutil.Amount(math.Ceil(otherRate + incrementalRelayFee))
+	if fee := txPool.MinFee(); fee != wantMinFee {
+		t.Fatalf("got minimum fee %v, want %v", fee, wantMinFee)
+	}
+}
+
+// TestPackageLimits ensures the ancestor and descendant limits of transactions
+// are enforced and that the statistics they are based on are kept up to date
+// as transactions are added to and removed from the pool.
+func TestPackageLimits(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+	txPool.cfg.Policy.MaxAncestorCount = 3
+	txPool.cfg.Policy.MaxDescendantCount = 3
+
+	// Create a chain of transactions as long as the ancestor limit allows.
+	coinbase := ctx.addCoinbaseTx(4)
+	chain := make([]*eacutil.Tx, 0, 3)
+	prevOut := txOutToSpendableOut(coinbase, 0)
+	for i := 0; i < 3; i++ {
+		tx := ctx.addSignedTx([]spendableOutput{prevOut}, 2, 1000,
+			false, false)
+		chain = append(chain, tx)
+		prevOut = txOutToSpendableOut(tx, 0)
+	}
+
+	txDesc := func(tx *eacutil.Tx) *TxDesc {
+		return txPool.pool[*tx.Hash()]
+	}
+	checkStats := func(tx *eacutil.Tx, ancestors, descendants int64) {
+		t.Helper()
+		desc := txDesc(tx)
+		if desc.ancestorCount != ancestors ||
+			desc.descendantCount != descendants {
+
+			t.Fatalf("tx %v: got %d ancestors and %d descendants, "+
+				"want %d and %d", tx.Hash(), desc.ancestorCount,
+				desc.descendantCount, ancestors, descendants)
+		}
+		if desc.ancestorFees != ancestors*1000 ||
+			desc.descendantFees != descendants*1000 {
+
+			t.Fatalf("tx %v: got ancestor fees %d and descendant "+
+				"fees %d", tx.Hash(), desc.ancestorFees,
+				desc.descendantFees)
+		}
+	}
+	checkStats(chain[0], 1, 3)
+	checkStats(chain[1], 2, 2)
+	checkStats(chain[2], 3, 1)
+	wantSize := GetTxVirtualSize(chain[0]) + GetTxVirtualSize(chain[1]) +
+		GetTxVirtualSize(chain[2])
+	if size := txDesc(chain[0]).descendantSize; size != wantSize {
+		t.Fatalf("got descendant size %d, want %d", size, wantSize)
+	}
+
+	// Extending the chain any further exceeds the ancestor limit.
+	tx, err := harness.CreateSignedTx([]spendableOutput{prevOut}, 1, 1000,
+		false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = txPool.ProcessTransaction(tx, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "too many unconfirmed "+
+		"ancestors") {
+
+		t.Fatalf("got error %v, want ancestor limit error", err)
+	}
+
+	// Spending the second output of the first transaction exceeds its
+	// descendant limit.
+	tx, err = harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(chain[0], 1),
+	}, 1, 1000, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = txPool.ProcessTransaction(tx, false, false, 0)
+	if err == nil || !strings.Contains(err.Error(), "descendant count "+
+		"limit") {
+
+		t.Fatalf("got error %v, want descendant limit error", err)
+	}
+
+	// Removing the last transaction of the chain makes room for the
+	// rejected one and updates the statistics of its ancestors.
+	txPool.RemoveTransaction(chain[2], false, RemovalReasonUnknown)
+	checkStats(chain[0], 1, 2)
+	checkStats(chain[1], 2, 1)
+	ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(chain[0], 1)},
+		1, 1000, false, false)
+	checkStats(chain[0], 1, 3)
+	checkStats(chain[1], 2, 1)
+}
+
+// TestExpireAndRevalidate ensures transactions which stayed in the pool for too
+// long are expired along with their descendants and that transactions which
+// are no longer valid after the chain changes are removed when the pool is
+// revalidated.
+func TestExpireAndRevalidate(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+	txPool.cfg.Policy.MaxTxAge = time.Hour
+	removed := make(map[chainhash.Hash]RemovalReason)
+	txPool.cfg.NotifyTxRemoved = func(tx *eacutil.Tx, reason RemovalReason) {
+		removed[*tx.Hash()] = reason
+	}
+	checkRemoved := func(tx *eacutil.Tx, want RemovalReason) {
+		t.Helper()
+		testPoolMembership(ctx, tx, false, false)
+		if reason, ok := removed[*tx.Hash()]; !ok || reason != want {
+			t.Fatalf("tx %v: got removal reason %v, want %v",
+				tx.Hash(), reason, want)
+		}
+	}
+
+	// Expiring the parent of a chain also expires its child while
+	// transactions added recently are kept.
+	oldCoinbase := ctx.addCoinbaseTx(4)
+	parent := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(oldCoinbase, 0),
+	}, 1, 1000, false, false)
+	child := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 1000, false, false)
+	recent := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(oldCoinbase, 1),
+	}, 1, 1000, false, false)
+	txPool.pool[*parent.Hash()].Added = time.Now().Add(-2 * time.Hour)
+	if n := txPool.ExpireTransactions(); n != 2 {
+		t.Fatalf("got %d expired transactions, want 2", n)
+	}
+	checkRemoved(parent, RemovalReasonExpiry)
+	checkRemoved(child, RemovalReasonExpiry)
+	testPoolMembership(ctx, recent, false, true)
+
+	// Spending a coinbase which becomes immature after the chain is
+	// reorganized to a lower height makes the spend invalid, while the
+	// spend of an older coinbase stays valid.
+	newCoinbase := ctx.addCoinbaseTx(4)
+	spend := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(newCoinbase, 0),
+	}, 1, 1000, false, false)
+	harness.chain.SetHeight(harness.chain.BestHeight() - 2)
+	if n := txPool.Revalidate(); n != 1 {
+		t.Fatalf("got %d invalid transactions, want 1", n)
+	}
+	checkRemoved(spend, RemovalReasonReorg)
+	testPoolMembership(ctx, recent, false, true)
+	if got := RemovalReasonReorg.String(); got != "reorg" {
+		t.Fatalf("got reason string %q, want \"reorg\"", got)
+	}
+}
+
+// TestMempoolEntry ensures the verbose descriptions of transactions in the
+// pool and of their unconfirmed relatives are correct.
+func TestMempoolEntry(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	// Create a parent with two children which are both spent by a
+	// grandchild so the grandchild has three ancestors.
+	coinbase := ctx.addCoinbaseTx(4)
+	parent := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 2, 1000, false, false)
+	child1 := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 1000, true, false)
+	child2 := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 1),
+	}, 1, 1000, false, false)
+	grandchild := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(child1, 0), txOutToSpendableOut(child2, 0),
+	}, 1, 1000, false, false)
+
+	sortedHashes := func(txns ...*eacutil.Tx) []string {
+		hashes := make([]string, 0, len(txns))
+		for _, tx := range txns {
+			hashes = append(hashes, tx.Hash().String())
+		}
+		sort.Strings(hashes)
+		return hashes
+	}
+
+	entry, err := txPool.MempoolEntry(parent.Hash())
+	if err != nil {
+		t.Fatalf("unable to get mempool entry: %v", err)
+	}
+	if entry.AncestorCount != 1 || entry.DescendantCount != 4 {
+		t.Fatalf("got %d ancestors and %d descendants, want 1 and 4",
+			entry.AncestorCount, entry.DescendantCount)
+	}
+	if entry.Fees.Base != eacutil.Amount(1000).ToBTC() ||
+		entry.Fees.Descendant != eacutil.Amount(4000).ToBTC() {
+
+		t.Fatalf("got base fee %v and descendant fees %v",
+			entry.Fees.Base, entry.Fees.Descendant)
+	}
+	if len(entry.Depends) != 0 {
+		t.Fatalf("got depends %v, want none", entry.Depends)
+	}
+	if !reflect.DeepEqual(entry.SpentBy, sortedHashes(child1, child2)) {
+		t.Fatalf("got spentby %v", entry.SpentBy)
+	}
+	if entry.BIP125Replaceable {
+		t.Fatalf("parent unexpectedly signals replacement")
+	}
+
+	entry, err = txPool.MempoolEntry(grandchild.Hash())
+	if err != nil {
+		t.Fatalf("unable to get mempool entry: %v", err)
+	}
+	if entry.AncestorCount != 4 || entry.DescendantCount != 1 {
+		t.Fatalf("got %d ancestors and %d descendants, want 4 and 1",
+			entry.AncestorCount, entry.DescendantCount)
+	}
+	if !reflect.DeepEqual(entry.Depends, sortedHashes(child1, child2)) {
+		t.Fatalf("got depends %v", entry.Depends)
+	}
+	if len(entry.SpentBy) != 0 {
+		t.Fatalf("got spentby %v, want none", entry.SpentBy)
+	}
+	if !entry.BIP125Replaceable {
+		t.Fatalf("grandchild does not inherit replacement signaling")
+	}
+
+	// The relatives are keyed by their hashes and do not include the
+	// transaction itself.
+	ancestors, err := txPool.MempoolAncestors(grandchild.Hash())
+	if err != nil {
+		t.Fatalf("unable to get mempool ancestors: %v", err)
+	}
+	if len(ancestors) != 3 {
+		t.Fatalf("got %d ancestors, want 3", len(ancestors))
+	}
+	for _, tx := range []*eacutil.Tx{parent, child1, child2} {
+		if _, ok := ancestors[tx.Hash().String()]; !ok {
+			t.Fatalf("ancestor %v is missing", tx.Hash())
+		}
+	}
+	descendants, err := txPool.MempoolDescendants(child1.Hash())
+	if err != nil {
+		t.Fatalf("unable to get mempool descendants: %v", err)
+	}
+	if _, ok := descendants[grandchild.Hash().String()]; len(descendants) != 1 ||
+		!ok {
+
+		t.Fatalf("got descendants %v, want only the grandchild",
+			descendants)
+	}
+
+	// Transactions which are not in the pool are rejected.
+	if _, err := txPool.MempoolEntry(coinbase.Hash()); err == nil {
+		t.Fatalf("got entry for transaction which is not in the pool")
+	}
+	if _, err := txPool.MempoolAncestors(coinbase.Hash()); err == nil {
+		t.Fatalf("got ancestors of transaction which is not in the pool")
+	}
+	if _, err := txPool.MempoolDescendants(coinbase.Hash()); err == nil {
+		t.Fatalf("got descendants of transaction which is not in the " +
+			"pool")
+	}
+}
+
+// TestTestMempoolAccept ensures testing whether a package of transactions would
+// be accepted validates each transaction as though the ones before it were
+// accepted without modifying the pool.
+func TestTestMempoolAccept(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+	txPool.cfg.Policy.MaxAncestorCount = 2
+
+	coinbase := ctx.addCoinbaseTx(4)
+	replaceable := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 3),
+	}, 1, 1000, true, false)
+
+	createTx := func(prevOut spendableOutput, fee eacutil.Amount) *eacutil.Tx {
+		t.Helper()
+		tx, err := harness.CreateSignedTx([]spendableOutput{prevOut}, 1,
+			fee, false)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		return tx
+	}
+	parent := createTx(txOutToSpendableOut(coinbase, 0), 1000)
+	child := createTx(txOutToSpendableOut(parent, 0), 1000)
+	grandchild := createTx(txOutToSpendableOut(child, 0), 1000)
+	doubleSpend := createTx(txOutToSpendableOut(coinbase, 0), 2000)
+	orphan := createTx(txOutToSpendableOut(
+		createTx(txOutToSpendableOut(coinbase, 1), 1000), 0), 1000)
+	replacement := createTx(txOutToSpendableOut(coinbase, 3), 5000)
+
+	results := txPool.TestMempoolAccept([]*eacutil.Tx{
+		parent, child, grandchild, doubleSpend, orphan, replacement,
+	})
+	wantErrs := []string{"", "", "too many unconfirmed ancestors",
+		"in the package", "missing inputs", ""}
+	if len(results) != len(wantErrs) {
+		t.Fatalf("got %d results, want %d", len(results), len(wantErrs))
+	}
+	for i, result := range results {
+		if wantErrs[i] == "" {
+			if result.Err != nil {
+				t.Fatalf("result #%d: unexpected error: %v", i,
+					result.Err)
+			}
+			if result.VSize != GetTxVirtualSize(result.Tx) {
+				t.Fatalf("result #%d: got vsize %d, want %d", i,
+					result.VSize, GetTxVirtualSize(result.Tx))
+			}
+			continue
+		}
+		if result.Err == nil || !strings.Contains(result.Err.Error(),
+			wantErrs[i]) {
+
+			t.Fatalf("result #%d: got error %v, want %q", i,
+				result.Err, wantErrs[i])
+		}
+	}
+	if code, _ := extractRejectCode(results[4].Err); code != wire.RejectInvalid {
+		t.Fatalf("got reject code %v for orphan, want %v", code,
+			wire.RejectInvalid)
+	}
+	if results[0].Fee != 1000 || results[5].Fee != 5000 {
+		t.Fatalf("got fees %d and %d, want 1000 and 5000",
+			results[0].Fee, results[5].Fee)
+	}
+	conflicts := results[5].Conflicts
+	if len(conflicts) != 1 || !conflicts[0].IsEqual(replaceable.Hash()) {
+		t.Fatalf("got conflicts %v, want %v", conflicts,
+			replaceable.Hash())
+	}
+
+	// The pool is left untouched.
+	if txPool.Count() != 1 {
+		t.Fatalf("got %d transactions in the pool, want 1",
+			txPool.Count())
+	}
+	testPoolMembership(ctx, replaceable, false, true)
+	testPoolMembership(ctx, parent, false, false)
+
+	// Once the pool is full, transactions which would be evicted right
+	// away are rejected without raising the rolling minimum fee, while
+	// transactions paying enough to evict others are accepted.  The
+	// slack allows for signatures of different sizes.
+	txPool.cfg.Policy.MaxPoolSize = txPool.poolSize + 10
+	cheap := createTx(txOutToSpendableOut(coinbase, 2), 1000)
+	expensive := createTx(txOutToSpendableOut(coinbase, 2), 50000)
+	results = txPool.TestMempoolAccept([]*eacutil.Tx{cheap, expensive})
+	if results[0].Err == nil || !strings.Contains(results[0].Err.Error(),
+		"mempool is full") {
+
+		t.Fatalf("got error %v, want mempool full error",
+			results[0].Err)
+	}
+	if results[1].Err != nil {
+		t.Fatalf("unexpected error: %v", results[1].Err)
+	}
+	if minFee := txPool.MinFee(); minFee != txPool.cfg.Policy.MinRelayTxFee {
+		t.Fatalf("minimum fee was raised to %v", minFee)
+	}
+	testPoolMembership(ctx, replaceable, false, true)
+
+	// Transactions paying less than the rolling minimum fee are rejected.
+	txPool.mtx.Lock()
+	txPool.rollingMinFee = 1e6
+	txPool.lastRollingFeeUpdate = blockchain.Now()
+	txPool.mtx.Unlock()
+	results = txPool.TestMempoolAccept([]*eacutil.Tx{expensive})
+	if results[0].Err == nil || !strings.Contains(results[0].Err.Error(),
+		"mempool minimum fee") {
+
+		t.Fatalf("got error %v, want mempool minimum fee error",
+			results[0].Err)
+	}
+}
+
+// TestPrioritiseTransaction ensures fee deltas are applied to transactions
+// both in the pool and ones which arrive later, are honored by the package
+// statistics and replacements, and are dropped once their transaction is
+// included in a block.
+func TestPrioritiseTransaction(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	coinbase := ctx.addCoinbaseTx(2)
+	parent := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 1, 1000, true, false)
+	child := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 1000, false, false)
+
+	// Prioritising a transaction in the pool must update its modified fee
+	// and the statistics of its package.
+	txPool.PrioritiseTransaction(parent.Hash(), 5000)
+	entry, err := txPool.MempoolEntry(parent.Hash())
+	if err != nil {
+		t.Fatalf("unable to get mempool entry: %v", err)
+	}
+	if entry.Fees.Base != eacutil.Amount(1000).ToBTC() ||
+		entry.Fees.Modified != eacutil.Amount(6000).ToBTC() ||
+		entry.Fees.Descendant != eacutil.Amount(7000).ToBTC() {
+
+		t.Fatalf("got base fee %v, modified fee %v and descendant "+
+			"fees %v", entry.Fees.Base, entry.Fees.Modified,
+			entry.Fees.Descendant)
+	}
+	entry, err = txPool.MempoolEntry(child.Hash())
+	if err != nil {
+		t.Fatalf("unable to get mempool entry: %v", err)
+	}
+	if entry.Fees.Ancestor != eacutil.Amount(7000).ToBTC() {
+		t.Fatalf("got ancestor fees %v, want %v", entry.Fees.Ancestor,
+			eacutil.Amount(7000).ToBTC())
+	}
+	verbose := txPool.RawMempoolVerbose()[parent.Hash().String()]
+	if verbose.ModifiedFee != eacutil.Amount(6000).ToBTC() {
+		t.Fatalf("got verbose modified fee %v, want %v",
+			verbose.ModifiedFee, eacutil.Amount(6000).ToBTC())
+	}
+
+	// A replacement paying more than the fees of the transactions it
+	// replaces but less than their modified fees must be rejected.
+	replacement, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 1, 3000, false)
+	if err != nil {
+		t.Fatalf("unable to create replacement transaction: %v", err)
+	}
+	_, err = txPool.ProcessTransaction(replacement, false, false, 0)
+	if err == nil {
+		t.Fatalf("replacement paying less than the modified fee was " +
+			"accepted")
+	}
+
+	// Once the delta is cancelled out, the replacement is accepted.
+	txPool.PrioritiseTransaction(parent.Hash(), -5000)
+	if _, exists := txPool.feeDeltas[*parent.Hash()]; exists {
+		t.Fatalf("fee delta which reached zero was not removed")
+	}
+	_, err = txPool.ProcessTransaction(replacement, false, false, 0)
+	if err != nil {
+		t.Fatalf("unable to process replacement transaction: %v", err)
+	}
+	testPoolMembership(ctx, parent, false, false)
+	testPoolMembership(ctx, child, false, false)
+
+	// A delta for a transaction which is not in the pool yet must be
+	// applied once it arrives.
+	tx, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 1),
+	}, 1, 1000, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	txPool.PrioritiseTransaction(tx.Hash(), 2000)
+	_, err = txPool.ProcessTransaction(tx, false, false, 0)
+	if err != nil {
+		t.Fatalf("unable to process transaction: %v", err)
+	}
+	found := false
+	for _, desc := range txPool.MiningDescs() {
+		if *desc.Tx.Hash() != *tx.Hash() {
+			continue
+		}
+		found = true
+		if desc.Fee != 1000 || desc.FeeDelta != 2000 {
+			t.Fatalf("got fee %d and fee delta %d, want 1000 and "+
+				"2000", desc.Fee, desc.FeeDelta)
+		}
+	}
+	if !found {
+		t.Fatalf("transaction is missing from the mining descriptors")
+	}
+
+	// The delta must be dropped once the transaction is mined.
+	txPool.RemoveTransaction(tx, false, RemovalReasonBlock)
+	if _, exists := txPool.feeDeltas[*tx.Hash()]; exists {
+		t.Fatalf("fee delta of mined transaction was not removed")
+	}
+}
+
+// TestFullRBF ensures transactions which do not signal replacement can only
+// be replaced when full replace-by-fee is enabled and that replacements are
+// reported along with the transactions they replaced.
+func TestFullRBF(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	var notifiedTx *TxDesc
+	var notifiedReplaced []*TxDesc
+	txPool.cfg.NotifyTxReplaced = func(txDesc *TxDesc, replaced []*TxDesc) {
+		notifiedTx = txDesc
+		notifiedReplaced = replaced
+	}
+
+	// Neither the parent nor the child signal replacement.
+	coinbase := ctx.addCoinbaseTx(1)
+	parent := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 1, 1000, false, false)
+	child := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+	}, 1, 1000, false, false)
+
+	replacement, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 1, 5000, false)
+	if err != nil {
+		t.Fatalf("unable to create replacement transaction: %v", err)
+	}
+
+	// The replacement must be rejected without full replace-by-fee and
+	// when replacements are rejected altogether.
+	_, err = txPool.ProcessTransaction(replacement, false, false, 0)
+	if err == nil {
+		t.Fatalf("replacement of a transaction which does not signal " +
+			"replacement was accepted")
+	}
+	txPool.cfg.Policy.FullRBF = true
+	txPool.cfg.Policy.RejectReplacement = true
+	_, err = txPool.ProcessTransaction(replacement, false, false, 0)
+	if err == nil {
+		t.Fatalf("replacement was accepted while replacements are " +
+			"rejected")
+	}
+	if notifiedTx != nil {
+		t.Fatalf("rejected replacement was notified")
+	}
+
+	// With full replace-by-fee, the replacement is accepted and replaces
+	// both the parent and its child.
+	txPool.cfg.Policy.RejectReplacement = false
+	_, err = txPool.ProcessTransaction(replacement, false, false, 0)
+	if err != nil {
+		t.Fatalf("unable to process replacement transaction: %v", err)
+	}
+	testPoolMembership(ctx, parent, false, false)
+	testPoolMembership(ctx, child, false, false)
+	testPoolMembership(ctx, replacement, false, true)
+
+	if notifiedTx == nil || *notifiedTx.Tx.Hash() != *replacement.Hash() {
+		t.Fatalf("replacement was not notified")
+	}
+	replacedHashes := make(map[chainhash.Hash]struct{})
+	for _, desc := range notifiedReplaced {
+		replacedHashes[*desc.Tx.Hash()] = struct{}{}
+	}
+	if _, ok := replacedHashes[*parent.Hash()]; !ok ||
+		len(replacedHashes) != 2 {
+
+		t.Fatalf("got %d replaced transactions, want the parent and "+
+			"its child", len(replacedHashes))
+	}
+	if _, ok := replacedHashes[*child.Hash()]; !ok {
+		t.Fatalf("child is missing from the replaced transactions")
+	}
+}
+
+// TestDoubleSpends ensures rejected transactions double spending transactions
+// in the pool are recorded along with the peer they were first received from
+// when their signatures are valid, and forgotten once the transactions they
+// conflict with leave the pool.
+func TestDoubleSpends(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	// The notification must be sent without holding the mempool lock, so
+	// calling back into the pool must not deadlock.
+	var notified []*DoubleSpend
+	txPool.cfg.NotifyDoubleSpend = func(ds *DoubleSpend) {
+		txPool.Count()
+		notified = append(notified, ds)
+	}
+
+	coinbase := ctx.addCoinbaseTx(2)
+	original := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0),
+	}, 1, 1000, false, false)
+
+	createDoubleSpend := func(fee eacutil.Amount) *eacutil.Tx {
+		t.Helper()
+
+		tx, err := harness.CreateSignedTx([]spendableOutput{
+			txOutToSpendableOut(coinbase, 0),
+		}, 1, fee, false)
+		if err != nil {
+			t.Fatalf("unable to create double spend: %v", err)
+		}
+		return tx
+	}
+	processDoubleSpend := func(tx *eacutil.Tx, tag Tag) {
+		t.Helper()
+
+		_, err := txPool.ProcessTransaction(tx, false, false, tag)
+		if err == nil {
+			t.Fatalf("double spend %v was accepted", tx.Hash())
+		}
+	}
+
+	// The first double spend must be recorded with the peer it was
+	// received from and relayed.
+	doubleSpend := createDoubleSpend(2000)
+	processDoubleSpend(doubleSpend, 7)
+	if len(notified) != 1 {
+		t.Fatalf("got %d double spend notifications, want 1",
+			len(notified))
+	}
+	ds := notified[0]
+	if *ds.Tx.Hash() != *doubleSpend.Hash() || ds.Peer != 7 ||
+		ds.Fee != 2000 || !ds.First {
+
+		t.Fatalf("got double spend %v from peer %d with fee %d "+
+			"(first %v)", ds.Tx.Hash(), ds.Peer, ds.Fee, ds.First)
+	}
+	if len(ds.Conflicts) != 1 ||
+		*ds.Conflicts[0].Hash() != *original.Hash() {
+
+		t.Fatalf("double spend does not conflict with the original " +
+			"transaction")
+	}
+	if _, err := txPool.FetchDoubleSpend(doubleSpend.Hash()); err != nil {
+		t.Fatalf("unable to fetch double spend: %v", err)
+	}
+
+	// Receiving the same double spend from another peer must keep the
+	// first record.
+	processDoubleSpend(doubleSpend, 8)
+	if len(notified) != 1 {
+		t.Fatalf("double spend received again was notified")
+	}
+
+	// Another double spend of the same output is recorded but is not the
+	// first one.
+	processDoubleSpend(createDoubleSpend(3000), 8)
+	if len(notified) != 2 || notified[1].First {
+		t.Fatalf("second double spend was not recorded as such")
+	}
+
+	// A double spend with an invalid signature proves nothing, so it must
+	// not be recorded.
+	msgTx := createDoubleSpend(4000).MsgTx().Copy()
+	msgTx.TxIn[0].SignatureScript = nil
+	processDoubleSpend(eacutil.NewTx(msgTx), 9)
+	if len(notified) != 2 {
+		t.Fatalf("double spend with an invalid signature was recorded")
+	}
+
+	// A failed replacement of a transaction signaling replacement is not
+	// a double spend.
+	replaceable := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 1),
+	}, 1, 5000, true, false)
+	replacement, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 1),
+	}, 1, 1000, false)
+	if err != nil {
+		t.Fatalf("unable to create replacement: %v", err)
+	}
+	processDoubleSpend(replacement, 9)
+	if len(notified) != 2 {
+		t.Fatalf("failed replacement %v was recorded as a double spend",
+			replaceable.Hash())
+	}
+
+	// Double spends are not validated once the rate limit is reached.
+	txPool.mtx.Lock()
+	txPool.doubleSpendChecks = maxDoubleSpendChecks
+	txPool.lastDoubleSpendCheck = blockchain.Now().Unix()
+	txPool.mtx.Unlock()
+	processDoubleSpend(createDoubleSpend(5000), 9)
+	if len(notified) != 2 {
+		t.Fatalf("double spend was recorded despite the rate limit")
+	}
+
+	doubleSpends := txPool.DoubleSpends()
+	if len(doubleSpends) != 2 ||
+		*doubleSpends[0].Tx.Hash() != *doubleSpend.Hash() {
+
+		t.Fatalf("got %d double spends, want 2 starting with the "+
+			"first one", len(doubleSpends))
+	}
+
+	// The double spends must be forgotten once the original transaction
+	// is mined.
+	txPool.RemoveTransaction(original, true, RemovalReasonBlock)
+	if len(txPool.DoubleSpends()) != 0 {
+		t.Fatalf("double spends of a mined transaction were kept")
+	}
+	if _, err := txPool.FetchDoubleSpend(doubleSpend.Hash()); err == nil {
+		t.Fatalf("double spend of a mined transaction was fetched")
+	}
+}
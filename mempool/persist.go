@@ -0,0 +1,232 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/wire"
+	"github.com/eacsuite/eacutil"
+)
+
+const (
+	// mempoolDumpVersion is the current version of the format written by
+	// Dump.
+	mempoolDumpVersion = 1
+
+	// maxDumpEntries is the maximum number of transactions and fee deltas
+	// read from a dump.  Memory is only allocated as entries are read, so
+	// a corrupt dump can't cause huge allocations by claiming a huge
+	// count either.
+	maxDumpEntries = 10000000
+)
+
+// errInterruptRequested indicates that loading a dump was cancelled due to a
+// user-requested interrupt.
+var errInterruptRequested = errors.New("interrupt requested")
+
+// interruptRequested returns true when the provided channel has been closed.
+func interruptRequested(interrupted <-chan struct{}) bool {
+	select {
+	case <-interrupted:
+		return true
+	default:
+	}
+
+	return false
+}
+
+// Dump writes the transactions in the pool along with the time they were added
+// to it and the fee deltas of transactions to the passed writer so they can be
+// restored with Load.  Transactions are written after the transactions they
+// depend on.  Orphans are not written.
+//
+// The dump starts with the version of the format and the network the pool is
+// for, followed by the number of transactions and the transactions with the
+// unix time they were added, and ends with the number of fee deltas and the
+// deltas along with the hash of their transaction.  All integers are little
+// endian.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Dump(w io.Writer) error {
+	type dumpEntry struct {
+		tx            *eacutil.Tx
+		added         int64
+		ancestorCount int64
+	}
+
+	mp.mtx.RLock()
+	entries := make([]dumpEntry, 0, len(mp.pool))
+	for _, desc := range mp.pool {
+		entries = append(entries, dumpEntry{
+			tx:            desc.Tx,
+			added:         desc.Added.Unix(),
+			ancestorCount: desc.ancestorCount,
+		})
+	}
+	feeDeltas := make(map[chainhash.Hash]int64, len(mp.feeDeltas))
+	for hash, delta := range mp.feeDeltas {
+		feeDeltas[hash] = delta
+	}
+	mp.mtx.RUnlock()
+
+	// A transaction has more ancestors than any of its ancestors, so this
+	// orders every transaction after the ones it depends on.
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ancestorCount < entries[j].ancestorCount
+	})
+
+	header := [2]uint32{mempoolDumpVersion, uint32(mp.cfg.ChainParams.Net)}
+	if err := binary.Write(w, binary.LittleEndian, header); err != nil {
+		return err
+	}
+
+	err := wire.WriteVarInt(w, 0, uint64(len(entries)))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := entry.tx.MsgTx().Serialize(w); err != nil {
+			return err
+		}
+		err := binary.Write(w, binary.LittleEndian, entry.added)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := wire.WriteVarInt(w, 0, uint64(len(feeDeltas))); err != nil {
+		return err
+	}
+	for hash, delta := range feeDeltas {
+		if _, err := w.Write(hash[:]); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, delta); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Load reads a dump written by Dump from the passed reader and adds the
+// transactions in it to the pool the same way ProcessTransaction does, keeping
+// the time they were originally added.  Transactions which are no longer
+// acceptable, such as the ones which were included in blocks since the dump
+// was written, are skipped.  It returns the number of transactions added.
+//
+// An error is returned and nothing is added when the dump is for a different
+// network, has an unknown version or is malformed.  Adding the transactions
+// stops early when the passed interrupt channel is closed.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Load(r io.Reader, interrupt <-chan struct{}) (int, error) {
+	var header [2]uint32
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return 0, err
+	}
+	if header[0] != mempoolDumpVersion {
+		return 0, fmt.Errorf("unsupported mempool dump version %d",
+			header[0])
+	}
+	if net := wire.BitcoinNet(header[1]); net != mp.cfg.ChainParams.Net {
+		return 0, fmt.Errorf("mempool dump is for network %v instead "+
+			"of %v", net, mp.cfg.ChainParams.Net)
+	}
+
+	numTxns, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return 0, err
+	}
+	if numTxns > maxDumpEntries {
+		return 0, fmt.Errorf("too many transactions in mempool dump "+
+			"[count %d, max %d]", numTxns, maxDumpEntries)
+	}
+	type dumpEntry struct {
+		tx    *eacutil.Tx
+		added time.Time
+	}
+	var entries []dumpEntry
+	for i := uint64(0); i < numTxns; i++ {
+		var msgTx wire.MsgTx
+		if err := msgTx.Deserialize(r); err != nil {
+			return 0, err
+		}
+		var added int64
+		if err := binary.Read(r, binary.LittleEndian, &added); err != nil {
+			return 0, err
+		}
+		entries = append(entries, dumpEntry{
+			tx:    eacutil.NewTx(&msgTx),
+			added: time.Unix(added, 0),
+		})
+	}
+
+	numDeltas, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return 0, err
+	}
+	if numDeltas > maxDumpEntries {
+		return 0, fmt.Errorf("too many fee deltas in mempool dump "+
+			"[count %d, max %d]", numDeltas, maxDumpEntries)
+	}
+	feeDeltas := make(map[chainhash.Hash]int64)
+	for i := uint64(0); i < numDeltas; i++ {
+		var hash chainhash.Hash
+		if _, err := io.ReadFull(r, hash[:]); err != nil {
+			return 0, err
+		}
+		var delta int64
+		if err := binary.Read(r, binary.LittleEndian, &delta); err != nil {
+			return 0, err
+		}
+		feeDeltas[hash] = delta
+	}
+
+	mp.mtx.Lock()
+	for hash, delta := range feeDeltas {
+		mp.feeDeltas[hash] = delta
+	}
+	mp.mtx.Unlock()
+
+	// The transactions are accepted the same way ProcessTransaction does
+	// without allowing orphans.  The time they were added is restored
+	// before the lock is released so nobody sees the time they were added
+	// again.
+	var numAdded int
+	for _, entry := range entries {
+		if interruptRequested(interrupt) {
+			return numAdded, errInterruptRequested
+		}
+
+		mp.mtx.Lock()
+		missingParents, txD, _, err := mp.maybeAcceptTransaction(
+			entry.tx, true, false, true)
+		if err == nil && len(missingParents) > 0 {
+			err = fmt.Errorf("orphan transaction references "+
+				"outputs of unknown or fully-spent transaction "+
+				"%v", missingParents[0])
+		}
+		if err == nil {
+			txD.Added = entry.added
+			numAdded++
+		}
+		mp.mtx.Unlock()
+
+		if err != nil {
+			log.Debugf("Skipping transaction %v from mempool dump: %v",
+				entry.tx.Hash(), err)
+		}
+	}
+
+	return numAdded, nil
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/wire"
+)
+
+// TestDumpLoad ensures the transactions in the pool along with the time they
+// were added and the fee deltas survive dumping and loading the pool and that
+// dumps for other networks or versions are rejected.
+func TestDumpLoad(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	txPool := harness.txPool
+
+	// Add a chain of transactions along with an unrelated one and set the
+	// time they were added to the past.
+	coinbase := ctx.addCoinbaseTx(4)
+	chain, err := harness.CreateTxChain(txOutToSpendableOut(coinbase, 0), 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chain {
+		_, err := txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("unable to process transaction %v: %v",
+				tx.Hash(), err)
+		}
+	}
+	single := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 1),
+	}, 1, 1000, false, false)
+	txns := append(chain, single)
+	added := time.Unix(time.Now().Unix()-3600, 0)
+	for i, tx := range txns {
+		txPool.pool[*tx.Hash()].Added = added.Add(time.Duration(i) *
+			time.Second)
+	}
+	var missingHash chainhash.Hash
+	missingHash[0] = 1
+	txPool.feeDeltas[*single.Hash()] = 500
+	txPool.feeDeltas[missingHash] = -100
+
+	var buf bytes.Buffer
+	if err := txPool.Dump(&buf); err != nil {
+		t.Fatalf("unable to dump pool: %v", err)
+	}
+	dump := buf.Bytes()
+
+	// Load the dump into an empty pool and ensure it matches.
+	loaded := New(&txPool.cfg)
+	numAdded, err := loaded.Load(bytes.NewReader(dump), nil)
+	if err != nil {
+		t.Fatalf("unable to load pool: %v", err)
+	}
+	if numAdded != len(txns) {
+		t.Fatalf("got %d loaded transactions, want %d", numAdded,
+			len(txns))
+	}
+	for _, tx := range txns {
+		want := txPool.pool[*tx.Hash()]
+		got, ok := loaded.pool[*tx.Hash()]
+		if !ok {
+			t.Fatalf("transaction %v was not loaded", tx.Hash())
+		}
+		if !got.Added.Equal(want.Added) {
+			t.Fatalf("transaction %v: got added time %v, want %v",
+				tx.Hash(), got.Added, want.Added)
+		}
+	}
+	if len(loaded.feeDeltas) != 2 || loaded.feeDeltas[*single.Hash()] != 500 ||
+		loaded.feeDeltas[missingHash] != -100 {
+
+		t.Fatalf("unexpected fee deltas %v", loaded.feeDeltas)
+	}
+
+	// Loading the dump again skips the transactions already in the pool.
+	numAdded, err = loaded.Load(bytes.NewReader(dump), nil)
+	if err != nil || numAdded != 0 {
+		t.Fatalf("got %d loaded transactions (%v), want 0", numAdded,
+			err)
+	}
+
+	// Dumps with a different version or for another network are
+	// rejected.
+	tests := []struct {
+		name   string
+		offset int
+		value  uint32
+		err    string
+	}{
+		{"version", 0, mempoolDumpVersion + 1, "version"},
+		{"network", 4, uint32(wire.TestNet3), "network"},
+	}
+	for _, test := range tests {
+		bad := append([]byte(nil), dump...)
+		binary.LittleEndian.PutUint32(bad[test.offset:], test.value)
+		empty := New(&txPool.cfg)
+		_, err := empty.Load(bytes.NewReader(bad), nil)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("%s: got error %v, want %s error", test.name,
+				err, test.err)
+		}
+		if empty.Count() != 0 {
+			t.Fatalf("%s: transactions were loaded", test.name)
+		}
+	}
+
+	// Truncated dumps are rejected without adding anything.
+	empty := New(&txPool.cfg)
+	truncated := bytes.NewReader(dump[:len(dump)-1])
+	if _, err := empty.Load(truncated, nil); err == nil {
+		t.Fatal("truncated dump was loaded")
+	}
+	if empty.Count() != 0 {
+		t.Fatal("transactions from a truncated dump were loaded")
+	}
+
+	// Loading stops when interrupted.
+	interrupt := make(chan struct{})
+	close(interrupt)
+	_, err = empty.Load(bytes.NewReader(dump), interrupt)
+	if err != errInterruptRequested || empty.Count() != 0 {
+		t.Fatalf("got error %v and %d transactions after interrupting "+
+			"load", err, empty.Count())
+	}
+}
+
+// TestLoadHugeCount ensures a dump claiming a huge number of transactions is
+// rejected without allocating memory for all of them.
+func TestLoadHugeCount(t *testing.T) {
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+
+	var buf bytes.Buffer
+	header := [2]uint32{mempoolDumpVersion, uint32(wire.MainNet)}
+	binary.Write(&buf, binary.LittleEndian, header)
+	wire.WriteVarInt(&buf, 0, maxDumpEntries)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = harness.txPool.Load(&buf, nil)
+	runtime.ReadMemStats(&after)
+	if err == nil {
+		t.Fatal("truncated dump was loaded")
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("loading the dump allocated %d bytes", allocated)
+	}
+}
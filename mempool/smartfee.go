@@ -0,0 +1,754 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacutil"
+)
+
+// The smart fee estimator tracks how many blocks transactions in the pool take
+// to be confirmed, grouped into exponentially spaced fee rate buckets, over
+// three horizons.  The counts of every horizon decay exponentially with every
+// block so recent blocks count more.  The horizons span the same time as the
+// ones used for Bitcoin's ten minute blocks, scaled for EarthCoin's one minute
+// blocks.
+const (
+	// minBucketFeeRate is the upper bound in Satoshi/kB of the lowest fee
+	// rate bucket.  Transactions paying less are counted in it.
+	minBucketFeeRate = 1000
+
+	// maxBucketFeeRate is the upper bound in Satoshi/kB of the highest
+	// finite fee rate bucket.  Transactions paying more are counted in a
+	// final bucket without upper bound.
+	maxBucketFeeRate = 1e9
+
+	// feeBucketSpacing is the ratio between the bounds of successive fee
+	// rate buckets.
+	feeBucketSpacing = 1.05
+
+	// shortBlockPeriods, shortScale and shortDecay define the short
+	// horizon, which tracks confirmations within 120 blocks in periods of
+	// 10 blocks.  Its counts halve in about three hours.
+	shortBlockPeriods = 12
+	shortScale        = 10
+	shortDecay        = 0.99613
+
+	// medBlockPeriods, medScale and medDecay define the medium horizon,
+	// which tracks confirmations within 480 blocks in periods of 20 blocks.
+	// Its counts halve in about a day.
+	medBlockPeriods = 24
+	medScale        = 20
+	medDecay        = 0.99952
+
+	// longBlockPeriods, longScale and longDecay define the long horizon,
+	// which tracks confirmations within 10080 blocks in periods of 240
+	// blocks.  Its counts halve in about a week.
+	longBlockPeriods = 42
+	longScale        = 240
+	longDecay        = 0.999931
+
+	// halfSuccessPct, successPct and doubleSuccessPct are the fractions of
+	// transactions which must have been confirmed within half, once and
+	// twice the target for a fee rate to be estimated.
+	halfSuccessPct   = 0.6
+	successPct       = 0.85
+	doubleSuccessPct = 0.95
+
+	// sufficientFeeTxs and sufficientTxsShort are the average number of
+	// transactions per block a range of buckets must have for its success
+	// rate to be trusted by the medium and long horizons and by the short
+	// horizon respectively.
+	sufficientFeeTxs   = 0.01
+	sufficientTxsShort = 0.05
+)
+
+// MaxFeeEstimateTarget is the highest number of blocks fees can be estimated
+// for.  It is the longest target tracked by the long horizon.
+const MaxFeeEstimateTarget = longBlockPeriods * longScale
+
+// FeeEstimateHorizon identifies one of the horizons the smart fee estimator
+// tracks confirmations over.
+type FeeEstimateHorizon int
+
+const (
+	// ShortHorizon tracks confirmations within a few hours.
+	ShortHorizon FeeEstimateHorizon = iota
+
+	// MediumHorizon tracks confirmations within about a day.
+	MediumHorizon
+
+	// LongHorizon tracks confirmations within about a week.
+	LongHorizon
+
+	numFeeEstimateHorizons
+)
+
+// feeEstimateHorizonStrings is a map of fee estimate horizons back to their
+// names for pretty printing.
+var feeEstimateHorizonStrings = map[FeeEstimateHorizon]string{
+	ShortHorizon:  "short",
+	MediumHorizon: "medium",
+	LongHorizon:   "long",
+}
+
+// String returns the FeeEstimateHorizon as a human-readable name.
+func (h FeeEstimateHorizon) String() string {
+	if s, ok := feeEstimateHorizonStrings[h]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown FeeEstimateHorizon (%d)", int(h))
+}
+
+// FeeEstimateBucket describes a range of fee rate buckets which was examined
+// by a fee estimate along with the decayed counts of transactions in it.
+type FeeEstimateBucket struct {
+	// StartRange and EndRange are the bounds of the fee rates in the
+	// range.
+	StartRange BtcPerKilobyte
+	EndRange   BtcPerKilobyte
+
+	// WithinTarget is the number of transactions confirmed within the
+	// target.
+	WithinTarget float64
+
+	// TotalConfirmed is the number of transactions confirmed at any time.
+	TotalConfirmed float64
+
+	// InMempool is the number of transactions still in the pool which
+	// were not confirmed within the target.
+	InMempool float64
+
+	// LeftMempool is the number of transactions which left the pool
+	// without being confirmed after the target.
+	LeftMempool float64
+}
+
+// RawFeeEstimate is the result of estimating a fee rate with a single horizon.
+type RawFeeEstimate struct {
+	// FeeRate is the estimated fee rate, or -1 when no range of buckets
+	// met the success threshold.
+	FeeRate BtcPerKilobyte
+
+	// Decay is the factor the counts of the horizon decay by every block.
+	Decay float64
+
+	// Scale is the number of blocks in every period tracked by the
+	// horizon.
+	Scale uint32
+
+	// Pass is the lowest range of buckets which met the success
+	// threshold, if any.
+	Pass *FeeEstimateBucket
+
+	// Fail is the highest range of buckets which did not meet the success
+	// threshold, if any.
+	Fail *FeeEstimateBucket
+}
+
+// feeRateBuckets returns the upper bounds in Satoshi/kB of the fee rate
+// buckets.  The last bucket has no upper bound.
+func feeRateBuckets() []float64 {
+	var buckets []float64
+	for bound := float64(minBucketFeeRate); bound <= maxBucketFeeRate; bound *= feeBucketSpacing {
+		buckets = append(buckets, bound)
+	}
+	return append(buckets, math.Inf(1))
+}
+
+// txConfirmStats holds the exponentially decaying counts of transactions
+// confirmed and not confirmed within every period of a horizon for every fee
+// rate bucket.
+type txConfirmStats struct {
+	decay float64
+	scale uint32
+
+	// txCtAvg and feeRateSum are the number of confirmed transactions and
+	// the sum of their fee rates by bucket.
+	txCtAvg    []float64
+	feeRateSum []float64
+
+	// confAvg is the number of transactions confirmed within every number
+	// of periods by period and bucket.
+	confAvg [][]float64
+
+	// failAvg is the number of transactions which left the pool without
+	// being confirmed after every number of periods by period and bucket.
+	failAvg [][]float64
+}
+
+// newTxConfirmStats returns confirmation counts for the passed number of fee
+// rate buckets and periods of scale blocks which decay by the passed factor.
+func newTxConfirmStats(numBuckets int, periods, scale uint32, decay float64) *txConfirmStats {
+	stats := &txConfirmStats{
+		decay:      decay,
+		scale:      scale,
+		txCtAvg:    make([]float64, numBuckets),
+		feeRateSum: make([]float64, numBuckets),
+		confAvg:    make([][]float64, periods),
+		failAvg:    make([][]float64, periods),
+	}
+	for i := range stats.confAvg {
+		stats.confAvg[i] = make([]float64, numBuckets)
+		stats.failAvg[i] = make([]float64, numBuckets)
+	}
+	return stats
+}
+
+// maxConfirms returns the highest number of blocks the confirmations are
+// tracked for.
+func (s *txConfirmStats) maxConfirms() uint32 {
+	return s.scale * uint32(len(s.confAvg))
+}
+
+// decayAverages applies the decay of a block to all counts.
+func (s *txConfirmStats) decayAverages() {
+	for bucket := range s.txCtAvg {
+		s.txCtAvg[bucket] *= s.decay
+		s.feeRateSum[bucket] *= s.decay
+		for period := range s.confAvg {
+			s.confAvg[period][bucket] *= s.decay
+			s.failAvg[period][bucket] *= s.decay
+		}
+	}
+}
+
+// recordConfirmation counts a transaction in the passed bucket paying the
+// passed fee rate which was confirmed after the passed number of blocks.
+func (s *txConfirmStats) recordConfirmation(blocksToConfirm uint32, bucket int, feeRate float64) {
+	periodsToConfirm := int((blocksToConfirm + s.scale - 1) / s.scale)
+	for period := periodsToConfirm - 1; period < len(s.confAvg); period++ {
+		s.confAvg[period][bucket]++
+	}
+	s.txCtAvg[bucket]++
+	s.feeRateSum[bucket] += feeRate
+}
+
+// recordFailure counts a transaction in the passed bucket which left the pool
+// without being confirmed after the passed number of blocks.
+func (s *txConfirmStats) recordFailure(blocksAgo uint32, bucket int) {
+	periodsAgo := int(blocksAgo / s.scale)
+	for period := 0; period < periodsAgo && period < len(s.failAvg); period++ {
+		s.failAvg[period][bucket]++
+	}
+}
+
+// estimateMedianFeeRate returns the median fee rate in Satoshi/kB of the
+// transactions in the lowest range of buckets of which at least the passed
+// fraction of transactions was confirmed within the target.  The buckets are
+// examined from the highest fee rate down and combined into ranges until they
+// hold enough transactions to be trusted.  The passed unconfirmed counts are
+// the transactions in the pool by bucket which were not confirmed within the
+// target.  It returns -1 when no range meets the threshold along with the
+// ranges which were found to pass and fail.
+func (s *txConfirmStats) estimateMedianFeeRate(confTarget uint32, sufficientTxs,
+	successThreshold float64, buckets []float64, unconfirmed []float64) (float64,
+	*FeeEstimateBucket, *FeeEstimateBucket) {
+
+	period := (confTarget+s.scale-1)/s.scale - 1
+	maxBucket := len(buckets) - 1
+
+	// The counts of the range of buckets currently examined.
+	var numConf, totalNum, failNum, extraNum float64
+
+	curNearBucket, curFarBucket := maxBucket, maxBucket
+	bestNearBucket, bestFarBucket := maxBucket, maxBucket
+	foundAnswer := false
+	newBucketRange := true
+	passing := true
+	var passBucket, failBucket *FeeEstimateBucket
+
+	for bucket := maxBucket; bucket >= 0; bucket-- {
+		if newBucketRange {
+			curNearBucket = bucket
+			newBucketRange = false
+		}
+		curFarBucket = bucket
+		numConf += s.confAvg[period][bucket]
+		totalNum += s.txCtAvg[bucket]
+		failNum += s.failAvg[period][bucket]
+		extraNum += unconfirmed[bucket]
+
+		// Only ranges with enough transactions to be trusted are
+		// checked.  Failing ranges keep growing until enough lower fee
+		// rates are added for them to pass.
+		if totalNum < sufficientTxs/(1-s.decay) {
+			continue
+		}
+		successRate := numConf / (totalNum + failNum + extraNum)
+		if successRate < successThreshold {
+			if passing {
+				failBucket = newFeeEstimateBucket(buckets,
+					curFarBucket, curNearBucket, numConf,
+					totalNum, extraNum, failNum)
+				passing = false
+			}
+			continue
+		}
+
+		failBucket = nil
+		foundAnswer = true
+		passing = true
+		passBucket = newFeeEstimateBucket(buckets, curFarBucket,
+			curNearBucket, numConf, totalNum, extraNum, failNum)
+		numConf, totalNum, failNum, extraNum = 0, 0, 0, 0
+		bestNearBucket, bestFarBucket = curNearBucket, curFarBucket
+		newBucketRange = true
+	}
+
+	// The lowest buckets didn't hold enough transactions to be checked
+	// after the last passing range, so they are reported as failing.
+	if passing && !newBucketRange {
+		failBucket = newFeeEstimateBucket(buckets, curFarBucket,
+			curNearBucket, numConf, totalNum, extraNum, failNum)
+	}
+
+	if !foundAnswer {
+		return -1, nil, failBucket
+	}
+
+	var txSum float64
+	for bucket := bestFarBucket; bucket <= bestNearBucket; bucket++ {
+		txSum += s.txCtAvg[bucket]
+	}
+	if txSum == 0 {
+		return -1, nil, failBucket
+	}
+
+	// Find the bucket holding the median transaction of the passing range
+	// and return the average fee rate of that bucket.
+	txSum /= 2
+	for bucket := bestFarBucket; bucket <= bestNearBucket; bucket++ {
+		if s.txCtAvg[bucket] < txSum {
+			txSum -= s.txCtAvg[bucket]
+			continue
+		}
+		return s.feeRateSum[bucket] / s.txCtAvg[bucket], passBucket,
+			failBucket
+	}
+
+	return -1, nil, failBucket
+}
+
+// newFeeEstimateBucket returns the description of the range of buckets from
+// minBucket to maxBucket with the passed counts.
+func newFeeEstimateBucket(buckets []float64, minBucket, maxBucket int,
+	withinTarget, totalConfirmed, inMempool, leftMempool float64) *FeeEstimateBucket {
+
+	var start float64
+	if minBucket > 0 {
+		start = buckets[minBucket-1]
+	}
+	return &FeeEstimateBucket{
+		StartRange:     SatoshiPerByte(start / bytePerKb).ToBtcPerKb(),
+		EndRange:       SatoshiPerByte(buckets[maxBucket] / bytePerKb).ToBtcPerKb(),
+		WithinTarget:   withinTarget,
+		TotalConfirmed: totalConfirmed,
+		InMempool:      inMempool,
+		LeftMempool:    leftMempool,
+	}
+}
+
+// trackedTx is a transaction in the pool tracked by the smart fee estimator.
+type trackedTx struct {
+	// height is the height of the best block when the transaction was
+	// added to the pool.
+	height int32
+
+	// bucket is the fee rate bucket of the transaction.
+	bucket int
+
+	// feeRate is the fee rate of the transaction in Satoshi/kB.
+	feeRate float64
+}
+
+// smartFeeStats holds the data the smart fee estimator estimates fee rates
+// from.  Unlike the legacy bins, the decaying counts can't be rolled back, so
+// blocks which are not higher than the best block seen are ignored.
+type smartFeeStats struct {
+	buckets  []float64
+	horizons [numFeeEstimateHorizons]*txConfirmStats
+
+	// tracked holds the transactions in the pool whose confirmation is
+	// being waited for.
+	tracked map[chainhash.Hash]*trackedTx
+
+	// bestSeenHeight is the height of the last block counted.
+	bestSeenHeight int32
+
+	// firstRecordedHeight is the height of the first block which confirmed
+	// a tracked transaction.  It is zero when none did yet.
+	firstRecordedHeight int32
+}
+
+// newSmartFeeStats returns empty smart fee estimator data.
+func newSmartFeeStats() *smartFeeStats {
+	buckets := feeRateBuckets()
+	stats := &smartFeeStats{
+		buckets: buckets,
+		tracked: make(map[chainhash.Hash]*trackedTx),
+	}
+	stats.horizons[ShortHorizon] = newTxConfirmStats(len(buckets),
+		shortBlockPeriods, shortScale, shortDecay)
+	stats.horizons[MediumHorizon] = newTxConfirmStats(len(buckets),
+		medBlockPeriods, medScale, medDecay)
+	stats.horizons[LongHorizon] = newTxConfirmStats(len(buckets),
+		longBlockPeriods, longScale, longDecay)
+	return stats
+}
+
+// observeTransaction starts tracking the passed transaction which was added to
+// the pool when the best block was at the passed height.  Transactions added
+// while blocks which were not counted yet are connected are ignored since
+// the number of blocks they take to be confirmed would be wrong.
+func (s *smartFeeStats) observeTransaction(hash *chainhash.Hash, fee eacutil.Amount,
+	size int64, height int32) {
+
+	if height != s.bestSeenHeight || s.bestSeenHeight == 0 || size <= 0 {
+		return
+	}
+	if _, exists := s.tracked[*hash]; exists {
+		return
+	}
+
+	feeRate := float64(fee) * bytePerKb / float64(size)
+	s.tracked[*hash] = &trackedTx{
+		height:  height,
+		bucket:  sort.SearchFloat64s(s.buckets, feeRate),
+		feeRate: feeRate,
+	}
+}
+
+// removeTransaction stops tracking the passed transaction, which left the pool
+// without being confirmed, and counts it as a failure.
+func (s *smartFeeStats) removeTransaction(hash *chainhash.Hash) {
+	tx, exists := s.tracked[*hash]
+	if !exists {
+		return
+	}
+	delete(s.tracked, *hash)
+
+	if blocksAgo := s.bestSeenHeight - tx.height; blocksAgo > 0 {
+		for _, stats := range s.horizons {
+			stats.recordFailure(uint32(blocksAgo), tx.bucket)
+		}
+	}
+}
+
+// registerBlock decays the counts and counts the tracked transactions
+// confirmed by the passed block.
+func (s *smartFeeStats) registerBlock(block *eacutil.Block) {
+	height := block.Height()
+	if height <= s.bestSeenHeight {
+		// The block replaces one which was already counted, so the
+		// transactions it confirms are only forgotten.
+		for _, tx := range block.Transactions() {
+			delete(s.tracked, *tx.Hash())
+		}
+		return
+	}
+	s.bestSeenHeight = height
+
+	for _, stats := range s.horizons {
+		stats.decayAverages()
+	}
+
+	var numCounted int
+	for _, tx := range block.Transactions() {
+		tracked, exists := s.tracked[*tx.Hash()]
+		if !exists {
+			continue
+		}
+		delete(s.tracked, *tx.Hash())
+
+		blocksToConfirm := height - tracked.height
+		if blocksToConfirm <= 0 {
+			continue
+		}
+		for _, stats := range s.horizons {
+			stats.recordConfirmation(uint32(blocksToConfirm),
+				tracked.bucket, tracked.feeRate)
+		}
+		numCounted++
+	}
+
+	if s.firstRecordedHeight == 0 && numCounted > 0 {
+		s.firstRecordedHeight = height
+	}
+}
+
+// unconfirmed returns the number of tracked transactions by bucket which were
+// not confirmed within the passed number of blocks.
+func (s *smartFeeStats) unconfirmed(confTarget uint32) []float64 {
+	counts := make([]float64, len(s.buckets))
+	for _, tx := range s.tracked {
+		if s.bestSeenHeight-tx.height >= int32(confTarget) {
+			counts[tx.bucket]++
+		}
+	}
+	return counts
+}
+
+// estimate returns the median fee rate in Satoshi/kB for the passed target
+// using the passed horizon, or -1 when there is none.
+func (s *smartFeeStats) estimate(horizon FeeEstimateHorizon, confTarget uint32,
+	successThreshold float64) float64 {
+
+	sufficientTxs := float64(sufficientFeeTxs)
+	if horizon == ShortHorizon {
+		sufficientTxs = sufficientTxsShort
+	}
+	feeRate, _, _ := s.horizons[horizon].estimateMedianFeeRate(confTarget,
+		sufficientTxs, successThreshold, s.buckets,
+		s.unconfirmed(confTarget))
+	return feeRate
+}
+
+// estimateCombinedFee returns the fee rate in Satoshi/kB for the passed target
+// using the shortest horizon which tracks it.  When checkShorterHorizon is set,
+// the lower estimate for the longest target of a shorter horizon is preferred
+// since it is more recent.  It returns -1 when there is no estimate.
+func (s *smartFeeStats) estimateCombinedFee(confTarget uint32, successThreshold float64,
+	checkShorterHorizon bool) float64 {
+
+	shortMax := s.horizons[ShortHorizon].maxConfirms()
+	medMax := s.horizons[MediumHorizon].maxConfirms()
+	if confTarget < 1 || confTarget > s.horizons[LongHorizon].maxConfirms() {
+		return -1
+	}
+
+	var estimate float64
+	switch {
+	case confTarget <= shortMax:
+		estimate = s.estimate(ShortHorizon, confTarget, successThreshold)
+	case confTarget <= medMax:
+		estimate = s.estimate(MediumHorizon, confTarget, successThreshold)
+	default:
+		estimate = s.estimate(LongHorizon, confTarget, successThreshold)
+	}
+
+	if !checkShorterHorizon {
+		return estimate
+	}
+	if confTarget > medMax {
+		medEstimate := s.estimate(MediumHorizon, medMax, successThreshold)
+		if medEstimate > 0 && (estimate == -1 || medEstimate < estimate) {
+			estimate = medEstimate
+		}
+	}
+	if confTarget > shortMax {
+		shortEstimate := s.estimate(ShortHorizon, shortMax, successThreshold)
+		if shortEstimate > 0 && (estimate == -1 || shortEstimate < estimate) {
+			estimate = shortEstimate
+		}
+	}
+	return estimate
+}
+
+// estimateConservativeFee returns the highest fee rate in Satoshi/kB of the
+// medium and long horizons for the passed target which is twice the one
+// requested, or -1 when there is none.
+func (s *smartFeeStats) estimateConservativeFee(doubleTarget uint32) float64 {
+	estimate := float64(-1)
+	if doubleTarget <= s.horizons[ShortHorizon].maxConfirms() {
+		estimate = s.estimate(MediumHorizon, doubleTarget, doubleSuccessPct)
+	}
+	if doubleTarget <= s.horizons[MediumHorizon].maxConfirms() {
+		longEstimate := s.estimate(LongHorizon, doubleTarget,
+			doubleSuccessPct)
+		if longEstimate > estimate {
+			estimate = longEstimate
+		}
+	}
+	return estimate
+}
+
+// maxUsableTarget returns the highest target which can be estimated.  Targets
+// longer than half the blocks counted since the first confirmation are not
+// estimated since too few transactions could have taken that long.
+func (s *smartFeeStats) maxUsableTarget() uint32 {
+	var blockSpan int32
+	if s.firstRecordedHeight > 0 {
+		blockSpan = s.bestSeenHeight - s.firstRecordedHeight
+	}
+	maxTarget := uint32(blockSpan / 2)
+	if longMax := s.horizons[LongHorizon].maxConfirms(); maxTarget > longMax {
+		maxTarget = longMax
+	}
+	return maxTarget
+}
+
+// estimateSmartFee returns the fee rate in Satoshi/kB for the passed target
+// along with the target it was estimated for.  See EstimateSmartFee for more
+// details.
+func (s *smartFeeStats) estimateSmartFee(confTarget uint32, conservative bool) (float64, uint32) {
+	// A transaction is never confirmed in the block it was added to the
+	// pool before, so a single block is estimated like two blocks.
+	if confTarget == 1 {
+		confTarget = 2
+	}
+	if maxTarget := s.maxUsableTarget(); confTarget > maxTarget {
+		confTarget = maxTarget
+	}
+	if confTarget <= 1 {
+		return -1, confTarget
+	}
+
+	median := s.estimateCombinedFee(confTarget/2, halfSuccessPct, true)
+	actual := s.estimateCombinedFee(confTarget, successPct, true)
+	if actual > median {
+		median = actual
+	}
+	double := s.estimateCombinedFee(2*confTarget, doubleSuccessPct,
+		!conservative)
+	if double > median {
+		median = double
+	}
+	if conservative || median == -1 {
+		consEstimate := s.estimateConservativeFee(2 * confTarget)
+		if consEstimate > median {
+			median = consEstimate
+		}
+	}
+	return median, confTarget
+}
+
+// serialize writes the smart fee estimator data to the passed writer.
+func (s *smartFeeStats) serialize(w io.Writer) error {
+	err := writeElements(w, s.bestSeenHeight, s.firstRecordedHeight,
+		uint32(len(s.buckets)))
+	if err != nil {
+		return err
+	}
+	for _, stats := range s.horizons {
+		err := writeElements(w, stats.scale, uint32(len(stats.confAvg)),
+			stats.txCtAvg, stats.feeRateSum)
+		if err != nil {
+			return err
+		}
+		for period := range stats.confAvg {
+			err := writeElements(w, stats.confAvg[period],
+				stats.failAvg[period])
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Put the tracked transactions in a sorted list so the serialized data
+	// always comes out the same.
+	hashes := make([]chainhash.Hash, 0, len(s.tracked))
+	for hash := range s.tracked {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i].String() < hashes[j].String()
+	})
+	if err := writeElements(w, uint32(len(hashes))); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		tx := s.tracked[hash]
+		err := writeElements(w, hash, tx.height, uint32(tx.bucket),
+			tx.feeRate)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deserializeSmartFeeStats reads smart fee estimator data written by serialize
+// from the passed reader.  An error is returned when it was written with
+// different buckets or horizons.
+func deserializeSmartFeeStats(r io.Reader) (*smartFeeStats, error) {
+	s := newSmartFeeStats()
+
+	var numBuckets uint32
+	err := readElements(r, &s.bestSeenHeight, &s.firstRecordedHeight,
+		&numBuckets)
+	if err != nil {
+		return nil, err
+	}
+	if int(numBuckets) != len(s.buckets) {
+		return nil, fmt.Errorf("saved fee estimates have %d buckets "+
+			"instead of %d", numBuckets, len(s.buckets))
+	}
+	for h, stats := range s.horizons {
+		var scale, periods uint32
+		if err := readElements(r, &scale, &periods); err != nil {
+			return nil, err
+		}
+		if scale != stats.scale || int(periods) != len(stats.confAvg) {
+			return nil, fmt.Errorf("saved %v fee estimates have %d "+
+				"periods of %d blocks instead of %d of %d",
+				FeeEstimateHorizon(h), periods, scale,
+				len(stats.confAvg), stats.scale)
+		}
+		err := readElements(r, stats.txCtAvg, stats.feeRateSum)
+		if err != nil {
+			return nil, err
+		}
+		for period := range stats.confAvg {
+			err := readElements(r, stats.confAvg[period],
+				stats.failAvg[period])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var numTracked uint32
+	if err := readElements(r, &numTracked); err != nil {
+		return nil, err
+	}
+	for i := uint32(0); i < numTracked; i++ {
+		var hash chainhash.Hash
+		var bucket uint32
+		tx := &trackedTx{}
+		err := readElements(r, &hash, &tx.height, &bucket, &tx.feeRate)
+		if err != nil {
+			return nil, err
+		}
+		if int(bucket) >= len(s.buckets) {
+			return nil, errors.New("saved fee estimates track a " +
+				"transaction in an unknown bucket")
+		}
+		tx.bucket = int(bucket)
+		s.tracked[hash] = tx
+	}
+
+	return s, nil
+}
+
+// writeElements writes the passed fixed size values to the passed writer in
+// big endian like the rest of the saved fee estimator.
+func writeElements(w io.Writer, elements ...interface{}) error {
+	for _, element := range elements {
+		if err := binary.Write(w, binary.BigEndian, element); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readElements reads the passed fixed size values written by writeElements
+// from the passed reader.
+func readElements(r io.Reader, elements ...interface{}) error {
+	for _, element := range elements {
+		if err := binary.Read(r, binary.BigEndian, element); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// mempoolDumpFilename is the name of the file in the data directory used to
+// persist the transactions in the mempool across restarts.
+const mempoolDumpFilename = "mempool.dat"
+
+// errMempoolNotLoaded is returned when saving the mempool is requested before
+// the transactions of the previous session finished loading.  Saving it then
+// would lose the transactions which were not loaded yet.
+var errMempoolNotLoaded = errors.New("the mempool was not loaded yet")
+
+// loadMempool adds the transactions persisted by a previous session to the
+// mempool.  It must be run as a goroutine.
+func (s *server) loadMempool() {
+	defer s.wg.Done()
+
+	path := filepath.Join(cfg.DataDir, mempoolDumpFilename)
+	file, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			srvrLog.Errorf("Unable to open mempool file %s: %v", path,
+				err)
+		}
+		atomic.StoreInt32(&s.mempoolLoaded, 1)
+		return
+	}
+	defer file.Close()
+
+	numAdded, err := s.txMemPool.Load(bufio.NewReader(file), s.quit)
+	if err != nil {
+		if interruptRequested(s.quit) {
+			return
+		}
+		srvrLog.Errorf("Unable to load mempool file %s: %v", path, err)
+	} else {
+		srvrLog.Infof("Loaded %d transactions from mempool file",
+			numAdded)
+	}
+	atomic.StoreInt32(&s.mempoolLoaded, 1)
+}
+
+// saveMempool writes the transactions in the mempool to the mempool file in
+// the data directory.  The file is replaced atomically so a failure never
+// leaves a truncated file behind.
+//
+// This function is safe for concurrent access.
+func (s *server) saveMempool() error {
+	if atomic.LoadInt32(&s.mempoolLoaded) == 0 {
+		return errMempoolNotLoaded
+	}
+
+	s.mempoolDumpMtx.Lock()
+	defer s.mempoolDumpMtx.Unlock()
+
+	path := filepath.Join(cfg.DataDir, mempoolDumpFilename)
+	tmpPath := path + ".new"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	err = s.txMemPool.Dump(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
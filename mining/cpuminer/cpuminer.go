@@ -16,6 +16,7 @@ import (
 	"github.com/eacsuite/eacd/chaincfg"
 	"github.com/eacsuite/eacd/chaincfg/chainhash"
 	"github.com/eacsuite/eacd/mining"
+	"github.com/eacsuite/eacd/scrypt"
 	"github.com/eacsuite/eacd/wire"
 	"github.com/eacsuite/eacutil"
 )
@@ -57,9 +58,10 @@ type Config struct {
 	// generate block templates that the miner will attempt to solve.
 	BlockTemplateGenerator *mining.BlkTmplGenerator
 
-	// MiningAddrs is a list of payment addresses to use for the generated
-	// blocks.  Each generated block will randomly choose one of them.
-	MiningAddrs []eacutil.Address
+	// MiningPayouts is a list of payout lists to use for the generated
+	// blocks.  Each generated block will randomly choose one of them and
+	// split its reward among the payouts of the list by their weights.
+	MiningPayouts [][]mining.Payout
 
 	// ProcessBlock defines the function to call with any solved blocks.
 	// It typically must run the provided block through the same set of
@@ -151,8 +153,9 @@ out:
 }
 
 // submitBlock submits the passed block to network after ensuring it passes all
-// of the consensus validation rules.
-func (m *CPUMiner) submitBlock(block *eacutil.Block) bool {
+// of the consensus validation rules.  It returns the reason when the block was
+// not accepted.
+func (m *CPUMiner) submitBlock(block *eacutil.Block) error {
 	m.submitBlockLock.Lock()
 	defer m.submitBlockLock.Unlock()
 
@@ -165,7 +168,7 @@ func (m *CPUMiner) submitBlock(block *eacutil.Block) bool {
 	if !msgBlock.Header.PrevBlock.IsEqual(&m.g.BestSnapshot().Hash) {
 		log.Debugf("Block submitted via CPU miner with previous "+
 			"block %s is stale", msgBlock.Header.PrevBlock)
-		return false
+		return fmt.Errorf("block %s is stale", block.Hash())
 	}
 
 	// Process this block using the same rules as blocks coming from other
@@ -177,22 +180,22 @@ func (m *CPUMiner) submitBlock(block *eacutil.Block) bool {
 		if _, ok := err.(blockchain.RuleError); !ok {
 			log.Errorf("Unexpected error while processing "+
 				"block submitted via CPU miner: %v", err)
-			return false
+			return err
 		}
 
 		log.Debugf("Block submitted via CPU miner rejected: %v", err)
-		return false
+		return err
 	}
 	if isOrphan {
 		log.Debugf("Block submitted via CPU miner is an orphan")
-		return false
+		return fmt.Errorf("block %s is an orphan", block.Hash())
 	}
 
 	// The block was accepted.
 	coinbaseTx := block.MsgBlock().Transactions[0].TxOut[0]
 	log.Infof("Block submitted via CPU miner accepted (hash %s, "+
 		"amount %v)", block.Hash(), eacutil.Amount(coinbaseTx.Value))
-	return true
+	return nil
 }
 
 // solveBlock attempts to find some combination of a nonce, extra nonce, and
@@ -204,8 +207,16 @@ func (m *CPUMiner) submitBlock(block *eacutil.Block) bool {
 // This function will return early with false when conditions that trigger a
 // stale block such as a new block showing up or periodically when there are
 // new transactions and enough time has elapsed without finding a solution.
+//
+// The passed hasher holds the scrypt scratch state of the calling worker and
+// must not be shared with other workers.
+//
+// The timestamp of the block is left alone when fixedTime is set.  When tries
+// is not nil, it is the number of hashes which may still be tried and the
+// function returns false once it drops to zero.
 func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
-	ticker *time.Ticker, quit chan struct{}) bool {
+	hasher *scrypt.Hasher, ticker *time.Ticker, fixedTime bool,
+	tries *uint64, quit chan struct{}) bool {
 
 	// Choose a random extra nonce offset for this block template and
 	// worker.
@@ -221,9 +232,10 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 	targetDifficulty := blockchain.CompactToBig(header.Bits)
 
 	// Initial state.
-	lastGenerated := time.Now()
+	lastGenerated := blockchain.Now()
 	lastTxUpdate := m.g.TxSource().LastUpdated()
 	hashesCompleted := uint64(0)
+	var hash chainhash.Hash
 
 	// Note that the entire extra nonce range is iterated and the offset is
 	// added relying on the fact that overflow will wrap around 0 as
@@ -258,31 +270,40 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight int32,
 				// generated and it has been at least one
 				// minute.
 				if lastTxUpdate != m.g.TxSource().LastUpdated() &&
-					time.Now().After(lastGenerated.Add(time.Minute)) {
+					blockchain.Now().After(lastGenerated.Add(time.Minute)) {
 
 					return false
 				}
 
-				m.g.UpdateBlockTime(msgBlock)
+				if !fixedTime {
+					m.g.UpdateBlockTime(msgBlock)
+				}
 
 			default:
 				// Non-blocking select to fall through
 			}
 
-			// Update the nonce and hash the block header.  Each
-			// hash is actually a double sha256 (two hashes), so
-			// increment the number of hashes completed for each
-			// attempt accordingly.
-			header.Nonce = i
-			hash, err := header.PowHash()
-			if err != nil {
-				return false
+			// Give up once the allowed number of hashes has been
+			// tried.
+			if tries != nil {
+				if *tries == 0 {
+					m.updateHashes <- hashesCompleted
+					return false
+				}
+				*tries--
 			}
-			hashesCompleted += 1
+
+			// Update the nonce and hash the block header.  The
+			// hasher reuses the SHA-256 midstate of the start of
+			// the header, which only changes along with the extra
+			// nonce.
+			header.Nonce = i
+			header.PowHashWith(hasher, &hash)
+			hashesCompleted++
 
 			// The block is solved when the new block hash is less
 			// than the target difficulty.  Yay!
-			if blockchain.HashToBig(hash).Cmp(targetDifficulty) <= 0 {
+			if blockchain.HashToBig(&hash).Cmp(targetDifficulty) <= 0 {
 				m.updateHashes <- hashesCompleted
 				return true
 			}
@@ -306,6 +327,10 @@ func (m *CPUMiner) generateBlocks(quit chan struct{}) {
 	// updates to the speed monitor.
 	ticker := time.NewTicker(time.Second * hashUpdateSecs)
 	defer ticker.Stop()
+
+	// Each worker hashes with its own scrypt hasher so the scratch state
+	// is allocated once rather than for every hash.
+	hasher := scrypt.NewHasher()
 out:
 	for {
 		// Quit when the miner is stopped.
@@ -337,14 +362,15 @@ out:
 			continue
 		}
 
-		// Choose a payment address at random.
+		// Choose a payout list at random.
 		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		payouts := m.cfg.MiningPayouts[rand.Intn(len(m.cfg.MiningPayouts))]
 
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
 		// include in the block.
-		template, err := m.g.NewBlockTemplate(payToAddr)
+		template, err := m.g.NewBlockTemplateWithOptions(nil,
+			&mining.TemplateOptions{Payouts: payouts})
 		m.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("Failed to create new block "+
@@ -357,7 +383,8 @@ out:
 		// with false when conditions that trigger a stale block, so
 		// a new block template can be generated.  When the return is
 		// true a solution was found, so submit the solved block.
-		if m.solveBlock(template.Block, curHeight+1, ticker, quit) {
+		if m.solveBlock(template.Block, curHeight+1, hasher, ticker,
+			false, nil, quit) {
 			block := eacutil.NewBlock(template.Block)
 			m.submitBlock(block)
 		}
@@ -548,6 +575,28 @@ func (m *CPUMiner) NumWorkers() int32 {
 // generating a new block template.  When a block is solved, it is submitted.
 // The function returns a list of the hashes of generated blocks.
 func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
+	return m.GenerateBlocks(n, nil, 0, nil)
+}
+
+// GenerateBlocks generates the requested number of blocks like GenerateNBlocks
+// while paying them to the passed address instead of a random one of the
+// configured mining addresses when it is not nil.
+//
+// The blocks are built with the passed template options when they are not
+// nil.  Each block after the first is given a timestamp one second after the
+// previous one when the options specify a timestamp, so the timestamps stay
+// after the median time of the last several blocks.  An error is returned when
+// a block template can't be created, while a solved block which is rejected,
+// such as because it became stale, is not counted and generated again unless
+// the options specify the transactions of the block, in which case an error is
+// returned as well.
+//
+// When maxTries is not zero, at most that many hashes are tried in total and
+// the hashes of the blocks generated until then are returned, which may be
+// fewer than requested.
+func (m *CPUMiner) GenerateBlocks(n uint32, payToAddr eacutil.Address,
+	maxTries uint64, opts *mining.TemplateOptions) ([]*chainhash.Hash, error) {
+
 	m.Lock()
 
 	// Respond with an error if server is already mining.
@@ -566,17 +615,32 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 
 	m.Unlock()
 
+	defer func() {
+		m.Lock()
+		close(m.speedMonitorQuit)
+		m.wg.Wait()
+		m.started = false
+		m.discreteMining = false
+		m.Unlock()
+	}()
+
 	log.Tracef("Generating %d blocks", n)
 
-	i := uint32(0)
-	blockHashes := make([]*chainhash.Hash, n)
+	blockHashes := make([]*chainhash.Hash, 0, n)
 
 	// Start a ticker which is used to signal checks for stale work and
 	// updates to the speed monitor.
 	ticker := time.NewTicker(time.Second * hashUpdateSecs)
 	defer ticker.Stop()
+	hasher := scrypt.NewHasher()
 
-	for {
+	var tries *uint64
+	if maxTries != 0 {
+		tries = &maxTries
+	}
+	fixedTime := opts != nil && !opts.Timestamp.IsZero()
+
+	for uint32(len(blockHashes)) < n {
 		// Read updateNumWorkers in case someone tries a `setgenerate` while
 		// we're generating. We can ignore it as the `generate` RPC call only
 		// uses 1 worker.
@@ -591,43 +655,63 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		m.submitBlockLock.Lock()
 		curHeight := m.g.BestSnapshot().Height
 
-		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
-
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
-		// include in the block.
-		template, err := m.g.NewBlockTemplate(payToAddr)
+		// include in the block unless the options say otherwise.
+		blockOpts := new(mining.TemplateOptions)
+		if opts != nil {
+			*blockOpts = *opts
+			if fixedTime {
+				offset := time.Duration(len(blockHashes)) * time.Second
+				blockOpts.Timestamp = opts.Timestamp.Add(offset)
+			}
+		}
+
+		// Choose a payout list at random unless a payment address or
+		// payouts are given.
+		if payToAddr == nil && len(blockOpts.Payouts) == 0 {
+			rand.Seed(time.Now().UnixNano())
+			blockOpts.Payouts = m.cfg.MiningPayouts[rand.Intn(
+				len(m.cfg.MiningPayouts))]
+		}
+		template, err := m.g.NewBlockTemplateWithOptions(payToAddr, blockOpts)
 		m.submitBlockLock.Unlock()
 		if err != nil {
-			errStr := fmt.Sprintf("Failed to create new block "+
+			return nil, fmt.Errorf("Failed to create new block "+
 				"template: %v", err)
-			log.Errorf(errStr)
-			continue
 		}
 
 		// Attempt to solve the block.  The function will exit early
 		// with false when conditions that trigger a stale block, so
 		// a new block template can be generated.  When the return is
 		// true a solution was found, so submit the solved block.
-		if m.solveBlock(template.Block, curHeight+1, ticker, nil) {
+		if m.solveBlock(template.Block, curHeight+1, hasher, ticker,
+			fixedTime, tries, nil) {
 			block := eacutil.NewBlock(template.Block)
-			m.submitBlock(block)
-			blockHashes[i] = block.Hash()
-			i++
-			if i == n {
-				log.Tracef("Generated %d blocks", i)
-				m.Lock()
-				close(m.speedMonitorQuit)
-				m.wg.Wait()
-				m.started = false
-				m.discreteMining = false
-				m.Unlock()
-				return blockHashes, nil
+			err := m.submitBlock(block)
+			if err == nil {
+				blockHashes = append(blockHashes, block.Hash())
+				continue
+			}
+
+			// A block of the specified transactions would be
+			// rejected again, so give up right away.
+			if opts != nil && opts.Transactions != nil {
+				return nil, fmt.Errorf("Generated block was "+
+					"rejected: %v", err)
 			}
+			continue
+		}
+
+		// Stop generating once the allowed number of hashes has been
+		// tried.
+		if tries != nil && *tries == 0 {
+			break
 		}
 	}
+
+	log.Tracef("Generated %d blocks", len(blockHashes))
+	return blockHashes, nil
 }
 
 // New returns a new instance of a CPU miner for the provided configuration.
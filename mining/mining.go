@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"container/heap"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/eacsuite/eacd/blockchain"
@@ -29,8 +30,14 @@ const (
 
 	// CoinbaseFlags is added to the coinbase script of a generated block
 	// and is used to monitor BIP16 support as well as blocks that are
-	// generated via eacd.
+	// generated via eacd.  It is used unless the CoinbaseFlags policy
+	// setting overrides it.
 	CoinbaseFlags = "/P2SH/eacd/"
+
+	// MaxCoinbaseFlagsLen is the maximum length of the coinbase flags.  It
+	// leaves room in the coinbase script for the block height and extra
+	// nonce as well as the extra nonces of Stratum miners.
+	MaxCoinbaseFlagsLen = 64
 )
 
 // TxDesc is a descriptor about a transaction in a transaction source along with
@@ -51,6 +58,11 @@ type TxDesc struct {
 
 	// FeePerKB is the fee the transaction pays in Satoshi per 1000 bytes.
 	FeePerKB int64
+
+	// FeeDelta is the adjustment in Satoshi added to the fee of the
+	// transaction when prioritizing it.  It does not change the fee the
+	// transaction pays.
+	FeeDelta int64
 }
 
 // TxSource represents a source of transactions to consider for inclusion in
@@ -80,12 +92,44 @@ type txPrioItem struct {
 	fee      int64
 	priority float64
 	feePerKB int64
+	size     int64
+
+	// modifiedFee is the fee adjusted by the fee delta of the transaction.
+	// It is used instead of the fee to prioritize the transaction, so
+	// feePerKB and the ancestor fee are based on it as well.
+	modifiedFee int64
 
 	// dependsOn holds a map of transaction hashes which this one depends
 	// on.  It will only be set when the transaction references other
 	// transactions in the source pool and hence must come after them in
 	// a block.
 	dependsOn map[chainhash.Hash]struct{}
+
+	// ancestors holds the transactions in the source pool which this one
+	// depends on directly or indirectly and which have not been included
+	// in the block yet, while descendants holds the transactions which
+	// depend on this one.  ancestorFee and ancestorSize are the total
+	// modified fee and virtual size of the transaction along with those
+	// ancestors.
+	ancestors    map[chainhash.Hash]*txPrioItem
+	descendants  map[chainhash.Hash]*txPrioItem
+	ancestorFee  int64
+	ancestorSize int64
+
+	// index is the position of the item in the priority queue it was last
+	// pushed onto.  It is -1 once the item is popped.
+	index int
+
+	// prepared is the prepared transaction of the source pool the item
+	// was created for.  It is nil for transactions which are not from the
+	// source pool.
+	prepared *preparedTx
+}
+
+// ancestorFeePerKB returns the fee in Satoshi/kB the transaction pays along
+// with its ancestors which have not been included in the block yet.
+func (item *txPrioItem) ancestorFeePerKB() int64 {
+	return item.ancestorFee * 1000 / item.ancestorSize
 }
 
 // txPriorityQueueLessFunc describes a function that can be used as a compare
@@ -116,12 +160,16 @@ func (pq *txPriorityQueue) Less(i, j int) bool {
 // part of the heap.Interface implementation.
 func (pq *txPriorityQueue) Swap(i, j int) {
 	pq.items[i], pq.items[j] = pq.items[j], pq.items[i]
+	pq.items[i].index = i
+	pq.items[j].index = j
 }
 
 // Push pushes the passed item onto the priority queue.  It is part of the
 // heap.Interface implementation.
 func (pq *txPriorityQueue) Push(x interface{}) {
-	pq.items = append(pq.items, x.(*txPrioItem))
+	item := x.(*txPrioItem)
+	item.index = len(pq.items)
+	pq.items = append(pq.items, item)
 }
 
 // Pop removes the highest priority item (according to Less) from the priority
@@ -131,6 +179,7 @@ func (pq *txPriorityQueue) Pop() interface{} {
 	item := pq.items[n-1]
 	pq.items[n-1] = nil
 	pq.items = pq.items[0 : n-1]
+	item.index = -1
 	return item
 }
 
@@ -165,6 +214,20 @@ func txPQByFee(pq *txPriorityQueue, i, j int) bool {
 	return pq.items[i].feePerKB > pq.items[j].feePerKB
 }
 
+// txPQByAncestorFee sorts a txPriorityQueue by the fees per kilobyte of the
+// transactions along with their ancestors not included in the block yet and
+// then transaction priority.
+func txPQByAncestorFee(pq *txPriorityQueue, i, j int) bool {
+	// Using > here so that pop gives the highest fee item as opposed
+	// to the lowest.  Sort by fee first, then priority.
+	feeI := pq.items[i].ancestorFeePerKB()
+	feeJ := pq.items[j].ancestorFeePerKB()
+	if feeI == feeJ {
+		return pq.items[i].priority > pq.items[j].priority
+	}
+	return feeI > feeJ
+}
+
 // newTxPriorityQueue returns a new transaction priority queue that reserves the
 // passed amount of space for the elements.  The new priority queue uses either
 // the txPQByPriority or the txPQByFee compare function depending on the
@@ -219,6 +282,33 @@ type BlockTemplate struct {
 	WitnessCommitment []byte
 }
 
+// TemplateOptions houses optional settings which change how the block templates
+// created by NewBlockTemplateWithOptions are built.  The zero value creates the
+// same templates as NewBlockTemplate.
+type TemplateOptions struct {
+	// Transactions, when not nil, are the exact transactions to include in
+	// the block after the coinbase, in the given order, instead of the
+	// transactions selected from the source pool.  They don't need to be
+	// in the source pool, but may only spend outputs of the main chain or
+	// of the transactions before them.  An empty slice creates a block
+	// with only the coinbase.
+	Transactions []*eacutil.Tx
+
+	// Timestamp, when not zero, is the timestamp of the block instead of
+	// the current time adjusted to be after the median time of the last
+	// several blocks.  It is truncated to a second boundary.
+	Timestamp time.Time
+
+	// Version, when not zero, is the version of the block instead of the
+	// version calculated from the state of the rule change deployments.
+	Version int32
+
+	// Payouts, when not empty, split the reward of the block among their
+	// addresses in proportion to their weights instead of paying it to
+	// the payment address.
+	Payouts []Payout
+}
+
 // mergeUtxoView adds all of the entries in viewB to viewA.  The result is that
 // viewA will contain all of its original entries plus all of the entries
 // in viewB.  It will replace any entries in viewB which also exist in viewA
@@ -237,10 +327,10 @@ func mergeUtxoView(viewA *blockchain.UtxoViewpoint, viewB *blockchain.UtxoViewpo
 // standardCoinbaseScript returns a standard script suitable for use as the
 // signature script of the coinbase transaction of a new block.  In particular,
 // it starts with the block height that is required by version 2 blocks and adds
-// the extra nonce as well as additional coinbase flags.
-func standardCoinbaseScript(nextBlockHeight int32, extraNonce uint64) ([]byte, error) {
+// the extra nonce as well as the passed coinbase flags.
+func standardCoinbaseScript(nextBlockHeight int32, extraNonce uint64, flags string) ([]byte, error) {
 	return txscript.NewScriptBuilder().AddInt64(int64(nextBlockHeight)).
-		AddInt64(int64(extraNonce)).AddData([]byte(CoinbaseFlags)).
+		AddInt64(int64(extraNonce)).AddData([]byte(flags)).
 		Script()
 }
 
@@ -314,6 +404,82 @@ func logSkippedDeps(tx *eacutil.Tx, deps map[chainhash.Hash]*txPrioItem) {
 	}
 }
 
+// calcAncestors sets the ancestors and descendants of the passed transactions
+// keyed by their hash along with the total fee and size of each transaction
+// and its ancestors.  Transactions depending on a transaction which is not
+// among them can never be included in a block, so they are removed.
+func calcAncestors(prioItems map[chainhash.Hash]*txPrioItem) {
+	unavailable := make(map[chainhash.Hash]struct{})
+	for hash, prioItem := range prioItems {
+		prioItem.descendants = make(map[chainhash.Hash]*txPrioItem)
+		if !populateAncestors(prioItem, prioItems, unavailable) {
+			log.Tracef("Skipping tx %s since it depends on an "+
+				"unavailable transaction", hash)
+			delete(prioItems, hash)
+		}
+	}
+
+	for hash, prioItem := range prioItems {
+		for _, ancestor := range prioItem.ancestors {
+			ancestor.descendants[hash] = prioItem
+		}
+	}
+}
+
+// populateAncestors sets the ancestors of the passed transaction along with
+// the total fee and size of it and its ancestors.  It returns false when the
+// transaction depends on a transaction which is not among the passed ones.
+func populateAncestors(prioItem *txPrioItem,
+	prioItems map[chainhash.Hash]*txPrioItem,
+	unavailable map[chainhash.Hash]struct{}) bool {
+
+	hash := *prioItem.tx.Hash()
+	if prioItem.ancestors != nil {
+		return true
+	}
+	if _, ok := unavailable[hash]; ok {
+		return false
+	}
+
+	ancestors := make(map[chainhash.Hash]*txPrioItem)
+	for parentHash := range prioItem.dependsOn {
+		parent, ok := prioItems[parentHash]
+		if !ok || !populateAncestors(parent, prioItems, unavailable) {
+			unavailable[hash] = struct{}{}
+			return false
+		}
+		ancestors[parentHash] = parent
+		for ancestorHash, ancestor := range parent.ancestors {
+			ancestors[ancestorHash] = ancestor
+		}
+	}
+
+	prioItem.ancestors = ancestors
+	prioItem.ancestorFee = prioItem.modifiedFee
+	prioItem.ancestorSize = prioItem.size
+	for _, ancestor := range ancestors {
+		prioItem.ancestorFee += ancestor.modifiedFee
+		prioItem.ancestorSize += ancestor.size
+	}
+	return true
+}
+
+// removeIncludedAncestor updates the transactions depending on the passed one,
+// which was included in the block, so their ancestor fee and size no longer
+// account for it.  Those transactions are reordered in the passed priority
+// queue when it is not nil.
+func removeIncludedAncestor(prioItem *txPrioItem, pq *txPriorityQueue) {
+	hash := *prioItem.tx.Hash()
+	for _, descendant := range prioItem.descendants {
+		delete(descendant.ancestors, hash)
+		descendant.ancestorFee -= prioItem.modifiedFee
+		descendant.ancestorSize -= prioItem.size
+		if pq != nil && descendant.index >= 0 {
+			heap.Fix(pq, descendant.index)
+		}
+	}
+}
+
 // MinimumMedianTime returns the minimum allowed timestamp for a block building
 // on the end of the provided best chain.  In particular, it is one second after
 // the median timestamp of the last several blocks per the chain consensus
@@ -353,6 +519,14 @@ type BlkTmplGenerator struct {
 	timeSource  blockchain.MedianTimeSource
 	sigCache    *txscript.SigCache
 	hashCache   *txscript.HashCache
+
+	// fetchUtxoView fetches the outputs of the main chain spent by a
+	// transaction.  It is the FetchUtxoView method of the chain.
+	fetchUtxoView func(tx *eacutil.Tx) (*blockchain.UtxoViewpoint, error)
+
+	// cache houses the transactions of the source pool prepared for
+	// selection along with the last selection made from them.
+	cache selectionCache
 }
 
 // NewBlkTmplGenerator returns a new block template generator for the given
@@ -368,13 +542,14 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 	hashCache *txscript.HashCache) *BlkTmplGenerator {
 
 	return &BlkTmplGenerator{
-		policy:      policy,
-		chainParams: params,
-		txSource:    txSource,
-		chain:       chain,
-		timeSource:  timeSource,
-		sigCache:    sigCache,
-		hashCache:   hashCache,
+		policy:        policy,
+		chainParams:   params,
+		txSource:      txSource,
+		chain:         chain,
+		timeSource:    timeSource,
+		sigCache:      sigCache,
+		hashCache:     hashCache,
+		fetchUtxoView: chain.FetchUtxoView,
 	}
 }
 
@@ -406,11 +581,14 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 //
 // Once the high-priority area (if configured) has been filled with
 // transactions, or the priority falls below what is considered high-priority,
-// the priority queue is updated to prioritize by fees per kilobyte (then
-// priority).
+// the remaining transactions are prioritized by the fee per kilobyte they pay
+// along with all of their ancestors which are not in the block yet (then
+// priority).  A transaction is added together with those ancestors, so a
+// transaction paying a high fee gets the low-fee transactions it depends on
+// included as well (child pays for parent).
 //
-// When the fees per kilobyte drop below the TxMinFreeFee policy setting, the
-// transaction will be skipped unless the BlockMinSize policy setting is
+// When the fees per kilobyte of a transaction and its ancestors drop below the
+// TxMinFreeFee policy setting, the transaction will be skipped unless the BlockMinSize policy setting is
 // nonzero, in which case the block will be filled with the low-fee/free
 // transactions until the block size reaches that minimum size.
 //
@@ -441,6 +619,25 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 //  |  <= policy.BlockMinSize)          |   |
 //   -----------------------------------  --
 func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress eacutil.Address) (*BlockTemplate, error) {
+	return g.NewBlockTemplateWithOptions(payToAddress, nil)
+}
+
+// NewBlockTemplateWithOptions returns a new block template like NewBlockTemplate
+// which is changed by the passed options.  When the options specify the
+// transactions of the block, an error is returned if any of them can't be
+// included rather than skipping it.  The timestamp and version of the options
+// are subject to the consensus rules as well, so for example a timestamp which
+// isn't after the median time of the last several blocks is an error.
+//
+// Templates created with the options only need to be valid for the current
+// best chain.  It is up to the caller to decide whether they are worth mining,
+// which makes the function useful to deterministically build blocks for tests
+// such as with the generateblock RPC.
+func (g *BlkTmplGenerator) NewBlockTemplateWithOptions(payToAddress eacutil.Address, opts *TemplateOptions) (*BlockTemplate, error) {
+	if opts == nil {
+		opts = &TemplateOptions{}
+	}
+
 	// Extend the most recently known best block.
 	best := g.chain.BestSnapshot()
 	nextBlockHeight := best.Height + 1
@@ -454,7 +651,8 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress eacutil.Address) (*Bloc
 	// same value to the same public key address would otherwise be an
 	// identical transaction for block version 1).
 	extraNonce := uint64(0)
-	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight, extraNonce)
+	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight, extraNonce,
+		g.CoinbaseFlags())
 	if err != nil {
 		return nil, err
 	}
@@ -463,23 +661,198 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress eacutil.Address) (*Bloc
 	if err != nil {
 		return nil, err
 	}
+	if len(opts.Payouts) > 0 {
+		err := PayCoinbase(coinbaseTx.MsgTx(), opts.Payouts,
+			g.policy.TxMinFreeFee)
+		if err != nil {
+			return nil, err
+		}
+	}
 	coinbaseSigOpCost := int64(blockchain.CountSigOps(coinbaseTx)) * blockchain.WitnessScaleFactor
 
-	// Get the current source transactions and create a priority queue to
-	// hold the transactions which are ready for inclusion into a block
-	// along with some priority related and fee metadata.  Reserve the same
-	// number of items that are available for the priority queue.  Also,
-	// choose the initial sort order for the priority queue based on whether
-	// or not there is an area allocated for high-priority transactions.
-	sourceTxns := g.txSource.MiningDescs()
+	// Query the version bits state to see if segwit has been activated, if
+	// so then this means that we'll include any transactions with witness
+	// data in the mempool, and also add the witness commitment as an
+	// OP_RETURN output in the coinbase transaction.
+	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
+	if err != nil {
+		return nil, err
+	}
+	segwitActive := segwitState == blockchain.ThresholdActive
+
+	// Select the transactions of the block.  The source pool isn't
+	// considered at all when the transactions of the block are specified.
+	// Otherwise the selection from the source pool is reused and updated
+	// as transactions are added to and removed from it until the best
+	// chain changes.
+	var sel *txSelection
+	if opts.Transactions != nil {
+		sel, err = g.selectTransactions(nextBlockHeight, nil,
+			opts.Transactions, coinbaseTx, coinbaseSigOpCost,
+			segwitActive)
+	} else {
+		sel, err = g.cache.selection(g, best, coinbaseTx,
+			coinbaseSigOpCost, segwitActive)
+	}
+	if err != nil {
+		return nil, err
+	}
+	blockTxns := make([]*eacutil.Tx, 0, len(sel.txns)+1)
+	blockTxns = append(blockTxns, coinbaseTx)
+	blockTxns = append(blockTxns, sel.txns...)
+	txFees := make([]int64, 0, len(blockTxns))
+	txFees = append(txFees, -sel.totalFees)
+	txFees = append(txFees, sel.fees...)
+	txSigOpCosts := make([]int64, 0, len(blockTxns))
+	txSigOpCosts = append(txSigOpCosts, coinbaseSigOpCost)
+	txSigOpCosts = append(txSigOpCosts, sel.sigOpCosts...)
+	totalFees := sel.totalFees
+	blockSigOpCost := sel.blockSigOpCost
+
+	// Now that the actual transactions have been selected, update the
+	// block weight for the real transaction count and coinbase value with
+	// the total fees accordingly.
+	blockWeight := sel.blockWeight - (wire.MaxVarIntPayload -
+		(uint32(wire.VarIntSerializeSize(uint64(len(blockTxns)))) *
+			blockchain.WitnessScaleFactor))
+	if len(opts.Payouts) > 0 {
+		// Split the fees among the payouts along with the subsidy.
+		// Shares which were dust without the fees may not be anymore,
+		// so the weight of the block is updated for the outputs which
+		// are added.
+		reward := blockchain.CalcBlockSubsidy(nextBlockHeight,
+			g.chainParams) + totalFees
+		outputs, err := payoutOutputs(opts.Payouts, reward,
+			g.policy.TxMinFreeFee)
+		if err != nil {
+			return nil, err
+		}
+		oldWeight := blockchain.GetTransactionWeight(coinbaseTx)
+		coinbaseTx.MsgTx().TxOut = outputs
+		blockWeight += uint32(blockchain.GetTransactionWeight(coinbaseTx) -
+			oldWeight)
+	} else {
+		coinbaseTx.MsgTx().TxOut[0].Value += totalFees
+	}
+
+	// If segwit is active and we included transactions with witness data,
+	// then we'll need to include a commitment to the witness data in an
+	// OP_RETURN output within the coinbase transaction.
+	var witnessCommitment []byte
+	if sel.witnessIncluded {
+		// The witness of the coinbase transaction MUST be exactly 32-bytes
+		// of all zeroes.
+		var witnessNonce [blockchain.CoinbaseWitnessDataLen]byte
+		coinbaseTx.MsgTx().TxIn[0].Witness = wire.TxWitness{witnessNonce[:]}
+
+		// With the commitment calculated along with the selection, the
+		// witness script for the output is: OP_RETURN OP_DATA_36
+		// {0xaa21a9ed || witnessCommitment}. The leading prefix is
+		// referred to as the "witness magic bytes".
+		witnessCommitment = sel.witnessCommitment
+		witnessScript := append(blockchain.WitnessMagicBytes, witnessCommitment...)
+
+		// Finally, create the OP_RETURN carrying witness commitment
+		// output as an additional output within the coinbase.
+		commitmentOutput := &wire.TxOut{
+			Value:    0,
+			PkScript: witnessScript,
+		}
+		coinbaseTx.MsgTx().TxOut = append(coinbaseTx.MsgTx().TxOut,
+			commitmentOutput)
+	}
+
+	// Calculate the required difficulty for the block.  The timestamp
+	// is potentially adjusted to ensure it comes after the median time of
+	// the last several blocks per the chain consensus rules unless it is
+	// specified, in which case the check of the block below ensures it is.
+	ts := medianAdjustedTime(best, g.timeSource)
+	if !opts.Timestamp.IsZero() {
+		ts = time.Unix(opts.Timestamp.Unix(), 0)
+	}
+	reqDifficulty, err := g.chain.CalcNextRequiredDifficulty(ts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Calculate the next expected block version based on the state of the
+	// rule change deployments.
+	nextBlockVersion, err := g.chain.CalcNextBlockVersion()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Version != 0 {
+		nextBlockVersion = opts.Version
+	}
+
+	// Create a new block ready to be solved.  The merkle root is only
+	// calculated again when the selection or the coinbase changed.
+	var msgBlock wire.MsgBlock
+	msgBlock.Header = wire.BlockHeader{
+		Version:    nextBlockVersion,
+		PrevBlock:  best.Hash,
+		MerkleRoot: sel.merkleRoot(blockTxns),
+		Timestamp:  ts,
+		Bits:       reqDifficulty,
+	}
+	for _, tx := range blockTxns {
+		if err := msgBlock.AddTransaction(tx.MsgTx()); err != nil {
+			return nil, err
+		}
+	}
+
+	// Finally, perform a full check on the created block against the chain
+	// consensus rules to ensure it properly connects to the current best
+	// chain with no issues.
+	block := eacutil.NewBlock(&msgBlock)
+	block.SetHeight(nextBlockHeight)
+	if err := g.chain.CheckConnectBlockTemplate(block); err != nil {
+		return nil, err
+	}
+
+	log.Debugf("Created new block template (%d transactions, %d in "+
+		"fees, %d signature operations cost, %d weight, target difficulty "+
+		"%064x)", len(msgBlock.Transactions), totalFees, blockSigOpCost,
+		blockWeight, blockchain.CompactToBig(msgBlock.Header.Bits))
+
+	return &BlockTemplate{
+		Block:             &msgBlock,
+		Fees:              txFees,
+		SigOpCosts:        txSigOpCosts,
+		Height:            nextBlockHeight,
+		ValidPayAddress:   payToAddress != nil || len(opts.Payouts) > 0,
+		WitnessCommitment: witnessCommitment,
+	}, nil
+}
+
+// selectTransactions selects the transactions of a block which extends the
+// current best chain and has the passed coinbase.  When txns is not nil,
+// exactly those transactions are selected in their order and an error is
+// returned if any of them can't be included.  Otherwise the transactions are
+// selected from the passed prepared transactions of the source pool as
+// described by NewBlockTemplate.
+//
+// The transactions from the source pool are only read, so they can be shared
+// by subsequent selections, except that the result of validating their scripts
+// is remembered.
+func (g *BlkTmplGenerator) selectTransactions(nextBlockHeight int32,
+	candidates []*preparedTx, txns []*eacutil.Tx, coinbaseTx *eacutil.Tx,
+	coinbaseSigOpCost int64, segwitActive bool) (*txSelection, error) {
+
+	// Create a priority queue to hold the transactions which are ready for
+	// inclusion into a block along with some priority related and fee
+	// metadata.  Reserve the same number of items that are available for
+	// the priority queue.  Also, choose the initial sort order for the
+	// priority queue based on whether or not there is an area allocated
+	// for high-priority transactions.
 	sortedByFee := g.policy.BlockPrioritySize == 0
-	priorityQueue := newTxPriorityQueue(len(sourceTxns), sortedByFee)
+	priorityQueue := newTxPriorityQueue(len(candidates), sortedByFee)
 
 	// Create a slice to hold the transactions to be included in the
 	// generated block with reserved space.  Also create a utxo view to
 	// house all of the input transactions so multiple lookups can be
 	// avoided.
-	blockTxns := make([]*eacutil.Tx, 0, len(sourceTxns))
+	blockTxns := make([]*eacutil.Tx, 0, len(candidates)+len(txns)+1)
 	blockTxns = append(blockTxns, coinbaseTx)
 	blockUtxos := blockchain.NewUtxoViewpoint()
 
@@ -490,94 +863,68 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress eacutil.Address) (*Bloc
 	// in the block once each transaction has been included.
 	dependers := make(map[chainhash.Hash]map[chainhash.Hash]*txPrioItem)
 
+	// prioItems holds all of the transactions which may be included in the
+	// block keyed by their hash.
+	prioItems := make(map[chainhash.Hash]*txPrioItem, len(candidates))
+
 	// Create slices to hold the fees and number of signature operations
 	// for each of the selected transactions and add an entry for the
 	// coinbase.  This allows the code below to simply append details about
 	// a transaction as it is selected for inclusion in the final block.
 	// However, since the total fees aren't known yet, use a dummy value for
 	// the coinbase fee which will be updated later.
-	txFees := make([]int64, 0, len(sourceTxns))
-	txSigOpCosts := make([]int64, 0, len(sourceTxns))
+	txFees := make([]int64, 0, cap(blockTxns))
+	txSigOpCosts := make([]int64, 0, cap(blockTxns))
 	txFees = append(txFees, -1) // Updated once known
 	txSigOpCosts = append(txSigOpCosts, coinbaseSigOpCost)
+	txFeeRates := make([]int64, 0, len(candidates)+len(txns))
 
 	log.Debugf("Considering %d transactions for inclusion to new block",
-		len(sourceTxns))
+		len(candidates))
 
-mempoolLoop:
-	for _, txDesc := range sourceTxns {
-		// A block can't have more than one coinbase or contain
-		// non-finalized transactions.
-		tx := txDesc.Tx
-		if blockchain.IsCoinBase(tx) {
-			log.Tracef("Skipping coinbase tx %s", tx.Hash())
-			continue
-		}
+	// timeSensitive is set when a transaction is skipped because it is not
+	// final yet, which may change with the adjusted time alone.
+	timeSensitive := false
+
+	for _, prepared := range candidates {
+		// A block can't contain non-finalized transactions.
+		tx := prepared.desc.Tx
 		if !blockchain.IsFinalizedTransaction(tx, nextBlockHeight,
 			g.timeSource.AdjustedTime()) {
 
 			log.Tracef("Skipping non-finalized tx %s", tx.Hash())
-			continue
-		}
-
-		// Fetch all of the utxos referenced by the this transaction.
-		// NOTE: This intentionally does not fetch inputs from the
-		// mempool since a transaction which depends on other
-		// transactions in the mempool must come after those
-		// dependencies in the final generated block.
-		utxos, err := g.chain.FetchUtxoView(tx)
-		if err != nil {
-			log.Warnf("Unable to fetch utxo view for tx %s: %v",
-				tx.Hash(), err)
+			timeSensitive = true
 			continue
 		}
 
 		// Setup dependencies for any transactions which reference
-		// other transactions in the mempool so they can be properly
-		// ordered below.
-		prioItem := &txPrioItem{tx: tx}
-		for _, txIn := range tx.MsgTx().TxIn {
-			originHash := &txIn.PreviousOutPoint.Hash
-			entry := utxos.LookupEntry(txIn.PreviousOutPoint)
-			if entry == nil || entry.IsSpent() {
-				if !g.txSource.HaveTransaction(originHash) {
-					log.Tracef("Skipping tx %s because it "+
-						"references unspent output %s "+
-						"which is not available",
-						tx.Hash(), txIn.PreviousOutPoint)
-					continue mempoolLoop
-				}
-
-				// The transaction is referencing another
-				// transaction in the source pool, so setup an
-				// ordering dependency.
-				deps, exists := dependers[*originHash]
-				if !exists {
-					deps = make(map[chainhash.Hash]*txPrioItem)
-					dependers[*originHash] = deps
-				}
-				deps[*prioItem.tx.Hash()] = prioItem
-				if prioItem.dependsOn == nil {
-					prioItem.dependsOn = make(
-						map[chainhash.Hash]struct{})
-				}
-				prioItem.dependsOn[*originHash] = struct{}{}
-
-				// Skip the check below. We already know the
-				// referenced transaction is available.
-				continue
+		// other transactions in the source pool so they can be
+		// properly ordered below.
+		prioItem := &txPrioItem{tx: tx, prepared: prepared, index: -1}
+		for _, originHash := range prepared.dependsOn {
+			deps, exists := dependers[originHash]
+			if !exists {
+				deps = make(map[chainhash.Hash]*txPrioItem)
+				dependers[originHash] = deps
+			}
+			deps[*tx.Hash()] = prioItem
+			if prioItem.dependsOn == nil {
+				prioItem.dependsOn = make(
+					map[chainhash.Hash]struct{})
 			}
+			prioItem.dependsOn[originHash] = struct{}{}
 		}
 
-		// Calculate the final transaction priority using the input
-		// value age sum as well as the adjusted transaction size.  The
-		// formula is: sum(inputValue * inputAge) / adjustedTxSize
-		prioItem.priority = CalcPriority(tx.MsgTx(), utxos,
-			nextBlockHeight)
-
-		// Calculate the fee in Satoshi/kB.
-		prioItem.feePerKB = txDesc.FeePerKB
+		// Transactions are prioritized by their fee adjusted by their
+		// fee delta while the block collects the fees they actually
+		// pay.
+		txDesc := prepared.desc
+		prioItem.priority = prepared.priority
+		prioItem.feePerKB = prepared.feePerKB()
 		prioItem.fee = txDesc.Fee
+		prioItem.modifiedFee = txDesc.Fee + txDesc.FeeDelta
+		prioItem.size = prepared.size
+		prioItems[*tx.Hash()] = prioItem
 
 		// Add the transaction to the priority queue to mark it ready
 		// for inclusion in the block unless it has dependencies.
@@ -585,12 +932,24 @@ mempoolLoop:
 			heap.Push(priorityQueue, prioItem)
 		}
 
-		// Merge the referenced outputs from the input transactions to
-		// this transaction into the block utxo view.  This allows the
-		// code below to avoid a second lookup.
-		mergeUtxoView(blockUtxos, utxos)
+		// Add copies of the referenced outputs from the input
+		// transactions to this transaction to the block utxo view.
+		// The view of the prepared transaction is shared with later
+		// selections, so its entries must not be spent.
+		for outpoint, entry := range prepared.utxos.Entries() {
+			blockEntry := blockUtxos.LookupEntry(outpoint)
+			if blockEntry == nil || blockEntry.IsSpent() {
+				blockUtxos.Entries()[outpoint] = entry.Clone()
+			}
+		}
 	}
 
+	// Determine the ancestors of each transaction so it can be selected
+	// along with them by the fee rate of the whole package.  Transactions
+	// depending on outputs which are neither in the main chain nor in the
+	// source pool are skipped.
+	calcAncestors(prioItems)
+
 	log.Tracef("Priority queue len %d, dependers len %d",
 		priorityQueue.Len(), len(dependers))
 
@@ -602,30 +961,41 @@ mempoolLoop:
 	blockSigOpCost := coinbaseSigOpCost
 	totalFees := int64(0)
 
-	// Query the version bits state to see if segwit has been activated, if
-	// so then this means that we'll include any transactions with witness
-	// data in the mempool, and also add the witness commitment as an
-	// OP_RETURN output in the coinbase transaction.
-	segwitState, err := g.chain.ThresholdState(chaincfg.DeploymentSegwit)
-	if err != nil {
-		return nil, err
-	}
-	segwitActive := segwitState == blockchain.ThresholdActive
-
 	witnessIncluded := false
 
-	// Choose which transactions make it into the block.
-	for priorityQueue.Len() > 0 {
-		// Grab the highest priority (or highest fee per kilobyte
-		// depending on the sort order) transaction.
-		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
+	// included and failed track the transactions which were added to the
+	// block and those which could not be added.  packageQueue is the
+	// priority queue of the transactions sorted by their ancestor fee rate
+	// once the high-priority area has been filled.
+	included := make(map[chainhash.Hash]struct{})
+	failed := make(map[chainhash.Hash]struct{})
+	var packageQueue *txPriorityQueue
+
+	// includeTx adds the passed transaction to the block unless that would
+	// make the block invalid or exceed the policy limits.  The ancestor
+	// fees and sizes of the transactions depending on it are updated
+	// accordingly.  It returns the reason when the transaction can't be
+	// added.
+	includeTx := func(prioItem *txPrioItem) error {
 		tx := prioItem.tx
 
+		// Grab any transactions which depend on this one.
+		deps := dependers[*tx.Hash()]
+
+		// skip logs why the transaction isn't added along with the
+		// transactions depending on it and returns the reason.
+		skip := func(err error) error {
+			log.Tracef("Skipping tx: %v", err)
+			logSkippedDeps(tx, deps)
+			return err
+		}
+
 		switch {
 		// If segregated witness has not been activated yet, then we
 		// shouldn't include any witness transactions in the block.
 		case !segwitActive && tx.HasWitness():
-			continue
+			return skip(fmt.Errorf("tx %s has witness data while "+
+				"segwit is not active", tx.Hash()))
 
 		// Otherwise, Keep track of if we've included a transaction
 		// with witness data or not. If so, then we'll need to include
@@ -660,19 +1030,14 @@ mempoolLoop:
 			witnessIncluded = true
 		}
 
-		// Grab any transactions which depend on this one.
-		deps := dependers[*tx.Hash()]
-
 		// Enforce maximum block size.  Also check for overflow.
 		txWeight := uint32(blockchain.GetTransactionWeight(tx))
 		blockPlusTxWeight := blockWeight + txWeight
 		if blockPlusTxWeight < blockWeight ||
 			blockPlusTxWeight >= g.policy.BlockMaxWeight {
 
-			log.Tracef("Skipping tx %s because it would exceed "+
-				"the max block weight", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
+			return skip(fmt.Errorf("tx %s would exceed the max "+
+				"block weight", tx.Hash()))
 		}
 
 		// Enforce maximum signature operation cost per block.  Also
@@ -680,81 +1045,42 @@ mempoolLoop:
 		sigOpCost, err := blockchain.GetSigOpCost(tx, false,
 			blockUtxos, true, segwitActive)
 		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"GetSigOpCost: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+			return skip(fmt.Errorf("error in GetSigOpCost of tx "+
+				"%s: %v", tx.Hash(), err))
 		}
 		if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
 			blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
-			log.Tracef("Skipping tx %s because it would "+
-				"exceed the maximum sigops per block", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
-		}
-
-		// Skip free transactions once the block is larger than the
-		// minimum block size.
-		if sortedByFee &&
-			prioItem.feePerKB < int64(g.policy.TxMinFreeFee) &&
-			blockPlusTxWeight >= g.policy.BlockMinWeight {
-
-			log.Tracef("Skipping tx %s with feePerKB %d "+
-				"< TxMinFreeFee %d and block weight %d >= "+
-				"minBlockWeight %d", tx.Hash(), prioItem.feePerKB,
-				g.policy.TxMinFreeFee, blockPlusTxWeight,
-				g.policy.BlockMinWeight)
-			logSkippedDeps(tx, deps)
-			continue
-		}
-
-		// Prioritize by fee per kilobyte once the block is larger than
-		// the priority size or there are no more high-priority
-		// transactions.
-		if !sortedByFee && (blockPlusTxWeight >= g.policy.BlockPrioritySize ||
-			prioItem.priority <= MinHighPriority) {
-
-			log.Tracef("Switching to sort by fees per "+
-				"kilobyte blockSize %d >= BlockPrioritySize "+
-				"%d || priority %.2f <= minHighPriority %.2f",
-				blockPlusTxWeight, g.policy.BlockPrioritySize,
-				prioItem.priority, MinHighPriority)
-
-			sortedByFee = true
-			priorityQueue.SetLessFunc(txPQByFee)
-
-			// Put the transaction back into the priority queue and
-			// skip it so it is re-priortized by fees if it won't
-			// fit into the high-priority section or the priority
-			// is too low.  Otherwise this transaction will be the
-			// final one in the high-priority section, so just fall
-			// though to the code below so it is added now.
-			if blockPlusTxWeight > g.policy.BlockPrioritySize ||
-				prioItem.priority < MinHighPriority {
-
-				heap.Push(priorityQueue, prioItem)
-				continue
-			}
+			return skip(fmt.Errorf("tx %s would exceed the maximum "+
+				"sigops per block", tx.Hash()))
 		}
 
 		// Ensure the transaction inputs pass all of the necessary
 		// preconditions before allowing it to be added to the block.
-		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
-			blockUtxos, g.chainParams)
+		// The fee is taken from the inputs rather than the source pool
+		// since the transaction may not be in it.
+		txFee, err := blockchain.CheckTransactionInputs(tx,
+			nextBlockHeight, blockUtxos, g.chainParams)
 		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"CheckTransactionInputs: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+			return skip(fmt.Errorf("error in CheckTransactionInputs "+
+				"of tx %s: %v", tx.Hash(), err))
 		}
-		err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
-			txscript.StandardVerifyFlags, g.sigCache,
-			g.hashCache)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"ValidateTransactionScripts: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+
+		// The scripts of a transaction from the source pool only need
+		// to be validated once for the current best chain since the
+		// outputs it spends don't change.
+		prepared := prioItem.prepared
+		if prepared == nil || !prepared.scriptsValid {
+			err = blockchain.ValidateTransactionScripts(tx,
+				blockUtxos, txscript.StandardVerifyFlags,
+				g.sigCache, g.hashCache)
+			if err != nil {
+				return skip(fmt.Errorf("error in "+
+					"ValidateTransactionScripts of tx %s: %v",
+					tx.Hash(), err))
+			}
+			if prepared != nil {
+				prepared.scriptsValid = true
+			}
 		}
 
 		// Spend the transaction inputs in the block utxo view and add
@@ -769,17 +1095,93 @@ mempoolLoop:
 		blockTxns = append(blockTxns, tx)
 		blockWeight += txWeight
 		blockSigOpCost += int64(sigOpCost)
-		totalFees += prioItem.fee
-		txFees = append(txFees, prioItem.fee)
+		totalFees += txFee
+		txFees = append(txFees, txFee)
 		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
+		txFeeRates = append(txFeeRates, prioItem.feePerKB)
+		included[*tx.Hash()] = struct{}{}
 
 		log.Tracef("Adding tx %s (priority %.2f, feePerKB %.2f)",
 			prioItem.tx.Hash(), prioItem.priority, prioItem.feePerKB)
 
+		// The transactions which depend on this one no longer need to
+		// pay for it.
+		removeIncludedAncestor(prioItem, packageQueue)
+		return nil
+	}
+
+	// Add exactly the specified transactions in their order when the
+	// transactions of the block are specified.  The outputs they spend
+	// are all fetched before any of them is added so spending an output
+	// twice within the block is detected as well.
+	for _, tx := range txns {
+		if blockchain.IsCoinBase(tx) {
+			return nil, fmt.Errorf("tx %s is a coinbase", tx.Hash())
+		}
+		if !blockchain.IsFinalizedTransaction(tx, nextBlockHeight,
+			g.timeSource.AdjustedTime()) {
+
+			return nil, fmt.Errorf("tx %s is not finalized",
+				tx.Hash())
+		}
+		utxos, err := g.fetchUtxoView(tx)
+		if err != nil {
+			return nil, err
+		}
+		mergeUtxoView(blockUtxos, utxos)
+	}
+	for _, tx := range txns {
+		if err := includeTx(&txPrioItem{tx: tx, index: -1}); err != nil {
+			return nil, err
+		}
+	}
+
+	// Fill the high-priority area of the block first when one is
+	// configured.  Only transactions whose dependencies are already
+	// included in the block are considered.
+	for !sortedByFee && priorityQueue.Len() > 0 {
+		// Grab the highest priority transaction.
+		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
+		tx := prioItem.tx
+
+		// Prioritize by fee per kilobyte once the block is larger than
+		// the priority size or there are no more high-priority
+		// transactions.
+		blockPlusTxWeight := blockWeight +
+			uint32(blockchain.GetTransactionWeight(tx))
+		if blockPlusTxWeight >= g.policy.BlockPrioritySize ||
+			prioItem.priority <= MinHighPriority {
+
+			log.Tracef("Switching to sort by fees per "+
+				"kilobyte blockSize %d >= BlockPrioritySize "+
+				"%d || priority %.2f <= minHighPriority %.2f",
+				blockPlusTxWeight, g.policy.BlockPrioritySize,
+				prioItem.priority, MinHighPriority)
+
+			sortedByFee = true
+
+			// Leave the transaction to be prioritized by fees if
+			// it won't fit into the high-priority section or the
+			// priority is too low.  Otherwise this transaction
+			// will be the final one in the high-priority section,
+			// so just fall though to the code below so it is
+			// added now.
+			if blockPlusTxWeight > g.policy.BlockPrioritySize ||
+				prioItem.priority < MinHighPriority {
+
+				break
+			}
+		}
+
+		if includeTx(prioItem) != nil {
+			failed[*tx.Hash()] = struct{}{}
+			continue
+		}
+
 		// Add transactions which depend on this one (and also do not
 		// have any other unsatisified dependencies) to the priority
 		// queue.
-		for _, item := range deps {
+		for _, item := range dependers[*tx.Hash()] {
 			// Add the transaction to the priority queue if there
 			// are no more dependencies after this one.
 			delete(item.dependsOn, *tx.Hash())
@@ -789,110 +1191,138 @@ mempoolLoop:
 		}
 	}
 
-	// Now that the actual transactions have been selected, update the
-	// block weight for the real transaction count and coinbase value with
-	// the total fees accordingly.
-	blockWeight -= wire.MaxVarIntPayload -
-		(uint32(wire.VarIntSerializeSize(uint64(len(blockTxns)))) *
-			blockchain.WitnessScaleFactor)
-	coinbaseTx.MsgTx().TxOut[0].Value += totalFees
-	txFees[0] = -totalFees
+	// Fill the rest of the block by the fee per kilobyte the transactions
+	// pay along with their ancestors which are not included yet, so a
+	// transaction paying a high fee also gets its ancestors paying a low
+	// fee included (child pays for parent).  The queue is reordered as the
+	// ancestors of transactions get included.
+	packageQueue = newTxPriorityQueue(len(prioItems), true)
+	packageQueue.SetLessFunc(txPQByAncestorFee)
+	for _, prioItem := range prioItems {
+		prioItem.index = -1
+	}
+	for hash, prioItem := range prioItems {
+		_, isIncluded := included[hash]
+		_, isFailed := failed[hash]
+		if !isIncluded && !isFailed {
+			heap.Push(packageQueue, prioItem)
+		}
+	}
+	for packageQueue.Len() > 0 {
+		// Grab the transaction paying the highest fee per kilobyte
+		// along with its ancestors.
+		prioItem := heap.Pop(packageQueue).(*txPrioItem)
+		tx := prioItem.tx
 
-	// If segwit is active and we included transactions with witness data,
-	// then we'll need to include a commitment to the witness data in an
-	// OP_RETURN output within the coinbase transaction.
-	var witnessCommitment []byte
-	if witnessIncluded {
-		// The witness of the coinbase transaction MUST be exactly 32-bytes
-		// of all zeroes.
-		var witnessNonce [blockchain.CoinbaseWitnessDataLen]byte
-		coinbaseTx.MsgTx().TxIn[0].Witness = wire.TxWitness{witnessNonce[:]}
+		// The transaction can't be included if one of its ancestors
+		// could not be.
+		pkgWeight := blockchain.GetTransactionWeight(tx)
+		pkg := make([]*txPrioItem, 0, len(prioItem.ancestors)+1)
+		for hash, ancestor := range prioItem.ancestors {
+			if _, ok := failed[hash]; ok {
+				log.Tracef("Skipping tx %s since it depends on "+
+					"%s", tx.Hash(), hash)
+				failed[*tx.Hash()] = struct{}{}
+				pkg = nil
+				break
+			}
+			pkgWeight += blockchain.GetTransactionWeight(ancestor.tx)
+			pkg = append(pkg, ancestor)
+		}
+		if pkg == nil {
+			continue
+		}
 
-		// Next, obtain the merkle root of a tree which consists of the
-		// wtxid of all transactions in the block. The coinbase
-		// transaction will have a special wtxid of all zeroes.
-		witnessMerkleTree := blockchain.BuildMerkleTreeStore(blockTxns,
-			true)
-		witnessMerkleRoot := witnessMerkleTree[len(witnessMerkleTree)-1]
-
-		// The preimage to the witness commitment is:
-		// witnessRoot || coinbaseWitness
-		var witnessPreimage [64]byte
-		copy(witnessPreimage[:32], witnessMerkleRoot[:])
-		copy(witnessPreimage[32:], witnessNonce[:])
-
-		// The witness commitment itself is the double-sha256 of the
-		// witness preimage generated above. With the commitment
-		// generated, the witness script for the output is: OP_RETURN
-		// OP_DATA_36 {0xaa21a9ed || witnessCommitment}. The leading
-		// prefix is referred to as the "witness magic bytes".
-		witnessCommitment = chainhash.DoubleHashB(witnessPreimage[:])
-		witnessScript := append(blockchain.WitnessMagicBytes, witnessCommitment...)
+		// Enforce maximum block size for the whole package.  Also check
+		// for overflow.
+		blockPlusPkgWeight := blockWeight + uint32(pkgWeight)
+		if blockPlusPkgWeight < blockWeight ||
+			blockPlusPkgWeight >= g.policy.BlockMaxWeight {
 
-		// Finally, create the OP_RETURN carrying witness commitment
-		// output as an additional output within the coinbase.
-		commitmentOutput := &wire.TxOut{
-			Value:    0,
-			PkScript: witnessScript,
+			log.Tracef("Skipping tx %s because it would exceed "+
+				"the max block weight along with its ancestors",
+				tx.Hash())
+			continue
 		}
-		coinbaseTx.MsgTx().TxOut = append(coinbaseTx.MsgTx().TxOut,
-			commitmentOutput)
-	}
 
-	// Calculate the required difficulty for the block.  The timestamp
-	// is potentially adjusted to ensure it comes after the median time of
-	// the last several blocks per the chain consensus rules.
-	ts := medianAdjustedTime(best, g.timeSource)
-	reqDifficulty, err := g.chain.CalcNextRequiredDifficulty(ts)
-	if err != nil {
-		return nil, err
-	}
+		// Skip free transactions once the block is larger than the
+		// minimum block size.
+		pkgFeePerKB := prioItem.ancestorFeePerKB()
+		if pkgFeePerKB < int64(g.policy.TxMinFreeFee) &&
+			blockPlusPkgWeight >= g.policy.BlockMinWeight {
 
-	// Calculate the next expected block version based on the state of the
-	// rule change deployments.
-	nextBlockVersion, err := g.chain.CalcNextBlockVersion()
-	if err != nil {
-		return nil, err
-	}
+			log.Tracef("Skipping tx %s with ancestor feePerKB %d "+
+				"< TxMinFreeFee %d and block weight %d >= "+
+				"minBlockWeight %d", tx.Hash(), pkgFeePerKB,
+				g.policy.TxMinFreeFee, blockPlusPkgWeight,
+				g.policy.BlockMinWeight)
+			continue
+		}
 
-	// Create a new block ready to be solved.
-	merkles := blockchain.BuildMerkleTreeStore(blockTxns, false)
-	var msgBlock wire.MsgBlock
-	msgBlock.Header = wire.BlockHeader{
-		Version:    nextBlockVersion,
-		PrevBlock:  best.Hash,
-		MerkleRoot: *merkles[len(merkles)-1],
-		Timestamp:  ts,
-		Bits:       reqDifficulty,
-	}
-	for _, tx := range blockTxns {
-		if err := msgBlock.AddTransaction(tx.MsgTx()); err != nil {
-			return nil, err
+		// Add the ancestors before the transaction itself.  Each
+		// ancestor has fewer ancestors left to include than the
+		// transactions depending on it, so ordering by that number
+		// puts every transaction after the ones it depends on.
+		pkg = append(pkg, prioItem)
+		sort.Slice(pkg, func(i, j int) bool {
+			return len(pkg[i].ancestors) < len(pkg[j].ancestors)
+		})
+		for _, item := range pkg {
+			if item.index >= 0 {
+				heap.Remove(packageQueue, item.index)
+			}
+			if includeTx(item) != nil {
+				failed[*item.tx.Hash()] = struct{}{}
+				failed[*tx.Hash()] = struct{}{}
+				break
+			}
 		}
 	}
 
-	// Finally, perform a full check on the created block against the chain
-	// consensus rules to ensure it properly connects to the current best
-	// chain with no issues.
-	block := eacutil.NewBlock(&msgBlock)
-	block.SetHeight(nextBlockHeight)
-	if err := g.chain.CheckConnectBlockTemplate(block); err != nil {
-		return nil, err
+	// The witness commitment only depends on the transactions after the
+	// coinbase, whose witness transaction id is all zeroes, so it is
+	// calculated along with the selection.
+	sel := &txSelection{
+		txns:              blockTxns[1:],
+		fees:              txFees[1:],
+		sigOpCosts:        txSigOpCosts[1:],
+		blockWeight:       blockWeight,
+		blockSigOpCost:    blockSigOpCost,
+		totalFees:         totalFees,
+		coinbaseWeight:    blockchain.GetTransactionWeight(coinbaseTx),
+		coinbaseSigOpCost: coinbaseSigOpCost,
+		witnessIncluded:   witnessIncluded,
+		included:          included,
+		timeSensitive:     timeSensitive,
+		feeRates:          txFeeRates,
+		complete: len(txns) == 0 && !timeSensitive &&
+			len(included) == len(candidates),
 	}
+	if witnessIncluded {
+		sel.witnessCommitment = calcWitnessCommitment(blockTxns)
+	}
+	return sel, nil
+}
 
-	log.Debugf("Created new block template (%d transactions, %d in "+
-		"fees, %d signature operations cost, %d weight, target difficulty "+
-		"%064x)", len(msgBlock.Transactions), totalFees, blockSigOpCost,
-		blockWeight, blockchain.CompactToBig(msgBlock.Header.Bits))
-
-	return &BlockTemplate{
-		Block:             &msgBlock,
-		Fees:              txFees,
-		SigOpCosts:        txSigOpCosts,
-		Height:            nextBlockHeight,
-		ValidPayAddress:   payToAddress != nil,
-		WitnessCommitment: witnessCommitment,
-	}, nil
+// calcWitnessCommitment returns the witness commitment of the passed block
+// transactions, which must start with a coinbase.
+func calcWitnessCommitment(blockTxns []*eacutil.Tx) []byte {
+	// Obtain the merkle root of a tree which consists of the wtxid of all
+	// transactions in the block.  The coinbase transaction will have a
+	// special wtxid of all zeroes.
+	witnessMerkleTree := blockchain.BuildMerkleTreeStore(blockTxns, true)
+	witnessMerkleRoot := witnessMerkleTree[len(witnessMerkleTree)-1]
+
+	// The preimage to the witness commitment is:
+	// witnessRoot || coinbaseWitness
+	// The witness of the coinbase transaction MUST be exactly 32-bytes of
+	// all zeroes.
+	var witnessPreimage [64]byte
+	copy(witnessPreimage[:32], witnessMerkleRoot[:])
+
+	// The witness commitment itself is the double-sha256 of the witness
+	// preimage generated above.
+	return chainhash.DoubleHashB(witnessPreimage[:])
 }
 
 // UpdateBlockTime updates the timestamp in the header of the passed block to
@@ -925,7 +1355,8 @@ func (g *BlkTmplGenerator) UpdateBlockTime(msgBlock *wire.MsgBlock) error {
 // height.  It also recalculates and updates the new merkle root that results
 // from changing the coinbase script.
 func (g *BlkTmplGenerator) UpdateExtraNonce(msgBlock *wire.MsgBlock, blockHeight int32, extraNonce uint64) error {
-	coinbaseScript, err := standardCoinbaseScript(blockHeight, extraNonce)
+	coinbaseScript, err := standardCoinbaseScript(blockHeight, extraNonce,
+		g.CoinbaseFlags())
 	if err != nil {
 		return err
 	}
@@ -958,6 +1389,17 @@ func (g *BlkTmplGenerator) BestSnapshot() *blockchain.BestState {
 	return g.chain.BestSnapshot()
 }
 
+// CoinbaseFlags returns the flags which are added to the coinbase script of
+// the generated blocks.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) CoinbaseFlags() string {
+	if g.policy.CoinbaseFlags != "" {
+		return g.policy.CoinbaseFlags
+	}
+	return CoinbaseFlags
+}
+
 // TxSource returns the associated transaction source.
 //
 // This function is safe for concurrent access.
@@ -9,6 +9,8 @@ import (
 	"math/rand"
 	"testing"
 
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/wire"
 	"github.com/eacsuite/eacutil"
 )
 
@@ -108,3 +110,104 @@ func TestTxFeePrioHeap(t *testing.T) {
 		highest = prioItem
 	}
 }
+
+// TestTxAncestorFeeHeap ensures transactions are ordered by the fee rate they
+// pay along with their ancestors and that the order is updated when ancestors
+// are included in a block.
+func TestTxAncestorFeeHeap(t *testing.T) {
+	// newItem returns an item for a transaction with the passed fee and
+	// size spending an output of each of the passed parents.
+	var lockTime uint32
+	newItem := func(fee, size int64, parents ...*txPrioItem) *txPrioItem {
+		lockTime++
+		msgTx := wire.NewMsgTx(wire.TxVersion)
+		msgTx.LockTime = lockTime
+		item := &txPrioItem{fee: fee, modifiedFee: fee, size: size,
+			index: -1}
+		for _, parent := range parents {
+			prevOut := wire.NewOutPoint(parent.tx.Hash(), 0)
+			msgTx.AddTxIn(wire.NewTxIn(prevOut, nil, nil))
+			if item.dependsOn == nil {
+				item.dependsOn = make(map[chainhash.Hash]struct{})
+			}
+			item.dependsOn[*parent.tx.Hash()] = struct{}{}
+		}
+		item.tx = eacutil.NewTx(msgTx)
+		item.feePerKB = fee * 1000 / size
+		return item
+	}
+
+	parent := newItem(100, 1000)
+	child := newItem(10000, 1000, parent)
+	grandchild := newItem(0, 1000, child)
+	standalone := newItem(4000, 1000)
+	missing := newItem(5000, 1000)
+	orphan := newItem(100000, 1000, missing)
+
+	// The fee delta of a prioritized transaction is used instead of the
+	// fee it pays.
+	prioritized := newItem(100, 1000)
+	prioritized.modifiedFee = 20000
+	prioritized.feePerKB = 20000
+
+	prioItems := make(map[chainhash.Hash]*txPrioItem)
+	for _, item := range []*txPrioItem{parent, child, grandchild,
+		standalone, orphan, prioritized} {
+
+		prioItems[*item.tx.Hash()] = item
+	}
+	calcAncestors(prioItems)
+
+	if _, ok := prioItems[*orphan.tx.Hash()]; ok {
+		t.Fatal("transaction with an unavailable parent was kept")
+	}
+	if len(grandchild.ancestors) != 2 || grandchild.ancestorFee != 10100 ||
+		grandchild.ancestorSize != 3000 {
+
+		t.Fatalf("unexpected grandchild ancestors %d, fee %d, size %d",
+			len(grandchild.ancestors), grandchild.ancestorFee,
+			grandchild.ancestorSize)
+	}
+	if len(parent.descendants) != 2 {
+		t.Fatalf("got %d parent descendants, want 2",
+			len(parent.descendants))
+	}
+
+	pq := newTxPriorityQueue(len(prioItems), true)
+	pq.SetLessFunc(txPQByAncestorFee)
+	for _, item := range prioItems {
+		heap.Push(pq, item)
+	}
+
+	if top := heap.Pop(pq).(*txPrioItem); top != prioritized {
+		t.Fatalf("got %v first, want the prioritized transaction",
+			top.tx.Hash())
+	}
+
+	// The child pays for its parent, so it comes before the standalone
+	// transaction even though the parent pays less.
+	if top := heap.Pop(pq).(*txPrioItem); top != child {
+		t.Fatalf("got %v next, want the child", top.tx.Hash())
+	}
+
+	// Including the parent and the child leaves the grandchild with no
+	// ancestors left to pay for, so it comes last.
+	heap.Remove(pq, parent.index)
+	removeIncludedAncestor(parent, pq)
+	removeIncludedAncestor(child, pq)
+	if grandchild.ancestorFee != 0 || grandchild.ancestorSize != 1000 ||
+		len(grandchild.ancestors) != 0 {
+
+		t.Fatalf("unexpected grandchild ancestors %d, fee %d, size %d "+
+			"after including its ancestors",
+			len(grandchild.ancestors), grandchild.ancestorFee,
+			grandchild.ancestorSize)
+	}
+	want := []*txPrioItem{standalone, grandchild}
+	for i, wantItem := range want {
+		if item := heap.Pop(pq).(*txPrioItem); item != wantItem {
+			t.Fatalf("item %d: got %v, want %v", i, item.tx.Hash(),
+				wantItem.tx.Hash())
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/eacsuite/eacd/blockchain"
+	"github.com/eacsuite/eacd/txscript"
+	"github.com/eacsuite/eacd/wire"
+	"github.com/eacsuite/eacutil"
+)
+
+// Payout is a recipient of a share of the reward of a generated block.  The
+// share is the weight of the payout relative to the total weight of all of the
+// payouts of the block.
+type Payout struct {
+	Address eacutil.Address
+	Weight  uint32
+}
+
+// isDustShare returns whether or not the passed payout output is dust under
+// the passed minimum relay fee in Satoshi/1000 bytes.  It follows the dust rule
+// of the memory pool policy, which can't be imported here, so the outputs
+// generated blocks pay are never too small to be spent by a standard
+// transaction.  Outputs which pay nothing are always dust.
+func isDustShare(txOut *wire.TxOut, minRelayTxFee eacutil.Amount) bool {
+	if txOut.Value <= 0 {
+		return true
+	}
+
+	// The size of the output along with the size of a typical input
+	// spending it, with the witness discount applied to the signature and
+	// public key of witness programs.
+	totalSize := txOut.SerializeSize() + 41
+	if txscript.IsWitnessProgram(txOut.PkScript) {
+		totalSize += 107 / blockchain.WitnessScaleFactor
+	} else {
+		totalSize += 107
+	}
+
+	// This is equivalent to (value/totalSize) * (1/3) * 1000 < fee without
+	// the multiplication of the value, which overflows for the large
+	// values coinbase outputs can have.
+	threshold := (3*int64(totalSize)*int64(minRelayTxFee) + 999) / 1000
+	return txOut.Value < threshold
+}
+
+// payoutOutputs returns the transaction outputs which split the passed value
+// among the passed payouts in proportion to their weights.  The remainder of
+// the integer division goes to the first payout so the outputs always add up to
+// the passed value.  Shares which would be dust under the passed minimum relay
+// fee in Satoshi/1000 bytes are folded into the largest output instead of being
+// paid on their own.
+func payoutOutputs(payouts []Payout, value int64,
+	minRelayTxFee eacutil.Amount) ([]*wire.TxOut, error) {
+
+	if len(payouts) == 0 {
+		return nil, errors.New("no payouts specified")
+	}
+	totalWeight := new(big.Int)
+	for _, payout := range payouts {
+		if payout.Weight == 0 {
+			return nil, errors.New("payout weights must be positive")
+		}
+		totalWeight.Add(totalWeight, big.NewInt(int64(payout.Weight)))
+	}
+
+	outputs := make([]*wire.TxOut, 0, len(payouts))
+	remaining := value
+	bigValue := big.NewInt(value)
+	share := new(big.Int)
+	for _, payout := range payouts {
+		pkScript, err := txscript.PayToAddrScript(payout.Address)
+		if err != nil {
+			return nil, err
+		}
+		share.Mul(bigValue, big.NewInt(int64(payout.Weight)))
+		share.Quo(share, totalWeight)
+		remaining -= share.Int64()
+		outputs = append(outputs, &wire.TxOut{
+			Value:    share.Int64(),
+			PkScript: pkScript,
+		})
+	}
+	outputs[0].Value += remaining
+
+	// Fold the dust shares into the largest output, which is never dust
+	// itself unless the whole value is.
+	largest := outputs[0]
+	for _, output := range outputs[1:] {
+		if output.Value > largest.Value {
+			largest = output
+		}
+	}
+	kept := outputs[:0]
+	for _, output := range outputs {
+		if output != largest && isDustShare(output, minRelayTxFee) {
+			largest.Value += output.Value
+			continue
+		}
+		kept = append(kept, output)
+	}
+	return kept, nil
+}
+
+// PayCoinbase replaces the first output of the passed coinbase transaction,
+// which pays the whole block reward, with outputs that split its value among
+// the passed payouts in proportion to their weights.  Any other outputs, such
+// as the witness commitment, are kept after them.
+//
+// Shares which would be dust under the passed minimum relay fee in
+// Satoshi/1000 bytes are folded into the largest output.
+//
+// It is used to pay the coinbase of templates created without a payment
+// address, such as those handed out to getblocktemplate and Stratum clients.
+func PayCoinbase(tx *wire.MsgTx, payouts []Payout,
+	minRelayTxFee eacutil.Amount) error {
+
+	outputs, err := payoutOutputs(payouts, tx.TxOut[0].Value,
+		minRelayTxFee)
+	if err != nil {
+		return err
+	}
+	tx.TxOut = append(outputs, tx.TxOut[1:]...)
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/txscript"
+	"github.com/eacsuite/eacd/wire"
+	"github.com/eacsuite/eacutil"
+)
+
+// TestPayCoinbase ensures the reward of a coinbase is split among payouts in
+// proportion to their weights with the remainder going to the first payout and
+// dust shares going to the largest one.
+func TestPayCoinbase(t *testing.T) {
+	t.Parallel()
+
+	var addrs []eacutil.Address
+	for i := byte(0); i < 3; i++ {
+		addr, err := eacutil.NewAddressPubKeyHash(bytes.Repeat([]byte{i}, 20),
+			&chaincfg.SimNetParams)
+		if err != nil {
+			t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+		}
+		addrs = append(addrs, addr)
+	}
+
+	tests := []struct {
+		name          string
+		value         int64
+		weights       []uint32
+		minRelayTxFee eacutil.Amount
+		want          []int64
+		wantAddrs     []int // defaults to the addresses in order
+	}{
+		{
+			name:    "single payout",
+			value:   5000000000,
+			weights: []uint32{7},
+			want:    []int64{5000000000},
+		},
+		{
+			name:    "even split",
+			value:   5000000000,
+			weights: []uint32{1, 1},
+			want:    []int64{2500000000, 2500000000},
+		},
+		{
+			name:    "remainder to first payout",
+			value:   100,
+			weights: []uint32{1, 1, 1},
+			want:    []int64{34, 33, 33},
+		},
+		{
+			name:    "large weights",
+			value:   27000000000000001,
+			weights: []uint32{0xffffffff, 0xffffffff},
+			want:    []int64{13500000000000001, 13500000000000000},
+		},
+		{
+			name:          "no dust",
+			value:         5000000000,
+			weights:       []uint32{1, 1},
+			minRelayTxFee: 1000,
+			want:          []int64{2500000000, 2500000000},
+		},
+		{
+			name:          "dust folded into largest payout",
+			value:         10100,
+			weights:       []uint32{1, 50, 50},
+			minRelayTxFee: 1000,
+			want:          []int64{5100, 5000},
+			wantAddrs:     []int{1, 2},
+		},
+		{
+			name:          "all dust folded into largest payout",
+			value:         100000,
+			weights:       []uint32{1, 1000, 1},
+			minRelayTxFee: 1000,
+			want:          []int64{100000},
+			wantAddrs:     []int{1},
+		},
+		{
+			name:    "zero shares folded without relay fee",
+			value:   1,
+			weights: []uint32{1, 1},
+			want:    []int64{1},
+		},
+	}
+
+	for _, test := range tests {
+		var payouts []Payout
+		for i, weight := range test.weights {
+			payouts = append(payouts, Payout{
+				Address: addrs[i],
+				Weight:  weight,
+			})
+		}
+
+		// The witness commitment must be kept after the payouts.
+		commitment := &wire.TxOut{PkScript: []byte{txscript.OP_RETURN}}
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxOut(&wire.TxOut{Value: test.value})
+		tx.AddTxOut(commitment)
+		err := PayCoinbase(tx, payouts, test.minRelayTxFee)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+
+		if len(tx.TxOut) != len(test.want)+1 {
+			t.Errorf("%s: got %d outputs, want %d", test.name,
+				len(tx.TxOut), len(test.want)+1)
+			continue
+		}
+		for i, want := range test.want {
+			addr := addrs[i]
+			if test.wantAddrs != nil {
+				addr = addrs[test.wantAddrs[i]]
+			}
+			pkScript, _ := txscript.PayToAddrScript(addr)
+			if tx.TxOut[i].Value != want ||
+				!bytes.Equal(tx.TxOut[i].PkScript, pkScript) {
+
+				t.Errorf("%s: output %d: got %d to %x, want %d "+
+					"to %x", test.name, i, tx.TxOut[i].Value,
+					tx.TxOut[i].PkScript, want, pkScript)
+			}
+		}
+		if tx.TxOut[len(test.want)] != commitment {
+			t.Errorf("%s: witness commitment not kept last", test.name)
+		}
+	}
+
+	// Empty payout lists and zero weights are rejected.
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxOut(&wire.TxOut{Value: 100})
+	if err := PayCoinbase(tx, nil, 0); err == nil {
+		t.Errorf("PayCoinbase: no error for empty payouts")
+	}
+	err := PayCoinbase(tx, []Payout{{Address: addrs[0], Weight: 0}}, 0)
+	if err == nil {
+		t.Errorf("PayCoinbase: no error for zero weight")
+	}
+}
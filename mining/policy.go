@@ -45,6 +45,11 @@ type Policy struct {
 	// required for a transaction to be treated as free for mining purposes
 	// (block template generation).
 	TxMinFreeFee eacutil.Amount
+
+	// CoinbaseFlags is added to the coinbase script of generated blocks
+	// instead of the default CoinbaseFlags when it is not empty.  It must
+	// not be longer than MaxCoinbaseFlagsLen.
+	CoinbaseFlags string
 }
 
 // minInt is a helper function to return the minimum of two ints.  This avoids
@@ -0,0 +1,567 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"sync"
+
+	"github.com/eacsuite/eacd/blockchain"
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/txscript"
+	"github.com/eacsuite/eacutil"
+)
+
+const (
+	// maxPendingTxChanges is the maximum number of changes of the source
+	// pool which are recorded until the next block template is created.
+	// The prepared transactions are dropped and prepared again from the
+	// whole source pool once more changes are made, which bounds the
+	// memory used when no templates are requested for a while.
+	maxPendingTxChanges = 50000
+)
+
+// preparedTx houses a transaction of the source pool along with the details
+// needed to select it for a block which only change when the best chain does.
+type preparedTx struct {
+	desc *TxDesc
+
+	// utxos houses the outputs the transaction spends from the main chain.
+	// Its entries are shared by all selections, so they must never be
+	// modified.
+	utxos *blockchain.UtxoViewpoint
+
+	// dependsOn holds the hashes of the transactions whose outputs the
+	// transaction spends and which are not in the main chain, so they
+	// must be in the source pool.
+	dependsOn []chainhash.Hash
+
+	priority float64
+	size     int64
+
+	// scriptsValid is set once the scripts of the transaction passed
+	// validation.
+	scriptsValid bool
+}
+
+// feePerKB returns the fee per kilobyte the transaction is prioritized by,
+// which is based on its fee adjusted by its fee delta.
+func (p *preparedTx) feePerKB() int64 {
+	txDesc := p.desc
+	if txDesc.FeeDelta == 0 {
+		return txDesc.FeePerKB
+	}
+	return (txDesc.Fee + txDesc.FeeDelta) * 1000 / p.size
+}
+
+// txChange is a transaction which was added to or removed from the source
+// pool.  The descriptor is nil when the transaction was removed.
+type txChange struct {
+	hash chainhash.Hash
+	desc *TxDesc
+}
+
+// txSelection houses the transactions selected for a block template after its
+// coinbase along with the details needed to complete the template.  It may be
+// shared by several templates, so it must not be modified once it is created,
+// except for the cached merkle root.
+type txSelection struct {
+	txns       []*eacutil.Tx
+	fees       []int64
+	sigOpCosts []int64
+	totalFees  int64
+
+	// feeRates holds the fee per kilobyte each selected transaction was
+	// prioritized by.
+	feeRates []int64
+
+	// blockWeight and blockSigOpCost are the weight and signature
+	// operation cost of the block including the header and the coinbase
+	// the transactions were selected for, whose weight and cost are
+	// coinbaseWeight and coinbaseSigOpCost.
+	blockWeight       uint32
+	blockSigOpCost    int64
+	coinbaseWeight    int64
+	coinbaseSigOpCost int64
+
+	witnessIncluded   bool
+	witnessCommitment []byte
+
+	// included houses the hashes of the selected transactions.
+	included map[chainhash.Hash]struct{}
+
+	// timeSensitive is set when transactions were skipped for not being
+	// final, which can change as time passes, so the selection must not
+	// be reused.
+	timeSensitive bool
+
+	// complete is set when every prepared transaction was selected, so
+	// the selection can be updated for changes of the source pool
+	// without selecting all transactions again.
+	complete bool
+
+	// merkleMtx protects the merkle root calculated for the last coinbase
+	// the selection was used with.
+	merkleMtx    sync.Mutex
+	coinbaseHash *chainhash.Hash
+	root         chainhash.Hash
+}
+
+// sameTransactions returns whether the passed selection selected the same
+// transactions in the same order for the same coinbase.
+func (sel *txSelection) sameTransactions(other *txSelection) bool {
+	if len(sel.txns) != len(other.txns) ||
+		sel.blockWeight != other.blockWeight ||
+		sel.blockSigOpCost != other.blockSigOpCost {
+
+		return false
+	}
+	for i, tx := range sel.txns {
+		if !tx.Hash().IsEqual(other.txns[i].Hash()) {
+			return false
+		}
+	}
+	return true
+}
+
+// merkleRoot returns the merkle root of the passed transactions, which must be
+// a coinbase followed by the selected transactions.  The root is only
+// calculated again when the coinbase differs from the last one.
+//
+// This function is safe for concurrent access.
+func (sel *txSelection) merkleRoot(blockTxns []*eacutil.Tx) chainhash.Hash {
+	coinbaseHash := blockTxns[0].MsgTx().TxHash()
+
+	sel.merkleMtx.Lock()
+	defer sel.merkleMtx.Unlock()
+	if sel.coinbaseHash == nil || *sel.coinbaseHash != coinbaseHash {
+		merkles := blockchain.BuildMerkleTreeStore(blockTxns, false)
+		sel.root = *merkles[len(merkles)-1]
+		sel.coinbaseHash = &coinbaseHash
+	}
+	return sel.root
+}
+
+// selectionCache houses the transactions of the source pool prepared for the
+// current best chain along with the last selection made from them, so block
+// templates don't need to look up the outputs spent by every transaction in
+// the source pool and sort them all again every time.  The prepared
+// transactions and the last selection are kept up to date with the changes of
+// the source pool recorded by TxAdded and TxRemoved.
+type selectionCache struct {
+	// pendingMtx protects the fields below it.  It is taken when the
+	// source pool records its changes, so it must never be held while
+	// calling into the source pool.
+	pendingMtx sync.Mutex
+	tracking   bool
+	pending    []txChange
+
+	// mtx protects the fields below it.
+	mtx     sync.Mutex
+	tip     chainhash.Hash
+	txs     map[chainhash.Hash]*preparedTx
+	changed bool
+	last    *txSelection
+}
+
+// record records the passed change of the source pool.  Changes are only
+// recorded while the prepared transactions track the source pool.
+//
+// This function is safe for concurrent access.
+func (c *selectionCache) record(change txChange) {
+	c.pendingMtx.Lock()
+	if c.tracking {
+		if len(c.pending) < maxPendingTxChanges {
+			c.pending = append(c.pending, change)
+		} else {
+			c.tracking = false
+			c.pending = nil
+		}
+	}
+	c.pendingMtx.Unlock()
+}
+
+// prepareTx returns the passed transaction of the source pool prepared for
+// blocks at the passed height.  It returns nil when the transaction can never
+// be included in such a block.
+func (g *BlkTmplGenerator) prepareTx(txDesc *TxDesc, nextBlockHeight int32) *preparedTx {
+	// A block can't have more than one coinbase.
+	tx := txDesc.Tx
+	if blockchain.IsCoinBase(tx) {
+		log.Tracef("Skipping coinbase tx %s", tx.Hash())
+		return nil
+	}
+
+	// Fetch all of the utxos referenced by the this transaction.
+	// NOTE: This intentionally does not fetch inputs from the source pool
+	// since a transaction which depends on other transactions in the
+	// source pool must come after those dependencies in the final
+	// generated block.
+	utxos, err := g.fetchUtxoView(tx)
+	if err != nil {
+		log.Warnf("Unable to fetch utxo view for tx %s: %v", tx.Hash(),
+			err)
+		return nil
+	}
+
+	// Calculate the final transaction priority using the input value age
+	// sum as well as the adjusted transaction size.  The formula is:
+	// sum(inputValue * inputAge) / adjustedTxSize
+	prepared := &preparedTx{
+		desc:     txDesc,
+		utxos:    utxos,
+		priority: CalcPriority(tx.MsgTx(), utxos, nextBlockHeight),
+		size: (blockchain.GetTransactionWeight(tx) +
+			blockchain.WitnessScaleFactor - 1) /
+			blockchain.WitnessScaleFactor,
+	}
+	for _, txIn := range tx.MsgTx().TxIn {
+		entry := utxos.LookupEntry(txIn.PreviousOutPoint)
+		if entry == nil || entry.IsSpent() {
+			prepared.dependsOn = append(prepared.dependsOn,
+				txIn.PreviousOutPoint.Hash)
+		}
+	}
+	return prepared
+}
+
+// selection returns the transactions selected from the source pool for a block
+// extending the passed best chain with the passed coinbase.  The last
+// selection is returned again as long as neither the source pool nor the best
+// chain changed and the coinbase has the same weight and signature operation
+// cost.  A selection which included every prepared transaction is updated for
+// the transactions added to and removed from the source pool since it was
+// made.  Otherwise the transactions are selected again from the prepared
+// transactions, which are only all prepared again when the best chain
+// changed.
+//
+// This function is safe for concurrent access.
+func (c *selectionCache) selection(g *BlkTmplGenerator, best *blockchain.BestState,
+	coinbaseTx *eacutil.Tx, coinbaseSigOpCost int64,
+	segwitActive bool) (*txSelection, error) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	// Take the changes of the source pool recorded since the last
+	// selection.  The prepared transactions are dropped when the best
+	// chain changed or too many changes were made, in which case changes
+	// are recorded again from now on so none are missed while the whole
+	// source pool is prepared again.
+	nextBlockHeight := best.Height + 1
+	c.pendingMtx.Lock()
+	reload := !c.tracking || c.tip != best.Hash
+	changes := c.pending
+	c.tracking = true
+	c.pending = nil
+	c.pendingMtx.Unlock()
+
+	if reload {
+		c.tip = best.Hash
+		c.last = nil
+		c.txs = make(map[chainhash.Hash]*preparedTx)
+		for _, txDesc := range g.txSource.MiningDescs() {
+			prepared := g.prepareTx(txDesc, nextBlockHeight)
+			if prepared != nil {
+				c.txs[*txDesc.Tx.Hash()] = prepared
+			}
+		}
+
+		// Changes recorded before the source pool was read are
+		// already reflected by it and are skipped below.
+		changes = nil
+	}
+
+	// Apply the changes to the prepared transactions and collect the ones
+	// the last selection has to be updated for.
+	var added []*preparedTx
+	var removed []chainhash.Hash
+	for _, change := range changes {
+		prepared, exists := c.txs[change.hash]
+
+		// The selection is only affected by removed transactions which
+		// were selected.
+		if change.desc == nil {
+			if !exists {
+				continue
+			}
+			delete(c.txs, change.hash)
+			if c.last != nil {
+				if _, selected := c.last.included[change.hash]; selected {
+					removed = append(removed, change.hash)
+				}
+			}
+			continue
+		}
+
+		// The details of transactions whose descriptor changed, such
+		// as for a new fee delta, are kept, but the order of the
+		// selection may change, so it is made again.
+		if exists && prepared.desc == change.desc {
+			continue
+		}
+		if exists {
+			updated := *prepared
+			updated.desc = change.desc
+			c.txs[change.hash] = &updated
+			c.changed = true
+			continue
+		}
+		prepared = g.prepareTx(change.desc, nextBlockHeight)
+		if prepared != nil {
+			c.txs[change.hash] = prepared
+			added = append(added, prepared)
+		}
+	}
+
+	sel := c.last
+	coinbaseWeight := blockchain.GetTransactionWeight(coinbaseTx)
+	if sel != nil && !c.changed && !sel.timeSensitive &&
+		sel.coinbaseWeight == coinbaseWeight &&
+		sel.coinbaseSigOpCost == coinbaseSigOpCost {
+
+		if len(added) == 0 && len(removed) == 0 {
+			log.Debugf("Reusing selection of %d transactions for "+
+				"new block template", len(sel.txns))
+			return sel, nil
+		}
+
+		if sel.complete {
+			newSel, ok := g.updateSelection(sel, c.txs, added,
+				removed, coinbaseTx, nextBlockHeight, segwitActive)
+			if ok {
+				log.Debugf("Updated selection for %d added and "+
+					"%d removed transactions to %d "+
+					"transactions for new block template",
+					len(added), len(removed), len(newSel.txns))
+				c.last = newSel
+				return newSel, nil
+			}
+		}
+	}
+
+	// The changes are reflected by the selection made below, but they
+	// still have to be once it fails.
+	c.changed = true
+	candidates := make([]*preparedTx, 0, len(c.txs))
+	for _, prepared := range c.txs {
+		candidates = append(candidates, prepared)
+	}
+	newSel, err := g.selectTransactions(nextBlockHeight, candidates, nil,
+		coinbaseTx, coinbaseSigOpCost, segwitActive)
+	if err != nil {
+		return nil, err
+	}
+	c.changed = false
+
+	// Keep the last selection along with the merkle root calculated for it
+	// when the same transactions were selected again.
+	if sel != nil && sel.coinbaseWeight == coinbaseWeight &&
+		sel.coinbaseSigOpCost == coinbaseSigOpCost &&
+		sel.sameTransactions(newSel) {
+
+		sel.merkleMtx.Lock()
+		newSel.coinbaseHash = sel.coinbaseHash
+		newSel.root = sel.root
+		sel.merkleMtx.Unlock()
+	}
+	c.last = newSel
+	return newSel, nil
+}
+
+// updateSelection returns a copy of the passed selection, which must have
+// selected every prepared transaction, updated for the passed transactions
+// added to and removed from the source pool since it was made.  The removed
+// transactions are dropped and the added ones are inserted in the order of
+// their fees per kilobyte after the transactions they depend on, so the
+// selection keeps including every prepared transaction.  It returns false when
+// that isn't possible, such as when an added transaction would exceed the
+// block limits or is not final yet, in which case the transactions must be
+// selected again.
+func (g *BlkTmplGenerator) updateSelection(sel *txSelection,
+	txs map[chainhash.Hash]*preparedTx, added []*preparedTx,
+	removed []chainhash.Hash, coinbaseTx *eacutil.Tx,
+	nextBlockHeight int32, segwitActive bool) (*txSelection, bool) {
+
+	removedSet := make(map[chainhash.Hash]struct{}, len(removed))
+	for _, hash := range removed {
+		removedSet[hash] = struct{}{}
+	}
+
+	newSel := &txSelection{
+		txns:              make([]*eacutil.Tx, 0, len(sel.txns)+len(added)),
+		fees:              make([]int64, 0, len(sel.txns)+len(added)),
+		sigOpCosts:        make([]int64, 0, len(sel.txns)+len(added)),
+		feeRates:          make([]int64, 0, len(sel.txns)+len(added)),
+		totalFees:         sel.totalFees,
+		blockWeight:       sel.blockWeight,
+		blockSigOpCost:    sel.blockSigOpCost,
+		coinbaseWeight:    sel.coinbaseWeight,
+		coinbaseSigOpCost: sel.coinbaseSigOpCost,
+		witnessIncluded:   sel.witnessIncluded,
+		included:          make(map[chainhash.Hash]struct{}, len(sel.txns)+len(added)),
+		complete:          true,
+	}
+
+	// Drop the removed transactions.  Transactions depending on them are
+	// removed from the source pool along with them, so the selection is
+	// made again when one of them is not.  The block weight reserved for
+	// the witness commitment can't be given back either.
+	hasWitness := false
+	for i, tx := range sel.txns {
+		hash := *tx.Hash()
+		if _, ok := removedSet[hash]; ok {
+			newSel.blockWeight -= uint32(blockchain.GetTransactionWeight(tx))
+			newSel.blockSigOpCost -= sel.sigOpCosts[i]
+			newSel.totalFees -= sel.fees[i]
+			continue
+		}
+		for _, txIn := range tx.MsgTx().TxIn {
+			if _, ok := removedSet[txIn.PreviousOutPoint.Hash]; ok {
+				return nil, false
+			}
+		}
+		hasWitness = hasWitness || tx.HasWitness()
+		newSel.txns = append(newSel.txns, tx)
+		newSel.fees = append(newSel.fees, sel.fees[i])
+		newSel.sigOpCosts = append(newSel.sigOpCosts, sel.sigOpCosts[i])
+		newSel.feeRates = append(newSel.feeRates, sel.feeRates[i])
+		newSel.included[hash] = struct{}{}
+	}
+	if newSel.witnessIncluded && !hasWitness {
+		return nil, false
+	}
+
+	// Insert the added transactions, which were added to the source pool
+	// after the transactions they depend on.  Transactions which were
+	// removed again since are skipped.
+	for _, prepared := range added {
+		tx := prepared.desc.Tx
+		hash := *tx.Hash()
+		if txs[hash] != prepared {
+			continue
+		}
+
+		// Free transactions might not be selected and neither might
+		// transactions with witness data when no witness commitment
+		// has been accounted for yet.
+		feePerKB := prepared.feePerKB()
+		if feePerKB < int64(g.policy.TxMinFreeFee) ||
+			(tx.HasWitness() && (!segwitActive || !newSel.witnessIncluded)) ||
+			!blockchain.IsFinalizedTransaction(tx, nextBlockHeight,
+				g.timeSource.AdjustedTime()) {
+
+			return nil, false
+		}
+
+		// The transaction can only spend outputs of the main chain and
+		// of selected transactions, so it is inserted after the last
+		// one of them it depends on.
+		utxos := blockchain.NewUtxoViewpoint()
+		for outpoint, entry := range prepared.utxos.Entries() {
+			if entry != nil {
+				utxos.Entries()[outpoint] = entry
+			}
+		}
+		pos := 0
+		for _, parentHash := range prepared.dependsOn {
+			if _, ok := newSel.included[parentHash]; !ok {
+				return nil, false
+			}
+			for i, selected := range newSel.txns {
+				if *selected.Hash() == parentHash {
+					utxos.AddTxOuts(selected, nextBlockHeight)
+					if i+1 > pos {
+						pos = i + 1
+					}
+					break
+				}
+			}
+		}
+		for pos < len(newSel.txns) && newSel.feeRates[pos] >= feePerKB {
+			pos++
+		}
+
+		// Enforce the block limits and ensure the transaction is
+		// valid along with the transactions it depends on.
+		txWeight := uint32(blockchain.GetTransactionWeight(tx))
+		blockPlusTxWeight := newSel.blockWeight + txWeight
+		if blockPlusTxWeight < newSel.blockWeight ||
+			blockPlusTxWeight >= g.policy.BlockMaxWeight {
+
+			return nil, false
+		}
+		sigOpCost, err := blockchain.GetSigOpCost(tx, false, utxos,
+			true, segwitActive)
+		if err != nil || newSel.blockSigOpCost+int64(sigOpCost) >
+			blockchain.MaxBlockSigOpsCost {
+
+			return nil, false
+		}
+		txFee, err := blockchain.CheckTransactionInputs(tx,
+			nextBlockHeight, utxos, g.chainParams)
+		if err != nil {
+			return nil, false
+		}
+		if !prepared.scriptsValid {
+			err := blockchain.ValidateTransactionScripts(tx, utxos,
+				txscript.StandardVerifyFlags, g.sigCache,
+				g.hashCache)
+			if err != nil {
+				return nil, false
+			}
+			prepared.scriptsValid = true
+		}
+
+		newSel.txns = append(newSel.txns, nil)
+		copy(newSel.txns[pos+1:], newSel.txns[pos:])
+		newSel.txns[pos] = tx
+		newSel.fees = insertInt64(newSel.fees, pos, txFee)
+		newSel.sigOpCosts = insertInt64(newSel.sigOpCosts, pos,
+			int64(sigOpCost))
+		newSel.feeRates = insertInt64(newSel.feeRates, pos, feePerKB)
+		newSel.blockWeight = blockPlusTxWeight
+		newSel.blockSigOpCost += int64(sigOpCost)
+		newSel.totalFees += txFee
+		newSel.included[hash] = struct{}{}
+	}
+
+	if newSel.witnessIncluded {
+		blockTxns := make([]*eacutil.Tx, 0, len(newSel.txns)+1)
+		blockTxns = append(blockTxns, coinbaseTx)
+		blockTxns = append(blockTxns, newSel.txns...)
+		newSel.witnessCommitment = calcWitnessCommitment(blockTxns)
+	}
+	return newSel, true
+}
+
+// insertInt64 inserts the passed value into the passed slice at the passed
+// index.
+func insertInt64(s []int64, i int, v int64) []int64 {
+	s = append(s, 0)
+	copy(s[i+1:], s[i:])
+	s[i] = v
+	return s
+}
+
+// TxAdded records that the transaction of the passed descriptor was added to
+// the source pool, or that the descriptor of a transaction in it changed such
+// as for a new fee delta, so the transaction is considered by the next block
+// template.  It doesn't call into the source pool, so the source pool may call
+// it with its lock held.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) TxAdded(txDesc *TxDesc) {
+	g.cache.record(txChange{hash: *txDesc.Tx.Hash(), desc: txDesc})
+}
+
+// TxRemoved records that the passed transaction was removed from the source
+// pool, so it is no longer considered by the next block template.  It doesn't
+// call into the source pool, so the source pool may call it with its lock
+// held.
+//
+// This function is safe for concurrent access.
+func (g *BlkTmplGenerator) TxRemoved(tx *eacutil.Tx) {
+	g.cache.record(txChange{hash: *tx.Hash()})
+}
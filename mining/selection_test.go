@@ -0,0 +1,271 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"testing"
+	"time"
+
+	"github.com/eacsuite/eacd/blockchain"
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/txscript"
+	"github.com/eacsuite/eacd/wire"
+	"github.com/eacsuite/eacutil"
+)
+
+// TestSelectionCacheRecord ensures changes of the source pool are only recorded
+// while the cache tracks the source pool and that tracking stops once too many
+// changes are pending.
+func TestSelectionCacheRecord(t *testing.T) {
+	t.Parallel()
+
+	var c selectionCache
+	c.record(txChange{})
+	if len(c.pending) != 0 {
+		t.Fatalf("change recorded while not tracking the source pool")
+	}
+
+	c.tracking = true
+	for i := 0; i < maxPendingTxChanges; i++ {
+		c.record(txChange{})
+	}
+	if !c.tracking || len(c.pending) != maxPendingTxChanges {
+		t.Fatalf("got %d pending changes, want %d", len(c.pending),
+			maxPendingTxChanges)
+	}
+
+	c.record(txChange{})
+	if c.tracking || c.pending != nil {
+		t.Fatalf("still tracking the source pool after too many " +
+			"changes")
+	}
+}
+
+// TestSelectionMerkleRoot ensures the merkle root of a selection matches the
+// one of the whole block and is calculated again when the coinbase changes.
+func TestSelectionMerkleRoot(t *testing.T) {
+	t.Parallel()
+
+	newTx := func(value int64) *eacutil.Tx {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+				wire.MaxPrevOutIndex),
+		})
+		tx.AddTxOut(wire.NewTxOut(value, nil))
+		return eacutil.NewTx(tx)
+	}
+	sel := &txSelection{txns: []*eacutil.Tx{newTx(1), newTx(2), newTx(3)}}
+
+	for _, value := range []int64{100, 100, 200} {
+		blockTxns := append([]*eacutil.Tx{newTx(value)}, sel.txns...)
+		merkles := blockchain.BuildMerkleTreeStore(blockTxns, false)
+		want := *merkles[len(merkles)-1]
+		if got := sel.merkleRoot(blockTxns); got != want {
+			t.Fatalf("merkle root for coinbase value %d: got %v, "+
+				"want %v", value, got, want)
+		}
+	}
+
+	// Selections of other transactions are not the same.
+	other := &txSelection{txns: []*eacutil.Tx{newTx(1), newTx(2), newTx(4)}}
+	if !sel.sameTransactions(sel) || sel.sameTransactions(other) {
+		t.Fatalf("sameTransactions: unexpected result")
+	}
+}
+
+// fakeTxSource is a source pool holding the transactions of its descriptors.
+type fakeTxSource struct {
+	descs []*TxDesc
+}
+
+// LastUpdated returns the zero time.
+func (s *fakeTxSource) LastUpdated() time.Time {
+	return time.Time{}
+}
+
+// MiningDescs returns the descriptors of the source pool.
+func (s *fakeTxSource) MiningDescs() []*TxDesc {
+	return s.descs
+}
+
+// HaveTransaction returns whether a transaction with the passed hash is in the
+// source pool.
+func (s *fakeTxSource) HaveTransaction(hash *chainhash.Hash) bool {
+	for _, txDesc := range s.descs {
+		if txDesc.Tx.Hash().IsEqual(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestSelectionCacheUpdates ensures the selection is reused while neither the
+// source pool nor the best chain changes, and that it selects the same
+// transactions as a new selection once a transaction is added or removed, the
+// fee delta of a transaction changes or the best chain changes.
+func TestSelectionCacheUpdates(t *testing.T) {
+	t.Parallel()
+
+	// All transactions spend outputs of a transaction in the main chain or
+	// of each other which anyone can spend.
+	anyone := []byte{txscript.OP_TRUE}
+	funding := wire.NewMsgTx(wire.TxVersion)
+	funding.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{1}, 0),
+	})
+	for i := 0; i < 4; i++ {
+		funding.AddTxOut(wire.NewTxOut(1e8, anyone))
+	}
+	fundingTx := eacutil.NewTx(funding)
+	newDesc := func(parent *eacutil.Tx, fee int64) *TxDesc {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: *wire.NewOutPoint(parent.Hash(), 0),
+		})
+		value := parent.MsgTx().TxOut[0].Value - fee
+		if parent == fundingTx {
+			tx.TxIn[0].PreviousOutPoint.Index = uint32(fee / 1e4)
+			value = 1e8 - fee
+		}
+		tx.AddTxOut(wire.NewTxOut(value, anyone))
+		size := int64(tx.SerializeSize())
+		return &TxDesc{
+			Tx:       eacutil.NewTx(tx),
+			Fee:      fee,
+			FeePerKB: fee * 1000 / size,
+		}
+	}
+
+	txSource := &fakeTxSource{}
+	g := &BlkTmplGenerator{
+		policy: &Policy{
+			BlockMaxWeight: 400000,
+			TxMinFreeFee:   1000,
+		},
+		chainParams: &chaincfg.SimNetParams,
+		txSource:    txSource,
+		timeSource:  blockchain.NewMedianTime(),
+		sigCache:    txscript.NewSigCache(100),
+		hashCache:   txscript.NewHashCache(100),
+		fetchUtxoView: func(tx *eacutil.Tx) (*blockchain.UtxoViewpoint, error) {
+			view := blockchain.NewUtxoViewpoint()
+			view.AddTxOuts(fundingTx, 1)
+			return view, nil
+		},
+	}
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{txscript.OP_0, txscript.OP_0},
+	})
+	coinbase.AddTxOut(wire.NewTxOut(50e8, anyone))
+	coinbaseTx := eacutil.NewTx(coinbase)
+	best := &blockchain.BestState{Hash: chainhash.Hash{2}, Height: 1}
+
+	// selection returns the selection of the cache of the generator and
+	// ensures it is the same as a new selection.
+	selection := func(desc string) *txSelection {
+		t.Helper()
+
+		sel, err := g.cache.selection(g, best, coinbaseTx, 0, false)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", desc, err)
+		}
+		var c selectionCache
+		want, err := c.selection(g, best, coinbaseTx, 0, false)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", desc, err)
+		}
+		if len(sel.txns) != len(txSource.descs) ||
+			len(sel.txns) != len(want.txns) ||
+			sel.totalFees != want.totalFees ||
+			sel.blockWeight != want.blockWeight ||
+			sel.blockSigOpCost != want.blockSigOpCost {
+
+			t.Fatalf("%s: got %d transactions paying %d, want %d "+
+				"paying %d", desc, len(sel.txns), sel.totalFees,
+				len(want.txns), want.totalFees)
+		}
+		for i, tx := range sel.txns {
+			if _, ok := want.included[*tx.Hash()]; !ok {
+				t.Fatalf("%s: unexpected tx %s", desc, tx.Hash())
+			}
+			for _, txIn := range tx.MsgTx().TxIn {
+				for _, later := range sel.txns[i:] {
+					if txIn.PreviousOutPoint.Hash == *later.Hash() {
+						t.Fatalf("%s: tx %s is selected "+
+							"before its parent", desc,
+							tx.Hash())
+					}
+				}
+			}
+		}
+		return sel
+	}
+	add := func(txDesc *TxDesc) {
+		txSource.descs = append(txSource.descs, txDesc)
+		g.TxAdded(txDesc)
+	}
+
+	low := newDesc(fundingTx, 1e4)
+	high := newDesc(fundingTx, 3e4)
+	txSource.descs = []*TxDesc{low, high}
+	sel := selection("initial selection")
+	if *sel.txns[0].Hash() != *high.Tx.Hash() {
+		t.Fatalf("transactions are not sorted by fee")
+	}
+	if reused := selection("unchanged source pool"); reused != sel {
+		t.Fatalf("selection was not reused")
+	}
+
+	// Adding transactions, including one spending another one in the
+	// source pool, updates the selection.
+	mid := newDesc(fundingTx, 2e4)
+	add(mid)
+	newSel := selection("added transaction")
+	if newSel == sel || *newSel.txns[1].Hash() != *mid.Tx.Hash() {
+		t.Fatalf("selection was not updated for added transaction")
+	}
+	sel = newSel
+	child := newDesc(mid.Tx, 1e4)
+	add(child)
+	sel = selection("added child transaction")
+	if _, ok := sel.included[*child.Tx.Hash()]; !ok {
+		t.Fatalf("selection was not updated for added child")
+	}
+	if reused := selection("unchanged source pool"); reused != sel {
+		t.Fatalf("updated selection was not reused")
+	}
+
+	// Removing a transaction updates the selection.
+	txSource.descs = []*TxDesc{low, mid, child}
+	g.TxRemoved(high.Tx)
+	newSel = selection("removed transaction")
+	if _, ok := newSel.included[*high.Tx.Hash()]; newSel == sel || ok {
+		t.Fatalf("selection was not updated for removed transaction")
+	}
+	sel = newSel
+
+	// Changing the fee delta of a transaction selects the transactions
+	// again.
+	prioritized := *low
+	prioritized.FeeDelta = 1e6
+	txSource.descs[0] = &prioritized
+	g.TxAdded(&prioritized)
+	newSel = selection("fee delta")
+	if newSel == sel || *newSel.txns[0].Hash() != *low.Tx.Hash() {
+		t.Fatalf("selection was not updated for fee delta")
+	}
+	sel = newSel
+
+	// Changing the best chain selects the transactions again.
+	best = &blockchain.BestState{Hash: chainhash.Hash{3}, Height: 2}
+	if newSel := selection("new best chain"); newSel == sel {
+		t.Fatalf("selection was not updated for new best chain")
+	}
+}
@@ -0,0 +1,648 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eacsuite/eacd/blockchain"
+	"github.com/eacsuite/eacd/mining"
+	"github.com/eacsuite/eacd/wire"
+	"github.com/eacsuite/eacutil"
+)
+
+const (
+	// maxMessageSize is the maximum size of a message a miner can send.
+	maxMessageSize = 16 * 1024
+
+	// idleTimeout is the duration of inactivity before a connection is
+	// closed.
+	idleTimeout = 10 * time.Minute
+
+	// writeTimeout is the maximum duration of a write to a connection.
+	writeTimeout = 30 * time.Second
+
+	// sendQueueSize is the number of messages which can be queued for a
+	// connection before it is considered too slow and closed.
+	sendQueueSize = 32
+
+	// maxClientJobs is the number of jobs of a connection shares are
+	// accepted for.
+	maxClientJobs = 8
+
+	// maxClientWorkers is the maximum number of workers which can be
+	// authorized on a connection.
+	maxClientWorkers = 16
+
+	// maxTimeOffset is how far in the future the time of a share can be.
+	maxTimeOffset = 2 * time.Hour
+
+	// minDifficulty is the lowest share difficulty connections are
+	// adjusted to unless the configured difficulty is lower.
+	minDifficulty = 1
+
+	// targetShareTime is the average time between shares the difficulty
+	// of connections is adjusted for.
+	targetShareTime = 15 * time.Second
+
+	// retargetInterval is the interval at which the difficulty of
+	// connections is adjusted.
+	retargetInterval = 90 * time.Second
+
+	// maxRetargetFactor is the maximum factor the difficulty of a
+	// connection changes by at once.
+	maxRetargetFactor = 4
+
+	// retargetThreshold is the relative change of the difficulty below
+	// which it is not worth sending a new one.
+	retargetThreshold = 0.3
+)
+
+// Error codes returned to miners.
+const (
+	errOther          = 20
+	errJobNotFound    = 21
+	errDuplicateShare = 22
+	errLowDifficulty  = 23
+	errUnauthorized   = 24
+	errNotSubscribed  = 25
+)
+
+// stratumError is an error returned to a miner in response to a request.
+type stratumError struct {
+	Code    int
+	Message string
+}
+
+// MarshalJSON encodes the error as the array of its code, message and
+// traceback used by Stratum.
+func (e *stratumError) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Code, e.Message, nil})
+}
+
+// newStratumError returns a Stratum error with the passed code and message.
+func newStratumError(code int, message string) *stratumError {
+	return &stratumError{Code: code, Message: message}
+}
+
+// request is a request sent by a miner.
+type request struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// response is the response to a request.
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result interface{}     `json:"result"`
+	Error  *stratumError   `json:"error"`
+}
+
+// notification is a message sent to a miner without a request.
+type notification struct {
+	ID     interface{}   `json:"id"`
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+}
+
+// clientJob is a job as sent to a connection.
+type clientJob struct {
+	job        *job
+	coinbase1  []byte
+	coinbase2  []byte
+	difficulty float64
+	target     *big.Int
+	submitted  map[string]struct{}
+}
+
+// client houses the state of a miner connection.
+type client struct {
+	server      *Server
+	conn        net.Conn
+	extraNonce1 []byte
+	sendQueue   chan []byte
+	quit        chan struct{}
+	quitOnce    sync.Once
+
+	mtx         sync.Mutex
+	subscribed  bool
+	workers     map[string]struct{}
+	payTo       []mining.Payout
+	difficulty  float64
+	jobs        map[string]*clientJob
+	jobIDs      []string
+	nextJobID   uint64
+	windowStart time.Time
+	shares      int
+}
+
+// newClient returns a client for the passed connection and extra nonce.
+func newClient(s *Server, conn net.Conn, extraNonce1 uint32) *client {
+	c := &client{
+		server:      s,
+		conn:        conn,
+		extraNonce1: make([]byte, extraNonce1Size),
+		sendQueue:   make(chan []byte, sendQueueSize),
+		quit:        make(chan struct{}),
+		workers:     make(map[string]struct{}),
+		difficulty:  s.cfg.Difficulty,
+		jobs:        make(map[string]*clientJob),
+	}
+	binary.BigEndian.PutUint32(c.extraNonce1, extraNonce1)
+	return c
+}
+
+// disconnect closes the connection.  It is safe to call it multiple times.
+func (c *client) disconnect() {
+	c.quitOnce.Do(func() {
+		close(c.quit)
+		c.conn.Close()
+	})
+}
+
+// queueMessage queues the passed message to be sent to the miner.  The
+// connection is closed when the miner doesn't keep up with its messages.
+func (c *client) queueMessage(msg interface{}) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		log.Errorf("Failed to marshal Stratum message: %v", err)
+		return
+	}
+	b = append(b, '\n')
+
+	select {
+	case c.sendQueue <- b:
+	default:
+		log.Warnf("Stratum connection from %s is too slow -- "+
+			"disconnecting", c.conn.RemoteAddr())
+		c.disconnect()
+	}
+}
+
+// outHandler writes queued messages to the connection.  It must be run as a
+// goroutine.
+func (c *client) outHandler() {
+out:
+	for {
+		select {
+		case b := <-c.sendQueue:
+			c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if _, err := c.conn.Write(b); err != nil {
+				log.Debugf("Failed to write to Stratum "+
+					"connection from %s: %v",
+					c.conn.RemoteAddr(), err)
+				c.disconnect()
+				break out
+			}
+
+		case <-c.quit:
+			break out
+		}
+	}
+	c.server.wg.Done()
+}
+
+// inHandler reads and handles requests until the connection is closed.
+func (c *client) inHandler() {
+	scanner := bufio.NewScanner(c.conn)
+	scanner.Buffer(make([]byte, 0, 1024), maxMessageSize)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(idleTimeout))
+		if !scanner.Scan() {
+			break
+		}
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			log.Debugf("Malformed Stratum request from %s: %v",
+				c.conn.RemoteAddr(), err)
+			break
+		}
+		result, stratumErr := c.handleRequest(&req)
+		c.queueMessage(&response{
+			ID:     req.ID,
+			Result: result,
+			Error:  stratumErr,
+		})
+
+		// Work is sent after the response to the request which made
+		// the connection both subscribed and authorized.
+		if stratumErr == nil && (req.Method == "mining.subscribe" ||
+			req.Method == "mining.authorize") {
+
+			c.sendWork()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Debugf("Failed to read from Stratum connection from %s: %v",
+			c.conn.RemoteAddr(), err)
+	}
+	c.disconnect()
+}
+
+// handleRequest handles the passed request and returns its result or error.
+func (c *client) handleRequest(req *request) (interface{}, *stratumError) {
+	switch req.Method {
+	case "mining.subscribe":
+		return c.handleSubscribe()
+
+	case "mining.authorize":
+		var params []string
+		if err := json.Unmarshal(req.Params, &params); err != nil ||
+			len(params) < 1 {
+
+			return nil, newStratumError(errOther, "invalid params")
+		}
+		return c.handleAuthorize(params[0])
+
+	case "mining.submit":
+		var params []string
+		if err := json.Unmarshal(req.Params, &params); err != nil ||
+			len(params) < 5 {
+
+			return nil, newStratumError(errOther, "invalid params")
+		}
+		return c.handleSubmit(params[0], params[1], params[2], params[3],
+			params[4])
+	}
+
+	return nil, newStratumError(errOther, "unsupported method "+req.Method)
+}
+
+// handleSubscribe handles the mining.subscribe method.
+func (c *client) handleSubscribe() (interface{}, *stratumError) {
+	c.mtx.Lock()
+	c.subscribed = true
+	c.mtx.Unlock()
+
+	subscriptionID := hex.EncodeToString(c.extraNonce1)
+	return []interface{}{
+		[]interface{}{
+			[]string{"mining.set_difficulty", subscriptionID},
+			[]string{"mining.notify", subscriptionID},
+		},
+		subscriptionID,
+		extraNonce2Size,
+	}, nil
+}
+
+// payouts returns the payouts of the blocks found by the named worker.
+// Workers named as an address followed by a dot and a name are paid to the
+// address.  Others are paid to one of the configured payout lists.
+func (c *client) payouts(name string) ([]mining.Payout, error) {
+	params := c.server.cfg.ChainParams
+	addrStr := name
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		addrStr = name[:i]
+	}
+	addr, err := eacutil.DecodeAddress(addrStr, params)
+	if err != nil || !addr.IsForNet(params) {
+		payouts := c.server.cfg.MiningPayouts
+		if len(payouts) == 0 {
+			return nil, errors.New("worker name is not an address " +
+				"and no mining addresses are configured")
+		}
+		return payouts[rand.Intn(len(payouts))], nil
+	}
+	return []mining.Payout{{Address: addr, Weight: 1}}, nil
+}
+
+// handleAuthorize handles the mining.authorize method.  The first worker
+// authorized on the connection determines whom its blocks pay to.
+func (c *client) handleAuthorize(name string) (interface{}, *stratumError) {
+	c.mtx.Lock()
+	if _, ok := c.workers[name]; ok {
+		c.mtx.Unlock()
+		return true, nil
+	}
+	if len(c.workers) >= maxClientWorkers {
+		c.mtx.Unlock()
+		log.Debugf("Stratum worker %q from %s not authorized: too many "+
+			"workers", name, c.conn.RemoteAddr())
+		return nil, newStratumError(errUnauthorized, "too many workers")
+	}
+	if c.payTo == nil {
+		payouts, err := c.payouts(name)
+		if err != nil {
+			c.mtx.Unlock()
+			log.Debugf("Stratum worker %q from %s not authorized: %v",
+				name, c.conn.RemoteAddr(), err)
+			return nil, newStratumError(errUnauthorized, err.Error())
+		}
+		c.payTo = payouts
+	}
+	c.workers[name] = struct{}{}
+	difficulty := c.difficulty
+	c.mtx.Unlock()
+
+	c.server.addWorkerConn(name, difficulty)
+	log.Debugf("Stratum worker %q authorized from %s", name,
+		c.conn.RemoteAddr())
+	return true, nil
+}
+
+// workerNames returns the names of the workers authorized on the connection.
+func (c *client) workerNames() []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	names := make([]string, 0, len(c.workers))
+	for name := range c.workers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// ready returns whether work can be sent to the connection.  It must be called
+// with the client lock held.
+func (c *client) ready() bool {
+	return c.subscribed && c.payTo != nil
+}
+
+// sendWork sends the share difficulty and the current job to the connection
+// once it is subscribed and authorized.
+func (c *client) sendWork() {
+	j := c.server.job()
+	if j == nil {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if !c.ready() || len(c.jobIDs) != 0 {
+		return
+	}
+	c.windowStart = time.Now()
+	c.shares = 0
+	c.setDifficulty(c.server.clampDifficulty(c.difficulty, j))
+	c.queueJob(j, true)
+}
+
+// sendJob sends the passed job to the connection.  Previous jobs are dropped
+// when the job is clean.
+func (c *client) sendJob(j *job, clean bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if !c.ready() {
+		return
+	}
+	if len(c.jobIDs) == 0 {
+		// Work is being sent for the first time.
+		c.windowStart = time.Now()
+		c.shares = 0
+		c.setDifficulty(c.server.clampDifficulty(c.difficulty, j))
+		clean = true
+	}
+	c.queueJob(j, clean)
+}
+
+// setDifficulty sends the passed share difficulty to the connection.  It must
+// be called with the client lock held.
+func (c *client) setDifficulty(difficulty float64) {
+	c.difficulty = difficulty
+	for name := range c.workers {
+		c.server.setWorkerDifficulty(name, difficulty)
+	}
+	c.queueMessage(&notification{
+		Method: "mining.set_difficulty",
+		Params: []interface{}{difficulty},
+	})
+}
+
+// queueJob sends the passed job to the connection with the current share
+// difficulty.  It must be called with the client lock held.
+func (c *client) queueJob(j *job, clean bool) {
+	coinbase1, coinbase2, err := j.coinbaseParts(c.payTo)
+	if err != nil {
+		log.Errorf("Failed to serialize Stratum coinbase: %v", err)
+		return
+	}
+
+	if clean {
+		c.jobs = make(map[string]*clientJob)
+		c.jobIDs = c.jobIDs[:0]
+	}
+	for len(c.jobIDs) >= maxClientJobs {
+		delete(c.jobs, c.jobIDs[0])
+		c.jobIDs = c.jobIDs[1:]
+	}
+	c.nextJobID++
+	id := strconv.FormatUint(c.nextJobID, 16)
+	c.jobs[id] = &clientJob{
+		job:        j,
+		coinbase1:  coinbase1,
+		coinbase2:  coinbase2,
+		difficulty: c.difficulty,
+		target:     difficultyToTarget(c.difficulty),
+		submitted:  make(map[string]struct{}),
+	}
+	c.jobIDs = append(c.jobIDs, id)
+
+	c.queueMessage(&notification{
+		Method: "mining.notify",
+		Params: j.notifyParams(id, coinbase1, coinbase2, clean),
+	})
+}
+
+// retargetDifficulty returns the share difficulty which makes a connection
+// which submitted the passed number of shares at the passed difficulty over
+// the passed duration submit shares at the target rate.
+func retargetDifficulty(difficulty float64, elapsed time.Duration, shares int) float64 {
+	factor := 1.0 / maxRetargetFactor
+	if shares > 0 {
+		shareTime := elapsed.Seconds() / float64(shares)
+		factor = targetShareTime.Seconds() / shareTime
+		if factor > maxRetargetFactor {
+			factor = maxRetargetFactor
+		}
+		if factor < 1.0/maxRetargetFactor {
+			factor = 1.0 / maxRetargetFactor
+		}
+	}
+	return difficulty * factor
+}
+
+// retarget adjusts the share difficulty of the connection to its hash rate
+// once the retarget interval has passed.  A new difficulty is sent along with
+// the current job so it applies right away.
+func (c *client) retarget(now time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if len(c.jobIDs) == 0 {
+		return
+	}
+	elapsed := now.Sub(c.windowStart)
+	if elapsed < retargetInterval {
+		return
+	}
+	shares := c.shares
+	c.windowStart = now
+	c.shares = 0
+
+	latest := c.jobs[c.jobIDs[len(c.jobIDs)-1]].job
+	difficulty := retargetDifficulty(c.difficulty, elapsed, shares)
+	difficulty = c.server.clampDifficulty(difficulty, latest)
+	change := difficulty/c.difficulty - 1
+	if change < retargetThreshold && change > -retargetThreshold {
+		return
+	}
+
+	log.Debugf("Stratum difficulty of %s changed from %v to %v after %d "+
+		"shares in %v", c.conn.RemoteAddr(), c.difficulty, difficulty,
+		shares, elapsed)
+	c.setDifficulty(difficulty)
+	c.queueJob(latest, false)
+}
+
+// parseHexUint32 parses the passed big-endian hex encoded 32-bit value.
+func parseHexUint32(s string) (uint32, error) {
+	if len(s) != 8 {
+		return 0, errors.New("invalid length")
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	return uint32(v), err
+}
+
+// handleSubmit handles the mining.submit method.
+func (c *client) handleSubmit(name, jobID, extraNonce2Hex, timeHex,
+	nonceHex string) (interface{}, *stratumError) {
+
+	c.mtx.Lock()
+	cj, header, coinbase, stratumErr := c.parseShare(name, jobID,
+		extraNonce2Hex, timeHex, nonceHex)
+	c.mtx.Unlock()
+	if stratumErr != nil {
+		return nil, stratumErr
+	}
+
+	// The share is hashed and any block is processed without holding the
+	// client lock since both take a while and the connection is otherwise
+	// blocked from receiving new work meanwhile.
+	s := c.server
+	powHash, err := header.PowHash()
+	if err != nil {
+		log.Errorf("Failed to hash Stratum share: %v", err)
+		return nil, newStratumError(errOther, "internal error")
+	}
+	hashNum := blockchain.HashToBig(powHash)
+	if hashNum.Cmp(cj.target) > 0 {
+		s.recordShare(name, cj.difficulty, shareRejected)
+		return nil, newStratumError(errLowDifficulty, "low difficulty share")
+	}
+
+	c.mtx.Lock()
+	c.shares++
+	c.mtx.Unlock()
+
+	result := shareAccepted
+	if hashNum.Cmp(blockchain.CompactToBig(header.Bits)) <= 0 {
+		block := eacutil.NewBlock(cj.job.solvedBlock(header, coinbase))
+		if s.submitBlock(block) {
+			result = shareBlock
+			log.Infof("Block %s found by Stratum worker %q",
+				block.Hash(), name)
+		}
+	}
+	s.recordShare(name, cj.difficulty, result)
+	return true, nil
+}
+
+// parseShare validates the passed share of the named worker and returns the
+// job it is for along with the header and coinbase of the resulting block.
+// The proof of work of the share is not checked.  It must be called with the
+// client lock held.
+func (c *client) parseShare(name, jobID, extraNonce2Hex, timeHex,
+	nonceHex string) (*clientJob, *wire.BlockHeader, *wire.MsgTx, *stratumError) {
+
+	if !c.subscribed {
+		return nil, nil, nil, newStratumError(errNotSubscribed,
+			"not subscribed")
+	}
+	if _, ok := c.workers[name]; !ok {
+		return nil, nil, nil, newStratumError(errUnauthorized,
+			"unauthorized worker")
+	}
+
+	s := c.server
+	cj, ok := c.jobs[jobID]
+	if !ok {
+		s.recordShare(name, c.difficulty, shareStale)
+		return nil, nil, nil, newStratumError(errJobNotFound,
+			"job not found")
+	}
+	j := cj.job
+	best := s.bestSnapshot()
+	if !j.block.Header.PrevBlock.IsEqual(&best.Hash) {
+		s.recordShare(name, cj.difficulty, shareStale)
+		return nil, nil, nil, newStratumError(errJobNotFound,
+			"stale job")
+	}
+
+	extraNonce2, err := hex.DecodeString(extraNonce2Hex)
+	if err != nil || len(extraNonce2) != extraNonce2Size {
+		s.recordShare(name, cj.difficulty, shareRejected)
+		return nil, nil, nil, newStratumError(errOther,
+			"invalid extranonce2")
+	}
+	timestamp, err := parseHexUint32(timeHex)
+	if err != nil {
+		s.recordShare(name, cj.difficulty, shareRejected)
+		return nil, nil, nil, newStratumError(errOther, "invalid ntime")
+	}
+	shareTime := time.Unix(int64(timestamp), 0)
+	if shareTime.Before(j.block.Header.Timestamp) ||
+		shareTime.After(blockchain.Now().Add(maxTimeOffset)) {
+
+		s.recordShare(name, cj.difficulty, shareRejected)
+		return nil, nil, nil, newStratumError(errOther,
+			"ntime out of range")
+	}
+	nonce, err := parseHexUint32(nonceHex)
+	if err != nil {
+		s.recordShare(name, cj.difficulty, shareRejected)
+		return nil, nil, nil, newStratumError(errOther, "invalid nonce")
+	}
+
+	key := fmt.Sprintf("%x%08x%08x", extraNonce2, timestamp, nonce)
+	if _, ok := cj.submitted[key]; ok {
+		s.recordShare(name, cj.difficulty, shareRejected)
+		return nil, nil, nil, newStratumError(errDuplicateShare,
+			"duplicate share")
+	}
+	cj.submitted[key] = struct{}{}
+
+	extraNonce := make([]byte, 0, extraNonceSize)
+	extraNonce = append(extraNonce, c.extraNonce1...)
+	extraNonce = append(extraNonce, extraNonce2...)
+	coinbase, err := j.coinbase(c.payTo, extraNonce)
+	if err != nil {
+		log.Errorf("Failed to create Stratum coinbase: %v", err)
+		return nil, nil, nil, newStratumError(errOther,
+			"internal error")
+	}
+	header := j.header(merkleRoot(coinbase.TxHash(), j.merkleBranch),
+		timestamp, nonce)
+	return cj, &header, coinbase, nil
+}
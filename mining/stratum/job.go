@@ -0,0 +1,258 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/eacsuite/eacd/blockchain"
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/mining"
+	"github.com/eacsuite/eacd/txscript"
+	"github.com/eacsuite/eacd/wire"
+	"github.com/eacsuite/eacutil"
+)
+
+const (
+	// extraNonce1Size is the size of the extra nonce allocated to every
+	// connection.
+	extraNonce1Size = 4
+
+	// extraNonce2Size is the size of the extra nonce miners iterate.
+	extraNonce2Size = 4
+
+	// extraNonceSize is the size of the data pushed by the coinbase script
+	// for both extra nonces.
+	extraNonceSize = extraNonce1Size + extraNonce2Size
+
+	// hashesPerDifficulty is the average number of hashes needed to find a
+	// share of difficulty one.
+	hashesPerDifficulty = 1 << 16
+)
+
+// diff1Target is the target of shares of difficulty one.  Scrypt miners and
+// pools measure share difficulty against a target 65536 times higher than the
+// one of Bitcoin, so shares of the same difficulty take about as long to find
+// with scrypt ASICs as with SHA-256 ones.
+var diff1Target = new(big.Int).Lsh(big.NewInt(0xffff), 224)
+
+// difficultyToTarget returns the target hashes of shares of the passed
+// difficulty must not exceed.
+func difficultyToTarget(difficulty float64) *big.Int {
+	target, _ := new(big.Float).Quo(new(big.Float).SetInt(diff1Target),
+		big.NewFloat(difficulty)).Int(nil)
+	return target
+}
+
+// targetToDifficulty returns the share difficulty of the passed target.
+func targetToDifficulty(target *big.Int) float64 {
+	if target.Sign() <= 0 {
+		return 0
+	}
+	difficulty, _ := new(big.Float).Quo(new(big.Float).SetInt(diff1Target),
+		new(big.Float).SetInt(target)).Float64()
+	return difficulty
+}
+
+// job is work created from a block template which is sent to all miners.  The
+// coinbase of every connection differs by its extra nonce and payment script.
+type job struct {
+	// block is the block template.  Its coinbase script holds the height
+	// and extra nonce of the template without the extra nonces of the
+	// miners.
+	block *wire.MsgBlock
+
+	// height is the height of the block.
+	height int32
+
+	// merkleBranch holds the hashes needed to calculate the merkle root of
+	// the block from the hash of its coinbase.
+	merkleBranch []*chainhash.Hash
+
+	// txUpdate is when the transaction source was last updated before the
+	// template was created.
+	txUpdate time.Time
+
+	// created is when the job was created.
+	created time.Time
+
+	// minRelayTxFee is the minimum relay fee in Satoshi/1000 bytes below
+	// which payouts are dust.
+	minRelayTxFee eacutil.Amount
+}
+
+// newJob returns a job for the passed block template.  The coinbase script is
+// updated with the passed extra nonce so every job is unique.  Payouts which
+// are dust under the passed minimum relay fee are folded into the largest
+// payout of the coinbase.
+func newJob(g *mining.BlkTmplGenerator, template *mining.BlockTemplate,
+	extraNonce uint64, txUpdate time.Time,
+	minRelayTxFee eacutil.Amount) (*job, error) {
+
+	block := template.Block
+	err := g.UpdateExtraNonce(block, template.Height, extraNonce)
+	if err != nil {
+		return nil, err
+	}
+	scriptLen := len(block.Transactions[0].TxIn[0].SignatureScript) + 1 +
+		extraNonceSize
+	if scriptLen > blockchain.MaxCoinbaseScriptLen {
+		return nil, fmt.Errorf("coinbase transaction script length "+
+			"of %d is out of range (max: %d)", scriptLen,
+			blockchain.MaxCoinbaseScriptLen)
+	}
+
+	return &job{
+		block:         block,
+		height:        template.Height,
+		merkleBranch:  merkleBranch(block.Transactions),
+		txUpdate:      txUpdate,
+		created:       blockchain.Now(),
+		minRelayTxFee: minRelayTxFee,
+	}, nil
+}
+
+// coinbase returns the coinbase transaction of the job paying to the passed
+// payouts with the passed extra nonces appended to its script.
+func (j *job) coinbase(payouts []mining.Payout, extraNonce []byte) (*wire.MsgTx, error) {
+	tx := j.block.Transactions[0].Copy()
+	baseScript := tx.TxIn[0].SignatureScript
+	script := make([]byte, 0, len(baseScript)+1+len(extraNonce))
+	script = append(script, baseScript...)
+	script = append(script, txscript.OP_DATA_1-1+byte(len(extraNonce)))
+	script = append(script, extraNonce...)
+	tx.TxIn[0].SignatureScript = script
+	if err := mining.PayCoinbase(tx, payouts, j.minRelayTxFee); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// coinbaseParts returns the serialized coinbase transaction of the job paying
+// to the passed payouts split around the extra nonces.  The witness of the
+// coinbase is not part of them since it doesn't change its hash.
+func (j *job) coinbaseParts(payouts []mining.Payout) ([]byte, []byte, error) {
+	tx, err := j.coinbase(payouts, make([]byte, extraNonceSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	buf.Grow(tx.SerializeSizeStripped())
+	if err := tx.SerializeNoWitness(&buf); err != nil {
+		return nil, nil, err
+	}
+
+	// The extra nonces are at the end of the script of the only input,
+	// which follows the version, input count, previous outpoint and
+	// script length.
+	script := tx.TxIn[0].SignatureScript
+	offset := 4 + wire.VarIntSerializeSize(uint64(len(tx.TxIn))) + 36 +
+		wire.VarIntSerializeSize(uint64(len(script))) + len(script) -
+		extraNonceSize
+	serialized := buf.Bytes()
+	return serialized[:offset], serialized[offset+extraNonceSize:], nil
+}
+
+// header returns the header of the block with the passed merkle root, time
+// and nonce.
+func (j *job) header(merkleRoot *chainhash.Hash, timestamp, nonce uint32) wire.BlockHeader {
+	header := j.block.Header
+	header.MerkleRoot = *merkleRoot
+	header.Timestamp = time.Unix(int64(timestamp), 0)
+	header.Nonce = nonce
+	return header
+}
+
+// solvedBlock returns the block of the job with the passed header and
+// coinbase.
+func (j *job) solvedBlock(header *wire.BlockHeader, coinbase *wire.MsgTx) *wire.MsgBlock {
+	block := &wire.MsgBlock{
+		Header:       *header,
+		Transactions: make([]*wire.MsgTx, len(j.block.Transactions)),
+	}
+	copy(block.Transactions, j.block.Transactions)
+	block.Transactions[0] = coinbase
+	return block
+}
+
+// notifyParams returns the parameters of the mining.notify notification for
+// the job with the passed ID and coinbase parts.
+func (j *job) notifyParams(id string, coinbase1, coinbase2 []byte, clean bool) []interface{} {
+	branch := make([]string, 0, len(j.merkleBranch))
+	for _, hash := range j.merkleBranch {
+		branch = append(branch, hex.EncodeToString(hash[:]))
+	}
+	header := &j.block.Header
+	return []interface{}{
+		id,
+		hex.EncodeToString(swapWords(header.PrevBlock[:])),
+		hex.EncodeToString(coinbase1),
+		hex.EncodeToString(coinbase2),
+		branch,
+		fmt.Sprintf("%08x", uint32(header.Version)),
+		fmt.Sprintf("%08x", header.Bits),
+		fmt.Sprintf("%08x", uint32(header.Timestamp.Unix())),
+		clean,
+	}
+}
+
+// swapWords returns a copy of the passed bytes with the byte order of every
+// four byte word reversed, which is how Stratum encodes the previous block
+// hash.
+func swapWords(b []byte) []byte {
+	swapped := make([]byte, len(b))
+	for i := 0; i+4 <= len(b); i += 4 {
+		binary.BigEndian.PutUint32(swapped[i:],
+			binary.LittleEndian.Uint32(b[i:]))
+	}
+	return swapped
+}
+
+// merkleBranch returns the hashes needed to calculate the merkle root of the
+// passed transactions from the hash of the first one, which is the coinbase.
+// They don't depend on the coinbase, so miners can calculate the merkle root
+// for any extra nonce.
+func merkleBranch(txns []*wire.MsgTx) []*chainhash.Hash {
+	level := make([]*chainhash.Hash, len(txns))
+	for i := 1; i < len(txns); i++ {
+		hash := txns[i].TxHash()
+		level[i] = &hash
+	}
+
+	var branch []*chainhash.Hash
+	for len(level) > 1 {
+		// The last hash is paired with itself when there is an odd
+		// number of them like in BuildMerkleTreeStore.
+		if len(level)%2 != 0 {
+			level = append(level, level[len(level)-1])
+		}
+		branch = append(branch, level[1])
+
+		// The first hash of every level depends on the coinbase, so
+		// it is left for the miner to calculate.
+		next := make([]*chainhash.Hash, len(level)/2)
+		for i := 1; i < len(next); i++ {
+			next[i] = blockchain.HashMerkleBranches(level[2*i],
+				level[2*i+1])
+		}
+		level = next
+	}
+	return branch
+}
+
+// merkleRoot returns the merkle root of a block whose coinbase has the passed
+// hash from the passed merkle branch.
+func merkleRoot(coinbaseHash chainhash.Hash, branch []*chainhash.Hash) *chainhash.Hash {
+	root := &coinbaseHash
+	for _, hash := range branch {
+		root = blockchain.HashMerkleBranches(root, hash)
+	}
+	return root
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/eacsuite/eacd/blockchain"
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/mining"
+	"github.com/eacsuite/eacd/txscript"
+	"github.com/eacsuite/eacd/wire"
+	"github.com/eacsuite/eacutil"
+)
+
+// testBlock returns a block with a coinbase and the passed number of other
+// transactions.
+func testBlock(numTxns int) *wire.MsgBlock {
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex),
+		SignatureScript: []byte{txscript.OP_1, txscript.OP_2},
+		Sequence:        wire.MaxTxInSequenceNum,
+	})
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, []byte{txscript.OP_TRUE}))
+
+	block := wire.NewMsgBlock(&wire.BlockHeader{
+		Version:   1,
+		Bits:      0x1e0ffff0,
+		Timestamp: time.Unix(1600000000, 0),
+	})
+	block.AddTransaction(coinbase)
+	for i := 0; i < numTxns; i++ {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+				uint32(i)),
+		})
+		tx.AddTxOut(wire.NewTxOut(int64(i), nil))
+		block.AddTransaction(tx)
+	}
+	return block
+}
+
+// TestMerkleBranch ensures the merkle root calculated from the merkle branch
+// matches the one of the whole merkle tree.
+func TestMerkleBranch(t *testing.T) {
+	t.Parallel()
+
+	for numTxns := 0; numTxns < 12; numTxns++ {
+		block := testBlock(numTxns)
+		branch := merkleBranch(block.Transactions)
+
+		utilBlock := eacutil.NewBlock(block)
+		merkles := blockchain.BuildMerkleTreeStore(utilBlock.Transactions(),
+			false)
+		want := merkles[len(merkles)-1]
+		got := merkleRoot(block.Transactions[0].TxHash(), branch)
+		if !got.IsEqual(want) {
+			t.Errorf("merkle root with %d transactions: got %v, "+
+				"want %v", numTxns+1, got, want)
+		}
+	}
+}
+
+// TestCoinbaseParts ensures the coinbase parts sent to miners reassemble into
+// the coinbase with the extra nonces of the miner.
+func TestCoinbaseParts(t *testing.T) {
+	t.Parallel()
+
+	j := &job{block: testBlock(2)}
+	addr1, err := eacutil.NewAddressPubKeyHash(make([]byte, 20),
+		&chaincfg.SimNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+	addr2, err := eacutil.NewAddressScriptHashFromHash(make([]byte, 20),
+		&chaincfg.SimNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressScriptHashFromHash: unexpected error: %v", err)
+	}
+	payouts := []mining.Payout{
+		{Address: addr1, Weight: 1},
+		{Address: addr2, Weight: 3},
+	}
+	coinbase1, coinbase2, err := j.coinbaseParts(payouts)
+	if err != nil {
+		t.Fatalf("coinbaseParts: unexpected error: %v", err)
+	}
+
+	extraNonce := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	var assembled []byte
+	assembled = append(assembled, coinbase1...)
+	assembled = append(assembled, extraNonce...)
+	assembled = append(assembled, coinbase2...)
+
+	var want bytes.Buffer
+	coinbase, err := j.coinbase(payouts, extraNonce)
+	if err != nil {
+		t.Fatalf("coinbase: unexpected error: %v", err)
+	}
+	if len(coinbase.TxOut) != 2 || coinbase.TxOut[0].Value != 1250000000 ||
+		coinbase.TxOut[1].Value != 3750000000 {
+
+		t.Fatalf("coinbase does not split the reward among the payouts")
+	}
+	if err := coinbase.SerializeNoWitness(&want); err != nil {
+		t.Fatalf("SerializeNoWitness: unexpected error: %v", err)
+	}
+	if !bytes.Equal(assembled, want.Bytes()) {
+		t.Fatalf("assembled coinbase: got %x, want %x", assembled,
+			want.Bytes())
+	}
+
+	// The template coinbase must not be modified.
+	templateCoinbase := j.block.Transactions[0]
+	if len(templateCoinbase.TxIn[0].SignatureScript) != 2 ||
+		len(templateCoinbase.TxOut) != 1 {
+
+		t.Fatalf("template coinbase was modified")
+	}
+}
+
+// TestDifficultyTarget ensures share difficulties and targets convert to each
+// other.
+func TestDifficultyTarget(t *testing.T) {
+	t.Parallel()
+
+	if diff1Target.Cmp(blockchain.CompactToBig(0x1f00ffff)) != 0 {
+		t.Fatalf("unexpected difficulty one target %x", diff1Target)
+	}
+
+	tests := []float64{1, 0.5, 2, 1024, 65536, 1e9}
+	for _, difficulty := range tests {
+		target := difficultyToTarget(difficulty)
+		got := targetToDifficulty(target)
+		if math.Abs(got-difficulty)/difficulty > 1e-9 {
+			t.Errorf("difficulty %v: got %v after conversion",
+				difficulty, got)
+		}
+	}
+}
+
+// TestRetargetDifficulty ensures the share difficulty adjusts to the rate of
+// shares within the allowed factor.
+func TestRetargetDifficulty(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		shares  int
+		want    float64
+	}{
+		{"on target", 90 * time.Second, 6, 100},
+		{"twice as fast", 90 * time.Second, 12, 200},
+		{"half as fast", 90 * time.Second, 3, 50},
+		{"much faster", 90 * time.Second, 1000, 400},
+		{"much slower", 900 * time.Second, 1, 25},
+		{"no shares", 90 * time.Second, 0, 25},
+	}
+
+	for _, test := range tests {
+		got := retargetDifficulty(100, test.elapsed, test.shares)
+		if math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestSwapWords ensures the byte order of every four byte word is reversed.
+func TestSwapWords(t *testing.T) {
+	t.Parallel()
+
+	got := swapWords([]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	want := []byte{4, 3, 2, 1, 8, 7, 6, 5}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %x, want %x", got, want)
+	}
+}
@@ -0,0 +1,619 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eacsuite/eacd/blockchain"
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/mining"
+	"github.com/eacsuite/eacutil"
+)
+
+const (
+	// jobUpdateInterval is the interval at which the server checks whether
+	// new work has to be sent to the miners.
+	jobUpdateInterval = time.Second
+
+	// txUpdateJobInterval is the minimum time between jobs which are only
+	// created to include new transactions from the memory pool.
+	txUpdateJobInterval = time.Minute
+
+	// hashRateWindow is the duration of the window over which the hash rate
+	// of workers is calculated.  Statistics of disconnected workers are
+	// kept until they have been idle for that long.
+	hashRateWindow = 10 * time.Minute
+
+	// maxAcceptDelay is the maximum time to wait before accepting another
+	// connection after accepting failed.
+	maxAcceptDelay = time.Second
+)
+
+// Config is a descriptor containing the Stratum server configuration.
+type Config struct {
+	// ChainParams identifies which chain parameters the Stratum server is
+	// associated with.
+	ChainParams *chaincfg.Params
+
+	// BlockTemplateGenerator identifies the instance to use in order to
+	// generate block templates that miners will attempt to solve.
+	BlockTemplateGenerator *mining.BlkTmplGenerator
+
+	// MiningPayouts is a list of payout lists to use for workers whose
+	// name is not an address.  Each worker randomly chooses one of them
+	// and splits the reward of its blocks among the payouts of the list.
+	MiningPayouts [][]mining.Payout
+
+	// ProcessBlock defines the function to call with any solved blocks.
+	// It typically must run the provided block through the same set of
+	// rules and handling as any other block coming from the network.
+	ProcessBlock func(*eacutil.Block, blockchain.BehaviorFlags) (bool, error)
+
+	// ConnectedCount defines the function to use to obtain how many other
+	// peers the server is connected to.  No work is handed out when not
+	// connected to any peers since there would be no one to send any found
+	// blocks to.
+	ConnectedCount func() int32
+
+	// IsCurrent defines the function to use to obtain whether or not the
+	// block chain is current.  No work is handed out when the chain is not
+	// current since any solved blocks would be on a side chain and end up
+	// orphaned anyways.
+	IsCurrent func() bool
+
+	// Listeners defines a slice of listeners for which the server will
+	// take ownership of and accept connections.  Since the server takes
+	// ownership of these listeners, they will be closed when the server is
+	// stopped.
+	Listeners []net.Listener
+
+	// Difficulty is the share difficulty new connections start with before
+	// it is adjusted to their hash rate.
+	Difficulty float64
+
+	// MaxClients is the maximum number of miner connections.  Further
+	// connections are closed right away.
+	MaxClients int
+
+	// MinRelayTxFee is the minimum relay fee in Satoshi/1000 bytes.  It
+	// determines which payouts are too small to be paid on their own, in
+	// which case they are folded into the largest payout.
+	MinRelayTxFee eacutil.Amount
+}
+
+// WorkerStats describes the shares submitted by a worker.
+type WorkerStats struct {
+	// Name is the name the worker authorized with.
+	Name string
+
+	// Connections is the number of connections authorized as the worker.
+	Connections int
+
+	// Difficulty is the share difficulty most recently sent to the worker.
+	Difficulty float64
+
+	// HashesPerSec is the hash rate of the worker estimated from the
+	// difficulty of its recent shares.
+	HashesPerSec float64
+
+	// AcceptedShares, RejectedShares and StaleShares are the numbers of
+	// shares the worker submitted which were accepted, rejected as invalid
+	// and rejected as stale respectively.
+	AcceptedShares uint64
+	RejectedShares uint64
+	StaleShares    uint64
+
+	// BlocksFound is the number of shares which were accepted as blocks.
+	BlocksFound uint64
+
+	// LastShare is when the worker last submitted an accepted share.
+	LastShare time.Time
+}
+
+// share is an accepted share used to calculate the hash rate of a worker.
+type share struct {
+	difficulty float64
+	time       time.Time
+}
+
+// worker houses the statistics of a worker.
+type worker struct {
+	stats     WorkerStats
+	shares    []share
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// hashesPerSec returns the hash rate of the worker at the passed time.
+func (w *worker) hashesPerSec(now time.Time) float64 {
+	window := now.Sub(w.firstSeen)
+	if window > hashRateWindow {
+		window = hashRateWindow
+	}
+	if window <= 0 {
+		return 0
+	}
+
+	var difficulty float64
+	for _, share := range w.shares {
+		if now.Sub(share.time) <= hashRateWindow {
+			difficulty += share.difficulty
+		}
+	}
+	return difficulty * hashesPerDifficulty / window.Seconds()
+}
+
+// shareResult describes what happened to a submitted share.
+type shareResult int
+
+const (
+	shareAccepted shareResult = iota
+	shareRejected
+	shareStale
+	shareBlock
+)
+
+// Server provides a Stratum v1 server which hands out work created from block
+// templates to scrypt miners and submits the blocks they solve.
+type Server struct {
+	sync.Mutex
+	g               *mining.BlkTmplGenerator
+	bestSnapshot    func() *blockchain.BestState
+	cfg             Config
+	started         bool
+	shutdown        int32
+	submitBlockLock sync.Mutex
+	wg              sync.WaitGroup
+	quit            chan struct{}
+
+	clientsMtx  sync.Mutex
+	clients     map[*client]struct{}
+	extraNonce1 uint32
+
+	jobMtx     sync.RWMutex
+	currentJob *job
+	jobCounter uint64
+
+	workersMtx sync.Mutex
+	workers    map[string]*worker
+}
+
+// listenHandler accepts incoming connections on the passed listener.  It must
+// be run as a goroutine.
+func (s *Server) listenHandler(listener net.Listener) {
+	log.Infof("Stratum server listening on %s", listener.Addr())
+	var acceptDelay time.Duration
+	for atomic.LoadInt32(&s.shutdown) == 0 {
+		conn, err := listener.Accept()
+		if err != nil {
+			// Only log the error if not forcibly shutting down.
+			if atomic.LoadInt32(&s.shutdown) != 0 {
+				break
+			}
+			log.Errorf("Can't accept connection: %v", err)
+
+			// Back off so persistent errors such as running out
+			// of file descriptors don't spin.
+			if acceptDelay == 0 {
+				acceptDelay = 5 * time.Millisecond
+			} else {
+				acceptDelay *= 2
+			}
+			if acceptDelay > maxAcceptDelay {
+				acceptDelay = maxAcceptDelay
+			}
+			select {
+			case <-time.After(acceptDelay):
+			case <-s.quit:
+			}
+			continue
+		}
+		acceptDelay = 0
+
+		s.wg.Add(1)
+		go s.handleConn(conn)
+	}
+	s.wg.Done()
+	log.Tracef("Stratum listener done for %s", listener.Addr())
+}
+
+// handleConn serves a miner connected over the passed connection until it
+// disconnects.  It must be run as a goroutine.
+func (s *Server) handleConn(conn net.Conn) {
+	s.clientsMtx.Lock()
+	if len(s.clients) >= s.cfg.MaxClients {
+		s.clientsMtx.Unlock()
+		log.Infof("Max Stratum clients exceeded [%d] - disconnecting "+
+			"client %s", s.cfg.MaxClients, conn.RemoteAddr())
+		conn.Close()
+		s.wg.Done()
+		return
+	}
+	s.extraNonce1++
+	c := newClient(s, conn, s.extraNonce1)
+	s.clients[c] = struct{}{}
+	s.clientsMtx.Unlock()
+
+	// Connections accepted while shutting down are closed right away since
+	// Stop might have already closed all the known ones.
+	if atomic.LoadInt32(&s.shutdown) != 0 {
+		c.disconnect()
+	}
+
+	log.Debugf("New Stratum connection from %s", conn.RemoteAddr())
+	s.wg.Add(1)
+	go c.outHandler()
+	c.inHandler()
+
+	s.clientsMtx.Lock()
+	delete(s.clients, c)
+	s.clientsMtx.Unlock()
+	for _, name := range c.workerNames() {
+		s.removeWorkerConn(name)
+	}
+	log.Debugf("Stratum connection from %s closed", conn.RemoteAddr())
+	s.wg.Done()
+}
+
+// connectedClients returns the currently connected clients.
+func (s *Server) connectedClients() []*client {
+	s.clientsMtx.Lock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.clientsMtx.Unlock()
+	return clients
+}
+
+// job returns the current job.  It is nil when there is no work to hand out.
+func (s *Server) job() *job {
+	s.jobMtx.RLock()
+	j := s.currentJob
+	s.jobMtx.RUnlock()
+	return j
+}
+
+// updateJob creates a new job when the best block changed or when there are
+// new transactions and the current job is old enough.  It returns the new job
+// and whether miners must abandon previous jobs, or nil when the current job
+// is still good.
+func (s *Server) updateJob() (*job, bool, error) {
+	// Grab the same lock as used for block submission, since the current
+	// block will be changing and this would otherwise end up building a
+	// new block template on a block that is in the process of becoming
+	// stale.
+	s.submitBlockLock.Lock()
+	best := s.bestSnapshot()
+	if best.Height != 0 && !s.cfg.IsCurrent() {
+		s.submitBlockLock.Unlock()
+		return nil, false, nil
+	}
+
+	current := s.job()
+	lastTxUpdate := s.g.TxSource().LastUpdated()
+	clean := current == nil || !current.block.Header.PrevBlock.IsEqual(&best.Hash)
+	if !clean && (lastTxUpdate == current.txUpdate ||
+		blockchain.Now().Sub(current.created) < txUpdateJobInterval) {
+
+		s.submitBlockLock.Unlock()
+		return nil, false, nil
+	}
+
+	// The template pays to an anyone can spend script which is replaced
+	// with the payment script of every connection.
+	template, err := s.g.NewBlockTemplate(nil)
+	s.submitBlockLock.Unlock()
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.jobMtx.Lock()
+	defer s.jobMtx.Unlock()
+	s.jobCounter++
+	j, err := newJob(s.g, template, s.jobCounter, lastTxUpdate,
+		s.cfg.MinRelayTxFee)
+	if err != nil {
+		return nil, false, err
+	}
+	s.currentJob = j
+	return j, clean, nil
+}
+
+// jobUpdater periodically sends new work to the miners and adjusts their
+// share difficulty.  It must be run as a goroutine.
+func (s *Server) jobUpdater() {
+	ticker := time.NewTicker(jobUpdateInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			s.pruneWorkers(now)
+
+			// There is no point in creating templates without any
+			// miners or without any peers to relay found blocks to.
+			clients := s.connectedClients()
+			if len(clients) == 0 || s.cfg.ConnectedCount() == 0 {
+				s.jobMtx.Lock()
+				s.currentJob = nil
+				s.jobMtx.Unlock()
+				continue
+			}
+
+			j, clean, err := s.updateJob()
+			if err != nil {
+				log.Errorf("Failed to create new block template: %v",
+					err)
+				continue
+			}
+			if j != nil {
+				log.Debugf("New Stratum job for block height %d "+
+					"(clean %v)", j.height, clean)
+				for _, c := range clients {
+					c.sendJob(j, clean)
+				}
+			}
+
+			for _, c := range clients {
+				c.retarget(now)
+			}
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	s.wg.Done()
+	log.Tracef("Stratum job updater done")
+}
+
+// networkDifficulty returns the share difficulty of the network target of the
+// passed job.
+func networkDifficulty(j *job) float64 {
+	return targetToDifficulty(blockchain.CompactToBig(j.block.Header.Bits))
+}
+
+// clampDifficulty returns the passed share difficulty limited to the range
+// allowed for the passed job.  Shares harder than the network target are never
+// asked for since they wouldn't be found any faster than blocks.
+func (s *Server) clampDifficulty(difficulty float64, j *job) float64 {
+	difficulty = math.Max(difficulty, math.Min(minDifficulty, s.cfg.Difficulty))
+	if j != nil {
+		difficulty = math.Min(difficulty, networkDifficulty(j))
+	}
+	return difficulty
+}
+
+// submitBlock submits the passed block to network after ensuring it passes all
+// of the consensus validation rules.
+func (s *Server) submitBlock(block *eacutil.Block) bool {
+	s.submitBlockLock.Lock()
+	defer s.submitBlockLock.Unlock()
+
+	// Ensure the block is not stale since a new block could have shown up
+	// while the share was in flight.
+	msgBlock := block.MsgBlock()
+	if !msgBlock.Header.PrevBlock.IsEqual(&s.bestSnapshot().Hash) {
+		log.Debugf("Block submitted via Stratum with previous block "+
+			"%s is stale", msgBlock.Header.PrevBlock)
+		return false
+	}
+
+	// Process this block using the same rules as blocks coming from other
+	// nodes.  This will in turn relay it to the network like normal.
+	isOrphan, err := s.cfg.ProcessBlock(block, blockchain.BFNone)
+	if err != nil {
+		// Anything other than a rule violation is an unexpected error,
+		// so log that error as an internal error.
+		if _, ok := err.(blockchain.RuleError); !ok {
+			log.Errorf("Unexpected error while processing "+
+				"block submitted via Stratum: %v", err)
+			return false
+		}
+
+		log.Debugf("Block submitted via Stratum rejected: %v", err)
+		return false
+	}
+	if isOrphan {
+		log.Debugf("Block submitted via Stratum is an orphan")
+		return false
+	}
+
+	// The block was accepted.
+	coinbaseTx := msgBlock.Transactions[0].TxOut[0]
+	log.Infof("Block submitted via Stratum accepted (hash %s, "+
+		"amount %v)", block.Hash(), eacutil.Amount(coinbaseTx.Value))
+	return true
+}
+
+// addWorkerConn records a new connection authorized as the named worker.
+func (s *Server) addWorkerConn(name string, difficulty float64) {
+	s.workersMtx.Lock()
+	defer s.workersMtx.Unlock()
+
+	now := time.Now()
+	w, ok := s.workers[name]
+	if !ok {
+		w = &worker{stats: WorkerStats{Name: name}, firstSeen: now}
+		s.workers[name] = w
+	}
+	w.stats.Connections++
+	w.stats.Difficulty = difficulty
+	w.lastSeen = now
+}
+
+// removeWorkerConn records that a connection authorized as the named worker
+// was closed.
+func (s *Server) removeWorkerConn(name string) {
+	s.workersMtx.Lock()
+	if w, ok := s.workers[name]; ok {
+		w.stats.Connections--
+		w.lastSeen = time.Now()
+	}
+	s.workersMtx.Unlock()
+}
+
+// setWorkerDifficulty records the share difficulty sent to the named worker.
+func (s *Server) setWorkerDifficulty(name string, difficulty float64) {
+	s.workersMtx.Lock()
+	if w, ok := s.workers[name]; ok {
+		w.stats.Difficulty = difficulty
+	}
+	s.workersMtx.Unlock()
+}
+
+// recordShare records a share of the passed difficulty submitted by the named
+// worker.
+func (s *Server) recordShare(name string, difficulty float64, result shareResult) {
+	s.workersMtx.Lock()
+	defer s.workersMtx.Unlock()
+
+	w, ok := s.workers[name]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	w.lastSeen = now
+	switch result {
+	case shareRejected:
+		w.stats.RejectedShares++
+		return
+	case shareStale:
+		w.stats.StaleShares++
+		return
+	case shareBlock:
+		w.stats.BlocksFound++
+	}
+	w.stats.AcceptedShares++
+	w.stats.LastShare = now
+
+	// Drop shares which fell out of the hash rate window.
+	var i int
+	for i < len(w.shares) && now.Sub(w.shares[i].time) > hashRateWindow {
+		i++
+	}
+	w.shares = append(w.shares[i:], share{difficulty: difficulty, time: now})
+}
+
+// pruneWorkers removes the statistics of workers which have been disconnected
+// for longer than the hash rate window.
+func (s *Server) pruneWorkers(now time.Time) {
+	s.workersMtx.Lock()
+	for name, w := range s.workers {
+		if w.stats.Connections <= 0 && now.Sub(w.lastSeen) > hashRateWindow {
+			delete(s.workers, name)
+		}
+	}
+	s.workersMtx.Unlock()
+}
+
+// WorkerStats returns the statistics of the workers which are connected or
+// submitted shares recently, sorted by name.
+//
+// This function is safe for concurrent access.
+func (s *Server) WorkerStats() []WorkerStats {
+	s.workersMtx.Lock()
+	now := time.Now()
+	stats := make([]WorkerStats, 0, len(s.workers))
+	for _, w := range s.workers {
+		ws := w.stats
+		ws.HashesPerSec = w.hashesPerSec(now)
+		stats = append(stats, ws)
+	}
+	s.workersMtx.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// NumClients returns the number of miners connected to the server.
+//
+// This function is safe for concurrent access.
+func (s *Server) NumClients() int {
+	s.clientsMtx.Lock()
+	defer s.clientsMtx.Unlock()
+	return len(s.clients)
+}
+
+// Start begins accepting connections from miners and handing out work.
+// Calling this function when the server has already been started will have no
+// effect.
+//
+// This function is safe for concurrent access.
+func (s *Server) Start() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.started {
+		return
+	}
+
+	s.quit = make(chan struct{})
+	atomic.StoreInt32(&s.shutdown, 0)
+	for _, listener := range s.cfg.Listeners {
+		s.wg.Add(1)
+		go s.listenHandler(listener)
+	}
+	s.wg.Add(1)
+	go s.jobUpdater()
+
+	s.started = true
+	log.Infof("Stratum server started")
+}
+
+// Stop gracefully stops the server by closing its listeners and all
+// connections.  Calling this function when the server has not already been
+// started will have no effect.
+//
+// This function is safe for concurrent access.
+func (s *Server) Stop() {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.started {
+		return
+	}
+
+	atomic.StoreInt32(&s.shutdown, 1)
+	close(s.quit)
+	for _, listener := range s.cfg.Listeners {
+		err := listener.Close()
+		if err != nil {
+			log.Errorf("Problem shutting down Stratum listener: %v",
+				err)
+		}
+	}
+	for _, c := range s.connectedClients() {
+		c.disconnect()
+	}
+	s.wg.Wait()
+
+	s.started = false
+	log.Infof("Stratum server stopped")
+}
+
+// New returns a new instance of a Stratum server for the provided
+// configuration.  Use Start to begin accepting miners.  See the documentation
+// for Server type for more details.
+func New(cfg *Config) *Server {
+	return &Server{
+		g:            cfg.BlockTemplateGenerator,
+		bestSnapshot: cfg.BlockTemplateGenerator.BestSnapshot,
+		cfg:          *cfg,
+		clients:      make(map[*client]struct{}),
+		workers:      make(map[string]*worker),
+	}
+}
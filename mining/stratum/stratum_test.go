@@ -0,0 +1,320 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package stratum
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eacsuite/eacd/blockchain"
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/mining"
+	"github.com/eacsuite/eacutil"
+)
+
+// testMessage is a message received by a test miner.
+type testMessage struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params []interface{}   `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  []interface{}   `json:"error"`
+}
+
+// testMiner is an in-process miner connected to a Stratum server.
+type testMiner struct {
+	t      *testing.T
+	conn   net.Conn
+	r      *bufio.Reader
+	nextID int
+}
+
+// connectMiner connects a miner to the passed server over an in-memory
+// connection.
+func connectMiner(t *testing.T, s *Server) *testMiner {
+	serverConn, minerConn := net.Pipe()
+	minerConn.SetDeadline(time.Now().Add(time.Minute))
+	s.wg.Add(1)
+	go s.handleConn(serverConn)
+	return &testMiner{t: t, conn: minerConn, r: bufio.NewReader(minerConn)}
+}
+
+// read reads the next message sent to the miner.
+func (m *testMiner) read() *testMessage {
+	m.t.Helper()
+
+	line, err := m.r.ReadBytes('\n')
+	if err != nil {
+		m.t.Fatalf("unable to read message: %v", err)
+	}
+	var msg testMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		m.t.Fatalf("unable to decode message %s: %v", line, err)
+	}
+	return &msg
+}
+
+// request sends a request with the passed method and parameters and returns
+// the response.
+func (m *testMiner) request(method string, params ...interface{}) *testMessage {
+	m.t.Helper()
+
+	if params == nil {
+		params = []interface{}{}
+	}
+	m.nextID++
+	b, err := json.Marshal(map[string]interface{}{
+		"id":     m.nextID,
+		"method": method,
+		"params": params,
+	})
+	if err != nil {
+		m.t.Fatalf("unable to encode request: %v", err)
+	}
+	if _, err := m.conn.Write(append(b, '\n')); err != nil {
+		m.t.Fatalf("unable to write request: %v", err)
+	}
+	resp := m.read()
+	if string(resp.ID) != strconv.Itoa(m.nextID) {
+		m.t.Fatalf("got response with id %s to request %d", resp.ID,
+			m.nextID)
+	}
+	return resp
+}
+
+// expectNotification reads the next message and ensures it is a notification
+// with the passed method.
+func (m *testMiner) expectNotification(method string) []interface{} {
+	m.t.Helper()
+
+	msg := m.read()
+	if msg.Method != method {
+		m.t.Fatalf("got message %q, want %q", msg.Method, method)
+	}
+	return msg.Params
+}
+
+// expectResult ensures the passed response succeeded with a true result.
+func expectResult(t *testing.T, resp *testMessage) {
+	t.Helper()
+
+	if resp.Error != nil || string(resp.Result) != "true" {
+		t.Fatalf("got result %s (error %v), want true", resp.Result,
+			resp.Error)
+	}
+}
+
+// expectError ensures the passed response failed with the passed error code.
+func expectError(t *testing.T, resp *testMessage, code int) {
+	t.Helper()
+
+	if len(resp.Error) == 0 || resp.Error[0] != float64(code) {
+		t.Fatalf("got error %v, want code %d", resp.Error, code)
+	}
+}
+
+// TestServerConnection ensures a miner can subscribe, authorize, receive work
+// and submit shares and blocks over a connection, and that its share
+// difficulty is adjusted to the rate of its shares.
+func TestServerConnection(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.SimNetParams
+	addr, err := eacutil.NewAddressPubKeyHash(make([]byte, 20), params)
+	if err != nil {
+		t.Fatalf("NewAddressPubKeyHash: unexpected error: %v", err)
+	}
+
+	// Every hash meets the share difficulty while about half of them meet
+	// the target of the block.
+	const difficulty = 1e-6
+	prevHash := chainhash.Hash{1}
+	block := testBlock(2)
+	block.Header.PrevBlock = prevHash
+	block.Header.Bits = 0x207fffff
+
+	var s *Server
+	var mtx sync.Mutex
+	var blocks []*eacutil.Block
+	s = New(&Config{
+		ChainParams: params,
+		ProcessBlock: func(block *eacutil.Block, flags blockchain.BehaviorFlags) (bool, error) {
+			// Blocks must be processed without holding the
+			// client lock, otherwise this deadlocks.
+			for _, c := range s.connectedClients() {
+				c.workerNames()
+			}
+			mtx.Lock()
+			blocks = append(blocks, block)
+			mtx.Unlock()
+			return false, nil
+		},
+		Difficulty: difficulty,
+		MaxClients: 1,
+	})
+	s.bestSnapshot = func() *blockchain.BestState {
+		return &blockchain.BestState{Hash: prevHash}
+	}
+	j := &job{
+		block:        block,
+		height:       1,
+		merkleBranch: merkleBranch(block.Transactions),
+		created:      time.Now(),
+	}
+	s.currentJob = j
+
+	m := connectMiner(t, s)
+	defer func() {
+		m.conn.Close()
+		s.wg.Wait()
+	}()
+
+	// Shares can't be submitted before subscribing.
+	expectError(t, m.request("mining.submit", "w", "1", "00000000",
+		"00000000", "00000000"), errNotSubscribed)
+
+	resp := m.request("mining.subscribe")
+	var subscribeResult []interface{}
+	if err := json.Unmarshal(resp.Result, &subscribeResult); err != nil ||
+		len(subscribeResult) != 3 {
+
+		t.Fatalf("unexpected subscribe result %s (%v)", resp.Result, err)
+	}
+	extraNonce1, err := hex.DecodeString(subscribeResult[1].(string))
+	if err != nil || len(extraNonce1) != extraNonce1Size ||
+		subscribeResult[2] != float64(extraNonce2Size) {
+
+		t.Fatalf("unexpected extra nonces in subscribe result %s",
+			resp.Result)
+	}
+
+	// Further connections are closed right away once the maximum number
+	// of clients is reached.
+	m2 := connectMiner(t, s)
+	if _, err := m2.r.ReadByte(); err == nil {
+		t.Fatalf("connection exceeding the client limit was not closed")
+	}
+
+	// Work is sent once the first worker is authorized.
+	name := addr.EncodeAddress() + ".rig"
+	expectResult(t, m.request("mining.authorize", name))
+	got := m.expectNotification("mining.set_difficulty")
+	if len(got) != 1 || got[0] != difficulty {
+		t.Fatalf("got share difficulty %v, want %v", got, difficulty)
+	}
+	notifyParams := m.expectNotification("mining.notify")
+	if len(notifyParams) != 9 || notifyParams[8] != true {
+		t.Fatalf("unexpected job %v", notifyParams)
+	}
+	jobID := notifyParams[0].(string)
+
+	// Only a limited number of workers can be authorized.
+	for i := 1; i < maxClientWorkers; i++ {
+		expectResult(t, m.request("mining.authorize",
+			fmt.Sprintf("%s.%d", addr.EncodeAddress(), i)))
+	}
+	expectError(t, m.request("mining.authorize", addr.EncodeAddress()),
+		errUnauthorized)
+
+	// Submit shares until at least one of them is a block and one isn't.
+	// The expected outcome of every share is determined by hashing it the
+	// same way the miner does.
+	const numShares = 12
+	payouts := []mining.Payout{{Address: addr, Weight: 1}}
+	extraNonce2 := make([]byte, extraNonce2Size)
+	extraNonce := append(append([]byte(nil), extraNonce1...), extraNonce2...)
+	coinbase, err := j.coinbase(payouts, extraNonce)
+	if err != nil {
+		t.Fatalf("coinbase: unexpected error: %v", err)
+	}
+	root := merkleRoot(coinbase.TxHash(), j.merkleBranch)
+	timestamp := uint32(block.Header.Timestamp.Unix())
+	blockTarget := blockchain.CompactToBig(block.Header.Bits)
+	var numBlocks, nonce uint32
+	for nonce = 0; nonce < numShares || numBlocks == 0 ||
+		numBlocks == nonce; nonce++ {
+
+		header := j.header(root, timestamp, nonce)
+		powHash, err := header.PowHash()
+		if err != nil {
+			t.Fatalf("PowHash: unexpected error: %v", err)
+		}
+		if blockchain.HashToBig(powHash).Cmp(blockTarget) <= 0 {
+			numBlocks++
+		}
+		expectResult(t, m.request("mining.submit", name, jobID,
+			hex.EncodeToString(extraNonce2),
+			fmt.Sprintf("%08x", timestamp),
+			fmt.Sprintf("%08x", nonce)))
+	}
+	mtx.Lock()
+	if uint32(len(blocks)) != numBlocks {
+		t.Fatalf("got %d blocks, want %d", len(blocks), numBlocks)
+	}
+	if blocks[0].MsgBlock().Header.MerkleRoot != *root {
+		t.Fatalf("block does not commit to the coinbase of the miner")
+	}
+	mtx.Unlock()
+
+	// Duplicate shares and shares for unknown jobs are rejected.
+	expectError(t, m.request("mining.submit", name, jobID,
+		hex.EncodeToString(extraNonce2), fmt.Sprintf("%08x", timestamp),
+		fmt.Sprintf("%08x", 0)), errDuplicateShare)
+	expectError(t, m.request("mining.submit", name, "ffff",
+		hex.EncodeToString(extraNonce2), fmt.Sprintf("%08x", timestamp),
+		fmt.Sprintf("%08x", nonce)), errJobNotFound)
+
+	stats := s.WorkerStats()
+	var found bool
+	for _, ws := range stats {
+		if ws.Name != name {
+			continue
+		}
+		found = true
+		if ws.AcceptedShares != uint64(nonce) ||
+			ws.BlocksFound != uint64(numBlocks) ||
+			ws.RejectedShares != 1 || ws.StaleShares != 1 {
+
+			t.Fatalf("unexpected worker stats %+v", ws)
+		}
+	}
+	if !found {
+		t.Fatalf("no stats for worker %q", name)
+	}
+
+	// The share difficulty rises once the retarget interval passed since
+	// more shares than targeted were submitted.
+	c := s.connectedClients()[0]
+	c.mtx.Lock()
+	windowStart := c.windowStart
+	c.mtx.Unlock()
+	c.retarget(windowStart.Add(retargetInterval))
+	want := retargetDifficulty(difficulty, retargetInterval, int(nonce))
+	got = m.expectNotification("mining.set_difficulty")
+	if len(got) != 1 || got[0] != want || want <= difficulty {
+		t.Fatalf("got share difficulty %v, want %v", got, want)
+	}
+	notifyParams = m.expectNotification("mining.notify")
+	if len(notifyParams) != 9 || notifyParams[8] != false {
+		t.Fatalf("unexpected job %v", notifyParams)
+	}
+	c.mtx.Lock()
+	newJob := c.jobs[notifyParams[0].(string)]
+	oldJob := c.jobs[jobID]
+	c.mtx.Unlock()
+	if newJob == nil || newJob.difficulty != want || oldJob == nil ||
+		oldJob.difficulty != difficulty {
+
+		t.Fatalf("jobs do not keep the difficulty they were sent with")
+	}
+}
@@ -0,0 +1,478 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NAT-PMP and PCP protocol constants as defined by RFC 6886 and RFC 6887.
+const (
+	natPMPPort    = 5351
+	natPMPVersion = 0
+	pcpVersion    = 2
+
+	natPMPOpExternalAddress = 0
+	natPMPOpMapUDP          = 1
+	natPMPOpMapTCP          = 2
+	natPMPResponseBit       = 0x80
+
+	pcpOpAnnounce = 0
+	pcpOpMap      = 1
+
+	pcpProtocolTCP = 6
+	pcpProtocolUDP = 17
+
+	pcpHeaderLen = 24
+	pcpMapLen    = 36
+
+	// natPMPTries is the number of times a request is sent before giving
+	// up.  The time to wait for a response starts at natPMPTimeout and is
+	// doubled after every try.
+	natPMPTries = 4
+)
+
+// leasedNAT is implemented by NATs whose gateway may grant port mappings a
+// shorter lifetime than requested.
+type leasedNAT interface {
+	// leaseDuration returns the lifetime granted to the last port
+	// mapping.
+	leaseDuration() time.Duration
+}
+
+// natPMPTimeout is the initial time to wait for a response from the gateway.
+// It is a variable so tests don't have to wait for unresponsive gateways.
+var natPMPTimeout = 250 * time.Millisecond
+
+// errNoNATPMPGateway is returned when the gateway speaks neither PCP nor
+// NAT-PMP.
+var errNoNATPMPGateway = errors.New("no NAT-PMP or PCP gateway found")
+
+// natPMPResultError is returned when the gateway answers a request with a
+// result code other than success.
+type natPMPResultError struct {
+	protocol string
+	code     int
+}
+
+// Error returns the error as a human-readable string and satisfies the error
+// interface.
+func (e *natPMPResultError) Error() string {
+	return fmt.Sprintf("%s request failed with result code %d", e.protocol,
+		e.code)
+}
+
+// gatewayExchange sends the request built by the passed function to the
+// gateway and returns the first response accepted by check.  The request is
+// built from the local address used to reach the gateway since PCP requires
+// it.  Requests are retransmitted with exponential backoff as recommended by
+// both protocols.
+func gatewayExchange(gateway *net.UDPAddr, build func(localIP net.IP) []byte,
+	check func(resp []byte) bool) ([]byte, error) {
+
+	conn, err := net.DialUDP("udp", nil, gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := build(conn.LocalAddr().(*net.UDPAddr).IP)
+	buf := make([]byte, 1100)
+	timeout := natPMPTimeout
+	for i := 0; i < natPMPTries; i++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(timeout)
+		timeout *= 2
+		conn.SetReadDeadline(deadline)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					break
+				}
+				// ICMP errors show up as read errors on
+				// connected sockets and mean nothing is
+				// listening on the gateway.
+				return nil, err
+			}
+			if check(buf[:n]) {
+				return buf[:n], nil
+			}
+		}
+	}
+	return nil, errNoNATPMPGateway
+}
+
+// natPMPNAT implements the NAT interface using the NAT Port Mapping Protocol
+// defined by RFC 6886.
+type natPMPNAT struct {
+	gateway *net.UDPAddr
+
+	mtx   sync.Mutex
+	lease time.Duration
+}
+
+// request sends a NAT-PMP request with the passed opcode and payload and
+// returns the response after checking its result code.
+func (n *natPMPNAT) request(op byte, payload []byte, respLen int) ([]byte, error) {
+	req := append([]byte{natPMPVersion, op}, payload...)
+	resp, err := gatewayExchange(n.gateway, func(net.IP) []byte {
+		return req
+	}, func(resp []byte) bool {
+		return len(resp) >= 4 && resp[0] == natPMPVersion &&
+			resp[1] == natPMPResponseBit|op
+	})
+	if err != nil {
+		return nil, err
+	}
+	if code := binary.BigEndian.Uint16(resp[2:4]); code != 0 {
+		return nil, &natPMPResultError{protocol: "NAT-PMP", code: int(code)}
+	}
+	if len(resp) < respLen {
+		return nil, errors.New("short NAT-PMP response")
+	}
+	return resp, nil
+}
+
+// GetExternalAddress implements the NAT interface by requesting the external
+// address from the gateway.
+func (n *natPMPNAT) GetExternalAddress() (net.IP, error) {
+	resp, err := n.request(natPMPOpExternalAddress, nil, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IPv4(resp[8], resp[9], resp[10], resp[11]), nil
+}
+
+// natPMPMapOp returns the NAT-PMP opcode used to map ports of the passed
+// protocol.
+func natPMPMapOp(protocol string) (byte, error) {
+	switch strings.ToLower(protocol) {
+	case "tcp":
+		return natPMPOpMapTCP, nil
+	case "udp":
+		return natPMPOpMapUDP, nil
+	}
+	return 0, fmt.Errorf("unsupported protocol %s", protocol)
+}
+
+// mapPort requests a mapping of the passed ports with the given lifetime in
+// seconds and returns the mapped external port and granted lifetime.
+func (n *natPMPNAT) mapPort(protocol string, externalPort, internalPort,
+	lifetime int) (int, int, error) {
+
+	op, err := natPMPMapOp(protocol)
+	if err != nil {
+		return 0, 0, err
+	}
+	payload := make([]byte, 10)
+	binary.BigEndian.PutUint16(payload[2:4], uint16(internalPort))
+	binary.BigEndian.PutUint16(payload[4:6], uint16(externalPort))
+	binary.BigEndian.PutUint32(payload[6:10], uint32(lifetime))
+	resp, err := n.request(op, payload, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	if int(binary.BigEndian.Uint16(resp[8:10])) != internalPort {
+		return 0, 0, errors.New("NAT-PMP response for wrong port")
+	}
+	return int(binary.BigEndian.Uint16(resp[10:12])),
+		int(binary.BigEndian.Uint32(resp[12:16])), nil
+}
+
+// AddPortMapping implements the NAT interface by requesting a mapping from the
+// gateway.  The gateway may assign a different external port than requested.
+func (n *natPMPNAT) AddPortMapping(protocol string, externalPort, internalPort int,
+	description string, timeout int) (int, error) {
+
+	mappedPort, lifetime, err := n.mapPort(protocol, externalPort,
+		internalPort, timeout)
+	if err != nil {
+		return 0, err
+	}
+	n.mtx.Lock()
+	n.lease = time.Duration(lifetime) * time.Second
+	n.mtx.Unlock()
+	return mappedPort, nil
+}
+
+// DeletePortMapping implements the NAT interface by requesting a mapping with
+// a lifetime of zero, which removes it.
+func (n *natPMPNAT) DeletePortMapping(protocol string, externalPort, internalPort int) error {
+	_, _, err := n.mapPort(protocol, 0, internalPort, 0)
+	return err
+}
+
+// leaseDuration returns the lifetime granted to the last port mapping.
+func (n *natPMPNAT) leaseDuration() time.Duration {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.lease
+}
+
+// pcpNAT implements the NAT interface using the Port Control Protocol defined
+// by RFC 6887.
+type pcpNAT struct {
+	gateway *net.UDPAddr
+
+	// nonce identifies the mappings of this client.  The same nonce must
+	// be used to renew and delete a mapping.
+	nonce [12]byte
+
+	mtx        sync.Mutex
+	externalIP net.IP
+	lease      time.Duration
+}
+
+// newPCPNAT returns a PCP client for the passed gateway with a random mapping
+// nonce.
+func newPCPNAT(gateway *net.UDPAddr) (*pcpNAT, error) {
+	n := &pcpNAT{gateway: gateway}
+	if _, err := rand.Read(n.nonce[:]); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// pcpHeader returns a PCP request header for the passed opcode, lifetime and
+// client address.
+func pcpHeader(op byte, lifetime uint32, clientIP net.IP) []byte {
+	hdr := make([]byte, pcpHeaderLen)
+	hdr[0] = pcpVersion
+	hdr[1] = op
+	binary.BigEndian.PutUint32(hdr[4:8], lifetime)
+	copy(hdr[8:24], clientIP.To16())
+	return hdr
+}
+
+// pcpResponse returns whether resp is a response to a request with the passed
+// opcode.  NAT-PMP responses are accepted too since servers which only speak
+// NAT-PMP answer PCP requests with an unsupported version error.
+func pcpResponse(resp []byte, op byte) bool {
+	return len(resp) >= 4 && (resp[0] == pcpVersion ||
+		resp[0] == natPMPVersion) && resp[1] == natPMPResponseBit|op
+}
+
+// pcpResultError returns the error for the result code of the passed response.
+// NAT-PMP responses are always errors.
+func pcpResultError(resp []byte) error {
+	if resp[0] == natPMPVersion {
+		return &natPMPResultError{
+			protocol: "PCP",
+			code:     int(binary.BigEndian.Uint16(resp[2:4])),
+		}
+	}
+	if resp[3] != 0 {
+		return &natPMPResultError{protocol: "PCP", code: int(resp[3])}
+	}
+	return nil
+}
+
+// announce sends a PCP ANNOUNCE request, which is used to find out whether the
+// gateway speaks PCP.
+func (n *pcpNAT) announce() error {
+	resp, err := gatewayExchange(n.gateway, func(localIP net.IP) []byte {
+		return pcpHeader(pcpOpAnnounce, 0, localIP)
+	}, func(resp []byte) bool {
+		return pcpResponse(resp, pcpOpAnnounce)
+	})
+	if err != nil {
+		return err
+	}
+	return pcpResultError(resp)
+}
+
+// pcpProtocol returns the IANA protocol number of the passed protocol.
+func pcpProtocol(protocol string) (byte, error) {
+	switch strings.ToLower(protocol) {
+	case "tcp":
+		return pcpProtocolTCP, nil
+	case "udp":
+		return pcpProtocolUDP, nil
+	}
+	return 0, fmt.Errorf("unsupported protocol %s", protocol)
+}
+
+// mapPort sends a PCP MAP request for the passed ports with the given lifetime
+// in seconds and returns the response.
+func (n *pcpNAT) mapPort(protocol string, externalPort, internalPort,
+	lifetime int) ([]byte, error) {
+
+	proto, err := pcpProtocol(protocol)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := gatewayExchange(n.gateway, func(localIP net.IP) []byte {
+		req := pcpHeader(pcpOpMap, uint32(lifetime), localIP)
+		payload := make([]byte, pcpMapLen)
+		copy(payload[0:12], n.nonce[:])
+		payload[12] = proto
+		binary.BigEndian.PutUint16(payload[16:18], uint16(internalPort))
+		binary.BigEndian.PutUint16(payload[18:20], uint16(externalPort))
+
+		// No external address is suggested, which is expressed by
+		// the IPv4-mapped unspecified address.
+		copy(payload[20:36], net.IPv4zero.To16())
+		return append(req, payload...)
+	}, func(resp []byte) bool {
+		if !pcpResponse(resp, pcpOpMap) {
+			return false
+		}
+		// Responses to other clients' requests are ignored.
+		return resp[0] != pcpVersion || (len(resp) >= pcpHeaderLen+pcpMapLen &&
+			string(resp[pcpHeaderLen:pcpHeaderLen+12]) == string(n.nonce[:]))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := pcpResultError(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetExternalAddress implements the NAT interface.  PCP reports the external
+// address along with each mapping, so the address assigned to the last
+// mapping is returned.
+func (n *pcpNAT) GetExternalAddress() (net.IP, error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	if n.externalIP == nil {
+		return nil, errors.New("no PCP mapping has been made")
+	}
+	return n.externalIP, nil
+}
+
+// AddPortMapping implements the NAT interface by requesting a mapping from the
+// gateway.  The gateway may assign a different external port than requested.
+func (n *pcpNAT) AddPortMapping(protocol string, externalPort, internalPort int,
+	description string, timeout int) (int, error) {
+
+	resp, err := n.mapPort(protocol, externalPort, internalPort, timeout)
+	if err != nil {
+		return 0, err
+	}
+	payload := resp[pcpHeaderLen:]
+	n.mtx.Lock()
+	n.externalIP = net.IP(append([]byte(nil), payload[20:36]...))
+	n.lease = time.Duration(binary.BigEndian.Uint32(resp[4:8])) * time.Second
+	n.mtx.Unlock()
+	return int(binary.BigEndian.Uint16(payload[18:20])), nil
+}
+
+// DeletePortMapping implements the NAT interface by requesting a mapping with
+// a lifetime of zero, which removes it.
+func (n *pcpNAT) DeletePortMapping(protocol string, externalPort, internalPort int) error {
+	_, err := n.mapPort(protocol, 0, internalPort, 0)
+	return err
+}
+
+// leaseDuration returns the lifetime granted to the last port mapping.
+func (n *pcpNAT) leaseDuration() time.Duration {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return n.lease
+}
+
+// discoverNATPMPGateway returns a NAT for the gateway at the passed address
+// using PCP when the gateway supports it and NAT-PMP otherwise.
+func discoverNATPMPGateway(gateway *net.UDPAddr) (NAT, error) {
+	pcp, err := newPCPNAT(gateway)
+	if err != nil {
+		return nil, err
+	}
+	err = pcp.announce()
+	if err == nil {
+		return pcp, nil
+	}
+	srvrLog.Debugf("PCP is not supported by gateway %v: %v", gateway, err)
+
+	pmp := &natPMPNAT{gateway: gateway}
+	if _, err := pmp.GetExternalAddress(); err != nil {
+		if _, ok := err.(*natPMPResultError); !ok {
+			err = errNoNATPMPGateway
+		}
+		return nil, err
+	}
+	return pmp, nil
+}
+
+// parseRouteTable returns the gateway of the default IPv4 route in the passed
+// routing table, which must be in the format of /proc/net/route on Linux.
+func parseRouteTable(r io.Reader) (net.IP, error) {
+	const rtfGateway = 0x2
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[1] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 16)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		gw, err := hex.DecodeString(fields[2])
+		if err != nil || len(gw) != 4 {
+			continue
+		}
+
+		// The address is in host byte order, which is little endian
+		// on all platforms Linux exposes the table on in this format.
+		return net.IPv4(gw[3], gw[2], gw[1], gw[0]), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no default route")
+}
+
+// defaultGateway returns the address of the default IPv4 gateway.  The Linux
+// routing table is used when available.  Otherwise, the gateway is assumed to
+// be the first address of the /24 network of the local address used to reach
+// the internet, which is the case for most home routers.
+func defaultGateway() (net.IP, error) {
+	if f, err := os.Open("/proc/net/route"); err == nil {
+		defer f.Close()
+		return parseRouteTable(f)
+	}
+
+	// Connecting a UDP socket doesn't send anything, but selects the
+	// local address.
+	conn, err := net.Dial("udp4", "192.0.2.1:9")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	ip := conn.LocalAddr().(*net.UDPAddr).IP.To4()
+	if ip == nil {
+		return nil, errors.New("no local IPv4 address")
+	}
+	return net.IPv4(ip[0], ip[1], ip[2], 1), nil
+}
+
+// DiscoverNATPMP searches for a PCP or NAT-PMP capable default gateway
+// returning a NAT for the network if so, nil if not.
+func DiscoverNATPMP() (NAT, error) {
+	gw, err := defaultGateway()
+	if err != nil {
+		return nil, err
+	}
+	return discoverNATPMPGateway(&net.UDPAddr{IP: gw, Port: natPMPPort})
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockGateway is a NAT-PMP gateway which optionally speaks PCP as well.
+type mockGateway struct {
+	conn net.PacketConn
+
+	// pcp is whether the gateway speaks PCP, mapResult is the result code
+	// of mapping requests, and mappedPort and lifetime are the external
+	// port and maximum lifetime of mappings.
+	pcp        bool
+	mapResult  byte
+	externalIP net.IP
+	mappedPort uint16
+	lifetime   uint32
+
+	mtx      sync.Mutex
+	requests [][]byte
+}
+
+// newMockGateway starts a mock gateway listening on the loopback address which
+// answers mapping requests with the passed result code.
+func newMockGateway(t *testing.T, pcp bool, mapResult byte) *mockGateway {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	g := &mockGateway{
+		conn:       conn,
+		pcp:        pcp,
+		mapResult:  mapResult,
+		externalIP: net.IPv4(203, 0, 113, 7).To4(),
+		mappedPort: 40123,
+		lifetime:   600,
+	}
+	go g.serve()
+	return g
+}
+
+func (g *mockGateway) addr() *net.UDPAddr {
+	return g.conn.LocalAddr().(*net.UDPAddr)
+}
+
+func (g *mockGateway) Close() {
+	g.conn.Close()
+}
+
+// requestsWithVersion returns the requests received with the passed protocol
+// version.
+func (g *mockGateway) requestsWithVersion(version byte) [][]byte {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	var reqs [][]byte
+	for _, req := range g.requests {
+		if req[0] == version {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+func (g *mockGateway) serve() {
+	buf := make([]byte, 1100)
+	for {
+		n, addr, err := g.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		req := append([]byte(nil), buf[:n]...)
+		g.mtx.Lock()
+		g.requests = append(g.requests, req)
+		g.mtx.Unlock()
+
+		var resp []byte
+		switch {
+		case req[0] == pcpVersion && g.pcp:
+			resp = g.pcpResponse(req, addr.(*net.UDPAddr).IP)
+		case req[0] == pcpVersion:
+			resp = []byte{natPMPVersion, natPMPResponseBit | req[1],
+				0, 1, 0, 0, 0, 1}
+		default:
+			resp = g.natPMPResponse(req)
+		}
+		g.conn.WriteTo(resp, addr)
+	}
+}
+
+func (g *mockGateway) natPMPResponse(req []byte) []byte {
+	resp := make([]byte, 8, 16)
+	resp[1] = natPMPResponseBit | req[1]
+	binary.BigEndian.PutUint32(resp[4:8], 1)
+	if req[1] == natPMPOpExternalAddress {
+		return append(resp, g.externalIP...)
+	}
+
+	resp[3] = g.mapResult
+	lifetime := binary.BigEndian.Uint32(req[8:12])
+	if lifetime > g.lifetime {
+		lifetime = g.lifetime
+	}
+	var fields [8]byte
+	copy(fields[0:2], req[4:6])
+	binary.BigEndian.PutUint16(fields[2:4], g.mappedPort)
+	binary.BigEndian.PutUint32(fields[4:8], lifetime)
+	return append(resp, fields[:]...)
+}
+
+func (g *mockGateway) pcpResponse(req []byte, source net.IP) []byte {
+	resp := make([]byte, pcpHeaderLen)
+	resp[0] = pcpVersion
+	resp[1] = natPMPResponseBit | req[1]
+	binary.BigEndian.PutUint32(resp[8:12], 1)
+	if req[1] == pcpOpAnnounce {
+		return resp
+	}
+
+	resp[3] = g.mapResult
+	if !net.IP(req[8:24]).Equal(source) {
+		// ADDRESS_MISMATCH
+		resp[3] = 12
+	}
+	lifetime := binary.BigEndian.Uint32(req[4:8])
+	if lifetime > g.lifetime {
+		lifetime = g.lifetime
+	}
+	binary.BigEndian.PutUint32(resp[4:8], lifetime)
+	payload := append([]byte(nil), req[pcpHeaderLen:]...)
+	binary.BigEndian.PutUint16(payload[18:20], g.mappedPort)
+	copy(payload[20:36], g.externalIP.To16())
+	return append(resp, payload...)
+}
+
+// TestNATPMPDiscoverPCP ensures PCP is used when the gateway supports it and
+// that ports are mapped and unmapped with it.
+func TestNATPMPDiscoverPCP(t *testing.T) {
+	g := newMockGateway(t, true, 0)
+	defer g.Close()
+
+	nat, err := discoverNATPMPGateway(g.addr())
+	if err != nil {
+		t.Fatalf("unable to discover gateway: %v", err)
+	}
+	pcp, ok := nat.(*pcpNAT)
+	if !ok {
+		t.Fatalf("got %T, want PCP", nat)
+	}
+	if _, err := nat.GetExternalAddress(); err == nil {
+		t.Fatal("got external address before mapping a port")
+	}
+
+	port, err := nat.AddPortMapping("tcp", 9333, 9333, "eacd", 1200)
+	if err != nil {
+		t.Fatalf("unable to map port: %v", err)
+	}
+	if port != int(g.mappedPort) {
+		t.Fatalf("got mapped port %d, want %d", port, g.mappedPort)
+	}
+	ip, err := nat.GetExternalAddress()
+	if err != nil || !ip.Equal(g.externalIP) {
+		t.Fatalf("got external address %v (%v), want %v", ip, err,
+			g.externalIP)
+	}
+	if lease := pcp.leaseDuration(); lease != 10*time.Minute {
+		t.Fatalf("got lease %v, want %v", lease, 10*time.Minute)
+	}
+
+	if err := nat.DeletePortMapping("tcp", 9333, 9333); err != nil {
+		t.Fatalf("unable to delete port mapping: %v", err)
+	}
+	reqs := g.requestsWithVersion(pcpVersion)
+	if len(reqs) != 3 {
+		t.Fatalf("got %d PCP requests, want 3", len(reqs))
+	}
+	add, del := reqs[1], reqs[2]
+	if string(add[24:36]) != string(del[24:36]) {
+		t.Fatal("mapping was deleted with a different nonce")
+	}
+	if add[36] != pcpProtocolTCP {
+		t.Fatalf("got protocol %d, want %d", add[36], pcpProtocolTCP)
+	}
+	if lifetime := binary.BigEndian.Uint32(del[4:8]); lifetime != 0 {
+		t.Fatalf("got delete lifetime %d, want 0", lifetime)
+	}
+	if len(g.requestsWithVersion(natPMPVersion)) != 0 {
+		t.Fatal("NAT-PMP was used with a PCP gateway")
+	}
+}
+
+// TestNATPMPDiscoverNATPMP ensures NAT-PMP is used when the gateway doesn't
+// support PCP and that ports are mapped and unmapped with it.
+func TestNATPMPDiscoverNATPMP(t *testing.T) {
+	g := newMockGateway(t, false, 0)
+	defer g.Close()
+
+	nat, err := discoverNATPMPGateway(g.addr())
+	if err != nil {
+		t.Fatalf("unable to discover gateway: %v", err)
+	}
+	pmp, ok := nat.(*natPMPNAT)
+	if !ok {
+		t.Fatalf("got %T, want NAT-PMP", nat)
+	}
+
+	ip, err := nat.GetExternalAddress()
+	if err != nil || !ip.Equal(g.externalIP) {
+		t.Fatalf("got external address %v (%v), want %v", ip, err,
+			g.externalIP)
+	}
+	port, err := nat.AddPortMapping("TCP", 9333, 9333, "eacd", 1200)
+	if err != nil {
+		t.Fatalf("unable to map port: %v", err)
+	}
+	if port != int(g.mappedPort) {
+		t.Fatalf("got mapped port %d, want %d", port, g.mappedPort)
+	}
+	if lease := pmp.leaseDuration(); lease != 10*time.Minute {
+		t.Fatalf("got lease %v, want %v", lease, 10*time.Minute)
+	}
+	if err := nat.DeletePortMapping("tcp", 9333, 9333); err != nil {
+		t.Fatalf("unable to delete port mapping: %v", err)
+	}
+
+	reqs := g.requestsWithVersion(natPMPVersion)
+	del := reqs[len(reqs)-1]
+	if del[1] != natPMPOpMapTCP ||
+		binary.BigEndian.Uint16(del[4:6]) != 9333 ||
+		binary.BigEndian.Uint16(del[6:8]) != 0 ||
+		binary.BigEndian.Uint32(del[8:12]) != 0 {
+
+		t.Fatalf("unexpected delete request %x", del)
+	}
+}
+
+// TestNATPMPMappingRefused ensures result codes of refused mappings are
+// returned.
+func TestNATPMPMappingRefused(t *testing.T) {
+	for _, pcp := range []bool{true, false} {
+		g := newMockGateway(t, pcp, 2)
+		nat, err := discoverNATPMPGateway(g.addr())
+		if err != nil {
+			t.Fatalf("unable to discover gateway: %v", err)
+		}
+		_, err = nat.AddPortMapping("tcp", 9333, 9333, "eacd", 1200)
+		resultErr, ok := err.(*natPMPResultError)
+		if !ok || resultErr.code != 2 {
+			t.Errorf("got error %v with PCP %v, want result code 2",
+				err, pcp)
+		}
+		g.Close()
+	}
+}
+
+// TestNATPMPNoGateway ensures discovery fails when nothing answers.
+func TestNATPMPNoGateway(t *testing.T) {
+	defer func(timeout time.Duration) {
+		natPMPTimeout = timeout
+	}(natPMPTimeout)
+	natPMPTimeout = 10 * time.Millisecond
+
+	// Keep the socket open without answering so requests are neither
+	// answered nor refused.
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = discoverNATPMPGateway(conn.LocalAddr().(*net.UDPAddr))
+	if err != errNoNATPMPGateway {
+		t.Fatalf("got error %v, want %v", err, errNoNATPMPGateway)
+	}
+}
+
+// TestParseRouteTable ensures the default gateway is found in the Linux
+// routing table.
+func TestParseRouteTable(t *testing.T) {
+	table := "Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\n" +
+		"eth0\t0000A8C0\t00000000\t0001\t0\t0\t0\t00FFFFFF\n" +
+		"eth0\t00000000\t0100A8C0\t0003\t0\t0\t100\t00000000\n"
+	gw, err := parseRouteTable(strings.NewReader(table))
+	if err != nil {
+		t.Fatalf("unable to parse routing table: %v", err)
+	}
+	if want := net.IPv4(192, 168, 0, 1); !gw.Equal(want) {
+		t.Fatalf("got gateway %v, want %v", gw, want)
+	}
+
+	table = "Iface\tDestination\tGateway \tFlags\n" +
+		"eth0\t0000A8C0\t00000000\t0001\n"
+	if _, err := parseRouteTable(strings.NewReader(table)); err == nil {
+		t.Fatal("found gateway in table without default route")
+	}
+}
@@ -198,6 +198,11 @@ type SyncManager struct {
 	peerStates       map[*peerpkg.Peer]*peerSyncState
 	lastProgressTime time.Time
 
+	// reorganized is set when blocks are disconnected from the main chain
+	// while processing a block, so the mempool is revalidated once the
+	// reorganization is complete.
+	reorganized bool
+
 	// The following fields are used for headers-first mode.
 	headersFirstMode bool
 	headerList       *list.List
@@ -708,6 +713,7 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
 	_, isOrphan, err := sm.chain.ProcessBlock(bmsg.block, behaviorFlags)
+	sm.revalidateMempool()
 	if err != nil {
 		// When the error is a rule error, it means the block was simply
 		// rejected as opposed to something actually going wrong, so log
@@ -936,40 +942,38 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		return
 	}
 
+	// Ensure there is a previous header to compare against.
+	prevNodeEl := sm.headerList.Back()
+	if prevNodeEl == nil {
+		log.Warnf("Header list does not contain a previous" +
+			"element as expected -- disconnecting peer")
+		peer.Disconnect()
+		return
+	}
+
 	// Process all of the received headers ensuring each one connects to the
-	// previous and that checkpoints match.
+	// previous and that checkpoints match.  These checks are cheap, so they
+	// are done before the expensive proof of work checks below to avoid
+	// hashing headers which would be rejected anyway.
 	receivedCheckpoint := false
 	var finalHash *chainhash.Hash
+	prevNode := prevNodeEl.Value.(*headerNode)
+	nodes := make([]*headerNode, 0, numHeaders)
 	for _, blockHeader := range msg.Headers {
 		blockHash := blockHeader.BlockHash()
 		finalHash = &blockHash
 
-		// Ensure there is a previous header to compare against.
-		prevNodeEl := sm.headerList.Back()
-		if prevNodeEl == nil {
-			log.Warnf("Header list does not contain a previous" +
-				"element as expected -- disconnecting peer")
-			peer.Disconnect()
-			return
-		}
-
-		// Ensure the header properly connects to the previous one and
-		// add it to the list of headers.
-		node := headerNode{hash: &blockHash}
-		prevNode := prevNodeEl.Value.(*headerNode)
-		if prevNode.hash.IsEqual(&blockHeader.PrevBlock) {
-			node.height = prevNode.height + 1
-			e := sm.headerList.PushBack(&node)
-			if sm.startHeader == nil {
-				sm.startHeader = e
-			}
-		} else {
+		// Ensure the header properly connects to the previous one.
+		if !prevNode.hash.IsEqual(&blockHeader.PrevBlock) {
 			log.Warnf("Received block header that does not "+
 				"properly connect to the chain from peer %s "+
 				"-- disconnecting", peer.Addr())
 			peer.Disconnect()
 			return
 		}
+		node := &headerNode{hash: &blockHash, height: prevNode.height + 1}
+		nodes = append(nodes, node)
+		prevNode = node
 
 		// Verify the header at the next checkpoint height matches.
 		if node.height == sm.nextCheckpoint.Height {
@@ -992,6 +996,25 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		}
 	}
 
+	// Verify the proof of work of the headers which passed the checks
+	// above concurrently.  The results are remembered by the chain, so
+	// the headers are not hashed again once their blocks arrive.
+	err := sm.chain.VerifyProofOfWork(msg.Headers[:len(nodes)])
+	if err != nil {
+		log.Warnf("Received block header with invalid proof of work "+
+			"from peer %s -- disconnecting: %v", peer.Addr(), err)
+		peer.Disconnect()
+		return
+	}
+
+	// Add the headers to the list of headers.
+	for _, node := range nodes {
+		e := sm.headerList.PushBack(node)
+		if sm.startHeader == nil {
+			sm.startHeader = e
+		}
+	}
+
 	// When this header is a checkpoint, switch to fetching the blocks for
 	// all of the headers since the last checkpoint.
 	if receivedCheckpoint {
@@ -1011,7 +1034,7 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 	// headers starting from the latest known header and ending with the
 	// next checkpoint.
 	locator := blockchain.BlockLocator([]*chainhash.Hash{finalHash})
-	err := peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
+	err = peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
 	if err != nil {
 		log.Warnf("Failed to send getheaders message to "+
 			"peer %s: %v", peer.Addr(), err)
@@ -1342,6 +1365,7 @@ out:
 			case processBlockMsg:
 				_, isOrphan, err := sm.chain.ProcessBlock(
 					msg.block, msg.flags)
+				sm.revalidateMempool()
 				if err != nil {
 					msg.reply <- processBlockResponse{
 						isOrphan: false,
@@ -1418,7 +1442,8 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 		// transaction are NOT removed recursively because they are still
 		// valid.
 		for _, tx := range block.Transactions()[1:] {
-			sm.txMemPool.RemoveTransaction(tx, false)
+			sm.txMemPool.RemoveTransaction(tx, false,
+				mempool.RemovalReasonBlock)
 			sm.txMemPool.RemoveDoubleSpends(tx)
 			sm.txMemPool.RemoveOrphan(tx)
 			sm.peerNotifier.TransactionConfirmed(tx)
@@ -1448,6 +1473,10 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 			break
 		}
 
+		// The transactions in the pool are revalidated once the
+		// reorganization is complete.
+		sm.reorganized = true
+
 		// Reinsert all of the transactions (except the coinbase) into
 		// the transaction pool.
 		for _, tx := range block.Transactions()[1:] {
@@ -1457,7 +1486,8 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 				// Remove the transaction and all transactions
 				// that depend on it if it wasn't accepted into
 				// the transaction pool.
-				sm.txMemPool.RemoveTransaction(tx, true)
+				sm.txMemPool.RemoveTransaction(tx, true,
+					mempool.RemovalReasonReorg)
 			}
 		}
 
@@ -1468,6 +1498,21 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 	}
 }
 
+// revalidateMempool removes the transactions which are no longer valid for the
+// next block from the mempool after the main chain was reorganized.  It must
+// be called after processing a block.
+func (sm *SyncManager) revalidateMempool() {
+	if !sm.reorganized {
+		return
+	}
+	sm.reorganized = false
+
+	if n := sm.txMemPool.Revalidate(); n > 0 {
+		log.Infof("Removed %d invalidated transactions from the "+
+			"mempool after a chain reorganization", n)
+	}
+}
+
 // NewPeer informs the sync manager of a newly active peer.
 func (sm *SyncManager) NewPeer(peer *peerpkg.Peer) {
 	// Ignore if we are shutting down.
@@ -1500,6 +1545,11 @@ func (sm *SyncManager) QueueBlock(block *eacutil.Block, peer *peerpkg.Peer, done
 		return
 	}
 
+	// Start checking the proof of work of the block in the background so
+	// the blocks queued by different peers are hashed concurrently rather
+	// than one by one when they are processed.
+	sm.chain.QueueProofOfWork([]*wire.BlockHeader{&block.MsgBlock().Header})
+
 	sm.msgChan <- &blockMsg{block: block, peer: peer, reply: done}
 }
 
@@ -0,0 +1,171 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eacsuite/eacd/addrmgr"
+	"github.com/eacsuite/eacd/connmgr"
+)
+
+const (
+	// onionKeyFilename is the name of the file in the data directory used
+	// to persist the private key of the onion service so the same onion
+	// address is used across restarts.
+	onionKeyFilename = "onion_private_key"
+
+	// onionRetryInterval is the time to wait before reconnecting to the Tor
+	// control port after failing to create the onion service or losing the
+	// connection.
+	onionRetryInterval = time.Minute
+)
+
+// onionKeyTypes are the key types new onion services are requested with, in
+// order of preference.  Only version 2 onion addresses can be advertised to
+// peers since the addresses are encoded as OnionCat IPv6 addresses on the wire,
+// so a version 3 service is only created when Tor refuses version 2 services.
+var onionKeyTypes = []string{"NEW:RSA1024", "NEW:ED25519-V3"}
+
+// onionTarget returns the address the onion service should forward inbound
+// connections to.  IPv4 listeners are preferred and unspecified addresses are
+// replaced by the loopback address.  An empty string is returned when no onion
+// service should be created.
+func onionTarget(listeners []net.Listener) string {
+	if !cfg.ListenOnion {
+		return ""
+	}
+	var target *net.TCPAddr
+	for _, listener := range listeners {
+		addr, ok := listener.Addr().(*net.TCPAddr)
+		if !ok {
+			continue
+		}
+		if target == nil || (target.IP.To4() == nil && addr.IP.To4() != nil) {
+			target = addr
+		}
+	}
+	if target == nil {
+		return ""
+	}
+
+	ip := target.IP
+	if ip.IsUnspecified() {
+		ip = net.IPv4(127, 0, 0, 1)
+		if target.IP.To4() == nil {
+			ip = net.IPv6loopback
+		}
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(target.Port))
+}
+
+// publishOnionService connects to the Tor control port, creates the onion
+// service and advertises its address.  The returned controller must be kept
+// open for as long as the service should remain available.
+func (s *server) publishOnionService(keyPath string) (*connmgr.TorController, error) {
+	ctrl, err := connmgr.DialTorController(cfg.TorControl)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctrl.Authenticate(cfg.TorPassword); err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+
+	virtPort, err := strconv.Atoi(activeNetParams.DefaultPort)
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+
+	// Reuse the persisted key when there is one and fall back to having
+	// Tor generate a new one.
+	keyTypes := onionKeyTypes
+	if key, err := ioutil.ReadFile(keyPath); err == nil {
+		keyTypes = []string{strings.TrimSpace(string(key))}
+	} else if !os.IsNotExist(err) {
+		srvrLog.Warnf("Unable to read onion service key %s: %v", keyPath,
+			err)
+	}
+	var serviceID, privateKey string
+	for _, keyType := range keyTypes {
+		serviceID, privateKey, err = ctrl.AddOnion(keyType, virtPort,
+			s.onionTarget)
+		if _, ok := err.(*connmgr.TorControlError); !ok {
+			break
+		}
+		srvrLog.Debugf("Tor refused to create onion service with key "+
+			"%s: %v", strings.SplitN(keyType, ":", 2)[0], err)
+	}
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	err = ioutil.WriteFile(keyPath, []byte(privateKey), 0600)
+	if err != nil {
+		srvrLog.Warnf("Unable to save onion service key %s: %v", keyPath,
+			err)
+	}
+
+	host := serviceID + ".onion"
+	srvrLog.Infof("Onion service %s forwarding to %s", net.JoinHostPort(host,
+		activeNetParams.DefaultPort), s.onionTarget)
+	na, err := s.addrManager.HostToNetAddress(host, uint16(virtPort),
+		s.services)
+	if err != nil {
+		srvrLog.Warnf("Onion address %s can not be advertised to peers: "+
+			"%v", host, err)
+		return ctrl, nil
+	}
+	if err := s.addrManager.AddLocalAddress(na, addrmgr.ManualPrio); err != nil {
+		srvrLog.Warnf("Skipping onion address %s: %v", host, err)
+	}
+	return ctrl, nil
+}
+
+// onionServiceThread keeps an onion service forwarding to the local listener
+// published through the Tor control port for as long as the server runs.  It
+// must be run as a goroutine.
+func (s *server) onionServiceThread() {
+	defer s.wg.Done()
+
+	keyPath := filepath.Join(cfg.DataDir, onionKeyFilename)
+	for {
+		ctrl, err := s.publishOnionService(keyPath)
+		if err != nil {
+			srvrLog.Warnf("Unable to create onion service through Tor "+
+				"control port %s: %v", cfg.TorControl, err)
+		} else {
+			// The onion service is removed by Tor once the control
+			// connection is closed, so it is kept open until
+			// shutdown.
+			closed := make(chan error, 1)
+			go func() {
+				closed <- ctrl.WaitClose()
+			}()
+			select {
+			case err := <-closed:
+				srvrLog.Warnf("Lost connection to Tor control port "+
+					"%s: %v", cfg.TorControl, err)
+			case <-s.quit:
+				ctrl.Close()
+				<-closed
+				return
+			}
+		}
+
+		select {
+		case <-time.After(onionRetryInterval):
+		case <-s.quit:
+			return
+		}
+	}
+}
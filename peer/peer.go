@@ -290,7 +290,9 @@ func minUint32(a, b uint32) uint32 {
 // newNetAddress attempts to extract the IP address and port from the passed
 // net.Addr interface and create a bitcoin NetAddress structure using that
 // information.
-func newNetAddress(addr net.Addr, services wire.ServiceFlag) (*wire.NetAddress, error) {
+func newNetAddress(addr net.Addr, services wire.ServiceFlag,
+	hostToNetAddr HostToNetAddrFunc) (*wire.NetAddress, error) {
+
 	// addr will be a net.TCPAddr when not using a proxy.
 	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
 		ip := tcpAddr.IP
@@ -312,16 +314,21 @@ func newNetAddress(addr net.Addr, services wire.ServiceFlag) (*wire.NetAddress,
 
 	// For the most part, addr should be one of the two above cases, but
 	// to be safe, fall back to trying to parse the information from the
-	// address string as a last resort.
+	// address string as a last resort.  Hosts that are not IP addresses,
+	// such as I2P addresses, are converted by the passed function when
+	// there is one.
 	host, portStr, err := net.SplitHostPort(addr.String())
 	if err != nil {
 		return nil, err
 	}
-	ip := net.ParseIP(host)
 	port, err := strconv.ParseUint(portStr, 10, 16)
 	if err != nil {
 		return nil, err
 	}
+	ip := net.ParseIP(host)
+	if ip == nil && hostToNetAddr != nil {
+		return hostToNetAddr(host, uint16(port), services)
+	}
 	na := wire.NewNetAddressIPPort(ip, uint16(port), services)
 	return na, nil
 }
@@ -2172,7 +2179,8 @@ func (p *Peer) AssociateConnection(conn net.Conn) {
 		// Set up a NetAddress for the peer to be used with AddrManager.  We
 		// only do this inbound because outbound set this up at connection time
 		// and no point recomputing.
-		na, err := newNetAddress(p.conn.RemoteAddr(), p.services)
+		na, err := newNetAddress(p.conn.RemoteAddr(), p.services,
+			p.cfg.HostToNetAddress)
 		if err != nil {
 			log.Errorf("Cannot create remote net address: %v", err)
 			p.Disconnect()
@@ -686,6 +686,140 @@ func (c *Client) GetMempoolEntry(txHash string) (*btcjson.GetMempoolEntryResult,
 	return c.GetMempoolEntryAsync(txHash).Receive()
 }
 
+// FutureGetMempoolAncestorsResult is a future promise to deliver the result of
+// a GetMempoolAncestorsAsync RPC invocation (or an applicable error).
+type FutureGetMempoolAncestorsResult chan *response
+
+// Receive waits for the response promised by the future and returns the hashes
+// of all unconfirmed ancestors of the transaction in the memory pool.
+func (r FutureGetMempoolAncestorsResult) Receive() ([]*chainhash.Hash, error) {
+	return FutureGetRawMempoolResult(r).Receive()
+}
+
+// GetMempoolAncestorsAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetMempoolAncestors for the blocking version and more details.
+func (c *Client) GetMempoolAncestorsAsync(txHash string) FutureGetMempoolAncestorsResult {
+	cmd := btcjson.NewGetMempoolAncestorsCmd(txHash, btcjson.Bool(false))
+	return c.sendCmd(cmd)
+}
+
+// GetMempoolAncestors returns the hashes of all unconfirmed ancestors of the
+// transaction in the memory pool given its hash.
+//
+// See GetMempoolAncestorsVerbose to retrieve data structures with information
+// about the ancestors instead.
+func (c *Client) GetMempoolAncestors(txHash string) ([]*chainhash.Hash, error) {
+	return c.GetMempoolAncestorsAsync(txHash).Receive()
+}
+
+// FutureGetMempoolAncestorsVerboseResult is a future promise to deliver the
+// result of a GetMempoolAncestorsVerboseAsync RPC invocation (or an applicable
+// error).
+type FutureGetMempoolAncestorsVerboseResult chan *response
+
+// Receive waits for the response promised by the future and returns a map of
+// transaction hashes to an associated data structure with information about the
+// transaction for all unconfirmed ancestors of the transaction in the memory
+// pool.
+func (r FutureGetMempoolAncestorsVerboseResult) Receive() (map[string]btcjson.GetMempoolEntryResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a map of strings (tx shas) to their detailed
+	// results.
+	var mempoolItems map[string]btcjson.GetMempoolEntryResult
+	err = json.Unmarshal(res, &mempoolItems)
+	if err != nil {
+		return nil, err
+	}
+	return mempoolItems, nil
+}
+
+// GetMempoolAncestorsVerboseAsync returns an instance of a type that can be
+// used to get the result of the RPC at some future time by invoking the
+// Receive function on the returned instance.
+//
+// See GetMempoolAncestorsVerbose for the blocking version and more details.
+func (c *Client) GetMempoolAncestorsVerboseAsync(txHash string) FutureGetMempoolAncestorsVerboseResult {
+	cmd := btcjson.NewGetMempoolAncestorsCmd(txHash, btcjson.Bool(true))
+	return c.sendCmd(cmd)
+}
+
+// GetMempoolAncestorsVerbose returns a map of transaction hashes to an
+// associated data structure with information about the transaction for all
+// unconfirmed ancestors of the transaction in the memory pool given its hash.
+//
+// See GetMempoolAncestors to retrieve only the transaction hashes instead.
+func (c *Client) GetMempoolAncestorsVerbose(txHash string) (map[string]btcjson.GetMempoolEntryResult, error) {
+	return c.GetMempoolAncestorsVerboseAsync(txHash).Receive()
+}
+
+// FutureGetMempoolDescendantsResult is a future promise to deliver the result
+// of a GetMempoolDescendantsAsync RPC invocation (or an applicable error).
+type FutureGetMempoolDescendantsResult chan *response
+
+// Receive waits for the response promised by the future and returns the hashes
+// of all unconfirmed descendants of the transaction in the memory pool.
+func (r FutureGetMempoolDescendantsResult) Receive() ([]*chainhash.Hash, error) {
+	return FutureGetRawMempoolResult(r).Receive()
+}
+
+// GetMempoolDescendantsAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetMempoolDescendants for the blocking version and more details.
+func (c *Client) GetMempoolDescendantsAsync(txHash string) FutureGetMempoolDescendantsResult {
+	cmd := btcjson.NewGetMempoolDescendantsCmd(txHash, btcjson.Bool(false))
+	return c.sendCmd(cmd)
+}
+
+// GetMempoolDescendants returns the hashes of all unconfirmed descendants of
+// the transaction in the memory pool given its hash.
+//
+// See GetMempoolDescendantsVerbose to retrieve data structures with
+// information about the descendants instead.
+func (c *Client) GetMempoolDescendants(txHash string) ([]*chainhash.Hash, error) {
+	return c.GetMempoolDescendantsAsync(txHash).Receive()
+}
+
+// FutureGetMempoolDescendantsVerboseResult is a future promise to deliver the
+// result of a GetMempoolDescendantsVerboseAsync RPC invocation (or an
+// applicable error).
+type FutureGetMempoolDescendantsVerboseResult chan *response
+
+// Receive waits for the response promised by the future and returns a map of
+// transaction hashes to an associated data structure with information about the
+// transaction for all unconfirmed descendants of the transaction in the memory
+// pool.
+func (r FutureGetMempoolDescendantsVerboseResult) Receive() (map[string]btcjson.GetMempoolEntryResult, error) {
+	return FutureGetMempoolAncestorsVerboseResult(r).Receive()
+}
+
+// GetMempoolDescendantsVerboseAsync returns an instance of a type that can be
+// used to get the result of the RPC at some future time by invoking the
+// Receive function on the returned instance.
+//
+// See GetMempoolDescendantsVerbose for the blocking version and more details.
+func (c *Client) GetMempoolDescendantsVerboseAsync(txHash string) FutureGetMempoolDescendantsVerboseResult {
+	cmd := btcjson.NewGetMempoolDescendantsCmd(txHash, btcjson.Bool(true))
+	return c.sendCmd(cmd)
+}
+
+// GetMempoolDescendantsVerbose returns a map of transaction hashes to an
+// associated data structure with information about the transaction for all
+// unconfirmed descendants of the transaction in the memory pool given its hash.
+//
+// See GetMempoolDescendants to retrieve only the transaction hashes instead.
+func (c *Client) GetMempoolDescendantsVerbose(txHash string) (map[string]btcjson.GetMempoolEntryResult, error) {
+	return c.GetMempoolDescendantsVerboseAsync(txHash).Receive()
+}
+
 // FutureGetRawMempoolResult is a future promise to deliver the result of a
 // GetRawMempoolAsync RPC invocation (or an applicable error).
 type FutureGetRawMempoolResult chan *response
@@ -850,6 +984,45 @@ func (c *Client) EstimateSmartFee(confTarget int64, mode *btcjson.EstimateSmartF
 	return c.EstimateSmartFeeAsync(confTarget, mode).Receive()
 }
 
+// FutureEstimateRawFeeResult is a future promise to deliver the result of a
+// EstimateRawFeeAsync RPC invocation (or an applicable error).
+type FutureEstimateRawFeeResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// estimates of every horizon tracking the requested target.
+func (r FutureEstimateRawFeeResult) Receive() (*btcjson.EstimateRawFeeResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcjson.EstimateRawFeeResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// EstimateRawFeeAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See EstimateRawFee for the blocking version and more details.
+func (c *Client) EstimateRawFeeAsync(confTarget int64, threshold *float64) FutureEstimateRawFeeResult {
+	cmd := btcjson.NewEstimateRawFeeCmd(confTarget, threshold)
+	return c.sendCmd(cmd)
+}
+
+// EstimateRawFee requests the server to estimate the fee rate required for a
+// transaction to be mined within the given number of blocks with each horizon
+// of its fee estimator.  The threshold is the fraction of transactions which
+// must have been mined within the number of blocks, which defaults to 0.95
+// when nil.
+func (c *Client) EstimateRawFee(confTarget int64, threshold *float64) (*btcjson.EstimateRawFeeResult, error) {
+	return c.EstimateRawFeeAsync(confTarget, threshold).Receive()
+}
+
 // FutureVerifyChainResult is a future promise to deliver the result of a
 // VerifyChainAsync, VerifyChainLevelAsyncRPC, or VerifyChainBlocksAsync
 // invocation (or an applicable error).
@@ -1075,6 +1248,65 @@ func (c *Client) InvalidateBlock(blockHash *chainhash.Hash) error {
 	return c.InvalidateBlockAsync(blockHash).Receive()
 }
 
+// FutureSaveMempoolResult is a future promise to deliver the result of a
+// SaveMempoolAsync RPC invocation (or an applicable error).
+type FutureSaveMempoolResult chan *response
+
+// Receive waits for the response promised by the future and returns an error
+// if the mempool could not be saved.
+func (r FutureSaveMempoolResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// SaveMempoolAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See SaveMempool for the blocking version and more details.
+func (c *Client) SaveMempoolAsync() FutureSaveMempoolResult {
+	cmd := btcjson.NewSaveMempoolCmd()
+	return c.sendCmd(cmd)
+}
+
+// SaveMempool makes the server write the transactions in its mempool to disk
+// so they are loaded when it starts again.
+func (c *Client) SaveMempool() error {
+	return c.SaveMempoolAsync().Receive()
+}
+
+// FuturePrioritiseTransactionResult is a future promise to deliver the result
+// of a PrioritiseTransactionAsync RPC invocation (or an applicable error).
+type FuturePrioritiseTransactionResult chan *response
+
+// Receive waits for the response promised by the future and returns an error
+// if the fee delta could not be applied.
+func (r FuturePrioritiseTransactionResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// PrioritiseTransactionAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See PrioritiseTransaction for the blocking version and more details.
+func (c *Client) PrioritiseTransactionAsync(txHash *chainhash.Hash, feeDelta int64) FuturePrioritiseTransactionResult {
+	hash := ""
+	if txHash != nil {
+		hash = txHash.String()
+	}
+
+	cmd := btcjson.NewPrioritiseTransactionCmd(hash, feeDelta)
+	return c.sendCmd(cmd)
+}
+
+// PrioritiseTransaction adds the passed fee delta in satoshi to the fee the
+// server uses when accepting and mining the transaction with the passed hash.
+func (c *Client) PrioritiseTransaction(txHash *chainhash.Hash, feeDelta int64) error {
+	return c.PrioritiseTransactionAsync(txHash, feeDelta).Receive()
+}
+
 // FutureGetCFilterResult is a future promise to deliver the result of a
 // GetCFilterAsync RPC invocation (or an applicable error).
 type FutureGetCFilterResult chan *response
@@ -1224,3 +1456,32 @@ func (c *Client) GetBlockStatsAsync(hashOrHeight interface{}, stats *[]string) F
 func (c *Client) GetBlockStats(hashOrHeight interface{}, stats *[]string) (*btcjson.GetBlockStatsResult, error) {
 	return c.GetBlockStatsAsync(hashOrHeight, stats).Receive()
 }
+
+// FutureSetMockTimeResult is a future promise to deliver the result of a
+// SetMockTimeAsync RPC invocation (or an applicable error).
+type FutureSetMockTimeResult chan *response
+
+// Receive waits for the response promised by the future and returns an error if
+// any occurred when setting the mock time.
+func (r FutureSetMockTimeResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// SetMockTimeAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See SetMockTime for the blocking version and more details.
+func (c *Client) SetMockTimeAsync(timestamp int64) FutureSetMockTimeResult {
+	cmd := btcjson.NewSetMockTimeCmd(timestamp)
+	return c.sendCmd(cmd)
+}
+
+// SetMockTime sets the time the server uses for its consensus and policy rules
+// to the passed number of seconds since the Unix epoch.  A timestamp of 0 makes
+// the server use its system time again.  It is only supported on the regression
+// test network.
+func (c *Client) SetMockTime(timestamp int64) error {
+	return c.SetMockTimeAsync(timestamp).Receive()
+}
@@ -305,6 +305,49 @@ func (c *Client) GetHeaders(blockLocators []chainhash.Hash, hashStop *chainhash.
 	return c.GetHeadersAsync(blockLocators, hashStop).Receive()
 }
 
+// FutureGetStratumInfoResult is a future promise to deliver the result of a
+// GetStratumInfoAsync RPC invocation (or an applicable error).
+type FutureGetStratumInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// statistics of the miners connected to the Stratum server.
+func (r FutureGetStratumInfoResult) Receive() (*btcjson.GetStratumInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a getstratuminfo result object.
+	var infoResult btcjson.GetStratumInfoResult
+	err = json.Unmarshal(res, &infoResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &infoResult, nil
+}
+
+// GetStratumInfoAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See GetStratumInfo for the blocking version and more details.
+//
+// NOTE: This is a eacd extension.
+func (c *Client) GetStratumInfoAsync() FutureGetStratumInfoResult {
+	cmd := btcjson.NewGetStratumInfoCmd()
+	return c.sendCmd(cmd)
+}
+
+// GetStratumInfo returns statistics about the miners connected to the
+// built-in Stratum server of the server, including the hash rate and shares
+// of every worker.
+//
+// NOTE: This is a eacd extension.
+func (c *Client) GetStratumInfo() (*btcjson.GetStratumInfoResult, error) {
+	return c.GetStratumInfoAsync().Receive()
+}
+
 // FutureExportWatchingWalletResult is a future promise to deliver the result of
 // an ExportWatchingWalletAsync RPC invocation (or an applicable error).
 type FutureExportWatchingWalletResult chan *response
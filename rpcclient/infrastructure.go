@@ -277,6 +277,9 @@ func (c *Client) trackRegisteredNtfns(cmd interface{}) {
 
 		}
 
+	case *btcjson.NotifyReplacementsCmd:
+		c.ntfnState.notifyReplacements = true
+
 	case *btcjson.NotifySpentCmd:
 		for _, op := range bcmd.OutPoints {
 			c.ntfnState.notifySpent[op] = struct{}{}
@@ -549,6 +552,14 @@ func (c *Client) reregisterNtfns() error {
 		}
 	}
 
+	// Reregister notifyreplacements if needed.
+	if stateCopy.notifyReplacements {
+		log.Debugf("Reregistering [notifyreplacements]")
+		if err := c.NotifyReplacements(); err != nil {
+			return err
+		}
+	}
+
 	// Reregister the combination of all previously registered notifyspent
 	// outpoints in one command if needed.
 	nslen := len(stateCopy.notifySpent)
@@ -99,7 +99,7 @@ func (f FutureGenerateToAddressResult) Receive() ([]*chainhash.Hash, error) {
 //
 // See GenerateToAddress for the blocking version and more details.
 func (c *Client) GenerateToAddressAsync(numBlocks int64, address eacutil.Address, maxTries *int64) FutureGenerateToAddressResult {
-	cmd := btcjson.NewGenerateToAddressCmd(numBlocks, address.EncodeAddress(), maxTries)
+	cmd := btcjson.NewGenerateToAddressCmd(numBlocks, address.EncodeAddress(), maxTries, nil, nil)
 	return c.sendCmd(cmd)
 }
 
@@ -108,6 +108,48 @@ func (c *Client) GenerateToAddress(numBlocks int64, address eacutil.Address, max
 	return c.GenerateToAddressAsync(numBlocks, address, maxTries).Receive()
 }
 
+// FutureGenerateBlockResult is a future promise to deliver the result of a
+// GenerateBlockAsync RPC invocation (or an applicable error).
+type FutureGenerateBlockResult chan *response
+
+// Receive waits for the response promised by the future and returns the hash of
+// the generated block.
+func (r FutureGenerateBlockResult) Receive() (*chainhash.Hash, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a generateblock result object.
+	var result btcjson.GenerateBlockResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return chainhash.NewHashFromStr(result.Hash)
+}
+
+// GenerateBlockAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GenerateBlock for the blocking version and more details.
+func (c *Client) GenerateBlockAsync(address eacutil.Address, transactions []string, timestamp *int64, version *int32) FutureGenerateBlockResult {
+	cmd := btcjson.NewGenerateBlockCmd(address.EncodeAddress(),
+		transactions, timestamp, version)
+	return c.sendCmd(cmd)
+}
+
+// GenerateBlock generates a block paying to the given address which includes
+// exactly the given transactions, each either a hex-encoded raw transaction or
+// the id of a transaction in the memory pool, and returns its hash.  The
+// timestamp and version of the block are chosen by the server unless given.
+func (c *Client) GenerateBlock(address eacutil.Address, transactions []string, timestamp *int64, version *int32) (*chainhash.Hash, error) {
+	return c.GenerateBlockAsync(address, transactions, timestamp,
+		version).Receive()
+}
+
 // FutureGetGenerateResult is a future promise to deliver the result of a
 // GetGenerateAsync RPC invocation (or an applicable error).
 type FutureGetGenerateResult chan *response
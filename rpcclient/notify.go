@@ -35,6 +35,7 @@ type notificationState struct {
 	notifyBlocks       bool
 	notifyNewTx        bool
 	notifyNewTxVerbose bool
+	notifyReplacements bool
 	notifyReceived     map[string]struct{}
 	notifySpent        map[btcjson.OutPoint]struct{}
 }
@@ -45,6 +46,7 @@ func (s *notificationState) Copy() *notificationState {
 	stateCopy.notifyBlocks = s.notifyBlocks
 	stateCopy.notifyNewTx = s.notifyNewTx
 	stateCopy.notifyNewTxVerbose = s.notifyNewTxVerbose
+	stateCopy.notifyReplacements = s.notifyReplacements
 	stateCopy.notifyReceived = make(map[string]struct{})
 	for addr := range s.notifyReceived {
 		stateCopy.notifyReceived[addr] = struct{}{}
@@ -151,6 +153,15 @@ type NotificationHandlers struct {
 	// github.com/decred/dcrrpcclient.
 	OnRelevantTxAccepted func(transaction []byte)
 
+	// OnRelevantDoubleSpend is invoked when a transaction which spends
+	// outputs already spent by transactions in the memory pool is rejected
+	// and it or the transactions it conflicts with pass the client's
+	// transaction filter.  It is called with the serialized double spend,
+	// the hashes of the transactions in the memory pool it conflicts with
+	// and the time it was first seen.
+	OnRelevantDoubleSpend func(transaction []byte,
+		conflicts []*chainhash.Hash, firstSeen time.Time)
+
 	// OnRescanFinished is invoked after a rescan finishes due to a previous
 	// call to Rescan or RescanEndHeight.  Finished rescans should be
 	// signaled on this notification, rather than relying on the return
@@ -179,6 +190,23 @@ type NotificationHandlers struct {
 	// made to register for the notification and the function is non-nil.
 	OnTxAcceptedVerbose func(txDetails *btcjson.TxRawResult)
 
+	// OnTxRemoved is invoked when a transaction is removed from the memory
+	// pool along with the reason it was removed, such as "block",
+	// "conflict", "replaced", "sizelimit", "expiry" or "reorg".  It will
+	// only be invoked if a preceding call to NotifyNewTransactions has
+	// been made to register for the notification and the function is
+	// non-nil.
+	OnTxRemoved func(hash *chainhash.Hash, reason string)
+
+	// OnTxReplaced is invoked when a transaction accepted into the memory
+	// pool replaced transactions in it.  It is called with the hash and
+	// fee rate of the replacement and of each replaced transaction.  It
+	// will only be invoked if a preceding call to NotifyReplacements has
+	// been made to register for the notification and the function is
+	// non-nil.
+	OnTxReplaced func(replacement *btcjson.TxFeeRate,
+		replaced []btcjson.TxFeeRate)
+
 	// OnBtcdConnected is invoked when a wallet connects or disconnects from
 	// eacd.
 	//
@@ -341,6 +369,25 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 
 		c.ntfnHandlers.OnRelevantTxAccepted(transaction)
 
+	// OnRelevantDoubleSpend
+	case btcjson.RelevantDoubleSpendNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnRelevantDoubleSpend == nil {
+			return
+		}
+
+		transaction, conflicts, firstSeen, err :=
+			parseRelevantDoubleSpendParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid relevantdoublespend "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnRelevantDoubleSpend(transaction, conflicts,
+			firstSeen)
+
 	// OnRescanFinished
 	case btcjson.RescanFinishedNtfnMethod:
 		// Ignore the notification if the client is not interested in
@@ -409,6 +456,40 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 
 		c.ntfnHandlers.OnTxAcceptedVerbose(rawTx)
 
+	// OnTxRemoved
+	case btcjson.TxRemovedNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnTxRemoved == nil {
+			return
+		}
+
+		hash, reason, err := parseTxRemovedNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid tx removed "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnTxRemoved(hash, reason)
+
+	// OnTxReplaced
+	case btcjson.TxReplacedNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnTxReplaced == nil {
+			return
+		}
+
+		replacement, replaced, err := parseTxReplacedNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid tx replaced "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnTxReplaced(replacement, replaced)
+
 	// OnBtcdConnected
 	case btcjson.BtcdConnectedNtfnMethod:
 		// Ignore the notification if the client is not interested in
@@ -634,6 +715,46 @@ func parseRelevantTxAcceptedParams(params []json.RawMessage) (transaction []byte
 	return parseHexParam(params[0])
 }
 
+// parseRelevantDoubleSpendParams parses out the double spend, the hashes of the
+// transactions it conflicts with and the time it was first seen from the
+// parameters of a relevantdoublespend notification.
+func parseRelevantDoubleSpendParams(params []json.RawMessage) ([]byte,
+	[]*chainhash.Hash, time.Time, error) {
+
+	if len(params) != 3 {
+		return nil, nil, time.Time{}, wrongNumParams(len(params))
+	}
+
+	transaction, err := parseHexParam(params[0])
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+
+	// Unmarshal second parameter as a slice of strings.
+	var conflictStrs []string
+	err = json.Unmarshal(params[1], &conflictStrs)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	conflicts := make([]*chainhash.Hash, 0, len(conflictStrs))
+	for _, conflictStr := range conflictStrs {
+		conflict, err := chainhash.NewHashFromStr(conflictStr)
+		if err != nil {
+			return nil, nil, time.Time{}, err
+		}
+		conflicts = append(conflicts, conflict)
+	}
+
+	// Unmarshal third parameter as an int64.
+	var firstSeen int64
+	err = json.Unmarshal(params[2], &firstSeen)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+
+	return transaction, conflicts, time.Unix(firstSeen, 0), nil
+}
+
 // parseChainTxNtfnParams parses out the transaction and optional details about
 // the block it's mined in from the parameters of recvtx and redeemingtx
 // notifications.
@@ -776,6 +897,65 @@ func parseTxAcceptedVerboseNtfnParams(params []json.RawMessage) (*btcjson.TxRawR
 	return &rawTx, nil
 }
 
+// parseTxRemovedNtfnParams parses out the transaction hash and the reason the
+// transaction was removed from the parameters of a txremoved notification.
+func parseTxRemovedNtfnParams(params []json.RawMessage) (*chainhash.Hash,
+	string, error) {
+
+	if len(params) != 2 {
+		return nil, "", wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as a string.
+	var txHashStr string
+	err := json.Unmarshal(params[0], &txHashStr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Unmarshal second parameter as a string.
+	var reason string
+	err = json.Unmarshal(params[1], &reason)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Decode string encoding of transaction sha.
+	txHash, err := chainhash.NewHashFromStr(txHashStr)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return txHash, reason, nil
+}
+
+// parseTxReplacedNtfnParams parses out the replacing transaction and the
+// replaced transactions along with their fee rates from the parameters of a
+// txreplaced notification.
+func parseTxReplacedNtfnParams(params []json.RawMessage) (*btcjson.TxFeeRate,
+	[]btcjson.TxFeeRate, error) {
+
+	if len(params) != 2 {
+		return nil, nil, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as a transaction fee rate.
+	var replacement btcjson.TxFeeRate
+	err := json.Unmarshal(params[0], &replacement)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Unmarshal second parameter as a slice of transaction fee rates.
+	var replaced []btcjson.TxFeeRate
+	err = json.Unmarshal(params[1], &replaced)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &replacement, replaced, nil
+}
+
 // parseBtcdConnectedNtfnParams parses out the connection status of eacd
 // and btcwallet from the parameters of a eacdconnected notification.
 func parseBtcdConnectedNtfnParams(params []json.RawMessage) (bool, error) {
@@ -1038,6 +1218,55 @@ func (c *Client) NotifyNewTransactions(verbose bool) error {
 	return c.NotifyNewTransactionsAsync(verbose).Receive()
 }
 
+// FutureNotifyReplacementsResult is a future promise to deliver the result of
+// a NotifyReplacementsAsync RPC invocation (or an applicable error).
+type FutureNotifyReplacementsResult chan *response
+
+// Receive waits for the response promised by the future and returns an error
+// if the registration was not successful.
+func (r FutureNotifyReplacementsResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// NotifyReplacementsAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See NotifyReplacements for the blocking version and more details.
+//
+// NOTE: This is a eacd extension and requires a websocket connection.
+func (c *Client) NotifyReplacementsAsync() FutureNotifyReplacementsResult {
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return newFutureError(ErrWebsocketsRequired)
+	}
+
+	// Ignore the notification if the client is not interested in
+	// notifications.
+	if c.ntfnHandlers == nil {
+		return newNilFutureResult()
+	}
+
+	cmd := btcjson.NewNotifyReplacementsCmd()
+	return c.sendCmd(cmd)
+}
+
+// NotifyReplacements registers the client to receive notifications every time
+// a transaction accepted to the memory pool replaces transactions in it.  The
+// notifications are delivered to the notification handlers associated with the
+// client.  Calling this function has no effect if there are no notification
+// handlers and will result in an error if the client is configured to run in
+// HTTP POST mode.
+//
+// The notifications delivered as a result of this call will be via
+// OnTxReplaced.
+//
+// NOTE: This is a eacd extension and requires a websocket connection.
+func (c *Client) NotifyReplacements() error {
+	return c.NotifyReplacementsAsync().Receive()
+}
+
 // FutureNotifyReceivedResult is a future promise to deliver the result of a
 // NotifyReceivedAsync RPC invocation (or an applicable error).
 //
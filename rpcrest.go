@@ -0,0 +1,609 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/eacsuite/eacd/btcjson"
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/txscript"
+	"github.com/eacsuite/eacd/wire"
+	"github.com/eacsuite/eacutil"
+)
+
+const (
+	// restPathPrefix is the path under which the REST interface is served
+	// by the RPC server.
+	restPathPrefix = "/rest/"
+
+	// restMaxHeaders is the maximum number of headers returned for a single
+	// request of the REST headers endpoint.
+	restMaxHeaders = 2000
+
+	// restMaxOutpoints is the maximum number of outpoints which may be
+	// queried by a single request of the REST getutxos endpoint.
+	restMaxOutpoints = 15
+
+	// restMempoolHeight is the height reported by the REST getutxos endpoint
+	// for outputs of transactions in the memory pool.
+	restMempoolHeight = math.MaxInt32
+)
+
+// restFormat identifies the format of a response of the REST interface, which
+// is selected by the extension of the requested path.
+type restFormat int
+
+const (
+	// restFormatBin is the serialized binary format.
+	restFormatBin restFormat = iota
+
+	// restFormatHex is the serialized binary format encoded as hex.
+	restFormatHex
+
+	// restFormatJSON is the JSON format, which matches the result of the
+	// equivalent RPC.
+	restFormatJSON
+)
+
+// restFormatExtensions maps the path extensions of the REST interface to the
+// formats they select.
+var restFormatExtensions = map[string]restFormat{
+	"bin":  restFormatBin,
+	"hex":  restFormatHex,
+	"json": restFormatJSON,
+}
+
+// restError is an error returned by a REST handler along with the HTTP status
+// code to respond with.
+type restError struct {
+	status  int
+	message string
+}
+
+// Error satisfies the error interface and returns the message of the error.
+func (e *restError) Error() string {
+	return e.message
+}
+
+// restBadRequest returns a restError for a malformed request with a formatted
+// message.
+func restBadRequest(format string, args ...interface{}) *restError {
+	return &restError{
+		status:  http.StatusBadRequest,
+		message: fmt.Sprintf(format, args...),
+	}
+}
+
+// restHandler describes a callback function used to handle a request of the
+// REST interface.  The parameter is the requested path after the prefix of the
+// endpoint without the format extension.  The result must be the serialized
+// data for the binary and hex formats and a value that is marshalled to JSON
+// for the JSON format.
+type restHandler func(s *rpcServer, param string, format restFormat) (interface{}, error)
+
+// restEndpoint houses the handler of a REST endpoint along with the prefix of
+// the path it is served under.
+type restEndpoint struct {
+	prefix   string
+	handler  restHandler
+	jsonOnly bool
+}
+
+// restEndpoints houses the endpoints of the REST interface.  The first endpoint
+// whose prefix matches the requested path handles the request, so more
+// specific prefixes must come first.  The JSON only endpoints don't take a
+// parameter, so their prefix must match the whole path before the extension.
+var restEndpoints = []restEndpoint{
+	{prefix: "block/notxdetails/", handler: handleRestBlockNoTxDetails},
+	{prefix: "block/", handler: handleRestBlock},
+	{prefix: "tx/", handler: handleRestTx},
+	{prefix: "headers/", handler: handleRestHeaders},
+	{prefix: "blockhashbyheight/", handler: handleRestBlockHashByHeight},
+	{prefix: "chaininfo", handler: handleRestChainInfo, jsonOnly: true},
+	{prefix: "mempool/info", handler: handleRestMempoolInfo, jsonOnly: true},
+	{prefix: "mempool/contents", handler: handleRestMempoolContents, jsonOnly: true},
+	{prefix: "getutxos/", handler: handleRestGetUtxos},
+}
+
+// restErrorReply returns the HTTP status code and message to respond with for
+// the passed error returned by a REST handler.  Errors returned by the RPC
+// handlers the REST handlers are built on are mapped by their code.
+func restErrorReply(err error) (int, string) {
+	switch e := err.(type) {
+	case *restError:
+		return e.status, e.message
+
+	case *btcjson.RPCError:
+		switch e.Code {
+		// The block not found code is the same as the one for
+		// transactions without information.
+		case btcjson.ErrRPCNoTxInfo:
+			return http.StatusNotFound, e.Message
+
+		case btcjson.ErrRPCDecodeHexString, btcjson.ErrRPCInvalidParameter:
+			return http.StatusBadRequest, e.Message
+		}
+		return http.StatusInternalServerError, e.Message
+	}
+	return http.StatusInternalServerError, err.Error()
+}
+
+// handleRestRequest serves a request of the REST interface.  The REST interface
+// is read-only and doesn't require authentication.
+func (s *rpcServer) handleRestRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Connection", "close")
+	r.Close = true
+
+	// Limit the number of connections to max allowed.  REST clients are
+	// tracked separately from RPC clients so unauthenticated requests
+	// can't take up the connections of RPC clients.
+	if int(atomic.AddInt32(&s.numRestClients, 1)) > cfg.RestMaxClients {
+		atomic.AddInt32(&s.numRestClients, -1)
+		rpcsLog.Infof("Max REST clients exceeded [%d] - "+
+			"disconnecting client %s", cfg.RestMaxClients,
+			r.RemoteAddr)
+		http.Error(w, "503 Too busy.  Try again later.",
+			http.StatusServiceUnavailable)
+		return
+	}
+	defer atomic.AddInt32(&s.numRestClients, -1)
+
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET requests are supported",
+			http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Split the format extension off the requested path.
+	path := strings.TrimPrefix(r.URL.Path, restPathPrefix)
+	var format restFormat
+	var ok bool
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		format, ok = restFormatExtensions[path[i+1:]]
+		path = path[:i]
+	}
+
+	var endpoint *restEndpoint
+	for i := range restEndpoints {
+		e := &restEndpoints[i]
+		if (e.jsonOnly && path == e.prefix) ||
+			(!e.jsonOnly && strings.HasPrefix(path, e.prefix)) {
+
+			endpoint = e
+			break
+		}
+	}
+	if endpoint == nil {
+		http.Error(w, "Unknown REST endpoint", http.StatusNotFound)
+		return
+	}
+	available := "bin, hex, json"
+	if endpoint.jsonOnly {
+		available = "json"
+	}
+	if !ok || (endpoint.jsonOnly && format != restFormatJSON) {
+		http.Error(w, fmt.Sprintf("Output format not found "+
+			"(available: %s)", available), http.StatusNotFound)
+		return
+	}
+
+	param := strings.TrimPrefix(path, endpoint.prefix)
+	result, err := endpoint.handler(s, param, format)
+	if err != nil {
+		status, message := restErrorReply(err)
+		http.Error(w, message, status)
+		return
+	}
+
+	switch format {
+	case restFormatBin:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(result.([]byte))
+
+	case restFormatHex:
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintln(w, hex.EncodeToString(result.([]byte)))
+
+	case restFormatJSON:
+		marshalled, err := json.Marshal(result)
+		if err != nil {
+			rpcsLog.Errorf("Failed to marshal REST reply: %v", err)
+			http.Error(w, "Failed to marshal reply",
+				http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(marshalled)
+		fmt.Fprintln(w)
+	}
+}
+
+// restSerializedResult returns the serialized data for the passed hex-encoded
+// result of an RPC handler.
+func restSerializedResult(result interface{}) ([]byte, error) {
+	serialized, err := hex.DecodeString(result.(string))
+	if err != nil {
+		return nil, internalRPCError(err.Error(), "Failed to decode "+
+			"RPC result")
+	}
+	return serialized, nil
+}
+
+// restBlock returns the block with the passed hash in the passed format.  The
+// transactions of the block are only detailed in the JSON format when
+// txDetails is set.
+func restBlock(s *rpcServer, hash string, format restFormat, txDetails bool) (interface{}, error) {
+	verbosity := 0
+	switch {
+	case format == restFormatJSON && txDetails:
+		verbosity = 2
+	case format == restFormatJSON:
+		verbosity = 1
+	}
+	result, err := handleGetBlock(s, &btcjson.GetBlockCmd{
+		Hash:      hash,
+		Verbosity: &verbosity,
+	}, nil)
+	if err != nil || format == restFormatJSON {
+		return result, err
+	}
+	return restSerializedResult(result)
+}
+
+// handleRestBlock implements the REST block endpoint.
+func handleRestBlock(s *rpcServer, param string, format restFormat) (interface{}, error) {
+	return restBlock(s, param, format, true)
+}
+
+// handleRestBlockNoTxDetails implements the REST block/notxdetails endpoint.
+func handleRestBlockNoTxDetails(s *rpcServer, param string, format restFormat) (interface{}, error) {
+	return restBlock(s, param, format, false)
+}
+
+// handleRestTx implements the REST tx endpoint.
+func handleRestTx(s *rpcServer, param string, format restFormat) (interface{}, error) {
+	verbose := 0
+	if format == restFormatJSON {
+		verbose = 1
+	}
+	result, err := handleGetRawTransaction(s, &btcjson.GetRawTransactionCmd{
+		Txid:    param,
+		Verbose: &verbose,
+	}, nil)
+	if err != nil || format == restFormatJSON {
+		return result, err
+	}
+	return restSerializedResult(result)
+}
+
+// handleRestHeaders implements the REST headers endpoint, which returns up to
+// the requested number of headers of the main chain starting with the one of
+// the requested block.
+func handleRestHeaders(s *rpcServer, param string, format restFormat) (interface{}, error) {
+	parts := strings.Split(param, "/")
+	if len(parts) != 2 {
+		return nil, restBadRequest("Invalid URI format. Expected " +
+			"/rest/headers/<count>/<hash>.<ext>")
+	}
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count < 1 || count > restMaxHeaders {
+		return nil, restBadRequest("Header count out of range: %s",
+			parts[0])
+	}
+	hash, err := chainhash.NewHashFromStr(parts[1])
+	if err != nil {
+		return nil, restBadRequest("Invalid hash: %s", parts[1])
+	}
+
+	chain := s.cfg.Chain
+	header, err := chain.HeaderByHash(hash)
+	if err != nil {
+		return nil, &restError{
+			status:  http.StatusNotFound,
+			message: fmt.Sprintf("Block %s not found", hash),
+		}
+	}
+	headers := []wire.BlockHeader{header}
+	hashes := []chainhash.Hash{*hash}
+
+	// Add the headers of the following blocks of the main chain.  Only the
+	// header of the requested block is returned when it isn't in the main
+	// chain.
+	if chain.MainChainHasBlock(hash) {
+		height, err := chain.BlockHeightByHash(hash)
+		if err != nil {
+			context := "Failed to obtain block height"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		for len(headers) < count {
+			height++
+			nextHash, err := chain.BlockHashByHeight(height)
+			if err != nil {
+				break
+			}
+			header, err := chain.HeaderByHash(nextHash)
+			if err != nil {
+				break
+			}
+			headers = append(headers, header)
+			hashes = append(hashes, *nextHash)
+		}
+	}
+
+	if format != restFormatJSON {
+		var buf bytes.Buffer
+		for i := range headers {
+			if err := headers[i].Serialize(&buf); err != nil {
+				context := "Failed to serialize block header"
+				return nil, internalRPCError(err.Error(), context)
+			}
+		}
+		return buf.Bytes(), nil
+	}
+
+	verbose := true
+	results := make([]interface{}, 0, len(hashes))
+	for i := range hashes {
+		result, err := handleGetBlockHeader(s, &btcjson.GetBlockHeaderCmd{
+			Hash:    hashes[i].String(),
+			Verbose: &verbose,
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// restBlockHashResult models the JSON result of the REST blockhashbyheight
+// endpoint.
+type restBlockHashResult struct {
+	BlockHash string `json:"blockhash"`
+}
+
+// handleRestBlockHashByHeight implements the REST blockhashbyheight endpoint.
+func handleRestBlockHashByHeight(s *rpcServer, param string, format restFormat) (interface{}, error) {
+	height, err := strconv.ParseInt(param, 10, 32)
+	if err != nil || height < 0 {
+		return nil, restBadRequest("Invalid height: %s", param)
+	}
+	result, err := handleGetBlockHash(s, &btcjson.GetBlockHashCmd{
+		Index: height,
+	}, nil)
+	if err != nil {
+		return nil, &restError{
+			status:  http.StatusNotFound,
+			message: "Block height out of range",
+		}
+	}
+	if format == restFormatJSON {
+		return &restBlockHashResult{BlockHash: result.(string)}, nil
+	}
+	hash, err := chainhash.NewHashFromStr(result.(string))
+	if err != nil {
+		return nil, internalRPCError(err.Error(), "Failed to decode "+
+			"block hash")
+	}
+	return hash[:], nil
+}
+
+// handleRestChainInfo implements the REST chaininfo endpoint.
+func handleRestChainInfo(s *rpcServer, param string, format restFormat) (interface{}, error) {
+	return handleGetBlockChainInfo(s, &btcjson.GetBlockChainInfoCmd{}, nil)
+}
+
+// handleRestMempoolInfo implements the REST mempool/info endpoint.
+func handleRestMempoolInfo(s *rpcServer, param string, format restFormat) (interface{}, error) {
+	return handleGetMempoolInfo(s, &btcjson.GetMempoolInfoCmd{}, nil)
+}
+
+// handleRestMempoolContents implements the REST mempool/contents endpoint.
+func handleRestMempoolContents(s *rpcServer, param string, format restFormat) (interface{}, error) {
+	verbose := true
+	return handleGetRawMempool(s, &btcjson.GetRawMempoolCmd{
+		Verbose: &verbose,
+	}, nil)
+}
+
+// restUtxo houses an unspent output found by the REST getutxos endpoint.
+type restUtxo struct {
+	height int32
+	txOut  *wire.TxOut
+}
+
+// restUtxoResult models an unspent output of the JSON result of the REST
+// getutxos endpoint.
+type restUtxoResult struct {
+	Height       int32                      `json:"height"`
+	Value        float64                    `json:"value"`
+	ScriptPubKey btcjson.ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
+// restGetUtxosResult models the JSON result of the REST getutxos endpoint.
+type restGetUtxosResult struct {
+	ChainHeight  int32            `json:"chainHeight"`
+	ChainTipHash string           `json:"chaintipHash"`
+	Bitmap       string           `json:"bitmap"`
+	Utxos        []restUtxoResult `json:"utxos"`
+}
+
+// parseRestOutpoints parses the outpoints requested from the REST getutxos
+// endpoint, which are separated by slashes and formatted as <txid>-<index>.  A
+// leading checkmempool element requests the memory pool to be considered.
+func parseRestOutpoints(param string) ([]wire.OutPoint, bool, error) {
+	parts := strings.Split(param, "/")
+	checkMempool := parts[0] == "checkmempool"
+	if checkMempool {
+		parts = parts[1:]
+	}
+	if len(parts) == 0 || (len(parts) == 1 && parts[0] == "") {
+		return nil, false, restBadRequest("No outpoints specified")
+	}
+	if len(parts) > restMaxOutpoints {
+		return nil, false, restBadRequest("Too many outpoints "+
+			"requested (max %d)", restMaxOutpoints)
+	}
+
+	outpoints := make([]wire.OutPoint, 0, len(parts))
+	for _, part := range parts {
+		i := strings.LastIndex(part, "-")
+		if i < 0 {
+			return nil, false, restBadRequest("Invalid outpoint: %s",
+				part)
+		}
+		hash, err := chainhash.NewHashFromStr(part[:i])
+		if err != nil {
+			return nil, false, restBadRequest("Invalid outpoint: %s",
+				part)
+		}
+		index, err := strconv.ParseUint(part[i+1:], 10, 32)
+		if err != nil {
+			return nil, false, restBadRequest("Invalid outpoint: %s",
+				part)
+		}
+		outpoints = append(outpoints, wire.OutPoint{
+			Hash:  *hash,
+			Index: uint32(index),
+		})
+	}
+	return outpoints, checkMempool, nil
+}
+
+// serializeRestUtxos returns the binary format of the result of the REST
+// getutxos endpoint, which matches the one of Bitcoin Core: the height and hash
+// of the chain tip, the bitmap of the outpoints which were found as a byte
+// vector, and the found outputs along with their heights.
+func serializeRestUtxos(height int32, tip *chainhash.Hash, bitmap []byte,
+	utxos []restUtxo) ([]byte, error) {
+
+	var buf bytes.Buffer
+	var scratch [4]byte
+	binary.LittleEndian.PutUint32(scratch[:], uint32(height))
+	buf.Write(scratch[:])
+	buf.Write(tip[:])
+	if err := wire.WriteVarBytes(&buf, 0, bitmap); err != nil {
+		return nil, err
+	}
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(utxos))); err != nil {
+		return nil, err
+	}
+	for _, utxo := range utxos {
+		// The outputs are preceded by an unused transaction version.
+		binary.LittleEndian.PutUint32(scratch[:], 0)
+		buf.Write(scratch[:])
+		binary.LittleEndian.PutUint32(scratch[:], uint32(utxo.height))
+		buf.Write(scratch[:])
+		if err := wire.WriteTxOut(&buf, 0, 0, utxo.txOut); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// handleRestGetUtxos implements the REST getutxos endpoint, which returns the
+// outputs of the requested outpoints that are unspent in the main chain.  When
+// the memory pool is checked, outputs spent by transactions in the memory pool
+// are considered spent and outputs of transactions in the memory pool are
+// considered unspent.
+func handleRestGetUtxos(s *rpcServer, param string, format restFormat) (interface{}, error) {
+	outpoints, checkMempool, err := parseRestOutpoints(param)
+	if err != nil {
+		return nil, err
+	}
+
+	best := s.cfg.Chain.BestSnapshot()
+	bitmap := make([]byte, (len(outpoints)+7)/8)
+	hits := make([]byte, len(outpoints))
+	utxos := make([]restUtxo, 0, len(outpoints))
+	for i, outpoint := range outpoints {
+		if checkMempool && s.cfg.TxMemPool.CheckSpend(outpoint) != nil {
+			hits[i] = '0'
+			continue
+		}
+
+		var utxo *restUtxo
+		entry, err := s.cfg.Chain.FetchUtxoEntry(outpoint)
+		if err != nil {
+			context := "Failed to fetch utxo"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if entry != nil && !entry.IsSpent() {
+			utxo = &restUtxo{
+				height: entry.BlockHeight(),
+				txOut:  wire.NewTxOut(entry.Amount(), entry.PkScript()),
+			}
+		} else if checkMempool {
+			tx, err := s.cfg.TxMemPool.FetchTransaction(&outpoint.Hash)
+			if err == nil && outpoint.Index < uint32(len(tx.MsgTx().TxOut)) {
+				utxo = &restUtxo{
+					height: restMempoolHeight,
+					txOut:  tx.MsgTx().TxOut[outpoint.Index],
+				}
+			}
+		}
+
+		if utxo == nil {
+			hits[i] = '0'
+			continue
+		}
+		hits[i] = '1'
+		bitmap[i/8] |= 1 << uint(i%8)
+		utxos = append(utxos, *utxo)
+	}
+
+	if format != restFormatJSON {
+		serialized, err := serializeRestUtxos(best.Height, &best.Hash,
+			bitmap, utxos)
+		if err != nil {
+			context := "Failed to serialize utxos"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		return serialized, nil
+	}
+
+	result := &restGetUtxosResult{
+		ChainHeight:  best.Height,
+		ChainTipHash: best.Hash.String(),
+		Bitmap:       string(hits),
+		Utxos:        make([]restUtxoResult, 0, len(utxos)),
+	}
+	for _, utxo := range utxos {
+		pkScript := utxo.txOut.PkScript
+
+		// Ignore the errors here since the script is reported as far as
+		// it could be parsed, just like for the gettxout RPC.
+		disbuf, _ := txscript.DisasmString(pkScript)
+		scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(
+			pkScript, s.cfg.ChainParams)
+		addresses := make([]string, len(addrs))
+		for i, addr := range addrs {
+			addresses[i] = addr.EncodeAddress()
+		}
+
+		result.Utxos = append(result.Utxos, restUtxoResult{
+			Height: utxo.height,
+			Value:  eacutil.Amount(utxo.txOut.Value).ToBTC(),
+			ScriptPubKey: btcjson.ScriptPubKeyResult{
+				Asm:       disbuf,
+				Hex:       hex.EncodeToString(pkScript),
+				ReqSigs:   int32(reqSigs),
+				Type:      scriptClass.String(),
+				Addresses: addresses,
+			},
+		})
+	}
+	return result, nil
+}
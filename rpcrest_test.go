@@ -0,0 +1,171 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btclog"
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/wire"
+)
+
+// TestParseRestOutpoints ensures the outpoints requested from the REST getutxos
+// endpoint are parsed as expected.
+func TestParseRestOutpoints(t *testing.T) {
+	t.Parallel()
+
+	const txid = "4c453935265b8cad61413cd7a787af5ed7646a057f13bd598bffa525b15b499e"
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		t.Fatalf("NewHashFromStr: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		param        string
+		outpoints    []wire.OutPoint
+		checkMempool bool
+		valid        bool
+	}{
+		{
+			name:      "single outpoint",
+			param:     txid + "-1",
+			outpoints: []wire.OutPoint{{Hash: *hash, Index: 1}},
+			valid:     true,
+		},
+		{
+			name:  "multiple outpoints checking the mempool",
+			param: "checkmempool/" + txid + "-0/" + txid + "-2",
+			outpoints: []wire.OutPoint{
+				{Hash: *hash, Index: 0},
+				{Hash: *hash, Index: 2},
+			},
+			checkMempool: true,
+			valid:        true,
+		},
+		{
+			name:  "no outpoints",
+			param: "",
+		},
+		{
+			name:  "only checkmempool",
+			param: "checkmempool",
+		},
+		{
+			name:  "missing index",
+			param: txid,
+		},
+		{
+			name:  "negative index",
+			param: txid + "--1",
+		},
+		{
+			name:  "invalid hash",
+			param: "zz-0",
+		},
+		{
+			name: "too many outpoints",
+			param: txid + "-0/" + txid + "-1/" + txid + "-2/" + txid +
+				"-3/" + txid + "-4/" + txid + "-5/" + txid + "-6/" +
+				txid + "-7/" + txid + "-8/" + txid + "-9/" + txid +
+				"-10/" + txid + "-11/" + txid + "-12/" + txid +
+				"-13/" + txid + "-14/" + txid + "-15",
+		},
+	}
+
+	for _, test := range tests {
+		outpoints, checkMempool, err := parseRestOutpoints(test.param)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: no error for invalid request", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(outpoints, test.outpoints) ||
+			checkMempool != test.checkMempool {
+
+			t.Errorf("%s: got %v (checkmempool %v), want %v "+
+				"(checkmempool %v)", test.name, outpoints,
+				checkMempool, test.outpoints, test.checkMempool)
+		}
+	}
+}
+
+// TestSerializeRestUtxos ensures the binary format of the result of the REST
+// getutxos endpoint matches the one of Bitcoin Core.
+func TestSerializeRestUtxos(t *testing.T) {
+	t.Parallel()
+
+	tip := chainhash.Hash{0x01, 0x02}
+	utxos := []restUtxo{
+		{height: 5, txOut: wire.NewTxOut(0x0100, []byte{0x51})},
+		{height: restMempoolHeight, txOut: wire.NewTxOut(0x02, nil)},
+	}
+	got, err := serializeRestUtxos(7, &tip, []byte{0x05}, utxos)
+	if err != nil {
+		t.Fatalf("serializeRestUtxos: unexpected error: %v", err)
+	}
+
+	want, _ := hex.DecodeString("07000000" +
+		"0102000000000000000000000000000000000000000000000000000000000000" +
+		"0105" + "02" +
+		"00000000" + "05000000" + "0001000000000000" + "0151" +
+		"00000000" + "ffffff7f" + "0200000000000000" + "00")
+	if !bytes.Equal(got, want) {
+		t.Fatalf("serializeRestUtxos: got %x, want %x", got, want)
+	}
+}
+
+// TestRestClientLimit ensures REST clients are limited separately from RPC
+// clients.
+func TestRestClientLimit(t *testing.T) {
+	oldCfg, oldLog := cfg, rpcsLog
+	cfg = &config{RPCMaxClients: 1, RestMaxClients: 1}
+	rpcsLog = btclog.Disabled
+	defer func() {
+		cfg, rpcsLog = oldCfg, oldLog
+	}()
+
+	// Requests are served while all RPC clients are connected.  Only GET
+	// requests are supported, so a POST request is rejected right after
+	// it passes the limit.
+	s := &rpcServer{numClients: 1}
+	request := func() int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, restPathPrefix+
+			"chaininfo.json", nil)
+		s.handleRestRequest(w, r)
+		return w.Code
+	}
+	if code := request(); code != http.StatusMethodNotAllowed {
+		t.Fatalf("got status %d, want %d", code,
+			http.StatusMethodNotAllowed)
+	}
+	if s.numRestClients != 0 || s.numClients != 1 {
+		t.Fatalf("got %d REST and %d RPC clients after request",
+			s.numRestClients, s.numClients)
+	}
+
+	// Requests are rejected while all REST clients are connected.
+	s.numRestClients = 1
+	if code := request(); code != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d, want %d", code,
+			http.StatusServiceUnavailable)
+	}
+	if s.numRestClients != 1 {
+		t.Fatalf("got %d REST clients after rejected request",
+			s.numRestClients)
+	}
+}
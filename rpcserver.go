@@ -16,11 +16,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"math/rand"
 	"net"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -38,6 +40,7 @@ import (
 	"github.com/eacsuite/eacd/mempool"
 	"github.com/eacsuite/eacd/mining"
 	"github.com/eacsuite/eacd/mining/cpuminer"
+	"github.com/eacsuite/eacd/mining/stratum"
 	"github.com/eacsuite/eacd/peer"
 	"github.com/eacsuite/eacd/txscript"
 	"github.com/eacsuite/eacd/wire"
@@ -75,6 +78,10 @@ const (
 
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = 70002
+
+	// maxTestMempoolAcceptTxns is the maximum number of transactions the
+	// testmempoolaccept RPC accepts in a package.
+	maxTestMempoolAcceptTxns = 25
 )
 
 var (
@@ -86,16 +93,6 @@ var (
 		"time", "transactions/add", "prevblock", "coinbase/append",
 	}
 
-	// gbtCoinbaseAux describes additional data that miners should include
-	// in the coinbase signature script.  It is declared here to avoid the
-	// overhead of creating a new object on every invocation for constant
-	// data.
-	gbtCoinbaseAux = &btcjson.GetBlockTemplateResultAux{
-		Flags: hex.EncodeToString(builderScript(txscript.
-			NewScriptBuilder().
-			AddData([]byte(mining.CoinbaseFlags)))),
-	}
-
 	// gbtCapabilities describes additional capabilities returned with a
 	// block template generated by the getblocktemplate RPC.    It is
 	// declared here to avoid the overhead of creating the slice on every
@@ -133,7 +130,11 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"decoderawtransaction":  handleDecodeRawTransaction,
 	"decodescript":          handleDecodeScript,
 	"estimatefee":           handleEstimateFee,
+	"estimaterawfee":        handleEstimateRawFee,
+	"estimatesmartfee":      handleEstimateSmartFee,
 	"generate":              handleGenerate,
+	"generateblock":         handleGenerateBlock,
+	"generatetoaddress":     handleGenerateToAddress,
 	"getaddednodeinfo":      handleGetAddedNodeInfo,
 	"getbestblock":          handleGetBestBlock,
 	"getbestblockhash":      handleGetBestBlockHash,
@@ -152,6 +153,9 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"gethashespersec":       handleGetHashesPerSec,
 	"getheaders":            handleGetHeaders,
 	"getinfo":               handleGetInfo,
+	"getmempoolancestors":   handleGetMempoolAncestors,
+	"getmempooldescendants": handleGetMempoolDescendants,
+	"getmempoolentry":       handleGetMempoolEntry,
 	"getmempoolinfo":        handleGetMempoolInfo,
 	"getmininginfo":         handleGetMiningInfo,
 	"getnettotals":          handleGetNetTotals,
@@ -159,15 +163,20 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getpeerinfo":           handleGetPeerInfo,
 	"getrawmempool":         handleGetRawMempool,
 	"getrawtransaction":     handleGetRawTransaction,
+	"getstratuminfo":        handleGetStratumInfo,
 	"gettxout":              handleGetTxOut,
 	"help":                  handleHelp,
 	"node":                  handleNode,
 	"ping":                  handlePing,
+	"prioritisetransaction": handlePrioritiseTransaction,
+	"savemempool":           handleSaveMempool,
 	"searchrawtransactions": handleSearchRawTransactions,
 	"sendrawtransaction":    handleSendRawTransaction,
 	"setgenerate":           handleSetGenerate,
+	"setmocktime":           handleSetMockTime,
 	"stop":                  handleStop,
 	"submitblock":           handleSubmitBlock,
+	"testmempoolaccept":     handleTestMempoolAccept,
 	"uptime":                handleUptime,
 	"validateaddress":       handleValidateAddress,
 	"verifychain":           handleVerifyChain,
@@ -227,7 +236,6 @@ var rpcAskWallet = map[string]struct{}{
 var rpcUnimplemented = map[string]struct{}{
 	"estimatepriority": {},
 	"getchaintips":     {},
-	"getmempoolentry":  {},
 	"getnetworkinfo":   {},
 	"getwork":          {},
 	"invalidateblock":  {},
@@ -255,6 +263,8 @@ var rpcLimited = map[string]struct{}{
 	"decoderawtransaction":  {},
 	"decodescript":          {},
 	"estimatefee":           {},
+	"estimaterawfee":        {},
+	"estimatesmartfee":      {},
 	"getbestblock":          {},
 	"getbestblockhash":      {},
 	"getblock":              {},
@@ -267,6 +277,9 @@ var rpcLimited = map[string]struct{}{
 	"getdifficulty":         {},
 	"getheaders":            {},
 	"getinfo":               {},
+	"getmempoolancestors":   {},
+	"getmempooldescendants": {},
+	"getmempoolentry":       {},
 	"getnettotals":          {},
 	"getnetworkhashps":      {},
 	"getrawmempool":         {},
@@ -275,6 +288,7 @@ var rpcLimited = map[string]struct{}{
 	"searchrawtransactions": {},
 	"sendrawtransaction":    {},
 	"submitblock":           {},
+	"testmempoolaccept":     {},
 	"uptime":                {},
 	"validateaddress":       {},
 	"verifymessage":         {},
@@ -324,6 +338,14 @@ func rpcNoTxInfoError(txHash *chainhash.Hash) *btcjson.RPCError {
 			txHash))
 }
 
+// rpcNotInMempoolError is a convenience function for returning a nicely
+// formatted RPC error which indicates the provided transaction is not in the
+// memory pool.
+func rpcNotInMempoolError(txHash *chainhash.Hash) *btcjson.RPCError {
+	return btcjson.NewRPCError(btcjson.ErrRPCNoTxInfo,
+		fmt.Sprintf("Transaction %v is not in the memory pool", txHash))
+}
+
 // gbtWorkState houses state that is used in between multiple RPC invocations to
 // getblocktemplate.
 type gbtWorkState struct {
@@ -335,14 +357,26 @@ type gbtWorkState struct {
 	template      *mining.BlockTemplate
 	notifyMap     map[chainhash.Hash]map[int64]chan struct{}
 	timeSource    blockchain.MedianTimeSource
+
+	// coinbaseAux describes additional data that miners should include
+	// in the coinbase signature script.  It is created along with the
+	// state to avoid the overhead of creating a new object on every
+	// invocation for constant data.
+	coinbaseAux *btcjson.GetBlockTemplateResultAux
 }
 
 // newGbtWorkState returns a new instance of a gbtWorkState with all internal
-// fields initialized and ready to use.
-func newGbtWorkState(timeSource blockchain.MedianTimeSource) *gbtWorkState {
+// fields initialized and ready to use.  The passed coinbase flags are handed
+// to miners which create their own coinbase.
+func newGbtWorkState(timeSource blockchain.MedianTimeSource, coinbaseFlags string) *gbtWorkState {
 	return &gbtWorkState{
 		notifyMap:  make(map[chainhash.Hash]map[int64]chan struct{}),
 		timeSource: timeSource,
+		coinbaseAux: &btcjson.GetBlockTemplateResultAux{
+			Flags: hex.EncodeToString(builderScript(txscript.
+				NewScriptBuilder().
+				AddData([]byte(coinbaseFlags)))),
+		},
 	}
 }
 
@@ -878,11 +912,181 @@ func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return float64(feeRate), nil
 }
 
+// handleEstimateRawFee handles estimaterawfee commands.
+func handleEstimateRawFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimateRawFeeCmd)
+
+	if s.cfg.FeeEstimator == nil {
+		return nil, errors.New("Fee estimation disabled")
+	}
+
+	if c.ConfTarget < 1 || c.ConfTarget > mempool.MaxFeeEstimateTarget {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid conf_target, must be "+
+				"between 1 and %d", mempool.MaxFeeEstimateTarget),
+		}
+	}
+	threshold := 0.95
+	if c.Threshold != nil {
+		threshold = *c.Threshold
+	}
+	if threshold < 0 || threshold > 1 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid threshold, must be between 0 and 1",
+		}
+	}
+
+	// Only the horizons tracking the requested target are included.
+	var result btcjson.EstimateRawFeeResult
+	horizons := map[mempool.FeeEstimateHorizon]**btcjson.EstimateRawFeeHorizonResult{
+		mempool.ShortHorizon:  &result.Short,
+		mempool.MediumHorizon: &result.Medium,
+		mempool.LongHorizon:   &result.Long,
+	}
+	for horizon, horizonResult := range horizons {
+		estimate, err := s.cfg.FeeEstimator.EstimateRawFee(
+			uint32(c.ConfTarget), threshold, horizon)
+		if err != nil {
+			continue
+		}
+
+		reply := &btcjson.EstimateRawFeeHorizonResult{
+			Decay: estimate.Decay,
+			Scale: int64(estimate.Scale),
+			Pass:  rawFeeBucketResult(estimate.Pass),
+			Fail:  rawFeeBucketResult(estimate.Fail),
+		}
+		if estimate.FeeRate >= 0 {
+			feeRate := float64(estimate.FeeRate)
+			reply.FeeRate = &feeRate
+		} else {
+			reply.Errors = []string{"Insufficient data or no fee " +
+				"rate found which meets threshold"}
+		}
+		*horizonResult = reply
+	}
+
+	return &result, nil
+}
+
+// rawFeeBucketResult converts the passed range of fee rates examined by a fee
+// estimate to its JSON-RPC result.  It returns nil when the range is nil.
+func rawFeeBucketResult(bucket *mempool.FeeEstimateBucket) *btcjson.EstimateRawFeeBucket {
+	if bucket == nil {
+		return nil
+	}
+	return &btcjson.EstimateRawFeeBucket{
+		StartRange:     float64(bucket.StartRange),
+		EndRange:       float64(bucket.EndRange),
+		WithinTarget:   bucket.WithinTarget,
+		TotalConfirmed: bucket.TotalConfirmed,
+		InMempool:      bucket.InMempool,
+		LeftMempool:    bucket.LeftMempool,
+	}
+}
+
+// handleEstimateSmartFee handles estimatesmartfee commands.
+func handleEstimateSmartFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimateSmartFeeCmd)
+
+	if s.cfg.FeeEstimator == nil {
+		return nil, errors.New("Fee estimation disabled")
+	}
+
+	if c.ConfTarget < 1 || c.ConfTarget > mempool.MaxFeeEstimateTarget {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid conf_target, must be "+
+				"between 1 and %d", mempool.MaxFeeEstimateTarget),
+		}
+	}
+
+	// Estimates are conservative unless economical estimates are
+	// explicitly requested.
+	conservative := c.EstimateMode == nil ||
+		*c.EstimateMode != btcjson.EstimateModeEconomical
+
+	feeRate, blocks, err := s.cfg.FeeEstimator.EstimateSmartFee(
+		uint32(c.ConfTarget), conservative)
+	if err != nil {
+		return &btcjson.EstimateSmartFeeResult{
+			Errors: []string{"Insufficient data or no fee rate found"},
+			Blocks: int64(blocks),
+		}, nil
+	}
+
+	// Transactions paying less than the minimum fee of the mempool
+	// wouldn't be relayed, so the estimate is never lower.
+	reply := float64(feeRate)
+	if minFee := s.cfg.TxMemPool.MinFee().ToBTC(); reply < minFee {
+		reply = minFee
+	}
+	return &btcjson.EstimateSmartFeeResult{
+		FeeRate: &reply,
+		Blocks:  int64(blocks),
+	}, nil
+}
+
+// checkGenerateSupported returns an error for the passed command when there's
+// virtually 0 chance of mining a block with the CPU on the current network.
+func checkGenerateSupported(s *rpcServer, method string) error {
+	if !s.cfg.ChainParams.GenerateSupported {
+		return &btcjson.RPCError{
+			Code: btcjson.ErrRPCDifficulty,
+			Message: fmt.Sprintf("No support for `%s` on "+
+				"the current network, %s, as it's unlikely to "+
+				"be possible to mine a block with the CPU.",
+				method, s.cfg.ChainParams.Net),
+		}
+	}
+	return nil
+}
+
+// decodeGenerateAddress decodes the passed address to pay generated blocks to
+// and ensures it is for the network the server is currently on.
+func decodeGenerateAddress(s *rpcServer, encodedAddr string) (eacutil.Address, error) {
+	params := s.cfg.ChainParams
+	addr, err := eacutil.DecodeAddress(encodedAddr, params)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+	if !addr.IsForNet(params) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address: " + encodedAddr +
+				" is for the wrong network",
+		}
+	}
+	return addr, nil
+}
+
+// generateTemplateOptions returns the block template options for the passed
+// optional timestamp and version of generated blocks, or nil when neither is
+// specified.
+func generateTemplateOptions(timestamp *int64, version *int32) *mining.TemplateOptions {
+	if timestamp == nil && version == nil {
+		return nil
+	}
+	opts := new(mining.TemplateOptions)
+	if timestamp != nil {
+		opts.Timestamp = time.Unix(*timestamp, 0)
+	}
+	if version != nil {
+		opts.Version = *version
+	}
+	return opts
+}
+
 // handleGenerate handles generate commands.
 func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if there are no addresses to pay the
 	// created blocks to.
-	if len(cfg.miningAddrs) == 0 {
+	if len(cfg.miningPayouts) == 0 {
 		return nil, &btcjson.RPCError{
 			Code: btcjson.ErrRPCInternal.Code,
 			Message: "No payment addresses specified " +
@@ -892,14 +1096,8 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 
 	// Respond with an error if there's virtually 0 chance of mining a block
 	// with the CPU.
-	if !s.cfg.ChainParams.GenerateSupported {
-		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCDifficulty,
-			Message: fmt.Sprintf("No support for `generate` on "+
-				"the current network, %s, as it's unlikely to "+
-				"be possible to mine a block with the CPU.",
-				s.cfg.ChainParams.Net),
-		}
+	if err := checkGenerateSupported(s, "generate"); err != nil {
+		return nil, err
 	}
 
 	c := cmd.(*btcjson.GenerateCmd)
@@ -932,6 +1130,135 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return reply, nil
 }
 
+// handleGenerateBlock handles generateblock commands.
+func handleGenerateBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if there's virtually 0 chance of mining a block
+	// with the CPU.
+	if err := checkGenerateSupported(s, "generateblock"); err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GenerateBlockCmd)
+	addr, err := decodeGenerateAddress(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each transaction is either the id of a transaction in the memory
+	// pool or a serialized transaction.  A serialized transaction is always
+	// longer than a hash, so the length tells them apart.  An empty list
+	// is kept non-nil to generate a block with only the coinbase.
+	txns := make([]*eacutil.Tx, 0, len(c.Transactions))
+	for _, txStr := range c.Transactions {
+		if len(txStr) == chainhash.MaxHashStringSize {
+			txHash, err := chainhash.NewHashFromStr(txStr)
+			if err != nil {
+				return nil, rpcDecodeHexError(txStr)
+			}
+			tx, err := s.cfg.TxMemPool.FetchTransaction(txHash)
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCNoTxInfo,
+					Message: "Transaction " + txStr +
+						" not in mempool",
+				}
+			}
+			txns = append(txns, tx)
+			continue
+		}
+
+		hexStr := txStr
+		if len(hexStr)%2 != 0 {
+			hexStr = "0" + hexStr
+		}
+		serializedTx, err := hex.DecodeString(hexStr)
+		if err != nil {
+			return nil, rpcDecodeHexError(hexStr)
+		}
+		var msgTx wire.MsgTx
+		err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDeserialization,
+				Message: "TX decode failed: " + err.Error(),
+			}
+		}
+		txns = append(txns, eacutil.NewTx(&msgTx))
+	}
+
+	opts := generateTemplateOptions(c.Timestamp, c.Version)
+	if opts == nil {
+		opts = new(mining.TemplateOptions)
+	}
+	opts.Transactions = txns
+
+	// Generating the block fails once it is rejected, so it is not
+	// generated over and over when the transactions are invalid.
+	blockHashes, err := s.cfg.CPUMiner.GenerateBlocks(1, addr, 0, opts)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: err.Error(),
+		}
+	}
+
+	return &btcjson.GenerateBlockResult{
+		Hash: blockHashes[0].String(),
+	}, nil
+}
+
+// handleGenerateToAddress handles generatetoaddress commands.
+func handleGenerateToAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if there's virtually 0 chance of mining a block
+	// with the CPU.
+	if err := checkGenerateSupported(s, "generatetoaddress"); err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GenerateToAddressCmd)
+
+	// Respond with an error if the client is requesting 0 blocks to be
+	// generated or more than can be generated at once.
+	if c.NumBlocks <= 0 || c.NumBlocks > math.MaxUint32 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Please request a nonzero number of blocks to generate.",
+		}
+	}
+	if c.MaxTries != nil && *c.MaxTries <= 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "The maximum number of tries must be positive",
+		}
+	}
+	addr, err := decodeGenerateAddress(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	var maxTries uint64
+	if c.MaxTries != nil {
+		maxTries = uint64(*c.MaxTries)
+	}
+	opts := generateTemplateOptions(c.Timestamp, c.Version)
+	blockHashes, err := s.cfg.CPUMiner.GenerateBlocks(uint32(c.NumBlocks),
+		addr, maxTries, opts)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: err.Error(),
+		}
+	}
+
+	// Fewer blocks than requested are generated when the maximum number
+	// of tries is reached.
+	reply := make([]string, len(blockHashes))
+	for i, hash := range blockHashes {
+		reply[i] = hash.String()
+	}
+	return reply, nil
+}
+
 // handleGetAddedNodeInfo handles getaddednodeinfo commands.
 func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetAddedNodeInfoCmd)
@@ -1485,7 +1812,7 @@ func (state *gbtWorkState) NotifyMempoolTx(lastUpdated time.Time) {
 			return
 		}
 
-		if time.Now().After(state.lastGenerated.Add(time.Second *
+		if blockchain.Now().After(state.lastGenerated.Add(time.Second *
 			gbtRegenerateSeconds)) {
 
 			state.notifyLongPollers(state.prevHash, lastUpdated)
@@ -1537,7 +1864,7 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 	generator := s.cfg.Generator
 	lastTxUpdate := generator.TxSource().LastUpdated()
 	if lastTxUpdate.IsZero() {
-		lastTxUpdate = time.Now()
+		lastTxUpdate = blockchain.Now()
 	}
 
 	// Generate a new block template when the current best block has
@@ -1551,7 +1878,7 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 	if template == nil || state.prevHash == nil ||
 		!state.prevHash.IsEqual(latestHash) ||
 		(state.lastTxUpdate != lastTxUpdate &&
-			time.Now().After(state.lastGenerated.Add(time.Second*
+			blockchain.Now().After(state.lastGenerated.Add(time.Second*
 				gbtRegenerateSeconds))) {
 
 		// Reset the previous best hash the block template was generated
@@ -1559,12 +1886,12 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 		// again.
 		state.prevHash = nil
 
-		// Choose a payment address at random if the caller requests a
-		// full coinbase as opposed to only the pertinent details needed
-		// to create their own coinbase.
-		var payAddr eacutil.Address
+		// Choose a payout list at random if the caller requests a full
+		// coinbase as opposed to only the pertinent details needed to
+		// create their own coinbase.
+		var opts mining.TemplateOptions
 		if !useCoinbaseValue {
-			payAddr = cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
+			opts.Payouts = cfg.miningPayouts[rand.Intn(len(cfg.miningPayouts))]
 		}
 
 		// Create a new block template that has a coinbase which anyone
@@ -1572,7 +1899,8 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 		// block template doesn't include the coinbase, so the caller
 		// will ultimately create their own coinbase which pays to the
 		// appropriate address(es).
-		blkTemplate, err := generator.NewBlockTemplate(payAddr)
+		blkTemplate, err := generator.NewBlockTemplateWithOptions(nil,
+			&opts)
 		if err != nil {
 			return internalRPCError("Failed to create new block "+
 				"template: "+err.Error(), "")
@@ -1591,7 +1919,7 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 		// Update work state to ensure another block template isn't
 		// generated until needed.
 		state.template = template
-		state.lastGenerated = time.Now()
+		state.lastGenerated = blockchain.Now()
 		state.lastTxUpdate = lastTxUpdate
 		state.prevHash = latestHash
 		state.minTimestamp = minTimestamp
@@ -1613,22 +1941,22 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 
 		// When the caller requires a full coinbase as opposed to only
 		// the pertinent details needed to create their own coinbase,
-		// add a payment address to the output of the coinbase of the
-		// template if it doesn't already have one.  Since this requires
-		// mining addresses to be specified via the config, an error is
-		// returned if none have been specified.
+		// add payment addresses to the outputs of the coinbase of the
+		// template if it doesn't already have them.  Since this
+		// requires mining addresses to be specified via the config, an
+		// error is returned if none have been specified.
 		if !useCoinbaseValue && !template.ValidPayAddress {
-			// Choose a payment address at random.
-			payToAddr := cfg.miningAddrs[rand.Intn(len(cfg.miningAddrs))]
+			// Choose a payout list at random.
+			payouts := cfg.miningPayouts[rand.Intn(len(cfg.miningPayouts))]
 
 			// Update the block coinbase output of the template to
-			// pay to the randomly selected payment address.
-			pkScript, err := txscript.PayToAddrScript(payToAddr)
+			// pay to the randomly selected payouts.
+			err := mining.PayCoinbase(template.Block.Transactions[0],
+				payouts, cfg.minRelayTxFee)
 			if err != nil {
-				context := "Failed to create pay-to-addr script"
+				context := "Failed to create coinbase payouts"
 				return internalRPCError(err.Error(), context)
 			}
-			template.Block.Transactions[0].TxOut[0].PkScript = pkScript
 			template.ValidPayAddress = true
 
 			// Update the merkle root.
@@ -1764,7 +2092,7 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 	}
 
 	if useCoinbaseValue {
-		reply.CoinbaseAux = gbtCoinbaseAux
+		reply.CoinbaseAux = state.coinbaseAux
 		reply.CoinbaseValue = &msgBlock.Transactions[0].TxOut[0].Value
 	} else {
 		// Ensure the template has a valid payment address associated
@@ -1927,7 +2255,7 @@ func handleGetBlockTemplateRequest(s *rpcServer, request *btcjson.TemplateReques
 
 	// When a coinbase transaction has been requested, respond with an error
 	// if there are no addresses to pay the created block template to.
-	if !useCoinbaseValue && len(cfg.miningAddrs) == 0 {
+	if !useCoinbaseValue && len(cfg.miningPayouts) == 0 {
 		return nil, &btcjson.RPCError{
 			Code: btcjson.ErrRPCInternal.Code,
 			Message: "A coinbase transaction has been requested, " +
@@ -2319,6 +2647,77 @@ func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 	return ret, nil
 }
 
+// mempoolRelatives returns the result of the getmempoolancestors and
+// getmempooldescendants commands for the passed relatives of a transaction.
+// It is the verbose descriptions of the relatives keyed by their hashes when
+// the verbose flag is set and their sorted hashes otherwise.
+func mempoolRelatives(relatives map[string]*btcjson.GetMempoolEntryResult,
+	verbose *bool) interface{} {
+
+	if verbose != nil && *verbose {
+		return relatives
+	}
+
+	hashStrings := make([]string, 0, len(relatives))
+	for hash := range relatives {
+		hashStrings = append(hashStrings, hash)
+	}
+	sort.Strings(hashStrings)
+
+	return hashStrings
+}
+
+// handleGetMempoolAncestors implements the getmempoolancestors command.
+func handleGetMempoolAncestors(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolAncestorsCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	ancestors, err := s.cfg.TxMemPool.MempoolAncestors(txHash)
+	if err != nil {
+		return nil, rpcNotInMempoolError(txHash)
+	}
+
+	return mempoolRelatives(ancestors, c.Verbose), nil
+}
+
+// handleGetMempoolDescendants implements the getmempooldescendants command.
+func handleGetMempoolDescendants(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolDescendantsCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	descendants, err := s.cfg.TxMemPool.MempoolDescendants(txHash)
+	if err != nil {
+		return nil, rpcNotInMempoolError(txHash)
+	}
+
+	return mempoolRelatives(descendants, c.Verbose), nil
+}
+
+// handleGetMempoolEntry implements the getmempoolentry command.
+func handleGetMempoolEntry(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolEntryCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	entry, err := s.cfg.TxMemPool.MempoolEntry(txHash)
+	if err != nil {
+		return nil, rpcNotInMempoolError(txHash)
+	}
+
+	return entry, nil
+}
+
 // handleGetMempoolInfo implements the getmempoolinfo command.
 func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	mempoolTxns := s.cfg.TxMemPool.TxDescs()
@@ -2329,8 +2728,11 @@ func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct
 	}
 
 	ret := &btcjson.GetMempoolInfoResult{
-		Size:  int64(len(mempoolTxns)),
-		Bytes: numBytes,
+		Size:          int64(len(mempoolTxns)),
+		Bytes:         numBytes,
+		MaxMempool:    int64(cfg.MaxMempool) * 1000000,
+		MempoolMinFee: s.cfg.TxMemPool.MinFee().ToBTC(),
+		MinRelayTxFee: cfg.minRelayTxFee.ToBTC(),
 	}
 
 	return ret, nil
@@ -2505,6 +2907,7 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			BanScore:       int32(p.BanScore()),
 			FeeFilter:      p.FeeFilter(),
 			SyncNode:       statsSnap.ID == syncPeerID,
+			MappedAS:       p.MappedAS(),
 		}
 		if p.ToPeer().LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
@@ -2656,6 +3059,43 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 	return *rawTxn, nil
 }
 
+// handleGetStratumInfo implements the getstratuminfo command.
+func handleGetStratumInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the Stratum server is not enabled.
+	stratumServer := s.cfg.StratumServer
+	if stratumServer == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Stratum server must be enabled (--stratumlisten)",
+		}
+	}
+
+	workers := stratumServer.WorkerStats()
+	result := &btcjson.GetStratumInfoResult{
+		Connections: int32(stratumServer.NumClients()),
+		Workers:     make([]btcjson.StratumWorkerResult, 0, len(workers)),
+	}
+	for _, w := range workers {
+		var lastShare int64
+		if !w.LastShare.IsZero() {
+			lastShare = w.LastShare.Unix()
+		}
+		result.HashesPerSec += w.HashesPerSec
+		result.Workers = append(result.Workers, btcjson.StratumWorkerResult{
+			Name:           w.Name,
+			Connections:    int32(w.Connections),
+			Difficulty:     w.Difficulty,
+			HashesPerSec:   w.HashesPerSec,
+			AcceptedShares: w.AcceptedShares,
+			RejectedShares: w.RejectedShares,
+			StaleShares:    w.StaleShares,
+			BlocksFound:    w.BlocksFound,
+			LastShare:      lastShare,
+		})
+	}
+	return result, nil
+}
+
 // handleGetTxOut handles gettxout commands.
 func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetTxOutCmd)
@@ -2814,6 +3254,22 @@ func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return nil, nil
 }
 
+// handlePrioritiseTransaction implements the prioritisetransaction command.
+func handlePrioritiseTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.PrioritiseTransactionCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	// The delta is kept even when the transaction is not in the pool so it
+	// is applied once the transaction arrives.
+	s.cfg.TxMemPool.PrioritiseTransaction(txHash, c.FeeDelta)
+
+	return true, nil
+}
+
 // retrievedTx represents a transaction that was either loaded from the
 // transaction memory pool or from the database.  When a transaction is loaded
 // from the database, it is loaded with the raw serialized bytes while the
@@ -3036,6 +3492,18 @@ func fetchMempoolTxnsForAddress(s *rpcServer, addr eacutil.Address, numToSkip, n
 	return mpTxns[numToSkip:rangeEnd], numToSkip
 }
 
+// handleSaveMempool implements the savemempool command.
+func handleSaveMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if err := s.cfg.SaveMempool(); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: err.Error(),
+		}
+	}
+
+	return nil, nil
+}
+
 // handleSearchRawTransactions implements the searchrawtransactions command.
 func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if the address index is not enabled.
@@ -3379,7 +3847,8 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	// Also, since an error is being returned to the caller, ensure the
 	// transaction is removed from the memory pool.
 	if len(acceptedTxs) == 0 || !acceptedTxs[0].Tx.Hash().IsEqual(tx.Hash()) {
-		s.cfg.TxMemPool.RemoveTransaction(tx, true)
+		s.cfg.TxMemPool.RemoveTransaction(tx, true,
+			mempool.RemovalReasonUnknown)
 
 		errStr := fmt.Sprintf("transaction %v is not in accepted list",
 			tx.Hash())
@@ -3425,7 +3894,7 @@ func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	} else {
 		// Respond with an error if there are no addresses to pay the
 		// created blocks to.
-		if len(cfg.miningAddrs) == 0 {
+		if len(cfg.miningPayouts) == 0 {
 			return nil, &btcjson.RPCError{
 				Code: btcjson.ErrRPCInternal.Code,
 				Message: "No payment addresses specified " +
@@ -3440,6 +3909,35 @@ func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleSetMockTime implements the setmocktime command.
+func handleSetMockTime(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetMockTimeCmd)
+
+	// Faking the time is only acceptable on the regression test network
+	// since it changes how time dependent consensus rules are applied.
+	if s.cfg.ChainParams.Net != chaincfg.RegressionNetParams.Net {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCMisc,
+			Message: "setmocktime is for regression testing " +
+				"(--regtest mode) only",
+		}
+	}
+	if c.Timestamp < 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Mock time can not be negative",
+		}
+	}
+
+	// A timestamp of 0 goes back to using the local clock.
+	var mockTime time.Time
+	if c.Timestamp != 0 {
+		mockTime = time.Unix(c.Timestamp, 0)
+	}
+	blockchain.SetMockTime(mockTime)
+	return nil, nil
+}
+
 // handleStop implements the stop command.
 func handleStop(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	select {
@@ -3482,6 +3980,88 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleTestMempoolAccept implements the testmempoolaccept command.
+func handleTestMempoolAccept(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.TestMempoolAcceptCmd)
+
+	if len(c.RawTxns) == 0 || len(c.RawTxns) > maxTestMempoolAcceptTxns {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Number of transactions must be "+
+				"between 1 and %d", maxTestMempoolAcceptTxns),
+		}
+	}
+
+	// A zero maximum fee rate means the fee rate is not limited.
+	maxFeeRate, err := eacutil.NewAmount(*c.MaxFeeRate)
+	if err != nil || maxFeeRate < 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid maximum fee rate",
+		}
+	}
+
+	txns := make([]*eacutil.Tx, 0, len(c.RawTxns))
+	for _, hexStr := range c.RawTxns {
+		if len(hexStr)%2 != 0 {
+			hexStr = "0" + hexStr
+		}
+		serializedTx, err := hex.DecodeString(hexStr)
+		if err != nil {
+			return nil, rpcDecodeHexError(hexStr)
+		}
+		var msgTx wire.MsgTx
+		err = msgTx.Deserialize(bytes.NewReader(serializedTx))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDeserialization,
+				Message: "TX decode failed: " + err.Error(),
+			}
+		}
+		txns = append(txns, eacutil.NewTx(&msgTx))
+	}
+
+	results := s.cfg.TxMemPool.TestMempoolAccept(txns)
+	reply := make([]*btcjson.TestMempoolAcceptResult, 0, len(results))
+	for _, result := range results {
+		item := &btcjson.TestMempoolAcceptResult{
+			TxID:  result.Tx.Hash().String(),
+			WTxID: result.Tx.WitnessHash().String(),
+		}
+		reply = append(reply, item)
+
+		// Errors other than rule errors mean something went wrong
+		// rather than the transaction being rejected.
+		if result.Err != nil {
+			if _, ok := result.Err.(mempool.RuleError); !ok {
+				return nil, internalRPCError(result.Err.Error(),
+					"Unable to test transaction")
+			}
+			item.RejectReason = result.Err.Error()
+			continue
+		}
+
+		feeRate := result.Fee * 1000 / result.VSize
+		if maxFeeRate > 0 && feeRate > int64(maxFeeRate) {
+			item.RejectReason = fmt.Sprintf("max-fee-exceeded: fee "+
+				"rate %v/kvB is above the maximum of %v/kvB",
+				eacutil.Amount(feeRate), maxFeeRate)
+			continue
+		}
+
+		item.Allowed = true
+		item.VSize = int32(result.VSize)
+		item.Fees = &btcjson.TestMempoolAcceptFees{
+			Base: eacutil.Amount(result.Fee).ToBTC(),
+		}
+		for _, hash := range result.Conflicts {
+			item.Conflicts = append(item.Conflicts, hash.String())
+		}
+	}
+
+	return reply, nil
+}
+
 // handleUptime implements the uptime command.
 func handleUptime(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	return time.Now().Unix() - s.cfg.StartupTime, nil
@@ -3642,6 +4222,7 @@ type rpcServer struct {
 	limitauthsha           [sha256.Size]byte
 	ntfnMgr                *wsNotificationManager
 	numClients             int32
+	numRestClients         int32
 	statusLines            map[int]string
 	statusLock             sync.RWMutex
 	wg                     sync.WaitGroup
@@ -3749,6 +4330,24 @@ func (s *rpcServer) NotifyNewTransactions(txns []*mempool.TxDesc) {
 	}
 }
 
+// NotifyTxRemoved notifies websocket clients of the passed transaction removed
+// from the mempool along with the reason it was removed.
+func (s *rpcServer) NotifyTxRemoved(tx *eacutil.Tx, reason mempool.RemovalReason) {
+	s.ntfnMgr.NotifyMempoolTxRemoved(tx, reason)
+}
+
+// NotifyTxReplaced notifies websocket clients of the passed transaction which
+// replaced the passed transactions in the mempool.
+func (s *rpcServer) NotifyTxReplaced(txDesc *mempool.TxDesc, replaced []*mempool.TxDesc) {
+	s.ntfnMgr.NotifyMempoolTxReplaced(txDesc, replaced)
+}
+
+// NotifyDoubleSpend notifies websocket clients of the passed double spend of
+// transactions in the mempool.
+func (s *rpcServer) NotifyDoubleSpend(ds *mempool.DoubleSpend) {
+	s.ntfnMgr.NotifyMempoolDoubleSpend(ds)
+}
+
 // limitConnections responds with a 503 service unavailable and returns true if
 // adding another client would exceed the maximum allow RPC clients.
 //
@@ -4108,6 +4707,11 @@ func (s *rpcServer) Start() {
 		s.WebsocketHandler(ws, r.RemoteAddr, authenticated, isAdmin)
 	})
 
+	// Unauthenticated read-only REST endpoints.
+	if cfg.Rest {
+		rpcServeMux.HandleFunc(restPathPrefix, s.handleRestRequest)
+	}
+
 	for _, listener := range s.cfg.Listeners {
 		s.wg.Add(1)
 		go func(listener net.Listener) {
@@ -4164,6 +4768,10 @@ type rpcserverPeer interface {
 	// FeeFilter returns the requested current minimum fee rate for which
 	// transactions should be announced.
 	FeeFilter() int64
+
+	// MappedAS returns the autonomous system number the peer's address is
+	// mapped to by the asmap in use, or zero when it is not mapped.
+	MappedAS() uint32
 }
 
 // rpcserverConnManager represents a connection manager for use with the RPC
@@ -4289,9 +4897,11 @@ type rpcserverConfig struct {
 	//
 	// Generator produces block templates and the CPUMiner solves them using
 	// the CPU.  CPU mining is typically only useful for test purposes when
-	// doing regression or simulation testing.
-	Generator *mining.BlkTmplGenerator
-	CPUMiner  *cpuminer.CPUMiner
+	// doing regression or simulation testing.  StratumServer hands out
+	// work to external miners and is nil unless enabled.
+	Generator     *mining.BlkTmplGenerator
+	CPUMiner      *cpuminer.CPUMiner
+	StratumServer *stratum.Server
 
 	// These fields define any optional indexes the RPC server can make use
 	// of to provide additional data when queried.
@@ -4302,6 +4912,10 @@ type rpcserverConfig struct {
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
 	FeeEstimator *mempool.FeeEstimator
+
+	// SaveMempool writes the transactions in the mempool to disk so they
+	// are loaded on the next startup.
+	SaveMempool func() error
 }
 
 // newRPCServer returns a new instance of the rpcServer struct.
@@ -4309,7 +4923,7 @@ func newRPCServer(config *rpcserverConfig) (*rpcServer, error) {
 	rpc := rpcServer{
 		cfg:                    *config,
 		statusLines:            make(map[int]string),
-		gbtWorkState:           newGbtWorkState(config.TimeSource),
+		gbtWorkState:           newGbtWorkState(config.TimeSource, config.Generator.CoinbaseFlags()),
 		helpCacher:             newHelpCacher(),
 		requestProcessShutdown: make(chan struct{}),
 		quit:                   make(chan int),
@@ -124,12 +124,73 @@ var helpDescsEnUS = map[string]string{
 	"estimatefee--result0": "Estimated fee per kilobyte in satoshis for a block to " +
 		"be mined in the next NumBlocks blocks.",
 
+	// EstimateRawFeeCmd help.
+	"estimaterawfee--synopsis": "Estimate the fee per kilobyte in BTC required for a transaction to be mined " +
+		"within a certain number of blocks with each horizon of the fee estimator.\n" +
+		"Horizons which do not track the number of blocks are omitted.",
+	"estimaterawfee-conftarget": "The maximum number of blocks which can be generated before the transaction is mined",
+	"estimaterawfee-threshold":  "The fraction of transactions paying the fee rate which must have been mined within the number of blocks",
+
+	// EstimateRawFeeResult help.
+	"estimaterawfeeresult-short":  "The estimate of the short horizon, which tracks up to 120 blocks",
+	"estimaterawfeeresult-medium": "The estimate of the medium horizon, which tracks up to 480 blocks",
+	"estimaterawfeeresult-long":   "The estimate of the long horizon, which tracks up to 10080 blocks",
+
+	// EstimateRawFeeHorizonResult help.
+	"estimaterawfeehorizonresult-feerate": "The estimated fee per kilobyte in BTC (omitted if no fee rate meets the threshold)",
+	"estimaterawfeehorizonresult-decay":   "The factor the counts of the horizon decay by every block",
+	"estimaterawfeehorizonresult-scale":   "The number of blocks in every period tracked by the horizon",
+	"estimaterawfeehorizonresult-pass":    "The lowest range of fee rates which met the threshold",
+	"estimaterawfeehorizonresult-fail":    "The highest range of fee rates which did not meet the threshold",
+	"estimaterawfeehorizonresult-errors":  "Errors encountered while estimating",
+
+	// EstimateRawFeeBucket help.
+	"estimaterawfeebucket-startrange":     "The lowest fee per kilobyte in BTC of the range",
+	"estimaterawfeebucket-endrange":       "The highest fee per kilobyte in BTC of the range",
+	"estimaterawfeebucket-withintarget":   "The decayed number of transactions in the range mined within the number of blocks",
+	"estimaterawfeebucket-totalconfirmed": "The decayed number of transactions in the range mined at any time",
+	"estimaterawfeebucket-inmempool":      "The number of transactions in the range still in the memory pool after the number of blocks",
+	"estimaterawfeebucket-leftmempool":    "The decayed number of transactions in the range which left the memory pool without being mined",
+
+	// EstimateSmartFeeCmd help.
+	"estimatesmartfee--synopsis": "Estimate the fee per kilobyte in BTC required for a transaction to be mined " +
+		"within a certain number of blocks.",
+	"estimatesmartfee-conftarget": "The maximum number of blocks which can be generated before the transaction is mined",
+	"estimatesmartfee-estimatemode": "The estimate mode, either ECONOMICAL or CONSERVATIVE. " +
+		"Conservative estimates react slower to falling fees",
+
+	// EstimateSmartFeeResult help.
+	"estimatesmartfeeresult-feerate": "The estimated fee per kilobyte in BTC, never below the minimum fee of the memory pool (omitted on error)",
+	"estimatesmartfeeresult-errors":  "Errors encountered while estimating",
+	"estimatesmartfeeresult-blocks":  "The number of blocks the fee rate was estimated for, which is lower than requested when there is not enough data",
+
 	// GenerateCmd help
 	"generate--synopsis": "Generates a set number of blocks (simnet or regtest only) and returns a JSON\n" +
 		" array of their hashes.",
 	"generate-numblocks": "Number of blocks to generate",
 	"generate--result0":  "The hashes, in order, of blocks generated by the call",
 
+	// GenerateBlockCmd help
+	"generateblock--synopsis": "Generates a block (simnet or regtest only) which pays to the given address and\n" +
+		" includes exactly the given transactions in the given order.",
+	"generateblock-address":      "The address to pay the coinbase of the block to",
+	"generateblock-transactions": "Ids of transactions in the memory pool or hex-encoded raw transactions to include in the block",
+	"generateblock-timestamp":    "The timestamp of the block in seconds since 1 Jan 1970 GMT (default: the current time)",
+	"generateblock-version":      "The version of the block (default: the version based on the state of the rule change deployments)",
+
+	// GenerateBlockResult help.
+	"generateblockresult-hash": "The hash of the generated block",
+
+	// GenerateToAddressCmd help
+	"generatetoaddress--synopsis": "Generates a set number of blocks (simnet or regtest only) which pay to the given\n" +
+		" address and returns a JSON array of their hashes.",
+	"generatetoaddress-numblocks": "Number of blocks to generate",
+	"generatetoaddress-address":   "The address to pay the coinbases of the blocks to",
+	"generatetoaddress-maxtries":  "The maximum number of hashes to try, fewer blocks are generated once it is reached",
+	"generatetoaddress-timestamp": "The timestamp of the first block in seconds since 1 Jan 1970 GMT, each following block is one second later (default: the current time)",
+	"generatetoaddress-version":   "The version of the blocks (default: the version based on the state of the rule change deployments)",
+	"generatetoaddress--result0":  "The hashes, in order, of blocks generated by the call",
+
 	// GetAddedNodeInfoResultAddr help.
 	"getaddednodeinforesultaddr-address":   "The ip address for this DNS entry",
 	"getaddednodeinforesultaddr-connected": "The connection 'direction' (inbound/outbound/false)",
@@ -414,12 +475,61 @@ var helpDescsEnUS = map[string]string{
 	// GetInfoCmd help.
 	"getinfo--synopsis": "Returns a JSON object containing various state info.",
 
+	// GetMempoolAncestorsCmd help.
+	"getmempoolancestors--synopsis":   "Returns information about all of the unconfirmed ancestors of a transaction in the memory pool.",
+	"getmempoolancestors-txid":        "The hash of the transaction",
+	"getmempoolancestors-verbose":     "Returns JSON object when true or an array of transaction hashes when false",
+	"getmempoolancestors--condition0": "verbose=false",
+	"getmempoolancestors--condition1": "verbose=true",
+	"getmempoolancestors--result0":    "Array of transaction hashes",
+
+	// GetMempoolDescendantsCmd help.
+	"getmempooldescendants--synopsis":   "Returns information about all of the unconfirmed descendants of a transaction in the memory pool.",
+	"getmempooldescendants-txid":        "The hash of the transaction",
+	"getmempooldescendants-verbose":     "Returns JSON object when true or an array of transaction hashes when false",
+	"getmempooldescendants--condition0": "verbose=false",
+	"getmempooldescendants--condition1": "verbose=true",
+	"getmempooldescendants--result0":    "Array of transaction hashes",
+
+	// GetMempoolEntryCmd help.
+	"getmempoolentry--synopsis": "Returns information about a transaction in the memory pool.",
+	"getmempoolentry-txid":      "The hash of the transaction",
+
+	// GetMempoolEntryResult help.
+	"getmempoolentryresult-vsize":              "The virtual size of the transaction",
+	"getmempoolentryresult-size":               "Transaction size in bytes",
+	"getmempoolentryresult-weight":             "The transaction's weight (between vsize*4-3 and vsize*4)",
+	"getmempoolentryresult-fee":                "Transaction fee in bitcoins (deprecated, use fees.base)",
+	"getmempoolentryresult-modifiedfee":        "Transaction fee with fee deltas used for mining priority in bitcoins (deprecated, use fees.modified)",
+	"getmempoolentryresult-time":               "Local time transaction entered pool in seconds since 1 Jan 1970 GMT",
+	"getmempoolentryresult-height":             "Block height when transaction entered the pool",
+	"getmempoolentryresult-descendantcount":    "Number of in-mempool descendant transactions, including this one",
+	"getmempoolentryresult-descendantsize":     "Virtual size of in-mempool descendants, including this one",
+	"getmempoolentryresult-descendantfees":     "Fees of in-mempool descendants, including this one, in satoshis (deprecated, use fees.descendant)",
+	"getmempoolentryresult-ancestorcount":      "Number of in-mempool ancestor transactions, including this one",
+	"getmempoolentryresult-ancestorsize":       "Virtual size of in-mempool ancestors, including this one",
+	"getmempoolentryresult-ancestorfees":       "Fees of in-mempool ancestors, including this one, in satoshis (deprecated, use fees.ancestor)",
+	"getmempoolentryresult-wtxid":              "The hash of the serialized transaction including witness data",
+	"getmempoolentryresult-fees":               "Fees of the transaction and its relatives in bitcoins",
+	"getmempoolentryresult-depends":            "Unconfirmed transactions used as inputs for this transaction",
+	"getmempoolentryresult-spentby":            "Unconfirmed transactions spending outputs of this transaction",
+	"getmempoolentryresult-bip125-replaceable": "Whether this transaction could be replaced due to BIP125 (replace-by-fee)",
+
+	// MempoolFees help.
+	"mempoolfees-base":       "Transaction fee in bitcoins",
+	"mempoolfees-modified":   "Transaction fee with fee deltas used for mining priority in bitcoins",
+	"mempoolfees-ancestor":   "Fees of in-mempool ancestors, including this one, with fee deltas in bitcoins",
+	"mempoolfees-descendant": "Fees of in-mempool descendants, including this one, with fee deltas in bitcoins",
+
 	// GetMempoolInfoCmd help.
 	"getmempoolinfo--synopsis": "Returns memory pool information",
 
 	// GetMempoolInfoResult help.
-	"getmempoolinforesult-bytes": "Size in bytes of the mempool",
-	"getmempoolinforesult-size":  "Number of transactions in the mempool",
+	"getmempoolinforesult-bytes":         "Size in bytes of the mempool",
+	"getmempoolinforesult-size":          "Number of transactions in the mempool",
+	"getmempoolinforesult-maxmempool":    "Maximum size in bytes of the mempool",
+	"getmempoolinforesult-mempoolminfee": "Minimum fee rate in BTC/KB for a transaction to be accepted, raised above minrelaytxfee while the mempool is full",
+	"getmempoolinforesult-minrelaytxfee": "The minimum relay fee for non-free transactions in BTC/KB",
 
 	// GetMiningInfoResult help.
 	"getmininginforesult-blocks":             "Height of the latest best block",
@@ -474,6 +584,7 @@ var helpDescsEnUS = map[string]string{
 	"getpeerinforesult-banscore":       "The ban score",
 	"getpeerinforesult-feefilter":      "The requested minimum fee a transaction must have to be announced to the peer",
 	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
+	"getpeerinforesult-mapped_as":      "The autonomous system number the peer's address is mapped to by the asmap (omitted when not mapped)",
 
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
@@ -481,6 +592,7 @@ var helpDescsEnUS = map[string]string{
 	// GetRawMempoolVerboseResult help.
 	"getrawmempoolverboseresult-size":             "Transaction size in bytes",
 	"getrawmempoolverboseresult-fee":              "Transaction fee in bitcoins",
+	"getrawmempoolverboseresult-modifiedfee":      "Transaction fee with fee deltas used for mining priority in bitcoins",
 	"getrawmempoolverboseresult-time":             "Local time transaction entered pool in seconds since 1 Jan 1970 GMT",
 	"getrawmempoolverboseresult-height":           "Block height when transaction entered the pool",
 	"getrawmempoolverboseresult-startingpriority": "Priority when transaction entered the pool",
@@ -504,6 +616,25 @@ var helpDescsEnUS = map[string]string{
 	"getrawtransaction--condition1": "verbose=true",
 	"getrawtransaction--result0":    "Hex-encoded bytes of the serialized transaction",
 
+	// StratumWorkerResult help.
+	"stratumworkerresult-name":           "The name the worker authorized with",
+	"stratumworkerresult-connections":    "The number of connections authorized as the worker",
+	"stratumworkerresult-difficulty":     "The share difficulty most recently sent to the worker",
+	"stratumworkerresult-hashespersec":   "The hash rate of the worker estimated from its shares over the last 10 minutes",
+	"stratumworkerresult-acceptedshares": "The number of accepted shares",
+	"stratumworkerresult-rejectedshares": "The number of shares rejected as invalid, duplicate or below the share difficulty",
+	"stratumworkerresult-staleshares":    "The number of shares rejected for an unknown or outdated job",
+	"stratumworkerresult-blocksfound":    "The number of shares accepted as blocks",
+	"stratumworkerresult-lastshare":      "The time of the last accepted share in seconds since 1 Jan 1970 GMT (0 if none)",
+
+	// GetStratumInfoResult help.
+	"getstratuminforesult-connections":  "The number of connected miners",
+	"getstratuminforesult-hashespersec": "The combined hash rate of all workers",
+	"getstratuminforesult-workers":      "The workers which are connected or submitted shares recently, sorted by name",
+
+	// GetStratumInfoCmd help.
+	"getstratuminfo--synopsis": "Returns statistics about the miners connected to the built-in Stratum server.",
+
 	// GetTxOutResult help.
 	"gettxoutresult-bestblock":     "The block hash that contains the transaction output",
 	"gettxoutresult-confirmations": "The number of confirmations",
@@ -530,6 +661,16 @@ var helpDescsEnUS = map[string]string{
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
 
+	// PrioritiseTransactionCmd help.
+	"prioritisetransaction--synopsis": "Adds a fee delta to a transaction so it is accepted to the memory pool and mined as if it paid the modified fee.\n" +
+		"The delta is kept until the transaction is included in a block, even when the transaction is not in the memory pool yet.",
+	"prioritisetransaction-txid":     "The hash of the transaction",
+	"prioritisetransaction-feedelta": "The fee in satoshi to add to the fee of the transaction (may be negative)",
+	"prioritisetransaction--result0": "Always true",
+
+	// SaveMempoolCmd help.
+	"savemempool--synopsis": "Writes the transactions in the memory pool to mempool.dat in the data directory so they are loaded on the next startup.",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
@@ -559,6 +700,10 @@ var helpDescsEnUS = map[string]string{
 	"setgenerate-generate":     "Use true to enable generation, false to disable it",
 	"setgenerate-genproclimit": "The number of processors (cores) to limit generation to or -1 for default",
 
+	// SetMockTimeCmd help.
+	"setmocktime--synopsis": "Set the local time to the given timestamp for the consensus and policy rules (regtest only).",
+	"setmocktime-timestamp": "The timestamp in seconds since 1 Jan 1970 GMT, or 0 to go back to using the system time",
+
 	// StopCmd help.
 	"stop--synopsis": "Shutdown eacd.",
 	"stop--result0":  "The string 'eacd stopping.'",
@@ -612,12 +757,18 @@ var helpDescsEnUS = map[string]string{
 	"stopnotifyblocks--synopsis": "Cancel registered notifications for whenever a block is connected or disconnected from the main (best) chain.",
 
 	// NotifyNewTransactionsCmd help.
-	"notifynewtransactions--synopsis": "Send either a txaccepted or a txacceptedverbose notification when a new transaction is accepted into the mempool.",
+	"notifynewtransactions--synopsis": "Send either a txaccepted or a txacceptedverbose notification when a new transaction is accepted into the mempool and a txremoved notification when a transaction is removed from it.",
 	"notifynewtransactions-verbose":   "Specifies which type of notification to receive. If verbose is true, then the caller receives txacceptedverbose, otherwise the caller receives txaccepted",
 
 	// StopNotifyNewTransactionsCmd help.
 	"stopnotifynewtransactions--synopsis": "Stop sending either a txaccepted or a txacceptedverbose notification when a new transaction is accepted into the mempool.",
 
+	// NotifyReplacementsCmd help.
+	"notifyreplacements--synopsis": "Send a txreplaced notification with the hashes and fee rates of the replacing and replaced transactions when a transaction accepted into the mempool replaces transactions in it.",
+
+	// StopNotifyReplacementsCmd help.
+	"stopnotifyreplacements--synopsis": "Stop sending txreplaced notifications when transactions in the mempool are replaced.",
+
 	// NotifyReceivedCmd help.
 	"notifyreceived--synopsis": "Send a recvtx notification when a transaction added to mempool or appears in a newly-attached block contains a txout pkScript sending to any of the passed addresses.\n" +
 		"Matching outpoints are automatically registered for redeemingtx notifications.",
@@ -665,6 +816,24 @@ var helpDescsEnUS = map[string]string{
 	"rescannedblock-transactions": "List of matching transactions, serialized and hex-encoded.",
 
 	// Uptime help.
+	// TestMempoolAcceptCmd help.
+	"testmempoolaccept--synopsis": "Returns whether raw transactions would be accepted to the memory pool without submitting them.\n" +
+		"The transactions form an ordered package in which each transaction may spend the outputs of the transactions before it.",
+	"testmempoolaccept-rawtxns":    "Serialized, hex-encoded transactions",
+	"testmempoolaccept-maxfeerate": "Reject transactions paying a fee rate in BTC/kvB above this value, 0 to accept any fee rate",
+
+	// TestMempoolAcceptResult help.
+	"testmempoolacceptresult-txid":          "The hash of the transaction",
+	"testmempoolacceptresult-wtxid":         "The hash of the serialized transaction including witness data",
+	"testmempoolacceptresult-allowed":       "Whether the transaction would be accepted to the memory pool",
+	"testmempoolacceptresult-vsize":         "The virtual size of the transaction, only present when it would be accepted",
+	"testmempoolacceptresult-fees":          "The fees of the transaction, only present when it would be accepted",
+	"testmempoolacceptresult-conflicts":     "Transactions in the memory pool which would be replaced by the transaction",
+	"testmempoolacceptresult-reject-reason": "The reason the transaction would be rejected, only present when it would not be accepted",
+
+	// TestMempoolAcceptFees help.
+	"testmempoolacceptfees-base": "Transaction fee in bitcoins",
+
 	"uptime--synopsis": "Returns the total uptime of the server.",
 	"uptime--result0":  "The number of seconds that the server has been running",
 
@@ -693,7 +862,11 @@ var rpcResultTypes = map[string][]interface{}{
 	"decoderawtransaction":  {(*btcjson.TxRawDecodeResult)(nil)},
 	"decodescript":          {(*btcjson.DecodeScriptResult)(nil)},
 	"estimatefee":           {(*float64)(nil)},
+	"estimaterawfee":        {(*btcjson.EstimateRawFeeResult)(nil)},
+	"estimatesmartfee":      {(*btcjson.EstimateSmartFeeResult)(nil)},
 	"generate":              {(*[]string)(nil)},
+	"generateblock":         {(*btcjson.GenerateBlockResult)(nil)},
+	"generatetoaddress":     {(*[]string)(nil)},
 	"getaddednodeinfo":      {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
 	"getbestblock":          {(*btcjson.GetBestBlockResult)(nil)},
 	"getbestblockhash":      {(*string)(nil)},
@@ -712,6 +885,9 @@ var rpcResultTypes = map[string][]interface{}{
 	"gethashespersec":       {(*float64)(nil)},
 	"getheaders":            {(*[]string)(nil)},
 	"getinfo":               {(*btcjson.InfoChainResult)(nil)},
+	"getmempoolancestors":   {(*[]string)(nil), (*btcjson.GetMempoolEntryResult)(nil)},
+	"getmempooldescendants": {(*[]string)(nil), (*btcjson.GetMempoolEntryResult)(nil)},
+	"getmempoolentry":       {(*btcjson.GetMempoolEntryResult)(nil)},
 	"getmempoolinfo":        {(*btcjson.GetMempoolInfoResult)(nil)},
 	"getmininginfo":         {(*btcjson.GetMiningInfoResult)(nil)},
 	"getnettotals":          {(*btcjson.GetNetTotalsResult)(nil)},
@@ -719,15 +895,20 @@ var rpcResultTypes = map[string][]interface{}{
 	"getpeerinfo":           {(*[]btcjson.GetPeerInfoResult)(nil)},
 	"getrawmempool":         {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
 	"getrawtransaction":     {(*string)(nil), (*btcjson.TxRawResult)(nil)},
+	"getstratuminfo":        {(*btcjson.GetStratumInfoResult)(nil)},
 	"gettxout":              {(*btcjson.GetTxOutResult)(nil)},
 	"node":                  nil,
 	"help":                  {(*string)(nil), (*string)(nil)},
 	"ping":                  nil,
+	"prioritisetransaction": {(*bool)(nil)},
+	"savemempool":           nil,
 	"searchrawtransactions": {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
 	"sendrawtransaction":    {(*string)(nil)},
 	"setgenerate":           nil,
+	"setmocktime":           nil,
 	"stop":                  {(*string)(nil)},
 	"submitblock":           {nil, (*string)(nil)},
+	"testmempoolaccept":     {(*[]btcjson.TestMempoolAcceptResult)(nil)},
 	"uptime":                {(*int64)(nil)},
 	"validateaddress":       {(*btcjson.ValidateAddressChainResult)(nil)},
 	"verifychain":           {(*bool)(nil)},
@@ -743,6 +924,8 @@ var rpcResultTypes = map[string][]interface{}{
 	"stopnotifynewtransactions": nil,
 	"notifyreceived":            nil,
 	"stopnotifyreceived":        nil,
+	"notifyreplacements":        nil,
+	"stopnotifyreplacements":    nil,
 	"notifyspent":               nil,
 	"stopnotifyspent":           nil,
 	"rescan":                    nil,
@@ -26,6 +26,7 @@ import (
 	"github.com/eacsuite/eacd/chaincfg"
 	"github.com/eacsuite/eacd/chaincfg/chainhash"
 	"github.com/eacsuite/eacd/database"
+	"github.com/eacsuite/eacd/mempool"
 	"github.com/eacsuite/eacd/txscript"
 	"github.com/eacsuite/eacd/wire"
 	"github.com/eacsuite/eacutil"
@@ -68,10 +69,12 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 	"notifyblocks":              handleNotifyBlocks,
 	"notifynewtransactions":     handleNotifyNewTransactions,
 	"notifyreceived":            handleNotifyReceived,
+	"notifyreplacements":        handleNotifyReplacements,
 	"notifyspent":               handleNotifySpent,
 	"session":                   handleSession,
 	"stopnotifyblocks":          handleStopNotifyBlocks,
 	"stopnotifynewtransactions": handleStopNotifyNewTransactions,
+	"stopnotifyreplacements":    handleStopNotifyReplacements,
 	"stopnotifyspent":           handleStopNotifySpent,
 	"stopnotifyreceived":        handleStopNotifyReceived,
 	"rescan":                    handleRescan,
@@ -246,6 +249,63 @@ func (m *wsNotificationManager) NotifyMempoolTx(tx *eacutil.Tx, isNew bool) {
 	}
 }
 
+// NotifyMempoolTxRemoved passes a transaction removed from the mempool along
+// with the reason it was removed to the notification manager for transaction
+// notification processing.
+func (m *wsNotificationManager) NotifyMempoolTxRemoved(tx *eacutil.Tx,
+	reason mempool.RemovalReason) {
+
+	n := &notificationTxRemovedFromMempool{
+		tx:     tx,
+		reason: reason,
+	}
+
+	// As NotifyMempoolTxRemoved will be called by mempool and the RPC
+	// server may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun shutting
+	// down.
+	select {
+	case m.queueNotification <- n:
+	case <-m.quit:
+	}
+}
+
+// NotifyMempoolTxReplaced passes a transaction accepted by the mempool along
+// with the transactions it replaced to the notification manager for
+// replacement notification processing.
+func (m *wsNotificationManager) NotifyMempoolTxReplaced(txDesc *mempool.TxDesc,
+	replaced []*mempool.TxDesc) {
+
+	n := &notificationTxReplacedInMempool{
+		txDesc:   txDesc,
+		replaced: replaced,
+	}
+
+	// As NotifyMempoolTxReplaced will be called by mempool and the RPC
+	// server may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun shutting
+	// down.
+	select {
+	case m.queueNotification <- n:
+	case <-m.quit:
+	}
+}
+
+// NotifyMempoolDoubleSpend passes a double spend of transactions in the
+// mempool to the notification manager for notification processing.
+func (m *wsNotificationManager) NotifyMempoolDoubleSpend(ds *mempool.DoubleSpend) {
+	n := (*notificationDoubleSpend)(ds)
+
+	// As NotifyMempoolDoubleSpend will be called by mempool and the RPC
+	// server may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun shutting
+	// down.
+	select {
+	case m.queueNotification <- n:
+	case <-m.quit:
+	}
+}
+
 // wsClientFilter tracks relevant addresses for each websocket client for
 // the `rescanblocks` extension. It is modified by the `loadtxfilter` command.
 //
@@ -451,6 +511,15 @@ type notificationTxAcceptedByMempool struct {
 	isNew bool
 	tx    *eacutil.Tx
 }
+type notificationTxRemovedFromMempool struct {
+	tx     *eacutil.Tx
+	reason mempool.RemovalReason
+}
+type notificationDoubleSpend mempool.DoubleSpend
+type notificationTxReplacedInMempool struct {
+	txDesc   *mempool.TxDesc
+	replaced []*mempool.TxDesc
+}
 
 // Notification control requests
 type notificationRegisterClient wsClient
@@ -459,6 +528,8 @@ type notificationRegisterBlocks wsClient
 type notificationUnregisterBlocks wsClient
 type notificationRegisterNewMempoolTxs wsClient
 type notificationUnregisterNewMempoolTxs wsClient
+type notificationRegisterReplacements wsClient
+type notificationUnregisterReplacements wsClient
 type notificationRegisterSpent struct {
 	wsc *wsClient
 	ops []*wire.OutPoint
@@ -491,6 +562,7 @@ func (m *wsNotificationManager) notificationHandler() {
 	// since it is quite a bit more efficient than using the entire struct.
 	blockNotifications := make(map[chan struct{}]*wsClient)
 	txNotifications := make(map[chan struct{}]*wsClient)
+	replacementNotifications := make(map[chan struct{}]*wsClient)
 	watchedOutPoints := make(map[wire.OutPoint]map[chan struct{}]*wsClient)
 	watchedAddrs := make(map[string]map[chan struct{}]*wsClient)
 
@@ -539,6 +611,22 @@ out:
 				m.notifyForTx(watchedOutPoints, watchedAddrs, n.tx, nil)
 				m.notifyRelevantTxAccepted(n.tx, clients)
 
+			case *notificationTxRemovedFromMempool:
+				if len(txNotifications) != 0 {
+					m.notifyTxRemoved(txNotifications, n.tx,
+						n.reason)
+				}
+
+			case *notificationDoubleSpend:
+				m.notifyRelevantDoubleSpend((*mempool.DoubleSpend)(n),
+					clients)
+
+			case *notificationTxReplacedInMempool:
+				if len(replacementNotifications) != 0 {
+					m.notifyTxReplaced(replacementNotifications,
+						n.txDesc, n.replaced)
+				}
+
 			case *notificationRegisterBlocks:
 				wsc := (*wsClient)(n)
 				blockNotifications[wsc.quit] = wsc
@@ -557,6 +645,7 @@ out:
 				// the client itself.
 				delete(blockNotifications, wsc.quit)
 				delete(txNotifications, wsc.quit)
+				delete(replacementNotifications, wsc.quit)
 				for k := range wsc.spentRequests {
 					op := k
 					m.removeSpentRequest(watchedOutPoints, wsc, &op)
@@ -586,6 +675,14 @@ out:
 				wsc := (*wsClient)(n)
 				delete(txNotifications, wsc.quit)
 
+			case *notificationRegisterReplacements:
+				wsc := (*wsClient)(n)
+				replacementNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterReplacements:
+				wsc := (*wsClient)(n)
+				delete(replacementNotifications, wsc.quit)
+
 			default:
 				rpcsLog.Warn("Unhandled notification type")
 			}
@@ -868,6 +965,66 @@ func (m *wsNotificationManager) notifyForNewTx(clients map[chan struct{}]*wsClie
 	}
 }
 
+// notifyTxRemoved notifies websocket clients that have registered for updates
+// when new transactions are added to the memory pool that a transaction was
+// removed from it.
+func (m *wsNotificationManager) notifyTxRemoved(clients map[chan struct{}]*wsClient,
+	tx *eacutil.Tx, reason mempool.RemovalReason) {
+
+	ntfn := btcjson.NewTxRemovedNtfn(tx.Hash().String(), reason.String())
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal tx removed notification: %v",
+			err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// RegisterReplacementUpdates requests notifications to the passed websocket
+// client when transactions in the memory pool are replaced.
+func (m *wsNotificationManager) RegisterReplacementUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterReplacements)(wsc)
+}
+
+// UnregisterReplacementUpdates removes notifications to the passed websocket
+// client when transactions in the memory pool are replaced.
+func (m *wsNotificationManager) UnregisterReplacementUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterReplacements)(wsc)
+}
+
+// notifyTxReplaced notifies websocket clients that have registered for
+// replacement updates that a transaction accepted into the memory pool
+// replaced the passed transactions.  The fee rates are reported in BTC/kB of
+// virtual size.
+func (m *wsNotificationManager) notifyTxReplaced(clients map[chan struct{}]*wsClient,
+	txDesc *mempool.TxDesc, replaced []*mempool.TxDesc) {
+
+	txFeeRate := func(desc *mempool.TxDesc) btcjson.TxFeeRate {
+		return btcjson.TxFeeRate{
+			TxID:    desc.Tx.Hash().String(),
+			FeeRate: eacutil.Amount(desc.FeePerKB).ToBTC(),
+		}
+	}
+	replacedFeeRates := make([]btcjson.TxFeeRate, 0, len(replaced))
+	for _, desc := range replaced {
+		replacedFeeRates = append(replacedFeeRates, txFeeRate(desc))
+	}
+
+	ntfn := btcjson.NewTxReplacedNtfn(txFeeRate(txDesc), replacedFeeRates)
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal tx replaced notification: %v",
+			err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // RegisterSpentRequests requests a notification when each of the passed
 // outpoints is confirmed spent (contained in a block connected to the main
 // chain) for the passed websocket client.  The request is automatically
@@ -1058,6 +1215,83 @@ func (m *wsNotificationManager) notifyRelevantTxAccepted(tx *eacutil.Tx,
 	}
 }
 
+// notifyRelevantDoubleSpend sends a relevantdoublespend notification to the
+// websocket clients whose transaction filter matches the passed double spend.
+// A double spend matches when it spends a watched outpoint, or when it or any
+// of the transactions in the mempool it conflicts with pays to a watched
+// address.  Unlike for accepted transactions, the filters are not updated.
+func (m *wsNotificationManager) notifyRelevantDoubleSpend(ds *mempool.DoubleSpend,
+	clients map[chan struct{}]*wsClient) {
+
+	txns := append([]*eacutil.Tx{ds.Tx}, ds.Conflicts...)
+	clientsToNotify := make(map[chan struct{}]struct{})
+	for quitChan, wsc := range clients {
+		wsc.Lock()
+		filter := wsc.filterData
+		wsc.Unlock()
+		if filter == nil {
+			continue
+		}
+
+		filter.mu.Lock()
+		if filterMatchesDoubleSpend(filter, txns,
+			m.server.cfg.ChainParams) {
+
+			clientsToNotify[quitChan] = struct{}{}
+		}
+		filter.mu.Unlock()
+	}
+	if len(clientsToNotify) == 0 {
+		return
+	}
+
+	conflicts := make([]string, 0, len(ds.Conflicts))
+	for _, conflict := range ds.Conflicts {
+		conflicts = append(conflicts, conflict.Hash().String())
+	}
+	n := btcjson.NewRelevantDoubleSpendNtfn(txHexString(ds.Tx.MsgTx()),
+		conflicts, ds.Time.Unix())
+	marshalled, err := btcjson.MarshalCmd(nil, n)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal notification: %v", err)
+		return
+	}
+	for quitChan := range clientsToNotify {
+		clients[quitChan].QueueNotification(marshalled)
+	}
+}
+
+// filterMatchesDoubleSpend returns whether any of the passed transactions, a
+// double spend followed by the transactions it conflicts with, spends an
+// outpoint or pays to an address watched by the passed filter.
+//
+// The filter must be locked by the caller.
+func filterMatchesDoubleSpend(filter *wsClientFilter, txns []*eacutil.Tx,
+	params *chaincfg.Params) bool {
+
+	for _, tx := range txns {
+		for _, input := range tx.MsgTx().TxIn {
+			if filter.existsUnspentOutPoint(&input.PreviousOutPoint) {
+				return true
+			}
+		}
+		for _, output := range tx.MsgTx().TxOut {
+			_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+				output.PkScript, params)
+			if err != nil {
+				continue
+			}
+			for _, a := range addrs {
+				if filter.existsAddress(a) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
 // notifyForTx examines the inputs and outputs of the passed transaction,
 // notifying websocket clients of outputs spending to a watched address
 // and inputs spending a watched outpoint.
@@ -1882,6 +2116,20 @@ func handleStopNotifyNewTransactions(wsc *wsClient, icmd interface{}) (interface
 	return nil, nil
 }
 
+// handleNotifyReplacements implements the notifyreplacements command extension
+// for websocket connections.
+func handleNotifyReplacements(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.RegisterReplacementUpdates(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyReplacements implements the stopnotifyreplacements command
+// extension for websocket connections.
+func handleStopNotifyReplacements(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterReplacementUpdates(wsc)
+	return nil, nil
+}
+
 // handleNotifyReceived implements the notifyreceived command extension for
 // websocket connections.
 func handleNotifyReceived(wsc *wsClient, icmd interface{}) (interface{}, error) {
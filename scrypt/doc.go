@@ -0,0 +1,23 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package scrypt implements the scrypt key derivation function with the N=1024,
+r=1, p=1 parameters used as the proof of work hash of block headers.
+
+The general purpose implementation of scrypt allocates its 128 KiB scratchpad
+every time a hash is calculated.  Hasher instead owns its scratch state, so it
+can be reused for any number of hashes without allocating.  Hashers are not
+safe for concurrent access, so every goroutine which hashes repeatedly, such as
+a mining worker, should use its own.  The Sum function hashes with hashers
+taken from a pool for callers which hash occasionally.
+
+Block headers are 80 bytes long and the nonce is in their last 4 bytes, so the
+SumHeader method of Hasher caches the state of the SHA-256 hash of the first 64
+bytes of a header and reuses it while only the following bytes change.
+
+The Salsa20/8 core is implemented with SSE2 instructions on amd64 and in Go on
+other architectures.
+*/
+package scrypt
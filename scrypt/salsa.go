@@ -0,0 +1,128 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package scrypt
+
+// The words of the blocks passed to the Salsa20/8 core are permuted so that
+// word i holds word 5*i mod 16 of the original block.  This puts the diagonals
+// of the 4x4 Salsa20 matrix into the four 128-bit lanes used by the SSE2 core,
+// which then processes columns and rows by rotating the lanes instead of
+// gathering words.
+
+// salsa8Generic applies the Salsa20/8 core to the passed permuted block of 16
+// words.
+func salsa8Generic(b []uint32) {
+	_ = b[15] // Bounds check hint to compiler.
+	x0, x1, x2, x3 := b[0], b[13], b[10], b[7]
+	x4, x5, x6, x7 := b[4], b[1], b[14], b[11]
+	x8, x9, x10, x11 := b[8], b[5], b[2], b[15]
+	x12, x13, x14, x15 := b[12], b[9], b[6], b[3]
+
+	for i := 0; i < 8; i += 2 {
+		// Columns.
+		u := x0 + x12
+		x4 ^= u<<7 | u>>(32-7)
+		u = x4 + x0
+		x8 ^= u<<9 | u>>(32-9)
+		u = x8 + x4
+		x12 ^= u<<13 | u>>(32-13)
+		u = x12 + x8
+		x0 ^= u<<18 | u>>(32-18)
+
+		u = x5 + x1
+		x9 ^= u<<7 | u>>(32-7)
+		u = x9 + x5
+		x13 ^= u<<9 | u>>(32-9)
+		u = x13 + x9
+		x1 ^= u<<13 | u>>(32-13)
+		u = x1 + x13
+		x5 ^= u<<18 | u>>(32-18)
+
+		u = x10 + x6
+		x14 ^= u<<7 | u>>(32-7)
+		u = x14 + x10
+		x2 ^= u<<9 | u>>(32-9)
+		u = x2 + x14
+		x6 ^= u<<13 | u>>(32-13)
+		u = x6 + x2
+		x10 ^= u<<18 | u>>(32-18)
+
+		u = x15 + x11
+		x3 ^= u<<7 | u>>(32-7)
+		u = x3 + x15
+		x7 ^= u<<9 | u>>(32-9)
+		u = x7 + x3
+		x11 ^= u<<13 | u>>(32-13)
+		u = x11 + x7
+		x15 ^= u<<18 | u>>(32-18)
+
+		// Rows.
+		u = x0 + x3
+		x1 ^= u<<7 | u>>(32-7)
+		u = x1 + x0
+		x2 ^= u<<9 | u>>(32-9)
+		u = x2 + x1
+		x3 ^= u<<13 | u>>(32-13)
+		u = x3 + x2
+		x0 ^= u<<18 | u>>(32-18)
+
+		u = x5 + x4
+		x6 ^= u<<7 | u>>(32-7)
+		u = x6 + x5
+		x7 ^= u<<9 | u>>(32-9)
+		u = x7 + x6
+		x4 ^= u<<13 | u>>(32-13)
+		u = x4 + x7
+		x5 ^= u<<18 | u>>(32-18)
+
+		u = x10 + x9
+		x11 ^= u<<7 | u>>(32-7)
+		u = x11 + x10
+		x8 ^= u<<9 | u>>(32-9)
+		u = x8 + x11
+		x9 ^= u<<13 | u>>(32-13)
+		u = x9 + x8
+		x10 ^= u<<18 | u>>(32-18)
+
+		u = x15 + x14
+		x12 ^= u<<7 | u>>(32-7)
+		u = x12 + x15
+		x13 ^= u<<9 | u>>(32-9)
+		u = x13 + x12
+		x14 ^= u<<13 | u>>(32-13)
+		u = x14 + x13
+		x15 ^= u<<18 | u>>(32-18)
+	}
+
+	b[0] += x0
+	b[13] += x1
+	b[10] += x2
+	b[7] += x3
+	b[4] += x4
+	b[1] += x5
+	b[14] += x6
+	b[11] += x7
+	b[8] += x8
+	b[5] += x9
+	b[2] += x10
+	b[15] += x11
+	b[12] += x12
+	b[9] += x13
+	b[6] += x14
+	b[3] += x15
+}
+
+// blockMixGeneric runs the BlockMix function of scrypt with a block size
+// parameter of 1 on the passed block of two permuted halves.
+func blockMixGeneric(b *[blockWords]uint32) {
+	b0, b1 := b[:16], b[16:]
+	for i := range b0 {
+		b0[i] ^= b1[i]
+	}
+	salsa8Generic(b0)
+	for i := range b1 {
+		b1[i] ^= b0[i]
+	}
+	salsa8Generic(b1)
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build amd64,!appengine,!gccgo
+
+#include "textflag.h"
+
+// SALSA_STEP adds a and b, rotates the sum left by rot bits and xors it into c.
+// X12 and X13 are clobbered.
+#define SALSA_STEP(a, b, c, rot, inv) \
+	MOVO  a, X12;        \
+	PADDL b, X12;        \
+	MOVO  X12, X13;      \
+	PSLLL $rot, X12;     \
+	PSRLL $inv, X13;     \
+	PXOR  X12, c;        \
+	PXOR  X13, c
+
+// SALSA_DOUBLE_ROUND runs a column and a row round on the permuted block held
+// in r0 to r3.  The lanes of r1 to r3 are rotated to line the rows up with the
+// diagonals in r0 and rotated back afterwards.
+#define SALSA_DOUBLE_ROUND(r0, r1, r2, r3) \
+	SALSA_STEP(r0, r3, r1, 7, 25);     \
+	SALSA_STEP(r1, r0, r2, 9, 23);     \
+	SALSA_STEP(r2, r1, r3, 13, 19);    \
+	SALSA_STEP(r3, r2, r0, 18, 14);    \
+	PSHUFL $0x93, r1, r1;              \
+	PSHUFL $0x4E, r2, r2;              \
+	PSHUFL $0x39, r3, r3;              \
+	SALSA_STEP(r0, r1, r3, 7, 25);     \
+	SALSA_STEP(r3, r0, r2, 9, 23);     \
+	SALSA_STEP(r2, r3, r1, 13, 19);    \
+	SALSA_STEP(r1, r2, r0, 18, 14);    \
+	PSHUFL $0x39, r1, r1;              \
+	PSHUFL $0x4E, r2, r2;              \
+	PSHUFL $0x93, r3, r3
+
+// SALSA8 applies the Salsa20/8 core to the permuted block held in r0 to r3.
+// X8 to X13 are clobbered.
+#define SALSA8(r0, r1, r2, r3) \
+	MOVO  r0, X8;                       \
+	MOVO  r1, X9;                       \
+	MOVO  r2, X10;                      \
+	MOVO  r3, X11;                      \
+	SALSA_DOUBLE_ROUND(r0, r1, r2, r3); \
+	SALSA_DOUBLE_ROUND(r0, r1, r2, r3); \
+	SALSA_DOUBLE_ROUND(r0, r1, r2, r3); \
+	SALSA_DOUBLE_ROUND(r0, r1, r2, r3); \
+	PADDL X8, r0;                       \
+	PADDL X9, r1;                       \
+	PADDL X10, r2;                      \
+	PADDL X11, r3
+
+// func blockMix(b *[blockWords]uint32)
+TEXT ·blockMix(SB), NOSPLIT, $0-8
+	MOVQ b+0(FP), DI
+
+	MOVOU 0(DI), X0
+	MOVOU 16(DI), X1
+	MOVOU 32(DI), X2
+	MOVOU 48(DI), X3
+	MOVOU 64(DI), X4
+	MOVOU 80(DI), X5
+	MOVOU 96(DI), X6
+	MOVOU 112(DI), X7
+
+	// First half: salsa(b0 ^ b1).
+	PXOR X4, X0
+	PXOR X5, X1
+	PXOR X6, X2
+	PXOR X7, X3
+	SALSA8(X0, X1, X2, X3)
+
+	// Second half: salsa(b1 ^ b0').
+	PXOR X0, X4
+	PXOR X1, X5
+	PXOR X2, X6
+	PXOR X3, X7
+	SALSA8(X4, X5, X6, X7)
+
+	MOVOU X0, 0(DI)
+	MOVOU X1, 16(DI)
+	MOVOU X2, 32(DI)
+	MOVOU X3, 48(DI)
+	MOVOU X4, 64(DI)
+	MOVOU X5, 80(DI)
+	MOVOU X6, 96(DI)
+	MOVOU X7, 112(DI)
+	RET
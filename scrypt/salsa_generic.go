@@ -0,0 +1,13 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build !amd64 appengine gccgo
+
+package scrypt
+
+// blockMix runs the BlockMix function of scrypt with a block size parameter of
+// 1 on the passed block of two permuted halves.
+func blockMix(b *[blockWords]uint32) {
+	blockMixGeneric(b)
+}
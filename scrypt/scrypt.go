@@ -0,0 +1,247 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package scrypt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding"
+	"encoding/binary"
+	"hash"
+	"sync"
+)
+
+const (
+	// HashSize is the size of the hashes calculated by the package.
+	HashSize = 32
+
+	// HeaderSize is the size of the serialized block headers which are
+	// hashed by SumHeader.
+	HeaderSize = 80
+
+	// n is the CPU/memory cost parameter of scrypt.
+	n = 1024
+
+	// blockWords is the number of 32-bit words of a mixed block, which is
+	// 128 bytes for a block size parameter of 1.
+	blockWords = 32
+
+	// hmacBlockSize is the block size of SHA-256 and therefore the size of
+	// the padded HMAC keys.
+	hmacBlockSize = sha256.BlockSize
+
+	// midstateSize is the number of bytes at the start of a header whose
+	// SHA-256 state is cached by SumHeader.
+	midstateSize = hmacBlockSize
+)
+
+// Hasher calculates scrypt hashes without allocating.  It holds the scratch
+// state of the calculation and the SHA-256 midstate of the last header hashed
+// with SumHeader.
+//
+// Hasher is not safe for concurrent access.
+type Hasher struct {
+	// v is the scratchpad which holds every intermediate block of the
+	// sequential memory-hard mixing.
+	v [n][blockWords]uint32
+
+	// x is the block being mixed.  Its words are kept in the order used by
+	// the Salsa20/8 core.
+	x [blockWords]uint32
+
+	// b holds the serialized block before and after mixing.
+	b [blockWords * 4]byte
+
+	// inner and outer are the hashes used for HMAC-SHA256, while ipad and
+	// opad hold the padded keys they start with.
+	inner hash.Hash
+	outer hash.Hash
+	ipad  [hmacBlockSize]byte
+	opad  [hmacBlockSize]byte
+
+	// digest and count hold the intermediate digests and the encoded
+	// block counters of the HMAC calculations, while result holds the
+	// final hash.  The hashes are only written to memory owned by the
+	// hasher so the buffers of callers don't escape to the heap.
+	digest [sha256.Size]byte
+	count  [4]byte
+	result [HashSize]byte
+
+	// header holds the last header hashed with SumHeader and midstate the
+	// state of the SHA-256 hash of its first bytes.
+	keyHash     hash.Hash
+	header      [HeaderSize]byte
+	midstate    []byte
+	hasMidstate bool
+}
+
+// NewHasher returns a new Hasher.  It uses 128 KiB of memory for its scratch
+// state.
+func NewHasher() *Hasher {
+	return &Hasher{
+		inner:   sha256.New(),
+		outer:   sha256.New(),
+		keyHash: sha256.New(),
+	}
+}
+
+// setKey prepares the HMAC pads for the passed key, which must not be longer
+// than the block size of SHA-256.
+func (h *Hasher) setKey(key []byte) {
+	for i := range h.ipad {
+		var k byte
+		if i < len(key) {
+			k = key[i]
+		}
+		h.ipad[i] = k ^ 0x36
+		h.opad[i] = k ^ 0x5c
+	}
+}
+
+// hmac writes the HMAC-SHA256 of the concatenation of the passed message and
+// the big-endian encoding of the passed counter to out using the key prepared
+// with setKey.  This is a single block of PBKDF2-HMAC-SHA256 with one
+// iteration.
+func (h *Hasher) hmac(out []byte, msg []byte, counter uint32) {
+	binary.BigEndian.PutUint32(h.count[:], counter)
+	h.inner.Reset()
+	h.inner.Write(h.ipad[:])
+	h.inner.Write(msg)
+	h.inner.Write(h.count[:])
+	h.inner.Sum(h.digest[:0])
+
+	h.outer.Reset()
+	h.outer.Write(h.opad[:])
+	h.outer.Write(h.digest[:])
+	h.outer.Sum(out[:0])
+}
+
+// smix runs the sequential memory-hard mixing function of scrypt on b.
+func (h *Hasher) smix() {
+	// Load the block with the words of both halves permuted into the order
+	// used by the Salsa20/8 core.
+	x := &h.x
+	for k := 0; k < 2; k++ {
+		for i := 0; i < 16; i++ {
+			offset := (k*16 + i*5%16) * 4
+			x[k*16+i] = binary.LittleEndian.Uint32(h.b[offset:])
+		}
+	}
+
+	v := &h.v
+	for i := 0; i < n; i++ {
+		v[i] = *x
+		blockMix(x)
+	}
+	for i := 0; i < n; i++ {
+		// The first word of the second half isn't moved by the
+		// permutation.
+		vj := &v[x[16]&(n-1)]
+		for k := range x {
+			x[k] ^= vj[k]
+		}
+		blockMix(x)
+	}
+
+	for k := 0; k < 2; k++ {
+		for i := 0; i < 16; i++ {
+			offset := (k*16 + i*5%16) * 4
+			binary.LittleEndian.PutUint32(h.b[offset:], x[k*16+i])
+		}
+	}
+}
+
+// sum writes the scrypt hash of the passed data used both as password and
+// salt to out.  The HMAC key must have already been prepared from the data.
+func (h *Hasher) sum(data []byte, out *[HashSize]byte) {
+	for i := 0; i < len(h.b)/sha256.Size; i++ {
+		h.hmac(h.b[i*sha256.Size:], data, uint32(i+1))
+	}
+	h.smix()
+	h.hmac(h.result[:], h.b[:], 1)
+	*out = h.result
+}
+
+// Sum writes the scrypt hash with N=1024, r=1, p=1 of the passed data used
+// both as password and salt to out.
+func (h *Hasher) Sum(data []byte, out *[HashSize]byte) {
+	// HMAC keys longer than the block size of the hash are hashed first.
+	key := data
+	if len(data) > hmacBlockSize {
+		h.keyHash.Reset()
+		h.keyHash.Write(data)
+		h.keyHash.Sum(h.digest[:0])
+		key = h.digest[:]
+	}
+	h.setKey(key)
+	h.sum(data, out)
+}
+
+// SumHeader writes the scrypt hash with N=1024, r=1, p=1 of the passed
+// serialized block header to out.  The hash is the same as the one calculated
+// by Sum, but the SHA-256 state of the first 64 bytes of the header is cached
+// and reused as long as they don't change, which is the case when only the
+// nonce or time of a header changes.
+func (h *Hasher) SumHeader(header *[HeaderSize]byte, out *[HashSize]byte) {
+	samePrefix := h.hasMidstate &&
+		bytes.Equal(h.header[:midstateSize], header[:midstateSize])
+	h.header = *header
+	if !samePrefix {
+		h.keyHash.Reset()
+		h.keyHash.Write(h.header[:midstateSize])
+		midstate, err := h.keyHash.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			// The SHA-256 implementation of the standard
+			// library never fails to marshal its state.
+			panic(err)
+		}
+		h.midstate = midstate
+		h.hasMidstate = true
+	} else {
+		err := h.keyHash.(encoding.BinaryUnmarshaler).UnmarshalBinary(h.midstate)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// The key of the HMAC is the hash of the header since headers are
+	// longer than the block size of SHA-256.
+	h.keyHash.Write(h.header[midstateSize:])
+	h.keyHash.Sum(h.digest[:0])
+	h.setKey(h.digest[:])
+	h.sum(h.header[:], out)
+}
+
+// hasherPool holds hashers for Sum so the scratch state is only allocated
+// when hashes are calculated concurrently.
+var hasherPool = sync.Pool{
+	New: func() interface{} {
+		return NewHasher()
+	},
+}
+
+// Sum returns the scrypt hash with N=1024, r=1, p=1 of the passed data used
+// both as password and salt.
+//
+// This function is safe for concurrent access.
+func Sum(data []byte) [HashSize]byte {
+	var out [HashSize]byte
+	h := hasherPool.Get().(*Hasher)
+	h.Sum(data, &out)
+	hasherPool.Put(h)
+	return out
+}
+
+// SumHeader returns the scrypt hash with N=1024, r=1, p=1 of the passed
+// serialized block header.
+//
+// This function is safe for concurrent access.
+func SumHeader(header *[HeaderSize]byte) [HashSize]byte {
+	var out [HashSize]byte
+	h := hasherPool.Get().(*Hasher)
+	h.SumHeader(header, &out)
+	hasherPool.Put(h)
+	return out
+}
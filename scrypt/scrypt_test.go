@@ -0,0 +1,182 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package scrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math/rand"
+	"testing"
+)
+
+// hexToBytes converts the passed hex string into bytes and will panic if there
+// is an error.  This is only provided for the hard-coded constants so errors in
+// the source code can be detected. It will only (and must only) be called with
+// hard-coded values.
+func hexToBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic("invalid hex in source file: " + s)
+	}
+	return b
+}
+
+// sumTests houses scrypt hashes with N=1024, r=1, p=1 of data used both as
+// password and salt.
+var sumTests = []struct {
+	data string
+	hash string
+}{
+	{
+		data: "",
+		hash: "b34ab7cd1ce0c308146ab970fa75517bcf20f95c7ed7a34efc0d5f096469b2e1",
+	},
+	{
+		data: "616263",
+		hash: "e652c1c3b7a8cd99d2edc49d4509f545c80e4395765e7225c4dde5d80dd76519",
+	},
+	{
+		data: "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+			"202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f" +
+			"404142434445464748494a4b4c4d4e4f",
+		hash: "bc540a1a801df96e493005c71e010e2d387607fbf0fec416fd3c2645aa1ba9d2",
+	},
+	{
+		data: "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f" +
+			"202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f" +
+			"404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f" +
+			"60616263",
+		hash: "3066fcb1d3a11e43139ac4340782e994e6fa7b4e02e5cfe6e9a2a308c59112df",
+	},
+}
+
+// TestSum ensures Sum and the Sum method of Hasher calculate the expected
+// hashes.
+func TestSum(t *testing.T) {
+	h := NewHasher()
+	for i, test := range sumTests {
+		data := hexToBytes(test.data)
+		want := hexToBytes(test.hash)
+
+		got := Sum(data)
+		if !bytes.Equal(got[:], want) {
+			t.Errorf("Sum #%d: got %x, want %x", i, got, want)
+		}
+
+		// Hash twice with the same hasher to ensure no state leaks
+		// between calculations.
+		for j := 0; j < 2; j++ {
+			h.Sum(data, &got)
+			if !bytes.Equal(got[:], want) {
+				t.Errorf("Hasher.Sum #%d (%d): got %x, want %x",
+					i, j, got, want)
+			}
+		}
+	}
+}
+
+// TestSumHeader ensures SumHeader calculates the same hashes as Sum while the
+// nonce and the cached prefix of the header change.
+func TestSumHeader(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	h := NewHasher()
+
+	var header [HeaderSize]byte
+	var got [HashSize]byte
+	for i := 0; i < 8; i++ {
+		// Change the prefix every few iterations and the nonce on
+		// every iteration.
+		if i%3 == 0 {
+			rng.Read(header[:])
+		}
+		binary.LittleEndian.PutUint32(header[76:], rng.Uint32())
+
+		want := Sum(header[:])
+		h.SumHeader(&header, &got)
+		if got != want {
+			t.Errorf("SumHeader #%d: got %x, want %x", i, got, want)
+		}
+	}
+}
+
+// TestBlockMix ensures the architecture specific BlockMix implementation
+// matches the generic one.
+func TestBlockMix(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 100; i++ {
+		var a, b [blockWords]uint32
+		for j := range a {
+			a[j] = rng.Uint32()
+		}
+		b = a
+		blockMix(&a)
+		blockMixGeneric(&b)
+		if a != b {
+			t.Fatalf("blockMix #%d: got %x, want %x", i, a, b)
+		}
+	}
+}
+
+// TestSumHeaderAllocs ensures hashing headers with a Hasher doesn't allocate.
+func TestSumHeaderAllocs(t *testing.T) {
+	h := NewHasher()
+	var header [HeaderSize]byte
+	var out [HashSize]byte
+	h.SumHeader(&header, &out)
+
+	allocs := testing.AllocsPerRun(10, func() {
+		header[76]++
+		h.SumHeader(&header, &out)
+	})
+	if allocs != 0 {
+		t.Fatalf("SumHeader allocated %v times per run", allocs)
+	}
+}
+
+// BenchmarkSumHeader benchmarks hashing headers which only differ in their
+// nonce with a Hasher.
+func BenchmarkSumHeader(b *testing.B) {
+	h := NewHasher()
+	var header [HeaderSize]byte
+	var out [HashSize]byte
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		binary.LittleEndian.PutUint32(header[76:], uint32(i))
+		h.SumHeader(&header, &out)
+	}
+}
+
+// BenchmarkSum benchmarks hashing headers with the pooled hashers of Sum.
+func BenchmarkSum(b *testing.B) {
+	var header [HeaderSize]byte
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		binary.LittleEndian.PutUint32(header[76:], uint32(i))
+		Sum(header[:])
+	}
+}
+
+// BenchmarkBlockMixGeneric benchmarks the generic BlockMix implementation for
+// comparison with the architecture specific one.
+func BenchmarkBlockMixGeneric(b *testing.B) {
+	var x [blockWords]uint32
+	for i := 0; i < b.N; i++ {
+		blockMixGeneric(&x)
+	}
+}
+
+// BenchmarkBlockMix benchmarks the BlockMix implementation used on the current
+// architecture.
+func BenchmarkBlockMix(b *testing.B) {
+	var x [blockWords]uint32
+	for i := 0; i < b.N; i++ {
+		blockMix(&x)
+	}
+}
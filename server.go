@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -32,10 +33,12 @@ import (
 	"github.com/eacsuite/eacd/mempool"
 	"github.com/eacsuite/eacd/mining"
 	"github.com/eacsuite/eacd/mining/cpuminer"
+	"github.com/eacsuite/eacd/mining/stratum"
 	"github.com/eacsuite/eacd/netsync"
 	"github.com/eacsuite/eacd/peer"
 	"github.com/eacsuite/eacd/txscript"
 	"github.com/eacsuite/eacd/wire"
+	"github.com/eacsuite/eacd/zmq"
 	"github.com/eacsuite/eacutil"
 	"github.com/eacsuite/eacutil/bloom"
 )
@@ -53,10 +56,22 @@ const (
 	// defaultTargetOutbound is the default number of outbound peers to target.
 	defaultTargetOutbound = 8
 
+	// defaultBlockRelayOutbound is the default number of block-relay-only
+	// outbound peers to target in addition to defaultTargetOutbound.
+	defaultBlockRelayOutbound = 2
+
 	// connectionRetryInterval is the base amount of time to wait in between
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// feeFilterInterval is the interval at which peers are sent an updated
+	// feefilter message when the minimum fee of the memory pool changed.
+	feeFilterInterval = time.Minute
+
+	// mempoolExpiryInterval is the interval at which transactions which
+	// have been in the memory pool for too long are removed.
+	mempoolExpiryInterval = time.Minute * 10
 )
 
 var (
@@ -204,6 +219,7 @@ type server struct {
 	shutdown      int32
 	shutdownSched int32
 	startupTime   int64
+	mempoolLoaded int32 // Whether the persisted mempool was loaded.
 
 	chainParams          *chaincfg.Params
 	addrManager          *addrmgr.AddrManager
@@ -214,7 +230,10 @@ type server struct {
 	syncManager          *netsync.SyncManager
 	chain                *blockchain.BlockChain
 	txMemPool            *mempool.TxPool
+	blkTmplGenerator     *mining.BlkTmplGenerator
 	cpuMiner             *cpuminer.CPUMiner
+	stratumServer        *stratum.Server
+	zmqServer            *zmq.Server
 	modifyRebroadcastInv chan interface{}
 	newPeers             chan *serverPeer
 	donePeers            chan *serverPeer
@@ -226,10 +245,15 @@ type server struct {
 	wg                   sync.WaitGroup
 	quit                 chan struct{}
 	nat                  NAT
+	onionTarget          string
 	db                   database.DB
 	timeSource           blockchain.MedianTimeSource
 	services             wire.ServiceFlag
 
+	// mempoolDumpMtx serializes writing the mempool file since it may be
+	// saved by the savemempool RPC while the server shuts down.
+	mempoolDumpMtx sync.Mutex
+
 	// The following fields are used for optional indexes.  They will be nil
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
@@ -262,11 +286,17 @@ type serverPeer struct {
 	// The following variables must only be used atomically
 	feeFilter int64
 
+	// sentFeeFilter is the minimum fee rate last sent to the peer in a
+	// feefilter message or -1 when none was sent.  It is only accessed
+	// from the peerHandler goroutine.
+	sentFeeFilter int64
+
 	*peer.Peer
 
 	connReq        *connmgr.ConnReq
 	server         *server
 	persistent     bool
+	blockRelayOnly bool
 	continueHash   *chainhash.Hash
 	relayMtx       sync.Mutex
 	disableRelayTx bool
@@ -290,6 +320,7 @@ func newServerPeer(s *server, isPersistent bool) *serverPeer {
 		persistent:     isPersistent,
 		filter:         bloom.LoadFilter(nil),
 		knownAddresses: make(map[string]struct{}),
+		sentFeeFilter:  -1,
 		quit:           make(chan struct{}),
 		txProcessed:    make(chan struct{}, 1),
 		blockProcessed: make(chan struct{}, 1),
@@ -330,23 +361,25 @@ func (sp *serverPeer) setDisableRelayTx(disable bool) {
 }
 
 // relayTxDisabled returns whether or not relaying of transactions for the given
-// peer is disabled.
+// peer is disabled.  Transactions are never relayed to block-relay-only peers.
 // It is safe for concurrent access.
 func (sp *serverPeer) relayTxDisabled() bool {
 	sp.relayMtx.Lock()
 	isDisabled := sp.disableRelayTx
 	sp.relayMtx.Unlock()
 
-	return isDisabled
+	return isDisabled || sp.blockRelayOnly
 }
 
 // pushAddrMsg sends an addr message to the connected peer using the provided
 // addresses.
 func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddress) {
-	// Filter addresses already known to the peer.
+	// Filter addresses already known to the peer.  I2P addresses are
+	// not shared either since addr messages can only hold part of their
+	// destination hash.
 	addrs := make([]*wire.NetAddress, 0, len(addresses))
 	for _, addr := range addresses {
-		if !sp.addressKnown(addr) {
+		if !sp.addressKnown(addr) && !addrmgr.IsI2P(addr) {
 			addrs = append(addrs, addr)
 		}
 	}
@@ -542,6 +575,11 @@ func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
 			msg.TxHash(), sp)
 		return
 	}
+	if sp.blockRelayOnly {
+		peerLog.Tracef("Ignoring tx %v from block-relay-only peer %v",
+			msg.TxHash(), sp)
+		return
+	}
 
 	// Add the transaction to the known inventory for the peer.
 	// Convert the raw MsgTx to a eacutil.Tx which provides some convenience
@@ -590,7 +628,7 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
-	if !cfg.BlocksOnly {
+	if !cfg.BlocksOnly && !sp.blockRelayOnly {
 		if len(msg.InvList) > 0 {
 			sp.server.syncManager.QueueInv(msg, sp.Peer)
 		}
@@ -601,7 +639,8 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 	for _, invVect := range msg.InvList {
 		if invVect.Type == wire.InvTypeTx {
 			peerLog.Tracef("Ignoring tx %v in inv from %v -- "+
-				"blocksonly enabled", invVect.Hash, sp)
+				"blocksonly enabled or block-relay-only peer",
+				invVect.Hash, sp)
 			if sp.ProtocolVersion() >= wire.BIP0037Version {
 				peerLog.Infof("Peer %v is announcing "+
 					"transactions -- disconnecting", sp)
@@ -1259,6 +1298,14 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		return
 	}
 
+	// Ignore addresses from block-relay-only peers since they are not
+	// supposed to participate in address relay.
+	if sp.blockRelayOnly {
+		peerLog.Debugf("Ignoring addr message from block-relay-only "+
+			"peer %v", sp)
+		return
+	}
+
 	// Ignore old style addresses which don't include a timestamp.
 	if sp.ProtocolVersion() < wire.NetAddressTimeVersion {
 		return
@@ -1397,6 +1444,23 @@ func (s *server) relayTransactions(txns []*mempool.TxDesc) {
 	}
 }
 
+// relayDoubleSpend relays the inventory vector of the passed double spend to
+// peers as proof of the attempt to spend the same outputs twice.  Peers request
+// the transaction like any other and it is served from the double spends
+// recorded by the mempool.
+func (s *server) relayDoubleSpend(ds *mempool.DoubleSpend) {
+	txD := &mempool.TxDesc{
+		TxDesc: mining.TxDesc{
+			Tx:       ds.Tx,
+			Added:    ds.Time,
+			Fee:      ds.Fee,
+			FeePerKB: ds.Fee * 1000 / mempool.GetTxVirtualSize(ds.Tx),
+		},
+	}
+	iv := wire.NewInvVect(wire.InvTypeTx, ds.Tx.Hash())
+	s.RelayInventory(iv, txD)
+}
+
 // AnnounceNewTransactions generates and relays inventory vectors and notifies
 // both websocket and getblocktemplate long poll clients of the passed
 // transactions.  This function should be called whenever new transactions
@@ -1434,6 +1498,13 @@ func (s *server) pushTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<-
 	// call could be made to check for existence first, but simply trying
 	// to fetch a missing transaction results in the same behavior.
 	tx, err := s.txMemPool.FetchTransaction(hash)
+	if err != nil {
+		// Double spends relayed as proof are not in the pool, so they
+		// are served from the ones recorded by the mempool.
+		if dsTx, dsErr := s.txMemPool.FetchDoubleSpend(hash); dsErr == nil {
+			tx, err = dsTx, nil
+		}
+	}
 	if err != nil {
 		peerLog.Tracef("Unable to fetch tx %v from transaction "+
 			"pool: %v", hash, err)
@@ -1665,7 +1736,7 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	if sp.Inbound() {
 		state.inboundPeers[sp.ID()] = sp
 	} else {
-		state.outboundGroups[addrmgr.GroupKey(sp.NA())]++
+		state.outboundGroups[s.addrManager.GroupKey(sp.NA())]++
 		if sp.persistent {
 			state.persistentPeers[sp.ID()] = sp
 		} else {
@@ -1686,12 +1757,14 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	// remote peer for outbound connections. This is skipped when running on
 	// the simulation test network since it is only intended to connect to
 	// specified peers and actively avoids advertising and connecting to
-	// discovered peers.
+	// discovered peers.  Addresses are neither advertised to nor requested
+	// from block-relay-only peers.
 	if !cfg.SimNet && !sp.Inbound() {
 		// Advertise the local address when the server accepts incoming
 		// connections and it believes itself to be close to the best
 		// known tip.
-		if !cfg.DisableListen && s.syncManager.IsCurrent() {
+		if !cfg.DisableListen && !sp.blockRelayOnly &&
+			s.syncManager.IsCurrent() {
 			// Get address that best matches.
 			lna := s.addrManager.GetBestLocalAddress(sp.NA())
 			if addrmgr.IsRoutable(lna) {
@@ -1705,7 +1778,8 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		// more and the peer has a protocol version new enough to
 		// include a timestamp with addresses.
 		hasTimestamp := sp.ProtocolVersion() >= wire.NetAddressTimeVersion
-		if s.addrManager.NeedMoreAddresses() && hasTimestamp {
+		if s.addrManager.NeedMoreAddresses() && hasTimestamp &&
+			!sp.blockRelayOnly {
 			sp.QueueMessage(wire.NewMsgGetAddr(), nil)
 		}
 
@@ -1713,6 +1787,9 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		s.addrManager.Good(sp.NA())
 	}
 
+	// Let the peer know the minimum fee of transactions to announce.
+	s.pushFeeFilter(sp, int64(s.txMemPool.MinFee()))
+
 	return true
 }
 
@@ -1736,13 +1813,13 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 			s.connManager.Disconnect(sp.connReq.ID())
 		} else {
 			s.connManager.Remove(sp.connReq.ID())
-			go s.connManager.NewConnReq()
+			go s.newOutboundConnReq(sp.connReq)
 		}
 	}
 
 	if _, ok := list[sp.ID()]; ok {
 		if !sp.Inbound() && sp.VersionKnown() {
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		}
 		delete(list, sp.ID())
 		srvrLog.Debugf("Removed peer %s", sp)
@@ -1848,6 +1925,39 @@ func (s *server) handleBroadcastMsg(state *peerState, bmsg *broadcastMsg) {
 	})
 }
 
+// pushFeeFilter sends a feefilter message with the passed minimum fee rate to
+// the peer unless it is close to the one sent to it before.  Peers which don't
+// support the message or which don't relay transactions are skipped.  It is
+// invoked from the peerHandler goroutine.
+func (s *server) pushFeeFilter(sp *serverPeer, minFee int64) {
+	if cfg.BlocksOnly || sp.blockRelayOnly ||
+		sp.ProtocolVersion() < wire.FeeFilterVersion {
+		return
+	}
+
+	// Only send the new fee rate if it changed by more than a quarter to
+	// avoid sending a message for every small change of the decaying
+	// minimum fee.
+	sent := sp.sentFeeFilter
+	if sent >= 0 && minFee*4 >= sent*3 && minFee*4 <= sent*5 {
+		return
+	}
+
+	sp.sentFeeFilter = minFee
+	sp.QueueMessage(wire.NewMsgFeeFilter(minFee), nil)
+}
+
+// handleFeeFilterUpdate sends the current minimum fee of the memory pool to
+// the peers it changed for.  It is invoked from the peerHandler goroutine.
+func (s *server) handleFeeFilterUpdate(state *peerState) {
+	minFee := int64(s.txMemPool.MinFee())
+	state.forAllPeers(func(sp *serverPeer) {
+		if sp.Connected() {
+			s.pushFeeFilter(sp, minFee)
+		}
+	})
+}
+
 type getConnCountMsg struct {
 	reply chan int32
 }
@@ -1938,7 +2048,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found := disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 
 		if found {
@@ -1974,7 +2084,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 		})
 		if found {
 			// If there are multiple outbound connections to the same
@@ -1982,7 +2092,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			// peers are found.
 			for found {
 				found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
-					state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+					state.outboundGroups[s.addrManager.GroupKey(sp.NA())]--
 				})
 			}
 			msg.reply <- nil
@@ -2058,7 +2168,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		UserAgentComments: cfg.UserAgentComments,
 		ChainParams:       sp.server.chainParams,
 		Services:          sp.server.services,
-		DisableRelayTx:    cfg.BlocksOnly,
+		DisableRelayTx:    cfg.BlocksOnly || sp.blockRelayOnly,
 		ProtocolVersion:   peer.MaxProtocolVersion,
 		TrickleInterval:   cfg.TrickleInterval,
 	}
@@ -2083,6 +2193,7 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp := newServerPeer(s, c.Permanent)
+	sp.blockRelayOnly = c.BlockRelayOnly
 	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
@@ -2090,7 +2201,7 @@ func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 			s.connManager.Disconnect(c.ID())
 		} else {
 			s.connManager.Remove(c.ID())
-			go s.connManager.NewConnReq()
+			go s.newOutboundConnReq(c)
 		}
 		return
 	}
@@ -2101,6 +2212,17 @@ func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	go s.peerDoneHandler(sp)
 }
 
+// newOutboundConnReq requests a new automatic outbound connection of the same
+// class, either full relay or block relay only, as the passed connection
+// request.
+func (s *server) newOutboundConnReq(c *connmgr.ConnReq) {
+	if c.BlockRelayOnly {
+		s.connManager.NewBlockRelayConnReq()
+		return
+	}
+	s.connManager.NewConnReq()
+}
+
 // peerDoneHandler handles peer disconnects by notifiying the server that it's
 // done along with other performing other desirable cleanup.
 func (s *server) peerDoneHandler(sp *serverPeer) {
@@ -2158,6 +2280,9 @@ func (s *server) peerHandler() {
 	}
 	go s.connManager.Start()
 
+	feeFilterTicker := time.NewTicker(feeFilterInterval)
+	defer feeFilterTicker.Stop()
+
 out:
 	for {
 		select {
@@ -2189,7 +2314,15 @@ out:
 		case qmsg := <-s.query:
 			s.handleQuery(state, qmsg)
 
+		// Tell peers about changes of the memory pool minimum fee.
+		case <-feeFilterTicker.C:
+			s.handleFeeFilterUpdate(state)
+
 		case <-s.quit:
+			// Persist the block-relay-only peers so they can be
+			// reconnected to first on the next startup.
+			s.saveAnchors(state)
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
@@ -2351,6 +2484,29 @@ cleanup:
 	s.wg.Done()
 }
 
+// mempoolExpiryHandler periodically removes the transactions which have been in
+// the memory pool for longer than the configured expiry.  It must be run as a
+// goroutine.
+func (s *server) mempoolExpiryHandler() {
+	ticker := time.NewTicker(mempoolExpiryInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			if n := s.txMemPool.ExpireTransactions(); n > 0 {
+				srvrLog.Infof("Removed %d expired transactions "+
+					"from the mempool", n)
+			}
+
+		case <-s.quit:
+			break out
+		}
+	}
+	s.wg.Done()
+}
+
 // Start begins accepting connections from peers.
 func (s *server) Start() {
 	// Already started?
@@ -2368,11 +2524,35 @@ func (s *server) Start() {
 	s.wg.Add(1)
 	go s.peerHandler()
 
+	// Load the transactions persisted by the previous session in the
+	// background since it can take a while.
+	if cfg.PersistMempool {
+		s.wg.Add(1)
+		go s.loadMempool()
+	} else {
+		atomic.StoreInt32(&s.mempoolLoaded, 1)
+	}
+
+	if cfg.MempoolExpiry > 0 {
+		s.wg.Add(1)
+		go s.mempoolExpiryHandler()
+	}
+
 	if s.nat != nil {
 		s.wg.Add(1)
 		go s.upnpUpdateThread()
 	}
 
+	if s.onionTarget != "" {
+		s.wg.Add(1)
+		go s.onionServiceThread()
+	}
+
+	if cfg.i2pSession != nil {
+		s.wg.Add(1)
+		go s.i2pSessionThread()
+	}
+
 	if !cfg.DisableRPC {
 		s.wg.Add(1)
 
@@ -2387,6 +2567,16 @@ func (s *server) Start() {
 	if cfg.Generate {
 		s.cpuMiner.Start()
 	}
+
+	// Start the Stratum server if it is enabled.
+	if s.stratumServer != nil {
+		s.stratumServer.Start()
+	}
+
+	// Start the ZeroMQ server if it is enabled.
+	if s.zmqServer != nil {
+		s.zmqServer.Start()
+	}
 }
 
 // Stop gracefully shuts down the server by stopping and disconnecting all
@@ -2403,6 +2593,16 @@ func (s *server) Stop() error {
 	// Stop the CPU miner if needed
 	s.cpuMiner.Stop()
 
+	// Stop the Stratum server if it is enabled.
+	if s.stratumServer != nil {
+		s.stratumServer.Stop()
+	}
+
+	// Stop the ZeroMQ server if it is enabled.
+	if s.zmqServer != nil {
+		s.zmqServer.Stop()
+	}
+
 	// Shutdown the RPC server if it's not disabled.
 	if !cfg.DisableRPC {
 		s.rpcServer.Stop()
@@ -2416,6 +2616,17 @@ func (s *server) Stop() error {
 		return nil
 	})
 
+	// Save the mempool so it is loaded again on the next startup.
+	if cfg.PersistMempool {
+		err := s.saveMempool()
+		switch {
+		case err == errMempoolNotLoaded:
+			srvrLog.Warnf("Not saving mempool: %v", err)
+		case err != nil:
+			srvrLog.Errorf("Unable to save mempool: %v", err)
+		}
+	}
+
 	// Signal the remaining goroutines to quit.
 	close(s.quit)
 	return nil
@@ -2512,7 +2723,8 @@ func parseListeners(addrs []string) ([]net.Addr, error) {
 
 func (s *server) upnpUpdateThread() {
 	// Go off immediately to prevent code duplication, thereafter we renew
-	// lease every 15 minutes.
+	// lease every 15 minutes, or before it expires when the gateway
+	// granted a shorter lease.
 	timer := time.NewTimer(0 * time.Second)
 	lport, _ := strconv.ParseInt(activeNetParams.DefaultPort, 10, 16)
 	first := true
@@ -2520,6 +2732,8 @@ out:
 	for {
 		select {
 		case <-timer.C:
+			renew := time.Minute * 15
+
 			// TODO: pick external port  more cleverly
 			// TODO: know which ports we are listening to on an external net.
 			// TODO: if specific listen port doesn't work then ask for wildcard
@@ -2528,14 +2742,20 @@ out:
 			listenPort, err := s.nat.AddPortMapping("tcp", int(lport), int(lport),
 				"eacd listen port", 20*60)
 			if err != nil {
-				srvrLog.Warnf("can't add UPnP port mapping: %v", err)
+				srvrLog.Warnf("can't add port mapping: %v", err)
+			} else if l, ok := s.nat.(leasedNAT); ok {
+				lease := l.leaseDuration() / 2
+				if lease > 0 && lease < renew {
+					renew = lease
+				}
 			}
 			if first && err == nil {
 				// TODO: look this up periodically to see if upnp domain changed
 				// and so did ip.
 				externalip, err := s.nat.GetExternalAddress()
 				if err != nil {
-					srvrLog.Warnf("UPnP can't get external address: %v", err)
+					srvrLog.Warnf("can't get external address: %v", err)
+					timer.Reset(renew)
 					continue out
 				}
 				na := wire.NewNetAddressIPPort(externalip, uint16(listenPort),
@@ -2544,10 +2764,10 @@ out:
 				if err != nil {
 					// XXX DeletePortMapping?
 				}
-				srvrLog.Warnf("Successfully bound via UPnP to %s", addrmgr.NetAddressKey(na))
+				srvrLog.Warnf("Successfully mapped port to %s", addrmgr.NetAddressKey(na))
 				first = false
 			}
-			timer.Reset(time.Minute * 15)
+			timer.Reset(renew)
 		case <-s.quit:
 			break out
 		}
@@ -2556,9 +2776,9 @@ out:
 	timer.Stop()
 
 	if err := s.nat.DeletePortMapping("tcp", int(lport), int(lport)); err != nil {
-		srvrLog.Warnf("unable to remove UPnP port mapping: %v", err)
+		srvrLog.Warnf("unable to remove port mapping: %v", err)
 	} else {
-		srvrLog.Debugf("successfully disestablished UPnP port mapping")
+		srvrLog.Debugf("successfully disestablished port mapping")
 	}
 
 	s.wg.Done()
@@ -2613,6 +2833,28 @@ func setupRPCListeners() ([]net.Listener, error) {
 	return listeners, nil
 }
 
+// setupStratumListeners returns a slice of listeners that are configured for
+// use with the Stratum server depending on the configuration settings for
+// listen addresses.
+func setupStratumListeners() ([]net.Listener, error) {
+	netAddrs, err := parseListeners(cfg.StratumListeners)
+	if err != nil {
+		return nil, err
+	}
+
+	listeners := make([]net.Listener, 0, len(netAddrs))
+	for _, addr := range netAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			strmLog.Warnf("Can't listen on %s: %v", addr, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
 // newServer returns a new eacd server configured to listen on addr for the
 // bitcoin network type specified by chainParams.  Use start to begin accepting
 // connections from peers.
@@ -2629,6 +2871,14 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	}
 
 	amgr := addrmgr.New(cfg.DataDir, eacdLookup)
+	if cfg.ASMap != "" {
+		asmap, err := addrmgr.LoadASMap(cfg.ASMap)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load asmap: %v", err)
+		}
+		amgr.SetASMap(asmap)
+		srvrLog.Infof("Using asmap %s to group peers", cfg.ASMap)
+	}
 
 	var listeners []net.Listener
 	var nat NAT
@@ -2641,6 +2891,12 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		if len(listeners) == 0 {
 			return nil, errors.New("no valid listen address")
 		}
+
+		// Inbound I2P connections are accepted through the SAM
+		// bridge rather than a local listener.
+		if cfg.I2PAcceptIncoming {
+			listeners = append(listeners, cfg.i2pSession.Listen())
+		}
 	}
 
 	if len(agentBlacklist) > 0 {
@@ -2663,6 +2919,7 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		modifyRebroadcastInv: make(chan interface{}),
 		peerHeightsUpdate:    make(chan updatePeerHeightsMsg),
 		nat:                  nat,
+		onionTarget:          onionTarget(listeners),
 		db:                   db,
 		timeSource:           blockchain.NewMedianTime(),
 		services:             services,
@@ -2774,6 +3031,13 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 			MinRelayTxFee:        cfg.minRelayTxFee,
 			MaxTxVersion:         2,
 			RejectReplacement:    cfg.RejectReplacement,
+			FullRBF:              cfg.MempoolFullRBF,
+			MaxPoolSize:          int64(cfg.MaxMempool) * 1000000,
+			MaxAncestorCount:     int64(cfg.LimitAncestorCount),
+			MaxAncestorSize:      int64(cfg.LimitAncestorSize) * 1000,
+			MaxDescendantCount:   int64(cfg.LimitDescendantCount),
+			MaxDescendantSize:    int64(cfg.LimitDescendantSize) * 1000,
+			MaxTxAge:             time.Duration(cfg.MempoolExpiry) * time.Hour,
 		},
 		ChainParams:    chainParams,
 		FetchUtxoView:  s.chain.FetchUtxoView,
@@ -2787,6 +3051,40 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		HashCache:          s.hashCache,
 		AddrIndex:          s.addrIndex,
 		FeeEstimator:       s.feeEstimator,
+		NotifyTxAdded: func(txDesc *mempool.TxDesc) {
+			if s.blkTmplGenerator != nil {
+				s.blkTmplGenerator.TxAdded(&txDesc.TxDesc)
+			}
+		},
+		NotifyTxAccepted: func(txDesc *mempool.TxDesc) {
+			if s.zmqServer != nil {
+				s.zmqServer.TxAccepted(txDesc.Tx)
+			}
+		},
+		NotifyTxRemoved: func(tx *eacutil.Tx, reason mempool.RemovalReason) {
+			if s.blkTmplGenerator != nil {
+				s.blkTmplGenerator.TxRemoved(tx)
+			}
+			if s.zmqServer != nil {
+				s.zmqServer.TxRemoved(tx, reason)
+			}
+			if s.rpcServer != nil {
+				s.rpcServer.NotifyTxRemoved(tx, reason)
+			}
+		},
+		NotifyTxReplaced: func(txDesc *mempool.TxDesc, replaced []*mempool.TxDesc) {
+			if s.rpcServer != nil {
+				s.rpcServer.NotifyTxReplaced(txDesc, replaced)
+			}
+		},
+		NotifyDoubleSpend: func(ds *mempool.DoubleSpend) {
+			if ds.First {
+				s.relayDoubleSpend(ds)
+			}
+			if s.rpcServer != nil {
+				s.rpcServer.NotifyDoubleSpend(ds)
+			}
+		},
 	}
 	s.txMemPool = mempool.New(&txC)
 
@@ -2815,19 +3113,62 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		BlockMaxSize:      cfg.BlockMaxSize,
 		BlockPrioritySize: cfg.BlockPrioritySize,
 		TxMinFreeFee:      cfg.minRelayTxFee,
+		CoinbaseFlags:     cfg.CoinbaseFlags,
 	}
 	blockTemplateGenerator := mining.NewBlkTmplGenerator(&policy,
 		s.chainParams, s.txMemPool, s.chain, s.timeSource,
 		s.sigCache, s.hashCache)
+	s.blkTmplGenerator = blockTemplateGenerator
 	s.cpuMiner = cpuminer.New(&cpuminer.Config{
 		ChainParams:            chainParams,
 		BlockTemplateGenerator: blockTemplateGenerator,
-		MiningAddrs:            cfg.miningAddrs,
+		MiningPayouts:          cfg.miningPayouts,
 		ProcessBlock:           s.syncManager.ProcessBlock,
 		ConnectedCount:         s.ConnectedCount,
 		IsCurrent:              s.syncManager.IsCurrent,
 	})
 
+	if len(cfg.StratumListeners) > 0 {
+		// Setup listeners for the configured Stratum listen addresses.
+		stratumListeners, err := setupStratumListeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(stratumListeners) == 0 {
+			return nil, errors.New("STRM: No valid listen address")
+		}
+
+		s.stratumServer = stratum.New(&stratum.Config{
+			ChainParams:            chainParams,
+			BlockTemplateGenerator: blockTemplateGenerator,
+			MiningPayouts:          cfg.miningPayouts,
+			ProcessBlock:           s.syncManager.ProcessBlock,
+			ConnectedCount:         s.ConnectedCount,
+			IsCurrent:              s.syncManager.IsCurrent,
+			Listeners:              stratumListeners,
+			Difficulty:             cfg.StratumDifficulty,
+			MaxClients:             cfg.StratumMaxClients,
+			MinRelayTxFee:          cfg.minRelayTxFee,
+		})
+	}
+
+	if cfg.ZMQPubHashBlock != "" || cfg.ZMQPubRawBlock != "" ||
+		cfg.ZMQPubHashTx != "" || cfg.ZMQPubRawTx != "" ||
+		cfg.ZMQPubSequence != "" {
+
+		s.zmqServer, err = zmq.New(&zmq.Config{
+			Chain:     s.chain,
+			HashBlock: cfg.ZMQPubHashBlock,
+			RawBlock:  cfg.ZMQPubRawBlock,
+			HashTx:    cfg.ZMQPubHashTx,
+			RawTx:     cfg.ZMQPubRawTx,
+			Sequence:  cfg.ZMQPubSequence,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Only setup a function to return new addresses to connect to when
 	// not running in connect-only mode.  The simulation network is always
 	// in connect-only mode since it is only intended to connect to
@@ -2849,7 +3190,7 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 				// in the same group so that we are not connecting
 				// to the same network segment at the expense of
 				// others.
-				key := addrmgr.GroupKey(addr.NetAddress())
+				key := s.addrManager.GroupKey(addr.NetAddress())
 				if s.OutboundGroupCount(key) != 0 {
 					continue
 				}
@@ -2877,19 +3218,35 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}
 	}
 
-	// Create a connection manager.
+	// Create a connection manager.  Block-relay-only connections and
+	// anchors are only used when automatic outbound connections are made
+	// since they are otherwise limited to the specified peers.
 	targetOutbound := defaultTargetOutbound
 	if cfg.MaxPeers < targetOutbound {
 		targetOutbound = cfg.MaxPeers
 	}
+	var targetBlockRelay int
+	var anchors []net.Addr
+	if newAddressFunc != nil {
+		targetBlockRelay = cfg.BlockRelayOutbound
+		if cfg.MaxPeers-targetOutbound < targetBlockRelay {
+			targetBlockRelay = cfg.MaxPeers - targetOutbound
+		}
+		if targetBlockRelay < 0 {
+			targetBlockRelay = 0
+		}
+		anchors = loadAnchors(filepath.Join(cfg.DataDir, anchorsFilename))
+	}
 	cmgr, err := connmgr.New(&connmgr.Config{
-		Listeners:      listeners,
-		OnAccept:       s.inboundPeerConnected,
-		RetryDuration:  connectionRetryInterval,
-		TargetOutbound: uint32(targetOutbound),
-		Dial:           eacdDial,
-		OnConnection:   s.outboundPeerConnected,
-		GetNewAddress:  newAddressFunc,
+		Listeners:                listeners,
+		OnAccept:                 s.inboundPeerConnected,
+		RetryDuration:            connectionRetryInterval,
+		TargetOutbound:           uint32(targetOutbound),
+		TargetBlockRelayOutbound: uint32(targetBlockRelay),
+		Anchors:                  anchors,
+		Dial:                     eacdDial,
+		OnConnection:             s.outboundPeerConnected,
+		GetNewAddress:            newAddressFunc,
 	})
 	if err != nil {
 		return nil, err
@@ -2925,21 +3282,23 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}
 
 		s.rpcServer, err = newRPCServer(&rpcserverConfig{
-			Listeners:    rpcListeners,
-			StartupTime:  s.startupTime,
-			ConnMgr:      &rpcConnManager{&s},
-			SyncMgr:      &rpcSyncMgr{&s, s.syncManager},
-			TimeSource:   s.timeSource,
-			Chain:        s.chain,
-			ChainParams:  chainParams,
-			DB:           db,
-			TxMemPool:    s.txMemPool,
-			Generator:    blockTemplateGenerator,
-			CPUMiner:     s.cpuMiner,
-			TxIndex:      s.txIndex,
-			AddrIndex:    s.addrIndex,
-			CfIndex:      s.cfIndex,
-			FeeEstimator: s.feeEstimator,
+			Listeners:     rpcListeners,
+			StartupTime:   s.startupTime,
+			ConnMgr:       &rpcConnManager{&s},
+			SyncMgr:       &rpcSyncMgr{&s, s.syncManager},
+			TimeSource:    s.timeSource,
+			Chain:         s.chain,
+			ChainParams:   chainParams,
+			DB:            db,
+			TxMemPool:     s.txMemPool,
+			Generator:     blockTemplateGenerator,
+			CPUMiner:      s.cpuMiner,
+			StratumServer: s.stratumServer,
+			TxIndex:       s.txIndex,
+			AddrIndex:     s.addrIndex,
+			CfIndex:       s.cfIndex,
+			FeeEstimator:  s.feeEstimator,
+			SaveMempool:   s.saveMempool,
 		})
 		if err != nil {
 			return nil, err
@@ -3011,14 +3370,23 @@ func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wir
 			}
 		}
 	} else {
-		if cfg.Upnp {
+		// PCP and NAT-PMP are preferred over UPnP when both are
+		// enabled since they are much simpler protocols.
+		if cfg.NATPMP || cfg.PortMap {
+			var err error
+			nat, err = DiscoverNATPMP()
+			if err != nil {
+				srvrLog.Warnf("Can't discover NAT-PMP or PCP: %v", err)
+			}
+		}
+		if nat == nil && (cfg.Upnp || cfg.PortMap) {
 			var err error
 			nat, err = Discover()
 			if err != nil {
 				srvrLog.Warnf("Can't discover upnp: %v", err)
 			}
-			// nil nat here is fine, just means no upnp on network.
 		}
+		// nil nat here is fine, just means no port mapping on network.
 
 		// Add bound addresses to address manager to be advertised to peers.
 		for _, listener := range listeners {
@@ -3035,8 +3403,8 @@ func initListeners(amgr *addrmgr.AddrManager, listenAddrs []string, services wir
 
 // addrStringToNetAddr takes an address in the form of 'host:port' and returns
 // a net.Addr which maps to the original address with any host names resolved
-// to IP addresses.  It also handles tor addresses properly by returning a
-// net.Addr that encapsulates the address.
+// to IP addresses.  It also handles tor and I2P addresses properly by returning
+// a net.Addr that encapsulates the address.
 func addrStringToNetAddr(addr string) (net.Addr, error) {
 	host, strPort, err := net.SplitHostPort(addr)
 	if err != nil {
@@ -3066,6 +3434,15 @@ func addrStringToNetAddr(addr string) (net.Addr, error) {
 		return &onionAddr{addr: addr}, nil
 	}
 
+	// I2P addresses are only reachable through the I2P SAM bridge.
+	if strings.HasSuffix(strings.ToLower(host), addrmgr.I2PSuffix) {
+		if cfg.i2pSession == nil {
+			return nil, errors.New("no I2P SAM bridge configured")
+		}
+
+		return &connmgr.I2PAddr{Host: host, Port: port}, nil
+	}
+
 	// Attempt to look up an IP address associated with the parsed host.
 	ips, err := eacdLookup(host)
 	if err != nil {
@@ -14,6 +14,8 @@ import (
 	"testing"
 
 	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/scrypt"
+	xscrypt "golang.org/x/crypto/scrypt"
 )
 
 // genesisCoinbaseTx is the coinbase transaction for the genesis blocks for
@@ -378,6 +380,46 @@ func BenchmarkWriteBlockHeader(b *testing.B) {
 	}
 }
 
+// BenchmarkPowHashScryptKey performs a benchmark on how long it takes to
+// calculate the scrypt proof of work hash of a block header with the general
+// purpose scrypt implementation, which PowHash used previously.
+func BenchmarkPowHashScryptKey(b *testing.B) {
+	header := blockOne.Header
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		header.Nonce = uint32(i)
+		var buf bytes.Buffer
+		writeBlockHeader(&buf, 0, &header)
+		xscrypt.Key(buf.Bytes(), buf.Bytes(), 1024, 1, 1, 32)
+	}
+}
+
+// BenchmarkPowHash performs a benchmark on how long it takes to calculate the
+// scrypt proof of work hash of a block header with pooled hashers.
+func BenchmarkPowHash(b *testing.B) {
+	header := blockOne.Header
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		header.Nonce = uint32(i)
+		header.PowHash()
+	}
+}
+
+// BenchmarkPowHashWith performs a benchmark on how long it takes to calculate
+// the scrypt proof of work hash of a block header with a dedicated hasher as
+// done when mining.
+func BenchmarkPowHashWith(b *testing.B) {
+	header := blockOne.Header
+	hasher := scrypt.NewHasher()
+	var hash chainhash.Hash
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		header.Nonce = uint32(i)
+		header.PowHashWith(hasher, &hash)
+	}
+}
+
 // BenchmarkDecodeGetHeaders performs a benchmark on how long it takes to
 // decode a getheaders message with the maximum number of block locator hashes.
 func BenchmarkDecodeGetHeaders(b *testing.B) {
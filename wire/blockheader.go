@@ -9,9 +9,8 @@ import (
 	"io"
 	"time"
 
-	"golang.org/x/crypto/scrypt"
-
 	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/scrypt"
 )
 
 // MaxBlockHeaderPayload is the maximum number of bytes a block header can be.
@@ -60,21 +59,28 @@ func (h *BlockHeader) BlockHash() chainhash.Hash {
 
 // PowHash returns the earthcoin scrypt hash of this block header. This value is
 // used to check the PoW on blocks advertised on the network.
+//
+// The hash is calculated with a pooled scrypt hasher, so the returned error is
+// always nil.
 func (h *BlockHeader) PowHash() (*chainhash.Hash, error) {
-	var powHash chainhash.Hash
-
-	buf := bytes.NewBuffer(make([]byte, 0, MaxBlockHeaderPayload))
-	_ = writeBlockHeader(buf, 0, h)
-
-	scryptHash, err := scrypt.Key(buf.Bytes(), buf.Bytes(), 1024, 1, 1, 32)
-	if err != nil {
-		return nil, err
-	}
-	copy(powHash[:], scryptHash)
+	var buf [blockHeaderLen]byte
+	putBlockHeader(&buf, h)
 
+	powHash := chainhash.Hash(scrypt.SumHeader(&buf))
 	return &powHash, nil
 }
 
+// PowHashWith calculates the earthcoin scrypt hash of this block header with
+// the passed hasher and stores it in powHash.  Unlike PowHash, it doesn't
+// allocate, and the hasher reuses the SHA-256 state of the start of the header
+// while only the timestamp, bits and nonce change, which makes it suitable for
+// mining.
+func (h *BlockHeader) PowHashWith(hasher *scrypt.Hasher, powHash *chainhash.Hash) {
+	var buf [blockHeaderLen]byte
+	putBlockHeader(&buf, h)
+	hasher.SumHeader(&buf, (*[scrypt.HashSize]byte)(powHash))
+}
+
 // BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
 // This is part of the Message interface implementation.
 // See Deserialize for decoding block headers stored to disk, such as in a
@@ -145,3 +151,14 @@ func writeBlockHeader(w io.Writer, pver uint32, bh *BlockHeader) error {
 	return writeElements(w, bh.Version, &bh.PrevBlock, &bh.MerkleRoot,
 		sec, bh.Bits, bh.Nonce)
 }
+
+// putBlockHeader serializes a bitcoin block header into buf in the same format
+// as writeBlockHeader without allocating.
+func putBlockHeader(buf *[blockHeaderLen]byte, bh *BlockHeader) {
+	littleEndian.PutUint32(buf[0:4], uint32(bh.Version))
+	copy(buf[4:36], bh.PrevBlock[:])
+	copy(buf[36:68], bh.MerkleRoot[:])
+	littleEndian.PutUint32(buf[68:72], uint32(bh.Timestamp.Unix()))
+	littleEndian.PutUint32(buf[72:76], bh.Bits)
+	littleEndian.PutUint32(buf[76:80], bh.Nonce)
+}
@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/scrypt"
 )
 
 // TestBlockHeader tests the BlockHeader API.
@@ -259,3 +261,75 @@ func TestBlockHeaderSerialize(t *testing.T) {
 		}
 	}
 }
+
+// TestBlockHeaderPowHash tests the scrypt proof of work hash of block headers
+// calculated by both PowHash and PowHashWith.
+func TestBlockHeaderPowHash(t *testing.T) {
+	baseBlockHdr := &BlockHeader{
+		Version:    1,
+		PrevBlock:  mainNetGenesisHash,
+		MerkleRoot: mainNetGenesisMerkleRoot,
+		Timestamp:  time.Unix(0x495fab29, 0), // 2009-01-03 12:15:05 -0600 CST
+		Bits:       0x1d00ffff,
+	}
+
+	tests := []struct {
+		nonce uint32 // Nonce of the header
+		hash  string // Expected proof of work hash
+	}{
+		{
+			123123,
+			"d045c6b8633f75844a0a1c02ab974a20536eb4156b4a434510b9ecfd227113de",
+		},
+		{
+			0,
+			"4665e7f102351cfac81c81764f5df1aca9a29f3fa6d5e76418b18c5179ac0357",
+		},
+	}
+
+	hasher := scrypt.NewHasher()
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		want, err := chainhash.NewHashFromStr(test.hash)
+		if err != nil {
+			t.Errorf("NewHashFromStr #%d: unexpected error: %v", i, err)
+			continue
+		}
+
+		header := *baseBlockHdr
+		header.Nonce = test.nonce
+
+		// Ensure the fixed size serialization used for hashing
+		// matches the wire encoding.
+		var buf bytes.Buffer
+		if err := header.Serialize(&buf); err != nil {
+			t.Errorf("Serialize #%d error %v", i, err)
+			continue
+		}
+		var fixed [blockHeaderLen]byte
+		putBlockHeader(&fixed, &header)
+		if !bytes.Equal(fixed[:], buf.Bytes()) {
+			t.Errorf("putBlockHeader #%d\n got: %s want: %s", i,
+				spew.Sdump(fixed[:]), spew.Sdump(buf.Bytes()))
+			continue
+		}
+
+		got, err := header.PowHash()
+		if err != nil {
+			t.Errorf("PowHash #%d error %v", i, err)
+			continue
+		}
+		if *got != *want {
+			t.Errorf("PowHash #%d: got %v want %v", i, got, want)
+			continue
+		}
+
+		var gotWith chainhash.Hash
+		header.PowHashWith(hasher, &gotWith)
+		if gotWith != *want {
+			t.Errorf("PowHashWith #%d: got %v want %v", i, gotWith,
+				want)
+			continue
+		}
+	}
+}
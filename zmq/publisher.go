@@ -0,0 +1,398 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// handshakeTimeout is the time subscribers have to complete the
+	// greeting and the handshake after connecting.
+	handshakeTimeout = 10 * time.Second
+
+	// highWaterMark is the maximum number of messages queued for a
+	// subscriber.  Like a ZeroMQ PUB socket, messages are dropped for
+	// subscribers which can't keep up once the limit is reached.
+	highWaterMark = 1000
+
+	// maxSubscribers is the maximum number of subscribers of a publisher.
+	// Further connections are closed right away.
+	maxSubscribers = 125
+
+	// maxSubscriptions is the maximum number of subscriptions of a
+	// subscriber.  Subscribers are disconnected when they exceed it.
+	maxSubscriptions = 1000
+
+	// maxPingContext is the maximum length of the context of a PING
+	// command.
+	maxPingContext = 16
+)
+
+// parseAddress converts a ZeroMQ endpoint of the form tcp://host:port to the
+// address to listen on.  A host of * listens on all interfaces.
+func parseAddress(endpoint string) (string, error) {
+	const scheme = "tcp://"
+	if !strings.HasPrefix(endpoint, scheme) {
+		return "", fmt.Errorf("unsupported endpoint %q: only tcp:// "+
+			"endpoints are supported", endpoint)
+	}
+	host, port, err := net.SplitHostPort(endpoint[len(scheme):])
+	if err != nil {
+		return "", fmt.Errorf("invalid endpoint %q: %v", endpoint, err)
+	}
+	if port == "" {
+		return "", fmt.Errorf("invalid endpoint %q: missing port",
+			endpoint)
+	}
+	if host == "*" {
+		host = ""
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// subscriber houses the state of a connection of a ZeroMQ subscriber.
+type subscriber struct {
+	conn     net.Conn
+	queue    chan []byte
+	writeMtx sync.Mutex
+	quit     chan struct{}
+
+	subsMtx       sync.Mutex
+	subscriptions map[string]int
+}
+
+// subscribed returns whether the subscriber has a subscription which is a
+// prefix of the passed topic.
+func (s *subscriber) subscribed(topic string) bool {
+	s.subsMtx.Lock()
+	defer s.subsMtx.Unlock()
+
+	for prefix := range s.subscriptions {
+		if strings.HasPrefix(topic, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribe adds a subscription to the passed topic prefix.  Subscriptions are
+// counted, so a prefix subscribed to twice must also be canceled twice.
+func (s *subscriber) subscribe(prefix string) error {
+	s.subsMtx.Lock()
+	defer s.subsMtx.Unlock()
+
+	if _, ok := s.subscriptions[prefix]; !ok &&
+		len(s.subscriptions) >= maxSubscriptions {
+
+		return errors.New("too many subscriptions")
+	}
+	s.subscriptions[prefix]++
+	return nil
+}
+
+// cancel removes a subscription to the passed topic prefix.
+func (s *subscriber) cancel(prefix string) {
+	s.subsMtx.Lock()
+	defer s.subsMtx.Unlock()
+
+	if s.subscriptions[prefix] > 1 {
+		s.subscriptions[prefix]--
+	} else {
+		delete(s.subscriptions, prefix)
+	}
+}
+
+// write writes the passed encoded frames to the subscriber.
+func (s *subscriber) write(b []byte) error {
+	s.writeMtx.Lock()
+	defer s.writeMtx.Unlock()
+
+	_, err := s.conn.Write(b)
+	return err
+}
+
+// writeHandler writes the messages queued for the subscriber until it
+// disconnects.  It must be run as a goroutine.
+func (s *subscriber) writeHandler() {
+	for {
+		select {
+		case msg := <-s.queue:
+			if err := s.write(msg); err != nil {
+				// Closing the connection makes the read loop of
+				// the subscriber exit as well.
+				s.conn.Close()
+				return
+			}
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// readHandler reads the subscriptions and commands of the subscriber until it
+// disconnects or violates the protocol.
+func (s *subscriber) readHandler(r *bufio.Reader) error {
+	continuation := false
+	for {
+		f, err := readFrame(r)
+		if err != nil {
+			return err
+		}
+
+		if f.command {
+			name, data, err := parseCommand(f.body)
+			if err != nil {
+				return err
+			}
+			switch name {
+			case cmdSubscribe:
+				if err := s.subscribe(string(data)); err != nil {
+					return err
+				}
+			case cmdCancel:
+				s.cancel(string(data))
+			case cmdPing:
+				if len(data) < 2 || len(data)-2 > maxPingContext {
+					return errors.New("malformed PING command")
+				}
+				err := s.write(encodeCommand(cmdPong, data[2:]))
+				if err != nil {
+					return err
+				}
+			case cmdError:
+				return fmt.Errorf("peer error: %q", data)
+			}
+			continue
+		}
+
+		// Subscribers of ZMTP 3.0 send subscriptions as messages whose
+		// first byte is 1 for subscriptions and 0 for cancellations.
+		// Any further frames of a message are ignored.
+		first := !continuation
+		continuation = f.more
+		if !first || len(f.body) == 0 {
+			continue
+		}
+		switch f.body[0] {
+		case 1:
+			if err := s.subscribe(string(f.body[1:])); err != nil {
+				return err
+			}
+		case 0:
+			s.cancel(string(f.body[1:]))
+		}
+	}
+}
+
+// publisher implements a ZeroMQ PUB socket listening on a single address.
+type publisher struct {
+	listener       net.Listener
+	maxSubscribers int
+	wg             sync.WaitGroup
+	quit           chan struct{}
+
+	mtx         sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+// newPublisher returns a publisher accepting subscribers with the passed
+// listener.  The publisher takes ownership of the listener and closes it when
+// it is stopped.
+func newPublisher(listener net.Listener) *publisher {
+	return &publisher{
+		listener:       listener,
+		maxSubscribers: maxSubscribers,
+		quit:           make(chan struct{}),
+		subscribers:    make(map[*subscriber]struct{}),
+	}
+}
+
+// handshake performs the greeting and the handshake of the NULL mechanism
+// with a new subscriber and ensures its socket type is compatible.
+func handshake(conn net.Conn, r *bufio.Reader) error {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := conn.Write(greeting()); err != nil {
+		return err
+	}
+	if err := readGreeting(r); err != nil {
+		return err
+	}
+
+	ready := encodeCommand(cmdReady, encodeMetadata(map[string]string{
+		socketTypeProperty: "PUB",
+	}))
+	if _, err := conn.Write(ready); err != nil {
+		return err
+	}
+
+	f, err := readFrame(r)
+	if err != nil {
+		return err
+	}
+	if !f.command {
+		return errors.New("handshake did not start with a command")
+	}
+	name, data, err := parseCommand(f.body)
+	if err != nil {
+		return err
+	}
+	switch name {
+	case cmdReady:
+	case cmdError:
+		return fmt.Errorf("peer error: %q", data)
+	default:
+		return fmt.Errorf("unexpected %s command during handshake",
+			name)
+	}
+
+	properties, err := parseMetadata(data)
+	if err != nil {
+		return err
+	}
+	socketType := properties[strings.ToLower(socketTypeProperty)]
+	if socketType != "SUB" && socketType != "XSUB" {
+		return fmt.Errorf("incompatible socket type %q", socketType)
+	}
+	return nil
+}
+
+// handleConn performs the handshake with a new subscriber and serves it until
+// it disconnects or the publisher is stopped.  It must be run as a goroutine.
+func (p *publisher) handleConn(conn net.Conn) {
+	defer p.wg.Done()
+	defer conn.Close()
+
+	// The subscriber is tracked during the handshake already so it is
+	// disconnected when the publisher is stopped.  No messages are queued
+	// for it before it subscribes to a topic.
+	s := &subscriber{
+		conn:          conn,
+		queue:         make(chan []byte, highWaterMark),
+		quit:          make(chan struct{}),
+		subscriptions: make(map[string]int),
+	}
+	p.mtx.Lock()
+	select {
+	case <-p.quit:
+		p.mtx.Unlock()
+		return
+	default:
+	}
+	if len(p.subscribers) >= p.maxSubscribers {
+		p.mtx.Unlock()
+		log.Infof("Max subscribers on %s exceeded [%d] - "+
+			"disconnecting subscriber %s", p.listener.Addr(),
+			p.maxSubscribers, conn.RemoteAddr())
+		return
+	}
+	p.subscribers[s] = struct{}{}
+	p.mtx.Unlock()
+	defer func() {
+		p.mtx.Lock()
+		delete(p.subscribers, s)
+		p.mtx.Unlock()
+	}()
+
+	r := bufio.NewReader(conn)
+	if err := handshake(conn, r); err != nil {
+		log.Debugf("Handshake with subscriber %s failed: %v",
+			conn.RemoteAddr(), err)
+		return
+	}
+	log.Debugf("New subscriber %s on %s", conn.RemoteAddr(),
+		p.listener.Addr())
+
+	p.wg.Add(1)
+	go func() {
+		s.writeHandler()
+		p.wg.Done()
+	}()
+
+	err := s.readHandler(r)
+	select {
+	case <-p.quit:
+	default:
+		log.Debugf("Subscriber %s disconnected: %v", conn.RemoteAddr(),
+			err)
+	}
+	close(s.quit)
+}
+
+// acceptHandler accepts new subscribers until the publisher is stopped.  It
+// must be run as a goroutine.
+func (p *publisher) acceptHandler() {
+	defer p.wg.Done()
+
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			select {
+			case <-p.quit:
+			default:
+				log.Errorf("Can't accept subscriber on %s: %v",
+					p.listener.Addr(), err)
+			}
+			return
+		}
+
+		p.wg.Add(1)
+		go p.handleConn(conn)
+	}
+}
+
+// publish queues the message made of the passed parts for all subscribers
+// with a subscription matching its first part.  The message is dropped for
+// subscribers whose queue is full.
+func (p *publisher) publish(parts [][]byte) {
+	topic := string(parts[0])
+	var msg []byte
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for s := range p.subscribers {
+		if !s.subscribed(topic) {
+			continue
+		}
+		if msg == nil {
+			msg = encodeMessage(parts)
+		}
+		select {
+		case s.queue <- msg:
+		default:
+			log.Debugf("Dropping %s message for slow subscriber %s",
+				topic, s.conn.RemoteAddr())
+		}
+	}
+}
+
+// start begins accepting subscribers.
+func (p *publisher) start() {
+	p.wg.Add(1)
+	go p.acceptHandler()
+}
+
+// stop disconnects all subscribers and stops accepting new ones.  It blocks
+// until all goroutines of the publisher have finished.
+func (p *publisher) stop() {
+	p.mtx.Lock()
+	close(p.quit)
+	for s := range p.subscribers {
+		s.conn.Close()
+	}
+	p.mtx.Unlock()
+
+	p.listener.Close()
+	p.wg.Wait()
+}
@@ -0,0 +1,283 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/eacsuite/eacd/blockchain"
+	"github.com/eacsuite/eacd/chaincfg/chainhash"
+	"github.com/eacsuite/eacd/mempool"
+	"github.com/eacsuite/eacutil"
+)
+
+// Topics of the messages published by the server.
+const (
+	TopicHashBlock = "hashblock"
+	TopicRawBlock  = "rawblock"
+	TopicHashTx    = "hashtx"
+	TopicRawTx     = "rawtx"
+	TopicSequence  = "sequence"
+)
+
+// Labels of the events published on the sequence topic.
+const (
+	labelBlockConnected    = 'C'
+	labelBlockDisconnected = 'D'
+	labelTxAdded           = 'A'
+	labelTxRemoved         = 'R'
+)
+
+// Config is a descriptor containing the ZeroMQ server configuration.  Each
+// topic is published on the endpoint of the form tcp://host:port given for
+// it, or not at all when the endpoint is empty.  Topics with the same
+// endpoint share a single socket.
+type Config struct {
+	// Chain is the block chain whose connected and disconnected blocks are
+	// published.  It may be nil, in which case blocks are only published
+	// by calling HandleBlockchainNotification.
+	Chain *blockchain.BlockChain
+
+	// HashBlock, RawBlock, HashTx, RawTx and Sequence are the endpoints
+	// to publish the respective topics on.
+	HashBlock string
+	RawBlock  string
+	HashTx    string
+	RawTx     string
+	Sequence  string
+}
+
+// topicPublisher is a topic along with the publisher of its messages and the
+// sequence number of its next message.
+type topicPublisher struct {
+	publisher *publisher
+	sequence  uint32
+}
+
+// Server publishes block and transaction events to ZeroMQ subscribers the
+// same way as the ZeroMQ notifications of Bitcoin Core.  Every message is made
+// of three parts: the topic, the body and the sequence number of the message
+// within its topic as a little-endian 32-bit integer.
+type Server struct {
+	publishers []*publisher
+
+	mtx             sync.Mutex
+	topics          map[string]*topicPublisher
+	mempoolSequence uint64
+}
+
+// New returns a new ZeroMQ server listening on the endpoints of the passed
+// configuration.  Use Start to begin accepting subscribers.
+func New(cfg *Config) (*Server, error) {
+	s := &Server{
+		topics: make(map[string]*topicPublisher),
+	}
+
+	endpoints := []struct {
+		topic    string
+		endpoint string
+	}{
+		{TopicHashBlock, cfg.HashBlock},
+		{TopicRawBlock, cfg.RawBlock},
+		{TopicHashTx, cfg.HashTx},
+		{TopicRawTx, cfg.RawTx},
+		{TopicSequence, cfg.Sequence},
+	}
+	publishers := make(map[string]*publisher)
+	for _, e := range endpoints {
+		if e.endpoint == "" {
+			continue
+		}
+		addr, err := parseAddress(e.endpoint)
+		if err != nil {
+			s.closeListeners()
+			return nil, err
+		}
+
+		p, ok := publishers[addr]
+		if !ok {
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				s.closeListeners()
+				return nil, err
+			}
+			p = newPublisher(listener)
+			publishers[addr] = p
+			s.publishers = append(s.publishers, p)
+		}
+		s.topics[e.topic] = &topicPublisher{publisher: p}
+	}
+	if len(s.publishers) == 0 {
+		return nil, errors.New("no endpoints to publish on")
+	}
+
+	if cfg.Chain != nil {
+		cfg.Chain.Subscribe(s.HandleBlockchainNotification)
+	}
+	return s, nil
+}
+
+// closeListeners closes the listeners of the publishers created so far when
+// the creation of the server fails.
+func (s *Server) closeListeners() {
+	for _, p := range s.publishers {
+		p.listener.Close()
+	}
+}
+
+// Start begins accepting subscribers.
+func (s *Server) Start() {
+	for _, p := range s.publishers {
+		p.start()
+	}
+	log.Infof("ZeroMQ server publishing %d topics on %d sockets",
+		len(s.topics), len(s.publishers))
+}
+
+// Stop disconnects all subscribers and closes the sockets of the server.
+func (s *Server) Stop() {
+	for _, p := range s.publishers {
+		p.stop()
+	}
+}
+
+// publish publishes the passed body on the passed topic if it is enabled.
+// The caller must hold the server lock, so the messages of all topics are
+// published in the order of the events they describe.
+func (s *Server) publish(topic string, body []byte) {
+	tp, ok := s.topics[topic]
+	if !ok {
+		return
+	}
+
+	var sequence [4]byte
+	binary.LittleEndian.PutUint32(sequence[:], tp.sequence)
+	tp.sequence++
+	tp.publisher.publish([][]byte{[]byte(topic), body, sequence[:]})
+}
+
+// enabled returns whether the passed topic is published.
+func (s *Server) enabled(topic string) bool {
+	_, ok := s.topics[topic]
+	return ok
+}
+
+// reversedHash returns the passed hash in the byte order it is displayed in.
+func reversedHash(hash *chainhash.Hash) []byte {
+	b := make([]byte, chainhash.HashSize)
+	for i := range hash {
+		b[chainhash.HashSize-1-i] = hash[i]
+	}
+	return b
+}
+
+// sequenceBody returns the body of a message of the sequence topic for the
+// passed hash and label, followed by the memory pool sequence number for
+// transaction events.
+func sequenceBody(hash *chainhash.Hash, label byte, mempoolSequence *uint64) []byte {
+	body := append(reversedHash(hash), label)
+	if mempoolSequence != nil {
+		var seq [8]byte
+		binary.LittleEndian.PutUint64(seq[:], *mempoolSequence)
+		body = append(body, seq[:]...)
+	}
+	return body
+}
+
+// publishTx publishes the passed transaction on the hashtx and rawtx topics.
+// The caller must hold the server lock.
+func (s *Server) publishTx(tx *eacutil.Tx) {
+	s.publish(TopicHashTx, reversedHash(tx.Hash()))
+	if s.enabled(TopicRawTx) {
+		msgTx := tx.MsgTx()
+		buf := bytes.NewBuffer(make([]byte, 0, msgTx.SerializeSize()))
+		if err := msgTx.Serialize(buf); err != nil {
+			log.Errorf("Unable to serialize transaction %v: %v",
+				tx.Hash(), err)
+			return
+		}
+		s.publish(TopicRawTx, buf.Bytes())
+	}
+}
+
+// publishBlock publishes the transactions of the passed block followed by the
+// block itself on the sequence topic with the passed label.  Connected blocks
+// are also published on the hashblock and rawblock topics.
+func (s *Server) publishBlock(block *eacutil.Block, label byte) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, tx := range block.Transactions() {
+		s.publishTx(tx)
+	}
+	s.publish(TopicSequence, sequenceBody(block.Hash(), label, nil))
+	if label != labelBlockConnected {
+		return
+	}
+	s.publish(TopicHashBlock, reversedHash(block.Hash()))
+	if s.enabled(TopicRawBlock) {
+		serialized, err := block.Bytes()
+		if err != nil {
+			log.Errorf("Unable to serialize block %v: %v",
+				block.Hash(), err)
+			return
+		}
+		s.publish(TopicRawBlock, serialized)
+	}
+}
+
+// HandleBlockchainNotification publishes blocks connected to and disconnected
+// from the main chain.  It is subscribed to the notifications of the chain of
+// the configuration when one is given.
+func (s *Server) HandleBlockchainNotification(notification *blockchain.Notification) {
+	switch notification.Type {
+	case blockchain.NTBlockConnected:
+		block, ok := notification.Data.(*eacutil.Block)
+		if !ok {
+			log.Warnf("Chain connected notification is not a block.")
+			break
+		}
+		s.publishBlock(block, labelBlockConnected)
+
+	case blockchain.NTBlockDisconnected:
+		block, ok := notification.Data.(*eacutil.Block)
+		if !ok {
+			log.Warnf("Chain disconnected notification is not a block.")
+			break
+		}
+		s.publishBlock(block, labelBlockDisconnected)
+	}
+}
+
+// TxAccepted publishes the passed transaction accepted into the memory pool.
+func (s *Server) TxAccepted(tx *eacutil.Tx) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.mempoolSequence++
+	s.publishTx(tx)
+	s.publish(TopicSequence, sequenceBody(tx.Hash(), labelTxAdded,
+		&s.mempoolSequence))
+}
+
+// TxRemoved publishes the removal of the passed transaction from the memory
+// pool on the sequence topic.  Transactions removed because they were
+// included in a block are not published since the block connected event
+// covers them.
+func (s *Server) TxRemoved(tx *eacutil.Tx, reason mempool.RemovalReason) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.mempoolSequence++
+	if reason == mempool.RemovalReasonBlock {
+		return
+	}
+	s.publish(TopicSequence, sequenceBody(tx.Hash(), labelTxRemoved,
+		&s.mempoolSequence))
+}
@@ -0,0 +1,425 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eacsuite/eacd/blockchain"
+	"github.com/eacsuite/eacd/chaincfg"
+	"github.com/eacsuite/eacd/mempool"
+	"github.com/eacsuite/eacutil"
+)
+
+// testSubscriber is an in-process ZeroMQ SUB socket connected to a publisher.
+type testSubscriber struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialSubscriber connects a subscriber with the passed socket type to the
+// passed address and performs the handshake.
+func dialSubscriber(t *testing.T, addr net.Addr, socketType string) *testSubscriber {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatalf("unable to connect to %s: %v", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	s := &testSubscriber{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	s.write(greeting())
+	if err := readGreeting(s.r); err != nil {
+		t.Fatalf("unable to read greeting: %v", err)
+	}
+	s.write(encodeCommand(cmdReady, encodeMetadata(map[string]string{
+		socketTypeProperty: socketType,
+	})))
+	f, err := readFrame(s.r)
+	if err != nil {
+		t.Fatalf("unable to read READY command: %v", err)
+	}
+	name, data, err := parseCommand(f.body)
+	if err != nil || !f.command || name != cmdReady {
+		t.Fatalf("unexpected handshake command %q (%v)", name, err)
+	}
+	properties, err := parseMetadata(data)
+	if err != nil || properties["socket-type"] != "PUB" {
+		t.Fatalf("unexpected metadata %v (%v)", properties, err)
+	}
+	return s
+}
+
+// write writes the passed bytes to the publisher.
+func (s *testSubscriber) write(b []byte) {
+	s.t.Helper()
+
+	if _, err := s.conn.Write(b); err != nil {
+		s.t.Fatalf("unable to write to publisher: %v", err)
+	}
+}
+
+// sync sends a PING command and waits for the PONG reply, which ensures all
+// subscriptions sent before were processed by the publisher.  No messages may
+// be published while waiting.
+func (s *testSubscriber) sync() {
+	s.t.Helper()
+
+	s.write(encodeCommand(cmdPing, []byte{0, 0, 's'}))
+	f, err := readFrame(s.r)
+	if err != nil {
+		s.t.Fatalf("unable to read PONG command: %v", err)
+	}
+	name, data, err := parseCommand(f.body)
+	if err != nil || !f.command || name != cmdPong ||
+		!bytes.Equal(data, []byte{'s'}) {
+
+		s.t.Fatalf("unexpected reply to PING %q (%x, %v)", name, data,
+			err)
+	}
+}
+
+// readMessage reads the next message published to the subscriber.
+func (s *testSubscriber) readMessage() [][]byte {
+	s.t.Helper()
+
+	var parts [][]byte
+	for {
+		f, err := readFrame(s.r)
+		if err != nil {
+			s.t.Fatalf("unable to read message: %v", err)
+		}
+		if f.command {
+			s.t.Fatalf("unexpected command frame %x", f.body)
+		}
+		parts = append(parts, f.body)
+		if !f.more {
+			return parts
+		}
+	}
+}
+
+// assertMessage reads the next message published to the subscriber and
+// ensures it has the passed topic, body and sequence number.
+func (s *testSubscriber) assertMessage(topic string, body []byte, sequence uint32) {
+	s.t.Helper()
+
+	var seq [4]byte
+	binary.LittleEndian.PutUint32(seq[:], sequence)
+	want := [][]byte{[]byte(topic), body, seq[:]}
+	if got := s.readMessage(); !reflect.DeepEqual(got, want) {
+		s.t.Fatalf("got message %x, want %x", got, want)
+	}
+}
+
+// TestParseAddress ensures ZeroMQ endpoints are converted to listen addresses
+// as expected.
+func TestParseAddress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		endpoint string
+		addr     string
+		valid    bool
+	}{
+		{"tcp://127.0.0.1:28332", "127.0.0.1:28332", true},
+		{"tcp://*:28332", ":28332", true},
+		{"tcp://[::1]:28332", "[::1]:28332", true},
+		{"tcp://127.0.0.1", "", false},
+		{"tcp://127.0.0.1:", "", false},
+		{"ipc:///tmp/eacd.sock", "", false},
+		{"127.0.0.1:28332", "", false},
+	}
+	for _, test := range tests {
+		addr, err := parseAddress(test.endpoint)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: no error for invalid endpoint",
+					test.endpoint)
+			}
+			continue
+		}
+		if err != nil || addr != test.addr {
+			t.Errorf("%s: got %q (%v), want %q", test.endpoint, addr,
+				err, test.addr)
+		}
+	}
+}
+
+// TestPublisher ensures messages are only sent to subscribers with a matching
+// subscription and that incompatible sockets are rejected.
+func TestPublisher(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	p := newPublisher(listener)
+	p.start()
+	defer p.stop()
+
+	// The first subscriber subscribes with ZMTP 3.0 messages and the
+	// second one with ZMTP 3.1 commands.
+	sub1 := dialSubscriber(t, listener.Addr(), "SUB")
+	sub1.write(encodeMessage([][]byte{append([]byte{1}, "hash"...)}))
+	sub1.write(encodeMessage([][]byte{append([]byte{1}, "sequence"...)}))
+	sub1.sync()
+	sub2 := dialSubscriber(t, listener.Addr(), "XSUB")
+	sub2.write(encodeCommand(cmdSubscribe, []byte("rawtx")))
+	sub2.sync()
+
+	p.publish([][]byte{[]byte("hashblock"), {1}, {0, 0, 0, 0}})
+	p.publish([][]byte{[]byte("rawtx"), {2}, {0, 0, 0, 0}})
+	p.publish([][]byte{[]byte("rawblock"), {3}, {0, 0, 0, 0}})
+	sub1.assertMessage("hashblock", []byte{1}, 0)
+	sub2.assertMessage("rawtx", []byte{2}, 0)
+
+	// Canceled subscriptions no longer match.
+	sub1.write(encodeMessage([][]byte{append([]byte{0}, "hash"...)}))
+	sub1.sync()
+	p.publish([][]byte{[]byte("hashtx"), {4}, {0, 0, 0, 0}})
+	p.publish([][]byte{[]byte("sequence"), {5}, {0, 0, 0, 0}})
+	sub1.assertMessage("sequence", []byte{5}, 0)
+
+	// Publishers can't subscribe to publishers.
+	pub := dialSubscriber(t, listener.Addr(), "PUB")
+	if _, err := readFrame(pub.r); err == nil {
+		t.Fatalf("connection of PUB socket was not closed")
+	}
+}
+
+// TestLibzmqSubscriber ensures a subscriber sending the exact bytes of a ZMTP
+// 3.0 SUB socket of libzmq completes the handshake, subscribes with a
+// subscription message and receives the matching messages.
+func TestLibzmqSubscriber(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	p := newPublisher(listener)
+	p.start()
+	defer p.stop()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unable to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	// write writes the passed hex encoded bytes, while expect reads as
+	// many bytes as the passed hex encoded bytes and ensures they match.
+	write := func(s string) {
+		t.Helper()
+
+		b, _ := hex.DecodeString(s)
+		if _, err := conn.Write(b); err != nil {
+			t.Fatalf("unable to write: %v", err)
+		}
+	}
+	expect := func(s string) {
+		t.Helper()
+
+		want, _ := hex.DecodeString(s)
+		got := make([]byte, len(want))
+		if _, err := io.ReadFull(conn, got); err != nil {
+			t.Fatalf("unable to read: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("got %x, want %x", got, want)
+		}
+	}
+
+	// libzmq sends the signature, with a padding of 1 for ZMTP 1.0 peers,
+	// and the major version first and the rest of the greeting once it
+	// knows the version of the peer.
+	write("ff00000000000000017f03")
+	expect("ff00000000000000007f0301" + "4e554c4c" +
+		strings.Repeat("00", 16) + "00" + strings.Repeat("00", 31))
+	write("00" + "4e554c4c" + strings.Repeat("00", 16) + "00" +
+		strings.Repeat("00", 31))
+
+	// READY commands with the socket types of both sides.
+	expect("0419055245414459" + "0b536f636b65742d54797065" +
+		"00000003505542")
+	write("0419055245414459" + "0b536f636b65742d54797065" +
+		"00000003535542")
+
+	// Subscribe to the hashblock topic with a ZMTP 3.0 subscription
+	// message, whose first byte is 0x01, and wait until it is processed
+	// since ZMTP 3.0 peers can't be synchronized with a PING.
+	write("000a01" + hex.EncodeToString([]byte("hashblock")))
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		p.mtx.Lock()
+		subscribed := false
+		for s := range p.subscribers {
+			subscribed = s.subscribed("hashblock")
+		}
+		p.mtx.Unlock()
+		if subscribed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("subscription was not processed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	p.publish([][]byte{[]byte("hashtx"), {1}, {0, 0, 0, 0}})
+	p.publish([][]byte{[]byte("hashblock"), {2}, {3, 0, 0, 0}})
+	expect("0109" + hex.EncodeToString([]byte("hashblock")) + "010102" +
+		"000403000000")
+}
+
+// TestSubscriberLimit ensures connections are closed once a publisher has the
+// maximum number of subscribers.
+func TestSubscriberLimit(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	p := newPublisher(listener)
+	p.maxSubscribers = 2
+	p.start()
+	defer p.stop()
+
+	sub1 := dialSubscriber(t, listener.Addr(), "SUB")
+	dialSubscriber(t, listener.Addr(), "SUB")
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("unable to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatalf("connection exceeding the subscriber limit was not " +
+			"closed")
+	}
+
+	// Subscribers are counted until they disconnect.
+	sub1.conn.Close()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		p.mtx.Lock()
+		numSubscribers := len(p.subscribers)
+		p.mtx.Unlock()
+		if numSubscribers == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("disconnected subscriber is still counted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	dialSubscriber(t, listener.Addr(), "SUB").sync()
+}
+
+// TestServer ensures block and transaction events are published on their
+// topics with the expected bodies and sequence numbers.
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	const endpoint = "tcp://127.0.0.1:0"
+	s, err := New(&Config{
+		HashBlock: endpoint,
+		RawBlock:  endpoint,
+		HashTx:    endpoint,
+		RawTx:     endpoint,
+		Sequence:  endpoint,
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	if len(s.publishers) != 1 {
+		t.Fatalf("got %d sockets for a single endpoint",
+			len(s.publishers))
+	}
+	s.Start()
+	defer s.Stop()
+
+	sub := dialSubscriber(t, s.publishers[0].listener.Addr(), "SUB")
+	sub.write(encodeCommand(cmdSubscribe, nil))
+	sub.sync()
+
+	block := eacutil.NewBlock(chaincfg.MainNetParams.GenesisBlock)
+	rawBlock, err := block.Bytes()
+	if err != nil {
+		t.Fatalf("unable to serialize block: %v", err)
+	}
+	tx := block.Transactions()[0]
+	var rawTx bytes.Buffer
+	if err := tx.MsgTx().Serialize(&rawTx); err != nil {
+		t.Fatalf("unable to serialize transaction: %v", err)
+	}
+	blockHash := reversedHash(block.Hash())
+	txHash := reversedHash(tx.Hash())
+	if blockHash[0] != block.Hash()[31] {
+		t.Fatalf("hash is not reversed")
+	}
+
+	// The transactions of connected blocks are published before the
+	// block.
+	s.HandleBlockchainNotification(&blockchain.Notification{
+		Type: blockchain.NTBlockConnected,
+		Data: block,
+	})
+	sub.assertMessage(TopicHashTx, txHash, 0)
+	sub.assertMessage(TopicRawTx, rawTx.Bytes(), 0)
+	sub.assertMessage(TopicSequence, append(blockHash, 'C'), 0)
+	sub.assertMessage(TopicHashBlock, blockHash, 0)
+	sub.assertMessage(TopicRawBlock, rawBlock, 0)
+
+	// Transactions accepted into the mempool are published with the
+	// mempool sequence number on the sequence topic.
+	s.TxAccepted(tx)
+	sub.assertMessage(TopicHashTx, txHash, 1)
+	sub.assertMessage(TopicRawTx, rawTx.Bytes(), 1)
+	sub.assertMessage(TopicSequence, append(txHash, 'A', 1, 0, 0, 0, 0, 0,
+		0, 0), 1)
+
+	// Removals due to blocks are not published, but they still advance
+	// the mempool sequence number.
+	s.TxRemoved(tx, mempool.RemovalReasonBlock)
+	s.TxRemoved(tx, mempool.RemovalReasonConflict)
+	sub.assertMessage(TopicSequence, append(txHash, 'R', 3, 0, 0, 0, 0, 0,
+		0, 0), 2)
+
+	// Disconnected blocks are only published on the sequence topic after
+	// their transactions, so the next message on the block topics is for
+	// the block being connected again.
+	s.HandleBlockchainNotification(&blockchain.Notification{
+		Type: blockchain.NTBlockDisconnected,
+		Data: block,
+	})
+	sub.assertMessage(TopicHashTx, txHash, 2)
+	sub.assertMessage(TopicRawTx, rawTx.Bytes(), 2)
+	sub.assertMessage(TopicSequence, append(blockHash, 'D'), 3)
+	s.HandleBlockchainNotification(&blockchain.Notification{
+		Type: blockchain.NTBlockConnected,
+		Data: block,
+	})
+	sub.assertMessage(TopicHashTx, txHash, 3)
+	sub.assertMessage(TopicRawTx, rawTx.Bytes(), 3)
+	sub.assertMessage(TopicSequence, append(blockHash, 'C'), 4)
+	sub.assertMessage(TopicHashBlock, blockHash, 1)
+	sub.assertMessage(TopicRawBlock, rawBlock, 1)
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// greetingLen is the length of the greeting ZMTP peers start the
+	// connection with.
+	greetingLen = 64
+
+	// signatureLen is the length of the signature at the start of the
+	// greeting.
+	signatureLen = 10
+
+	// versionMajor and versionMinor are the ZMTP version sent in the
+	// greeting.
+	versionMajor = 3
+	versionMinor = 1
+
+	// mechanismLen is the length of the zero padded security mechanism
+	// name in the greeting.
+	mechanismLen = 20
+
+	// mechanismNull is the name of the only supported security mechanism,
+	// which performs no authentication and no encryption.
+	mechanismNull = "NULL"
+
+	// Flags of the first byte of a frame.
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+
+	// maxFrameSize is the maximum size of the frames accepted from peers.
+	// Subscribers only send subscriptions and small commands, so larger
+	// frames are a protocol violation.
+	maxFrameSize = 4096
+
+	// socketTypeProperty is the name of the metadata property of the
+	// READY command holding the socket type of a peer.
+	socketTypeProperty = "Socket-Type"
+)
+
+// Names of the ZMTP commands.
+const (
+	cmdReady     = "READY"
+	cmdError     = "ERROR"
+	cmdSubscribe = "SUBSCRIBE"
+	cmdCancel    = "CANCEL"
+	cmdPing      = "PING"
+	cmdPong      = "PONG"
+)
+
+// greeting returns the greeting of a socket using the NULL mechanism.
+func greeting() []byte {
+	g := make([]byte, greetingLen)
+	g[0] = 0xff
+	g[signatureLen-1] = 0x7f
+	g[signatureLen] = versionMajor
+	g[signatureLen+1] = versionMinor
+	copy(g[signatureLen+2:], mechanismNull)
+	return g
+}
+
+// readGreeting reads the greeting of a peer and ensures it uses a supported
+// protocol version and the NULL mechanism.
+func readGreeting(r io.Reader) error {
+	var g [greetingLen]byte
+	if _, err := io.ReadFull(r, g[:signatureLen+1]); err != nil {
+		return err
+	}
+	if g[0] != 0xff || g[signatureLen-1] != 0x7f {
+		return errors.New("invalid greeting signature")
+	}
+	if g[signatureLen] < versionMajor {
+		return fmt.Errorf("unsupported ZMTP version %d",
+			g[signatureLen])
+	}
+	if _, err := io.ReadFull(r, g[signatureLen+1:]); err != nil {
+		return err
+	}
+
+	mechanism := g[signatureLen+2 : signatureLen+2+mechanismLen]
+	mechanism = bytes.TrimRight(mechanism, "\x00")
+	if string(mechanism) != mechanismNull {
+		return fmt.Errorf("unsupported security mechanism %q",
+			mechanism)
+	}
+	return nil
+}
+
+// frame is a single frame read from a peer.
+type frame struct {
+	more    bool
+	command bool
+	body    []byte
+}
+
+// appendFrame appends the encoding of a frame with the passed flags and body
+// to the passed buffer and returns the result.
+func appendFrame(buf []byte, flags byte, body []byte) []byte {
+	if len(body) > 0xff {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(body)))
+		buf = append(buf, flags|flagLong)
+		buf = append(buf, size[:]...)
+	} else {
+		buf = append(buf, flags, byte(len(body)))
+	}
+	return append(buf, body...)
+}
+
+// encodeMessage returns the encoding of a message made of the passed parts.
+func encodeMessage(parts [][]byte) []byte {
+	size := 0
+	for _, part := range parts {
+		size += 9 + len(part)
+	}
+	buf := make([]byte, 0, size)
+	for i, part := range parts {
+		var flags byte
+		if i < len(parts)-1 {
+			flags = flagMore
+		}
+		buf = appendFrame(buf, flags, part)
+	}
+	return buf
+}
+
+// encodeCommand returns the encoding of a command with the passed name and
+// data.
+func encodeCommand(name string, data []byte) []byte {
+	body := make([]byte, 0, 1+len(name)+len(data))
+	body = append(body, byte(len(name)))
+	body = append(body, name...)
+	body = append(body, data...)
+	return appendFrame(nil, flagCommand, body)
+}
+
+// readFrame reads a frame from the passed reader.  Frames larger than
+// maxFrameSize are rejected.
+func readFrame(r io.Reader) (*frame, error) {
+	var header [9]byte
+	if _, err := io.ReadFull(r, header[:2]); err != nil {
+		return nil, err
+	}
+	flags := header[0]
+	if flags&^(flagMore|flagLong|flagCommand) != 0 {
+		return nil, fmt.Errorf("invalid frame flags %#x", flags)
+	}
+
+	size := uint64(header[1])
+	if flags&flagLong != 0 {
+		if _, err := io.ReadFull(r, header[2:]); err != nil {
+			return nil, err
+		}
+		size = binary.BigEndian.Uint64(header[1:])
+	}
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("frame size %d exceeds maximum of %d",
+			size, maxFrameSize)
+	}
+
+	f := &frame{
+		more:    flags&flagMore != 0,
+		command: flags&flagCommand != 0,
+		body:    make([]byte, size),
+	}
+	if f.command && f.more {
+		return nil, errors.New("command frame with more flag")
+	}
+	if _, err := io.ReadFull(r, f.body); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parseCommand splits the body of a command frame into the name and the data
+// of the command.
+func parseCommand(body []byte) (string, []byte, error) {
+	if len(body) == 0 || int(body[0]) > len(body)-1 {
+		return "", nil, errors.New("malformed command")
+	}
+	nameLen := int(body[0])
+	return string(body[1 : 1+nameLen]), body[1+nameLen:], nil
+}
+
+// encodeMetadata returns the encoding of the passed metadata properties as
+// used by the READY command.
+func encodeMetadata(properties map[string]string) []byte {
+	var buf []byte
+	for name, value := range properties {
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(value)))
+		buf = append(buf, byte(len(name)))
+		buf = append(buf, name...)
+		buf = append(buf, size[:]...)
+		buf = append(buf, value...)
+	}
+	return buf
+}
+
+// parseMetadata parses the metadata properties of a READY command.  The names
+// of properties are case-insensitive, so they are returned in lower case.
+func parseMetadata(data []byte) (map[string]string, error) {
+	properties := make(map[string]string)
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 1+nameLen+4 {
+			return nil, errors.New("malformed metadata")
+		}
+		name := string(bytes.ToLower(data[1 : 1+nameLen]))
+		data = data[1+nameLen:]
+		valueLen := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(len(data)) < uint64(valueLen) {
+			return nil, errors.New("malformed metadata")
+		}
+		properties[name] = string(data[:valueLen])
+		data = data[valueLen:]
+	}
+	return properties, nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2020 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmq
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// TestGreeting ensures the greeting sent to peers is accepted by readGreeting
+// and that greetings of unsupported versions and mechanisms are rejected.
+func TestGreeting(t *testing.T) {
+	t.Parallel()
+
+	g := greeting()
+	if len(g) != greetingLen {
+		t.Fatalf("greeting: got %d bytes, want %d", len(g), greetingLen)
+	}
+	if err := readGreeting(bytes.NewReader(g)); err != nil {
+		t.Fatalf("readGreeting: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(g []byte)
+	}{
+		{"invalid signature", func(g []byte) { g[0] = 0 }},
+		{"ZMTP 2.0", func(g []byte) { g[signatureLen] = 2 }},
+		{"CURVE mechanism", func(g []byte) {
+			copy(g[signatureLen+2:], "CURVE")
+		}},
+	}
+	for _, test := range tests {
+		g := greeting()
+		test.modify(g)
+		if err := readGreeting(bytes.NewReader(g)); err == nil {
+			t.Errorf("%s: no error for invalid greeting", test.name)
+		}
+	}
+}
+
+// TestFrames ensures frames are encoded and decoded as expected.
+func TestFrames(t *testing.T) {
+	t.Parallel()
+
+	long := bytes.Repeat([]byte{0xab}, 300)
+	encoded := encodeMessage([][]byte{[]byte("hashtx"), long})
+	want := append([]byte{flagMore, 6}, "hashtx"...)
+	want = append(want, flagLong, 0, 0, 0, 0, 0, 0, 1, 0x2c)
+	want = append(want, long...)
+	if !bytes.Equal(encoded, want) {
+		t.Fatalf("encodeMessage: got %x, want %x", encoded, want)
+	}
+
+	r := bytes.NewReader(encoded)
+	f, err := readFrame(r)
+	if err != nil {
+		t.Fatalf("readFrame: unexpected error: %v", err)
+	}
+	if !f.more || f.command || string(f.body) != "hashtx" {
+		t.Fatalf("readFrame: unexpected first frame %+v", f)
+	}
+	f, err = readFrame(r)
+	if err != nil {
+		t.Fatalf("readFrame: unexpected error: %v", err)
+	}
+	if f.more || f.command || !bytes.Equal(f.body, long) {
+		t.Fatalf("readFrame: unexpected second frame %+v", f)
+	}
+
+	f, err = readFrame(bytes.NewReader(encodeCommand(cmdPing, []byte{0, 1})))
+	if err != nil {
+		t.Fatalf("readFrame: unexpected error: %v", err)
+	}
+	name, data, err := parseCommand(f.body)
+	if err != nil || !f.command || name != cmdPing ||
+		!bytes.Equal(data, []byte{0, 1}) {
+
+		t.Fatalf("unexpected command %q (%x, %v)", name, data, err)
+	}
+
+	invalid := [][]byte{
+		{0x08, 0},
+		{flagCommand | flagMore, 0},
+		{flagLong, 0, 0, 0, 0, 0, 0, 0x10, 0x01},
+		{0, 5, 1, 2},
+	}
+	for _, b := range invalid {
+		if _, err := readFrame(bytes.NewReader(b)); err == nil {
+			t.Errorf("readFrame: no error for invalid frame %x", b)
+		}
+	}
+}
+
+// TestMetadata ensures the metadata of READY commands is encoded and decoded
+// as expected.
+func TestMetadata(t *testing.T) {
+	t.Parallel()
+
+	encoded := encodeMetadata(map[string]string{"Socket-Type": "SUB"})
+	want := append([]byte{11}, "Socket-Type"...)
+	want = append(want, 0, 0, 0, 3)
+	want = append(want, "SUB"...)
+	if !bytes.Equal(encoded, want) {
+		t.Fatalf("encodeMetadata: got %x, want %x", encoded, want)
+	}
+
+	encoded = append(encoded, 8)
+	encoded = append(encoded, "Identity"...)
+	encoded = append(encoded, 0, 0, 0, 0)
+	properties, err := parseMetadata(encoded)
+	if err != nil {
+		t.Fatalf("parseMetadata: unexpected error: %v", err)
+	}
+	wantProperties := map[string]string{"socket-type": "SUB", "identity": ""}
+	if !reflect.DeepEqual(properties, wantProperties) {
+		t.Fatalf("parseMetadata: got %v, want %v", properties,
+			wantProperties)
+	}
+
+	if _, err := parseMetadata(want[:len(want)-1]); err == nil {
+		t.Fatalf("parseMetadata: no error for truncated metadata")
+	}
+}